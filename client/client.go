@@ -0,0 +1,359 @@
+// Package client is a Go client for the server's /api/v1 endpoints.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+)
+
+// Errors matched by errors.Is against an *APIError's code.
+var (
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrNotFound       = errors.New("not found")
+	ErrConflict       = errors.New("conflict")
+	ErrGone           = errors.New("gone")
+)
+
+// codeErrors maps the server's error codes to the sentinel errors above.
+var codeErrors = map[string]error{
+	"invalid_request":   ErrInvalidRequest,
+	"validation_failed": ErrInvalidRequest,
+	"not_found":         ErrNotFound,
+	"conflict":          ErrConflict,
+	"gone":              ErrGone,
+}
+
+// FieldError is one field-level problem reported with a validation failure.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"error"`
+}
+
+// APIError is returned for any non-2xx response. Code, Message and Fields
+// come from the server's JSON error envelope when one is present.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Fields     []FieldError
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("server returned %d", e.StatusCode)
+	}
+	return fmt.Sprintf("server returned %d %s: %s", e.StatusCode, e.Code, e.Detail())
+}
+
+// Detail returns Message followed by any field errors.
+func (e *APIError) Detail() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	fields := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		fields[i] = f.Field + " " + f.Message
+	}
+	return e.Message + ": " + strings.Join(fields, ", ")
+}
+
+func (e *APIError) Is(target error) bool {
+	return codeErrors[e.Code] == target
+}
+
+type UserData struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+}
+
+type User struct {
+	ID        string    `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Verified  bool      `json:"verified"`
+	Tags      []string  `json:"tags"`
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+	timeout    time.Duration
+	username   string
+	password   string
+	retry      RetryPolicy
+	onAttempt  func(Attempt)
+
+	// sleep waits between attempts; tests replace it.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the http.Client used for requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithTimeout bounds each call, in addition to any deadline on its context.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithBasicAuth sends Basic auth credentials with every request. The server
+// resolves username as a user's email; deleting users requires an admin.
+func WithBasicAuth(username string, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		userAgent:  "go-http-client",
+		sleep:      sleep,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Hello returns the greeting for name.
+func (c *Client) Hello(ctx context.Context, name string) (string, error) {
+	var resp greeting.Result
+	err := c.do(ctx, http.MethodGet, "/api/v1/hello?user="+url.QueryEscape(name), nil, &resp)
+	return resp.Greeting, err
+}
+
+func (c *Client) CreateUser(ctx context.Context, data UserData) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users", data, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *Client) GetUser(ctx context.Context, first string, last string) (*User, error) {
+	var user User
+	path := "/api/v1/users/" + url.PathEscape(first) + "/" + url.PathEscape(last)
+	if err := c.do(ctx, http.MethodGet, path, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// listPageSize is the page size ListUsers requests; it matches the server's
+// maximum.
+const listPageSize = 500
+
+// ListUsers returns every user, following pagination until the server
+// reports no more.
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var all []User
+	for {
+		var page struct {
+			Users      []User `json:"users"`
+			Pagination struct {
+				Total int `json:"total"`
+			} `json:"pagination"`
+		}
+		path := fmt.Sprintf("/api/v1/users?offset=%d&limit=%d", len(all), listPageSize)
+		if err := c.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Users...)
+		if len(page.Users) == 0 || len(all) >= page.Pagination.Total {
+			return all, nil
+		}
+	}
+}
+
+func (c *Client) DeleteUser(ctx context.Context, email string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/users/"+url.PathEscape(email), nil, nil)
+}
+
+// RestoreUser brings back a deleted user and returns it.
+func (c *Client) RestoreUser(ctx context.Context, email string) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users/"+url.PathEscape(email)+"/restore", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ImportResult counts what ImportArchive did with each archived user.
+type ImportResult struct {
+	Added       int `json:"added"`
+	Overwritten int `json:"overwritten"`
+	Skipped     int `json:"skipped"`
+}
+
+// ExportArchive writes every user to w as an archive for ImportArchive.
+// It requires an admin.
+func (c *Client) ExportArchive(ctx context.Context, w io.Writer) error {
+	var archive json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/api/v1/users/archive", nil, &archive); err != nil {
+		return err
+	}
+	_, err := w.Write(archive)
+	return err
+}
+
+// ImportArchive adds the users of an archive written by ExportArchive,
+// all or nothing. mode says what happens to users whose name or email is
+// taken: "skip", "overwrite" or, when empty, "fail". It requires an admin.
+func (c *Client) ImportArchive(ctx context.Context, r io.Reader, mode string) (*ImportResult, error) {
+	archive, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	path := "/api/v1/users/archive"
+	if mode != "" {
+		path += "?mode=" + url.QueryEscape(mode)
+	}
+	var result ImportResult
+	if err := c.do(ctx, http.MethodPost, path, json.RawMessage(archive), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response
+// into out, retrying it as c.retry allows. Non-2xx responses are returned
+// as *APIError.
+func (c *Client) do(ctx context.Context, method string, path string, body any, out any) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+	}
+
+	key := idempotencyKeyFrom(ctx)
+	canRetry := retryable(method, key)
+	for n := 1; ; n++ {
+		start := time.Now()
+		resp, err := c.attempt(ctx, method, path, data, key, out)
+
+		took := time.Since(start)
+
+		var delay time.Duration
+		retry := false
+		if canRetry {
+			delay, retry = c.retryDelay(ctx, n, resp, err, took)
+		}
+		if c.onAttempt != nil {
+			a := Attempt{Method: method, Path: path, Number: n, Err: err, Duration: took, Delay: delay}
+			if resp != nil {
+				a.StatusCode = resp.StatusCode
+			}
+			c.onAttempt(a)
+		}
+		if !retry {
+			return err
+		}
+		if c.sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// attempt sends a request once. It returns the response, whose body has
+// been consumed and closed, if one was received.
+func (c *Client) attempt(ctx context.Context, method string, path string, data []byte, key string, out any) (*http.Response, error) {
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := decodeError(resp)
+		// Drain what the envelope left so the connection can be reused.
+		io.Copy(io.Discard, resp.Body)
+		return resp, err
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return resp, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp, fmt.Errorf("error decoding response: %w", err)
+	}
+	return resp, nil
+}
+
+func decodeError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var envelope struct {
+		Error struct {
+			Code    string       `json:"code"`
+			Message string       `json:"message"`
+			Fields  []FieldError `json:"fields"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err == nil {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.Fields = envelope.Error.Fields
+	}
+	return apiErr
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOptions(t *testing.T) {
+	var gotAgent, gotUser, gotPassword string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAgent = r.Header.Get("User-Agent")
+		gotUser, gotPassword, _ = r.BasicAuth()
+		if r.URL.Query().Get("user") == "slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(`{"greeting":"hi"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(srv.URL+"/", WithHTTPClient(srv.Client()), WithUserAgent("test-agent"), WithTimeout(50*time.Millisecond),
+		WithBasicAuth("admin@example.com", "secret"))
+
+	if _, err := c.Hello(context.Background(), "fast"); err != nil {
+		t.Fatalf("Hello: %v", err)
+	}
+	if gotAgent != "test-agent" {
+		t.Errorf("expected user agent %q, got %q", "test-agent", gotAgent)
+	}
+	if gotUser != "admin@example.com" || gotPassword != "secret" {
+		t.Errorf("bad basic auth: got %q/%q", gotUser, gotPassword)
+	}
+
+	if _, err := c.Hello(context.Background(), "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestNonJSONError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := New(srv.URL).Hello(context.Background(), "alice")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Code != "" {
+		t.Errorf("expected bare 502 APIError, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy says how often and how patiently a Client retries a request
+// that failed in a way a retry may fix: a transport error, or a 408, 429,
+// 502, 503 or 504 response. Only idempotent requests are retried: GET,
+// HEAD and OPTIONS, and requests whose context carries an idempotency key.
+// No attempt starts after the context's deadline, nor is one scheduled to
+// start past it.
+type RetryPolicy struct {
+	// MaxAttempts caps the attempts of one call, counting the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// BaseDelay is the backoff before the first retry. It doubles for each
+	// retry after that, up to MaxDelay, and each wait is jittered down to
+	// as little as half of it.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy retries up to three times, starting at 100ms.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// Attempt describes one try of a call, for the WithAttemptHook hook.
+type Attempt struct {
+	Method string
+	Path   string
+
+	// Number is 1 for the first try of a call.
+	Number int
+
+	// StatusCode is the response status, or 0 when none was received.
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+
+	// Delay is the wait before the next attempt, or 0 when there is none.
+	Delay time.Duration
+}
+
+// WithRetry retries idempotent requests according to p. Clients do not
+// retry by default.
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// WithAttemptHook calls hook after every attempt of every call, including
+// calls that are not retried, for logging and metrics. It runs on the
+// calling goroutine.
+func WithAttemptHook(hook func(Attempt)) Option {
+	return func(c *Client) {
+		c.onAttempt = hook
+	}
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context under which calls send key as their
+// Idempotency-Key header. The server replays its first response to a
+// repeated key, so calls made with one may be retried whatever their method.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// retryable reports whether a request may be sent again.
+func retryable(method string, key string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return key != ""
+}
+
+// retryableStatus reports whether a response with status is worth retrying.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the wait before retry n, counting from 1.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 {
+		d = min(d, p.MaxDelay)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter returns the wait a 429 or 503 response asks for in its
+// Retry-After header, as seconds or an HTTP date, or 0 when it asks for
+// none.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	value := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryDelay returns the wait before the attempt after attempt n, and
+// whether there should be one at all. err is the error of attempt n, resp
+// its response if one was received, and took how long it lasted: there is
+// no next attempt unless the wait and one as long fit before ctx's
+// deadline.
+func (c *Client) retryDelay(ctx context.Context, n int, resp *http.Response, err error, took time.Duration) (time.Duration, bool) {
+	if n >= c.retry.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+	switch {
+	case resp != nil && !retryableStatus(resp.StatusCode):
+		return 0, false
+	case resp == nil && (err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+		return 0, false
+	}
+
+	delay := c.retry.backoff(n)
+	if resp != nil {
+		delay = max(delay, retryAfter(resp, time.Now()))
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay+took {
+		return 0, false
+	}
+	return delay, true
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests with status, setting
+// Retry-After to retryAfter when it is not empty, and greets after that.
+func flakyServer(t *testing.T, failures int32, status int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			http.Error(w, `{"error":{"code":"unavailable","message":"try later"}}`, status)
+			return
+		}
+		w.Write([]byte(`{"greeting":"hi","id":"x"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// recordSleeps makes c record its waits between attempts instead of
+// sleeping.
+func recordSleeps(c *Client) *[]time.Duration {
+	var slept []time.Duration
+	c.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	return &slept
+}
+
+func TestRetryRecovers(t *testing.T) {
+	srv, calls := flakyServer(t, 2, http.StatusServiceUnavailable, "")
+
+	var attempts []Attempt
+	c := New(srv.URL, WithRetry(RetryPolicy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}),
+		WithAttemptHook(func(a Attempt) { attempts = append(attempts, a) }))
+	slept := recordSleeps(c)
+
+	got, err := c.Hello(context.Background(), "alice")
+	if err != nil || got != "hi" {
+		t.Fatalf("expected a greeting after retries, got %q, %v", got, err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 requests, got %d", calls.Load())
+	}
+
+	wantStatus := []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK}
+	if len(attempts) != len(wantStatus) {
+		t.Fatalf("expected %d attempts reported, got %+v", len(wantStatus), attempts)
+	}
+	for i, a := range attempts {
+		if a.Number != i+1 || a.StatusCode != wantStatus[i] || a.Method != http.MethodGet {
+			t.Errorf("attempt %d: got %+v", i+1, a)
+		}
+		if (a.Err == nil) != (i == 2) {
+			t.Errorf("attempt %d: unexpected error %v", i+1, a.Err)
+		}
+	}
+	if attempts[2].Delay != 0 {
+		t.Errorf("last attempt reports a delay of %v", attempts[2].Delay)
+	}
+
+	// Backoff doubles, jittered down to no less than half.
+	if len(*slept) != 2 {
+		t.Fatalf("expected 2 waits, got %v", *slept)
+	}
+	for i, d := range *slept {
+		ceiling := 100 * time.Millisecond << i
+		if d < ceiling/2 || d > ceiling {
+			t.Errorf("wait %d: expected %v to %v, got %v", i+1, ceiling/2, ceiling, d)
+		}
+		if attempts[i].Delay != d {
+			t.Errorf("attempt %d reports delay %v, waited %v", i+1, attempts[i].Delay, d)
+		}
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	srv, calls := flakyServer(t, 1000, http.StatusTooManyRequests, "3")
+
+	c := New(srv.URL, WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+	slept := recordSleeps(c)
+
+	_, err := c.Hello(context.Background(), "alice")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Code != "unavailable" {
+		t.Fatalf("expected the last 429, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 requests, got %d", calls.Load())
+	}
+	if len(*slept) != 2 || (*slept)[0] != 3*time.Second || (*slept)[1] != 3*time.Second {
+		t.Errorf("expected two 3s waits, got %v", *slept)
+	}
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+	// Retry-After asks for longer than the deadline allows: there is no
+	// point waiting for a retry that cannot happen.
+	srv, calls := flakyServer(t, 1000, http.StatusServiceUnavailable, "10")
+	c := New(srv.URL, WithRetry(DefaultRetryPolicy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.Hello(ctx, "alice")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the 503, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("gave up after %v, expected at once", elapsed)
+	}
+
+	// Backoff alone runs into the deadline after a few attempts. The last
+	// 502 is returned rather than the deadline cutting a wait or an attempt
+	// short.
+	srv, calls = flakyServer(t, 1000, http.StatusBadGateway, "")
+	c = New(srv.URL, WithRetry(RetryPolicy{MaxAttempts: 100, BaseDelay: 40 * time.Millisecond, MaxDelay: 40 * time.Millisecond}),
+		WithTimeout(300*time.Millisecond))
+
+	_, err = c.Hello(context.Background(), "alice")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected the 502, got %v", err)
+	}
+	if n := calls.Load(); n < 2 || n >= 100 {
+		t.Errorf("expected a few attempts before the deadline, got %d", n)
+	}
+}
+
+func TestRetryOnlyIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if calls.Add(1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"first_name":"ada"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(srv.URL, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	recordSleeps(c)
+	data := UserData{FirstName: "ada", LastName: "lovelace", Email: "ada@example.com"}
+
+	if _, err := c.CreateUser(context.Background(), data); err == nil {
+		t.Error("expected the 503 of a POST without an idempotency key")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("POST without a key retried: %d requests", calls.Load())
+	}
+
+	keys = nil
+	calls.Store(0)
+	user, err := c.CreateUser(WithIdempotencyKey(context.Background(), "create-ada"), data)
+	if err != nil || user.FirstName != "ada" {
+		t.Fatalf("expected a retried create to succeed, got %+v, %v", user, err)
+	}
+	if len(keys) != 2 || keys[0] != "create-ada" || keys[1] != "create-ada" {
+		t.Errorf("expected the key on both attempts, got %q", keys)
+	}
+}
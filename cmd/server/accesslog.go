@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/config"
+	"github.com/kunalkumar-1/go-http/internal/rotate"
+)
+
+// openAccessLog opens the rotating file named by cfg.AccessLog.
+func openAccessLog(cfg *config.Config) (*rotate.Writer, error) {
+	return rotate.Open(cfg.AccessLog, rotate.Options{
+		MaxSize:    int64(cfg.AccessLogMaxSize) << 20,
+		MaxBackups: cfg.AccessLogMaxBackups,
+		Compress:   cfg.AccessLogCompress,
+	})
+}
+
+// newAccessLogger logs one JSON object per line to w. Access log lines have
+// no level, so it is left out.
+func newAccessLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.LevelKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// accessLogWriter records the status and body size of a response.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (aw *accessLogWriter) WriteHeader(code int) {
+	if aw.status == 0 {
+		aw.status = code
+	}
+	aw.ResponseWriter.WriteHeader(code)
+}
+
+func (aw *accessLogWriter) Write(b []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	n, err := aw.ResponseWriter.Write(b)
+	aw.bytes += int64(n)
+	return n, err
+}
+
+func (aw *accessLogWriter) Unwrap() http.ResponseWriter {
+	return aw.ResponseWriter
+}
+
+// withAccessLog writes a line to s.accessLog for every request once it has
+// been served. It sits inside withRealIP so the client address is the
+// resolved one.
+func (s *Server) withAccessLog(next http.Handler) http.Handler {
+	if s.accessLog == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		aw := &accessLogWriter{ResponseWriter: w}
+		next.ServeHTTP(aw, r)
+
+		status := aw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		s.accessLog.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", aw.bytes,
+			"duration_ms", float64(time.Since(start))/float64(time.Millisecond),
+			"client", clientIPFrom(r.Context()),
+			"user_agent", r.UserAgent(),
+		)
+	})
+}
+
+// reopenOnHangup reopens the access log each time hup delivers, so that
+// logrotate can move the file away and signal the process to start a new
+// one.
+func reopenOnHangup(logger *slog.Logger, hup <-chan os.Signal, w *rotate.Writer) {
+	for range hup {
+		if err := w.Reopen(); err != nil {
+			logger.Error("error reopening access log", "err", err)
+			continue
+		}
+		logger.Info("reopened access log")
+	}
+}
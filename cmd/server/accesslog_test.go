@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/rotate"
+)
+
+type accessLogLine struct {
+	Level    string  `json:"level"`
+	Method   string  `json:"method"`
+	Path     string  `json:"path"`
+	Status   int     `json:"status"`
+	Bytes    int64   `json:"bytes"`
+	Duration float64 `json:"duration_ms"`
+	Client   string  `json:"client"`
+}
+
+func TestAccessLog(t *testing.T) {
+	s := newTestServer(t)
+	var buf bytes.Buffer
+	s.accessLog = newAccessLogger(&buf)
+	h := s.Routes()
+
+	for _, target := range []string{"/hello/?user=alice", "/nope"} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.RemoteAddr = "192.0.2.7:1234"
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per request, got %q", lines)
+	}
+
+	var got [2]accessLogLine
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &got[i]); err != nil {
+			t.Fatalf("line %d is not JSON: %v\n%s", i, err, line)
+		}
+	}
+	if got[0].Method != http.MethodGet || got[0].Path != "/hello/" || got[0].Status != http.StatusOK || got[0].Bytes == 0 {
+		t.Errorf("bad line for /hello/: %+v", got[0])
+	}
+	if got[0].Client != "192.0.2.7" {
+		t.Errorf("expected client 192.0.2.7, got %q", got[0].Client)
+	}
+	if got[0].Level != "" {
+		t.Errorf("access log lines should have no level, got %q", got[0].Level)
+	}
+	if got[1].Path != "/nope" || got[1].Status != http.StatusNotFound {
+		t.Errorf("bad line for /nope: %+v", got[1])
+	}
+}
+
+func TestReopenAccessLogOnHangup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	w, err := rotate.Open(path, rotate.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go reopenOnHangup(slog.New(slog.DiscardHandler), hup, w)
+
+	io.WriteString(w, "before\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("access log not reopened after SIGHUP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	io.WriteString(w, "after\n")
+
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "before\n" {
+		t.Errorf("bad rotated file: %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "after\n" {
+		t.Errorf("bad reopened file: %q", data)
+	}
+}
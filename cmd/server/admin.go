@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"google.golang.org/grpc"
+)
+
+// AdminRoutes builds the mux served on the admin address. It is meant to be
+// reachable only from inside the cluster and is never mounted on the public
+// listener. It panics if two routes conflict, which is a wiring bug.
+func (s *Server) AdminRoutes() http.Handler {
+	h, err := s.buildAdminRoutes()
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// buildAdminRoutes is AdminRoutes, returning a conflict between two routes
+// as an error.
+func (s *Server) buildAdminRoutes() (http.Handler, error) {
+	mux := http.NewServeMux()
+	routes := newRouteRegistry("admin", mux)
+	handle := func(pattern string, h http.HandlerFunc) {
+		routes.handle(pattern, "", h, h)
+	}
+
+	handle("GET /metrics", s.handleMetrics)
+	handle("GET /log-level", s.handleLogLevel)
+	handle("PUT /log-level", s.handleLogLevel)
+	handle("GET /admin/audit", s.handleAudit)
+	handle("GET /admin/routes", s.handleRoutes)
+	handle("POST /admin/reload", s.handleReload)
+
+	if s.enablePprof {
+		handle("/debug/pprof/", pprof.Index)
+		handle("/debug/pprof/cmdline", pprof.Cmdline)
+		handle("/debug/pprof/profile", pprof.Profile)
+		handle("/debug/pprof/symbol", pprof.Symbol)
+		handle("/debug/pprof/trace", pprof.Trace)
+	}
+
+	if routes.err != nil {
+		return nil, routes.err
+	}
+	s.adminTable = routes.routes
+
+	return s.middleware(groupAdmin).Then(withErrorHandlers(mux)), nil
+}
+
+// AdminHTTPServer returns the http.Server for the admin address.
+func (s *Server) AdminHTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           s.AdminRoutes(),
+		ReadHeaderTimeout: s.timeouts.ReadHeader,
+		ReadTimeout:       s.timeouts.Read,
+		WriteTimeout:      s.timeouts.Write,
+		IdleTimeout:       s.timeouts.Idle,
+	}
+}
+
+// handleMetrics writes gauges in the Prometheus text exposition format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	rev, err := s.store.Revision(r.Context())
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	entries, bytes := s.exports.stats()
+
+	var buf strings.Builder
+	if s.storeMetrics != nil {
+		s.storeMetrics.writeTo(&buf)
+	} else {
+		all, err := s.store.List(r.Context())
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+		fmt.Fprintf(&buf, "# TYPE users_total gauge\nusers_total %d\n", len(all))
+	}
+	fmt.Fprintf(&buf, "# TYPE users_revision gauge\nusers_revision %d\n", rev)
+	fmt.Fprintf(&buf, "# TYPE export_cache_entries gauge\nexport_cache_entries %d\n", entries)
+	fmt.Fprintf(&buf, "# TYPE export_cache_bytes gauge\nexport_cache_bytes %d\n", bytes)
+	fmt.Fprintf(&buf, "# TYPE http_requests_in_flight gauge\nhttp_requests_in_flight %d\n", s.limiter.inFlight())
+	fmt.Fprintf(&buf, "# TYPE http_requests_shed_total counter\nhttp_requests_shed_total %d\n", s.limiter.shedCount())
+	fmt.Fprintf(&buf, "# TYPE http_body_read_timeouts_total counter\nhttp_body_read_timeouts_total %d\n", s.bodyReadTimeouts.Load())
+	fmt.Fprintf(&buf, "# TYPE http_stream_aborts_total counter\n")
+	fmt.Fprintf(&buf, "http_stream_aborts_total{reason=\"client_gone\"} %d\n", s.streamAborts.clientGone.Load())
+	fmt.Fprintf(&buf, "http_stream_aborts_total{reason=\"error\"} %d\n", s.streamAborts.failed.Load())
+	s.routeStats.writeSlowRequests(&buf)
+	httpx.Write(w, http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(buf.String()))
+}
+
+// listener pairs an http.Server, or for the gRPC listener a grpc.Server,
+// with the socket it serves. TLS listeners use srv.TLSConfig when certFile
+// and keyFile are empty.
+type listener struct {
+	name     string
+	srv      *http.Server
+	grpc     *grpc.Server
+	ln       net.Listener
+	tls      bool
+	certFile string
+	keyFile  string
+}
+
+func (l listener) serve() error {
+	if l.grpc != nil {
+		if err := l.grpc.Serve(l.ln); err != nil {
+			return err
+		}
+		// Serve returns nil only once the server was stopped.
+		return http.ErrServerClosed
+	}
+	if l.tls {
+		return l.srv.ServeTLS(l.ln, l.certFile, l.keyFile)
+	}
+	return l.srv.Serve(l.ln)
+}
+
+// shutdown stops l gracefully, waiting for in-flight requests until ctx is
+// done. A gRPC server still busy then is stopped outright.
+func (l listener) shutdown(ctx context.Context) error {
+	if l.grpc == nil {
+		return l.srv.Shutdown(ctx)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		l.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		l.grpc.Stop()
+		return ctx.Err()
+	}
+}
+
+// serveAll serves every listener until ctx is done or one of them fails,
+// then calls shutdown, which is expected to stop the listeners; see
+// stopListeners.
+func serveAll(ctx context.Context, logger *slog.Logger, shutdown func(context.Context) error, listeners ...listener) error {
+	errc := make(chan error, len(listeners))
+	for _, l := range listeners {
+		logger.Info("listening", "server", l.name, "addr", l.ln.Addr().String(), "tls", l.tls)
+		go func() {
+			if err := l.serve(); !errors.Is(err, http.ErrServerClosed) {
+				errc <- fmt.Errorf("%s server: %w", l.name, err)
+			}
+		}()
+	}
+
+	var serveErr error
+	select {
+	case serveErr = <-errc:
+	case <-ctx.Done():
+		logger.Info("shutting down")
+	}
+
+	return errors.Join(serveErr, shutdown(context.WithoutCancel(ctx)))
+}
+
+// listen opens the socket for srv. It is separate from serving so that
+// address errors are reported before anything starts.
+func listen(name string, srv *http.Server, tlsConfig *tls.Config, certFile string, keyFile string) (listener, error) {
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return listener{}, fmt.Errorf("%s server: %w", name, err)
+	}
+
+	srv.TLSConfig = tlsConfig
+	return listener{
+		name:     name,
+		srv:      srv,
+		ln:       ln,
+		tls:      tlsConfig != nil || certFile != "",
+		certFile: certFile,
+		keyFile:  keyFile,
+	}, nil
+}
+
+// listenGRPC opens the socket for the gRPC server srv at addr.
+func listenGRPC(name string, srv *grpc.Server, addr string) (listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return listener{}, fmt.Errorf("%s server: %w", name, err)
+	}
+	return listener{name: name, grpc: srv, ln: ln}, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startListeners(t *testing.T, s *Server) (publicURL string, adminURL string, stop func() error) {
+	t.Helper()
+
+	public, err := listen("public", s.HTTPServer("127.0.0.1:0"), nil, "", "")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	admin, err := listen("admin", s.AdminHTTPServer("127.0.0.1:0"), nil, "", "")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		s.OnShutdown("stop listeners", time.Second, stopListeners(public, admin))
+		done <- serveAll(ctx, slog.New(slog.DiscardHandler), s.Shutdown, public, admin)
+	}()
+
+	stop = func() error {
+		cancel()
+		return <-done
+	}
+	return "http://" + public.ln.Addr().String(), "http://" + admin.ln.Addr().String(), stop
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("error requesting %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestAdminListenerSeparation(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.users.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+	publicURL, adminURL, stop := startListeners(t, s)
+
+	if code, _ := get(t, publicURL+"/metrics"); code != http.StatusNotFound {
+		t.Errorf("/metrics on public port: expected %d, got %d", http.StatusNotFound, code)
+	}
+
+	code, body := get(t, adminURL+"/metrics")
+	if code != http.StatusOK || !strings.Contains(body, "users_total 1\n") || !strings.Contains(body, "http_requests_in_flight ") {
+		t.Errorf("/metrics on admin port: %d %q", code, body)
+	}
+
+	if code, _ := get(t, adminURL+"/hello/"); code != http.StatusNotFound {
+		t.Errorf("public route on admin port: expected %d, got %d", http.StatusNotFound, code)
+	}
+	if code, _ := get(t, adminURL+"/debug/pprof/"); code != http.StatusNotFound {
+		t.Errorf("pprof served without -enable-pprof: got %d", code)
+	}
+
+	if err := stop(); err != nil {
+		t.Errorf("error shutting down: %v", err)
+	}
+	if _, err := http.Get(publicURL + "/"); err == nil {
+		t.Error("public listener still serving after shutdown")
+	}
+	if _, err := http.Get(adminURL + "/metrics"); err == nil {
+		t.Error("admin listener still serving after shutdown")
+	}
+}
+
+func TestAdminPprof(t *testing.T) {
+	s := newTestServer(t)
+	s.enablePprof = true
+	publicURL, adminURL, stop := startListeners(t, s)
+	defer stop()
+
+	if code, _ := get(t, adminURL+"/debug/pprof/"); code != http.StatusOK {
+		t.Errorf("pprof on admin port: expected %d, got %d", http.StatusOK, code)
+	}
+	if code, _ := get(t, publicURL+"/debug/pprof/"); code != http.StatusNotFound {
+		t.Errorf("pprof on public port: expected %d, got %d", http.StatusNotFound, code)
+	}
+}
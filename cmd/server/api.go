@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// apiVersion describes how a mounted API version encodes its responses.
+// Handlers take an apiVersion so a new version can reuse their logic with a
+// different encoder.
+type apiVersion struct {
+	prefix        string
+	writeGreeting func(w http.ResponseWriter, gs []greeting.Result)
+
+	// multiGreet allows several user parameters in one hello request. All of
+	// the greetings are passed to writeGreeting together.
+	multiGreet bool
+}
+
+// apiV0 is the unversioned plain-text API.
+var apiV0 = apiVersion{
+	multiGreet: true,
+	writeGreeting: func(w http.ResponseWriter, gs []greeting.Result) {
+		httpx.WriteTextFunc(w, http.StatusOK, func(body *bytes.Buffer) {
+			for _, g := range gs {
+				body.WriteString(g.Greeting)
+				body.WriteByte('\n')
+			}
+		})
+	},
+}
+
+var apiV1 = apiVersion{
+	prefix: "/api/v1",
+	writeGreeting: func(w http.ResponseWriter, gs []greeting.Result) {
+		// A pointer spares the encoder copying the response.
+		resp := newGreetingResponse(gs[0])
+		httpx.WriteJSON(w, http.StatusOK, &resp)
+	},
+}
+
+// mountAPI registers the versioned routes for v under its prefix.
+func (s *Server) mountAPI(handle func(pattern string, h http.HandlerFunc), v apiVersion) {
+	handle("GET "+v.prefix+"/hello", s.handleHelloQuery(v))
+	handle("GET "+v.prefix+"/hello/{user}", s.handleHelloPath(v))
+	handle("GET "+v.prefix+"/users", s.handleListUsers)
+	handle("POST "+v.prefix+"/users", requireJSON(s.withIdempotency(s.handleCreateUser)))
+	handle("POST "+v.prefix+"/users/batch", requireJSON(s.handleCreateUsersBatch))
+	handle("GET "+v.prefix+"/users/archive", s.requireRole(users.RoleAdmin, s.handleUsersArchive))
+	handle("POST "+v.prefix+"/users/archive", s.requireRole(users.RoleAdmin, requireJSON(s.handleImportArchive)))
+	handle("GET "+v.prefix+"/users/search", s.handleSearchUsers)
+	handle("GET "+v.prefix+"/users/inactive", s.handleInactiveUsers)
+	handle("GET "+v.prefix+"/users/verify", s.handleVerifyUser)
+	handle("GET "+v.prefix+"/users/domains", s.handleUserDomains)
+	handle("GET "+v.prefix+"/users/by-id", s.handleGetUserByID)
+	handle("GET "+v.prefix+"/users/{email}", s.handleGetUser)
+	handle("GET "+v.prefix+"/users/{email}/greetings", s.handleGreetingHistory)
+	handle("GET "+v.prefix+"/users/{first}/{last}", s.handleGetUserByName)
+	handle("PUT "+v.prefix+"/users/{email}", s.requireRole(users.RoleAdmin, requireJSON(s.handleUpdateUser)))
+	handle("DELETE "+v.prefix+"/users/{email}", s.requireRole(users.RoleAdmin, s.handleDeleteUser))
+	handle("POST "+v.prefix+"/users/{email}/restore", s.requireRole(users.RoleAdmin, s.handleRestoreUser))
+	handle("POST "+v.prefix+"/logout", s.handleLogout)
+}
+
+// maxHelloNames caps the distinct user parameters greeted in one request.
+const maxHelloNames = 20
+
+// errEmptyUsername is reported when a user is named but the name is empty
+// or only whitespace. An absent name is not an error; each variant decides
+// its own default.
+var errEmptyUsername = greeting.ErrEmptyName
+
+// checkUsername is the validation shared by the query, path and header
+// hello variants: the name rules of the user store, with an empty name
+// reported as errEmptyUsername.
+func (s *Server) checkUsername(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errEmptyUsername
+	}
+	return s.users.NameRules().Validate(name)
+}
+
+// helloNames returns the user query parameters in order, skipping empty
+// values and exact repeats. It fails with errEmptyUsername when the
+// parameter is present but none of its values is usable, and with the
+// checkUsername error of the first invalid one.
+func (s *Server) helloNames(r *http.Request) ([]string, error) {
+	values := queryValues(r.URL.RawQuery, "user")
+
+	var names []string
+	var seen map[string]bool
+	if len(values) > 1 {
+		seen = make(map[string]bool, len(values))
+	}
+	for _, name := range values {
+		if strings.TrimSpace(name) == "" || seen[name] {
+			continue
+		}
+		if err := s.checkUsername(name); err != nil {
+			return nil, err
+		}
+		if seen != nil {
+			seen[name] = true
+		}
+		names = append(names, name)
+	}
+
+	if len(values) > 0 && len(names) == 0 {
+		return nil, errEmptyUsername
+	}
+	if len(names) > maxHelloNames {
+		return nil, fmt.Errorf("at most %d user parameters are allowed", maxHelloNames)
+	}
+	return names, nil
+}
+
+// queryValues returns the values of key in rawQuery as url.ParseQuery
+// would, skipping the pairs it rejects, without building a map of every
+// parameter.
+func queryValues(rawQuery string, key string) []string {
+	var values []string
+	for rawQuery != "" {
+		var pair string
+		pair, rawQuery, _ = strings.Cut(rawQuery, "&")
+		if pair == "" || strings.Contains(pair, ";") {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(k); err != nil || k != key {
+			continue
+		}
+		v, err := url.QueryUnescape(v)
+		if err != nil {
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// handleHelloQuery greets the users named by the user query parameters and
+// remembers the first in the session cookie. Without the parameter it greets
+// the remembered user, or else answers as greetMissing does. Versions
+// without multiGreet accept a single name.
+func (s *Server) handleHelloQuery(v apiVersion) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.logRequest(r)
+
+		names, err := s.helloNames(r)
+		if err != nil {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		if len(names) > 1 && !v.multiGreet {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "only one user parameter is allowed")
+			return
+		}
+
+		if len(names) > 0 {
+			s.rememberUser(w, r, names[0])
+		} else if remembered, ok := s.rememberedUser(r); ok {
+			names = []string{remembered}
+		} else {
+			s.greetMissing(w, r, v)
+			return
+		}
+		for _, name := range names {
+			s.recordGreetingByName(name)
+		}
+
+		s.greet(w, r, v, names...)
+	}
+}
+
+// handleHelloPath greets the user named by the {user} path segment.
+func (s *Server) handleHelloPath(v apiVersion) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.logRequest(r)
+
+		username := r.PathValue("user")
+		if err := s.checkUsername(username); err != nil {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+
+		s.recordGreetingByName(username)
+		s.greet(w, r, v, username)
+	}
+}
+
+// legacyPolicy controls the deprecation headers sent on v0 routes.
+type legacyPolicy struct {
+	deprecated bool
+	sunset     time.Time
+}
+
+// withLegacyHeaders marks v0 responses as deprecated, and announces the
+// sunset date when one is configured.
+func (s *Server) withLegacyHeaders(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.legacy.deprecated {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", `</api/v1>; rel="successor-version"`)
+		}
+		if !s.legacy.sunset.IsZero() {
+			w.Header().Set("Sunset", s.legacy.sunset.UTC().Format(http.TimeFormat))
+		}
+		h(w, r)
+	}
+}
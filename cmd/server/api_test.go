@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestGreetingAcrossVersions(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/?user=alice", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "Hello alice!\n" {
+		t.Errorf("bad v0 response: %d %q", w.Code, w.Body.String())
+	}
+
+	for _, target := range []string{"/api/v1/hello?user=alice", "/api/v1/hello/alice"} {
+		w = httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Accept-Language", "fr")
+		handler.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: bad response code: expected %d, got %d", target, http.StatusOK, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: bad content type: %q", target, ct)
+		}
+
+		var got greeting.Result
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: error decoding response: %v", target, err)
+		}
+		want := greeting.Result{Greeting: "Bonjour alice !", Language: "fr"}
+		if got != want {
+			t.Errorf("%s: expected %+v, got %+v", target, want, got)
+		}
+	}
+}
+
+func TestAPIv1Users(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/users",
+		strings.NewReader(`{"first_name":"jhon","last_name":"smith","email":"jhon@bar.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("bad response code: expected %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+	var list userListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Users) != 1 {
+		t.Errorf("bad list response: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLegacyDeprecationHeaders(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.Routes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/", nil))
+	if w.Header().Get("Deprecation") != "" || w.Header().Get("Sunset") != "" {
+		t.Errorf("deprecation headers sent without configuration: %v", w.Header())
+	}
+
+	s.legacy = legacyPolicy{deprecated: true, sunset: time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	handler = s.Routes()
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/", nil))
+	if got := w.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("bad Deprecation header: %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Fri, 01 Jan 2027 00:00:00 GMT" {
+		t.Errorf("bad Sunset header: %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/hello", nil))
+	if w.Header().Get("Deprecation") != "" || w.Header().Get("Sunset") != "" {
+		t.Errorf("deprecation headers sent on v1: %v", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Header().Get("Deprecation") != "" {
+		t.Errorf("deprecation header sent on /health")
+	}
+}
+
+func TestV1HelloSingleName(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/hello?user=alice&user=bob", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+			http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeInvalidRequest, "only one user parameter is allowed")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/hello?user=alice&user=alice&user=", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+			http.StatusOK, w.Code, w.Body.String())
+	}
+}
+
+func TestHelloValidatesNames(t *testing.T) {
+	s := NewServer(newTestServer(t).logger, users.NewManager(users.WithNameRules(users.NameRules{RejectDigits: true})))
+	handler := s.Routes()
+
+	requests := map[string]func(name string) *http.Request{
+		"query": func(name string) *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/api/v1/hello?"+url.Values{"user": {name}}.Encode(), nil)
+		},
+		"path": func(name string) *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/api/v1/hello/"+url.PathEscape(name), nil)
+		},
+		"header": func(name string) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/user/hello", nil)
+			r.Header.Set("user", name)
+			return r
+		},
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"alice", ""},
+		{"Zoë", ""},
+		{strings.Repeat("a", users.MaxNameLength+1), "must be at most 100 characters"},
+		{"alice\x1b[31m", "must not contain control characters"},
+		{"alice2", "must not contain digits"},
+	}
+	for variant, request := range requests {
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request(tt.name))
+			if tt.want == "" {
+				if w.Code != http.StatusOK {
+					t.Errorf("%s %q: bad response code: expected %d, got %d\nbody: %s\n", variant, tt.name, http.StatusOK, w.Code, w.Body.String())
+				}
+				continue
+			}
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("%s %q: expected 400 with %q, got %d\nbody: %s\n", variant, tt.name, tt.want, w.Code, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestQueryValues(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"user=alice",
+		"user=alice&user=bob&other=x",
+		"user=",
+		"user",
+		"us%65r=alice+smith&user=%C3%A9",
+		"user=%zz&user=ok",
+		"user=a;b&user=c",
+		"&&user=alice&",
+		"x=1&%zz=2&user=alice",
+	} {
+		// ParseQuery skips the pairs it reports errors for.
+		want, _ := url.ParseQuery(raw)
+		if got := queryValues(raw, "user"); !slices.Equal(got, want["user"]) {
+			t.Errorf("%q: expected %q, got %q", raw, want["user"], got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// maxArchiveBytes caps the body of POST /users/archive.
+const maxArchiveBytes = 32 << 20
+
+// handleUsersArchive sends every live user as an archive for
+// POST /users/archive, to move users between environments.
+func (s *Server) handleUsersArchive(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	var buf bytes.Buffer
+	if err := s.users.ExportArchive(&buf); err != nil {
+		s.logger.Error("error exporting archive", "err", err)
+		httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "error exporting archive")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="users-archive.json"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// handleImportArchive adds the users of an archive written by GET
+// /users/archive. ?mode= picks what happens to users whose name or email is
+// taken: skip, overwrite or, by default, fail.
+func (s *Server) handleImportArchive(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	mode, err := users.ParseMergeMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	timing := timingFrom(r.Context())
+	start := timing.start()
+	res, err := s.users.ImportArchive(r.Context(), http.MaxBytesReader(w, r.Body, maxArchiveBytes), mode)
+	timing.end(phaseStore, start)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		httpx.WriteError(w, http.StatusRequestEntityTooLarge, codeTooLarge, "archive must not exceed "+strconv.Itoa(maxArchiveBytes)+" bytes")
+		return
+	case err != nil:
+		writeUserError(w, err)
+		return
+	}
+
+	s.logger.Info("archive imported", "mode", mode, "added", res.Added, "overwritten", res.Overwritten, "skipped", res.Skipped)
+	httpx.WriteJSON(w, http.StatusOK, newImportResultResponse(res))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestUsersArchive(t *testing.T) {
+	serve := func(s *Server, method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(testAdminEmail, testAdminPassword)
+		s.Routes().ServeHTTP(w, req)
+		return w
+	}
+
+	src := newTestServer(t)
+	addAdmin(t, src.users)
+	if err := src.users.AddUser(context.Background(), "jane", "doe", "jane@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	w := serve(src, http.MethodGet, "/api/v1/users/archive", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code for export: expected %d, got %d\nbody: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	archive := w.Body.String()
+
+	// The destination already has the admin, which skip mode leaves alone.
+	dst := newTestServer(t)
+	addAdmin(t, dst.users)
+	w = serve(dst, http.MethodPost, "/api/v1/users/archive", archive)
+	if w.Code != http.StatusConflict {
+		t.Errorf("bad response code without a mode: expected %d, got %d", http.StatusConflict, w.Code)
+	}
+	assertErrorCode(t, w, codeConflict, "")
+
+	w = serve(dst, http.MethodPost, "/api/v1/users/archive?mode=skip", archive)
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code for import: expected %d, got %d\nbody: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if want := `{"added":1,"overwritten":0,"skipped":1}`; strings.TrimSpace(w.Body.String()) != want {
+		t.Errorf("bad import result: expected %s, got %s", want, w.Body.String())
+	}
+	if _, err := dst.users.GetUserByEmail(context.Background(), "jane@bar.com"); err != nil {
+		t.Errorf("imported user missing: %v", err)
+	}
+	entries := dst.users.Audit().(users.AuditReader).Last(1)
+	if len(entries) != 1 || entries[0].Op != users.AuditAdd || entries[0].Actor != testAdminEmail {
+		t.Errorf("import should be audited under the admin, got %+v", entries)
+	}
+
+	w = serve(dst, http.MethodPost, "/users/archive?mode=merge", archive)
+	assertErrorCode(t, w, codeInvalidRequest, `invalid merge mode "merge": must be skip, overwrite or fail`)
+	w = serve(dst, http.MethodPost, "/users/archive", `{"users": []}`)
+	assertErrorCode(t, w, codeInvalidRequest, "invalid archive: missing format_version")
+
+	// Archives hold every user, so only admins may read or write them.
+	w = httptest.NewRecorder()
+	dst.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/archive", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("bad response code without credentials: expected %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
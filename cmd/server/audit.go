@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = users.DefaultAuditCapacity
+)
+
+type auditResponse struct {
+	Entries jsonList[users.AuditEntry] `json:"entries"`
+}
+
+// handleAudit returns the most recent changes to users, oldest first. ?limit=
+// caps how many; the manager's audit log may hold fewer.
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	limit := defaultAuditLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	reader, ok := s.users.Audit().(users.AuditReader)
+	if !ok {
+		httpx.WriteError(w, http.StatusNotFound, codeNotFound, "audit log is not kept in memory")
+		return
+	}
+
+	httpx.WriteJSON(w, http.StatusOK, auditResponse{Entries: reader.Last(limit)})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func getAudit(t *testing.T, s *Server, target string) auditResponse {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	s.AdminRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp auditResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestAuditRecordsPrincipal(t *testing.T) {
+	m := users.NewManager()
+	addAdmin(t, m)
+	m.AddUser(context.Background(), "Max", "Member", "member@example.com")
+	s := NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), m)
+	handler := s.Routes()
+
+	req := httptest.NewRequest(http.MethodPut, "/users/member@example.com", strings.NewReader(`{"email":"max@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(testAdminEmail, testAdminPassword)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/users/max@example.com", nil)
+	req.SetBasicAuth(testAdminEmail, testAdminPassword)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := getAudit(t, s, "/admin/audit").Entries
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %+v", entries)
+	}
+	update, del := entries[2], entries[3]
+	if update.Op != users.AuditUpdate || update.Actor != testAdminEmail || update.After.Email != "max@example.com" {
+		t.Errorf("bad update entry: %+v", update)
+	}
+	if del.Op != users.AuditDelete || del.Actor != testAdminEmail || del.Before.Email != "max@example.com" {
+		t.Errorf("bad delete entry: %+v", del)
+	}
+
+	last := getAudit(t, s, "/admin/audit?limit=1").Entries
+	if len(last) != 1 || last[0].Op != users.AuditDelete {
+		t.Errorf("limit=1: expected the delete, got %+v", last)
+	}
+}
+
+func TestAuditLimit(t *testing.T) {
+	s := newTestServer(t)
+
+	if got := getAudit(t, s, "/admin/audit").Entries; got == nil || len(got) != 0 {
+		t.Errorf("expected an empty list, got %#v", got)
+	}
+
+	for _, target := range []string{"/admin/audit?limit=0", "/admin/audit?limit=x", "/admin/audit?limit=1001"} {
+		w := httptest.NewRecorder()
+		s.AdminRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: bad response code: expected %d, got %d\nbody: %s\n", target, http.StatusBadRequest, w.Code, w.Body.String())
+			continue
+		}
+		assertErrorCode(t, w, codeInvalidRequest, "invalid limit")
+	}
+}
+
+func TestAuditNotKept(t *testing.T) {
+	s := NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), users.NewManager(users.WithAudit(users.NewAuditWriter(io.Discard))))
+
+	w := httptest.NewRecorder()
+	s.AdminRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusNotFound, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeNotFound, "audit log is not kept in memory")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+var errUnauthenticated = errors.New("unauthenticated")
+
+// principal resolves the user making r from its Basic auth credentials. The
+// Basic auth username is the user's email address and the password is the
+// one set with SetPassword.
+func (s *Server) principal(r *http.Request) (*users.User, error) {
+	email, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, errUnauthenticated
+	}
+
+	user, err := s.store.GetUserByEmail(r.Context(), email)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errUnauthenticated
+	}
+	match, err := s.users.CheckPassword(user.FirstName, user.LastName, password)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, errUnauthenticated
+	}
+	return user, nil
+}
+
+// requireRole only lets h run for principals with the given role. Missing or
+// bad credentials get 401 with a Basic challenge; other roles get 403. h
+// sees the principal's email as the users.WithActor actor, so its changes
+// are audited under that name. The credential check is timed as the auth
+// phase.
+func (s *Server) requireRole(role users.Role, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timing := timingFrom(r.Context())
+		start := timing.start()
+		user, err := s.principal(r)
+		timing.end(phaseAuth, start)
+		if errors.Is(err, errUnauthenticated) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="users", charset="UTF-8"`)
+			httpx.WriteError(w, http.StatusUnauthorized, codeUnauthorized, "authentication required")
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			writeUserError(w, err)
+			return
+		}
+		if err != nil {
+			s.logRequestError(r, "error resolving principal", err)
+			httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "internal server error")
+			return
+		}
+		if user.Role != role {
+			httpx.WriteError(w, http.StatusForbidden, codeForbidden, "requires role "+string(role))
+			return
+		}
+		h(w, r.WithContext(users.WithActor(r.Context(), user.Email.Address)))
+	}
+}
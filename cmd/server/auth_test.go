@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+const (
+	testAdminEmail    = "admin@example.com"
+	testAdminPassword = "admin-password"
+)
+
+// addAdmin adds an admin user who can authenticate with testAdminEmail and
+// testAdminPassword.
+func addAdmin(t *testing.T, m *users.Manager) {
+	t.Helper()
+
+	if err := m.AddUserWithRole("Ada", "Admin", testAdminEmail, "admin"); err != nil {
+		t.Fatalf("error adding admin: %v", err)
+	}
+	if err := m.SetPassword("Ada", "Admin", testAdminPassword); err != nil {
+		t.Fatalf("error setting admin password: %v", err)
+	}
+}
+
+func TestDeleteRequiresAdmin(t *testing.T) {
+	m := users.NewManager()
+	addAdmin(t, m)
+	if err := m.AddUser(context.Background(), "Max", "Member", "member@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetPassword("Max", "Member", "member-password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewServer(newTestServer(t).logger, m).Routes()
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		status   int
+		code     string
+	}{
+		{name: "anonymous", status: http.StatusUnauthorized, code: codeUnauthorized},
+		{name: "wrong password", user: testAdminEmail, password: "nope", status: http.StatusUnauthorized, code: codeUnauthorized},
+		{name: "unknown user", user: "who@example.com", password: "whatever", status: http.StatusUnauthorized, code: codeUnauthorized},
+		{name: "member", user: "member@example.com", password: "member-password", status: http.StatusForbidden, code: codeForbidden},
+		{name: "admin", user: testAdminEmail, password: testAdminPassword, status: http.StatusNoContent},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, target := range []string{"/users/jhon@bar.com", "/api/v1/users/jhon@bar.com"} {
+				if _, err := m.GetUserByEmail(context.Background(), "jhon@bar.com"); errors.Is(err, users.ErrNoResultFound) {
+					if err := m.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				req := httptest.NewRequest(http.MethodDelete, target, strings.NewReader(""))
+				if tc.user != "" {
+					req.SetBasicAuth(tc.user, tc.password)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+
+				if w.Code != tc.status {
+					t.Errorf("bad response code for %s: expected %d, got %d\nbody: %s\n", target, tc.status, w.Code, w.Body.String())
+				}
+				if tc.code != "" {
+					assertErrorCode(t, w, tc.code, "")
+				}
+				if tc.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+					t.Errorf("missing WWW-Authenticate header for %s", target)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// maxBatchBytes caps the body of POST /users/batch.
+const maxBatchBytes = 1 << 20
+
+const (
+	batchCreated    = "created"
+	batchError      = "error"
+	batchRolledBack = "rolled_back"
+)
+
+// batchResult is the outcome of one entry of a batch. ID is the created
+// user's ID, as GET /api/v1/users/by-id takes it.
+type batchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleCreateUsersBatch creates users from a JSON array and reports each
+// entry separately with 207 Multi-Status. Entries are independent unless
+// ?atomic=true is passed, in which case one failure rolls back the rest.
+func (s *Server) handleCreateUsersBatch(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	atomic := false
+	if v := r.URL.Query().Get("atomic"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "invalid atomic")
+			return
+		}
+		atomic = parsed
+	}
+
+	var entries []CreateUserRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&entries)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		httpx.WriteError(w, http.StatusRequestEntityTooLarge, codeTooLarge, "batch must not exceed "+strconv.Itoa(maxBatchBytes)+" bytes")
+		return
+	}
+	if err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "error parsing request body")
+		return
+	}
+	if len(entries) == 0 {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "batch must contain at least one user")
+		return
+	}
+
+	results := make([]batchResult, len(entries))
+	valid := true
+	for i, e := range entries {
+		results[i] = batchResult{Index: i, Status: batchCreated}
+		if err := s.users.NameRules().ValidateUser(e.FirstName, e.LastName, e.Email); err != nil {
+			results[i] = batchResult{Index: i, Status: batchError, Error: err.Error()}
+			valid = false
+		}
+	}
+
+	timing := timingFrom(r.Context())
+	start := timing.start()
+	if atomic {
+		s.addBatchAtomic(entries, results, valid)
+	} else {
+		for i, e := range results {
+			if e.Status != batchCreated {
+				continue
+			}
+			if err := s.store.AddUser(r.Context(), entries[i].FirstName, entries[i].LastName, entries[i].Email); err != nil {
+				results[i] = batchResult{Index: i, Status: batchError, Error: err.Error()}
+				continue
+			}
+			user, err := s.store.GetUserByEmail(r.Context(), entries[i].Email)
+			if err != nil {
+				results[i] = batchResult{Index: i, Status: batchError, Error: err.Error()}
+				continue
+			}
+			results[i].ID = user.ID
+		}
+	}
+	timing.end(phaseStore, start)
+
+	httpx.WriteJSON(w, http.StatusMultiStatus, results)
+}
+
+// addBatchAtomic adds every entry or, when any entry is invalid or fails,
+// none of them. Entries that would have succeeded are reported as rolled
+// back.
+func (s *Server) addBatchAtomic(entries []CreateUserRequest, results []batchResult, valid bool) {
+	rollBack := func() {
+		for i := range results {
+			if results[i].Status == batchCreated {
+				results[i] = batchResult{Index: i, Status: batchRolledBack}
+			}
+		}
+	}
+	if !valid {
+		rollBack()
+		return
+	}
+
+	batch := make([]users.NewUser, len(entries))
+	for i, e := range entries {
+		batch[i] = e.newUser()
+	}
+	added, err := s.users.AddUsers(batch)
+	var batchErr *users.BatchError
+	if errors.As(err, &batchErr) {
+		results[batchErr.Index] = batchResult{Index: batchErr.Index, Status: batchError, Error: batchErr.Err.Error()}
+		rollBack()
+		return
+	}
+	for i, u := range added {
+		results[i].ID = u.ID
+	}
+}
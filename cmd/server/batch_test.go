@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func postBatch(t *testing.T, handler http.Handler, target string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func decodeBatch(t *testing.T, w *httptest.ResponseRecorder) []batchResult {
+	t.Helper()
+
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusMultiStatus, w.Code, w.Body.String())
+	}
+	var results []batchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	return results
+}
+
+const mixedBatch = `[
+	{"first_name":"ada","last_name":"lovelace","email":"ada@bar.com"},
+	{"first_name":"jhon","last_name":"smith","email":"other@bar.com"},
+	{"first_name":"","last_name":"hopper","email":"nope"},
+	{"first_name":"grace","last_name":"hopper","email":"grace@bar.com"}
+]`
+
+func TestCreateUsersBatch(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.users.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	results := decodeBatch(t, postBatch(t, s.Routes(), "/users/batch", mixedBatch))
+
+	statuses := make([]string, len(results))
+	for i, r := range results {
+		if r.Index != i {
+			t.Errorf("bad index: expected %d, got %d", i, r.Index)
+		}
+		statuses[i] = r.Status
+	}
+	expected := []string{batchCreated, batchError, batchError, batchCreated}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Fatalf("bad statuses: expected %v, got %v", expected, statuses)
+	}
+	ada, err := s.users.GetUserByEmail(context.Background(), "ada@bar.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].ID != ada.ID || results[1].Error != "user already exists" || results[2].Error == "" {
+		t.Errorf("bad results: %+v", results)
+	}
+	if n := len(s.users.GetAllUsers()); n != 3 {
+		t.Errorf("expected 3 users, got %d", n)
+	}
+}
+
+func TestCreateUsersBatchAtomic(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.users.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	handler := s.Routes()
+
+	conflicting := `[
+		{"first_name":"ada","last_name":"lovelace","email":"ada@bar.com"},
+		{"first_name":"jhon","last_name":"smith","email":"other@bar.com"}
+	]`
+	tests := []struct {
+		body     string
+		expected []string
+	}{
+		{conflicting, []string{batchRolledBack, batchError}},
+		{mixedBatch, []string{batchRolledBack, batchRolledBack, batchError, batchRolledBack}},
+	}
+	for _, tc := range tests {
+		results := decodeBatch(t, postBatch(t, handler, "/api/v1/users/batch?atomic=true", tc.body))
+		statuses := make([]string, len(results))
+		for i, r := range results {
+			statuses[i] = r.Status
+		}
+		if !reflect.DeepEqual(statuses, tc.expected) {
+			t.Errorf("bad atomic statuses: expected %v, got %v", tc.expected, statuses)
+		}
+		if n := len(s.users.GetAllUsers()); n != 1 {
+			t.Errorf("expected rollback to leave 1 user, got %d", n)
+		}
+	}
+
+	results := decodeBatch(t, postBatch(t, handler, "/users/batch?atomic=true", `[
+		{"first_name":"ada","last_name":"lovelace","email":"ada@bar.com"},
+		{"first_name":"grace","last_name":"hopper","email":"grace@bar.com"}
+	]`))
+	for i, email := range []string{"ada@bar.com", "grace@bar.com"} {
+		u, err := s.users.GetUserByEmail(context.Background(), email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[i].ID != u.ID {
+			t.Errorf("bad atomic result %d: expected id %s, got %+v", i, u.ID, results[i])
+		}
+	}
+}
+
+func TestCreateUsersBatchRejected(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	huge := "[" + strings.Repeat(`{"first_name":"a","last_name":"b","email":"c@d.com"},`, maxBatchBytes/40) + "]"
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "empty array", target: "/users/batch", body: `[]`, status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "not an array", target: "/users/batch", body: `{}`, status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "bad atomic", target: "/users/batch?atomic=maybe", body: `[]`, status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "too large", target: "/users/batch", body: huge, status: http.StatusRequestEntityTooLarge, code: codeTooLarge},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := postBatch(t, handler, tc.target, tc.body)
+			if w.Code != tc.status {
+				t.Errorf("bad response code: expected %d, got %d\nbody: %s\n", tc.status, w.Code, w.Body.String())
+			}
+			assertErrorCode(t, w, tc.code, "")
+		})
+	}
+}
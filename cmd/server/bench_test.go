@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// benchWriter is a ResponseWriter that keeps nothing, so the benchmarks
+// count only the handler's allocations.
+type benchWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *benchWriter) Header() http.Header         { return w.header }
+func (w *benchWriter) WriteHeader(status int)      { w.status = status }
+func (w *benchWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+// reset clears the writer for the next request without dropping its
+// header map.
+func (w *benchWriter) reset() {
+	clear(w.header)
+	w.status = 0
+}
+
+func benchServer() *Server {
+	return NewServer(slog.New(slog.DiscardHandler), users.NewManager())
+}
+
+// benchHandler serves r with h b.N times and fails unless each response is
+// a 200.
+func benchHandler(b *testing.B, h http.HandlerFunc, r *http.Request, reset func(r *http.Request)) {
+	w := &benchWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	for b.Loop() {
+		w.reset()
+		if reset != nil {
+			reset(r)
+		}
+		h(w, r)
+		if w.status != http.StatusOK {
+			b.Fatalf("bad response code: expected %d, got %d", http.StatusOK, w.status)
+		}
+	}
+}
+
+func BenchmarkHandleHelloQuery(b *testing.B) {
+	r := httptest.NewRequest(http.MethodGet, "/hello?user=alice", nil)
+	benchHandler(b, benchServer().handleHelloQuery(apiV0), r, nil)
+}
+
+func BenchmarkHandleHelloPath(b *testing.B) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/hello/alice", nil)
+	r.SetPathValue("user", "alice")
+	benchHandler(b, benchServer().handleHelloPath(apiV1), r, nil)
+}
+
+func BenchmarkHandleJSON(b *testing.B) {
+	const body = `{"first_name":"alice","last_name":"smith"}`
+	r := httptest.NewRequest(http.MethodPost, "/json", nil)
+	r.Header.Set("Content-Type", "application/json")
+	var br strings.Reader
+	rc := io.NopCloser(&br)
+	benchHandler(b, benchServer().handleJSON, r, func(r *http.Request) {
+		br.Reset(body)
+		r.Body = rc
+	})
+}
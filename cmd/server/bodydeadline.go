@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// errBodyReadTimeout is returned by request bodies that were not received
+// within the body read timeout. It wraps os.ErrDeadlineExceeded.
+var errBodyReadTimeout = fmt.Errorf("request body read timed out: %w", os.ErrDeadlineExceeded)
+
+// withBodyReadDeadline bounds the time a request's body may take to arrive,
+// which ReadHeaderTimeout does not cover, so a client trickling a body
+// cannot hold a connection for the whole handler timeout. It must run
+// outside withTimeout, whose writer hides the connection from
+// http.ResponseController. A body that times out is logged and counted in
+// bodyReadTimeouts, and its reads fail with errBodyReadTimeout.
+func (s *Server) withBodyReadDeadline(h http.Handler) http.Handler {
+	if s.timeouts.BodyRead <= 0 {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		deadline := time.Now().Add(s.timeouts.BodyRead)
+		if err := rc.SetReadDeadline(deadline); err != nil {
+			// Only test recorders lack a connection.
+			h.ServeHTTP(w, r)
+			return
+		}
+		body := &deadlineBody{ReadCloser: r.Body, s: s, r: r, rc: rc, deadline: deadline}
+
+		// The connection cancels the request when a read fails, and
+		// withTimeout would answer that with a bare 503 before the handler
+		// could send its 408. Every other cancellation is passed on.
+		ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+		defer cancel()
+		stop := context.AfterFunc(r.Context(), func() {
+			if !body.expired() {
+				cancel()
+			}
+		})
+		defer stop()
+
+		r = r.WithContext(ctx)
+		r.Body = body
+		h.ServeHTTP(w, r)
+	})
+}
+
+// deadlineBody reports a body read that ran past its deadline as
+// errBodyReadTimeout, and lifts the deadline once the body has been read:
+// the connection keeps reading in the background while the handler runs,
+// and a deadline firing there would cancel the request.
+type deadlineBody struct {
+	io.ReadCloser
+	s        *Server
+	r        *http.Request
+	rc       *http.ResponseController
+	deadline time.Time
+	done     atomic.Bool
+	timedOut bool
+}
+
+// expired reports whether the deadline passed before the whole body was
+// read.
+func (b *deadlineBody) expired() bool {
+	return !b.done.Load() && !time.Now().Before(b.deadline)
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	switch {
+	case err == io.EOF:
+		b.done.Store(true)
+		b.rc.SetReadDeadline(time.Time{})
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		if !b.timedOut {
+			b.timedOut = true
+			b.s.bodyReadTimeouts.Add(1)
+			b.s.logger.Warn("request body read timed out", "path", b.r.URL.Path, "timeout", b.s.timeouts.BodyRead)
+		}
+		err = errBodyReadTimeout
+	}
+	return n, err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBodyReadDeadline(t *testing.T) {
+	s := newTestServer(t)
+	s.timeouts.BodyRead = 200 * time.Millisecond
+	ts := httptest.NewServer(s.Routes())
+	defer ts.Close()
+
+	// The client sends the headers and a few bytes of the body, then
+	// trickles the rest slower than the deadline allows.
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	body := `{"FirstName":"bob"}`
+	fmt.Fprintf(conn, "POST /json HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n", len(body))
+	go func() {
+		for i := range len(body) {
+			if _, err := io.WriteString(conn, body[i:i+1]); err != nil {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("bad response code: expected %d, got %d %s", http.StatusRequestTimeout, resp.StatusCode, b)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("deadline fired after %v", elapsed)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(data), `"code":"`+codeRequestTimeout+`"`) {
+		t.Errorf("bad response body: %s", data)
+	}
+	if n := s.bodyReadTimeouts.Load(); n != 1 {
+		t.Errorf("expected 1 body read timeout counted, got %d", n)
+	}
+
+	// A client that sends its body promptly is unaffected.
+	resp, err = http.Post(ts.URL+"/json", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("bad response code: expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if n := s.bodyReadTimeouts.Load(); n != 1 {
+		t.Errorf("fast request counted as a timeout: %d", n)
+	}
+}
+
+func TestBodyReadDeadlineLiftedAfterBody(t *testing.T) {
+	s := newTestServer(t)
+	s.timeouts.BodyRead = 50 * time.Millisecond
+
+	// Once the body is in, the handler may outlive the deadline without
+	// its request being canceled.
+	ts := httptest.NewServer(s.withBodyReadDeadline(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			t.Errorf("error reading body: %v", err)
+		}
+		time.Sleep(4 * s.timeouts.BodyRead)
+		if err := r.Context().Err(); err != nil {
+			t.Errorf("request canceled after the body was read: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("bad response code: expected %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// clientGone reports whether err, or the request's context, shows that the
+// client canceled the request. Errors caused by a departed client are
+// expected and not worth an Error-level log entry.
+func clientGone(r *http.Request, err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(r.Context().Err(), context.Canceled)
+}
+
+// logRequestError logs err at Debug when the client went away and at Error
+// otherwise.
+func (s *Server) logRequestError(r *http.Request, msg string, err error) {
+	if clientGone(r, err) {
+		s.logger.Debug(msg, "err", err, "path", r.URL.Path)
+		return
+	}
+	s.logger.Error(msg, "err", err)
+}
+
+// canceled reports whether the request's context is done, logging at Debug
+// which stage was abandoned. Handlers call it before starting work that is
+// pointless once the client has gone.
+func (s *Server) canceled(r *http.Request, stage string) bool {
+	err := r.Context().Err()
+	if err == nil {
+		return false
+	}
+	s.logger.Debug("request abandoned", "stage", stage, "path", r.URL.Path, "err", err)
+	return true
+}
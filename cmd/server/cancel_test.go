@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// recordHandler is a slog.Handler that keeps every record it handles.
+type recordHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *recordHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordHandler) count(level slog.Level) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := 0
+	for _, r := range h.records {
+		if r.Level == level {
+			n++
+		}
+	}
+	return n
+}
+
+func newRecordingServer(t *testing.T) (*Server, *recordHandler) {
+	t.Helper()
+
+	h := &recordHandler{}
+	return NewServer(slog.New(h), users.NewManager()), h
+}
+
+func canceledRequest(method string, target string, body string) *http.Request {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return httptest.NewRequest(method, target, strings.NewReader(body)).WithContext(ctx)
+}
+
+func TestCanceledRequestsLogAtDebug(t *testing.T) {
+	tests := []struct {
+		name  string
+		serve func(s *Server, w http.ResponseWriter)
+	}{
+		{"json", func(s *Server, w http.ResponseWriter) {
+			s.handleJSON(w, canceledRequest(http.MethodPost, "/json", `{"FirstName":"alice"}`))
+		}},
+		{"hello", func(s *Server, w http.ResponseWriter) {
+			s.handleHello(w, canceledRequest(http.MethodGet, "/hello/", ""), "alice")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, logs := newRecordingServer(t)
+			w := httptest.NewRecorder()
+
+			tt.serve(s, w)
+
+			if n := logs.count(slog.LevelError); n != 0 {
+				t.Errorf("expected no Error entries, got %d", n)
+			}
+			if n := logs.count(slog.LevelDebug); n == 0 {
+				t.Error("expected a Debug entry for the abandoned request")
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("expected no response body, got %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestLogRequestError(t *testing.T) {
+	s, logs := newRecordingServer(t)
+
+	live := httptest.NewRequest(http.MethodGet, "/hello/", nil)
+	apiV0.writeGreeting(httpx.Wrap(failingWriter{httptest.NewRecorder()}, live, s.logger), []greeting.Result{{Greeting: "Hello alice!"}})
+	if logs.count(slog.LevelError) != 1 {
+		t.Errorf("write error on a live request should log at Error")
+	}
+
+	gone := canceledRequest(http.MethodGet, "/hello/", "")
+	apiV0.writeGreeting(httpx.Wrap(failingWriter{httptest.NewRecorder()}, gone, s.logger), []greeting.Result{{Greeting: "Hello alice!"}})
+	if logs.count(slog.LevelError) != 1 || logs.count(slog.LevelDebug) != 1 {
+		t.Errorf("write error after cancellation should log at Debug")
+	}
+
+	if !clientGone(live, context.Canceled) || clientGone(live, errors.New("boom")) {
+		t.Error("clientGone misclassified an error")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/client"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// TestClientAgainstRoutes exercises the client package against the real mux
+// so the client and server wire formats cannot drift apart.
+func TestClientAgainstRoutes(t *testing.T) {
+	m := users.NewManager()
+	addAdmin(t, m)
+	srv := httptest.NewServer(NewServer(newTestServer(t).logger, m).Routes())
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	c := client.New(srv.URL, client.WithHTTPClient(srv.Client()), client.WithBasicAuth(testAdminEmail, testAdminPassword))
+
+	greeting, err := c.Hello(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Hello: %v", err)
+	}
+	if greeting != "Hello alice!" {
+		t.Errorf("expected greeting %q, got %q", "Hello alice!", greeting)
+	}
+
+	created, err := c.CreateUser(ctx, client.UserData{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if created.Email != "ada@example.com" || created.CreatedAt.IsZero() {
+		t.Errorf("unexpected created user: %+v", created)
+	}
+
+	got, err := c.GetUser(ctx, "Ada", "Lovelace")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if !reflect.DeepEqual(got, created) {
+		t.Errorf("expected %+v, got %+v", created, got)
+	}
+
+	_, err = c.CreateUser(ctx, client.UserData{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"})
+	if !errors.Is(err, client.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+
+	all, err := c.ListUsers(ctx)
+	if err != nil || len(all) != 2 || !reflect.DeepEqual(all[1], *created) {
+		t.Errorf("ListUsers: expected [admin %+v], got %+v (%v)", created, all, err)
+	}
+
+	if err := c.DeleteUser(ctx, "ada@example.com"); err != nil {
+		t.Errorf("DeleteUser: %v", err)
+	}
+
+	_, err = c.GetUser(ctx, "Ada", "Lovelace")
+	if !errors.Is(err, client.ErrGone) {
+		t.Errorf("expected ErrGone, got %v", err)
+	}
+	if restored, err := c.RestoreUser(ctx, "ada@example.com"); err != nil || restored.Email != "ada@example.com" {
+		t.Errorf("RestoreUser: got %+v, %v", restored, err)
+	}
+	if _, err := c.GetUser(ctx, "No", "Body"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	_, err = c.CreateUser(ctx, client.UserData{FirstName: "Bad", LastName: "Email", Email: "not-an-email"})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 422 || !errors.Is(err, client.ErrInvalidRequest) || len(apiErr.Fields) != 1 {
+		t.Errorf("expected 422 validation_failed with one field, got %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := c.ExportArchive(ctx, &archive); err != nil {
+		t.Fatalf("ExportArchive: %v", err)
+	}
+	result, err := c.ImportArchive(ctx, &archive, "skip")
+	if err != nil || *result != (client.ImportResult{Skipped: 2}) {
+		t.Errorf("ImportArchive: got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// requireJSON rejects POST, PUT and PATCH requests whose Content-Type is not
+// application/json with 415. Parameters such as charset are allowed. Other
+// methods pass straight through.
+func requireJSON(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				w.Header().Set("Accept-Post", "application/json")
+				httpx.WriteError(w, http.StatusUnsupportedMediaType, codeUnsupportedMedia, "Content-Type must be application/json")
+				return
+			}
+		}
+		h(w, r)
+	}
+}
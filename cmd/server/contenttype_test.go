@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireJSON(t *testing.T) {
+	h := requireJSON(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		want        int
+	}{
+		{"json", http.MethodPost, "application/json", http.StatusNoContent},
+		{"json with charset", http.MethodPost, "application/json; charset=utf-8", http.StatusNoContent},
+		{"mixed case", http.MethodPut, "Application/JSON", http.StatusNoContent},
+		{"text", http.MethodPost, "text/plain", http.StatusUnsupportedMediaType},
+		{"json suffix", http.MethodPatch, "application/jsonx", http.StatusUnsupportedMediaType},
+		{"malformed", http.MethodPost, "application/json; charset", http.StatusUnsupportedMediaType},
+		{"missing", http.MethodPost, "", http.StatusUnsupportedMediaType},
+		{"get", http.MethodGet, "", http.StatusNoContent},
+		{"get with text", http.MethodGet, "text/plain", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("bad response code: expected %d, got %d", tt.want, w.Code)
+			}
+			if tt.want == http.StatusUnsupportedMediaType {
+				assertErrorCode(t, w, codeUnsupportedMedia, "Content-Type must be application/json")
+			}
+		})
+	}
+}
+
+func TestRoutesRequireJSON(t *testing.T) {
+	handler := newTestServer(t).Routes()
+	for _, target := range []string{"/json", "/users", "/api/v1/users", "/users/batch"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, target, nil)
+		r.Header.Set("Content-Type", "text/plain")
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("POST %s: expected %d, got %d", target, http.StatusUnsupportedMediaType, w.Code)
+		}
+	}
+}
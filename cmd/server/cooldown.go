@@ -0,0 +1,108 @@
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"golang.org/x/text/cases"
+)
+
+// throttledHeader marks a greeting the name's cooldown kept out of /stats.
+const throttledHeader = "X-Greeting-Throttled"
+
+// cooldownMaxNames bounds the distinct names a greetCooldown tracks.
+const cooldownMaxNames = 10000
+
+// greetCooldown throttles a name greeted more than limit times within a
+// sliding window. It keeps the times of each name's last limit greetings,
+// throttled ones included, so a name greeted without pause stays throttled.
+// At most maxNames names are tracked; beyond that the least recently
+// greeted is forgotten. A nil greetCooldown throttles nothing.
+type greetCooldown struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	maxNames int
+	// lru holds a *cooldownName per name, most recently greeted first.
+	lru   *list.List
+	names map[string]*list.Element
+}
+
+type cooldownName struct {
+	key string
+	// times are the name's most recent greetings, oldest first.
+	times []time.Time
+}
+
+func newGreetCooldown(limit int, window time.Duration, maxNames int) *greetCooldown {
+	return &greetCooldown{
+		limit:    limit,
+		window:   window,
+		maxNames: maxNames,
+		lru:      list.New(),
+		names:    make(map[string]*list.Element),
+	}
+}
+
+// cooldownKey is the form of name the cooldown tracks, so case variants of
+// a name share one window.
+func cooldownKey(name string) string {
+	return cases.Fold().String(greeting.Sanitize(strings.TrimSpace(name)))
+}
+
+// allow records a greeting of name at now and reports whether it is within
+// the limit.
+func (c *greetCooldown) allow(name string, now time.Time) bool {
+	if c == nil {
+		return true
+	}
+	key := cooldownKey(name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var entry *cooldownName
+	if e, ok := c.names[key]; ok {
+		c.lru.MoveToFront(e)
+		entry = e.Value.(*cooldownName)
+	} else {
+		if c.lru.Len() >= c.maxNames {
+			oldest := c.lru.Back()
+			delete(c.names, c.lru.Remove(oldest).(*cooldownName).key)
+		}
+		entry = &cooldownName{key: key, times: make([]time.Time, 0, c.limit)}
+		c.names[key] = c.lru.PushFront(entry)
+	}
+
+	// Greetings at or before the start of the window have left it.
+	start := now.Add(-c.window)
+	i := 0
+	for i < len(entry.times) && !entry.times[i].After(start) {
+		i++
+	}
+	entry.times = entry.times[i:]
+
+	allowed := len(entry.times) < c.limit
+	if !allowed {
+		entry.times = entry.times[1:]
+	}
+	entry.times = append(entry.times, now)
+	return allowed
+}
+
+// countGreeting adds a greeting of name to /stats unless the name's
+// cooldown throttles it, in which case it marks the response on w. w is
+// nil where there are no headers to mark, as on a WebSocket.
+func (s *Server) countGreeting(w http.ResponseWriter, name string) {
+	if !s.cooldown.allow(name, s.now()) {
+		if w != nil {
+			w.Header().Set(throttledHeader, "true")
+		}
+		return
+	}
+	s.greetings.add(name)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGreetCooldownWindow(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		at   []time.Duration
+		want []bool
+	}{
+		{
+			name: "third greeting inside the window",
+			at:   []time.Duration{0, 30 * time.Second, time.Minute - time.Nanosecond},
+			want: []bool{true, true, false},
+		},
+		{
+			name: "first greeting leaves the window",
+			at:   []time.Duration{0, 30 * time.Second, time.Minute},
+			want: []bool{true, true, true},
+		},
+		{
+			// Throttled greetings count too, so greeting without pause
+			// keeps the name throttled.
+			name: "throttled greetings count",
+			at:   []time.Duration{0, 30 * time.Second, 45 * time.Second, 75 * time.Second, 105 * time.Second},
+			want: []bool{true, true, false, false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newGreetCooldown(2, time.Minute, 10)
+			for i, at := range tt.at {
+				if got := c.allow("bob", start.Add(at)); got != tt.want[i] {
+					t.Errorf("greeting at %v: allowed = %v, want %v", at, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGreetCooldownFoldsCase(t *testing.T) {
+	now := time.Now()
+	c := newGreetCooldown(3, time.Minute, 10)
+
+	for _, name := range []string{"Straße", "STRASSE", " strasse\x1b"} {
+		if !c.allow(name, now) {
+			t.Errorf("%q throttled within the limit", name)
+		}
+	}
+	if c.allow("strasse", now) {
+		t.Error("fourth greeting of a case variant was not throttled")
+	}
+	if !c.allow("Strasser", now) {
+		t.Error("a different name shares the window")
+	}
+}
+
+func TestGreetCooldownBoundsNames(t *testing.T) {
+	now := time.Now()
+	c := newGreetCooldown(1, time.Hour, 2)
+
+	c.allow("a", now)
+	c.allow("b", now)
+	c.allow("a", now)
+	c.allow("c", now)
+	if len(c.names) != 2 || c.lru.Len() != 2 {
+		t.Fatalf("tracking %d names, want 2", len(c.names))
+	}
+	// b was the least recently greeted, so it was forgotten.
+	if !c.allow("b", now) {
+		t.Error("evicted name is still throttled")
+	}
+	if c.allow("c", now) {
+		t.Error("tracked name was not throttled")
+	}
+}
+
+func TestGreetCooldownConcurrent(t *testing.T) {
+	now := time.Now()
+	c := newGreetCooldown(10, time.Hour, 10)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Go(func() {
+			if c.allow("bob", now) {
+				allowed.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+	if allowed.Load() != 10 {
+		t.Errorf("allowed %d greetings, want 10", allowed.Load())
+	}
+}
+
+func TestNilGreetCooldownAllows(t *testing.T) {
+	var c *greetCooldown
+	if !c.allow("bob", time.Now()) {
+		t.Error("nil cooldown throttled a greeting")
+	}
+}
+
+func TestThrottledGreetingsLeftOutOfStats(t *testing.T) {
+	s := newTestServer(t)
+	s.cooldown = newGreetCooldown(2, time.Minute, 10)
+	handler := s.Routes()
+
+	for i, name := range []string{"bob", "BOB", "Bob", "alice"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/hello?user="+name, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: bad response code: expected %d, got %d", name, http.StatusOK, w.Code)
+		}
+		want := ""
+		if i == 2 {
+			want = "true"
+		}
+		if got := w.Header().Get(throttledHeader); got != want {
+			t.Errorf("%s: %s = %q, want %q", name, throttledHeader, got, want)
+		}
+	}
+
+	stats := getStats(t, handler, "/stats")
+	if stats.TotalGreetings != 3 {
+		t.Errorf("expected 3 greetings counted, got %d", stats.TotalGreetings)
+	}
+	for _, nc := range stats.Top {
+		if nc.Name == "Bob" {
+			t.Errorf("throttled greeting counted: %+v", stats.Top)
+		}
+	}
+}
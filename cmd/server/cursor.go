@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// signCursor encodes c as the opaque next_cursor token of a user list page:
+// the cursor in JSON and its signature under the server's cursor secret,
+// each base64-encoded.
+func (s *Server) signCursor(c users.Cursor) string {
+	payload, _ := json.Marshal(c)
+	mac := hmac.New(sha256.New, s.cursorSecret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyCursor returns the cursor in a token made by signCursor, or false if
+// the token is malformed, was tampered with or was issued before the server
+// last started.
+func (s *Server) verifyCursor(token string) (users.Cursor, bool) {
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return users.Cursor{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return users.Cursor{}, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return users.Cursor{}, false
+	}
+
+	mac := hmac.New(sha256.New, s.cursorSecret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return users.Cursor{}, false
+	}
+	var c users.Cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return users.Cursor{}, false
+	}
+	return c, true
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// maxEchoBody is how much of a request body /debug/echo reports.
+const maxEchoBody = 64 << 10
+
+type echoResponse struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Headers map[string][]string `json:"headers"`
+	Query   map[string][]string `json:"query"`
+
+	// Body is the request body as text, or base64 when BodyEncoding is
+	// "base64" because the body is not valid UTF-8.
+	Body         string `json:"body"`
+	BodyEncoding string `json:"body_encoding"`
+	Truncated    bool   `json:"truncated"`
+}
+
+// handleDebugEcho reports the request back to the client so integrators can
+// see exactly what they sent. It is only registered with
+// -enable-debug-endpoints.
+func (s *Server) handleDebugEcho(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEchoBody+1))
+	if err != nil {
+		s.logRequestError(r, "error reading request body", err)
+		if !clientGone(r, err) {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "bad request body")
+		}
+		return
+	}
+
+	resp := echoResponse{
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		Headers:      s.redact.Header(r.Header),
+		Query:        r.URL.Query(),
+		BodyEncoding: "utf-8",
+	}
+	if len(body) > maxEchoBody {
+		body = body[:maxEchoBody]
+		resp.Truncated = true
+	} else {
+		// A truncated body is no longer JSON, so it cannot be redacted.
+		body = s.redact.JSON(body)
+	}
+	if utf8.Valid(body) {
+		resp.Body = string(body)
+	} else {
+		resp.Body = base64.StdEncoding.EncodeToString(body)
+		resp.BodyEncoding = "base64"
+	}
+
+	httpx.WriteJSON(w, http.StatusOK, resp)
+}
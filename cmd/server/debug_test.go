@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func echo(t *testing.T, r *http.Request) echoResponse {
+	t.Helper()
+
+	s := newTestServer(t)
+	s.enableDebugEndpoints = true
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp echoResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	return resp
+}
+
+func TestDebugEcho(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/debug/echo?a=1&a=2", strings.NewReader(`{"name":"alice","password":"hunter22"}`))
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Cookie", "session=abcdef")
+	r.Header.Set("User", "alice")
+	r.Header.Set("X-Trace", "abc")
+
+	resp := echo(t, r)
+	if resp.Method != http.MethodPost || resp.Path != "/debug/echo" {
+		t.Errorf("bad request line: got %s %s", resp.Method, resp.Path)
+	}
+	for name, want := range map[string]string{"Authorization": "Bearer se…(6)", "Cookie": "se…(14)", "User": "al…(5)"} {
+		if got := resp.Headers[name]; len(got) != 1 || got[0] != want {
+			t.Errorf("%s not redacted: expected %q, got %q", name, want, got)
+		}
+	}
+	if got := resp.Headers["X-Trace"]; len(got) != 1 || got[0] != "abc" {
+		t.Errorf("bad X-Trace: got %q", got)
+	}
+	if got := resp.Query["a"]; len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("bad query: got %q", got)
+	}
+	if resp.Body != `{"name":"alice","password":"hu…(8)"}` || resp.BodyEncoding != "utf-8" || resp.Truncated {
+		t.Errorf("bad body: got %+v", resp)
+	}
+}
+
+func TestDebugEchoBinaryAndTruncated(t *testing.T) {
+	binary := []byte{0xff, 0xfe, 0x00, 0x01}
+	resp := echo(t, httptest.NewRequest(http.MethodPost, "/debug/echo", bytes.NewReader(binary)))
+	if resp.BodyEncoding != "base64" || resp.Body != base64.StdEncoding.EncodeToString(binary) {
+		t.Errorf("bad binary body: got %+v", resp)
+	}
+
+	large := strings.Repeat("x", maxEchoBody+10)
+	resp = echo(t, httptest.NewRequest(http.MethodPost, "/debug/echo", strings.NewReader(large)))
+	if !resp.Truncated || len(resp.Body) != maxEchoBody {
+		t.Errorf("bad truncation: truncated=%v, got %d bytes", resp.Truncated, len(resp.Body))
+	}
+}
+
+func TestDebugEchoDisabledByDefault(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestServer(t).Routes().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/echo", strings.NewReader("hi")))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusNotFound, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+type domainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+type domainsResponse struct {
+	Domains jsonList[domainCount] `json:"domains"`
+}
+
+// handleUserDomains counts users per email domain, most common first.
+func (s *Server) handleUserDomains(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	counts, err := users.CountByDomain(r.Context(), s.store)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	var resp domainsResponse
+	for domain, count := range counts {
+		resp.Domains = append(resp.Domains, domainCount{Domain: domain, Count: count})
+	}
+	slices.SortFunc(resp.Domains, func(a, b domainCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Domain, b.Domain))
+	})
+
+	httpx.WriteJSON(w, http.StatusOK, resp)
+}
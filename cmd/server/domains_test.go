@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserDomains(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.Routes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/domains", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"domains":[]}`+"\n" {
+		t.Errorf("empty manager: %d %q", w.Code, w.Body.String())
+	}
+
+	for _, u := range []struct{ first, email string }{
+		{"alice", "alice@x.com"},
+		{"bob", "Bob <bob+tag@Example.COM>"},
+		{"carol", "carol@example.com"},
+		{"dave", "dave@EXAMPLE.com"},
+		{"erin", "erin@a.org"},
+	} {
+		if err := s.users.AddUser(context.Background(), u.first, "smith", u.email); err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/domains", nil))
+	want := `{"domains":[{"domain":"example.com","count":3},{"domain":"a.org","count":1},{"domain":"x.com","count":1}]}` + "\n"
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("bad response: %d\nexpected %s\ngot      %s", w.Code, want, w.Body.String())
+	}
+}
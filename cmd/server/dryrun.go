@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/config"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// dryRun loads everything run would before serving — the configuration,
+// greeting templates and locales, route table, snapshot and TLS key pair —
+// and writes a summary of what would be served to stdout. It binds no
+// socket and opens no file for writing. Every problem found is returned
+// together rather than stopping at the first; a bad configuration wraps
+// errUsage.
+func dryRun(cfg *config.Config, stdout io.Writer) error {
+	var errs []error
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("%w:\n%w", errUsage, err))
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	manager := users.NewManager(managerOptions(cfg)...)
+	srv := newServerFromConfig(cfg, logger, manager)
+	if err := srv.loadGreeter(); err != nil {
+		errs = append(errs, err)
+	}
+
+	var public, admin int
+	routes, err := srv.routeTable()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, r := range routes {
+		if r.Listener == "admin" {
+			admin++
+		} else {
+			public++
+		}
+	}
+
+	store := "memory, empty"
+	if cfg.SnapshotPath != "" {
+		if err := restoreSnapshot(logger, manager, cfg.SnapshotPath); err != nil {
+			errs = append(errs, err)
+		} else if _, err := os.Stat(cfg.SnapshotPath); err == nil {
+			store = fmt.Sprintf("memory, %d users restored from %s", len(manager.GetAllUsers()), cfg.SnapshotPath)
+		} else {
+			store = fmt.Sprintf("memory, empty (no snapshot at %s)", cfg.SnapshotPath)
+		}
+	}
+	if cfg.Store == "sqlite" {
+		errs = append(errs, errSQLiteIncomplete)
+		store = "sqlite, " + cfg.DBPath
+		if _, err := os.Stat(cfg.DBPath); errors.Is(err, os.ErrNotExist) {
+			store += " (created on startup)"
+		}
+	}
+
+	scheme := "http"
+	switch {
+	case cfg.TLSSelfSigned:
+		scheme = "https, self-signed"
+	case cfg.TLSCert != "" && cfg.TLSKey != "":
+		scheme = "https"
+		if err := checkKeyPair(cfg.TLSCert, cfg.TLSKey, time.Now()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	fmt.Fprintf(stdout, "public: %s (%s), %d routes\n", cfg.Addr, scheme, public)
+	fmt.Fprintf(stdout, "admin:  %s (http), %d routes\n", cfg.AdminAddr, admin)
+	if cfg.GRPCAddr != "" {
+		fmt.Fprintf(stdout, "grpc:   %s (plaintext)\n", cfg.GRPCAddr)
+	}
+	fmt.Fprintf(stdout, "store:  %s\n", store)
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("dry run found %d problems:\n%w", len(errs), err)
+	}
+	fmt.Fprintln(stdout, "ok")
+	return nil
+}
+
+// checkKeyPair loads a certificate and key as listen would, and also
+// rejects a certificate that is not valid at now, which the TLS handshake
+// would otherwise only report to clients.
+func checkKeyPair(certFile string, keyFile string, now time.Time) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading TLS key pair %s, %s: %w", certFile, keyFile, err)
+	}
+	if leaf := cert.Leaf; now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return fmt.Errorf("TLS certificate %s is only valid from %s to %s", certFile,
+			leaf.NotBefore.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
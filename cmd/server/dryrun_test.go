@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes the certificate and key of a generated self-signed
+// TLS config to dir as PEM files.
+func writeKeyPair(t *testing.T, dir string, name string) (certFile string, keyFile string) {
+	t.Helper()
+
+	cfg, err := selfSignedTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := cfg.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	writeFile(t, certFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})))
+	writeFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})))
+	return certFile, keyFile
+}
+
+func TestRunDryRun(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "server")
+	templateFile := filepath.Join(dir, "greeting.tmpl")
+	writeFile(t, templateFile, "Hi {{.Name}}")
+
+	snapshot := filepath.Join(dir, "users.json")
+	m := newTestServer(t).users
+	if err := m.AddUser(context.Background(), "ada", "lovelace", "ada@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveSnapshotFile(snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	// The public address is taken: a dry run must not try to bind it.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var stdout bytes.Buffer
+	err = run(context.Background(), []string{
+		"--dry-run",
+		"-addr", ln.Addr().String(),
+		"-grpc-addr", "127.0.0.1:4002",
+		"-greeting-file", templateFile,
+		"-snapshot-path", snapshot,
+		"-tls-cert", certFile,
+		"-tls-key", keyFile,
+	}, &stdout, io.Discard)
+	if err != nil {
+		t.Fatalf("expected a clean dry run, got %v\nstdout: %s", err, stdout.String())
+	}
+	for _, want := range []string{
+		"public: " + ln.Addr().String() + " (https)",
+		"admin:  127.0.0.1:4001 (http)",
+		"grpc:   127.0.0.1:4002 (plaintext)",
+		"memory, 1 users restored from " + snapshot,
+		"ok",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("summary missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	// A missing snapshot is not a problem: the server would start empty.
+	stdout.Reset()
+	err = run(context.Background(), []string{"-dry-run", "-snapshot-path", filepath.Join(dir, "missing.json")}, &stdout, io.Discard)
+	if err != nil || !strings.Contains(stdout.String(), "empty (no snapshot at") {
+		t.Errorf("missing snapshot: got %v\nstdout: %s", err, stdout.String())
+	}
+
+	// The SQLite store is refused until the whole API uses it, but the
+	// dry run still must not create the database.
+	stdout.Reset()
+	dbPath := filepath.Join(dir, "users.db")
+	err = run(context.Background(), []string{"-dry-run", "-store", "sqlite", "-db-path", dbPath}, &stdout, io.Discard)
+	if !errors.Is(err, errSQLiteIncomplete) || !strings.Contains(stdout.String(), "sqlite, "+dbPath+" (created on startup)") {
+		t.Errorf("sqlite store: got %v\nstdout: %s", err, stdout.String())
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		t.Error("dry run created the database")
+	}
+}
+
+func TestRunDryRunAggregatesErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := writeKeyPair(t, dir, "server")
+	_, otherKey := writeKeyPair(t, dir, "other")
+	templateFile := filepath.Join(dir, "greeting.tmpl")
+	writeFile(t, templateFile, "Hi {{.Name")
+	snapshot := filepath.Join(dir, "users.json")
+	writeFile(t, snapshot, "{not json")
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{
+		"-dry-run",
+		"-addr", ":99999",
+		"-greeting-file", templateFile,
+		"-snapshot-path", snapshot,
+		"-tls-cert", certFile,
+		"-tls-key", otherKey,
+	}, &stdout, io.Discard)
+	if err == nil {
+		t.Fatalf("expected errors, got none\nstdout: %s", stdout.String())
+	}
+	if exitCode(err) != 2 {
+		t.Errorf("bad exit code: expected 2, got %d", exitCode(err))
+	}
+	for _, want := range []string{
+		"dry run found 4 problems",
+		"addr: invalid port",
+		"invalid greeting template",
+		"error restoring snapshot " + snapshot,
+		"error loading TLS key pair",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+	if strings.Contains(stdout.String(), "ok") {
+		t.Errorf("summary reports ok despite errors:\n%s", stdout.String())
+	}
+
+	// Without a configuration problem the exit status is 1.
+	err = run(context.Background(), []string{"-dry-run", "-snapshot-path", snapshot}, io.Discard, io.Discard)
+	if err == nil || errors.Is(err, errUsage) || exitCode(err) != 1 {
+		t.Errorf("corrupt snapshot: expected exit code 1, got %v", err)
+	}
+}
+
+func TestCheckKeyPairExpired(t *testing.T) {
+	certFile, keyFile := writeKeyPair(t, t.TempDir(), "server")
+	err := checkKeyPair(certFile, keyFile, time.Now().AddDate(0, 0, 2))
+	if err == nil || !strings.Contains(err.Error(), "only valid from") {
+		t.Errorf("expected an expiry error, got %v", err)
+	}
+}
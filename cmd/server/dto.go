@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// The request and response bodies of the API. They are kept apart from
+// users.User and greeting.Result so the stored and wire shapes can change
+// independently, and name their fields in snake_case as the API style guide
+// asks.
+
+// CreateUserRequest is the body of POST /json and POST /users, and of each
+// entry of POST /users/batch.
+type CreateUserRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+
+	// Greeting and Language are optional and only read by POST /json; see
+	// Validate.
+	Greeting string `json:"greeting,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// UnmarshalJSON also accepts FirstName and LastName, the names bodies used
+// before CreateUserRequest, in any case. They are deprecated: when a body
+// has both spellings of a field the snake_case one wins, and they will be
+// dropped once clients have moved.
+func (req *CreateUserRequest) UnmarshalJSON(data []byte) error {
+	var body struct {
+		FirstName *string `json:"first_name"`
+		LastName  *string `json:"last_name"`
+		Email     string  `json:"email"`
+		Greeting  string  `json:"greeting"`
+		Language  string  `json:"language"`
+
+		LegacyFirstName string `json:"FirstName"`
+		LegacyLastName  string `json:"LastName"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
+	}
+
+	*req = CreateUserRequest{
+		FirstName: body.LegacyFirstName,
+		LastName:  body.LegacyLastName,
+		Email:     body.Email,
+		Greeting:  body.Greeting,
+		Language:  body.Language,
+	}
+	if body.FirstName != nil {
+		req.FirstName = *body.FirstName
+	}
+	if body.LastName != nil {
+		req.LastName = *body.LastName
+	}
+	return nil
+}
+
+// newUser returns the user req asks to create.
+func (req CreateUserRequest) newUser() users.NewUser {
+	return users.NewUser{FirstName: req.FirstName, LastName: req.LastName, Email: req.Email}
+}
+
+// UserResponse is a user as every users route returns it.
+type UserResponse struct {
+	ID        string    `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Verified  bool      `json:"verified"`
+
+	Tags jsonList[string] `json:"tags"`
+}
+
+func newUserResponse(u users.User) UserResponse {
+	return UserResponse{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email.Address,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		Verified:  u.Verified,
+		Tags:      u.Tags,
+	}
+}
+
+// GreetingResponse is a greeting as the JSON API versions return it.
+type GreetingResponse struct {
+	Greeting string `json:"greeting"`
+	Language string `json:"language"`
+}
+
+func newGreetingResponse(res greeting.Result) GreetingResponse {
+	return GreetingResponse{Greeting: res.Greeting, Language: res.Language}
+}
+
+// ImportResultResponse is the body of a successful POST /users/archive.
+type ImportResultResponse struct {
+	Added       int `json:"added"`
+	Overwritten int `json:"overwritten"`
+	Skipped     int `json:"skipped"`
+}
+
+func newImportResultResponse(res users.ImportResult) ImportResultResponse {
+	return ImportResultResponse{Added: res.Added, Overwritten: res.Overwritten, Skipped: res.Skipped}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestWireFormat(t *testing.T) {
+	created := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			"create user request",
+			CreateUserRequest{FirstName: "ada", LastName: "lovelace", Email: "ada@bar.com"},
+			`{"first_name":"ada","last_name":"lovelace","email":"ada@bar.com"}`,
+		},
+		{
+			"user response",
+			newUserResponse(users.User{
+				ID:        "0b9f7c4e-5d1a-4f2b-9c3d-6e8a1b2c3d4e",
+				FirstName: "ada",
+				LastName:  "lovelace",
+				Email:     mail.Address{Address: "ada@bar.com"},
+				CreatedAt: created,
+				UpdatedAt: created.Add(time.Hour),
+				Tags:      []string{"admin"},
+			}),
+			`{"id":"0b9f7c4e-5d1a-4f2b-9c3d-6e8a1b2c3d4e","first_name":"ada","last_name":"lovelace","email":"ada@bar.com","created_at":"2024-03-01T12:00:00Z","updated_at":"2024-03-01T13:00:00Z","verified":false,"tags":["admin"]}`,
+		},
+		{
+			"user response without tags",
+			newUserResponse(users.User{FirstName: "ada", LastName: "lovelace", CreatedAt: created, UpdatedAt: created}),
+			`{"id":"","first_name":"ada","last_name":"lovelace","email":"","created_at":"2024-03-01T12:00:00Z","updated_at":"2024-03-01T12:00:00Z","verified":false,"tags":[]}`,
+		},
+		{
+			"greeting response",
+			newGreetingResponse(greeting.Result{Greeting: "Bonjour ada !", Language: "fr"}),
+			`{"greeting":"Bonjour ada !","language":"fr"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("bad wire format:\nexpected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCreateUserRequestLegacyNames(t *testing.T) {
+	tests := []struct {
+		body string
+		want CreateUserRequest
+	}{
+		{`{"first_name":"ada","last_name":"lovelace","email":"ada@bar.com"}`, CreateUserRequest{FirstName: "ada", LastName: "lovelace", Email: "ada@bar.com"}},
+		{`{"FirstName":"ada","LastName":"lovelace","Email":"ada@bar.com"}`, CreateUserRequest{FirstName: "ada", LastName: "lovelace", Email: "ada@bar.com"}},
+		{`{"firstName":"ada","lastName":"lovelace","email":"ada@bar.com"}`, CreateUserRequest{FirstName: "ada", LastName: "lovelace", Email: "ada@bar.com"}},
+		{`{"FirstName":"old","first_name":"ada","Greeting":"Hi","Language":"en"}`, CreateUserRequest{FirstName: "ada", Greeting: "Hi", Language: "en"}},
+		// An empty snake_case name still wins, so validation reports it.
+		{`{"first_name":"","FirstName":"ada"}`, CreateUserRequest{}},
+	}
+	for _, tt := range tests {
+		var got CreateUserRequest
+		if err := json.Unmarshal([]byte(tt.body), &got); err != nil {
+			t.Errorf("%s: %v", tt.body, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.body, tt.want, got)
+		}
+	}
+
+	var got CreateUserRequest
+	if err := json.Unmarshal([]byte(`{"first_name":1}`), &got); err == nil {
+		t.Error("expected an error for a non-string name")
+	}
+}
+
+func TestUsersWireFormat(t *testing.T) {
+	created := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	s := NewServer(newTestServer(t).logger, users.NewManager(users.WithClock(func() time.Time { return created })))
+	handler := s.Routes()
+
+	for _, body := range []string{
+		`{"first_name":"ada","last_name":"lovelace","email":"ada@bar.com"}`,
+		`{"FirstName":"grace","LastName":"hopper","Email":"grace@bar.com"}`,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s: bad response code: expected %d, got %d\nbody: %s\n", body, http.StatusCreated, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users?limit=1", nil))
+	var resp struct {
+		Users      []json.RawMessage `json:"users"`
+		Pagination map[string]any    `json:"pagination"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("error decoding response: %v\nbody: %s\n", err, w.Body.String())
+	}
+	ada, err := s.users.GetUserByEmail(context.Background(), "ada@bar.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":"` + ada.ID + `","first_name":"ada","last_name":"lovelace","email":"ada@bar.com","created_at":"2024-03-01T12:00:00Z","updated_at":"2024-03-01T12:00:00Z","verified":false,"tags":[]}`
+	if len(resp.Users) != 1 || string(resp.Users[0]) != want {
+		t.Errorf("bad user:\nexpected %s\ngot      %s", want, w.Body.String())
+	}
+	if cursor, _ := resp.Pagination["next_cursor"].(string); cursor == "" {
+		t.Errorf("missing next_cursor: %v", resp.Pagination)
+	}
+}
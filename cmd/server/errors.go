@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// Error codes returned in the "code" field of every error response. Clients
+// switch on these, so they must never change once published.
+const (
+	codeInvalidRequest      = "invalid_request"
+	codeNotFound            = "not_found"
+	codeUnauthorized        = "unauthorized"
+	codeForbidden           = "forbidden"
+	codeMethodNotAllowed    = "method_not_allowed"
+	codeGone                = "gone"
+	codeConflict            = "conflict"
+	codePreconditionFailed  = "precondition_failed"
+	codeValidation          = "validation_failed"
+	codeInvalidCursor       = "invalid_cursor"
+	codeMissingUser         = "missing_user"
+	codeTooLarge            = "request_too_large"
+	codeURITooLong          = "uri_too_long"
+	codeUnsupportedMedia    = "unsupported_media_type"
+	codeIdempotencyMismatch = "idempotency_key_mismatch"
+	codeTimeout             = "timeout"
+	codeRequestTimeout      = "request_timeout"
+	codeStoreTimeout        = "store_timeout"
+	codeOverloaded          = "overloaded"
+	codeShuttingDown        = "shutting_down"
+	codeInternal            = "internal_error"
+)
+
+// writeValidationError writes a 422 listing every field that failed
+// validation under "fields".
+func writeValidationError(w http.ResponseWriter, errs users.ValidationErrors) {
+	httpx.WriteErrorBody(w, http.StatusUnprocessableEntity, httpx.Error{
+		Code:    codeValidation,
+		Message: "validation failed",
+		Fields:  errs,
+	})
+}
+
+// statusRecorder captures the status and headers of a handler whose body is
+// going to be replaced.
+type statusRecorder struct {
+	header http.Header
+	status int
+}
+
+func (sr *statusRecorder) Header() http.Header         { return sr.header }
+func (sr *statusRecorder) Write(b []byte) (int, error) { return len(b), nil }
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.status == 0 {
+		sr.status = code
+	}
+}
+
+// withErrorHandlers replaces the mux's plain-text 404 and 405 responses with
+// JSON error responses. The mux reports both cases with an empty pattern.
+func withErrorHandlers(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &statusRecorder{header: make(http.Header)}
+		h.ServeHTTP(rec, r)
+
+		switch rec.status {
+		case http.StatusMethodNotAllowed:
+			if allow := rec.header.Get("Allow"); allow != "" {
+				w.Header().Set("Allow", allow)
+			}
+			httpx.WriteError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed")
+		default:
+			httpx.WriteError(w, http.StatusNotFound, codeNotFound, "not found")
+		}
+	})
+}
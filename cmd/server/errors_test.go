@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// errorResponse decodes the error envelope written by httpx.WriteError.
+type errorResponse struct {
+	Error struct {
+		Code    string                 `json:"code"`
+		Message string                 `json:"message"`
+		Fields  users.ValidationErrors `json:"fields,omitempty"`
+	} `json:"error"`
+}
+
+func assertErrorCode(t *testing.T, w *httptest.ResponseRecorder, code string, message string) {
+	t.Helper()
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("bad content type: expected application/json, got %q", ct)
+	}
+
+	var resp errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("error decoding error response: %v\nbody: %s\n", err, w.Body.String())
+	}
+	if resp.Error.Code != code {
+		t.Errorf("bad error code: expected %q, got %q", code, resp.Error.Code)
+	}
+	if message != "" && resp.Error.Message != message {
+		t.Errorf("bad error message: expected %q, got %q", message, resp.Error.Message)
+	}
+}
+
+func TestHandleJSONInvalidJSON(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader("{"))
+	w := httptest.NewRecorder()
+
+	newTestServer(t).handleJSON(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad response code: expected %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	assertErrorCode(t, w, codeInvalidRequest, "error parsing request body")
+}
+
+func TestErrorHandlers(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		code   string
+	}{
+		{"unknown path", http.MethodGet, "/no/such/path", http.StatusNotFound, codeNotFound},
+		{"wrong method", http.MethodGet, "/json", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("bad response code: expected %d, got %d", tt.status, w.Code)
+			}
+			assertErrorCode(t, w, tt.code, "")
+		})
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/json", nil))
+	if allow := w.Header().Get("Allow"); !strings.Contains(allow, http.MethodPost) {
+		t.Errorf("missing Allow header on 405: got %q", allow)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("matched route not served: got %d", w.Code)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// exportCacheBudget bounds the memory held by cached export snapshots.
+const exportCacheBudget = 64 << 20
+
+// revisionHeader reports the snapshot revision an export was rendered from.
+// Clients pass it back as ?revision= to resume a download of the same bytes.
+const revisionHeader = "X-Export-Revision"
+
+type exportSnapshot struct {
+	rev     uint64
+	data    []byte
+	created time.Time
+	// etag is a strong ETag derived from a hash of data. Revisions restart
+	// with the process and move on restore, so only the bytes themselves
+	// identify a snapshot across them.
+	etag string
+}
+
+func newExportSnapshot(rev uint64, data []byte, created time.Time) *exportSnapshot {
+	sum := sha256.Sum256(data)
+	return &exportSnapshot{
+		rev:     rev,
+		data:    data,
+		created: created,
+		etag:    `"users-` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+}
+
+// exportCache keeps rendered export snapshots keyed by revision so ranged
+// requests for the same revision are served from identical bytes. Snapshots
+// are evicted least recently used first once budget bytes are exceeded.
+type exportCache struct {
+	mu      sync.Mutex
+	budget  int
+	used    int
+	lru     *list.List
+	entries map[uint64]*list.Element
+}
+
+func newExportCache(budget int) *exportCache {
+	return &exportCache{
+		budget:  budget,
+		lru:     list.New(),
+		entries: make(map[uint64]*list.Element),
+	}
+}
+
+func (c *exportCache) get(rev uint64) (*exportSnapshot, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[rev]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*exportSnapshot), true
+}
+
+// put caches s unless it alone exceeds the budget.
+func (c *exportCache) put(s *exportSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[s.rev]; ok || len(s.data) > c.budget {
+		return
+	}
+
+	c.entries[s.rev] = c.lru.PushFront(s)
+	c.used += len(s.data)
+	for c.used > c.budget {
+		oldest := c.lru.Back()
+		evicted := c.lru.Remove(oldest).(*exportSnapshot)
+		delete(c.entries, evicted.rev)
+		c.used -= len(evicted.data)
+	}
+}
+
+// stats reports the number of cached snapshots and their total size.
+func (c *exportCache) stats() (entries int, bytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries), c.used
+}
+
+// currentExport returns the snapshot for the store's current revision,
+// rendering and caching it on first use. If ctx is done before rendering
+// finishes, the partial export is logged and dropped.
+func (s *Server) currentExport(ctx context.Context) (*exportSnapshot, error) {
+	rev, err := s.store.Revision(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot, ok := s.exports.get(rev); ok {
+		return snapshot, nil
+	}
+	// The revision is read before the users: a mutation in between only
+	// files newer rows under an older revision, which later reads skip.
+	all, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	rows, err := users.ExportCSV(ctx, &buf, all)
+	if err != nil {
+		if ctx.Err() != nil {
+			s.logger.Info("export stopped: client went away", "format", "csv", "rows", rows, "total", len(all))
+		}
+		return nil, err
+	}
+
+	snapshot := newExportSnapshot(rev, buf.Bytes(), time.Now())
+	s.exports.put(snapshot)
+	return snapshot, nil
+}
+
+// handleUsersExport serves the users CSV export. Range and If-Range are
+// honoured through http.ServeContent against a strong ETag derived from the
+// snapshot's bytes, so a resumed download never mixes two revisions, even
+// across a restart or a restore.
+// ?revision= selects a previously rendered snapshot while it is still cached.
+func (s *Server) handleUsersExport(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	var snapshot *exportSnapshot
+	if v := r.URL.Query().Get("revision"); v != "" {
+		rev, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "invalid revision")
+			return
+		}
+
+		var ok bool
+		snapshot, ok = s.exports.get(rev)
+		if !ok {
+			httpx.WriteError(w, http.StatusGone, codeGone, "export revision no longer available")
+			return
+		}
+	} else {
+		var err error
+		snapshot, err = s.currentExport(r.Context())
+		if err != nil && r.Context().Err() != nil {
+			// currentExport has logged it, and there is no one to answer.
+			return
+		}
+		if err != nil {
+			s.logger.Error("error rendering export", "err", err)
+			httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "error rendering export")
+			return
+		}
+	}
+
+	// Large exports may legitimately take longer than the server-wide
+	// write timeout, so lift it for this response.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("ETag", snapshot.etag)
+	w.Header().Set(revisionHeader, strconv.FormatUint(snapshot.rev, 10))
+	// The stream logs a failed write; ServeContent stops copying at it.
+	http.ServeContent(s.newStream(w, "csv export"), r, "users.csv", snapshot.created, bytes.NewReader(snapshot.data))
+}
+
+// newStream wraps w in an httpx.Stream that counts its abort in
+// s.streamAborts.
+func (s *Server) newStream(w http.ResponseWriter, name string) *httpx.Stream {
+	return httpx.NewStream(w, name, func(clientGone bool) {
+		if clientGone {
+			s.streamAborts.clientGone.Add(1)
+		} else {
+			s.streamAborts.failed.Add(1)
+		}
+	})
+}
+
+// handleUsersExportFormat serves GET /users/export. format=ndjson, the
+// default, streams JSON Lines straight from the manager and flushes after
+// every batch so clients see data before the export completes. Unlike the
+// CSV export it is not a snapshot and does not support ranges.
+// format=csv serves the cached CSV export.
+func (s *Server) handleUsersExportFormat(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("format") {
+	case "", "ndjson":
+	case "csv":
+		s.handleUsersExport(w, r)
+		return
+	default:
+		s.logRequest(r)
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "format must be ndjson or csv")
+		return
+	}
+
+	s.logRequest(r)
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	// Once the status is sent errors can only be logged; the client sees a
+	// truncated stream. A failed write has been logged by the stream, and a
+	// client that goes away stops the export at the next batch, which is
+	// not an error.
+	rows, err := users.ExportNDJSON(r.Context(), s.newStream(w, "ndjson export"), s.store)
+	switch {
+	case errors.Is(err, httpx.ErrStreamAborted):
+	case r.Context().Err() != nil:
+		s.logger.Info("export stopped: client went away", "format", "ndjson", "rows", rows)
+	case err != nil:
+		s.logger.Error("error streaming export", "err", err, "rows", rows)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/fixtures"
+)
+
+func newExportServer(t *testing.T, count int) *Server {
+	t.Helper()
+
+	f := &fixtures.Fixture{}
+	for i := range count {
+		f.Users = append(f.Users, fixtures.User{
+			FirstName: fmt.Sprintf("first%d", i),
+			LastName:  "last",
+			Email:     fmt.Sprintf("user%d@bar.com", i),
+		})
+	}
+
+	return NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), f.Manager(t))
+}
+
+func getExport(t *testing.T, s *Server, target string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	s.handleUsersExport(w, r)
+	return w
+}
+
+func TestUsersExportRanges(t *testing.T) {
+	s := newExportServer(t, 50)
+
+	full := getExport(t, s, "/users/export.csv", nil)
+	if full.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n",
+			http.StatusOK, full.Code, full.Body.String())
+	}
+	etag := full.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag header")
+	}
+
+	size := full.Body.Len()
+	third := size / 3
+	chunks := []string{
+		fmt.Sprintf("bytes=0-%d", third-1),
+		fmt.Sprintf("bytes=%d-%d", third, 2*third-1),
+		fmt.Sprintf("bytes=%d-", 2*third),
+	}
+
+	var assembled bytes.Buffer
+	for _, rng := range chunks {
+		w := getExport(t, s, "/users/export.csv", http.Header{
+			"Range":    {rng},
+			"If-Range": {etag},
+		})
+		if w.Code != http.StatusPartialContent {
+			t.Fatalf("bad response code for %s: expected %d, got %d",
+				rng, http.StatusPartialContent, w.Code)
+		}
+		if w.Header().Get("Content-Range") == "" {
+			t.Errorf("missing Content-Range for %s", rng)
+		}
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("ETag changed between chunks: expected %s, got %s", etag, got)
+		}
+		assembled.Write(w.Body.Bytes())
+	}
+
+	if !bytes.Equal(assembled.Bytes(), full.Body.Bytes()) {
+		t.Errorf("reassembled export does not match full download")
+	}
+
+	w := getExport(t, s, "/users/export.csv", http.Header{
+		"Range": {fmt.Sprintf("bytes=%d-", size+10)},
+	})
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("bad response code: expected %d, got %d",
+			http.StatusRequestedRangeNotSatisfiable, w.Code)
+	}
+}
+
+func TestUsersExportETagAcrossRestart(t *testing.T) {
+	before := getExport(t, newExportServer(t, 10), "/users/export.csv", nil)
+
+	// A restarted server holding different users reaches the same revision.
+	f := &fixtures.Fixture{}
+	for i := range 10 {
+		f.Users = append(f.Users, fixtures.User{
+			FirstName: fmt.Sprintf("other%d", i),
+			LastName:  "last",
+			Email:     fmt.Sprintf("other%d@bar.com", i),
+		})
+	}
+	restarted := NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), f.Manager(t))
+
+	w := getExport(t, restarted, "/users/export.csv", http.Header{
+		"Range":    {"bytes=10-"},
+		"If-Range": {before.Header().Get("ETag")},
+	})
+	if w.Header().Get(revisionHeader) != before.Header().Get(revisionHeader) {
+		t.Fatalf("revisions differ, so the test proves nothing: %s and %s",
+			before.Header().Get(revisionHeader), w.Header().Get(revisionHeader))
+	}
+	if w.Header().Get("ETag") == before.Header().Get("ETag") {
+		t.Errorf("different exports share ETag %s", w.Header().Get("ETag"))
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("bad response code: expected full %d for another server's ETag, got %d", http.StatusOK, w.Code)
+	}
+
+	again := getExport(t, newExportServer(t, 10), "/users/export.csv", nil)
+	if again.Header().Get("ETag") != before.Header().Get("ETag") {
+		t.Errorf("identical exports have different ETags: %s and %s", before.Header().Get("ETag"), again.Header().Get("ETag"))
+	}
+}
+
+func TestUsersExportRevisionChange(t *testing.T) {
+	s := newExportServer(t, 10)
+
+	first := getExport(t, s, "/users/export.csv", nil)
+	oldETag := first.Header().Get("ETag")
+	oldRev := first.Header().Get(revisionHeader)
+
+	err := s.users.AddUser(context.Background(), "late", "last", "late@bar.com")
+	if err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+
+	w := getExport(t, s, "/users/export.csv", http.Header{
+		"Range":    {"bytes=10-"},
+		"If-Range": {oldETag},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected full %d after revision change, got %d",
+			http.StatusOK, w.Code)
+	}
+	if w.Header().Get("ETag") == oldETag {
+		t.Errorf("ETag not changed after revision change")
+	}
+
+	w = getExport(t, s, "/users/export.csv?revision="+oldRev, http.Header{
+		"Range":    {"bytes=10-"},
+		"If-Range": {oldETag},
+	})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("bad response code for cached revision: expected %d, got %d",
+			http.StatusPartialContent, w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), first.Body.Bytes()[10:]) {
+		t.Errorf("cached revision served different bytes")
+	}
+
+	w = getExport(t, s, "/users/export.csv?revision=999", nil)
+	if w.Code != http.StatusGone {
+		t.Errorf("bad response code for unknown revision: expected %d, got %d",
+			http.StatusGone, w.Code)
+	}
+	assertErrorCode(t, w, codeGone, "")
+}
+
+func TestExportCacheEviction(t *testing.T) {
+	cache := newExportCache(10)
+	cache.put(&exportSnapshot{rev: 1, data: make([]byte, 4)})
+	cache.put(&exportSnapshot{rev: 2, data: make([]byte, 4)})
+	cache.get(1)
+	cache.put(&exportSnapshot{rev: 3, data: make([]byte, 4)})
+
+	if _, ok := cache.get(2); ok {
+		t.Errorf("least recently used snapshot not evicted")
+	}
+	for _, rev := range []uint64{1, 3} {
+		if _, ok := cache.get(rev); !ok {
+			t.Errorf("snapshot %d evicted unexpectedly", rev)
+		}
+	}
+
+	cache.put(&exportSnapshot{rev: 4, data: make([]byte, 11)})
+	if _, ok := cache.get(4); ok {
+		t.Errorf("snapshot larger than budget was cached")
+	}
+}
+
+// flushRecorder records how much of the body had been written at each flush.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushedAt []int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushedAt = append(f.flushedAt, f.Body.Len())
+	f.ResponseRecorder.Flush()
+}
+
+func TestUsersExportNDJSONStreams(t *testing.T) {
+	s := newExportServer(t, 1200)
+
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/export?format=ndjson", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n",
+			http.StatusOK, w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("bad content type: expected application/x-ndjson, got %q", ct)
+	}
+	if lines := bytes.Count(w.Body.Bytes(), []byte("\n")); lines != 1200 {
+		t.Errorf("expected 1200 lines, got %d", lines)
+	}
+
+	if len(w.flushedAt) < 2 || w.flushedAt[0] == 0 || w.flushedAt[0] >= w.Body.Len() {
+		t.Errorf("expected partial flushes before the export completed, got flushes at %v of %d bytes",
+			w.flushedAt, w.Body.Len())
+	}
+}
+
+// cancelOnFlush cancels the request after the first flush, as if the
+// client went away once it had the first batch.
+type cancelOnFlush struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnFlush) Flush() {
+	c.ResponseRecorder.Flush()
+	c.cancel()
+}
+
+func TestUsersExportNDJSONStopsWhenClientLeaves(t *testing.T) {
+	s := newExportServer(t, 1200)
+	var logs bytes.Buffer
+	s.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &cancelOnFlush{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+	s.Routes().ServeHTTP(w, httptest.NewRequestWithContext(ctx, http.MethodGet, "/users/export?format=ndjson", nil))
+
+	if lines := bytes.Count(w.Body.Bytes(), []byte("\n")); lines != 500 {
+		t.Errorf("expected the export to stop after 500 lines, got %d", lines)
+	}
+	if !strings.Contains(logs.String(), `level=INFO msg="export stopped: client went away" format=ndjson rows=500`) {
+		t.Errorf("partial export not logged:\n%s", logs.String())
+	}
+}
+
+// failOnWrite fails the failAt-th body write and every one after it,
+// counting them all.
+type failOnWrite struct {
+	*httptest.ResponseRecorder
+	failAt int
+	writes int
+}
+
+func (f *failOnWrite) Write(p []byte) (int, error) {
+	f.writes++
+	if f.writes >= f.failAt {
+		return 0, errors.New("disk on fire")
+	}
+	return f.ResponseRecorder.Write(p)
+}
+
+func TestUsersExportAbortsOnWriteFailure(t *testing.T) {
+	for _, target := range []string{"/users/export?format=ndjson", "/users/export.csv"} {
+		t.Run(target, func(t *testing.T) {
+			s := newExportServer(t, 20000)
+			var logs bytes.Buffer
+			s.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+			w := &failOnWrite{ResponseRecorder: httptest.NewRecorder(), failAt: 2}
+			s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+			if w.writes != 2 {
+				t.Errorf("expected writing to stop at the failed write, got %d writes", w.writes)
+			}
+			if n := strings.Count(logs.String(), "level=ERROR"); n != 1 || !strings.Contains(logs.String(), `level=ERROR msg="stream aborted"`) {
+				t.Errorf("expected exactly one error entry, got:\n%s", logs.String())
+			}
+			if got := s.streamAborts.failed.Load(); got != 1 {
+				t.Errorf("expected one abort counted, got %d", got)
+			}
+		})
+	}
+}
+
+func TestUsersExportFormat(t *testing.T) {
+	handler := newExportServer(t, 3).Routes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/export?format=csv", nil))
+	if ct := w.Header().Get("Content-Type"); w.Code != http.StatusOK || ct != "text/csv; charset=utf-8" {
+		t.Errorf("format=csv: unexpected response %d %q", w.Code, ct)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/export?format=xml", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+			http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeInvalidRequest, "format must be ndjson or csv")
+}
+
+func TestUsersExportOmitsPasswordHash(t *testing.T) {
+	s := newExportServer(t, 1)
+	if err := s.users.SetPassword("first0", "last", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := getExport(t, s, "/users/export.csv", nil)
+	if bytes.Contains(w.Body.Bytes(), []byte("$2a$")) {
+		t.Errorf("export contains password hash:\n%s", w.Body.String())
+	}
+}
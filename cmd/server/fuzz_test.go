@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+)
+
+// FuzzHandleJSON checks that handleJSON never panics, only succeeds for
+// bodies that decode into a valid CreateUserRequest, and rejects bodies that decode
+// into an invalid one with 422.
+func FuzzHandleJSON(f *testing.F) {
+	seeds := []string{
+		`{"FirstName":"human"}`,
+		`{"FirstName":""}`,
+		`{"FirstName":"alice","LastName":"smith","Email":"alice@example.com"}`,
+		``,
+		`{`,
+		`null`,
+		`[]`,
+		`"FirstName"`,
+		`{"FirstName":"\u0000"}`,
+		`{"FirstName":"\ud800"}`,
+		`{"FirstName":"{{.Name}}"}`,
+		`{"FirstName":1}`,
+		`{"FirstName":"a"}{"FirstName":"b"}`,
+		`{"firstname":"case"}`,
+		strings.Repeat("[", 10000) + strings.Repeat("]", 10000),
+		`{"FirstName":"x","Nested":` + strings.Repeat(`{"a":`, 1000) + `1` + strings.Repeat(`}`, 1000) + `}`,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/json", bytes.NewReader(body))
+		newTestServer(t).handleJSON(w, r)
+
+		var data CreateUserRequest
+		decoded := len(body) > 0 && json.Unmarshal(body, &data) == nil
+		valid := decoded && data.Validate() == nil
+
+		switch {
+		case valid && w.Code != http.StatusOK:
+			t.Errorf("bad response code for valid body %q: expected %d, got %d\nbody: %s\n",
+				body, http.StatusOK, w.Code, w.Body.String())
+		case !valid && w.Code == http.StatusOK:
+			t.Errorf("accepted invalid body %q\nbody: %s\n", body, w.Body.String())
+		case decoded && !valid && w.Code != http.StatusUnprocessableEntity:
+			t.Errorf("bad response code for invalid user %q: expected %d, got %d\nbody: %s\n",
+				body, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		case !decoded && w.Code != http.StatusBadRequest:
+			t.Errorf("bad response code for invalid body %q: expected %d, got %d\nbody: %s\n",
+				body, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	})
+}
+
+// FuzzSanitizeUsername checks that greeting.Sanitize is idempotent, leaves
+// no control characters and keeps every other character, and that a
+// greeting rendered for any name carries no control characters either.
+func FuzzSanitizeUsername(f *testing.F) {
+	seeds := []string{
+		"alice",
+		"",
+		"a\tb\nc",
+		"\x1b[31mred\x1b[0m",
+		"line\r\nbreak",
+		"next\u0085line",
+		"bell\a",
+		"\x00",
+		"\xff\xfe",
+		"Zoë",
+		"{{.Name}}",
+		"\u202eevil",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	g, err := greeting.New()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		got := greeting.Sanitize(name)
+		if strings.ContainsFunc(got, unicode.IsControl) {
+			t.Errorf("Sanitize(%q) = %q: control character left", name, got)
+		}
+		if again := greeting.Sanitize(got); again != got {
+			t.Errorf("Sanitize is not idempotent: %q, then %q", got, again)
+		}
+		if utf8.ValidString(name) {
+			controls := 0
+			for _, r := range name {
+				if unicode.IsControl(r) {
+					controls++
+				}
+			}
+			if utf8.RuneCountInString(got) != utf8.RuneCountInString(name)-controls {
+				t.Errorf("Sanitize(%q) = %q: expected only control characters removed", name, got)
+			}
+		}
+
+		res, err := g.Negotiate("", name)
+		if err == nil && strings.ContainsFunc(res.Greeting, unicode.IsControl) {
+			t.Errorf("greeting for %q carries a control character: %q", name, res.Greeting)
+		}
+	})
+}
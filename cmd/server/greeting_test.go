@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/i18n"
+)
+
+func TestCustomGreetingTemplate(t *testing.T) {
+	s := newTestServer(t)
+	s.now = func() time.Time { return time.Date(2024, time.March, 1, 8, 30, 0, 0, time.UTC) }
+
+	greeter, err := s.newGreeter(greeting.WithTemplate(`{{if lt .Time.Hour 12}}Good morning{{else}}Hello{{end}}, {{.Name}}!`))
+	if err != nil {
+		t.Fatalf("error setting greeting template: %v", err)
+	}
+	s.greeter = greeter
+
+	tests := []struct {
+		name string
+		user string
+		want string
+	}{
+		{"time variable", "alice", "Good morning, alice!\n"},
+		{"name is not executed", "{{.Time}}", "Good morning, {{.Time}}!\n"},
+		{"name with template actions", `{{template "x"}}{{printf "%s" 1}}`, `Good morning, {{template "x"}}{{printf "%s" 1}}!` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/user/hello", nil)
+			r.Header.Set("user", tt.user)
+			w := httptest.NewRecorder()
+
+			s.helloFromHeader("user")(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("bad response code: expected %d, got %d", http.StatusOK, w.Code)
+			}
+			if w.Body.String() != tt.want {
+				t.Errorf("bad response body: expected %q, got %q", tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestInvalidGreetingTemplate(t *testing.T) {
+	locales := i18n.Builtin()
+	for _, bad := range []string{"Hello {{.Name", "Hello {{.Username}}!"} {
+		if err := locales.SetDefault(i18n.KeyGreeting, bad); err == nil {
+			t.Errorf("no error returned for greeting template %q", bad)
+		}
+	}
+}
+
+func TestHelloMatchesGreeter(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.Routes()
+
+	text, err := s.greeter.Greet("alice")
+	if err != nil {
+		t.Fatalf("error greeting: %v", err)
+	}
+	data, err := s.greeter.GreetJSON("alice")
+	if err != nil {
+		t.Fatalf("error greeting: %v", err)
+	}
+
+	tests := []struct {
+		target string
+		header string
+		want   string
+	}{
+		{"/hello/?user=alice", "", text + "\n"},
+		{"/responses/alice/hello/", "", text + "\n"},
+		{"/user/hello", "alice", text + "\n"},
+		{"/api/v1/hello?user=alice", "", string(data) + "\n"},
+		{"/api/v1/hello/alice", "", string(data) + "\n"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.header != "" {
+			r.Header.Set("user", tt.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Body.String() != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.target, tt.want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	greeterv1 "github.com/kunalkumar-1/go-http/internal/grpcapi/greeter/v1"
+	"github.com/kunalkumar-1/go-http/internal/users"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcGreeter serves greeterv1.GreeterService from the Server's manager,
+// store and greeter, the same ones the HTTP handlers use, so the two APIs
+// answer alike. Each RPC mirrors the HTTP route named in its comment.
+type grpcGreeter struct {
+	greeterv1.UnimplementedGreeterServiceServer
+	s *Server
+}
+
+// GRPCServer returns a grpc.Server serving the GreeterService. Calls are
+// bounded by the store timeout, like the HTTP routes.
+func (s *Server) GRPCServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.UnaryInterceptor(s.grpcStoreDeadline))
+	greeterv1.RegisterGreeterServiceServer(srv, &grpcGreeter{s: s})
+	return srv
+}
+
+// grpcStoreDeadline is withStoreDeadline for gRPC calls.
+func (s *Server) grpcStoreDeadline(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if s.timeouts.Store <= 0 {
+		return handler(ctx, req)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Store)
+	defer cancel()
+	return handler(ctx, req)
+}
+
+// SayHello mirrors GET /api/v1/hello?user=. The greeting's language is
+// negotiated from the accept-language metadata.
+func (g *grpcGreeter) SayHello(ctx context.Context, req *greeterv1.HelloRequest) (*greeterv1.HelloResponse, error) {
+	s := g.s
+	acceptLanguage := strings.Join(metadata.ValueFromIncomingContext(ctx, "accept-language"), ",")
+
+	name := req.GetName()
+	if strings.TrimSpace(name) == "" {
+		switch s.missingName.mode {
+		case missingNameReject:
+			return nil, status.Error(codes.InvalidArgument, "name is required")
+		case missingNameAnonymous:
+			res, err := s.greeter.NegotiateAnonymous(acceptLanguage)
+			if err != nil {
+				s.logger.Error("error rendering greeting", "err", err)
+				return nil, status.Error(codes.Internal, "error rendering greeting")
+			}
+			return &greeterv1.HelloResponse{Message: res.Greeting}, nil
+		}
+		name = s.missingName.name
+	} else if err := s.checkUsername(name); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.recordGreetingByName(name)
+
+	res, err := s.greeter.Negotiate(acceptLanguage, name)
+	if err != nil {
+		s.logger.Error("error rendering greeting", "err", err)
+		return nil, status.Error(codes.Internal, "error rendering greeting")
+	}
+	s.countGreeting(nil, name)
+	return &greeterv1.HelloResponse{Message: res.Greeting}, nil
+}
+
+// CreateUser mirrors POST /users.
+func (g *grpcGreeter) CreateUser(ctx context.Context, req *greeterv1.CreateUserRequest) (*greeterv1.User, error) {
+	s := g.s
+	if err := s.users.NameRules().ValidateUser(req.GetFirstName(), req.GetLastName(), req.GetEmail()); err != nil {
+		return nil, s.grpcUserError(err)
+	}
+
+	err := s.store.AddUser(ctx, req.GetFirstName(), req.GetLastName(), req.GetEmail())
+	var user *users.User
+	if err == nil {
+		user, err = s.store.GetUserByEmail(ctx, req.GetEmail())
+	}
+	if err != nil {
+		return nil, s.grpcUserError(err)
+	}
+
+	s.sendVerification(*user)
+	return newGRPCUser(*user), nil
+}
+
+// GetUser mirrors GET /users/{email}.
+func (g *grpcGreeter) GetUser(ctx context.Context, req *greeterv1.GetUserRequest) (*greeterv1.User, error) {
+	user, err := g.s.store.GetUserByEmail(ctx, req.GetEmail())
+	if err != nil {
+		return nil, g.s.grpcUserError(err)
+	}
+	return newGRPCUser(*user), nil
+}
+
+// ListUsers mirrors GET /users paged by cursor: page_token is the signed
+// cursor the HTTP route returns as next_cursor.
+func (g *grpcGreeter) ListUsers(ctx context.Context, req *greeterv1.ListUsersRequest) (*greeterv1.ListUsersResponse, error) {
+	s := g.s
+	limit := int(req.GetPageSize())
+	switch {
+	case limit == 0:
+		limit = defaultPageLimit
+	case limit < 0 || limit > maxPageLimit:
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be between 1 and %d", maxPageLimit)
+	}
+	cursor := users.Cursor{Sort: users.SortByInsertion}
+	if token := req.GetPageToken(); token != "" {
+		var ok bool
+		if cursor, ok = s.verifyCursor(token); !ok || cursor.Sort != users.SortByInsertion {
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired page_token")
+		}
+	}
+
+	page, next, err := s.store.ListAfter(ctx, cursor, limit, nil)
+	if err != nil {
+		return nil, s.grpcUserError(err)
+	}
+	resp := &greeterv1.ListUsersResponse{}
+	for _, u := range page {
+		resp.Users = append(resp.Users, newGRPCUser(u))
+	}
+	if next != nil {
+		resp.NextPageToken = s.signCursor(*next)
+	}
+	return resp, nil
+}
+
+// UpdateUser mirrors PUT /users/{email}, with version in place of If-Match.
+// It requires admin credentials.
+func (g *grpcGreeter) UpdateUser(ctx context.Context, req *greeterv1.UpdateUserRequest) (*greeterv1.User, error) {
+	s := g.s
+	ctx, err := s.grpcRequireRole(ctx, users.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.GetUserByEmail(ctx, req.GetEmail())
+	if err == nil {
+		err = s.store.UpdateUser(ctx, user.FirstName, user.LastName, req.GetNewEmail(), req.GetVersion())
+	}
+	if err == nil {
+		user, err = s.store.GetUserByName(ctx, user.FirstName, user.LastName)
+	}
+	if err != nil {
+		return nil, s.grpcUserError(err)
+	}
+	return newGRPCUser(*user), nil
+}
+
+// DeleteUser mirrors DELETE /users/{email}. It requires admin credentials.
+func (g *grpcGreeter) DeleteUser(ctx context.Context, req *greeterv1.DeleteUserRequest) (*greeterv1.DeleteUserResponse, error) {
+	s := g.s
+	ctx, err := s.grpcRequireRole(ctx, users.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.GetUserByEmail(ctx, req.GetEmail())
+	if err == nil {
+		err = s.store.DeleteUser(ctx, user.FirstName, user.LastName)
+	}
+	if err != nil {
+		return nil, s.grpcUserError(err)
+	}
+	return &greeterv1.DeleteUserResponse{}, nil
+}
+
+// grpcRequireRole is requireRole for gRPC calls, which carry the Basic
+// credentials in their authorization metadata. The returned context has the
+// principal as its users.WithActor actor.
+func (s *Server) grpcRequireRole(ctx context.Context, role users.Role) (context.Context, error) {
+	r := &http.Request{Header: http.Header{"Authorization": metadata.ValueFromIncomingContext(ctx, "authorization")}}
+	user, err := s.principal(r.WithContext(ctx))
+	if errors.Is(err, errUnauthenticated) {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, s.grpcUserError(err)
+	}
+	if err != nil {
+		s.logger.Error("error resolving principal", "err", err)
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	if user.Role != role {
+		return nil, status.Error(codes.PermissionDenied, "requires role "+string(role))
+	}
+	return users.WithActor(ctx, user.Email.Address), nil
+}
+
+// grpcUserError is writeUserError for gRPC: it maps a users.Store or
+// users.Manager error to a status. Validation failures carry a BadRequest
+// detail naming each bad field. Any other error is logged and reported as
+// Internal without its text.
+func (s *Server) grpcUserError(err error) error {
+	var verrs users.ValidationErrors
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "user store did not respond in time")
+	case errors.As(err, &verrs):
+		details := &errdetails.BadRequest{}
+		for _, e := range verrs {
+			details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: e.Field, Description: e.Message})
+		}
+		st, _ := status.New(codes.InvalidArgument, "validation failed").WithDetails(details)
+		return st.Err()
+	case errors.Is(err, users.ErrInvalidEmail), errors.Is(err, users.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, users.ErrUserDeleted):
+		return status.Error(codes.NotFound, "user was deleted")
+	case errors.Is(err, users.ErrNoResultFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, users.ErrDuplicateUser), errors.Is(err, users.ErrDuplicateEmail):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, users.ErrAmbiguousName):
+		// A 409 like the duplicates over HTTP, but nothing was created:
+		// Aborted is the other code gRPC maps to 409.
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, users.ErrVersionConflict):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		s.logger.Error("error serving gRPC call", "err", err)
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+func newGRPCUser(u users.User) *greeterv1.User {
+	return &greeterv1.User{
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email.Address,
+		Role:      string(u.Role),
+		Version:   u.Version,
+		CreatedAt: timestamppb.New(u.CreatedAt),
+		UpdatedAt: timestamppb.New(u.UpdatedAt),
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	greeterv1 "github.com/kunalkumar-1/go-http/internal/grpcapi/greeter/v1"
+	"github.com/kunalkumar-1/go-http/internal/users"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCClient serves s.GRPCServer on an in-memory listener for the
+// duration of the test and returns a client connected to it.
+func newGRPCClient(t *testing.T, s *Server) greeterv1.GreeterServiceClient {
+	t.Helper()
+
+	ln := bufconn.Listen(1 << 20)
+	srv := s.GRPCServer()
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return greeterv1.NewGreeterServiceClient(conn)
+}
+
+// withBasicAuth returns ctx carrying Basic credentials as authorization
+// metadata.
+func withBasicAuth(ctx context.Context, user string, password string) context.Context {
+	creds := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Basic "+creds)
+}
+
+func assertCode(t *testing.T, err error, expected codes.Code) {
+	t.Helper()
+
+	if got := status.Code(err); got != expected {
+		t.Errorf("bad status code: expected %s, got %s (%v)", expected, got, err)
+	}
+}
+
+func TestGRPCSayHelloMatchesHTTP(t *testing.T) {
+	s := newTestServer(t)
+	client := newGRPCClient(t, s)
+
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/hello?user=Alice", nil))
+	var expected GreetingResponse
+	if err := json.NewDecoder(w.Body).Decode(&expected); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.SayHello(context.Background(), &greeterv1.HelloRequest{Name: "Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetMessage() != expected.Greeting {
+		t.Errorf("expected %q, got %q", expected.Greeting, resp.GetMessage())
+	}
+
+	// A missing name greets the default name, as over HTTP.
+	resp, err = client.SayHello(context.Background(), &greeterv1.HelloRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetMessage() != "Hello User!" {
+		t.Errorf("expected the default name, got %q", resp.GetMessage())
+	}
+}
+
+func TestGRPCSayHelloRejectsMissingName(t *testing.T) {
+	s := newTestServer(t)
+	s.missingName = missingNamePolicy{mode: missingNameReject}
+	client := newGRPCClient(t, s)
+
+	_, err := client.SayHello(context.Background(), &greeterv1.HelloRequest{Name: " "})
+	assertCode(t, err, codes.InvalidArgument)
+}
+
+func TestGRPCCreateUser(t *testing.T) {
+	client := newGRPCClient(t, newTestServer(t))
+	ctx := context.Background()
+
+	user, err := client.CreateUser(ctx, &greeterv1.CreateUserRequest{FirstName: "jhon", LastName: "smith", Email: "foo@bar.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.GetEmail() != "foo@bar.com" || user.GetVersion() != 1 || user.GetCreatedAt() == nil {
+		t.Errorf("bad user: %v", user)
+	}
+
+	got, err := client.GetUser(ctx, &greeterv1.GetUserRequest{Email: "foo@bar.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetFirstName() != "jhon" {
+		t.Errorf("bad user: %v", got)
+	}
+
+	_, err = client.CreateUser(ctx, &greeterv1.CreateUserRequest{FirstName: "jane", LastName: "doe", Email: "foo@bar.com"})
+	assertCode(t, err, codes.AlreadyExists)
+
+	_, err = client.GetUser(ctx, &greeterv1.GetUserRequest{Email: "nobody@bar.com"})
+	assertCode(t, err, codes.NotFound)
+
+	_, err = client.GetUser(ctx, &greeterv1.GetUserRequest{Email: "not an email"})
+	assertCode(t, err, codes.InvalidArgument)
+}
+
+func TestGRPCUserError(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		err      error
+		expected codes.Code
+	}{
+		{fmt.Errorf("get: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{users.ValidationErrors{{Field: "email", Message: "must not be empty"}}, codes.InvalidArgument},
+		{fmt.Errorf("%w: x", users.ErrInvalidEmail), codes.InvalidArgument},
+		{users.ErrInvalidCursor, codes.InvalidArgument},
+		{users.ErrUserDeleted, codes.NotFound},
+		{users.ErrNoResultFound, codes.NotFound},
+		{users.ErrDuplicateEmail, codes.AlreadyExists},
+		{users.ErrAmbiguousName, codes.Aborted},
+		{users.ErrVersionConflict, codes.FailedPrecondition},
+		{errors.New("disk I/O error at /var/lib/users.db"), codes.Internal},
+	}
+	for _, tt := range tests {
+		err := s.grpcUserError(tt.err)
+		assertCode(t, err, tt.expected)
+		if tt.expected == codes.Internal && status.Convert(err).Message() != "internal server error" {
+			t.Errorf("internal error leaked: %v", err)
+		}
+	}
+}
+
+func TestGRPCCreateUserValidation(t *testing.T) {
+	client := newGRPCClient(t, newTestServer(t))
+
+	_, err := client.CreateUser(context.Background(), &greeterv1.CreateUserRequest{FirstName: "jhon", Email: "not an email"})
+	assertCode(t, err, codes.InvalidArgument)
+
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				fields = append(fields, v.GetField())
+			}
+		}
+	}
+	if len(fields) != 2 || fields[0] != "last_name" || fields[1] != "email" {
+		t.Errorf("expected violations of last_name and email, got %v", fields)
+	}
+}
+
+func TestGRPCListUsers(t *testing.T) {
+	s := newTestServer(t)
+	for _, email := range []string{"a@bar.com", "b@bar.com", "c@bar.com"} {
+		if err := s.users.AddUser(context.Background(), "user", email[:1], email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := newGRPCClient(t, s)
+
+	var emails []string
+	req := &greeterv1.ListUsersRequest{PageSize: 2}
+	for {
+		resp, err := client.ListUsers(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range resp.GetUsers() {
+			emails = append(emails, u.GetEmail())
+		}
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if len(emails) != 3 || emails[0] != "a@bar.com" || emails[2] != "c@bar.com" {
+		t.Errorf("expected every user once in order, got %v", emails)
+	}
+
+	_, err := client.ListUsers(context.Background(), &greeterv1.ListUsersRequest{PageToken: "forged"})
+	assertCode(t, err, codes.InvalidArgument)
+}
+
+func TestGRPCUpdateAndDeleteRequireAdmin(t *testing.T) {
+	m := users.NewManager()
+	addAdmin(t, m)
+	if err := m.AddUser(context.Background(), "Max", "Member", "member@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetPassword("Max", "Member", "member-password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	client := newGRPCClient(t, NewServer(newTestServer(t).logger, m))
+
+	update := &greeterv1.UpdateUserRequest{Email: "jhon@bar.com", NewEmail: "smith@bar.com"}
+	_, err := client.UpdateUser(context.Background(), update)
+	assertCode(t, err, codes.Unauthenticated)
+	_, err = client.UpdateUser(withBasicAuth(context.Background(), testAdminEmail, "nope"), update)
+	assertCode(t, err, codes.Unauthenticated)
+	_, err = client.UpdateUser(withBasicAuth(context.Background(), "member@example.com", "member-password"), update)
+	assertCode(t, err, codes.PermissionDenied)
+
+	admin := withBasicAuth(context.Background(), testAdminEmail, testAdminPassword)
+	stale := &greeterv1.UpdateUserRequest{Email: "jhon@bar.com", NewEmail: "smith@bar.com", Version: 7}
+	_, err = client.UpdateUser(admin, stale)
+	assertCode(t, err, codes.FailedPrecondition)
+
+	user, err := client.UpdateUser(admin, update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.GetEmail() != "smith@bar.com" || user.GetVersion() != 2 {
+		t.Errorf("bad user: %v", user)
+	}
+
+	_, err = client.DeleteUser(context.Background(), &greeterv1.DeleteUserRequest{Email: "smith@bar.com"})
+	assertCode(t, err, codes.Unauthenticated)
+	if _, err := client.DeleteUser(admin, &greeterv1.DeleteUserRequest{Email: "smith@bar.com"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetUser(context.Background(), &greeterv1.GetUserRequest{Email: "smith@bar.com"})
+	assertCode(t, err, codes.NotFound)
+}
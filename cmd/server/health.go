@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/worker"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+type healthResponse struct {
+	Status string `json:"status"`
+	// Workers details the background tasks; a failing one does not make
+	// the server unhealthy.
+	Workers []worker.Status `json:"workers,omitempty"`
+}
+
+type versionResponse struct {
+	Version string `json:"version"`
+	Go      string `json:"go"`
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	httpx.WriteJSON(w, http.StatusOK, healthResponse{Status: "ok", Workers: s.workers.Status()})
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	httpx.WriteJSON(w, http.StatusOK, versionResponse{Version: version, Go: runtime.Version()})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/config"
+)
+
+const (
+	// healthcheckTimeout bounds the whole check, retries included, so it
+	// finishes well inside a container HEALTHCHECK timeout.
+	healthcheckTimeout = 3 * time.Second
+
+	// healthcheckAttempts probes are made, healthcheckBackoff apart, before
+	// the server is reported unhealthy.
+	healthcheckAttempts = 3
+	healthcheckBackoff  = 250 * time.Millisecond
+)
+
+// runHealthcheck is the healthcheck subcommand, for container images
+// without curl. It reads the same flags, file and environment as run, GETs
+// /health on the public address and returns nil if the server reports ok.
+func runHealthcheck(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := flag.NewFlagSet("server healthcheck", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg, err := config.Load(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	target, err := healthcheckURL(cfg)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	client := &http.Client{}
+	if cfg.TLSCert != "" || cfg.TLSSelfSigned {
+		// The probe dials the server on its own host, usually loopback, where
+		// the certificate's names would not match anyway.
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthcheckTimeout)
+	defer cancel()
+	if err := probeHealth(ctx, client, target); err != nil {
+		return fmt.Errorf("unhealthy: %w", err)
+	}
+	fmt.Fprintln(stdout, "ok")
+	return nil
+}
+
+// healthcheckURL returns the /health URL of the public address. A server
+// listening on every interface is probed on loopback.
+func healthcheckURL(cfg *config.Config) (string, error) {
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return "", fmt.Errorf("addr: %w", err)
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+
+	scheme := "http"
+	if cfg.TLSCert != "" || cfg.TLSSelfSigned {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port) + "/health", nil
+}
+
+// probeHealth GETs target until it answers 200 with status ok, making at
+// most healthcheckAttempts attempts. It returns the last failure.
+func probeHealth(ctx context.Context, client *http.Client, target string) error {
+	var err error
+	for attempt := range healthcheckAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %w)", ctx.Err(), err)
+			case <-time.After(healthcheckBackoff):
+			}
+		}
+		if err = probeOnce(ctx, client, target); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func probeOnce(ctx context.Context, client *http.Client, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", target, resp.Status)
+	}
+	var health healthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return fmt.Errorf("GET %s: error decoding response: %w", target, err)
+	}
+	if health.Status != "ok" {
+		return fmt.Errorf("GET %s: status %q", target, health.Status)
+	}
+	return nil
+}
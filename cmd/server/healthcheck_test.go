@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/config"
+)
+
+func TestHealthcheck(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	var probes atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(int(status.Load()))
+		io.WriteString(w, `{"status":"ok"}`)
+	}))
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "http://")
+
+	healthcheck := func() (int, string) {
+		var stderr strings.Builder
+		err := run(context.Background(), []string{"healthcheck", "-addr", addr}, io.Discard, &stderr)
+		if err == nil {
+			return 0, ""
+		}
+		return exitCode(err), err.Error()
+	}
+
+	if code, msg := healthcheck(); code != 0 {
+		t.Errorf("healthy server: expected exit 0, got %d: %s", code, msg)
+	}
+
+	status.Store(http.StatusServiceUnavailable)
+	probes.Store(0)
+	code, msg := healthcheck()
+	if code != 1 || !strings.Contains(msg, "503") {
+		t.Errorf("unhealthy server: expected exit 1 naming the status, got %d: %s", code, msg)
+	}
+	if n := probes.Load(); n != healthcheckAttempts {
+		t.Errorf("expected %d attempts, got %d", healthcheckAttempts, n)
+	}
+
+	ts.Close()
+	if code, _ := healthcheck(); code != 1 {
+		t.Errorf("stopped server: expected exit 1, got %d", code)
+	}
+
+	if err := run(context.Background(), []string{"healthcheck", "-addr", "nope"}, io.Discard, io.Discard); exitCode(err) != 2 {
+		t.Errorf("bad addr: expected exit 2, got %v", err)
+	}
+}
+
+func TestHealthcheckRetries(t *testing.T) {
+	var probes atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probes.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"status":"ok"}`)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := probeHealth(ctx, ts.Client(), ts.URL+"/health"); err != nil {
+		t.Errorf("expected the retry to succeed: %v", err)
+	}
+}
+
+func TestHealthcheckURL(t *testing.T) {
+	tests := []struct {
+		addr       string
+		selfSigned bool
+		want       string
+	}{
+		{":4000", false, "http://127.0.0.1:4000/health"},
+		{"0.0.0.0:4000", false, "http://127.0.0.1:4000/health"},
+		{"[::]:4000", false, "http://127.0.0.1:4000/health"},
+		{"10.0.0.5:8080", false, "http://10.0.0.5:8080/health"},
+		{"[::1]:8443", true, "https://[::1]:8443/health"},
+	}
+	for _, tt := range tests {
+		cfg := config.Default()
+		cfg.Addr = tt.addr
+		cfg.TLSSelfSigned = tt.selfSigned
+		if got, err := healthcheckURL(&cfg); err != nil || got != tt.want {
+			t.Errorf("%s: expected %s, got %s, %v", tt.addr, tt.want, got, err)
+		}
+	}
+}
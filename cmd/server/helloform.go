@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// maxHelloFormBytes caps the body of POST /hello.
+const maxHelloFormBytes = 64 << 10
+
+// helloFormField is the form field naming the user to greet.
+const helloFormField = "user"
+
+// handleHelloForm greets the user posted by an HTML form, urlencoded or
+// multipart. The name goes through the same validation as POST /json, and
+// a form without the field is answered as greetMissing does. The
+// reply is JSON when Accept ranks application/json above text/plain, and
+// plain text otherwise.
+func (s *Server) handleHelloForm(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxHelloFormBytes)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var err error
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		err = r.ParseForm()
+	case "multipart/form-data":
+		err = r.ParseMultipartForm(maxHelloFormBytes)
+	default:
+		w.Header().Set("Accept-Post", "application/x-www-form-urlencoded, multipart/form-data")
+		httpx.WriteError(w, http.StatusUnsupportedMediaType, codeUnsupportedMedia, "Content-Type must be application/x-www-form-urlencoded or multipart/form-data")
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		httpx.WriteError(w, http.StatusRequestEntityTooLarge, codeTooLarge, "form must not exceed "+strconv.Itoa(maxHelloFormBytes)+" bytes")
+		return
+	case err != nil:
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "error parsing form")
+		return
+	}
+
+	v := apiV0
+	if accept := r.Header.Get("Accept"); acceptQuality(accept, "application/json") > acceptQuality(accept, "text/plain") {
+		v = apiV1
+	}
+
+	values, ok := r.PostForm[helloFormField]
+	if !ok {
+		s.greetMissing(w, r, v)
+		return
+	}
+	name := values[0]
+	if err := (CreateUserRequest{FirstName: name}).Validate(); err != nil {
+		var verrs users.ValidationErrors
+		errors.As(err, &verrs)
+		for i := range verrs {
+			verrs[i].Field = helloFormField
+		}
+		httpx.WriteErrorBody(w, http.StatusBadRequest, httpx.Error{
+			Code:    codeInvalidRequest,
+			Message: "invalid user field",
+			Fields:  verrs,
+		})
+		return
+	}
+
+	s.recordGreetingByName(name)
+	s.greet(w, r, v, name)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func postHelloForm(t *testing.T, handler http.Handler, contentType string, body string, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/hello", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func multipartForm(t *testing.T, fields map[string]string) (string, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatalf("error writing field: %v", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("error closing multipart writer: %v", err)
+	}
+	return mw.FormDataContentType(), buf.String()
+}
+
+func TestHelloFormURLEncoded(t *testing.T) {
+	handler := newTestServer(t).Routes()
+	body := url.Values{"user": {"alice"}}.Encode()
+
+	w := postHelloForm(t, handler, "application/x-www-form-urlencoded", body, "")
+	if w.Code != http.StatusOK || w.Body.String() != "Hello alice!\n" {
+		t.Errorf("bad text response: %d %q", w.Code, w.Body.String())
+	}
+
+	w = postHelloForm(t, handler, "application/x-www-form-urlencoded", body, "application/json")
+	var got struct{ Greeting, Language string }
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Greeting != "Hello alice!" || got.Language != "en" {
+		t.Errorf("bad JSON response: %d %s", w.Code, w.Body.String())
+	}
+
+	w = postHelloForm(t, handler, "application/x-www-form-urlencoded", body, "application/json;q=0.5, text/plain")
+	if w.Body.String() != "Hello alice!\n" {
+		t.Errorf("text/plain preferred but got %q", w.Body.String())
+	}
+}
+
+func TestHelloFormMultipart(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	contentType, body := multipartForm(t, map[string]string{"user": "bob"})
+	w := postHelloForm(t, handler, contentType, body, "")
+	if w.Code != http.StatusOK || w.Body.String() != "Hello bob!\n" {
+		t.Errorf("bad response: %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestHelloFormErrors(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		message     string
+	}{
+		{"empty field", "application/x-www-form-urlencoded", "user=+", http.StatusBadRequest, codeInvalidRequest, "invalid user field"},
+		{"over-long field", "application/x-www-form-urlencoded", "user=" + strings.Repeat("a", maxNameLength+1), http.StatusBadRequest, codeInvalidRequest, "invalid user field"},
+		{"malformed", "application/x-www-form-urlencoded", "user=%zz", http.StatusBadRequest, codeInvalidRequest, "error parsing form"},
+		{"malformed multipart", "multipart/form-data; boundary=x", "garbage", http.StatusBadRequest, codeInvalidRequest, "error parsing form"},
+		{"too large", "application/x-www-form-urlencoded", "user=" + strings.Repeat("a", maxHelloFormBytes), http.StatusRequestEntityTooLarge, codeTooLarge, ""},
+		{"json", "application/json", `{"user":"alice"}`, http.StatusUnsupportedMediaType, codeUnsupportedMedia, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postHelloForm(t, handler, tt.contentType, tt.body, "")
+			if w.Code != tt.status {
+				t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", tt.status, w.Code, w.Body.String())
+			}
+			assertErrorCode(t, w, tt.code, tt.message)
+		})
+	}
+
+	w := postHelloForm(t, handler, "application/x-www-form-urlencoded", "user="+strings.Repeat("a", maxNameLength+1), "")
+	if !strings.Contains(w.Body.String(), `"field":"user"`) {
+		t.Errorf("field errors do not name the user field: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+type greetingHistoryResponse struct {
+	Email         string     `json:"email"`
+	GreetCount    int        `json:"greet_count"`
+	LastGreetedAt *time.Time `json:"last_greeted_at,omitempty"`
+}
+
+// recordGreeting adds a greeting to the history of the stored user with the
+// given name. Greeting a name that is not a user's is not an error.
+func (s *Server) recordGreeting(first string, last string) {
+	if err := s.users.RecordGreeting(first, last); err != nil {
+		s.logger.LogAttrs(context.Background(), slog.LevelDebug, "greeting not recorded",
+			slog.String("first", first), slog.String("last", last), slog.Any("err", err))
+	}
+}
+
+// recordGreetingByName is recordGreeting for the single name the hello
+// variants take. The name splits at its last space, so "Mary Ann Smith" is
+// Mary Ann, Smith; a name without a space never matches a user.
+func (s *Server) recordGreetingByName(name string) {
+	name = strings.TrimSpace(name)
+	i := strings.LastIndexByte(name, ' ')
+	if i < 0 {
+		return
+	}
+	s.recordGreeting(strings.TrimSpace(name[:i]), name[i+1:])
+}
+
+// handleGreetingHistory reports how often the user was greeted and when last.
+func (s *Server) handleGreetingHistory(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	user, err := s.store.GetUserByEmail(r.Context(), r.PathValue("email"))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	resp := greetingHistoryResponse{Email: user.Email.Address, GreetCount: user.GreetCount}
+	if !user.LastGreetedAt.IsZero() {
+		resp.LastGreetedAt = &user.LastGreetedAt
+	}
+	httpx.WriteJSON(w, http.StatusOK, resp)
+}
+
+type inactiveUsersResponse struct {
+	Since time.Time              `json:"since"`
+	Users jsonList[UserResponse] `json:"users"`
+	Count int                    `json:"count"`
+}
+
+// parseSince parses an RFC 3339 time, or a date alone, which stands for
+// midnight UTC at its start.
+func parseSince(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
+
+// handleInactiveUsers lists the users not greeted since ?since=, which is
+// required. Users never greeted are included.
+func (s *Server) handleInactiveUsers(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	v := r.URL.Query().Get("since")
+	if v == "" {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "since is required")
+		return
+	}
+	since, err := parseSince(v)
+	if err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "since must be an RFC 3339 time or a YYYY-MM-DD date")
+		return
+	}
+
+	resp := inactiveUsersResponse{Since: since}
+	for _, u := range s.users.GetInactiveSince(since) {
+		resp.Users = append(resp.Users, newUserResponse(u))
+	}
+	resp.Count = len(resp.Users)
+	httpx.WriteJSON(w, http.StatusOK, resp)
+}
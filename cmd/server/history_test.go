@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestGreetingHistory(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	manager := users.NewManager(users.WithClock(func() time.Time { return now }))
+	s := NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), manager)
+	h := s.Routes()
+	manager.AddUser(context.Background(), "Mary Ann", "Smith", "mary@example.com")
+
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, target := range []string{"/hello/?user=Mary+Ann+Smith", "/api/v1/hello/Mary%20Ann%20Smith", "/hello/?user=Mary"} {
+		if w := serve(http.MethodGet, target, ""); w.Code != http.StatusOK {
+			t.Fatalf("%s: bad response code: expected %d, got %d\nbody: %s\n", target, http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+	now = now.Add(time.Hour)
+	if w := serve(http.MethodPost, "/json", `{"FirstName": "Mary Ann", "LastName": "Smith"}`); w.Code != http.StatusOK {
+		t.Fatalf("/json: bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	for _, target := range []string{"/users/mary@example.com/greetings", "/api/v1/users/mary@example.com/greetings"} {
+		w := serve(http.MethodGet, target, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: bad response code: expected %d, got %d\nbody: %s\n", target, http.StatusOK, w.Code, w.Body.String())
+		}
+		var got greetingHistoryResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.GreetCount != 3 || got.LastGreetedAt == nil || !got.LastGreetedAt.Equal(now) {
+			t.Errorf("%s: bad history: %s", target, w.Body.String())
+		}
+	}
+}
+
+func TestGreetingHistoryNeverGreeted(t *testing.T) {
+	s := newTestServer(t)
+	s.users.AddUser(context.Background(), "alice", "smith", "alice@example.com")
+
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/alice@example.com/greetings", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+	}
+	if expected := `{"email":"alice@example.com","greet_count":0}`; strings.TrimSpace(w.Body.String()) != expected {
+		t.Errorf("bad body: expected %s, got %s", expected, w.Body.String())
+	}
+}
+
+func TestGreetingHistoryUnknownUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestServer(t).Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/nobody@example.com/greetings", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusNotFound, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeNotFound, "user not found")
+}
+
+func TestInactiveUsers(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	manager := users.NewManager(users.WithClock(func() time.Time { return now }))
+	s := NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), manager)
+	h := s.Routes()
+	ctx := context.Background()
+	manager.AddUser(ctx, "Mary", "Smith", "mary@example.com")
+	manager.AddUser(ctx, "John", "Doe", "john@example.com")
+	if err := manager.RecordGreeting("Mary", "Smith"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		since string
+		code  int
+		want  int
+	}{
+		// Mary was greeted at noon on March 1: the date alone is midnight
+		// before that, and John was never greeted.
+		{"2024-03-01", http.StatusOK, 1},
+		{"2024-03-02", http.StatusOK, 2},
+		{"2024-03-01T12:00:00Z", http.StatusOK, 1},
+		{"2024-03-01T13:00:00%2B02:00", http.StatusOK, 1},
+		{"2024-03-01T12:00:01Z", http.StatusOK, 2},
+		{"", http.StatusBadRequest, 0},
+		{"yesterday", http.StatusBadRequest, 0},
+		{"2024-13-01", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		for _, prefix := range []string{"", "/api/v1"} {
+			target := prefix + "/users/inactive?since=" + tt.since
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+			if w.Code != tt.code {
+				t.Fatalf("%s: bad response code: expected %d, got %d\nbody: %s", target, tt.code, w.Code, w.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				assertErrorCode(t, w, codeInvalidRequest, "")
+				continue
+			}
+			var resp struct {
+				Users []UserResponse `json:"users"`
+				Count int            `json:"count"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Count != tt.want || len(resp.Users) != tt.want {
+				t.Errorf("%s: expected %d users, got %+v", target, tt.want, resp)
+			}
+		}
+	}
+}
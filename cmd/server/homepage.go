@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+// pageTemplates is parsed when the package is initialized, so a broken
+// template stops the server from starting instead of failing requests.
+var pageTemplates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// staticFiles serves the embedded assets under /static/.
+func staticFiles() http.Handler {
+	sub, err := fs.Sub(staticFS, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/static/", http.FileServerFS(sub))
+}
+
+type endpoint struct {
+	Method  string
+	Path    string
+	Summary string
+}
+
+// endpointsOf lists the operations in doc by path, then in the order the
+// Allow header uses.
+func endpointsOf(doc *openAPIDoc) []endpoint {
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var result []endpoint
+	for _, path := range paths {
+		for _, method := range probeMethods {
+			if op, ok := doc.Paths[path][strings.ToLower(method)]; ok {
+				result = append(result, endpoint{Method: method, Path: path, Summary: op.Summary})
+			}
+		}
+	}
+	return result
+}
+
+type homePage struct {
+	Endpoints []endpoint
+	Name      string
+	Greeting  template.HTML
+	Error     string
+}
+
+// handleRoot renders the landing page. A POSTed name is greeted on the
+// page, rendered as HTML with the name escaped. Clients whose Accept header
+// prefers text/plain get the original one-line welcome instead, or the
+// plain-text greeting for a POSTed name.
+func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	if prefersPlainText(r.Header.Get("Accept")) {
+		s.handleRootText(w, r)
+		return
+	}
+
+	page := homePage{Endpoints: s.endpoints}
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		page.Name = r.PostFormValue("name")
+		if err := s.checkUsername(page.Name); err != nil {
+			page.Error = err.Error()
+			status = http.StatusBadRequest
+		} else {
+			res, err := s.renderGreetingHTML(w, r, strings.TrimSpace(page.Name))
+			if err != nil {
+				s.logger.Error("error rendering greeting", "err", err)
+				httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "error rendering greeting")
+				return
+			}
+			page.Greeting = res.Greeting
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pageTemplates.ExecuteTemplate(&buf, "index.html", page); err != nil {
+		s.logger.Error("error rendering homepage", "err", err)
+		httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "error rendering homepage")
+		return
+	}
+
+	httpx.Write(w, status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// handleRootText is handleRoot for clients that prefer text/plain.
+func (s *Server) handleRootText(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpx.WriteText(w, http.StatusOK, "Welcome to our HomePage!\n")
+		return
+	}
+
+	name := r.PostFormValue("name")
+	if err := s.checkUsername(name); err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	res, err := s.renderGreeting(w, r, strings.TrimSpace(name))
+	if err != nil {
+		s.logger.Error("error rendering greeting", "err", err)
+		httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "error rendering greeting")
+		return
+	}
+	w.Header().Set("Content-Language", res.Language)
+	httpx.WriteText(w, http.StatusOK, res.Greeting+"\n")
+}
+
+// prefersPlainText reports whether an Accept header ranks text/plain above
+// text/html. A missing header accepts anything, so HTML wins.
+func prefersPlainText(accept string) bool {
+	return acceptQuality(accept, "text/plain") > acceptQuality(accept, "text/html")
+}
+
+// acceptQuality returns the q value an Accept header gives mediaType, taken
+// from its most specific matching range.
+func acceptQuality(accept string, mediaType string) float64 {
+	if strings.TrimSpace(accept) == "" {
+		return 1
+	}
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	best, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		rng, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		rng = strings.ToLower(strings.TrimSpace(rng))
+
+		var s int
+		switch rng {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s < specificity {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		best, specificity = q, s
+	}
+	return best
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+)
+
+func TestHomepage(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("bad content type: %q", ct)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"<td>GET</td><td><code>/health</code></td>",
+		"<td>POST</td><td><code>/users</code></td>",
+		"<td>DELETE</td><td><code>/api/v1/users/{email}</code></td>",
+		`<form method="post" action="/">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("homepage is missing %q", want)
+		}
+	}
+}
+
+func TestHomepageGreetForm(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	post := func(name string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"name": {name}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post("<alice>")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<p class="greeting">Hello &lt;alice&gt;!</p>`) {
+		t.Errorf("bad greeting: %d\nbody: %s\n", w.Code, w.Body.String())
+	}
+
+	w = post("  ")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `<p class="error">`) {
+		t.Errorf("bad response to empty name: %d\nbody: %s\n", w.Code, w.Body.String())
+	}
+}
+
+func TestHomepagePlainText(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	tests := []struct {
+		accept string
+		plain  bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/html", false},
+		{"text/plain", true},
+		{"text/plain, text/html;q=0.5", true},
+		{"text/*, text/html", false},
+		{"text/html;q=0.1, */*", true},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", tc.accept)
+		handler.ServeHTTP(w, r)
+
+		if got := w.Body.String() == "Welcome to our HomePage!\n"; got != tc.plain {
+			t.Errorf("Accept %q: expected plain text %v, got %q", tc.accept, tc.plain, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestStaticAssets(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/style.css", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/css; charset=utf-8" {
+		t.Errorf("bad content type: %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), ".endpoints") {
+		t.Errorf("unexpected stylesheet: %q", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/missing.css", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("bad response code for missing asset: expected %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestHomepageDualRendering(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	post := func(name string, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"name": {name}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", accept)
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post("<b>bob</b>", "text/html")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<p class="greeting">Hello &lt;b&gt;bob&lt;/b&gt;`) {
+		t.Errorf("bad HTML greeting: %d\nbody: %s\n", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "<b>bob") {
+		t.Error("name not escaped in HTML")
+	}
+
+	w = post("<b>bob</b>", "text/plain")
+	if w.Code != http.StatusOK || w.Body.String() != "Hello <b>bob</b>!\n" {
+		t.Errorf("bad text greeting: %d %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("bad Content-Type for text greeting: %q", ct)
+	}
+
+	w = post(" ", "text/plain")
+	assertErrorCode(t, w, codeInvalidRequest, "")
+
+	w = post("bob\x07", "text/html")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "must not contain control characters") {
+		t.Errorf("name with a control character accepted: %d\nbody: %s\n", w.Code, w.Body.String())
+	}
+}
+
+func TestHomepageGreetingTemplateError(t *testing.T) {
+	s := newTestServer(t)
+	g, err := s.newGreeter(greeting.WithTemplate(`Hello {{.Name}}{{if eq .Name "boom"}}{{index .Name 99}}{{end}}!`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.greeter = g
+	handler := s.Routes()
+
+	for _, accept := range []string{"text/html", "text/plain"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"name": {"boom"}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", accept)
+		handler.ServeHTTP(w, r)
+
+		assertErrorCode(t, w, codeInternal, "error rendering greeting")
+		if strings.Contains(w.Body.String(), "Hello") {
+			t.Errorf("Accept %s: partial greeting written: %q", accept, w.Body.String())
+		}
+	}
+}
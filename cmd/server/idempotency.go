@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+const (
+	// idempotencyKeyHeader lets clients retry a POST safely: every request
+	// carrying the same key gets the response of the first one.
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotencyReplayHeader marks a response replayed from the cache.
+	idempotencyReplayHeader = "Idempotent-Replayed"
+
+	// defaultIdempotencyTTL is how long a response is kept for replay.
+	defaultIdempotencyTTL = 24 * time.Hour
+
+	// maxIdempotentBody caps the bodies read for fingerprinting.
+	maxIdempotentBody = 1 << 20
+
+	// maxIdempotencyKey caps the length of an Idempotency-Key.
+	maxIdempotencyKey = 255
+
+	// idempotencySweepInterval is how often expired responses are dropped.
+	idempotencySweepInterval = time.Minute
+)
+
+// idempotentResponse is the outcome of the first request with a key. done is
+// closed once status, header and body are set, so concurrent retries wait
+// for the first request rather than run the handler again.
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	expires     time.Time
+
+	status int
+	header http.Header
+	body   []byte
+}
+
+// idempotencyCache maps idempotency keys to responses. Expired entries are
+// ignored on lookup and dropped by sweep, which a background worker runs.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]*idempotentResponse
+}
+
+func newIdempotencyCache(ttl time.Duration, now func() time.Time) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:     ttl,
+		now:     now,
+		entries: make(map[string]*idempotentResponse),
+	}
+}
+
+// claim returns the entry for key. owner is true when the caller created the
+// entry and must run the request and then call finish or abandon.
+func (c *idempotencyCache) claim(key string, fingerprint [sha256.Size]byte) (entry *idempotentResponse, owner bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if e, ok := c.entries[key]; ok && !c.expired(e, now) {
+		return e, false
+	}
+
+	e := &idempotentResponse{fingerprint: fingerprint, done: make(chan struct{})}
+	c.entries[key] = e
+	return e, true
+}
+
+// expired reports whether a finished entry has outlived the TTL. Entries
+// still in flight never expire.
+func (c *idempotencyCache) expired(e *idempotentResponse, now time.Time) bool {
+	select {
+	case <-e.done:
+		return !now.Before(e.expires)
+	default:
+		return false
+	}
+}
+
+// sweep drops the expired entries.
+func (c *idempotencyCache) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, e := range c.entries {
+		if c.expired(e, now) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// sweepIdempotency is the worker task that sweeps the idempotency cache.
+func (s *Server) sweepIdempotency(context.Context) error {
+	s.idempotency.sweep()
+	return nil
+}
+
+// finish stores the response for e and releases waiting retries.
+func (c *idempotencyCache) finish(e *idempotentResponse, status int, header http.Header, body []byte) {
+	c.mu.Lock()
+	e.status, e.header, e.body = status, header, body
+	e.expires = c.now().Add(c.ttl)
+	c.mu.Unlock()
+
+	close(e.done)
+}
+
+// abandon forgets key without storing a response, so the next retry runs the
+// handler again. Waiting retries see a zero status and do the same.
+func (c *idempotencyCache) abandon(key string, e *idempotentResponse) {
+	c.mu.Lock()
+	if c.entries[key] == e {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	close(e.done)
+}
+
+// len reports the number of keys held, including expired ones not yet swept.
+func (c *idempotencyCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+		rw.header = rw.ResponseWriter.Header().Clone()
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
+
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// withIdempotency replays the stored response to requests that repeat an
+// Idempotency-Key with the same body, and rejects a reused key with a
+// different body with 422. Retries that arrive while the first request is
+// still running wait for it. Requests without the header pass straight
+// through, and 5xx responses are not stored so the client can retry.
+func (s *Server) withIdempotency(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			h(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+		if err != nil {
+			httpx.WriteError(w, http.StatusRequestEntityTooLarge, codeTooLarge, "request body too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped to the route, so one key cannot replay a
+		// response from a different endpoint.
+		key = r.Method + " " + r.URL.Path + " " + key
+		fingerprint := sha256.Sum256(body)
+
+		for {
+			entry, owner := s.idempotency.claim(key, fingerprint)
+			if entry.fingerprint != fingerprint {
+				httpx.WriteError(w, http.StatusUnprocessableEntity, codeIdempotencyMismatch, "Idempotency-Key was already used with a different request body")
+				return
+			}
+			if owner {
+				s.runIdempotent(w, r, h, key, entry)
+				return
+			}
+
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				return
+			}
+			if entry.status == 0 {
+				// The first request failed; try to become the owner.
+				continue
+			}
+
+			for name, values := range entry.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(idempotencyReplayHeader, "true")
+			w.WriteHeader(entry.status)
+			if _, err := w.Write(entry.body); err != nil {
+				httpx.LogWriteError(w, "error replaying idempotent response", err)
+			}
+			return
+		}
+	}
+}
+
+func (s *Server) runIdempotent(w http.ResponseWriter, r *http.Request, h http.HandlerFunc, key string, entry *idempotentResponse) {
+	rw := &recordingWriter{ResponseWriter: w}
+	stored := false
+	defer func() {
+		if !stored {
+			s.idempotency.abandon(key, entry)
+		}
+	}()
+
+	h(rw, r)
+
+	if rw.status == 0 || rw.status >= http.StatusInternalServerError {
+		return
+	}
+	s.idempotency.finish(entry, rw.status, rw.header, rw.body.Bytes())
+	stored = true
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+func postUser(t *testing.T, handler http.Handler, key string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		r.Header.Set(idempotencyKeyHeader, key)
+	}
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func assertStatusCode(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+
+	if w.Code != status {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n", status, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, code, "")
+}
+
+const adaBody = `{"FirstName":"ada","LastName":"lovelace","Email":"ada@bar.com"}`
+
+func TestIdempotencyReplay(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	first := postUser(t, handler, "key-1", adaBody)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusCreated, first.Code, first.Body.String())
+	}
+
+	retry := postUser(t, handler, "key-1", adaBody)
+	if retry.Code != http.StatusCreated {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusCreated, retry.Code, retry.Body.String())
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("bad replay body: expected %q, got %q", first.Body.String(), retry.Body.String())
+	}
+	if retry.Header().Get("Location") != first.Header().Get("Location") {
+		t.Errorf("bad replay Location: expected %q, got %q", first.Header().Get("Location"), retry.Header().Get("Location"))
+	}
+	if retry.Header().Get(idempotencyReplayHeader) != "true" {
+		t.Errorf("expected %s on the replay", idempotencyReplayHeader)
+	}
+
+	// Without a key the same body is an ordinary duplicate.
+	w := postUser(t, handler, "", adaBody)
+	assertStatusCode(t, w, http.StatusConflict, codeConflict)
+}
+
+func TestIdempotencyConflictingBody(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	postUser(t, handler, "key-1", adaBody)
+	w := postUser(t, handler, "key-1", `{"FirstName":"grace","LastName":"hopper","Email":"grace@bar.com"}`)
+	assertStatusCode(t, w, http.StatusUnprocessableEntity, codeIdempotencyMismatch)
+}
+
+func TestIdempotencyExpiry(t *testing.T) {
+	s := newTestServer(t)
+	now := time.Date(2024, time.March, 1, 8, 30, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	handler := s.Routes()
+
+	postUser(t, handler, "key-1", adaBody)
+
+	now = now.Add(defaultIdempotencyTTL)
+	w := postUser(t, handler, "key-1", adaBody)
+	assertStatusCode(t, w, http.StatusConflict, codeConflict)
+
+	postUser(t, handler, "key-2", `{"FirstName":"grace","LastName":"hopper","Email":"grace@bar.com"}`)
+	if n := s.idempotency.len(); n != 2 {
+		t.Errorf("bad cache size: expected 2, got %d", n)
+	}
+	now = now.Add(defaultIdempotencyTTL)
+	postUser(t, handler, "key-3", `{"FirstName":"alan","LastName":"turing","Email":"alan@bar.com"}`)
+	if n := s.idempotency.len(); n != 3 {
+		t.Errorf("bad cache size before sweep: expected 3, got %d", n)
+	}
+	s.sweepIdempotency(context.Background())
+	if n := s.idempotency.len(); n != 1 {
+		t.Errorf("expired keys not swept: expected 1 entry, got %d", n)
+	}
+}
+
+func TestIdempotencyConcurrentDuplicates(t *testing.T) {
+	s := newTestServer(t)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	handler := s.withIdempotency(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created\n"))
+	})
+
+	const retries = 8
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, retries)
+	for i := range retries {
+		wg.Go(func() {
+			responses[i] = postUser(t, handler, "key-1", adaBody)
+		})
+	}
+
+	// Let every retry reach the cache before the first one finishes.
+	for s.idempotency.len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times, expected once", n)
+	}
+	for i, w := range responses {
+		if w.Code != http.StatusCreated || w.Body.String() != "created\n" {
+			t.Errorf("response %d: expected 201 created, got %d %q", i, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestIdempotencyServerErrorNotStored(t *testing.T) {
+	s := newTestServer(t)
+
+	var calls atomic.Int32
+	handler := s.withIdempotency(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "boom")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	postUser(t, handler, "key-1", adaBody)
+	w := postUser(t, handler, "key-1", adaBody)
+	if w.Code != http.StatusCreated {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusCreated, w.Code, w.Body.String())
+	}
+}
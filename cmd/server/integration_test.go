@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/fixtures"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// startTestServer serves the full middleware chain built by Routes on a real
+// listener, so routing, method matching and middleware are exercised.
+func startTestServer(t *testing.T, f *fixtures.Fixture) (*httptest.Server, *users.Manager) {
+	t.Helper()
+
+	return f.Serve(t, func(m *users.Manager) http.Handler {
+		return NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), m).Routes()
+	})
+}
+
+func doRequest(t *testing.T, client *http.Client, method string, url string, body string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error building request: %v", err)
+	}
+	if method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("error reading response body: %v", err)
+	}
+	return resp, string(data)
+}
+
+func TestIntegrationRootDoesNotMatchSubpaths(t *testing.T) {
+	srv, _ := startTestServer(t, &fixtures.Fixture{})
+
+	resp, body := doRequest(t, srv.Client(), http.MethodGet, srv.URL+"/", "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "Welcome to our HomePage!") {
+		t.Errorf("bad root response: %d %q", resp.StatusCode, body)
+	}
+
+	resp, body = doRequest(t, srv.Client(), http.MethodGet, srv.URL+"/some/random/path", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+			http.StatusNotFound, resp.StatusCode, body)
+	}
+
+	var errResp errorResponse
+	if err := json.Unmarshal([]byte(body), &errResp); err != nil || errResp.Error.Code != codeNotFound {
+		t.Errorf("bad not found body: %q", body)
+	}
+}
+
+func TestIntegrationPathValue(t *testing.T) {
+	srv, _ := startTestServer(t, &fixtures.Fixture{})
+
+	resp, body := doRequest(t, srv.Client(), http.MethodGet, srv.URL+"/responses/alice/hello/", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("bad response code: expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if body != "Hello alice!\n" {
+		t.Errorf("bad response body: expected %q, got %q", "Hello alice!\n", body)
+	}
+}
+
+func TestIntegrationJSON(t *testing.T) {
+	srv, _ := startTestServer(t, &fixtures.Fixture{})
+
+	resp, body := doRequest(t, srv.Client(), http.MethodPost, srv.URL+"/json", `{"FirstName":"alice"}`)
+	if resp.StatusCode != http.StatusOK || body != "Hello alice!\n" {
+		t.Errorf("bad response: %d %q", resp.StatusCode, body)
+	}
+
+	resp, body = doRequest(t, srv.Client(), http.MethodPost, srv.URL+"/json", "")
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, codeInvalidRequest) {
+		t.Errorf("bad empty body response: %d %q", resp.StatusCode, body)
+	}
+
+	resp, _ = doRequest(t, srv.Client(), http.MethodGet, srv.URL+"/json", "")
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("bad response code: expected %d, got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
+
+func TestIntegrationExport(t *testing.T) {
+	srv, _ := startTestServer(t, fixtures.MustLoad(t, "testdata/users.json"))
+
+	resp, body := doRequest(t, srv.Client(), http.MethodGet, srv.URL+"/users/export.csv", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if !strings.Contains(body, "jhon,smith,jhon@bar.com,2024-03-01T12:00:00Z") {
+		t.Errorf("export missing fixture user:\n%s", body)
+	}
+}
+
+func TestIntegrationSQLiteStore(t *testing.T) {
+	store, err := users.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	s := newTestServer(t)
+	s.store = store
+	srv := httptest.NewServer(s.Routes())
+	t.Cleanup(srv.Close)
+
+	resp, body := doRequest(t, srv.Client(), http.MethodPost, srv.URL+"/api/v1/users", `{"first_name":"ada","last_name":"lovelace","email":"ada@bar.com"}`)
+	var created UserResponse
+	if err := json.Unmarshal([]byte(body), &created); err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("bad create response: %d %s", resp.StatusCode, body)
+	}
+	if _, err := store.GetUserByEmail(t.Context(), "ada@bar.com"); err != nil {
+		t.Fatalf("user not in the SQLite store: %v", err)
+	}
+	if _, err := s.users.GetUserByEmail(t.Context(), "ada@bar.com"); err == nil {
+		t.Error("user also added to the in-memory manager")
+	}
+
+	for _, target := range []string{
+		"/api/v1/users",
+		"/api/v1/users/search?q=ada",
+		"/api/v1/users/by-id?id=" + created.ID,
+		"/users/export",
+		"/users/export.csv",
+	} {
+		resp, body := doRequest(t, srv.Client(), http.MethodGet, srv.URL+target, "")
+		if resp.StatusCode != http.StatusOK || !strings.Contains(body, "ada@bar.com") {
+			t.Errorf("GET %s: expected the user, got %d %s", target, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+const (
+	defaultMaxInFlight = 256
+	defaultLimitWait   = 100 * time.Millisecond
+
+	// shedRetryAfter is sent with every 503 from the limiter, in seconds.
+	shedRetryAfter = 1
+)
+
+// concurrencyLimiter caps the requests handled at once. A request that
+// cannot get a slot within wait is shed rather than queued, so latency
+// stays bounded under overload.
+type concurrencyLimiter struct {
+	slots chan struct{}
+	wait  time.Duration
+	shed  atomic.Uint64
+}
+
+// newConcurrencyLimiter returns a limiter allowing max requests at once, or
+// nil, which allows any number, when max is not positive.
+func newConcurrencyLimiter(max int, wait time.Duration) *concurrencyLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &concurrencyLimiter{slots: make(chan struct{}, max), wait: wait}
+}
+
+// acquire takes a slot, waiting at most l.wait. It fails early if ctx is
+// done first.
+func (l *concurrencyLimiter) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		l.shed.Add(1)
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	<-l.slots
+}
+
+// inFlight returns the requests currently holding a slot. A nil limiter
+// reports zero.
+func (l *concurrencyLimiter) inFlight() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.slots)
+}
+
+// shedCount returns the requests turned away since the server started.
+func (l *concurrencyLimiter) shedCount() uint64 {
+	if l == nil {
+		return 0
+	}
+	return l.shed.Load()
+}
+
+// limiterExempt lists paths served even when the limiter is full: probes
+// must keep passing during overload, and WebSockets would hold a slot for
+// the life of the connection.
+var limiterExempt = map[string]bool{
+	"/health": true,
+	"/ws":     true,
+}
+
+// withConcurrencyLimit sheds requests to h with a 503 and Retry-After once
+// s.limiter has no free slot. Requests whose client goes away while waiting
+// are dropped without a response.
+func (s *Server) withConcurrencyLimit(h http.Handler) http.Handler {
+	l := s.limiter
+	if l == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limiterExempt[r.URL.Path] {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if !l.acquire(r.Context()) {
+			if s.canceled(r, "wait for a request slot") {
+				return
+			}
+			s.logger.Warn("shedding request", "method", r.Method, "path", r.URL.Path, "inFlight", l.inFlight())
+			w.Header().Set("Retry-After", strconv.Itoa(shedRetryAfter))
+			httpx.WriteError(w, http.StatusServiceUnavailable, codeOverloaded, "server is overloaded, retry later")
+			return
+		}
+		defer l.release()
+
+		h.ServeHTTP(w, r)
+	})
+}
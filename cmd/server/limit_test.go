@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingHandler holds every request until release is closed, signalling
+// started as each one begins.
+func blockingHandler() (h http.Handler, started chan struct{}, release chan struct{}) {
+	started = make(chan struct{})
+	release = make(chan struct{})
+	h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return h, started, release
+}
+
+func TestConcurrencyLimitSheds(t *testing.T) {
+	s := newTestServer(t)
+	s.limiter = newConcurrencyLimiter(2, 20*time.Millisecond)
+	inner, started, release := blockingHandler()
+	handler := s.withConcurrencyLimit(inner)
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Go(func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello/", nil))
+		})
+		<-started
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusServiceUnavailable, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeOverloaded, "")
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("bad Retry-After: expected %q, got %q", "1", got)
+	}
+	if n := s.limiter.inFlight(); n != 2 {
+		t.Errorf("bad in-flight count: expected 2, got %d", n)
+	}
+	if n := s.limiter.shedCount(); n != 1 {
+		t.Errorf("bad shed count: expected 1, got %d", n)
+	}
+
+	close(release)
+	wg.Wait()
+	if n := s.limiter.inFlight(); n != 0 {
+		t.Errorf("slots not released: %d still in flight", n)
+	}
+
+	go func() { <-started }()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("bad response code after release: expected %d, got %d", http.StatusNoContent, w.Code)
+	}
+}
+
+func TestConcurrencyLimitWaitsForSlot(t *testing.T) {
+	s := newTestServer(t)
+	s.limiter = newConcurrencyLimiter(1, 5*time.Second)
+	inner, started, release := blockingHandler()
+	handler := s.withConcurrencyLimit(inner)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello/", nil))
+	}()
+	<-started
+
+	// The waiting request gets the slot as soon as the first finishes.
+	go func() { <-started }()
+	time.AfterFunc(20*time.Millisecond, func() { close(release) })
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	<-done
+}
+
+func TestConcurrencyLimitClientCancel(t *testing.T) {
+	s := newTestServer(t)
+	s.limiter = newConcurrencyLimiter(1, 5*time.Second)
+	inner, started, _ := blockingHandler()
+	handler := s.withConcurrencyLimit(inner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r := httptest.NewRequest(http.MethodGet, "/hello/", nil).WithContext(ctx)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+	<-started
+
+	// A client that gives up while waiting is dropped without a response.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, canceledRequest(http.MethodGet, "/hello/", ""))
+	if w.Body.Len() != 0 || w.Header().Get("Retry-After") != "" {
+		t.Errorf("expected no response to a canceled request, got %d %q", w.Code, w.Body.String())
+	}
+	if n := s.limiter.shedCount(); n != 0 {
+		t.Errorf("canceled request counted as shed: %d", n)
+	}
+
+	// A client that gives up while its handler runs frees the slot.
+	cancel()
+	<-done
+	if n := s.limiter.inFlight(); n != 0 {
+		t.Errorf("slot not released after cancel: %d still in flight", n)
+	}
+}
+
+func TestConcurrencyLimitExemptsHealth(t *testing.T) {
+	s := newTestServer(t)
+	s.limiter = newConcurrencyLimiter(1, time.Millisecond)
+	handler := s.Routes()
+
+	// Fill the only slot as a stuck request would.
+	s.limiter.slots <- struct{}{}
+	defer s.limiter.release()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("health probe shed: expected %d, got %d", http.StatusOK, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("bad response code: expected %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	stats := getStats(t, http.HandlerFunc(s.handleStats), "/stats")
+	if stats.InFlight != 1 || stats.Shed != 1 {
+		t.Errorf("bad stats: expected 1 in flight and 1 shed, got %d and %d", stats.InFlight, stats.Shed)
+	}
+}
+
+func TestConcurrencyLimitDisabled(t *testing.T) {
+	if l := newConcurrencyLimiter(0, time.Second); l != nil {
+		t.Fatal("expected no limiter for a zero cap")
+	}
+
+	s := newTestServer(t)
+	s.limiter = nil
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("bad response code: expected %d, got %d", http.StatusOK, w.Code)
+	}
+}
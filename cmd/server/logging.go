@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// newLogger builds the server's logger. format is "text" or "json"; level
+// is read on every record, so changing it takes effect immediately.
+func newLogger(w io.Writer, format string, level *slog.LevelVar) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q: must be text or json", format)
+}
+
+// parseLogLevel accepts debug, info, warn or error in any case.
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: must be debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+type logLevelResponse struct {
+	Level string `json:"level"`
+}
+
+// handleLogLevel reports the current log level, and on PUT sets it from
+// ?level= without a restart.
+func (s *Server) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		level, err := parseLogLevel(r.URL.Query().Get("level"))
+		if err != nil {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		previous := s.logLevel.Level()
+		s.logLevel.Set(level)
+		s.logger.Info("log level changed", "from", previous, "to", level)
+	}
+
+	httpx.WriteJSON(w, http.StatusOK, logLevelResponse{Level: strings.ToLower(s.logLevel.Level().String())})
+}
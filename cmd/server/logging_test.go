@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestNewLoggerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+
+	logger, err := newLogger(&buf, "text", level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "shown") {
+		t.Errorf("bad filtering at warn: %q", out)
+	}
+
+	buf.Reset()
+	level.Set(slog.LevelDebug)
+	logger.Debug("now shown")
+	if !strings.Contains(buf.String(), "now shown") {
+		t.Errorf("level change not applied: %q", buf.String())
+	}
+}
+
+func TestNewLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "json", new(slog.LevelVar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("request", "path", "/hello")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log line is not JSON: %v\n%s", err, buf.String())
+	}
+	for _, key := range []string{"time", "level", "msg", "path"} {
+		if _, ok := record[key]; !ok {
+			t.Errorf("missing key %q in %s", key, buf.String())
+		}
+	}
+
+	if _, err := newLogger(&buf, "xml", new(slog.LevelVar)); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected slog.Level
+		ok       bool
+	}{
+		{"debug", slog.LevelDebug, true},
+		{"INFO", slog.LevelInfo, true},
+		{"warn", slog.LevelWarn, true},
+		{"error", slog.LevelError, true},
+		{"loud", 0, false},
+		{"", 0, false},
+	}
+	for _, tc := range tests {
+		got, err := parseLogLevel(tc.in)
+		if (err == nil) != tc.ok || got != tc.expected {
+			t.Errorf("parseLogLevel(%q): expected %v (ok %v), got %v (%v)", tc.in, tc.expected, tc.ok, got, err)
+		}
+	}
+}
+
+func TestLogLevelEndpoint(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.AdminRoutes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/log-level?level=debug", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"level":"debug"`) {
+		t.Errorf("bad response: %d %s", w.Code, w.Body.String())
+	}
+	if s.logLevel.Level() != slog.LevelDebug {
+		t.Errorf("level not changed: %v", s.logLevel.Level())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/log-level?level=loud", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeInvalidRequest, "")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/log-level", nil))
+	if !strings.Contains(w.Body.String(), `"level":"debug"`) {
+		t.Errorf("bad level after rejected change: %s", w.Body.String())
+	}
+}
+
+func TestLogRequestRedactsHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewServer(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), users.NewManager())
+	r := httptest.NewRequest(http.MethodGet, "/hello/", nil)
+	r.Header.Set("Authorization", "Bearer topsecret")
+	r.Header.Set("Cookie", "session=abcdef")
+	r.Header.Set("User", "alice")
+	r.Header.Set("Accept-Language", "fr")
+	s.Routes().ServeHTTP(httptest.NewRecorder(), r)
+
+	out := buf.String()
+	for _, secret := range []string{"topsecret", "abcdef", "alice"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%q logged in plaintext:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{"Authorization:[Bearer to…(9)]", "Cookie:[se…(14)]", "User:[al…(5)]", "Accept-Language:[fr]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in the request log:\n%s", want, out)
+		}
+	}
+
+	// Headers are left out above Debug.
+	buf.Reset()
+	s.logLevel.Set(slog.LevelInfo)
+	s.logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: s.logLevel}))
+	s.logRequest(r)
+	if strings.Contains(buf.String(), "headers") {
+		t.Errorf("headers logged at Info:\n%s", buf.String())
+	}
+}
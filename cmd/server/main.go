@@ -1,145 +1,283 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
 	"log/slog"
-	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/config"
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+	"golang.org/x/text/language"
 )
 
-type UserData struct {
-	FirstName string
-	LastName  string
-	Email     string
-}
-
-func main() {
-	mux := http.NewServeMux()
+// errUsage marks errors caused by bad flags or configuration rather than a
+// failure to start or serve.
+var errUsage = errors.New("invalid configuration")
 
-	mux.HandleFunc("/{$}", handleRoot)
-	mux.HandleFunc("/goodbye", handleGoodbye)
-	mux.HandleFunc("/hello/", handleHelloParameterized)
-	mux.HandleFunc("/responses/{user}/hello/", handleUserResponsesHello)
-	mux.HandleFunc("/user/hello", handleHelloHeader)
-	mux.HandleFunc("POST /json", handleJSON)
+// errSQLiteIncomplete refuses -store=sqlite while parts of the API still
+// read and write the in-memory manager, which would leave them out of step
+// with the database.
+var errSQLiteIncomplete = errors.New("-store=sqlite is not supported yet: authentication, verification, greeting history, archives, signup and atomic batches still use the in-memory store")
 
-	fmt.Println("Listening on port 4000")
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
+	}
+}
 
-	log.Fatal(http.ListenAndServe(":4000", mux))
+// exitCode is 2 for usage errors and 1 for everything else.
+func exitCode(err error) int {
+	if errors.Is(err, errUsage) {
+		return 2
+	}
+	return 1
 }
 
-func handleRoot(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Requested path:", r.URL.Path)
+// run is the testable entrypoint. It parses args, listens on the configured
+// addresses and serves until ctx is done or a listener fails, then shuts
+// down. Logs go to stdout; flag errors and usage go to stderr. A first
+// argument of "healthcheck" probes a running server instead, "smoke" checks
+// every route of a throwaway one, -print-routes writes the route table to
+// stdout and returns, and -dry-run checks everything serving would load and
+// returns.
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	if len(args) > 0 && args[0] == "healthcheck" {
+		return runHealthcheck(ctx, args[1:], stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "smoke" {
+		return runSmoke(ctx, args[1:], stdout, stderr)
+	}
 
-	_, err := w.Write([]byte("Welcome to our HomePage!\n"))
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg, err := config.Load(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
 	if err != nil {
-		slog.Error("Error serving the health_handler err: " + err.Error())
-		return
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+	if cfg.DryRun {
+		return dryRun(cfg, stdout)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("%w:\n%w", errUsage, err)
+	}
+	if cfg.Store == "sqlite" {
+		return errSQLiteIncomplete
 	}
 
-}
-
-func handleGoodbye(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Requested path:", r.URL.Path)
-
-	_, err := w.Write([]byte("Goodbye world is served at goodbye\n"))
+	level, err := parseLogLevel(cfg.LogLevel)
 	if err != nil {
-		log.Fatal("Error serving the goodbye handler err: " + err.Error())
-		return
+		return fmt.Errorf("%w: %w", errUsage, err)
 	}
-}
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(level)
+	logger, err := newLogger(stdout, cfg.LogFormat, logLevel)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+	// httpx logs through the default logger on writers it is not tracking.
+	slog.SetDefault(logger)
 
-func handleHelloParameterized(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Requested Path: ", r.URL.Path)
+	storeMetrics := newStoreMetrics()
+	manager := users.NewManager(append(managerOptions(cfg), users.WithMetrics(storeMetrics))...)
 
-	params := r.URL.Query()
-	userlist := params["user"]
+	srv := newServerFromConfig(cfg, logger, manager)
+	srv.storeMetrics = storeMetrics
+	srv.logLevel = logLevel
+	if err := srv.loadGreeter(); err != nil {
+		return err
+	}
+	hup, stopHup := notifyHangup()
+	defer stopHup()
+	go srv.reloadOnHangup(hup)
 
-	username := "User"
-	if len(userlist) > 0 {
-		username = userlist[0]
+	// Building the route table up front reports conflicting routes as an
+	// error rather than a panic in HTTPServer.
+	routes, err := srv.routeTable()
+	if err != nil {
+		return err
+	}
+	if cfg.PrintRoutes {
+		return writeRouteTable(stdout, routes)
 	}
 
-	handleHello(w, username)
-}
+	if cfg.SnapshotPath != "" {
+		if err := restoreSnapshot(logger, manager, cfg.SnapshotPath); err != nil {
+			return err
+		}
+	}
+	if cfg.Store == "sqlite" {
+		store, err := users.OpenSQLite(cfg.DBPath)
+		if err != nil {
+			return fmt.Errorf("%s: %w", cfg.DBPath, err)
+		}
+		defer store.Close()
+		srv.store = store
+		logger.Info("opened user store", "store", cfg.Store, "path", cfg.DBPath)
+	}
 
-func handleUserResponsesHello(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Requested Path: ", r.URL.Path)
+	if cfg.AccessLog != "" {
+		accessLog, err := openAccessLog(cfg)
+		if err != nil {
+			return fmt.Errorf("error opening access log: %w", err)
+		}
+		defer accessLog.Close()
+		srv.accessLog = newAccessLogger(accessLog)
+
+		hup, stopHup := notifyHangup()
+		defer stopHup()
+		go reopenOnHangup(logger, hup, accessLog)
+	}
 
-	username := r.PathValue("user")
+	var tlsConfig *tls.Config
+	if cfg.TLSSelfSigned {
+		tlsConfig, err = selfSignedTLSConfig()
+		if err != nil {
+			return fmt.Errorf("error generating self-signed certificate: %w", err)
+		}
+	}
 
-	handleHello(w, username)
-}
+	public, err := listen("public", srv.HTTPServer(cfg.Addr), tlsConfig, cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return err
+	}
+	admin, err := listen("admin", srv.AdminHTTPServer(cfg.AdminAddr), nil, "", "")
+	if err != nil {
+		public.ln.Close()
+		return err
+	}
+	listeners := []listener{public, admin}
+	if cfg.GRPCAddr != "" {
+		rpc, err := listenGRPC("grpc", srv.GRPCServer(), cfg.GRPCAddr)
+		if err != nil {
+			public.ln.Close()
+			admin.ln.Close()
+			return err
+		}
+		listeners = append(listeners, rpc)
+	}
 
-func handleHelloHeader(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Requested Path: ", r.URL.Path)
-	//username := r.PathValue("user")
-	username := r.Header.Get("user")
-	if username == "" {
-		http.Error(w, "invalid username provided", http.StatusBadRequest)
-		return
+	if cfg.SnapshotPath != "" {
+		go srv.workers.Run(ctx, "autosave", cfg.SnapshotInterval.Duration, autosave(manager, cfg.SnapshotPath))
+	}
+	go srv.workers.Run(ctx, "idempotency-sweep", idempotencySweepInterval, srv.sweepIdempotency)
+
+	// NewServer's hooks have already stopped new writes and closed the
+	// WebSockets by the time these run.
+	srv.OnShutdown("stop listeners", cfg.ShutdownTimeout.Duration, stopListeners(listeners...))
+	if cfg.SnapshotPath != "" {
+		srv.OnShutdown("save snapshot", snapshotSaveTimeout, func(context.Context) error {
+			return manager.SaveSnapshotFile(cfg.SnapshotPath)
+		})
 	}
 
-	handleHello(w, username)
+	if err := serveAll(ctx, logger, srv.Shutdown, listeners...); err != nil {
+		return fmt.Errorf("server stopped: %w", err)
+	}
+	return nil
 }
 
-func handleHelloNoHeader(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Requested Path: ", r.URL.Path)
-	//username := r.PathValue("user")
-	username := r.Header.Get("user")
-	if username == "" {
-		http.Error(w, "invalid username provided", http.StatusBadRequest)
-		return
+// managerOptions returns the options of the user store cfg describes.
+func managerOptions(cfg *config.Config) []users.Option {
+	opts := []users.Option{
+		users.WithNameRules(users.NameRules{RejectDigits: cfg.NameRejectDigits}),
 	}
-
-	handleHello(w, username)
+	// Validate reports a bad tag; a dry run gets this far regardless.
+	if tag, err := language.Parse(cfg.Collation); cfg.Collation != "" && err == nil {
+		opts = append(opts, users.WithCollation(tag))
+	}
+	return opts
 }
 
-func handleJSON(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Requested Path: ", r.URL.Path)
-
-	byteData, err := io.ReadAll(r.Body)
-	if err != nil {
-		slog.Error("error reading request body", "err: ", err)
-		http.Error(w, "bad request body", http.StatusBadRequest)
-		return
+// restoreSnapshot loads the snapshot at path into manager. A missing file
+// is not an error: the store starts empty.
+func restoreSnapshot(logger *slog.Logger, manager *users.Manager, path string) error {
+	err := manager.LoadSnapshotFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		logger.Info("no snapshot to restore", "path", path)
+	case err != nil:
+		return fmt.Errorf("error restoring snapshot %s: %w", path, err)
+	default:
+		logger.Info("restored snapshot", "path", path)
 	}
+	return nil
+}
 
-	if len(byteData) == 0 {
-		http.Error(w, "empty request body", http.StatusBadRequest)
-		return
+// newServerFromConfig builds a Server from a validated Config. A dry run
+// also passes it one that failed validation, so bad values must not panic.
+func newServerFromConfig(cfg *config.Config, logger *slog.Logger, manager *users.Manager) *Server {
+	// Validate has already checked the date and the proxy list.
+	var sunset time.Time
+	if cfg.LegacySunset != "" {
+		sunset, _ = time.Parse(time.RFC3339, cfg.LegacySunset)
 	}
 
-	var reqData UserData
-	err = json.Unmarshal(byteData, &reqData)
-	if err != nil {
-		slog.Error("error unmarshalling request body", "err", err)
-		http.Error(w, "error parsing request body", http.StatusBadRequest)
-		return
+	proxies, _ := config.ParseTrustedProxies(cfg.TrustedProxies)
+	slowGroups, _ := config.ParseSlowRequestGroups(cfg.SlowRequestGroups)
+
+	srv := NewServer(logger, manager)
+	srv.realIP = &realIP{trusted: proxies}
+	srv.timeouts = Timeouts{
+		ReadHeader: cfg.ReadHeaderTimeout.Duration,
+		Read:       cfg.ReadTimeout.Duration,
+		Write:      cfg.WriteTimeout.Duration,
+		Idle:       cfg.IdleTimeout.Duration,
+		Handler:    cfg.HandlerTimeout.Duration,
+		Store:      cfg.StoreTimeout.Duration,
+		BodyRead:   cfg.BodyReadTimeout.Duration,
 	}
-
-	if reqData.FirstName == "" {
-		http.Error(w, "invalid request body!", http.StatusBadRequest)
-		return
+	srv.slowRequest = cfg.SlowRequestThreshold.Duration
+	srv.slowRequestGroups = slowGroups
+	srv.debugTiming = cfg.DebugTiming
+	srv.registerOnGreet = cfg.RegisterOnGreet
+	srv.enablePprof = cfg.EnablePprof
+	srv.enableDebugEndpoints = cfg.EnableDebugEndpoints
+	srv.legacy = legacyPolicy{deprecated: cfg.DeprecateLegacy, sunset: sunset}
+	srv.greetings = newGreetingCounter(cfg.StatsMaxNames)
+	if cfg.GreetingCooldownLimit > 0 {
+		srv.cooldown = newGreetCooldown(cfg.GreetingCooldownLimit, cfg.GreetingCooldownWindow.Duration, cooldownMaxNames)
 	}
-
-	handleHello(w, reqData.FirstName)
-
+	srv.searchLimit = cfg.SearchMaxResults
+	srv.limiter = newConcurrencyLimiter(cfg.MaxInFlight, cfg.MaxInFlightWait.Duration)
+	srv.urlLimits = urlLimits{
+		maxLength:      cfg.MaxURLLength,
+		maxParams:      cfg.MaxQueryParams,
+		maxValueLength: cfg.MaxQueryValueLength,
+	}
+	srv.trailingSlash = cfg.TrailingSlash
+	srv.redact = httpx.NewRedactor(config.ParseList(cfg.RedactHeaders), config.ParseList(cfg.RedactFields))
+	srv.missingName = missingNamePolicy{mode: cfg.MissingName, name: cfg.DefaultName}
+	srv.greeterSource = greeterSource{localesDir: cfg.LocalesDir, template: cfg.Greeting, templateFile: cfg.GreetingFile}
+	srv.idempotency = newIdempotencyCache(cfg.IdempotencyTTL.Duration, time.Now)
+	if cfg.SessionSecret != "" {
+		srv.sessionSecret = []byte(cfg.SessionSecret)
+	}
+	return srv
 }
 
-func handleHello(w http.ResponseWriter, username string) {
-
-	var output bytes.Buffer
-	output.WriteString("Hello ")
-	output.WriteString(username)
-	output.WriteString("!\n")
-
-	_, err := w.Write(output.Bytes())
-	if err != nil {
-		slog.Error("error starting response body", "err: ", err.Error())
-		return
+// notifyHangup relays SIGHUP to the returned channel until stop is called,
+// which also closes the channel so the goroutine reading it returns.
+func notifyHangup() (hup <-chan os.Signal, stop func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	return c, func() {
+		signal.Stop(c)
+		close(c)
 	}
 }
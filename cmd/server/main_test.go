@@ -3,15 +3,30 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/users"
 )
 
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	return NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), users.NewManager())
+}
+
 func TestHandleRoot(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/health", nil)
-	handleRoot(w, r)
+	r.Header.Set("Accept", "text/plain")
+	newTestServer(t).handleRoot(w, r)
 
 	desiredCode := http.StatusOK
 	if w.Code != desiredCode {
@@ -29,7 +44,7 @@ func TestHandleRoot(t *testing.T) {
 func TestHandleGoodbye(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/goodbye", nil)
-	handleGoodbye(w, r)
+	newTestServer(t).handleGoodbye(w, r)
 
 	desiredCode := http.StatusOK
 	if w.Code != desiredCode {
@@ -48,7 +63,7 @@ func TestHandleHelloParameterized(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/hello?user=Testing", nil)
 
-	handleHelloParameterized(w, r)
+	newTestServer(t).handleHelloParameterized(w, r)
 
 	desiredCode := http.StatusOK
 	if w.Code != desiredCode {
@@ -63,11 +78,47 @@ func TestHandleHelloParameterized(t *testing.T) {
 	}
 }
 
+func TestHandleHelloParameterizedMultiple(t *testing.T) {
+	tooMany := "/hello/?user=last"
+	for i := range maxHelloNames {
+		tooMany += fmt.Sprintf("&user=name%d", i)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		want   string
+	}{
+		{"two names", "/hello/?user=alice&user=bob", http.StatusOK, "Hello alice!\nHello bob!\n"},
+		{"order preserved", "/hello/?user=bob&user=alice", http.StatusOK, "Hello bob!\nHello alice!\n"},
+		{"duplicates", "/hello/?user=alice&user=bob&user=alice", http.StatusOK, "Hello alice!\nHello bob!\n"},
+		{"empty values skipped", "/hello/?user=&user=bob&user=", http.StatusOK, "Hello bob!\n"},
+		{"repeats do not count towards the cap", "/hello/?user=a" + strings.Repeat("&user=a", 30), http.StatusOK, "Hello a!\n"},
+		{"cap", tooMany, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newTestServer(t).handleHelloParameterized(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+					tt.status, w.Code, w.Body.String())
+			}
+			if tt.want != "" && w.Body.String() != tt.want {
+				t.Errorf("bad response body: expected %q, got %q", tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestHandleHelloNoParameterized(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/hello/", nil)
 
-	handleHelloParameterized(w, r)
+	newTestServer(t).handleHelloParameterized(w, r)
 
 	desiredCode := http.StatusOK
 	if w.Code != desiredCode {
@@ -86,7 +137,7 @@ func TestHandleHelloWrongParameterized(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/hello?foo=bar", nil)
 
-	handleHelloParameterized(w, r)
+	newTestServer(t).handleHelloParameterized(w, r)
 
 	desiredCode := http.StatusOK
 	if w.Code != desiredCode {
@@ -106,7 +157,7 @@ func TestHandleUserResponsesHello(t *testing.T) {
 	r.SetPathValue("user", "TestMan")
 	w := httptest.NewRecorder()
 
-	handleUserResponsesHello(w, r)
+	newTestServer(t).handleUserResponsesHello(w, r)
 
 	desiredCode := http.StatusOK
 	if w.Code != desiredCode {
@@ -122,48 +173,71 @@ func TestHandleUserResponsesHello(t *testing.T) {
 }
 
 func TestHandleHelloHeader(t *testing.T) {
-	r := httptest.NewRequest(http.MethodGet, "/user/hello", nil)
-	r.Header.Set("user", "TestMan")
-
-	w := httptest.NewRecorder()
-
-	handleHelloHeader(w, r)
-
-	desiredCode := http.StatusOK
-	if w.Code != desiredCode {
-		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
-			desiredCode, w.Code, w.Body.String())
+	handler := newTestServer(t).Routes()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"user header", map[string]string{"user": "TestMan"}, "Hello TestMan!\n"},
+		{"X-User header", map[string]string{"X-User": "TestMan"}, "Hello TestMan!\n"},
+		{"X-User wins over user", map[string]string{"X-User": "NewMan", "user": "OldMan"}, "Hello NewMan!\n"},
 	}
-
-	expectedMessage := []byte("Hello TestMan!\n")
-	if !bytes.Equal(w.Body.Bytes(), expectedMessage) {
-		t.Errorf("bad response body: expected %s, got %s\nbody: %s\n",
-			string(expectedMessage), string(w.Body.Bytes()), w.Body.String())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/user/hello", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+					http.StatusOK, w.Code, w.Body.String())
+			}
+			if w.Body.String() != tt.want {
+				t.Errorf("bad response body: expected %q, got %q", tt.want, w.Body.String())
+			}
+		})
 	}
 }
 
 func TestHandleHelloNoHeader(t *testing.T) {
-	r := httptest.NewRequest(http.MethodGet, "/user/hello", nil)
-
-	w := httptest.NewRecorder()
-
-	handleHelloNoHeader(w, r)
-
-	desiredCode := http.StatusBadRequest
-	if w.Code != desiredCode {
-		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
-			desiredCode, w.Code, w.Body.String())
+	handler := newTestServer(t).Routes()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		message string
+	}{
+		{"empty user", map[string]string{"user": ""}, "user must not be empty"},
+		// An empty X-User is not a reason to fall back to user.
+		{"empty X-User", map[string]string{"X-User": "", "user": "OldMan"}, ""},
 	}
-
-	expectedMessage := []byte("invalid username provided\n")
-	if !bytes.Equal(w.Body.Bytes(), expectedMessage) {
-		t.Errorf("bad response body: expected %s, got %s\nbody: %s\n",
-			string(expectedMessage), string(w.Body.Bytes()), w.Body.String())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/user/hello", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+					http.StatusBadRequest, w.Code, w.Body.String())
+			}
+			assertErrorCode(t, w, codeInvalidRequest, tt.message)
+		})
 	}
 }
 
 func TestHandleJSON(t *testing.T) {
-	testRequest := UserData{
+	testRequest := CreateUserRequest{
 		FirstName: "human",
 	}
 
@@ -176,7 +250,7 @@ func TestHandleJSON(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	handleJSON(w, req)
+	newTestServer(t).handleJSON(w, req)
 
 	desiredCode := http.StatusOK
 	if w.Code != desiredCode {
@@ -196,7 +270,7 @@ func TestHandleJSONEmptyBody(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	handleJSON(w, req)
+	newTestServer(t).handleJSON(w, req)
 
 	desiredCode := http.StatusBadRequest
 	if w.Code != desiredCode {
@@ -204,15 +278,11 @@ func TestHandleJSONEmptyBody(t *testing.T) {
 			desiredCode, w.Code, w.Body.String())
 	}
 
-	expectedMessage := []byte("empty request body\n")
-	if !bytes.Equal(w.Body.Bytes(), expectedMessage) {
-		t.Errorf("bad response body: expected %s, got %s\nbody: %s\n",
-			string(expectedMessage), string(w.Body.Bytes()), w.Body.String())
-	}
+	assertErrorCode(t, w, codeInvalidRequest, "empty request body")
 }
 
 func TestHandleJSONEmptyNameFeild(t *testing.T) {
-	testRequest := UserData{
+	testRequest := CreateUserRequest{
 		FirstName: "",
 	}
 
@@ -225,17 +295,148 @@ func TestHandleJSONEmptyNameFeild(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	handleJSON(w, req)
+	newTestServer(t).handleJSON(w, req)
 
-	desiredCode := http.StatusBadRequest
+	desiredCode := http.StatusUnprocessableEntity
 	if w.Code != desiredCode {
 		t.Errorf("bad response code: expected %v got %v\nbody: %s\n",
 			desiredCode, w.Code, w.Body.String())
 	}
 
-	expectedMessage := []byte("invalid request body!\n")
-	if !bytes.Equal(w.Body.Bytes(), expectedMessage) {
-		t.Errorf("bad response body: expected %s, got %s\nbody: %s\n",
-			string(expectedMessage), string(w.Body.Bytes()), w.Body.String())
+	assertErrorCode(t, w, codeValidation, "validation failed")
+}
+
+func TestHandleJSONGreetingAndLanguage(t *testing.T) {
+	tests := []struct {
+		body     string
+		header   string
+		expected string
+		language string
+	}{
+		{`{"FirstName":"human","Language":"fr"}`, "de", "Bonjour human !\n", "fr"},
+		{`{"FirstName":"human","Greeting":"Good morning"}`, "fr", "Good morning human!\n", "en"},
+		{`{"FirstName":"human","Greeting":"Hi","Language":"en-GB"}`, "", "Hi human!\n", "en"},
+		{`{"FirstName":"human","Language":"fr_CA"}`, "de", "Bonjour human !\n", "fr"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(tt.body))
+		req.Header.Set("Accept-Language", tt.header)
+		w := httptest.NewRecorder()
+		newTestServer(t).handleJSON(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: bad response code: expected %d, got %d\nbody: %s\n", tt.body, http.StatusOK, w.Code, w.Body.String())
+			continue
+		}
+		if w.Body.String() != tt.expected {
+			t.Errorf("%s: bad response body: expected %q, got %q", tt.body, tt.expected, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Language"); got != tt.language {
+			t.Errorf("%s: expected Content-Language %q, got %q", tt.body, tt.language, got)
+		}
+		if got := req.Header.Get("Accept-Language"); got != tt.header {
+			t.Errorf("%s: request header modified: %q", tt.body, got)
+		}
+	}
+}
+
+func TestHandleJSONGreetingRenderError(t *testing.T) {
+	s := newTestServer(t)
+	g, err := s.newGreeter(greeting.WithMaxNameLength(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.greeter = g
+
+	req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(`{"FirstName":"human","Greeting":"Hi"}`))
+	w := httptest.NewRecorder()
+	s.handleJSON(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeInternal, "error rendering greeting")
+	if w.Header().Get("Content-Language") != "" {
+		t.Errorf("Content-Language set on a failed greeting: %q", w.Header().Get("Content-Language"))
+	}
+}
+
+func TestHandleJSONValidationErrors(t *testing.T) {
+	body := `{"FirstName":"` + strings.Repeat("x", 101) + `","Greeting":"{{.Name}}","Language":"en-"}`
+	req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	newTestServer(t).handleJSON(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeValidation, "validation failed")
+
+	var resp errorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	var fields []string
+	for _, fe := range resp.Error.Fields {
+		fields = append(fields, fe.Field)
+	}
+	if want := []string{"first_name", "language", "greeting"}; !slices.Equal(fields, want) {
+		t.Errorf("expected problems with %q, got %q", want, fields)
+	}
+}
+
+func TestHelloUsernameValidation(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	header := func(value string) map[string][]string {
+		return map[string][]string{"User": {value}}
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		header  map[string][]string
+		status  int
+		want    string
+		message string
+	}{
+		{"query absent", "/hello/", nil, http.StatusOK, "Hello User!\n", ""},
+		{"query empty", "/hello/?user=", nil, http.StatusBadRequest, "", "user must not be empty"},
+		{"query whitespace", "/hello/?user=%20%09", nil, http.StatusBadRequest, "", "user must not be empty"},
+		{"query valid", "/hello/?user=alice", nil, http.StatusOK, "Hello alice!\n", ""},
+		{"header absent", "/user/hello", nil, http.StatusOK, "Hello User!\n", ""},
+		{"header empty", "/user/hello", header(""), http.StatusBadRequest, "", "user must not be empty"},
+		{"header whitespace", "/user/hello", header("   "), http.StatusBadRequest, "", "user must not be empty"},
+		{"header valid", "/user/hello", header("alice"), http.StatusOK, "Hello alice!\n", ""},
+		{"path whitespace", "/responses/%20/hello/", nil, http.StatusBadRequest, "", "user must not be empty"},
+		{"path valid", "/responses/alice/hello/", nil, http.StatusOK, "Hello alice!\n", ""},
+		{"path valid without slash", "/responses/alice/hello", nil, http.StatusOK, "Hello alice!\n", ""},
+		{"path multi-byte", "/responses/%C3%89lo%C3%AFse%20%E7%8E%8B/hello/", nil, http.StatusOK, "Hello Éloïse 王!\n", ""},
+		{"path too long", "/responses/" + strings.Repeat("a", users.MaxNameLength+1) + "/hello", nil, http.StatusBadRequest, "",
+			`invalid name "` + strings.Repeat("a", users.MaxNameLength+1) + `": must be at most 100 characters, got 101`},
+		{"path control character", "/responses/al%00ice/hello/", nil, http.StatusBadRequest, "", `invalid name "al\x00ice": must not contain control characters`},
+		{"v1 query empty", "/api/v1/hello?user=", nil, http.StatusBadRequest, "", "user must not be empty"},
+		{"v1 path whitespace", "/api/v1/hello/%20", nil, http.StatusBadRequest, "", "user must not be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+					tt.status, w.Code, w.Body.String())
+			}
+			if tt.want != "" && w.Body.String() != tt.want {
+				t.Errorf("bad response body: expected %q, got %q", tt.want, w.Body.String())
+			}
+			if tt.message != "" {
+				assertErrorCode(t, w, codeInvalidRequest, tt.message)
+			}
+		})
 	}
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// probeMethods are the methods checked against the mux when answering
+// OPTIONS, in the order they are listed in the Allow header.
+var probeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// allowedMethods asks the mux which methods r's path is registered for. The
+// mux is the route registry: a "GET /x" pattern also answers HEAD, and a
+// pattern without a method answers every method.
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allowed []string
+	for _, method := range probeMethods {
+		probe := r.WithContext(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// withMethodHandling answers OPTIONS for every registered path with 204 and
+// an Allow header, and gives HEAD responses the Content-Length of the GET
+// body they stand in for. Everything else, including OPTIONS on an unknown
+// path, is passed to next.
+func withMethodHandling(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodOptions:
+			allowed := allowedMethods(mux, r)
+			if len(allowed) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+			w.WriteHeader(http.StatusNoContent)
+
+		case http.MethodHead:
+			hw := &headWriter{ResponseWriter: w}
+			next.ServeHTTP(hw, r)
+			hw.finish()
+
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// headWriter discards the body of a HEAD response while counting it, and
+// holds the status back until the handler returns so Content-Length can be
+// set from the count. Like the server does for GET, it sniffs Content-Type
+// from the first write when the handler did not set one.
+type headWriter struct {
+	http.ResponseWriter
+	status  int
+	written int
+}
+
+func (hw *headWriter) WriteHeader(code int) {
+	if hw.status == 0 {
+		hw.status = code
+	}
+}
+
+func (hw *headWriter) Write(b []byte) (int, error) {
+	hw.WriteHeader(http.StatusOK)
+	if hw.written == 0 && len(b) > 0 && hw.Header().Get("Content-Type") == "" {
+		hw.Header().Set("Content-Type", http.DetectContentType(b))
+	}
+	hw.written += len(b)
+	return len(b), nil
+}
+
+// Flush is a no-op: nothing is sent until the handler has returned.
+func (hw *headWriter) Flush() {}
+
+func (hw *headWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+func (hw *headWriter) finish() {
+	if hw.status == 0 {
+		hw.status = http.StatusOK
+	}
+	header := hw.Header()
+	if header.Get("Content-Length") == "" && hw.written > 0 {
+		header.Set("Content-Length", strconv.Itoa(hw.written))
+	}
+	hw.ResponseWriter.WriteHeader(hw.status)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	tests := []struct {
+		target string
+		status int
+		allow  string
+	}{
+		{"/json", http.StatusNoContent, "POST, OPTIONS"},
+		{"/health", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{"/users/alice@example.com", http.StatusNoContent, "GET, HEAD, PUT, DELETE, OPTIONS"},
+		{"/api/v1/users", http.StatusNoContent, "GET, HEAD, POST, OPTIONS"},
+		{"/goodbye", http.StatusNoContent, "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS"},
+		{"/no/such/path", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, tt.target, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+					tt.status, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("bad Allow header: expected %q, got %q", tt.allow, got)
+			}
+		})
+	}
+}
+
+func TestHead(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(t).Routes())
+	t.Cleanup(srv.Close)
+
+	for _, target := range []string{"/", "/hello/?user=alice", "/api/v1/users"} {
+		get, getBody := doRequest(t, srv.Client(), http.MethodGet, srv.URL+target, "")
+
+		resp, err := srv.Client().Head(srv.URL + target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != get.StatusCode {
+			t.Errorf("%s: bad response code: expected %d, got %d", target, get.StatusCode, resp.StatusCode)
+		}
+		if len(body) != 0 {
+			t.Errorf("%s: expected no body, got %q", target, body)
+		}
+		if resp.ContentLength != int64(len(getBody)) {
+			t.Errorf("%s: bad Content-Length: expected %d, got %d", target, len(getBody), resp.ContentLength)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != get.Header.Get("Content-Type") {
+			t.Errorf("%s: bad content type: expected %q, got %q", target, get.Header.Get("Content-Type"), ct)
+		}
+	}
+}
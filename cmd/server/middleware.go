@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// Middleware groups. Routes and AdminRoutes take every stack from
+// middleware, so the order of each is defined here and nowhere else.
+const (
+	// groupPublic wraps the whole public listener.
+	groupPublic = "public"
+
+	// groupAPI wraps each public route that must finish within the handler
+	// timeout, and bounds its store calls by the shorter store timeout.
+	groupAPI = "api"
+
+	// groupLegacy is groupAPI plus the deprecation headers of the
+	// unversioned (v0) routes.
+	groupLegacy = "legacy"
+
+	// groupStream wraps the unversioned routes that stream large bodies and
+	// manage their own write deadline, so they get no handler timeout.
+	groupStream = "stream"
+
+	// groupAdmin wraps the admin listener. It has no load shedding, so
+	// operators can still reach /metrics when the public side is saturated.
+	groupAdmin = "admin"
+)
+
+// middleware returns the stack for group, outermost first. It panics on an
+// unknown group, which is a wiring bug.
+func (s *Server) middleware(group string) *httpx.Chain {
+	api := httpx.NewChain(
+		httpx.Middleware{Name: "body-deadline", Wrap: s.withBodyReadDeadline},
+		httpx.Middleware{Name: "timeout", Wrap: func(h http.Handler) http.Handler { return s.withTimeout(h.ServeHTTP) }},
+		httpx.Middleware{Name: "store-deadline", Wrap: s.withStoreDeadline},
+	)
+	legacyHeaders := httpx.Middleware{Name: "legacy-headers", Wrap: func(h http.Handler) http.Handler { return s.withLegacyHeaders(h.ServeHTTP) }}
+
+	switch group {
+	case groupPublic:
+		return httpx.NewChain(
+			httpx.Middleware{Name: "real-ip", Wrap: s.withRealIP},
+			httpx.Middleware{Name: "access-log", Wrap: s.withAccessLog},
+			httpx.Middleware{Name: "request-count", Wrap: s.withRequestCount},
+			httpx.Middleware{Name: "recover", Wrap: s.withRecovery},
+			httpx.Middleware{Name: "url-limits", Wrap: s.withURLLimits},
+			httpx.Middleware{Name: "concurrency-limit", Wrap: s.withConcurrencyLimit},
+			httpx.Middleware{Name: "drain", Wrap: s.withDrain},
+			httpx.Middleware{Name: "server-timing", Wrap: func(h http.Handler) http.Handler { return withServerTiming(h, s.debugTiming) }},
+		)
+	case groupAPI:
+		return api
+	case groupLegacy:
+		return api.Extend(legacyHeaders)
+	case groupStream:
+		return httpx.NewChain(legacyHeaders)
+	case groupAdmin:
+		return httpx.NewChain(
+			httpx.Middleware{Name: "track", Wrap: func(h http.Handler) http.Handler { return httpx.Track(s.logger, h) }},
+		)
+	}
+	panic("unknown middleware group " + group)
+}
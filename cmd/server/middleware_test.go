@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareStacks(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		group string
+		want  []string
+	}{
+		{groupPublic, []string{"real-ip", "access-log", "request-count", "recover", "url-limits", "concurrency-limit", "drain", "server-timing"}},
+		{groupAPI, []string{"body-deadline", "timeout", "store-deadline"}},
+		{groupLegacy, []string{"body-deadline", "timeout", "store-deadline", "legacy-headers"}},
+		{groupStream, []string{"legacy-headers"}},
+		{groupAdmin, []string{"track"}},
+	}
+	for _, tt := range tests {
+		if got := s.middleware(tt.group).Stack(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %q, got %q", tt.group, tt.want, got)
+		}
+	}
+
+	if slices.Contains(s.middleware(groupAdmin).Stack(), "concurrency-limit") {
+		t.Error("admin group must not shed load")
+	}
+}
+
+func TestAdminNotShed(t *testing.T) {
+	s := newTestServer(t)
+	s.limiter = newConcurrencyLimiter(1, time.Millisecond)
+	s.limiter.slots <- struct{}{}
+	defer s.limiter.release()
+
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("public: expected %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.AdminRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("admin: expected %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestUnknownMiddlewareGroup(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	newTestServer(t).middleware("nope")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// Missing-name modes: how the query, header and form hello variants answer
+// a request that names no one. Under missingNameDefault they greet the
+// policy's name, under missingNameReject they fail with 400 missing_user,
+// and under missingNameAnonymous they send the anonymous greeting, such as
+// "Hello there!". The path variant always has a name.
+const (
+	missingNameDefault   = "default-name"
+	missingNameReject    = "reject"
+	missingNameAnonymous = "anonymous"
+)
+
+// defaultGreetingName is greeted under missingNameDefault unless another
+// name is configured.
+const defaultGreetingName = "User"
+
+// missingNamePolicy is a missing-name mode and, for missingNameDefault, the
+// name to greet.
+type missingNamePolicy struct {
+	mode string
+	name string
+}
+
+// greetMissing answers a hello request that names no one according to
+// s.missingName, encoding the greeting as v does.
+func (s *Server) greetMissing(w http.ResponseWriter, r *http.Request, v apiVersion) {
+	switch s.missingName.mode {
+	case missingNameReject:
+		httpx.WriteError(w, http.StatusBadRequest, codeMissingUser, "user is required")
+	case missingNameAnonymous:
+		s.greetAnonymous(w, r, v)
+	default:
+		s.recordGreetingByName(s.missingName.name)
+		s.greet(w, r, v, s.missingName.name)
+	}
+}
+
+// greetAnonymous is greet for someone whose name is not known. It counts
+// no greeting, since there is no name to count it under.
+func (s *Server) greetAnonymous(w http.ResponseWriter, r *http.Request, v apiVersion) {
+	timing := timingFrom(r.Context())
+
+	if s.canceled(r, "render greeting") {
+		return
+	}
+
+	start := timing.start()
+	res, err := s.greeter.NegotiateAnonymous(r.Header.Get("Accept-Language"))
+	timing.end(phaseRender, start)
+	if err != nil {
+		s.logger.Error("error rendering greeting", "err", err)
+		httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "error rendering greeting")
+		return
+	}
+
+	w.Header().Set("Content-Language", res.Language)
+	v.writeGreeting(w, []greeting.Result{res})
+}
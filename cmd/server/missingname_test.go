@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMissingName(t *testing.T) {
+	multipartType, multipartBody := multipartForm(t, map[string]string{"name": "bob"})
+
+	request := func(variant string) *http.Request {
+		switch variant {
+		case "header":
+			return httptest.NewRequest(http.MethodGet, "/user/hello", nil)
+		case "form":
+			r := httptest.NewRequest(http.MethodPost, "/hello", strings.NewReader("name=bob"))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return r
+		case "multipart form":
+			r := httptest.NewRequest(http.MethodPost, "/hello", strings.NewReader(multipartBody))
+			r.Header.Set("Content-Type", multipartType)
+			return r
+		case "v1 query":
+			return httptest.NewRequest(http.MethodGet, "/api/v1/hello", nil)
+		default:
+			return httptest.NewRequest(http.MethodGet, "/hello", nil)
+		}
+	}
+
+	tests := []struct {
+		mode    string
+		variant string
+		status  int
+		want    string
+	}{
+		{missingNameDefault, "query", http.StatusOK, "Hello Friend!\n"},
+		{missingNameDefault, "header", http.StatusOK, "Hello Friend!\n"},
+		{missingNameDefault, "form", http.StatusOK, "Hello Friend!\n"},
+		{missingNameDefault, "v1 query", http.StatusOK, `{"greeting":"Hello Friend!","language":"en"}` + "\n"},
+		{missingNameReject, "query", http.StatusBadRequest, ""},
+		{missingNameReject, "header", http.StatusBadRequest, ""},
+		{missingNameReject, "multipart form", http.StatusBadRequest, ""},
+		{missingNameReject, "v1 query", http.StatusBadRequest, ""},
+		{missingNameAnonymous, "query", http.StatusOK, "Hello there!\n"},
+		{missingNameAnonymous, "header", http.StatusOK, "Hello there!\n"},
+		{missingNameAnonymous, "form", http.StatusOK, "Hello there!\n"},
+		{missingNameAnonymous, "v1 query", http.StatusOK, `{"greeting":"Hello there!","language":"en"}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+tt.variant, func(t *testing.T) {
+			s := newTestServer(t)
+			s.missingName = missingNamePolicy{mode: tt.mode, name: "Friend"}
+
+			w := httptest.NewRecorder()
+			s.Routes().ServeHTTP(w, request(tt.variant))
+			if w.Code != tt.status {
+				t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				assertErrorCode(t, w, codeMissingUser, "user is required")
+				return
+			}
+			if w.Body.String() != tt.want {
+				t.Errorf("bad response body: expected %q, got %q", tt.want, w.Body.String())
+			}
+		})
+	}
+
+	// A name that is present but empty is invalid in every mode.
+	s := newTestServer(t)
+	s.missingName = missingNamePolicy{mode: missingNameAnonymous}
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello?user=", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("empty name: expected %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	assertErrorCode(t, w, codeInvalidRequest, "user must not be empty")
+}
+
+func TestRunUnknownMissingNameMode(t *testing.T) {
+	err := run(context.Background(), []string{"-missing-name", "shrug"}, io.Discard, io.Discard)
+	if !errors.Is(err, errUsage) || exitCode(err) != 2 {
+		t.Fatalf("expected a usage error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `missing-name: invalid mode "shrug"`) {
+		t.Errorf("error does not name the mode: %v", err)
+	}
+}
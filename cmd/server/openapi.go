@@ -0,0 +1,608 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// The OpenAPI document is written by hand as Go values. Routes checks it
+// against the patterns it registers, so adding a route without documenting
+// it (or removing one and leaving its documentation behind) panics at
+// startup and fails every test. The document's own keys, such as
+// requestBody, are spelled as the OpenAPI specification says; the bodies it
+// describes are snake_case like the rest of the API.
+
+type openAPIDoc struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components openAPIComponents                `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*schema `json:"schemas"`
+}
+
+type operation struct {
+	Summary     string              `json:"summary"`
+	Parameters  []parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]response `json:"responses"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Properties map[string]*schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *schema            `json:"items,omitempty"`
+}
+
+func ref(name string) *schema {
+	return &schema{Ref: "#/components/schemas/" + name}
+}
+
+func scalar(typ string) *schema {
+	return &schema{Type: typ}
+}
+
+func object(required []string, properties map[string]*schema) *schema {
+	return &schema{Type: "object", Required: required, Properties: properties}
+}
+
+func arrayOf(items *schema) *schema {
+	return &schema{Type: "array", Items: items}
+}
+
+func jsonContent(s *schema) map[string]mediaType {
+	return map[string]mediaType{"application/json": {Schema: s}}
+}
+
+func jsonBody(s *schema) *requestBody {
+	return &requestBody{Required: true, Content: jsonContent(s)}
+}
+
+func jsonResponse(description string, s *schema) response {
+	return response{Description: description, Content: jsonContent(s)}
+}
+
+func textResponse(description string) response {
+	return response{Description: description, Content: map[string]mediaType{"text/plain": {Schema: scalar("string")}}}
+}
+
+func errResponse(description string) response {
+	return jsonResponse(description, ref("Error"))
+}
+
+func pathParam(name string) parameter {
+	return parameter{Name: name, In: "path", Required: true, Schema: scalar("string")}
+}
+
+func queryParam(name string, typ string) parameter {
+	return parameter{Name: name, In: "query", Schema: scalar(typ)}
+}
+
+var openAPISchemas = map[string]*schema{
+	"Error": object([]string{"error"}, map[string]*schema{
+		"error": object([]string{"code", "message"}, map[string]*schema{
+			"code":    scalar("string"),
+			"message": scalar("string"),
+			"fields": arrayOf(object([]string{"field", "error"}, map[string]*schema{
+				"field": scalar("string"),
+				"error": scalar("string"),
+			})),
+		}),
+	}),
+	"NewUser": object([]string{"first_name", "last_name", "email"}, map[string]*schema{
+		"first_name": scalar("string"),
+		"last_name":  scalar("string"),
+		"email":      {Type: "string", Format: "email"},
+	}),
+	"User": object([]string{"id", "first_name", "last_name", "email", "created_at", "updated_at", "verified", "tags"}, map[string]*schema{
+		"id":         scalar("string"),
+		"first_name": scalar("string"),
+		"last_name":  scalar("string"),
+		"email":      {Type: "string", Format: "email"},
+		"created_at": {Type: "string", Format: "date-time"},
+		"updated_at": {Type: "string", Format: "date-time"},
+		"verified":   scalar("boolean"),
+		"tags":       arrayOf(scalar("string")),
+	}),
+	"ExportedUser": object([]string{"first_name", "last_name", "email", "created_at", "updated_at"}, map[string]*schema{
+		"first_name": scalar("string"),
+		"last_name":  scalar("string"),
+		"email":      {Type: "string", Format: "email"},
+		"email_name": scalar("string"),
+		"created_at": {Type: "string", Format: "date-time"},
+		"updated_at": {Type: "string", Format: "date-time"},
+	}),
+	"UserList": object([]string{"users", "pagination"}, map[string]*schema{
+		"users": arrayOf(ref("User")),
+		"pagination": object([]string{"offset", "limit", "total"}, map[string]*schema{
+			"offset":      scalar("integer"),
+			"limit":       scalar("integer"),
+			"total":       scalar("integer"),
+			"next_cursor": scalar("string"),
+		}),
+	}),
+	"Greeting": object([]string{"greeting", "language"}, map[string]*schema{
+		"greeting": scalar("string"),
+		"language": scalar("string"),
+	}),
+}
+
+// routeDocs documents every registered pattern, keyed exactly as passed to
+// the mux. Patterns without a method are documented as GET.
+func routeDocs() map[string]*operation {
+	docs := map[string]*operation{
+		"GET /health": {
+			Summary: "Liveness check, with the status of each background task",
+			Responses: map[string]response{"200": jsonResponse("Healthy", object([]string{"status"}, map[string]*schema{
+				"status": scalar("string"),
+				"workers": arrayOf(object([]string{"name", "runs", "failures"}, map[string]*schema{
+					"name":       scalar("string"),
+					"runs":       scalar("integer"),
+					"failures":   scalar("integer"),
+					"last_run":   scalar("string"),
+					"last_error": scalar("string"),
+				})),
+			}))},
+		},
+		"GET /version": {
+			Summary: "Build version",
+			Responses: map[string]response{"200": jsonResponse("Version", object([]string{"version", "go"}, map[string]*schema{
+				"version": scalar("string"),
+				"go":      scalar("string"),
+			}))},
+		},
+		"GET /stats": {
+			Summary:    "Most greeted names, request totals and per-route request metrics",
+			Parameters: []parameter{queryParam("top", "integer")},
+			Responses: map[string]response{
+				"200": jsonResponse("Stats", object(nil, map[string]*schema{
+					"top": arrayOf(object([]string{"name", "count"}, map[string]*schema{
+						"name":  scalar("string"),
+						"count": scalar("integer"),
+					})),
+					"distinct_names":     scalar("integer"),
+					"truncated":          scalar("boolean"),
+					"total_greetings":    scalar("integer"),
+					"total_requests":     scalar("integer"),
+					"in_flight_requests": scalar("integer"),
+					"shed_requests":      scalar("integer"),
+					"uptime_seconds":     scalar("number"),
+					"routes": arrayOf(object([]string{"route", "requests", "client_errors", "server_errors", "slow_requests", "latency_ms"}, map[string]*schema{
+						"route":         scalar("string"),
+						"requests":      scalar("integer"),
+						"client_errors": scalar("integer"),
+						"server_errors": scalar("integer"),
+						"slow_requests": scalar("integer"),
+						"latency_ms": object([]string{"p50", "p95", "p99"}, map[string]*schema{
+							"p50": scalar("number"),
+							"p95": scalar("number"),
+							"p99": scalar("number"),
+						}),
+					})),
+				})),
+				"400": errResponse("Invalid top"),
+			},
+		},
+		"GET /openapi.json": {
+			Summary:   "This document",
+			Responses: map[string]response{"200": jsonResponse("OpenAPI 3 document", scalar("object"))},
+		},
+		"GET /docs": {
+			Summary:   "Interactive API documentation",
+			Responses: map[string]response{"200": {Description: "HTML page", Content: map[string]mediaType{"text/html": {Schema: scalar("string")}}}},
+		},
+
+		"/{$}": {
+			Summary:   "Homepage; text/plain welcome when Accept prefers it",
+			Responses: map[string]response{"200": {Description: "Homepage", Content: map[string]mediaType{"text/html": {Schema: scalar("string")}, "text/plain": {Schema: scalar("string")}}}},
+		},
+		"POST /debug/echo": {
+			Summary:     "Echo the request back; only with -enable-debug-endpoints",
+			RequestBody: &requestBody{Content: map[string]mediaType{"*/*": {Schema: scalar("string")}}},
+			Responses: map[string]response{
+				"200": jsonResponse("The request as received, Authorization redacted", object([]string{"method", "path", "headers", "query", "body", "body_encoding", "truncated"}, map[string]*schema{
+					"method":        scalar("string"),
+					"path":          scalar("string"),
+					"headers":       scalar("object"),
+					"query":         scalar("object"),
+					"body":          scalar("string"),
+					"body_encoding": scalar("string"),
+					"truncated":     scalar("boolean"),
+				})),
+			},
+		},
+		"GET /ws": {
+			Summary: "WebSocket: send {\"name\":...} messages, receive {\"message\":...} greetings",
+			Responses: map[string]response{
+				"101": {Description: "Switching to the WebSocket protocol"},
+				"426": {Description: "Not a WebSocket upgrade request"},
+			},
+		},
+		"GET /static/": {
+			Summary:   "Static assets for the homepage",
+			Responses: map[string]response{"200": {Description: "Asset"}, "404": {Description: "No such asset"}},
+		},
+		"/goodbye": {
+			Summary:   "Goodbye message",
+			Responses: map[string]response{"200": textResponse("Goodbye")},
+		},
+		"/hello/": {
+			Summary:    "Greet one or more users named by ?user=",
+			Parameters: []parameter{queryParam("user", "string")},
+			Responses: map[string]response{
+				"200": textResponse("One greeting per line"),
+				"400": errResponse("Empty user or too many users"),
+			},
+		},
+		"POST /hello": {
+			Summary: "Greet the user posted by an HTML form; JSON when Accept prefers it",
+			RequestBody: &requestBody{Required: true, Content: map[string]mediaType{
+				"application/x-www-form-urlencoded": {Schema: object([]string{"user"}, map[string]*schema{"user": scalar("string")})},
+				"multipart/form-data":               {Schema: object([]string{"user"}, map[string]*schema{"user": scalar("string")})},
+			}},
+			Responses: map[string]response{
+				"200": {Description: "Greeting", Content: map[string]mediaType{"text/plain": {Schema: scalar("string")}, "application/json": {Schema: ref("Greeting")}}},
+				"400": errResponse("Malformed form, or a missing or invalid user field"),
+				"413": errResponse("Form too large"),
+				"415": errResponse("Content-Type is not a form encoding"),
+			},
+		},
+		"/responses/{user}/hello/": {
+			Summary:    "Greet the user named in the path",
+			Parameters: []parameter{pathParam("user")},
+			Responses:  map[string]response{"200": textResponse("Greeting")},
+		},
+		"/user/hello": {
+			Summary: "Greet the user named by the X-User header, or the user header without it",
+			Parameters: []parameter{
+				{Name: "X-User", In: "header", Schema: scalar("string")},
+				{Name: "user", In: "header", Schema: scalar("string")},
+			},
+			Responses: map[string]response{
+				"200": textResponse("One greeting per line"),
+				"400": errResponse("Missing or empty X-User and user headers"),
+			},
+		},
+		"POST /json": {
+			Summary: "Greet the user posted as JSON",
+			RequestBody: jsonBody(object([]string{"first_name"}, map[string]*schema{
+				"first_name": scalar("string"),
+				"last_name":  scalar("string"),
+				"email":      scalar("string"),
+				"greeting":   scalar("string"),
+				"language":   scalar("string"),
+			})),
+			Responses: map[string]response{
+				"200": textResponse("Greeting, or a JSON registration result when the server registers on greet"),
+				"400": errResponse("Empty or malformed body"),
+				"415": errResponse("Content-Type is not application/json"),
+				"422": errResponse("Invalid FirstName, Greeting or Language"),
+			},
+		},
+		"GET /users/export.csv": {
+			Summary:    "Export all users as CSV",
+			Parameters: []parameter{queryParam("revision", "integer")},
+			Responses: map[string]response{
+				"200": {Description: "CSV export", Content: map[string]mediaType{"text/csv": {Schema: scalar("string")}}},
+				"410": errResponse("Revision no longer cached"),
+			},
+		},
+		"GET /users/export": {
+			Summary:    "Export all users as NDJSON or CSV",
+			Parameters: []parameter{queryParam("format", "string")},
+			Responses: map[string]response{
+				"200": {Description: "Export", Content: map[string]mediaType{"application/x-ndjson": {Schema: ref("ExportedUser")}}},
+				"400": errResponse("Unknown format"),
+			},
+		},
+
+		"GET /api/v1/hello": {
+			Summary:    "Greet the user named by ?user=",
+			Parameters: []parameter{queryParam("user", "string")},
+			Responses: map[string]response{
+				"200": jsonResponse("Greeting", ref("Greeting")),
+				"400": errResponse("Empty user or more than one user"),
+			},
+		},
+		"GET /api/v1/hello/{user}": {
+			Summary:    "Greet the user named in the path",
+			Parameters: []parameter{pathParam("user")},
+			Responses: map[string]response{
+				"200": jsonResponse("Greeting", ref("Greeting")),
+				"400": errResponse("Empty user"),
+			},
+		},
+		"GET /api/v1/users/by-id": {
+			Summary:    "Get a user by ID",
+			Parameters: []parameter{{Name: "id", In: "query", Required: true, Schema: scalar("string")}},
+			Responses: map[string]response{
+				"200": jsonResponse("User; the ETag header carries its version", ref("User")),
+				"304": {Description: "If-None-Match matches the user's ETag"},
+				"400": errResponse("Missing id, or not an ID the server could have issued"),
+				"404": errResponse("No such user"),
+				"410": errResponse("User was deleted"),
+			},
+		},
+		"GET /api/v1/users/{first}/{last}": {
+			Summary:    "Get a user by name",
+			Parameters: []parameter{pathParam("first"), pathParam("last")},
+			Responses: map[string]response{
+				"200": jsonResponse("User; the ETag header carries its version", ref("User")),
+				"304": {Description: "If-None-Match matches the user's ETag"},
+				"404": errResponse("No such user"),
+				"410": errResponse("User was deleted"),
+			},
+		},
+	}
+
+	// The users routes are the same in v0 and v1.
+	for _, prefix := range []string{"", apiV1.prefix} {
+		docs["GET "+prefix+"/users"] = &operation{
+			Summary:    "List users",
+			Parameters: []parameter{queryParam("cursor", "string"), queryParam("offset", "integer"), queryParam("limit", "integer"), queryParam("verified", "boolean"), queryParam("sort", "string"), queryParam("tags", "string")},
+			Responses: map[string]response{
+				"200": jsonResponse("A page of users; pagination.next_cursor continues it", ref("UserList")),
+				"304": {Description: "If-None-Match matches the weak ETag of the current user list"},
+				"400": errResponse("Invalid cursor, offset, limit, verified, sort or tags; invalid_cursor for a tampered or expired cursor, or one issued for another sort"),
+			},
+		}
+		docs["POST "+prefix+"/users"] = &operation{
+			Summary:     "Create a user; retries with the same Idempotency-Key replay the first response",
+			Parameters:  []parameter{{Name: idempotencyKeyHeader, In: "header", Schema: scalar("string")}},
+			RequestBody: jsonBody(ref("NewUser")),
+			Responses: map[string]response{
+				"201": jsonResponse("Created; Location points at the user", ref("User")),
+				"409": errResponse("Name or email already taken"),
+				"413": errResponse("Body too large to check against an Idempotency-Key"),
+				"415": errResponse("Content-Type is not application/json"),
+				"422": errResponse("Validation failed, or Idempotency-Key reused with a different body"),
+			},
+		}
+		docs["POST "+prefix+"/users/batch"] = &operation{
+			Summary:     "Create several users, all or nothing with ?atomic=true",
+			Parameters:  []parameter{queryParam("atomic", "boolean")},
+			RequestBody: jsonBody(arrayOf(ref("NewUser"))),
+			Responses: map[string]response{
+				"207": jsonResponse("One result per entry", arrayOf(object([]string{"index", "status"}, map[string]*schema{
+					"index":  scalar("integer"),
+					"status": scalar("string"),
+					"id":     scalar("string"),
+					"error":  scalar("string"),
+				}))),
+				"400": errResponse("Empty or malformed batch"),
+				"413": errResponse("Batch too large"),
+				"415": errResponse("Content-Type is not application/json"),
+			},
+		}
+		docs["GET "+prefix+"/users/archive"] = &operation{
+			Summary: "Export every live user as a versioned archive for POST /users/archive (admin only)",
+			Responses: map[string]response{
+				"200": jsonResponse("Archive", object([]string{"format_version", "exported_at", "users"}, map[string]*schema{
+					"format_version": scalar("integer"),
+					"exported_at":    {Type: "string", Format: "date-time"},
+					"users":          arrayOf(scalar("object")),
+				})),
+				"401": errResponse("Authentication required"),
+				"403": errResponse("Not an admin"),
+			},
+		}
+		docs["POST "+prefix+"/users/archive"] = &operation{
+			Summary:     "Import an archive from GET /users/archive, all or nothing (admin only)",
+			Parameters:  []parameter{queryParam("mode", "string")},
+			RequestBody: jsonBody(scalar("object")),
+			Responses: map[string]response{
+				"200": jsonResponse("How many users were added, overwritten and skipped", object([]string{"added", "overwritten", "skipped"}, map[string]*schema{
+					"added":       scalar("integer"),
+					"overwritten": scalar("integer"),
+					"skipped":     scalar("integer"),
+				})),
+				"400": errResponse("Invalid mode or malformed archive"),
+				"401": errResponse("Authentication required"),
+				"403": errResponse("Not an admin"),
+				"409": errResponse("A name or email is taken and mode is fail, or an archived user clashes with two users"),
+				"413": errResponse("Archive too large"),
+				"415": errResponse("Content-Type is not application/json"),
+			},
+		}
+		docs["GET "+prefix+"/users/search"] = &operation{
+			Summary:    "Search users by name prefix",
+			Parameters: []parameter{{Name: "q", In: "query", Required: true, Schema: scalar("string")}, queryParam("fuzzy", "boolean"), queryParam("verified", "boolean")},
+			Responses: map[string]response{
+				"200": jsonResponse("Matching users", object([]string{"users", "count", "truncated"}, map[string]*schema{
+					"users":     arrayOf(ref("User")),
+					"count":     scalar("integer"),
+					"truncated": scalar("boolean"),
+				})),
+				"400": errResponse("Missing q"),
+			},
+		}
+		docs["GET "+prefix+"/users/inactive"] = &operation{
+			Summary:    "List users not greeted since a time, including users never greeted",
+			Parameters: []parameter{{Name: "since", In: "query", Required: true, Schema: scalar("string")}},
+			Responses: map[string]response{
+				"200": jsonResponse("Inactive users", object([]string{"since", "users", "count"}, map[string]*schema{
+					"since": {Type: "string", Format: "date-time"},
+					"users": arrayOf(ref("User")),
+					"count": scalar("integer"),
+				})),
+				"400": errResponse("Missing since, or not an RFC 3339 time or YYYY-MM-DD date"),
+			},
+		}
+		docs["GET "+prefix+"/users/domains"] = &operation{
+			Summary: "Count users per email domain, most common first",
+			Responses: map[string]response{
+				"200": jsonResponse("Counts", object([]string{"domains"}, map[string]*schema{
+					"domains": arrayOf(object([]string{"domain", "count"}, map[string]*schema{
+						"domain": scalar("string"),
+						"count":  scalar("integer"),
+					})),
+				})),
+			},
+		}
+		docs["GET "+prefix+"/users/verify"] = &operation{
+			Summary:    "Confirm a user's email with the token issued when they were created",
+			Parameters: []parameter{{Name: "token", In: "query", Required: true, Schema: scalar("string")}},
+			Responses: map[string]response{
+				"204": {Description: "Verified"},
+				"400": errResponse("Missing token"),
+				"404": errResponse("Unknown or already used token"),
+				"410": errResponse("Token expired"),
+			},
+		}
+		docs["GET "+prefix+"/users/{email}"] = &operation{
+			Summary:    "Get a user by email",
+			Parameters: []parameter{pathParam("email")},
+			Responses: map[string]response{
+				"200": jsonResponse("User; the ETag header carries its version", ref("User")),
+				"304": {Description: "If-None-Match matches the user's ETag"},
+				"404": errResponse("No such user"),
+				"410": errResponse("User was deleted"),
+			},
+		}
+		docs["GET "+prefix+"/users/{email}/greetings"] = &operation{
+			Summary:    "How often a user was greeted and when last",
+			Parameters: []parameter{pathParam("email")},
+			Responses: map[string]response{
+				"200": jsonResponse("Greeting history; last_greeted_at is absent until the first greeting", object([]string{"email", "greet_count"}, map[string]*schema{
+					"email":           scalar("string"),
+					"greet_count":     scalar("integer"),
+					"last_greeted_at": {Type: "string", Format: "date-time"},
+				})),
+				"404": errResponse("No such user"),
+				"410": errResponse("User was deleted"),
+			},
+		}
+		docs["PUT "+prefix+"/users/{email}"] = &operation{
+			Summary:     "Change a user's email (admin only); honours If-Match",
+			Parameters:  []parameter{pathParam("email"), {Name: "If-Match", In: "header", Schema: scalar("string")}},
+			RequestBody: jsonBody(object([]string{"email"}, map[string]*schema{"email": {Type: "string", Format: "email"}})),
+			Responses: map[string]response{
+				"200": jsonResponse("Updated user", ref("User")),
+				"401": errResponse("Authentication required"),
+				"403": errResponse("Not an admin"),
+				"404": errResponse("No such user"),
+				"412": errResponse("The user changed since the ETag was read"),
+				"415": errResponse("Content-Type is not application/json"),
+			},
+		}
+		docs["DELETE "+prefix+"/users/{email}"] = &operation{
+			Summary:    "Soft-delete a user (admin only); restorable until purged",
+			Parameters: []parameter{pathParam("email")},
+			Responses: map[string]response{
+				"204": {Description: "Deleted"},
+				"401": errResponse("Authentication required"),
+				"403": errResponse("Not an admin"),
+				"404": errResponse("No such user"),
+				"410": errResponse("User already deleted"),
+			},
+		}
+		docs["POST "+prefix+"/users/{email}/restore"] = &operation{
+			Summary:    "Restore a soft-deleted user (admin only)",
+			Parameters: []parameter{pathParam("email")},
+			Responses: map[string]response{
+				"200": jsonResponse("Restored user", ref("User")),
+				"401": errResponse("Authentication required"),
+				"403": errResponse("Not an admin"),
+				"404": errResponse("No deleted user with that email"),
+				"409": errResponse("User is not deleted, or its name or email was reused"),
+			},
+		}
+		docs["POST "+prefix+"/logout"] = &operation{
+			Summary:   "Forget the remembered user",
+			Responses: map[string]response{"204": {Description: "Session cookie cleared"}},
+		}
+	}
+	return docs
+}
+
+// optionalRoutes are documented but only registered behind a flag, so their
+// absence does not make the document stale.
+var optionalRoutes = []string{"POST /debug/echo"}
+
+// buildOpenAPI documents patterns. It fails if a pattern has no
+// documentation or documentation names a pattern that is not registered.
+func buildOpenAPI(patterns []string) (*openAPIDoc, error) {
+	docs := routeDocs()
+	doc := &openAPIDoc{
+		OpenAPI:    "3.0.3",
+		Info:       openAPIInfo{Title: "go-http", Version: version},
+		Paths:      map[string]map[string]*operation{},
+		Components: openAPIComponents{Schemas: openAPISchemas},
+	}
+
+	var undocumented []string
+	for _, pattern := range patterns {
+		op, ok := docs[pattern]
+		if !ok {
+			undocumented = append(undocumented, pattern)
+			continue
+		}
+		delete(docs, pattern)
+
+		method, path, found := strings.Cut(pattern, " ")
+		if !found {
+			method, path = http.MethodGet, pattern
+		}
+		path = strings.TrimSuffix(path, "{$}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*operation{}
+		}
+		doc.Paths[path][strings.ToLower(method)] = op
+	}
+
+	for _, pattern := range optionalRoutes {
+		delete(docs, pattern)
+	}
+	if len(undocumented) > 0 || len(docs) > 0 {
+		stale := make([]string, 0, len(docs))
+		for pattern := range docs {
+			stale = append(stale, pattern)
+		}
+		slices.Sort(stale)
+		return nil, fmt.Errorf("OpenAPI document out of date: undocumented routes %q, documented but unregistered %q", undocumented, stale)
+	}
+	return doc, nil
+}
+
+//go:embed docs.html
+var docsFS embed.FS
+
+// handleDocs serves a Swagger UI page for /openapi.json. The page is
+// embedded; the Swagger UI assets themselves are loaded from a CDN.
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, docsFS, "docs.html")
+}
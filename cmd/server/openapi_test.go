@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestServer(t).Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("error decoding document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("bad openapi version: %q", doc.OpenAPI)
+	}
+
+	for _, tc := range []struct{ path, method string }{
+		{"/", "get"},
+		{"/goodbye", "get"},
+		{"/hello/", "get"},
+		{"/responses/{user}/hello/", "get"},
+		{"/user/hello", "get"},
+		{"/json", "post"},
+		{"/users", "get"},
+		{"/users", "post"},
+		{"/users/{email}", "get"},
+		{"/users/{email}", "put"},
+		{"/users/{email}", "delete"},
+		{"/api/v1/hello", "get"},
+		{"/api/v1/hello/{user}", "get"},
+		{"/api/v1/users", "post"},
+		{"/api/v1/users/{first}/{last}", "get"},
+	} {
+		if doc.Paths[tc.path][tc.method] == nil {
+			t.Errorf("missing %s %s", strings.ToUpper(tc.method), tc.path)
+		}
+	}
+
+	newUser := doc.Components.Schemas["NewUser"]
+	if newUser == nil || !slices.Equal(newUser.Required, []string{"first_name", "last_name", "email"}) {
+		t.Errorf("bad NewUser schema: %+v", newUser)
+	}
+	create := doc.Paths["/users"]["post"]
+	if create == nil || create.RequestBody == nil || !create.RequestBody.Required ||
+		create.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/NewUser" {
+		t.Errorf("bad POST /users request body: %+v", create)
+	}
+	if greet := doc.Paths["/json"]["post"]; greet == nil || !slices.Contains(greet.RequestBody.Content["application/json"].Schema.Required, "first_name") {
+		t.Errorf("POST /json does not require first_name: %+v", greet)
+	}
+}
+
+// TestOpenAPIPropertiesAreSnakeCase holds every documented JSON body to the
+// API's snake_case style. The document's own keys, such as requestBody, are
+// named by the OpenAPI specification and are not properties.
+func TestOpenAPIPropertiesAreSnakeCase(t *testing.T) {
+	snakeCase := regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+	var walk func(where string, sc *schema)
+	walk = func(where string, sc *schema) {
+		if sc == nil {
+			return
+		}
+		for name, prop := range sc.Properties {
+			if !snakeCase.MatchString(name) {
+				t.Errorf("%s: property %q is not snake_case", where, name)
+			}
+			walk(where+"."+name, prop)
+		}
+		walk(where+"[]", sc.Items)
+	}
+
+	w := httptest.NewRecorder()
+	newTestServer(t).Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc openAPIDoc
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("error decoding document: %v", err)
+	}
+	for name, sc := range doc.Components.Schemas {
+		walk(name, sc)
+	}
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			where := strings.ToUpper(method) + " " + path
+			if op.RequestBody != nil {
+				for _, mt := range op.RequestBody.Content {
+					walk(where, mt.Schema)
+				}
+			}
+			for code, resp := range op.Responses {
+				for _, mt := range resp.Content {
+					walk(where+" "+code, mt.Schema)
+				}
+			}
+		}
+	}
+}
+
+func TestBuildOpenAPIRejectsDrift(t *testing.T) {
+	if _, err := buildOpenAPI([]string{"GET /health", "GET /undocumented"}); err == nil ||
+		!strings.Contains(err.Error(), "/undocumented") || !strings.Contains(err.Error(), "GET /version") {
+		t.Errorf("expected undocumented and unregistered routes to be reported, got %v", err)
+	}
+}
+
+func TestDocsPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestServer(t).Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("bad content type: %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "/openapi.json") {
+		t.Errorf("docs page does not load /openapi.json")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// Trailing-slash policies. Under trailingSlashKeep a path is served with or
+// without its trailing slash and neither form is redirected.
+const (
+	trailingSlashKeep  = "keep"
+	trailingSlashStrip = "strip"
+	trailingSlashAdd   = "add"
+)
+
+// routeHandler marks the handlers Routes registers, so mux.Handler can tell
+// a real match from the mux's own redirect and error handlers.
+type routeHandler struct {
+	http.Handler
+}
+
+// routed reports whether r reaches a registered route.
+func routed(mux *http.ServeMux, r *http.Request) bool {
+	h, _ := mux.Handler(r)
+	_, ok := h.(*routeHandler)
+	return ok
+}
+
+// canonicalPath returns the canonical form of an escaped path under policy:
+// duplicate slashes collapsed, dot segments resolved, and the trailing
+// slash stripped, added or kept.
+func canonicalPath(escaped string, policy string) string {
+	clean := path.Clean(escaped)
+	if clean == "/" {
+		return clean
+	}
+	switch policy {
+	case trailingSlashStrip:
+		return clean
+	case trailingSlashAdd:
+		return clean + "/"
+	}
+	if strings.HasSuffix(escaped, "/") {
+		return clean + "/"
+	}
+	return clean
+}
+
+// encodedDotSegment reports whether a segment of an escaped path decodes to
+// "." or "..", which path cleaning would not see.
+func encodedDotSegment(escaped string) bool {
+	for segment := range strings.SplitSeq(escaped, "/") {
+		if !strings.Contains(segment, "%") {
+			continue
+		}
+		if decoded, err := url.PathUnescape(segment); err == nil && (decoded == "." || decoded == "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// withPathNormalization gives every resource one path before routing. A
+// request for a non-canonical path is redirected with 308, which keeps the
+// method and body, and one with an encoded dot segment such as %2e%2e is
+// rejected with 400. A canonical path the mux only knows with the other
+// trailing slash is served by that route without a redirect, so the route
+// patterns do not have to follow the policy.
+func (s *Server) withPathNormalization(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		escaped := r.URL.EscapedPath()
+		if !strings.HasPrefix(escaped, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if encodedDotSegment(escaped) {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "path must not contain encoded dot segments")
+			return
+		}
+
+		if canonical := canonicalPath(escaped, s.trailingSlash); canonical != escaped {
+			target := canonical
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+			return
+		}
+
+		if escaped != "/" && !routed(mux, r) {
+			alt := r.WithContext(r.Context())
+			u := *r.URL
+			alt.URL = &u
+			if strings.HasSuffix(escaped, "/") {
+				alt.URL.Path = strings.TrimSuffix(r.URL.Path, "/")
+				alt.URL.RawPath = strings.TrimSuffix(r.URL.RawPath, "/")
+			} else {
+				alt.URL.Path = r.URL.Path + "/"
+				if r.URL.RawPath != "" {
+					alt.URL.RawPath = r.URL.RawPath + "/"
+				}
+			}
+			if routed(mux, alt) {
+				r = alt
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
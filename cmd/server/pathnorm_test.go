@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPathNormalization(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		method   string
+		target   string
+		status   int
+		location string
+		want     string
+	}{
+		{"keep serves hello without slash", trailingSlashKeep, http.MethodGet, "/hello?user=alice", http.StatusOK, "", "Hello alice!\n"},
+		{"keep serves hello with slash", trailingSlashKeep, http.MethodGet, "/hello/?user=alice", http.StatusOK, "", "Hello alice!\n"},
+		{"keep serves path value without slash", trailingSlashKeep, http.MethodGet, "/responses/alice/hello", http.StatusOK, "", "Hello alice!\n"},
+		{"keep serves goodbye with slash", trailingSlashKeep, http.MethodGet, "/goodbye/", http.StatusOK, "", "Goodbye world is served at goodbye\n"},
+		{"keep leaves unknown paths alone", trailingSlashKeep, http.MethodGet, "/nope/", http.StatusNotFound, "", ""},
+
+		{"strip redirects", trailingSlashStrip, http.MethodGet, "/hello/?user=alice", http.StatusPermanentRedirect, "/hello?user=alice", ""},
+		{"strip redirects path value", trailingSlashStrip, http.MethodGet, "/responses/alice/hello/", http.StatusPermanentRedirect, "/responses/alice/hello", ""},
+		{"strip serves canonical", trailingSlashStrip, http.MethodGet, "/responses/alice/hello", http.StatusOK, "", "Hello alice!\n"},
+		{"strip keeps root", trailingSlashStrip, http.MethodGet, "/", http.StatusOK, "", ""},
+
+		{"add redirects", trailingSlashAdd, http.MethodGet, "/goodbye", http.StatusPermanentRedirect, "/goodbye/", ""},
+		{"add serves canonical", trailingSlashAdd, http.MethodGet, "/goodbye/", http.StatusOK, "", "Goodbye world is served at goodbye\n"},
+		{"add serves canonical hello", trailingSlashAdd, http.MethodGet, "/hello/?user=alice", http.StatusOK, "", "Hello alice!\n"},
+
+		{"duplicate slashes collapsed", trailingSlashKeep, http.MethodGet, "//hello//?user=alice", http.StatusPermanentRedirect, "/hello/?user=alice", ""},
+		{"zero width space kept", trailingSlashKeep, http.MethodGet, "//hello//​", http.StatusPermanentRedirect, "/hello/%E2%80%8B", ""},
+		{"zero width space stripped form", trailingSlashStrip, http.MethodGet, "//hello//%E2%80%8B/", http.StatusPermanentRedirect, "/hello/%E2%80%8B", ""},
+		{"dot segments resolved", trailingSlashKeep, http.MethodGet, "/users/./../health", http.StatusPermanentRedirect, "/health", ""},
+		{"encoded slash kept escaped", trailingSlashKeep, http.MethodGet, "//responses/a%2Fb/hello/", http.StatusPermanentRedirect, "/responses/a%2Fb/hello/", ""},
+
+		{"encoded traversal rejected", trailingSlashKeep, http.MethodGet, "/hello/%2e%2e/users", http.StatusBadRequest, "", ""},
+		{"mixed encoded traversal rejected", trailingSlashKeep, http.MethodGet, "/hello/.%2E/users", http.StatusBadRequest, "", ""},
+		{"encoded dot rejected", trailingSlashKeep, http.MethodGet, "/%2e/health", http.StatusBadRequest, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.trailingSlash = tt.policy
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			r.Header.Set("Accept", "text/plain")
+			w := httptest.NewRecorder()
+			s.Routes().ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("bad response code: expected %d, got %d\nbody: %s", tt.status, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("bad Location: expected %q, got %q", tt.location, got)
+			}
+			if tt.want != "" && w.Body.String() != tt.want {
+				t.Errorf("bad response body: expected %q, got %q", tt.want, w.Body.String())
+			}
+			if tt.status == http.StatusBadRequest {
+				assertErrorCode(t, w, codeInvalidRequest, "")
+			}
+		})
+	}
+}
+
+func TestPathNormalizationRedirectKeepsBody(t *testing.T) {
+	s := newTestServer(t)
+	s.trailingSlash = trailingSlashStrip
+	srv := httptest.NewServer(s.Routes())
+	defer srv.Close()
+
+	var redirects []string
+	client := &http.Client{CheckRedirect: func(r *http.Request, via []*http.Request) error {
+		redirects = append(redirects, r.Method+" "+r.URL.Path)
+		return nil
+	}}
+	resp, err := client.Post(srv.URL+"/json/", "application/json", strings.NewReader(`{"FirstName":"alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if len(redirects) != 1 || redirects[0] != "POST /json" {
+		t.Errorf("expected one redirect to POST /json, got %v", redirects)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != "Hello alice!\n" {
+		t.Errorf("expected 200 Hello alice!, got %d %q", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// snapshotSaveTimeout bounds the final save on shutdown.
+const snapshotSaveTimeout = 30 * time.Second
+
+// autosave is the worker task that saves the manager's snapshot to path.
+// The Runner logs failures and the next tick retries; the final save on
+// shutdown is left to the caller so it runs after requests have drained.
+func autosave(manager *users.Manager, path string) func(context.Context) error {
+	return func(context.Context) error {
+		return manager.SaveSnapshotFile(path)
+	}
+}
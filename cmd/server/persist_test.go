@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+	"github.com/kunalkumar-1/go-http/internal/worker"
+)
+
+func TestAutosave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.snapshot")
+	manager := users.NewManager()
+	if err := manager.AddUser(context.Background(), "jhon", "smith", "foo@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	runner := worker.NewRunner(worker.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	go func() {
+		runner.Run(ctx, "autosave", 10*time.Millisecond, autosave(manager, path))
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	restored := users.NewManager()
+	for restored.LoadSnapshotFile(path) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("autosave never wrote a snapshot")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if _, err := restored.GetUserByEmail(context.Background(), "foo@bar.com"); err != nil {
+		t.Errorf("autosaved snapshot missing user: %v", err)
+	}
+}
+
+func TestHealthReportsWorkers(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.Routes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := make(chan struct{}, 1)
+	go s.workers.Run(ctx, "probe", time.Millisecond, func(context.Context) error {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		return errors.New("still failing")
+	})
+	<-ran
+
+	var health healthResponse
+	deadline := time.Now().Add(5 * time.Second)
+	for len(health.Workers) == 0 || health.Workers[0].Runs == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("worker never reported: %+v", health)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		health = healthResponse{}
+		if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if st := health.Workers[0]; health.Status != "ok" || st.Name != "probe" || st.LastError != "still failing" || st.LastRun.IsZero() {
+		t.Errorf("bad health: %+v", health)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// realIP resolves the address of the client behind any trusted proxies.
+// Forwarding headers are only believed when the immediate peer is trusted,
+// so a client connecting directly cannot spoof its address.
+type realIP struct {
+	trusted []netip.Prefix
+}
+
+func (ri *realIP) isTrusted(addr netip.Addr) bool {
+	for _, p := range ri.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the client address of r. When the peer is a trusted
+// proxy, the hops in Forwarded, or else X-Forwarded-For, are walked from
+// the right and the first untrusted one is the client. A malformed hop ends
+// the walk at the last address known to be good. The zero Addr means
+// RemoteAddr itself could not be parsed.
+func (ri *realIP) resolve(r *http.Request) netip.Addr {
+	client, ok := parseHop(r.RemoteAddr)
+	if !ok || !ri.isTrusted(client) {
+		return client
+	}
+
+	var hops []string
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		hops = forwardedFor(values)
+	} else {
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+		client = hop
+		if !ri.isTrusted(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedFor returns the for= parameter of every element of RFC 7239
+// Forwarded header values, in order. Elements without one yield "", which
+// parseHop rejects.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			hop := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(key, "for") {
+					hop = strings.Trim(value, `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// parseHop parses an address as it appears in RemoteAddr or a forwarding
+// header: bare, with a port, or bracketed IPv6 with or without a port.
+// Obfuscated identifiers such as "unknown" or "_hidden" are rejected.
+func parseHop(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+type clientIPKey struct{}
+
+// clientIPFrom returns the client address resolved by withRealIP, or the
+// zero Addr when there is none.
+func clientIPFrom(ctx context.Context) netip.Addr {
+	addr, _ := ctx.Value(clientIPKey{}).(netip.Addr)
+	return addr
+}
+
+// withRealIP stores the resolved client address in the request context for
+// logging and rate limiting. RemoteAddr is left untouched.
+func (s *Server) withRealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPKey{}, s.realIP.resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
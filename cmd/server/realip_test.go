@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/config"
+)
+
+func TestRealIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trusted   string
+		remote    string
+		xff       []string
+		forwarded []string
+		expected  string
+	}{
+		{name: "no proxy", remote: "203.0.113.7:5000", expected: "203.0.113.7"},
+		{name: "no trusted proxies ignores XFF", remote: "10.0.0.2:5000", xff: []string{"203.0.113.7"}, expected: "10.0.0.2"},
+		{name: "spoofed XFF from untrusted peer", trusted: "10.0.0.0/8", remote: "198.51.100.9:5000", xff: []string{"203.0.113.7"}, expected: "198.51.100.9"},
+		{name: "one trusted hop", trusted: "10.0.0.2", remote: "10.0.0.2:5000", xff: []string{"203.0.113.7"}, expected: "203.0.113.7"},
+		{name: "trusted peer without header", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", expected: "10.0.0.2"},
+		{name: "chained proxies", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", xff: []string{"203.0.113.7, 10.0.0.5, 10.0.0.6"}, expected: "203.0.113.7"},
+		{name: "client-supplied hops are skipped", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", xff: []string{"1.1.1.1, 203.0.113.7, 10.0.0.5"}, expected: "203.0.113.7"},
+		{name: "XFF split across lines", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", xff: []string{"1.1.1.1", "203.0.113.7, 10.0.0.5"}, expected: "203.0.113.7"},
+		{name: "every hop trusted", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", xff: []string{"10.0.0.9, 10.0.0.5"}, expected: "10.0.0.9"},
+		{name: "hop with port", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", xff: []string{"203.0.113.7:4711"}, expected: "203.0.113.7"},
+		{name: "malformed rightmost hop", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", xff: []string{"203.0.113.7, not-an-ip"}, expected: "10.0.0.2"},
+		{name: "malformed hop behind trusted hop", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", xff: []string{"garbage, 10.0.0.5"}, expected: "10.0.0.5"},
+		{name: "empty XFF", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", xff: []string{""}, expected: "10.0.0.2"},
+		{name: "IPv6 peer and client", trusted: "2001:db8::/32", remote: "[2001:db8::1]:443", xff: []string{"2a00:1450::5"}, expected: "2a00:1450::5"},
+		{name: "bracketed IPv6 hop", trusted: "2001:db8::/32", remote: "[2001:db8::1]:443", xff: []string{"[2a00:1450::5]"}, expected: "2a00:1450::5"},
+		{name: "IPv4-mapped peer", trusted: "10.0.0.0/8", remote: "[::ffff:10.0.0.2]:5000", xff: []string{"203.0.113.7"}, expected: "203.0.113.7"},
+		{name: "Forwarded", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", forwarded: []string{"for=192.0.2.60;proto=http;by=10.0.0.2"}, expected: "192.0.2.60"},
+		{name: "Forwarded chain", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", forwarded: []string{"for=1.1.1.1, for=192.0.2.60", "For=10.0.0.5"}, expected: "192.0.2.60"},
+		{name: "Forwarded IPv6 with port", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", forwarded: []string{`for="[2a00:1450::5]:4711"`}, expected: "2a00:1450::5"},
+		{name: "Forwarded obfuscated", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", forwarded: []string{"for=unknown"}, expected: "10.0.0.2"},
+		{name: "Forwarded wins over XFF", trusted: "10.0.0.0/8", remote: "10.0.0.2:5000", xff: []string{"198.51.100.1"}, forwarded: []string{"for=192.0.2.60"}, expected: "192.0.2.60"},
+		{name: "malformed RemoteAddr", remote: "somewhere", expected: "invalid IP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trusted, err := config.ParseTrustedProxies(tt.trusted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			for _, v := range tt.forwarded {
+				r.Header.Add("Forwarded", v)
+			}
+
+			got := (&realIP{trusted: trusted}).resolve(r)
+			if got.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRealIPInContext(t *testing.T) {
+	s := newTestServer(t)
+	trusted, err := config.ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.realIP = &realIP{trusted: trusted}
+
+	var got netip.Addr
+	handler := s.withRealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientIPFrom(r.Context())
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.2:5000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got != netip.MustParseAddr("203.0.113.7") {
+		t.Errorf("expected 203.0.113.7 in the context, got %s", got)
+	}
+	if r.RemoteAddr != "10.0.0.2:5000" {
+		t.Errorf("RemoteAddr changed: %s", r.RemoteAddr)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// withRecovery turns a panicking handler into a 500, logging the panic
+// with the request, its headers redacted, and the stack. The response is
+// left alone if it had already started. http.ErrAbortHandler is passed on,
+// since it is how a handler asks net/http to abort the response.
+func (s *Server) withRecovery(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w = httpx.Wrap(w, r, s.logger)
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			s.logger.Error("panic serving request",
+				"panic", p,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"client", clientIPFrom(r.Context()),
+				"headers", s.redact.Header(r.Header),
+				"stack", string(debug.Stack()),
+			)
+			if !httpx.HeaderSent(w) {
+				w.Header().Set("Connection", "close")
+				httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "internal server error")
+			}
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
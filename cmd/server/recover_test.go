@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewServer(slog.New(slog.NewTextHandler(&buf, nil)), users.NewManager())
+	h := s.middleware(groupPublic).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/hello/", nil)
+	r.Header.Set("Authorization", "Basic YWxpY2U6c2VjcmV0")
+	r.Header.Set("X-User", "alice")
+	r.Header.Set("X-Request-Id", "req-42")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("bad response code: expected %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	assertErrorCode(t, w, codeInternal, "internal server error")
+
+	out := buf.String()
+	for _, want := range []string{"panic serving request", "panic=boom", "Authorization:[Basic YW…(16)]", "X-User:[al…(5)]", "X-Request-Id:[req-42]", "recover.go"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in the log:\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{"YWxpY2U6c2VjcmV0", "alice"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%q logged in plaintext:\n%s", secret, out)
+		}
+	}
+}
+
+func TestRecoveryAfterResponseStarted(t *testing.T) {
+	s := newTestServer(t)
+	h := s.withRecovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("late")
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusAccepted || w.Body.Len() != 0 {
+		t.Errorf("started response was changed: %d %q", w.Code, w.Body.String())
+	}
+
+	abort := s.withRecovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be passed on, got %v", p)
+		}
+	}()
+	abort.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/i18n"
+)
+
+// greeterSource is where the greeter's configuration is read from, at
+// startup and again on each reload.
+type greeterSource struct {
+	// localesDir holds the <tag>.json locale bundles; empty uses only the
+	// built-in translations.
+	localesDir string
+
+	// template is the default greeting template, or templateFile the file
+	// holding it. Both empty keep the built-in template.
+	template     string
+	templateFile string
+}
+
+// load reads a fresh catalog and the default greeting template. Neither is
+// validated against the other; greeting.Greeter.Reload does that.
+func (src greeterSource) load() (*i18n.Catalog, string, error) {
+	catalog, err := i18n.NewCatalog(src.localesDir)
+	if err != nil {
+		return nil, "", err
+	}
+	text := src.template
+	if src.templateFile != "" {
+		data, err := os.ReadFile(src.templateFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("error reading greeting template: %w", err)
+		}
+		text = string(data)
+	}
+	return catalog, text, nil
+}
+
+// loadGreeter builds the server's greeter from its greeterSource, at
+// startup.
+func (s *Server) loadGreeter() error {
+	catalog, text, err := s.greeterSource.load()
+	if err != nil {
+		return fmt.Errorf("error loading greeting configuration: %w", err)
+	}
+	s.greeter, err = s.newGreeter(greeting.WithCatalog(catalog), greeting.WithTemplate(text))
+	return err
+}
+
+// reloadGreeter re-reads the locale bundles and the greeting template and
+// swaps them into the greeter together. If either is invalid the error is
+// logged and returned, and the greeter keeps its current configuration.
+func (s *Server) reloadGreeter() error {
+	catalog, text, err := s.greeterSource.load()
+	if err == nil {
+		err = s.greeter.Reload(catalog, text)
+	}
+	if err != nil {
+		s.logger.Error("keeping previous greeting configuration", "err", err)
+		return err
+	}
+	s.logger.Info("reloaded greeting configuration")
+	return nil
+}
+
+// reloadOnHangup reloads the greeter each time hup delivers a signal.
+func (s *Server) reloadOnHangup(hup <-chan os.Signal) {
+	for range hup {
+		s.reloadGreeter()
+	}
+}
+
+// handleReload is POST /admin/reload, the SIGHUP reload for operators who
+// cannot signal the process. A rejected configuration is a 422.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := s.reloadGreeter(); err != nil {
+		httpx.WriteError(w, http.StatusUnprocessableEntity, codeValidation, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// reloadTestServer returns a server whose greeter is loaded from a template
+// file and a locale directory the test can rewrite.
+func reloadTestServer(t *testing.T) (s *Server, templateFile string, localesDir string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	templateFile = filepath.Join(dir, "greeting.tmpl")
+	localesDir = filepath.Join(dir, "locales")
+	if err := os.Mkdir(localesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, templateFile, "Hi {{.Name}}")
+
+	s = newTestServer(t)
+	s.greeterSource = greeterSource{localesDir: localesDir, templateFile: templateFile}
+	if err := s.reloadGreeter(); err != nil {
+		t.Fatal(err)
+	}
+	return s, templateFile, localesDir
+}
+
+func writeFile(t *testing.T, path string, data string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func greet(t *testing.T, handler http.Handler, acceptLanguage string) string {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/hello?user=bob", nil)
+	r.Header.Set("Accept", "text/plain")
+	r.Header.Set("Accept-Language", acceptLanguage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return strings.TrimSpace(w.Body.String())
+}
+
+func TestAdminReload(t *testing.T) {
+	s, templateFile, localesDir := reloadTestServer(t)
+	handler := s.Routes()
+	admin := s.AdminRoutes()
+
+	if got := greet(t, handler, ""); got != "Hi bob" {
+		t.Fatalf("bad greeting before reload: %q", got)
+	}
+
+	writeFile(t, templateFile, "Howdy {{.Name}}")
+	writeFile(t, filepath.Join(localesDir, "fr.json"), `{"greeting": "Salut {{.Name}}"}`)
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if got := greet(t, handler, ""); got != "Howdy bob" {
+		t.Errorf("template not reloaded: %q", got)
+	}
+	if got := greet(t, handler, "fr"); got != "Salut bob" {
+		t.Errorf("locale bundle not reloaded: %q", got)
+	}
+
+	// A bad template keeps the bundles it came with out too.
+	writeFile(t, templateFile, "Hello {{.Username}}!")
+	writeFile(t, filepath.Join(localesDir, "fr.json"), `{"greeting": "Bonjour {{.Name}}"}`)
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeValidation, "")
+	if got := greet(t, handler, ""); got != "Howdy bob" {
+		t.Errorf("rejected reload changed the template: %q", got)
+	}
+	if got := greet(t, handler, "fr"); got != "Salut bob" {
+		t.Errorf("rejected reload changed the locale bundle: %q", got)
+	}
+
+	os.Remove(templateFile)
+	if err := s.reloadGreeter(); err == nil {
+		t.Error("no error returned for a missing template file")
+	}
+}
+
+func TestReloadOnHangup(t *testing.T) {
+	s, templateFile, _ := reloadTestServer(t)
+	hup := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		s.reloadOnHangup(hup)
+		close(done)
+	}()
+
+	writeFile(t, templateFile, "Howdy {{.Name}}")
+	hup <- nil
+	close(hup)
+	<-done
+	if got, _ := s.greeter.Greet("bob"); got != "Howdy bob" {
+		t.Errorf("template not reloaded on hangup: %q", got)
+	}
+}
+
+func TestReloadWhileGreeting(t *testing.T) {
+	s, templateFile, localesDir := reloadTestServer(t)
+	handler := s.Routes()
+
+	// Each reload swaps the template and the bundle together, so the
+	// languages of a greeting always agree.
+	valid := map[string]bool{"Hi bob|Bonjour bob !": true, "Howdy bob|Salut bob": true}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 100 {
+				en := greet(t, handler, "")
+				fr := greet(t, handler, "fr")
+				if en != "Hi bob" && en != "Howdy bob" || fr != "Bonjour bob !" && fr != "Salut bob" {
+					t.Errorf("greeting during reload: %q, %q", en, fr)
+					return
+				}
+			}
+		})
+	}
+
+	for i := range 50 {
+		switch i % 3 {
+		case 0:
+			writeFile(t, templateFile, "Howdy {{.Name}}")
+			writeFile(t, filepath.Join(localesDir, "fr.json"), `{"greeting": "Salut {{.Name}}"}`)
+		case 1:
+			writeFile(t, templateFile, "Hi {{.Name}}")
+			os.Remove(filepath.Join(localesDir, "fr.json"))
+		case 2:
+			writeFile(t, templateFile, "Hello {{.Username}}!")
+		}
+		s.reloadGreeter()
+
+		got := greet(t, handler, "") + "|" + greet(t, handler, "fr")
+		if !valid[got] {
+			t.Errorf("reload %d left a mixed configuration: %s", i, got)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,14 @@
+package main
+
+import "encoding/json"
+
+// jsonList is the canonical type for JSON array fields. It encodes a nil
+// slice as [] so clients never have to handle null in place of a list.
+type jsonList[T any] []T
+
+func (l jsonList[T]) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]T(l))
+}
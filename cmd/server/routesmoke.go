@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/config"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// smokeRequestTimeout bounds each smoke check, which leaves time for the
+// pprof profile.
+const smokeRequestTimeout = 5 * time.Second
+
+// smokeResult is the outcome of one route's smoke check.
+type smokeResult struct {
+	Route  routeEntry
+	Method string
+	Path   string
+	Want   int
+	// Got is the status received, or 0 if there was none.
+	Got int
+	Err error
+}
+
+func (r smokeResult) ok() bool {
+	return r.Err == nil && r.Got == r.Want
+}
+
+// runSmoke is the smoke subcommand, for deploy jobs to run on a new
+// instance before traffic is switched to it. It reads the same flags, file
+// and environment as run, but serves both listeners on ephemeral loopback
+// ports with an empty store, sends every route its smoke check, writes a
+// pass/fail table to stdout and returns an error if any route failed.
+func runSmoke(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := flag.NewFlagSet("server smoke", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg, err := config.Load(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("%w:\n%w", errUsage, err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := newServerFromConfig(cfg, logger, users.NewManager(managerOptions(cfg)...))
+	if err := srv.loadGreeter(); err != nil {
+		return err
+	}
+	if err := seedSmoke(srv.users); err != nil {
+		return err
+	}
+	routes, err := srv.routeTable()
+	if err != nil {
+		return err
+	}
+
+	public, err := listen("public", srv.HTTPServer("127.0.0.1:0"), nil, "", "")
+	if err != nil {
+		return err
+	}
+	admin, err := listen("admin", srv.AdminHTTPServer("127.0.0.1:0"), nil, "", "")
+	if err != nil {
+		public.ln.Close()
+		return err
+	}
+	for _, l := range []listener{public, admin} {
+		go l.serve()
+	}
+	defer stopListeners(public, admin)(context.WithoutCancel(ctx))
+
+	results := smokeRoutes(ctx, &http.Client{}, map[string]string{
+		"public": "http://" + public.ln.Addr().String(),
+		"admin":  "http://" + admin.ln.Addr().String(),
+	}, routes)
+	if err := writeSmokeTable(stdout, results); err != nil {
+		return err
+	}
+	var failed int
+	for _, r := range results {
+		if !r.ok() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("smoke test failed: %d of %d routes", failed, len(results))
+	}
+	return nil
+}
+
+// seedSmoke adds smokeUser to m.
+func seedSmoke(m *users.Manager) error {
+	if err := m.AddUser(context.Background(), smokeUser[0], smokeUser[1], smokeUser[2]); err != nil {
+		return fmt.Errorf("error adding smoke test user: %w", err)
+	}
+	return nil
+}
+
+// smokeRoutes sends each route its smoke check, in order, on the listener
+// it is registered on; bases maps listener names to base URLs. A route
+// without a check fails.
+func smokeRoutes(ctx context.Context, client *http.Client, bases map[string]string, routes []routeEntry) []smokeResult {
+	results := make([]smokeResult, len(routes))
+	for i, route := range routes {
+		check, declared := smokeChecks[route.Pattern]
+		method, path := smokeTarget(route.Pattern, check)
+		results[i] = smokeResult{Route: route, Method: method, Path: path, Want: check.Status}
+		if !declared {
+			results[i].Err = errors.New("no smoke check declared")
+			continue
+		}
+		results[i].Got, results[i].Err = smokeOnce(ctx, client, bases[route.Listener], method, path, check)
+	}
+	return results
+}
+
+// smokeTarget returns the method and path check is sent with to pattern.
+func smokeTarget(pattern string, check smokeCheck) (method string, path string) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = http.MethodGet, pattern
+	}
+	if check.Method != "" {
+		method = check.Method
+	}
+	if check.Path != "" {
+		path = check.Path
+	}
+	return method, path
+}
+
+func smokeOnce(ctx context.Context, client *http.Client, base string, method string, path string, check smokeCheck) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, smokeRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, base+path, strings.NewReader(check.Body))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range check.Header {
+		req.Header[k] = v
+	}
+	if check.Body != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, err
+}
+
+// writeSmokeTable writes results as an aligned text table.
+func writeSmokeTable(w io.Writer, results []smokeResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESULT\tLISTENER\tPATTERN\tREQUEST\tWANT\tGOT")
+	for _, r := range results {
+		result, got := "PASS", fmt.Sprint(r.Got)
+		if !r.ok() {
+			result = "FAIL"
+		}
+		if r.Err != nil {
+			got = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s %s\t%d\t%s\n", result, r.Route.Listener, r.Route.Pattern, r.Method, r.Path, r.Want, got)
+	}
+	return tw.Flush()
+}
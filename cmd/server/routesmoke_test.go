@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSmokeRoutes(t *testing.T) {
+	s := newTestServer(t)
+	s.enablePprof = true
+	s.enableDebugEndpoints = true
+	if err := seedSmoke(s.users); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := s.routeTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := httptest.NewServer(s.Routes())
+	t.Cleanup(public.Close)
+	admin := httptest.NewServer(s.AdminRoutes())
+	t.Cleanup(admin.Close)
+
+	results := smokeRoutes(context.Background(), public.Client(), map[string]string{"public": public.URL, "admin": admin.URL}, routes)
+	for _, r := range results {
+		if !r.ok() {
+			t.Errorf("%s: %s %s: expected %d, got %d, %v", r.Route.Pattern, r.Method, r.Path, r.Want, r.Got, r.Err)
+		}
+	}
+
+	// With every option on, each check belongs to a registered route.
+	registered := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		registered[r.Pattern] = true
+	}
+	for pattern := range smokeChecks {
+		if !registered[pattern] {
+			t.Errorf("smoke check for unregistered route %q", pattern)
+		}
+	}
+}
+
+func TestSmokeRoutesFailures(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("GET /goodbye", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	routes := []routeEntry{
+		{Listener: "public", Pattern: "GET /health"},
+		{Listener: "public", Pattern: "/goodbye"},
+		{Listener: "public", Pattern: "GET /undeclared"},
+	}
+	results := smokeRoutes(context.Background(), srv.Client(), map[string]string{"public": srv.URL}, routes)
+	if r := results[0]; r.ok() || r.Got != http.StatusInternalServerError || r.Err != nil {
+		t.Errorf("broken handler: expected a failed 500, got %+v", r)
+	}
+	if r := results[1]; !r.ok() || r.Method != http.MethodGet || r.Path != "/goodbye" {
+		t.Errorf("working handler: expected a passing GET /goodbye, got %+v", r)
+	}
+	if r := results[2]; r.ok() || r.Err == nil || r.Got != 0 {
+		t.Errorf("undeclared route: expected a failure without a request, got %+v", r)
+	}
+
+	var table strings.Builder
+	if err := writeSmokeTable(&table, results); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(table.String(), "FAIL"); n != 2 {
+		t.Errorf("expected 2 failures in the table, got %d:\n%s", n, table.String())
+	}
+	if !strings.Contains(table.String(), "no smoke check declared") {
+		t.Errorf("table does not explain the undeclared route:\n%s", table.String())
+	}
+}
+
+func TestRunSmoke(t *testing.T) {
+	var stdout strings.Builder
+	if err := run(context.Background(), []string{"smoke"}, &stdout, io.Discard); err != nil {
+		t.Fatalf("smoke failed: %v\n%s", err, stdout.String())
+	}
+	if strings.Contains(stdout.String(), "FAIL") || !strings.Contains(stdout.String(), "GET /metrics") {
+		t.Errorf("unexpected smoke table:\n%s", stdout.String())
+	}
+}
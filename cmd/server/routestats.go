@@ -0,0 +1,254 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultSlowRequest is the default slow request threshold, and
+// defaultSlowStreamRequest that of groupStream, whose exports can
+// legitimately take seconds.
+const (
+	defaultSlowRequest       = 500 * time.Millisecond
+	defaultSlowStreamRequest = 10 * time.Second
+)
+
+// routeLatencySamples is the size of each route's latency reservoir. It
+// bounds the memory the collector uses however much traffic it sees.
+const routeLatencySamples = 1024
+
+// latencyReservoir keeps a uniform random sample of at most
+// routeLatencySamples latencies (Vitter's algorithm R), from which it
+// estimates percentiles. It is not safe for concurrent use.
+type latencyReservoir struct {
+	samples []time.Duration
+	seen    uint64
+	rng     *rand.Rand
+}
+
+func newLatencyReservoir() *latencyReservoir {
+	// A fixed seed keeps the estimates reproducible; the sample only needs
+	// to be unbiased, not unpredictable.
+	return &latencyReservoir{rng: rand.New(rand.NewPCG(1, 2))}
+}
+
+func (lr *latencyReservoir) observe(d time.Duration) {
+	lr.seen++
+	if len(lr.samples) < routeLatencySamples {
+		lr.samples = append(lr.samples, d)
+		return
+	}
+	if i := lr.rng.Uint64N(lr.seen); i < routeLatencySamples {
+		lr.samples[i] = d
+	}
+}
+
+// percentiles returns the nearest-rank estimate of each quantile q, in
+// (0, 1]. They are zero before the first observation.
+func (lr *latencyReservoir) percentiles(qs ...float64) []time.Duration {
+	out := make([]time.Duration, len(qs))
+	if len(lr.samples) == 0 {
+		return out
+	}
+	sorted := slices.Clone(lr.samples)
+	slices.Sort(sorted)
+	for i, q := range qs {
+		rank := int(math.Ceil(q * float64(len(sorted))))
+		out[i] = sorted[max(rank, 1)-1]
+	}
+	return out
+}
+
+// routeStats counts the requests served by one route.
+type routeStats struct {
+	mu           sync.Mutex
+	requests     uint64
+	clientErrors uint64
+	serverErrors uint64
+	slow         uint64
+	latency      *latencyReservoir
+}
+
+func (rs *routeStats) observe(status int, d time.Duration, slow bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.requests++
+	if slow {
+		rs.slow++
+	}
+	switch {
+	case status >= 500:
+		rs.serverErrors++
+	case status >= 400:
+		rs.clientErrors++
+	}
+	rs.latency.observe(d)
+}
+
+type latencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+type routeStat struct {
+	Route        string             `json:"route"`
+	Requests     uint64             `json:"requests"`
+	ClientErrors uint64             `json:"client_errors"`
+	ServerErrors uint64             `json:"server_errors"`
+	SlowRequests uint64             `json:"slow_requests"`
+	LatencyMs    latencyPercentiles `json:"latency_ms"`
+}
+
+func (rs *routeStats) stat(route string) routeStat {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	p := rs.latency.percentiles(0.50, 0.95, 0.99)
+	return routeStat{
+		Route:        route,
+		Requests:     rs.requests,
+		ClientErrors: rs.clientErrors,
+		ServerErrors: rs.serverErrors,
+		SlowRequests: rs.slow,
+		LatencyMs:    latencyPercentiles{P50: milliseconds(p[0]), P95: milliseconds(p[1]), P99: milliseconds(p[2])},
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// routeCollector holds the stats of every registered route. Routes are
+// keyed by their mux pattern, so the set is fixed when Routes runs.
+type routeCollector struct {
+	mu     sync.Mutex
+	routes map[string]*routeStats
+}
+
+func newRouteCollector() *routeCollector {
+	return &routeCollector{routes: make(map[string]*routeStats)}
+}
+
+// route returns the stats for pattern, creating them on first use.
+func (c *routeCollector) route(pattern string) *routeStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rs, ok := c.routes[pattern]
+	if !ok {
+		rs = &routeStats{latency: newLatencyReservoir()}
+		c.routes[pattern] = rs
+	}
+	return rs
+}
+
+// stats returns the routes that have served at least one request, sorted
+// by pattern.
+func (c *routeCollector) stats() []routeStat {
+	c.mu.Lock()
+	routes := make(map[string]*routeStats, len(c.routes))
+	for pattern, rs := range c.routes {
+		routes[pattern] = rs
+	}
+	c.mu.Unlock()
+
+	var out []routeStat
+	for pattern, rs := range routes {
+		if st := rs.stat(pattern); st.Requests > 0 {
+			out = append(out, st)
+		}
+	}
+	slices.SortFunc(out, func(a, b routeStat) int { return cmp.Compare(a.Route, b.Route) })
+	return out
+}
+
+// writeSlowRequests writes the http_slow_requests_total counter of every
+// route that has served a slow request.
+func (c *routeCollector) writeSlowRequests(w io.Writer) {
+	fmt.Fprintf(w, "# TYPE http_slow_requests_total counter\n")
+	for _, st := range c.stats() {
+		if st.SlowRequests > 0 {
+			fmt.Fprintf(w, "http_slow_requests_total{route=%q} %d\n", st.Route, st.SlowRequests)
+		}
+	}
+}
+
+// statusWriter records the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	// 1xx responses are informational; the real status is still to come.
+	if sw.status == 0 && code >= 200 {
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// slowRequestThreshold returns the slow request threshold of the routes in
+// group.
+func (s *Server) slowRequestThreshold(group string) time.Duration {
+	if d, ok := s.slowRequestGroups[group]; ok {
+		return d
+	}
+	return s.slowRequest
+}
+
+// withRouteStats records the status and latency of every request h serves
+// under pattern, and logs those slower than the threshold of group with the
+// time spent in each Server-Timing phase.
+func (s *Server) withRouteStats(pattern string, group string, h http.Handler) http.Handler {
+	rs := s.routeStats.route(pattern)
+	threshold := s.slowRequestThreshold(group)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var timing *serverTiming
+		if threshold > 0 {
+			r, timing = withTiming(r)
+		}
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		d := time.Since(start)
+		slow := threshold > 0 && d > threshold
+		rs.observe(status, d, slow)
+		if slow {
+			s.logger.Warn("slow request",
+				"route", pattern,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				"duration_ms", milliseconds(d),
+				"threshold", threshold,
+				"request_id", r.Header.Get("X-Request-Id"),
+				timing.logAttr(),
+			)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLatencyReservoirPercentiles(t *testing.T) {
+	lr := newLatencyReservoir()
+
+	// 1ms to 10000ms, each once, in shuffled order.
+	latencies := make([]time.Duration, 10000)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+	rand.New(rand.NewPCG(3, 4)).Shuffle(len(latencies), func(i, j int) {
+		latencies[i], latencies[j] = latencies[j], latencies[i]
+	})
+	for _, d := range latencies {
+		lr.observe(d)
+	}
+
+	if len(lr.samples) != routeLatencySamples {
+		t.Fatalf("reservoir grew to %d samples, expected %d", len(lr.samples), routeLatencySamples)
+	}
+	got := lr.percentiles(0.50, 0.95, 0.99)
+	for i, expected := range []float64{5000, 9500, 9900} {
+		// 5% of the range, several standard errors for a 1024-sample
+		// reservoir.
+		if ms := milliseconds(got[i]); math.Abs(ms-expected) > 500 {
+			t.Errorf("percentile %d: expected about %vms, got %vms", i, expected, ms)
+		}
+	}
+}
+
+func TestLatencyReservoirSmall(t *testing.T) {
+	lr := newLatencyReservoir()
+	if got := lr.percentiles(0.5); got[0] != 0 {
+		t.Errorf("expected 0 before any observation, got %v", got[0])
+	}
+
+	for _, ms := range []int{40, 10, 30, 20} {
+		lr.observe(time.Duration(ms) * time.Millisecond)
+	}
+	got := lr.percentiles(0.50, 0.99)
+	if got[0] != 20*time.Millisecond || got[1] != 40*time.Millisecond {
+		t.Errorf("expected p50 20ms and p99 40ms, got %v", got)
+	}
+}
+
+func TestRouteStatsConcurrent(t *testing.T) {
+	c := newRouteCollector()
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rs := c.route("GET /a")
+			status := []int{http.StatusOK, http.StatusNotFound, http.StatusInternalServerError}[g%3]
+			for i := range 500 {
+				rs.observe(status, time.Duration(i)*time.Microsecond, false)
+				if i%50 == 0 {
+					c.stats()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := c.stats()
+	if len(stats) != 1 {
+		t.Fatalf("expected one route, got %+v", stats)
+	}
+	if st := stats[0]; st.Requests != 4000 || st.ClientErrors != 1500 || st.ServerErrors != 1000 {
+		t.Errorf("bad counts: %+v", st)
+	}
+}
+
+func TestStatsRoutes(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.Routes()
+
+	for _, target := range []string{"/health", "/health", "/users/nobody@bar.com"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	stats := getStats(t, handler, "/stats")
+	byRoute := make(map[string]routeStat)
+	for _, st := range stats.Routes {
+		byRoute[st.Route] = st
+	}
+	if st := byRoute["GET /health"]; st.Requests != 2 || st.ClientErrors != 0 || st.ServerErrors != 0 {
+		t.Errorf("bad /health stats: %+v", st)
+	}
+	if st := byRoute["GET /users/{email}"]; st.Requests != 1 || st.ClientErrors != 1 {
+		t.Errorf("bad /users/{email} stats: %+v", st)
+	}
+	if _, ok := byRoute["GET /version"]; ok {
+		t.Error("routes without requests should be left out")
+	}
+}
+
+func TestSlowRequests(t *testing.T) {
+	var logs bytes.Buffer
+	s := newTestServer(t)
+	s.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	s.slowRequest = 50 * time.Millisecond
+	s.slowRequestGroups = map[string]time.Duration{groupStream: time.Hour}
+
+	sleep := func(d time.Duration) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timing := timingFrom(r.Context())
+			start := timing.start()
+			time.Sleep(d)
+			timing.end(phaseStore, start)
+			w.WriteHeader(http.StatusAccepted)
+		})
+	}
+	for _, h := range []http.Handler{
+		s.withRouteStats("GET /slow", groupAPI, sleep(100*time.Millisecond)),
+		s.withRouteStats("GET /fast", groupAPI, sleep(0)),
+		s.withRouteStats("GET /export", groupStream, sleep(100*time.Millisecond)),
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Request-Id", "req-1")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	var records []map[string]any
+	for line := range strings.Lines(logs.String()) {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record["msg"] == "slow request" {
+			records = append(records, record)
+		}
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected one slow request record, got %v", records)
+	}
+	record := records[0]
+	if record["level"] != "WARN" || record["route"] != "GET /slow" || record["status"] != float64(http.StatusAccepted) || record["request_id"] != "req-1" {
+		t.Errorf("bad slow request record: %v", record)
+	}
+	if d, _ := record["duration_ms"].(float64); d < 100 {
+		t.Errorf("bad duration: %v", record["duration_ms"])
+	}
+	phases, _ := record["phases_ms"].(map[string]any)
+	if d, _ := phases[phaseStore].(float64); d < 100 {
+		t.Errorf("bad %s phase: %v", phaseStore, record["phases_ms"])
+	}
+
+	w := httptest.NewRecorder()
+	s.AdminRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	metrics := w.Body.String()
+	if !strings.Contains(metrics, `http_slow_requests_total{route="GET /slow"} 1`+"\n") {
+		t.Errorf("slow request not counted:\n%s", metrics)
+	}
+	for _, route := range []string{"GET /fast", "GET /export"} {
+		if strings.Contains(metrics, `http_slow_requests_total{route="`+route+`"}`) {
+			t.Errorf("%s counted as slow:\n%s", route, metrics)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// routeEntry is one registration in a route table.
+type routeEntry struct {
+	// Listener is "public" or "admin".
+	Listener string `json:"listener"`
+	Pattern  string `json:"pattern"`
+	// Methods are the methods the pattern matches; "*" is any method.
+	Methods []string `json:"methods"`
+	// Handler names the function the route ends in, before middleware.
+	Handler string `json:"handler"`
+	// Group is the route's own middleware group, if it has one.
+	Group string `json:"group,omitempty"`
+	// Source is the file and line the route was registered at.
+	Source string `json:"source"`
+}
+
+// smokeCheck is the canned request the smoke subcommand sends to a route,
+// and the status it expects back.
+type smokeCheck struct {
+	// Method defaults to the pattern's, or GET for a pattern without one.
+	Method string
+	// Path defaults to the pattern's.
+	Path string
+	// Header is sent with the request.
+	Header http.Header
+	// Body is sent as JSON unless Header sets a Content-Type.
+	Body   string
+	Status int
+}
+
+// smokeUser is the user the smoke subcommand adds before checking the
+// routes, so those that look a user up find one.
+var smokeUser = [3]string{"Smoke", "Test", "smoke@example.com"}
+
+// smokeChecks holds the smoke check of every route pattern of both
+// listeners, including those registered only by an option. The smoke
+// subcommand fails a route without one, so a new route must declare its
+// check here. Routes that need an admin are expected to refuse the
+// anonymous check.
+var smokeChecks = map[string]smokeCheck{
+	"GET /health":       {Status: http.StatusOK},
+	"GET /version":      {Status: http.StatusOK},
+	"GET /stats":        {Status: http.StatusOK},
+	"GET /openapi.json": {Status: http.StatusOK},
+	"GET /docs":         {Status: http.StatusOK},
+	"GET /static/":      {Path: "/static/style.css", Status: http.StatusOK},
+
+	"/{$}":                     {Path: "/", Status: http.StatusOK},
+	"/goodbye":                 {Status: http.StatusOK},
+	"/hello/":                  {Path: "/hello/?user=smoke", Status: http.StatusOK},
+	"POST /hello":              {Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, Body: "user=smoke", Status: http.StatusOK},
+	"/responses/{user}/hello/": {Path: "/responses/smoke/hello/", Status: http.StatusOK},
+	"/user/hello":              {Header: http.Header{"X-User": {"smoke"}}, Status: http.StatusOK},
+	"POST /json":               {Body: `{"first_name": "Smoke", "last_name": "Test"}`, Status: http.StatusOK},
+
+	"GET /users":                          {Status: http.StatusOK},
+	"POST /users":                         {Body: `{"first_name": "Smoke", "last_name": "Zero", "email": "smoke0@example.com"}`, Status: http.StatusCreated},
+	"POST /users/batch":                   {Body: `[{"first_name": "Smoke", "last_name": "Batch", "email": "smoke-batch@example.com"}]`, Status: http.StatusMultiStatus},
+	"GET /users/archive":                  {Status: http.StatusUnauthorized},
+	"POST /users/archive":                 {Body: `{}`, Status: http.StatusUnauthorized},
+	"GET /users/search":                   {Path: "/users/search?q=smoke", Status: http.StatusOK},
+	"GET /users/inactive":                 {Path: "/users/inactive?since=2024-01-01", Status: http.StatusOK},
+	"GET /users/verify":                   {Path: "/users/verify?token=smoke", Status: http.StatusNotFound},
+	"GET /users/domains":                  {Status: http.StatusOK},
+	"GET /users/{email}":                  {Path: "/users/smoke@example.com", Status: http.StatusOK},
+	"GET /users/{email}/greetings":        {Path: "/users/smoke@example.com/greetings", Status: http.StatusOK},
+	"PUT /users/{email}":                  {Path: "/users/smoke@example.com", Body: `{}`, Status: http.StatusUnauthorized},
+	"DELETE /users/{email}":               {Path: "/users/smoke@example.com", Status: http.StatusUnauthorized},
+	"POST /users/{email}/restore":         {Path: "/users/smoke@example.com/restore", Status: http.StatusUnauthorized},
+	"POST /logout":                        {Status: http.StatusNoContent},
+	"GET /users/export.csv":               {Status: http.StatusOK},
+	"GET /users/export":                   {Status: http.StatusOK},
+	"POST /debug/echo":                    {Body: `{}`, Status: http.StatusOK},
+	"GET /ws":                             {Status: http.StatusUpgradeRequired},
+	"GET /api/v1/hello":                   {Path: "/api/v1/hello?user=smoke", Status: http.StatusOK},
+	"GET /api/v1/hello/{user}":            {Path: "/api/v1/hello/smoke", Status: http.StatusOK},
+	"GET /api/v1/users":                   {Status: http.StatusOK},
+	"POST /api/v1/users":                  {Body: `{"first_name": "Smoke", "last_name": "One", "email": "smoke1@example.com"}`, Status: http.StatusCreated},
+	"POST /api/v1/users/batch":            {Body: `[{"first_name": "Smoke", "last_name": "Bulk", "email": "smoke-bulk@example.com"}]`, Status: http.StatusMultiStatus},
+	"GET /api/v1/users/archive":           {Status: http.StatusUnauthorized},
+	"POST /api/v1/users/archive":          {Body: `{}`, Status: http.StatusUnauthorized},
+	"GET /api/v1/users/search":            {Path: "/api/v1/users/search?q=smoke", Status: http.StatusOK},
+	"GET /api/v1/users/inactive":          {Path: "/api/v1/users/inactive?since=2024-01-01", Status: http.StatusOK},
+	"GET /api/v1/users/verify":            {Path: "/api/v1/users/verify?token=smoke", Status: http.StatusNotFound},
+	"GET /api/v1/users/domains":           {Status: http.StatusOK},
+	"GET /api/v1/users/by-id":             {Path: "/api/v1/users/by-id?id=00000000-0000-4000-8000-000000000000", Status: http.StatusNotFound},
+	"GET /api/v1/users/{email}":           {Path: "/api/v1/users/smoke@example.com", Status: http.StatusOK},
+	"GET /api/v1/users/{email}/greetings": {Path: "/api/v1/users/smoke@example.com/greetings", Status: http.StatusOK},
+	"GET /api/v1/users/{first}/{last}":    {Path: "/api/v1/users/Smoke/Test", Status: http.StatusOK},
+	"PUT /api/v1/users/{email}":           {Path: "/api/v1/users/smoke@example.com", Body: `{}`, Status: http.StatusUnauthorized},
+	"DELETE /api/v1/users/{email}":        {Path: "/api/v1/users/smoke@example.com", Status: http.StatusUnauthorized},
+	"POST /api/v1/users/{email}/restore":  {Path: "/api/v1/users/smoke@example.com/restore", Status: http.StatusUnauthorized},
+	"POST /api/v1/logout":                 {Status: http.StatusNoContent},
+
+	"GET /metrics":         {Status: http.StatusOK},
+	"GET /log-level":       {Status: http.StatusOK},
+	"PUT /log-level":       {Path: "/log-level?level=info", Status: http.StatusOK},
+	"GET /admin/audit":     {Status: http.StatusOK},
+	"GET /admin/routes":    {Status: http.StatusOK},
+	"POST /admin/reload":   {Status: http.StatusNoContent},
+	"/debug/pprof/":        {Status: http.StatusOK},
+	"/debug/pprof/cmdline": {Status: http.StatusOK},
+	"/debug/pprof/profile": {Path: "/debug/pprof/profile?seconds=1", Status: http.StatusOK},
+	"/debug/pprof/symbol":  {Status: http.StatusOK},
+	"/debug/pprof/trace":   {Path: "/debug/pprof/trace?seconds=0.1", Status: http.StatusOK},
+}
+
+// routeRegistry registers routes on a ServeMux and records them. A pattern
+// that conflicts with an earlier one is not registered; the first such
+// conflict is kept in err, naming where both were registered.
+type routeRegistry struct {
+	listener string
+	mux      *http.ServeMux
+	routes   []routeEntry
+	err      error
+
+	// owner is the function that created the registry. Its closures are
+	// registration helpers, so call sites are looked for above them.
+	owner string
+}
+
+func newRouteRegistry(listener string, mux *http.ServeMux) *routeRegistry {
+	reg := &routeRegistry{listener: listener, mux: mux}
+	if pc, _, _, ok := runtime.Caller(1); ok {
+		reg.owner = runtime.FuncForPC(pc).Name()
+	}
+	return reg
+}
+
+// handle registers h for pattern. fn is the handler the route ends in,
+// used to name it; h is what is actually registered, fn with any
+// middleware around it.
+func (reg *routeRegistry) handle(pattern string, group string, fn http.HandlerFunc, h http.Handler) {
+	entry := routeEntry{
+		Listener: reg.listener,
+		Pattern:  pattern,
+		Methods:  patternMethods(pattern),
+		Handler:  funcName(fn),
+		Group:    group,
+		Source:   reg.callSite(),
+	}
+	if reg.err != nil {
+		return
+	}
+	if err := tryHandle(reg.mux, pattern, h); err != nil {
+		reg.err = reg.conflict(entry, err)
+		return
+	}
+	reg.routes = append(reg.routes, entry)
+}
+
+// conflict explains why entry could not be registered: it names the
+// earlier route it conflicts with, or passes on the mux's complaint about
+// an invalid pattern.
+func (reg *routeRegistry) conflict(entry routeEntry, err error) error {
+	for _, prev := range reg.routes {
+		probe := http.NewServeMux()
+		probe.Handle(prev.Pattern, http.NotFoundHandler())
+		if tryHandle(probe, entry.Pattern, http.NotFoundHandler()) != nil {
+			return fmt.Errorf("route %q (%s, registered at %s) conflicts with route %q (%s, registered at %s)",
+				entry.Pattern, entry.Handler, entry.Source, prev.Pattern, prev.Handler, prev.Source)
+		}
+	}
+	return fmt.Errorf("route %q (%s, registered at %s): %w", entry.Pattern, entry.Handler, entry.Source, err)
+}
+
+// callSite returns the file and line of the first caller outside the
+// registry and its owner's helpers.
+func (reg *routeRegistry) callSite() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		helper := strings.Contains(frame.Function, ".(*routeRegistry).") ||
+			reg.owner != "" && strings.HasPrefix(frame.Function, reg.owner+".func")
+		if !helper {
+			return filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// tryHandle registers h on mux, returning the mux's panic as an error.
+func tryHandle(mux *http.ServeMux, pattern string, h http.Handler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	mux.Handle(pattern, h)
+	return nil
+}
+
+// patternMethods returns the methods a ServeMux pattern matches. GET
+// patterns also match HEAD.
+func patternMethods(pattern string) []string {
+	method, _, ok := strings.Cut(pattern, " ")
+	switch {
+	case !ok:
+		return []string{"*"}
+	case method == http.MethodGet:
+		return []string{http.MethodGet, http.MethodHead}
+	}
+	return []string{method}
+}
+
+// funcName returns fn's name without its package path, such as
+// "(*Server).handleHealth".
+func funcName(fn http.HandlerFunc) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	name := f.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	if _, rest, ok := strings.Cut(name, "."); ok {
+		name = rest
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// routeTable builds both listeners' routes and returns them, public first.
+// It reports the first conflicting registration instead of panicking as
+// Routes and AdminRoutes do.
+func (s *Server) routeTable() ([]routeEntry, error) {
+	if _, err := s.buildRoutes(); err != nil {
+		return nil, err
+	}
+	if _, err := s.buildAdminRoutes(); err != nil {
+		return nil, err
+	}
+	return slices.Concat(s.publicTable, s.adminTable), nil
+}
+
+// writeRouteTable writes routes as an aligned text table.
+func writeRouteTable(w io.Writer, routes []routeEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LISTENER\tMETHODS\tPATTERN\tGROUP\tHANDLER\tSOURCE")
+	for _, r := range routes {
+		group := r.Group
+		if group == "" {
+			group = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Listener, strings.Join(r.Methods, ","), r.Pattern, group, r.Handler, r.Source)
+	}
+	return tw.Flush()
+}
+
+type routesResponse struct {
+	Routes jsonList[routeEntry] `json:"routes"`
+}
+
+// handleRoutes lists the routes of both listeners, as JSON or, for a client
+// that prefers text/plain, as a text table. The public routes are those of
+// the last Routes call.
+func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := slices.Concat(s.publicTable, s.adminTable)
+	accept := r.Header.Get("Accept")
+	if acceptQuality(accept, "text/plain") > acceptQuality(accept, "application/json") {
+		var buf strings.Builder
+		writeRouteTable(&buf, routes)
+		httpx.WriteText(w, http.StatusOK, buf.String())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, routesResponse{Routes: routes})
+}
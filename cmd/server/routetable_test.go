@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func handleA(w http.ResponseWriter, r *http.Request) {}
+
+func handleB(w http.ResponseWriter, r *http.Request) {}
+
+// registerAt registers fn on reg and returns the source position of the
+// call, as the registry should report it.
+func registerAt(reg *routeRegistry, pattern string, fn http.HandlerFunc) string {
+	_, _, line, _ := runtime.Caller(0)
+	reg.handle(pattern, "", fn, fn)
+	return "routetable_test.go:" + strconv.Itoa(line+1)
+}
+
+func TestRouteRegistryReportsConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  string
+		second string
+	}{
+		{"duplicate", "GET /users/{email}", "GET /users/{email}"},
+		{"same path, different wildcard", "GET /users/{email}", "GET /users/{id}"},
+		{"neither more specific", "GET /users/{first}/x", "GET /users/x/{last}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			reg := newRouteRegistry("public", mux)
+			firstAt := registerAt(reg, tt.first, handleA)
+			secondAt := registerAt(reg, tt.second, handleB)
+
+			if reg.err == nil {
+				t.Fatal("no conflict reported")
+			}
+			for _, want := range []string{
+				strconv.Quote(tt.first), "handleA", firstAt,
+				strconv.Quote(tt.second), "handleB", secondAt,
+			} {
+				if !strings.Contains(reg.err.Error(), want) {
+					t.Errorf("error %q does not mention %s", reg.err, want)
+				}
+			}
+			if len(reg.routes) != 1 || reg.routes[0].Source != firstAt {
+				t.Errorf("routes = %+v, want only the first registration", reg.routes)
+			}
+		})
+	}
+}
+
+func TestRouteRegistryAllowsMoreSpecificPatterns(t *testing.T) {
+	reg := newRouteRegistry("public", http.NewServeMux())
+	registerAt(reg, "GET /users/{email}", handleA)
+	registerAt(reg, "GET /users/search", handleB)
+	registerAt(reg, "/users/{email}", handleB)
+	if reg.err != nil {
+		t.Fatal(reg.err)
+	}
+	if len(reg.routes) != 3 {
+		t.Errorf("recorded %d routes, want 3", len(reg.routes))
+	}
+}
+
+func TestRouteTable(t *testing.T) {
+	routes, err := newTestServer(t).routeTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byPattern := make(map[string]routeEntry)
+	for _, r := range routes {
+		byPattern[r.Listener+" "+r.Pattern] = r
+	}
+	tests := []struct {
+		key     string
+		methods string
+		handler string
+		group   string
+		file    string
+	}{
+		{"public GET /health", "GET,HEAD", "(*Server).handleHealth", groupAPI, "server.go:"},
+		{"public POST /users", "POST", "requireJSON.func1", groupLegacy, "server.go:"},
+		{"public GET /api/v1/users", "GET,HEAD", "(*Server).handleListUsers", groupAPI, "api.go:"},
+		{"public GET /users/export", "GET,HEAD", "(*Server).handleUsersExportFormat", groupStream, "server.go:"},
+		{"public /goodbye", "*", "(*Server).handleGoodbye", groupLegacy, "server.go:"},
+		{"public GET /ws", "GET,HEAD", "(*Server).handleWebSocket", "", "server.go:"},
+		{"admin GET /admin/routes", "GET,HEAD", "(*Server).handleRoutes", "", "admin.go:"},
+	}
+	for _, tt := range tests {
+		r, ok := byPattern[tt.key]
+		if !ok {
+			t.Errorf("%s missing from the route table", tt.key)
+			continue
+		}
+		if got := strings.Join(r.Methods, ","); got != tt.methods {
+			t.Errorf("%s: methods = %s, want %s", tt.key, got, tt.methods)
+		}
+		if r.Handler != tt.handler {
+			t.Errorf("%s: handler = %s, want %s", tt.key, r.Handler, tt.handler)
+		}
+		if r.Group != tt.group {
+			t.Errorf("%s: group = %q, want %q", tt.key, r.Group, tt.group)
+		}
+		if !strings.HasPrefix(r.Source, tt.file) {
+			t.Errorf("%s: source = %s, want %s...", tt.key, r.Source, tt.file)
+		}
+	}
+	if routes[0].Listener != "public" || routes[len(routes)-1].Listener != "admin" {
+		t.Error("public routes are not listed before admin routes")
+	}
+}
+
+func TestAdminRoutesEndpoint(t *testing.T) {
+	s := newTestServer(t)
+	s.Routes()
+	admin := s.AdminRoutes()
+
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/routes", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200\nbody: %s", w.Code, w.Body.String())
+	}
+	var body routesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Routes) != len(s.publicTable)+len(s.adminTable) {
+		t.Errorf("listed %d routes, want %d", len(body.Routes), len(s.publicTable)+len(s.adminTable))
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/admin/routes", nil)
+	r.Header.Set("Accept", "text/plain")
+	admin.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200\nbody: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if !strings.HasPrefix(lines[0], "LISTENER") || len(lines) != len(body.Routes)+1 {
+		t.Errorf("text table has %d lines starting %q, want a header and %d routes", len(lines), lines[0], len(body.Routes))
+	}
+}
+
+func TestRunPrintRoutes(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := run(context.Background(), []string{"-print-routes"}, &stdout, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"LISTENER", "GET /health", "(*Server).handleHealth", "GET /admin/routes"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	greeterv1 "github.com/kunalkumar-1/go-http/internal/grpcapi/greeter/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// logBuffer collects run's JSON logs; the server writes to it from several
+// goroutines while the test polls it.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// listenAddr returns the address the named server logged it is listening on.
+func (b *logBuffer) listenAddr(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sc := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	for sc.Scan() {
+		var line struct {
+			Msg    string `json:"msg"`
+			Server string `json:"server"`
+			Addr   string `json:"addr"`
+		}
+		if json.Unmarshal(sc.Bytes(), &line) == nil && line.Msg == "listening" && line.Server == name {
+			return line.Addr
+		}
+	}
+	return ""
+}
+
+// startRun runs the server with args on ephemeral ports until the returned
+// cancel is called, and returns the public address and the channel run's
+// result is sent on.
+func startRun(t *testing.T, args ...string) (addr string, cancel context.CancelFunc, done <-chan error) {
+	t.Helper()
+
+	logs, cancel, done := startRunLogs(t, args...)
+	return logs.waitListenAddr(t, "public", done), cancel, done
+}
+
+// startRunLogs is startRun returning the logs run writes rather than an
+// address.
+func startRunLogs(t *testing.T, args ...string) (logs *logBuffer, cancel context.CancelFunc, done <-chan error) {
+	t.Helper()
+
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	logs = new(logBuffer)
+	errc := make(chan error, 1)
+	go func() {
+		args = append([]string{"-addr", "127.0.0.1:0", "-admin-addr", "127.0.0.1:0", "-log-format", "json"}, args...)
+		errc <- run(ctx, args, logs, io.Discard)
+	}()
+	return logs, cancel, errc
+}
+
+// waitListenAddr waits for the named server to log that it is listening and
+// returns its address. It fails the test if run returns first.
+func (b *logBuffer) waitListenAddr(t *testing.T, name string, done <-chan error) string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if addr := b.listenAddr(name); addr != "" {
+			return addr
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("run returned early: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s server never started listening", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunServes(t *testing.T) {
+	addr, _, _ := startRun(t)
+
+	resp, err := http.Get("http://" + addr + "/health")
+	if err != nil {
+		t.Fatalf("GET /health: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("bad response code: expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	addr, cancel, done := startRun(t)
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return after the context was canceled")
+	}
+
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("expected the listener to be closed")
+	}
+}
+
+func TestRunServesGRPC(t *testing.T) {
+	logs, cancel, done := startRunLogs(t, "-grpc-addr", "127.0.0.1:0")
+	addr := logs.waitListenAddr(t, "grpc", done)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	resp, err := greeterv1.NewGreeterServiceClient(conn).SayHello(context.Background(), &greeterv1.HelloRequest{Name: "Alice"})
+	if err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	if resp.GetMessage() == "" {
+		t.Error("expected a greeting")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return after the context was canceled")
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("expected the gRPC listener to be closed")
+	}
+}
+
+func TestRunSQLiteStore(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "users.db")
+
+	err := run(context.Background(), []string{"-store", "sqlite", "-db-path", dbPath}, io.Discard, io.Discard)
+	if !errors.Is(err, errSQLiteIncomplete) {
+		t.Fatalf("expected errSQLiteIncomplete, got %v", err)
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		t.Error("a refused start created the database")
+	}
+}
+
+func TestRunPortInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	previous := slog.Default()
+	defer slog.SetDefault(previous)
+
+	err = run(context.Background(), []string{"-addr", ln.Addr().String(), "-admin-addr", "127.0.0.1:0"}, io.Discard, io.Discard)
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		t.Fatalf("expected EADDRINUSE, got %v", err)
+	}
+	if exitCode(err) != 1 {
+		t.Errorf("bad exit code: expected 1, got %d", exitCode(err))
+	}
+}
+
+func TestRunUsageErrors(t *testing.T) {
+	var stderr bytes.Buffer
+	err := run(context.Background(), []string{"-no-such-flag"}, io.Discard, &stderr)
+	if !errors.Is(err, errUsage) || exitCode(err) != 2 {
+		t.Errorf("unknown flag: expected a usage error, got %v", err)
+	}
+	if !strings.Contains(stderr.String(), "-no-such-flag") {
+		t.Errorf("expected the flag error on stderr, got %q", stderr.String())
+	}
+
+	err = run(context.Background(), []string{"-addr", ":99999"}, io.Discard, io.Discard)
+	if !errors.Is(err, errUsage) || !strings.Contains(err.Error(), "addr: invalid port") {
+		t.Errorf("invalid config: expected a usage error, got %v", err)
+	}
+
+	if err := run(context.Background(), []string{"-h"}, io.Discard, io.Discard); err != nil {
+		t.Errorf("-h: expected no error, got %v", err)
+	}
+}
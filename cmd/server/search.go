@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// defaultSearchLimit caps the results of a user search.
+const defaultSearchLimit = 50
+
+type searchResponse struct {
+	Users     jsonList[UserResponse] `json:"users"`
+	Count     int                    `json:"count"`
+	Truncated bool                   `json:"truncated"`
+}
+
+// handleSearchUsers finds users by name prefix. ?q= is required;
+// ?fuzzy=true also accepts a one-letter typo, and ?verified= keeps only
+// verified or unverified users.
+func (s *Server) handleSearchUsers(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	query := r.URL.Query()
+	q := query.Get("q")
+	if strings.TrimSpace(q) == "" {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "q must not be empty")
+		return
+	}
+	var fuzzy bool
+	if v := query.Get("fuzzy"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "invalid fuzzy")
+			return
+		}
+		fuzzy = parsed
+	}
+	verified, ok := parseVerified(r)
+	if !ok {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "invalid verified")
+		return
+	}
+
+	// Ask for one more than the limit to learn whether there were more.
+	found, err := users.Search(r.Context(), s.store, q, users.SearchOptions{Fuzzy: fuzzy, Verified: verified, Limit: s.searchLimit + 1})
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	var resp searchResponse
+	if len(found) > s.searchLimit {
+		found = found[:s.searchLimit]
+		resp.Truncated = true
+	}
+	for _, u := range found {
+		resp.Users = append(resp.Users, newUserResponse(u))
+	}
+	resp.Count = len(resp.Users)
+
+	httpx.WriteJSON(w, http.StatusOK, resp)
+}
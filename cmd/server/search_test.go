@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchUsers(t *testing.T) {
+	s := newTestServer(t)
+	s.searchLimit = 2
+	for _, u := range [][3]string{
+		{"John", "Smith", "john@example.com"},
+		{"Joanna", "Baker", "joanna@example.com"},
+		{"Mary", "Jones", "mary@example.com"},
+		{"Peter", "Parker", "peter@example.com"},
+	} {
+		if err := s.users.AddUser(context.Background(), u[0], u[1], u[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := s.Routes()
+
+	tests := []struct {
+		target    string
+		emails    []string
+		truncated bool
+	}{
+		{"/users/search?q=pe", []string{"peter@example.com"}, false},
+		{"/api/v1/users/search?q=PARK", []string{"peter@example.com"}, false},
+		{"/users/search?q=jo", []string{"john@example.com", "joanna@example.com"}, true},
+		{"/users/search?q=smyth", []string{}, false},
+		{"/users/search?q=smyth&fuzzy=true", []string{"john@example.com"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+			}
+
+			var resp searchResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("error decoding response: %v", err)
+			}
+			emails := []string{}
+			for _, u := range resp.Users {
+				emails = append(emails, u.Email)
+			}
+			if len(emails) != len(tc.emails) || resp.Count != len(tc.emails) || resp.Truncated != tc.truncated {
+				t.Fatalf("bad response: expected %v (truncated %v), got %s", tc.emails, tc.truncated, w.Body.String())
+			}
+			for i := range emails {
+				if emails[i] != tc.emails[i] {
+					t.Errorf("bad result %d: expected %s, got %s", i, tc.emails[i], emails[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSearchUsersBadRequest(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	for _, target := range []string{"/users/search", "/users/search?q=", "/users/search?q=%20", "/users/search?q=jo&fuzzy=maybe"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("bad response code for %s: expected %d, got %d\nbody: %s\n", target, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+		assertErrorCode(t, w, codeInvalidRequest, "")
+	}
+}
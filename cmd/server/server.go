@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+	"github.com/kunalkumar-1/go-http/internal/worker"
+	"golang.org/x/text/language"
+)
+
+// Server holds the dependencies shared by all handlers. Handlers are methods
+// on Server so tests can construct one with their own logger and manager.
+type Server struct {
+	logger *slog.Logger
+	users  *users.Manager
+	// store serves the operations users.Store covers, bounded by the
+	// request's context. It is users unless a test swaps in another Store;
+	// run refuses -store=sqlite until every handler goes through it.
+	store   users.Store
+	exports *exportCache
+	greeter *greeting.Greeter
+	now     func() time.Time
+
+	// greeterSource is what reloadGreeter re-reads on SIGHUP and
+	// POST /admin/reload.
+	greeterSource greeterSource
+
+	timeouts Timeouts
+
+	// debugTiming emits a Server-Timing header on every response rather
+	// than only for requests that opt in.
+	debugTiming bool
+
+	// registerOnGreet makes POST /json register the posted user before
+	// greeting them and report the outcome in a JSON response.
+	registerOnGreet bool
+
+	// enablePprof registers /debug/pprof on the admin mux.
+	enablePprof bool
+
+	// enableDebugEndpoints registers /debug/echo on the public mux.
+	enableDebugEndpoints bool
+
+	legacy legacyPolicy
+
+	// sessionSecret signs the session cookie that remembers the greeted
+	// username; macs pools the HMACs keyed with it.
+	sessionSecret []byte
+	macs          sync.Pool
+
+	// cursorSecret signs the cursors of user list pages. It is never
+	// configured: cursors name users by sequence numbers, which a restart
+	// reassigns, so they must not outlive the process.
+	cursorSecret []byte
+
+	// greetings counts greetings per name for /stats, leaving out those
+	// cooldown throttles; requests counts every request since started, and
+	// routeStats the requests of each route.
+	greetings *greetingCounter
+	cooldown  *greetCooldown
+	requests  atomic.Uint64
+	// bodyReadTimeouts counts the request bodies withBodyReadDeadline
+	// gave up on.
+	bodyReadTimeouts atomic.Uint64
+	routeStats       *routeCollector
+	started          time.Time
+
+	// streamAborts counts the streamed responses cut short by a failed
+	// write, by whether the client had gone away; see newStream.
+	streamAborts struct{ clientGone, failed atomic.Uint64 }
+
+	// slowRequest is the latency above which a request is logged at Warn
+	// and counted as slow in its route stats; slowRequestGroups overrides
+	// it for the routes of a middleware group. Zero disables the check.
+	slowRequest       time.Duration
+	slowRequestGroups map[string]time.Duration
+
+	// workers runs the background tasks, and reports them on /health.
+	workers *worker.Runner
+
+	// logLevel is adjustable at runtime through the admin address. main
+	// builds logger's handler on it; NewServer's default controls nothing.
+	logLevel *slog.LevelVar
+
+	// shuttingDown is closed by closeWebSockets when the server shuts down.
+	shuttingDown chan struct{}
+	shutdownOnce sync.Once
+
+	// endpoints is listed on the homepage. Routes fills it in.
+	endpoints []endpoint
+
+	// publicTable and adminTable record the routes of each listener for
+	// /admin/routes. Routes and AdminRoutes fill them in.
+	publicTable []routeEntry
+	adminTable  []routeEntry
+
+	// realIP resolves client addresses behind trusted proxies. It trusts
+	// no proxies by default.
+	realIP *realIP
+
+	// idempotency holds responses to POST /users for replay to retries
+	// that send the same Idempotency-Key.
+	idempotency *idempotencyCache
+
+	// searchLimit caps the users returned by /users/search.
+	searchLimit int
+
+	// limiter caps concurrent requests; nil allows any number.
+	limiter *concurrencyLimiter
+
+	// urlLimits bounds request URLs before handlers parse them.
+	urlLimits urlLimits
+
+	// trailingSlash is the trailing-slash policy of withPathNormalization.
+	trailingSlash string
+
+	// missingName answers the hello requests that name no one.
+	missingName missingNamePolicy
+
+	// shutdownHooks run in order on Shutdown. draining is set by the first
+	// of them and makes withDrain refuse user writes.
+	shutdownHooks []shutdownHook
+	draining      atomic.Bool
+
+	// storeMetrics is the users.Metrics sink of the server's manager, if it
+	// was created with one. /metrics falls back to counting users itself.
+	storeMetrics *storeMetrics
+
+	// accessLog receives one line per public request; nil disables it.
+	accessLog *slog.Logger
+
+	// redact masks sensitive headers and body fields wherever a request
+	// is logged or echoed.
+	redact *httpx.Redactor
+}
+
+func NewServer(logger *slog.Logger, manager *users.Manager) *Server {
+	s := &Server{
+		logger:  logger,
+		users:   manager,
+		store:   manager,
+		exports: newExportCache(exportCacheBudget),
+		now:     time.Now,
+
+		timeouts: defaultTimeouts(),
+
+		sessionSecret: newSessionSecret(),
+		cursorSecret:  newSessionSecret(),
+		greetings:     newGreetingCounter(defaultStatsNames),
+		routeStats:    newRouteCollector(),
+		workers:       worker.NewRunner(worker.WithLogger(logger)),
+		searchLimit:   defaultSearchLimit,
+		logLevel:      new(slog.LevelVar),
+		shuttingDown:  make(chan struct{}),
+		realIP:        &realIP{},
+		limiter:       newConcurrencyLimiter(defaultMaxInFlight, defaultLimitWait),
+		urlLimits:     defaultURLLimits(),
+		trailingSlash: trailingSlashKeep,
+		missingName:   missingNamePolicy{mode: missingNameDefault, name: defaultGreetingName},
+		redact:        httpx.NewRedactor(httpx.DefaultRedactedHeaders, httpx.DefaultRedactedFields),
+
+		slowRequest:       defaultSlowRequest,
+		slowRequestGroups: map[string]time.Duration{groupStream: defaultSlowStreamRequest},
+	}
+	s.started = s.now()
+	// Without a template the greeter cannot fail to build.
+	s.greeter, _ = s.newGreeter()
+	s.OnShutdown("refuse new writes", 0, s.refuseWrites)
+	s.OnShutdown("close websockets", 0, func(context.Context) error {
+		s.closeWebSockets()
+		return nil
+	})
+	s.idempotency = newIdempotencyCache(defaultIdempotencyTTL, func() time.Time { return s.now() })
+	return s
+}
+
+// Routes builds the mux and wraps it in the server's middleware chain. It
+// panics if two routes conflict, which is a wiring bug.
+func (s *Server) Routes() http.Handler {
+	h, err := s.buildRoutes()
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// buildRoutes is Routes, returning a conflict between two routes as an
+// error.
+func (s *Server) buildRoutes() (http.Handler, error) {
+	mux := http.NewServeMux()
+	routes := newRouteRegistry("public", mux)
+
+	// Every pattern goes through register so the OpenAPI document can be
+	// checked against the full route list, the route table records it,
+	// every response is tracked for httpx, and every request is counted in
+	// the route stats. group may be empty for a route with no middleware
+	// of its own.
+	var patterns []string
+	register := func(pattern string, group string, h http.HandlerFunc) {
+		patterns = append(patterns, pattern)
+		var next http.Handler = h
+		if group != "" {
+			next = s.middleware(group).Then(h)
+		}
+		routes.handle(pattern, group, h, &routeHandler{httpx.Track(s.logger, s.withRouteStats(pattern, group, next))})
+	}
+
+	handle := func(pattern string, h http.HandlerFunc) {
+		register(pattern, groupAPI, h)
+	}
+
+	legacy := func(pattern string, h http.HandlerFunc) {
+		register(pattern, groupLegacy, h)
+	}
+
+	handle("GET /health", s.handleHealth)
+	handle("GET /version", s.handleVersion)
+	handle("GET /stats", s.handleStats)
+
+	var openAPI *openAPIDoc
+	handle("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		httpx.WriteJSON(w, http.StatusOK, openAPI)
+	})
+	handle("GET /docs", s.handleDocs)
+	handle("GET /static/", staticFiles().ServeHTTP)
+
+	// Unversioned routes are API v0 and keep their original shapes.
+	legacy("/{$}", s.handleRoot)
+	legacy("/goodbye", s.handleGoodbye)
+	legacy("/hello/", s.handleHelloParameterized)
+	legacy("POST /hello", s.handleHelloForm)
+	legacy("/responses/{user}/hello/", s.handleUserResponsesHello)
+	// Newer clients send X-User; it wins when a client sends both.
+	legacy("/user/hello", preferHeader("X-User", s.helloFromHeader("X-User"), s.helloFromHeader("user")))
+	legacy("POST /json", requireJSON(s.handleJSON))
+	legacy("GET /users", s.handleListUsers)
+	legacy("POST /users", requireJSON(s.withIdempotency(s.handleCreateUser)))
+	legacy("POST /users/batch", requireJSON(s.handleCreateUsersBatch))
+	legacy("GET /users/archive", s.requireRole(users.RoleAdmin, s.handleUsersArchive))
+	legacy("POST /users/archive", s.requireRole(users.RoleAdmin, requireJSON(s.handleImportArchive)))
+	legacy("GET /users/search", s.handleSearchUsers)
+	legacy("GET /users/inactive", s.handleInactiveUsers)
+	legacy("GET /users/verify", s.handleVerifyUser)
+	legacy("GET /users/domains", s.handleUserDomains)
+	legacy("GET /users/{email}", s.handleGetUser)
+	legacy("GET /users/{email}/greetings", s.handleGreetingHistory)
+	legacy("PUT /users/{email}", s.requireRole(users.RoleAdmin, requireJSON(s.handleUpdateUser)))
+	legacy("DELETE /users/{email}", s.requireRole(users.RoleAdmin, s.handleDeleteUser))
+	legacy("POST /users/{email}/restore", s.requireRole(users.RoleAdmin, s.handleRestoreUser))
+	legacy("POST /logout", s.handleLogout)
+
+	register("GET /users/export.csv", groupStream, s.handleUsersExport)
+	register("GET /users/export", groupStream, s.handleUsersExportFormat)
+
+	if s.enableDebugEndpoints {
+		handle("POST /debug/echo", s.handleDebugEcho)
+	}
+
+	// WebSockets hijack the connection, which http.TimeoutHandler does not
+	// allow.
+	register("GET /ws", "", s.handleWebSocket)
+
+	s.mountAPI(handle, apiV1)
+
+	if routes.err != nil {
+		return nil, routes.err
+	}
+	openAPI, err := buildOpenAPI(patterns)
+	if err != nil {
+		return nil, err
+	}
+	s.endpoints = endpointsOf(openAPI)
+	s.publicTable = routes.routes
+
+	return s.middleware(groupPublic).Then(s.withPathNormalization(mux, withMethodHandling(mux, httpx.Track(s.logger, withErrorHandlers(mux))))), nil
+}
+
+// logRequest logs r at Info, with its headers, redacted, when Debug is
+// enabled. It runs on every request, so nothing is boxed for the
+// attributes unless Info is enabled.
+func (s *Server) logRequest(r *http.Request) {
+	ctx := r.Context()
+	if !s.logger.Enabled(ctx, slog.LevelInfo) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Any("client", clientIPFrom(ctx))}
+	if s.logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs, slog.Any("headers", s.redact.Header(r.Header)))
+	}
+	s.logger.LogAttrs(ctx, slog.LevelInfo, "request", attrs...)
+}
+
+func (s *Server) handleGoodbye(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	httpx.WriteText(w, http.StatusOK, "Goodbye world is served at goodbye\n")
+}
+
+func (s *Server) handleHelloParameterized(w http.ResponseWriter, r *http.Request) {
+	s.handleHelloQuery(apiV0)(w, r)
+}
+
+func (s *Server) handleUserResponsesHello(w http.ResponseWriter, r *http.Request) {
+	s.handleHelloPath(apiV0)(w, r)
+}
+
+// helloFromHeader returns a handler greeting the user named by the first
+// headerName request header. Without the header it answers as greetMissing
+// does.
+func (s *Server) helloFromHeader(headerName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.logRequest(r)
+
+		values := r.Header.Values(headerName)
+		if len(values) == 0 {
+			s.greetMissing(w, r, apiV0)
+			return
+		}
+		username := values[0]
+		if err := s.checkUsername(username); err != nil {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+
+		s.recordGreetingByName(username)
+		s.handleHello(w, r, username)
+	}
+}
+
+// preferHeader serves requests carrying headerName, even empty, with
+// preferred and all others with fallback.
+func preferHeader(headerName string, preferred http.HandlerFunc, fallback http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Values(headerName)) > 0 {
+			preferred(w, r)
+			return
+		}
+		fallback(w, r)
+	}
+}
+
+// bodyBuffers holds the buffers handleJSON reads request bodies into.
+var bodyBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooledBody keeps one huge body from pinning its buffer.
+const maxPooledBody = 64 << 10
+
+func putBodyBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBody {
+		buf.Reset()
+		bodyBuffers.Put(buf)
+	}
+}
+
+func (s *Server) handleJSON(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	timing := timingFrom(r.Context())
+
+	if s.canceled(r, "read body") {
+		return
+	}
+
+	start := timing.start()
+	body := bodyBuffers.Get().(*bytes.Buffer)
+	defer putBodyBuffer(body)
+	_, err := body.ReadFrom(r.Body)
+	byteData := body.Bytes()
+	timing.end(phaseBodyRead, start)
+	if errors.Is(err, errBodyReadTimeout) {
+		// withBodyReadDeadline has logged it.
+		httpx.WriteError(w, http.StatusRequestTimeout, codeRequestTimeout, "request body not received in time")
+		return
+	}
+	if err != nil {
+		s.logRequestError(r, "error reading request body", err)
+		if !clientGone(r, err) {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "bad request body")
+		}
+		return
+	}
+
+	if len(byteData) == 0 {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "empty request body")
+		return
+	}
+
+	var reqData CreateUserRequest
+	err = json.Unmarshal(byteData, &reqData)
+	if err != nil {
+		s.logger.Error("error unmarshalling request body", "err", err)
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "error parsing request body")
+		return
+	}
+
+	if err := reqData.Validate(); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	// The language in the body takes precedence over Accept-Language.
+	// Validate has parsed it; the header gets its canonical form.
+	if reqData.Language != "" {
+		tag, _ := language.Parse(reqData.Language)
+		r = r.Clone(r.Context())
+		r.Header.Set("Accept-Language", tag.String())
+	}
+
+	if s.registerOnGreet {
+		s.handleSignup(w, r, reqData)
+		return
+	}
+
+	s.recordGreeting(reqData.FirstName, reqData.LastName)
+
+	if reqData.Greeting != "" {
+		start := timing.start()
+		res, err := s.renderUserGreeting(w, r, reqData)
+		timing.end(phaseRender, start)
+		if err != nil {
+			s.logger.Error("error rendering greeting", "err", err)
+			httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "error rendering greeting")
+			return
+		}
+		w.Header().Set("Content-Language", res.Language)
+		apiV0.writeGreeting(w, []greeting.Result{res})
+		return
+	}
+
+	s.handleHello(w, r, reqData.FirstName)
+}
+
+// newGreeter returns a Greeter built from opts that reads the server's
+// clock, so a test that replaces s.now sees it in templates.
+func (s *Server) newGreeter(opts ...greeting.Option) (*greeting.Greeter, error) {
+	return greeting.New(append([]greeting.Option{greeting.WithClock(func() time.Time { return s.now() })}, opts...)...)
+}
+
+// renderGreeting returns the greeting for username in the language
+// negotiated from the request's Accept-Language header, and counts it
+// through countGreeting, which may mark w.
+func (s *Server) renderGreeting(w http.ResponseWriter, r *http.Request, username string) (greeting.Result, error) {
+	res, err := s.greeter.Negotiate(r.Header.Get("Accept-Language"), username)
+	if err == nil {
+		s.countGreeting(w, username)
+	}
+	return res, err
+}
+
+// renderGreetingHTML is renderGreeting for an HTML page.
+func (s *Server) renderGreetingHTML(w http.ResponseWriter, r *http.Request, username string) (greeting.HTMLResult, error) {
+	res, err := s.greeter.NegotiateHTML(r.Header.Get("Accept-Language"), username)
+	if err == nil {
+		s.countGreeting(w, username)
+	}
+	return res, err
+}
+
+// renderUserGreeting is renderGreeting for a CreateUserRequest, which may replace the
+// translated greeting with one of the safelisted English ones.
+func (s *Server) renderUserGreeting(w http.ResponseWriter, r *http.Request, data CreateUserRequest) (greeting.Result, error) {
+	if data.Greeting == "" {
+		return s.renderGreeting(w, r, data.FirstName)
+	}
+	res, err := s.greeter.Salute(data.Greeting, data.FirstName)
+	if err == nil {
+		s.countGreeting(w, data.FirstName)
+	}
+	return res, err
+}
+
+func (s *Server) handleHello(w http.ResponseWriter, r *http.Request, username string) {
+	s.greet(w, r, apiV0, username)
+}
+
+// greet renders the greeting for each username and hands them to the
+// version's encoder, so every API version shares the same greeting logic.
+// Nothing is written unless every greeting renders.
+func (s *Server) greet(w http.ResponseWriter, r *http.Request, v apiVersion, usernames ...string) {
+	timing := timingFrom(r.Context())
+
+	if s.canceled(r, "render greeting") {
+		return
+	}
+
+	start := timing.start()
+	results := make([]greeting.Result, 0, len(usernames))
+	for _, username := range usernames {
+		res, err := s.renderGreeting(w, r, username)
+		if err != nil {
+			timing.end(phaseRender, start)
+			s.logger.Error("error rendering greeting", "err", err)
+			httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "error rendering greeting")
+			return
+		}
+		results = append(results, res)
+	}
+	timing.end(phaseRender, start)
+
+	w.Header().Set("Content-Language", results[0].Language)
+	v.writeGreeting(w, results)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		header map[string]string
+		status int
+		want   string
+	}{
+		{"root", http.MethodGet, "/", "", map[string]string{"Accept": "text/plain"}, http.StatusOK, "Welcome to our HomePage!\n"},
+		{"root does not match subpaths", http.MethodGet, "/some/random/path", "", nil, http.StatusNotFound, ""},
+		{"goodbye", http.MethodGet, "/goodbye", "", nil, http.StatusOK, "Goodbye world is served at goodbye\n"},
+		{"hello query", http.MethodGet, "/hello/?user=alice", "", nil, http.StatusOK, "Hello alice!\n"},
+		{"hello without trailing slash", http.MethodGet, "/hello?user=alice", "", nil, http.StatusOK, "Hello alice!\n"},
+		{"hello localized", http.MethodGet, "/hello/?user=alice", "", map[string]string{"Accept-Language": "fr-CA, en;q=0.5"}, http.StatusOK, "Bonjour alice !\n"},
+		{"path value", http.MethodGet, "/responses/alice/hello/", "", nil, http.StatusOK, "Hello alice!\n"},
+		{"path value missing segment", http.MethodGet, "/responses/hello/", "", nil, http.StatusNotFound, ""},
+		{"header", http.MethodGet, "/user/hello", "", map[string]string{"user": "alice"}, http.StatusOK, "Hello alice!\n"},
+		{"json", http.MethodPost, "/json", `{"FirstName":"alice"}`, map[string]string{"Content-Type": "application/json"}, http.StatusOK, "Hello alice!\n"},
+		{"json wrong method", http.MethodGet, "/json", "", nil, http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+					tt.status, w.Code, w.Body.String())
+			}
+			if tt.want != "" && w.Body.String() != tt.want {
+				t.Errorf("bad response body: expected %q, got %q", tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// sessionCookie carries the last username greeted through ?user=, signed
+// with the server's session secret.
+const sessionCookie = "greeted_user"
+
+// newSessionSecret returns a random secret for servers started without one.
+// Cookies signed with it do not survive a restart.
+func newSessionSecret() []byte {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+}
+
+// sessionMAC is an HMAC keyed with the session secret it was made for.
+// They are pooled, since every named greeting signs a cookie.
+type sessionMAC struct {
+	secret []byte
+	hash.Hash
+}
+
+// sessionMAC returns a reset HMAC keyed with the session secret. Pass it to
+// s.macs.Put when done.
+func (s *Server) sessionMAC() *sessionMAC {
+	if mac, ok := s.macs.Get().(*sessionMAC); ok && bytes.Equal(mac.secret, s.sessionSecret) {
+		mac.Reset()
+		return mac
+	}
+	return &sessionMAC{secret: s.sessionSecret, Hash: hmac.New(sha256.New, s.sessionSecret)}
+}
+
+func (s *Server) signUsername(username string) string {
+	mac := s.sessionMAC()
+	defer s.macs.Put(mac)
+	var sum [sha256.Size]byte
+	io.WriteString(mac, username)
+
+	enc := base64.RawURLEncoding
+	value := make([]byte, 0, enc.EncodedLen(len(username))+1+enc.EncodedLen(sha256.Size))
+	value = enc.AppendEncode(value, []byte(username))
+	value = append(value, '.')
+	value = enc.AppendEncode(value, mac.Sum(sum[:0]))
+	return string(value)
+}
+
+// verifyUsername returns the username from a signed cookie value, or false
+// if the value is malformed or the signature does not match.
+func (s *Server) verifyUsername(value string) (string, bool) {
+	encodedName, encodedSig, ok := strings.Cut(value, ".")
+	if !ok {
+		return "", false
+	}
+	name, err := base64.RawURLEncoding.DecodeString(encodedName)
+	if err != nil {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return "", false
+	}
+
+	mac := s.sessionMAC()
+	defer s.macs.Put(mac)
+	var sum [sha256.Size]byte
+	mac.Write(name)
+	if !hmac.Equal(sig, mac.Sum(sum[:0])) {
+		return "", false
+	}
+	return string(name), true
+}
+
+// rememberUser sets the session cookie for username.
+func (s *Server) rememberUser(w http.ResponseWriter, r *http.Request, username string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.signUsername(username),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// rememberedUser returns the username from a valid session cookie.
+func (s *Server) rememberedUser(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	return s.verifyUsername(cookie.Value)
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
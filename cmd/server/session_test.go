@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func hello(t *testing.T, handler http.Handler, target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func sessionCookieFrom(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie {
+			return c
+		}
+	}
+	t.Fatalf("response did not set the %s cookie", sessionCookie)
+	return nil
+}
+
+func TestSessionRemembersUser(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	cookie := sessionCookieFrom(t, hello(t, handler, "/hello/?user=alice"))
+	if !cookie.HttpOnly {
+		t.Error("session cookie should be HttpOnly")
+	}
+
+	if got := hello(t, handler, "/hello/", cookie).Body.String(); got != "Hello alice!\n" {
+		t.Errorf("bad response body: expected %q, got %q", "Hello alice!\n", got)
+	}
+	if got := hello(t, handler, "/hello/").Body.String(); got != "Hello User!\n" {
+		t.Errorf("bad response body without cookie: expected %q, got %q", "Hello User!\n", got)
+	}
+
+	_, sig, _ := strings.Cut(cookie.Value, ".")
+	tampered := []string{
+		base64.RawURLEncoding.EncodeToString([]byte("bob")) + "." + sig,
+		cookie.Value + "x",
+		"garbage",
+	}
+	for _, value := range tampered {
+		w := hello(t, handler, "/hello/", &http.Cookie{Name: sessionCookie, Value: value})
+		if got := w.Body.String(); got != "Hello User!\n" {
+			t.Errorf("tampered cookie %q: expected %q, got %q", value, "Hello User!\n", got)
+		}
+	}
+}
+
+func TestSessionSignedWithServerSecret(t *testing.T) {
+	a, b := newTestServer(t), newTestServer(t)
+	b.sessionSecret = []byte("another secret")
+
+	cookie := sessionCookieFrom(t, hello(t, a.Routes(), "/hello/?user=alice"))
+	if got := hello(t, b.Routes(), "/hello/", cookie).Body.String(); got != "Hello User!\n" {
+		t.Errorf("cookie from another server accepted: got %q", got)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/logout", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n",
+			http.StatusNoContent, w.Code, w.Body.String())
+	}
+
+	cleared := sessionCookieFrom(t, w)
+	if cleared.MaxAge >= 0 || cleared.Value != "" {
+		t.Errorf("logout should expire the cookie, got %+v", cleared)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// shutdownHook is one step of Server.Shutdown. A zero timeout leaves the
+// hook bounded only by the context passed to Shutdown.
+type shutdownHook struct {
+	name    string
+	timeout time.Duration
+	fn      func(ctx context.Context) error
+}
+
+// OnShutdown appends a hook to the steps run by Shutdown. Hooks run one at a
+// time in the order they were added; NewServer adds the ones that stop new
+// writes and close WebSockets, so hooks added later run after both.
+func (s *Server) OnShutdown(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	s.shutdownHooks = append(s.shutdownHooks, shutdownHook{name: name, timeout: timeout, fn: fn})
+}
+
+// Shutdown runs every shutdown hook in order and returns their errors
+// joined. A hook that fails or overruns its timeout is logged and
+// abandoned, and the next hook runs regardless.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, hook := range s.shutdownHooks {
+		if err := s.runShutdownHook(ctx, hook); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %q: %w", hook.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) runShutdownHook(ctx context.Context, hook shutdownHook) error {
+	if hook.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	s.logger.Info("running shutdown hook", "hook", hook.name)
+
+	// The hook runs in its own goroutine so one that ignores ctx cannot
+	// hold up the rest of the shutdown.
+	done := make(chan error, 1)
+	go func() { done <- hook.fn(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		s.logger.Error("shutdown hook failed", "hook", hook.name, "err", err, "elapsed", time.Since(start))
+		return err
+	}
+	s.logger.Info("shutdown hook finished", "hook", hook.name, "elapsed", time.Since(start))
+	return nil
+}
+
+// refuseWrites is the first shutdown hook. From then on, requests that would
+// change users get a 503 so the final snapshot is not racing new writes.
+func (s *Server) refuseWrites(context.Context) error {
+	s.draining.Store(true)
+	return nil
+}
+
+// isUserWrite reports whether r would change the stored users.
+func isUserWrite(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	path := strings.TrimPrefix(r.URL.Path, apiV1.prefix)
+	return path == "/users" || strings.HasPrefix(path, "/users/")
+}
+
+// withDrain refuses user writes once shutdown has begun. Reads, and
+// requests already past this point, are unaffected.
+func (s *Server) withDrain(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.draining.Load() && isUserWrite(r) {
+			w.Header().Set("Connection", "close")
+			httpx.WriteError(w, http.StatusServiceUnavailable, codeShuttingDown, "server is shutting down")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// stopListeners returns a shutdown hook that gracefully shuts down every
+// listener's server, waiting for in-flight requests until ctx is done.
+func stopListeners(listeners ...listener) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, l := range listeners {
+			if err := l.shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s server: %w", l.name, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownHookOrder(t *testing.T) {
+	s := newTestServer(t)
+
+	var ran []string
+	for _, name := range []string{"first", "second", "third"} {
+		s.OnShutdown(name, time.Second, func(context.Context) error {
+			ran = append(ran, name)
+			return nil
+		})
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if want := []string{"first", "second", "third"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("bad hook order: expected %q, got %q", want, ran)
+	}
+
+	var names []string
+	for _, hook := range s.shutdownHooks {
+		names = append(names, hook.name)
+	}
+	if want := []string{"refuse new writes", "close websockets", "first", "second", "third"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("bad hooks: expected %q, got %q", want, names)
+	}
+	if !s.draining.Load() {
+		t.Error("expected writes to be refused after shutdown")
+	}
+	select {
+	case <-s.shuttingDown:
+	default:
+		t.Error("expected websockets to be closed after shutdown")
+	}
+}
+
+func TestShutdownHookTimeout(t *testing.T) {
+	s, logs := newRecordingServer(t)
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	s.OnShutdown("stuck", 20*time.Millisecond, func(context.Context) error {
+		<-stuck // ignores ctx on purpose
+		return nil
+	})
+	var after bool
+	s.OnShutdown("after", time.Second, func(context.Context) error {
+		after = true
+		return nil
+	})
+
+	start := time.Now()
+	err := s.Shutdown(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("hook timeout not enforced: took %v", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), `"stuck"`) {
+		t.Errorf("expected a deadline error naming the hook, got %v", err)
+	}
+	if !after {
+		t.Error("hooks after a timed-out hook did not run")
+	}
+	if n := logs.count(slog.LevelError); n != 1 {
+		t.Errorf("expected 1 Error entry, got %d", n)
+	}
+}
+
+func TestShutdownHookErrors(t *testing.T) {
+	s := newTestServer(t)
+
+	boom := errors.New("boom")
+	s.OnShutdown("failing", 0, func(context.Context) error { return boom })
+	var after bool
+	s.OnShutdown("after", 0, func(context.Context) error {
+		after = true
+		return nil
+	})
+
+	if err := s.Shutdown(context.Background()); !errors.Is(err, boom) {
+		t.Errorf("expected the hook's error, got %v", err)
+	}
+	if !after {
+		t.Error("hooks after a failed hook did not run")
+	}
+}
+
+func TestWritesRefusedDuringDrain(t *testing.T) {
+	s := newTestServer(t)
+	addAdmin(t, s.users)
+	handler := s.Routes()
+
+	inFlight := make(chan struct{})
+	finish := make(chan struct{})
+	s.OnShutdown("wait for in-flight", time.Second, func(context.Context) error {
+		close(inFlight)
+		<-finish
+		return nil
+	})
+	done := make(chan error, 1)
+	go func() { done <- s.Shutdown(context.Background()) }()
+	<-inFlight
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/users", `{"first_name":"Ann","last_name":"Lee","email":"ann@example.com"}`, http.StatusServiceUnavailable},
+		{http.MethodPost, "/api/v1/users", `{"first_name":"Ann","last_name":"Lee","email":"ann@example.com"}`, http.StatusServiceUnavailable},
+		{http.MethodPost, "/users/batch", `[]`, http.StatusServiceUnavailable},
+		{http.MethodDelete, "/users/" + testAdminEmail, "", http.StatusServiceUnavailable},
+		{http.MethodGet, "/users", "", http.StatusOK},
+		{http.MethodGet, "/hello/?user=ann", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		r.SetBasicAuth(testAdminEmail, testAdminPassword)
+		handler.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %s: bad response code: expected %d, got %d\nbody: %s\n", tt.method, tt.target, tt.status, w.Code, w.Body.String())
+			continue
+		}
+		if tt.status == http.StatusServiceUnavailable {
+			assertErrorCode(t, w, codeShuttingDown, "server is shutting down")
+		}
+	}
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
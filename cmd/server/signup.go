@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// Registration outcomes reported by the greet-and-register flow.
+const (
+	registrationRegistered     = "registered"
+	registrationAlreadyExisted = "already_existed"
+	registrationFailed         = "registration_failed"
+)
+
+type signupResponse struct {
+	Greeting     string `json:"greeting"`
+	Registration string `json:"registration"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// handleSignup registers the user before anything is written, so the
+// response always describes what actually happened to the store. The
+// registration goes through GetOrCreateUser, which makes retries of the same
+// payload report already_existed instead of failing. A failed registration
+// still greets the user and carries the reason; running out of the store
+// deadline is answered with a 504 like any other write.
+func (s *Server) handleSignup(w http.ResponseWriter, r *http.Request, reqData CreateUserRequest) {
+	timing := timingFrom(r.Context())
+
+	resp := signupResponse{Registration: registrationRegistered}
+
+	start := timing.start()
+	_, created, err := s.users.GetOrCreateUser(r.Context(), reqData.FirstName, reqData.LastName, reqData.Email)
+	timing.end(phaseStore, start)
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeUserError(w, err)
+		return
+	}
+	switch {
+	case err != nil:
+		resp.Registration = registrationFailed
+		resp.Reason = err.Error()
+	case !created:
+		resp.Registration = registrationAlreadyExisted
+	}
+	s.recordGreeting(reqData.FirstName, reqData.LastName)
+
+	start = timing.start()
+	res, err := s.renderUserGreeting(w, r, reqData)
+	timing.end(phaseRender, start)
+	if err != nil {
+		s.logger.Error("error rendering greeting", "err", err)
+		httpx.WriteError(w, http.StatusInternalServerError, codeInternal, "error rendering greeting")
+		return
+	}
+	resp.Greeting = res.Greeting
+
+	w.Header().Set("Content-Language", res.Language)
+	httpx.WriteJSON(w, http.StatusOK, resp)
+}
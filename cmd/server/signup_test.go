@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func postSignup(t *testing.T, s *Server, body string) signupResponse {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	s.handleJSON(w, httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n",
+			http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp signupResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("error decoding signup response: %v\nbody: %s\n", err, w.Body.String())
+	}
+	return resp
+}
+
+func TestSignupRegistersThenConverges(t *testing.T) {
+	s := newTestServer(t)
+	s.registerOnGreet = true
+	body := `{"FirstName":"jhon","LastName":"smith","Email":"foo@bar.com"}`
+
+	resp := postSignup(t, s, body)
+	if resp.Registration != registrationRegistered || resp.Greeting != "Hello jhon!" {
+		t.Errorf("bad first response: %+v", resp)
+	}
+
+	resp = postSignup(t, s, body)
+	if resp.Registration != registrationAlreadyExisted || resp.Reason != "" {
+		t.Errorf("bad retry response: %+v", resp)
+	}
+
+	if n := len(s.users.GetAllUsers()); n != 1 {
+		t.Errorf("bad user count: expected 1, got %d", n)
+	}
+}
+
+func TestSignupValidationFailureStillGreets(t *testing.T) {
+	s := newTestServer(t)
+	s.registerOnGreet = true
+
+	resp := postSignup(t, s, `{"FirstName":"jhon","Email":"foo@bar.com"}`)
+	if resp.Registration != registrationFailed {
+		t.Errorf("bad registration: expected %q, got %q", registrationFailed, resp.Registration)
+	}
+	if resp.Greeting != "Hello jhon!" || !strings.Contains(resp.Reason, "last name") {
+		t.Errorf("bad failure response: %+v", resp)
+	}
+	if n := len(s.users.GetAllUsers()); n != 0 {
+		t.Errorf("bad user count: expected 0, got %d", n)
+	}
+}
+
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (fw failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestSignupWriteFailure(t *testing.T) {
+	s := newTestServer(t)
+	s.registerOnGreet = true
+	body := `{"FirstName":"jhon","LastName":"smith","Email":"foo@bar.com"}`
+
+	w := failingWriter{httptest.NewRecorder()}
+	s.handleJSON(w, httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(body)))
+
+	if n := len(s.users.GetAllUsers()); n != 1 {
+		t.Fatalf("bad user count after failed write: expected 1, got %d", n)
+	}
+
+	resp := postSignup(t, s, body)
+	if resp.Registration != registrationAlreadyExisted {
+		t.Errorf("retry after failed write: expected %q, got %q",
+			registrationAlreadyExisted, resp.Registration)
+	}
+}
+
+func TestSignupUsesRequestContext(t *testing.T) {
+	s := newTestServer(t)
+	s.registerOnGreet = true
+	body := `{"FirstName":"jhon","LastName":"smith","Email":"foo@bar.com"}`
+
+	ctx := users.WithActor(context.Background(), "signup@example.com")
+	w := httptest.NewRecorder()
+	s.handleJSON(w, httptest.NewRequestWithContext(ctx, http.MethodPost, "/json", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+	}
+	entries := s.users.Audit().(users.AuditReader).Last(10)
+	if len(entries) != 1 || entries[0].Op != users.AuditAdd || entries[0].Actor != "signup@example.com" {
+		t.Errorf("signup not audited under the request's actor: %+v", entries)
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	w = httptest.NewRecorder()
+	// handleJSON would stop before signing up on a done context.
+	s.handleSignup(w, httptest.NewRequestWithContext(expired, http.MethodPost, "/json", nil),
+		CreateUserRequest{FirstName: "jane", LastName: "smith", Email: "jane@bar.com"})
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("bad response code past the store deadline: expected %d, got %d\nbody: %s\n", http.StatusGatewayTimeout, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeStoreTimeout, "")
+	if n := len(s.users.GetAllUsers()); n != 1 {
+		t.Errorf("bad user count: expected 1, got %d", n)
+	}
+}
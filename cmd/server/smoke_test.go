@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/fixtures"
+	"github.com/kunalkumar-1/go-http/internal/smoke"
+)
+
+func TestSmokeSequence(t *testing.T) {
+	srv, m := startTestServer(t, &fixtures.Fixture{})
+	addAdmin(t, m)
+
+	report := smoke.Run(context.Background(), smoke.Config{
+		Server:   srv.URL,
+		Username: testAdminEmail,
+		Password: testAdminPassword,
+		Client:   srv.Client(),
+	})
+	for _, step := range report.Steps {
+		if !step.OK {
+			t.Errorf("step %s failed: %s", step.Name, step.Error)
+		}
+	}
+	if !report.Passed {
+		t.Error("smoke report did not pass")
+	}
+
+	if n := len(m.GetAllUsers()) - 1; n != 0 {
+		t.Errorf("smoke test user left behind: %d users besides the admin", n)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+const (
+	// defaultStatsNames bounds the distinct names a greetingCounter tracks.
+	defaultStatsNames = 10000
+
+	defaultStatsTop = 10
+	maxStatsTop     = 100
+)
+
+// greetingCounter counts greetings per name. It tracks at most limit
+// distinct names; when a new name arrives at the limit, the name with the
+// lowest count is evicted and the counter reports itself as truncated from
+// then on. Totals are never truncated.
+type greetingCounter struct {
+	mu        sync.Mutex
+	limit     int
+	counts    map[string]int
+	total     int
+	truncated bool
+}
+
+func newGreetingCounter(limit int) *greetingCounter {
+	return &greetingCounter{limit: limit, counts: make(map[string]int)}
+}
+
+func (c *greetingCounter) add(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.total++
+	if _, ok := c.counts[name]; !ok && len(c.counts) >= c.limit {
+		if c.limit <= 0 {
+			c.truncated = true
+			return
+		}
+		c.evictLowest()
+	}
+	c.counts[name]++
+}
+
+// evictLowest removes the least greeted name, breaking ties by name so the
+// choice is deterministic.
+func (c *greetingCounter) evictLowest() {
+	var lowest string
+	first := true
+	for name, count := range c.counts {
+		if first || count < c.counts[lowest] || (count == c.counts[lowest] && name < lowest) {
+			lowest = name
+			first = false
+		}
+	}
+	delete(c.counts, lowest)
+	c.truncated = true
+}
+
+type nameCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// top returns the n most greeted names, most greeted first, together with
+// the totals.
+func (c *greetingCounter) top(n int) (top []nameCount, distinct int, total int, truncated bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	top = make([]nameCount, 0, len(c.counts))
+	for name, count := range c.counts {
+		top = append(top, nameCount{Name: name, Count: count})
+	}
+	slices.SortFunc(top, func(a, b nameCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Name, b.Name))
+	})
+	return top[:min(n, len(top))], len(c.counts), c.total, c.truncated
+}
+
+type statsResponse struct {
+	Top            jsonList[nameCount] `json:"top"`
+	DistinctNames  int                 `json:"distinct_names"`
+	Truncated      bool                `json:"truncated"`
+	TotalGreetings int                 `json:"total_greetings"`
+	TotalRequests  uint64              `json:"total_requests"`
+	InFlight       int                 `json:"in_flight_requests"`
+	Shed           uint64              `json:"shed_requests"`
+	UptimeSeconds  float64             `json:"uptime_seconds"`
+	Routes         jsonList[routeStat] `json:"routes"`
+}
+
+// handleStats reports the most greeted names, ?top= selecting how many, and
+// the request counts, error counts and latency percentiles of each route.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	n := defaultStatsTop
+	if v := r.URL.Query().Get("top"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 || parsed > maxStatsTop {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "invalid top")
+			return
+		}
+		n = parsed
+	}
+
+	top, distinct, total, truncated := s.greetings.top(n)
+	httpx.WriteJSON(w, http.StatusOK, statsResponse{
+		Top:            top,
+		DistinctNames:  distinct,
+		Truncated:      truncated,
+		TotalGreetings: total,
+		TotalRequests:  s.requests.Load(),
+		InFlight:       s.limiter.inFlight(),
+		Shed:           s.limiter.shedCount(),
+		UptimeSeconds:  s.now().Sub(s.started).Seconds(),
+		Routes:         s.routeStats.stats(),
+	})
+}
+
+// withRequestCount counts every request served by h.
+func (s *Server) withRequestCount(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getStats(t *testing.T, handler http.Handler, target string) statsResponse {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n",
+			http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var stats statsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal("error decoding stats:", err)
+	}
+	return stats
+}
+
+func TestStatsCountsEveryGreeting(t *testing.T) {
+	s := newTestServer(t)
+	s.greetings = newGreetingCounter(10)
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	s.started = start
+	s.now = func() time.Time { return start.Add(90 * time.Second) }
+	handler := s.Routes()
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/hello/?user=alice", nil),
+		httptest.NewRequest(http.MethodGet, "/responses/alice/hello/", nil),
+		httptest.NewRequest(http.MethodGet, "/api/v1/hello/bob", nil),
+	}
+	body := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(`{"FirstName":"alice"}`))
+	body.Header.Set("Content-Type", "application/json")
+	header := httptest.NewRequest(http.MethodGet, "/user/hello", nil)
+	header.Header.Set("user", "carol")
+	requests = append(requests, body, header)
+
+	for _, r := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	stats := getStats(t, handler, "/stats?top=2")
+	want := []nameCount{{Name: "alice", Count: 3}, {Name: "bob", Count: 1}}
+	if !reflect.DeepEqual([]nameCount(stats.Top), want) {
+		t.Errorf("bad top names: expected %+v, got %+v", want, stats.Top)
+	}
+	if stats.TotalGreetings != 5 || stats.DistinctNames != 3 || stats.Truncated {
+		t.Errorf("bad totals: %+v", stats)
+	}
+	if stats.TotalRequests != 6 {
+		t.Errorf("bad request count: expected 6, got %d", stats.TotalRequests)
+	}
+	if stats.UptimeSeconds != 90 {
+		t.Errorf("bad uptime: expected 90, got %v", stats.UptimeSeconds)
+	}
+}
+
+func TestGreetingCounterEviction(t *testing.T) {
+	c := newGreetingCounter(2)
+	for _, name := range []string{"alice", "alice", "bob", "carol", "carol"} {
+		c.add(name)
+	}
+
+	top, distinct, total, truncated := c.top(10)
+	want := []nameCount{{Name: "alice", Count: 2}, {Name: "carol", Count: 2}}
+	if !reflect.DeepEqual(top, want) {
+		t.Errorf("expected %+v, got %+v", want, top)
+	}
+	if distinct != 2 || total != 5 || !truncated {
+		t.Errorf("bad totals: distinct %d, total %d, truncated %v", distinct, total, truncated)
+	}
+}
+
+func TestStatsInvalidTop(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	for _, target := range []string{"/stats?top=0", "/stats?top=101", "/stats?top=x"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: bad response code: expected %d, got %d", target, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	if stats := getStats(t, handler, "/stats"); stats.Top == nil || len(stats.Top) != 0 {
+		t.Errorf("expected empty top list, got %#v", stats.Top)
+	}
+}
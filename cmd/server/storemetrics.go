@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// storeMetrics is the users.Metrics sink behind /metrics. It keeps plain
+// counters and writes them in the Prometheus text exposition format.
+type storeMetrics struct {
+	usersTotal atomic.Int64
+	added      atomic.Uint64
+	deleted    atomic.Uint64
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+
+	mu       sync.Mutex
+	failures map[string]uint64
+}
+
+var _ users.Metrics = (*storeMetrics)(nil)
+
+func newStoreMetrics() *storeMetrics {
+	m := &storeMetrics{failures: make(map[string]uint64)}
+	for _, reason := range users.AddFailureReasons {
+		m.failures[reason] = 0
+	}
+	return m
+}
+
+func (m *storeMetrics) UserAdded()       { m.added.Add(1) }
+func (m *storeMetrics) UserDeleted()     { m.deleted.Add(1) }
+func (m *storeMetrics) UsersTotal(n int) { m.usersTotal.Store(int64(n)) }
+
+func (m *storeMetrics) AddFailed(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures[reason]++
+}
+
+func (m *storeMetrics) Lookup(hit bool) {
+	if hit {
+		m.hits.Add(1)
+	} else {
+		m.misses.Add(1)
+	}
+}
+
+// writeTo writes every metric, with the failure reasons in sorted order so
+// the output is stable.
+func (m *storeMetrics) writeTo(w io.Writer) {
+	fmt.Fprintf(w, "# TYPE users_total gauge\nusers_total %d\n", m.usersTotal.Load())
+	fmt.Fprintf(w, "# TYPE users_added_total counter\nusers_added_total %d\n", m.added.Load())
+
+	m.mu.Lock()
+	reasons := make([]string, 0, len(m.failures))
+	for reason := range m.failures {
+		reasons = append(reasons, reason)
+	}
+	slices.Sort(reasons)
+	fmt.Fprintf(w, "# TYPE users_add_failures_total counter\n")
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "users_add_failures_total{reason=%q} %d\n", reason, m.failures[reason])
+	}
+	m.mu.Unlock()
+
+	fmt.Fprintf(w, "# TYPE users_deleted_total counter\nusers_deleted_total %d\n", m.deleted.Load())
+	fmt.Fprintf(w, "# TYPE users_lookups_total counter\n")
+	fmt.Fprintf(w, "users_lookups_total{result=\"hit\"} %d\n", m.hits.Load())
+	fmt.Fprintf(w, "users_lookups_total{result=\"miss\"} %d\n", m.misses.Load())
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestMetricsFromStore(t *testing.T) {
+	sink := newStoreMetrics()
+	manager := users.NewManager(users.WithMetrics(sink))
+	s := NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), manager)
+	s.storeMetrics = sink
+
+	manager.AddUser(context.Background(), "Alice", "Smith", "alice@example.com")
+	manager.AddUser(context.Background(), "Bob", "Jones", "bob@example.com")
+	manager.AddUser(context.Background(), "Alice", "Smith", "alice2@example.com")
+	manager.AddUser(context.Background(), "Carol", "White", "bad address")
+	manager.GetUserByName(context.Background(), "Alice", "Smith")
+	manager.GetUserByName(context.Background(), "Dave", "Brown")
+	manager.DeleteUser(context.Background(), "Bob", "Jones")
+
+	w := httptest.NewRecorder()
+	s.handleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	for _, want := range []string{
+		"users_total 1\n",
+		"users_added_total 2\n",
+		`users_add_failures_total{reason="duplicate_email"} 0` + "\n",
+		`users_add_failures_total{reason="duplicate_user"} 1` + "\n",
+		`users_add_failures_total{reason="invalid_email"} 1` + "\n",
+		`users_add_failures_total{reason="invalid_name"} 0` + "\n",
+		"users_deleted_total 1\n",
+		`users_lookups_total{result="hit"} 1` + "\n",
+		`users_lookups_total{result="miss"} 1` + "\n",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected %q in metrics:\n%s", want, w.Body.String())
+		}
+	}
+	if n := strings.Count(w.Body.String(), "# TYPE users_total "); n != 1 {
+		t.Errorf("expected users_total once, got %d", n)
+	}
+}
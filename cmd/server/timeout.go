@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+// Timeouts bounds how long the server spends on a connection and on each
+// request. Zero disables the corresponding limit.
+type Timeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+
+	// Handler bounds routes wrapped with withTimeout. When it expires the
+	// client gets a 503 timeout error while the handler is abandoned.
+	Handler time.Duration
+
+	// Store bounds the users.Store calls of routes wrapped with
+	// withStoreDeadline; a store that overruns it gets the client a 504.
+	// It should be shorter than Handler, or the 503 is sent first.
+	Store time.Duration
+
+	// BodyRead bounds the time the body of a groupAPI or groupLegacy
+	// route may take to arrive; see withBodyReadDeadline.
+	BodyRead time.Duration
+}
+
+func defaultTimeouts() Timeouts {
+	return Timeouts{
+		ReadHeader: 5 * time.Second,
+		Read:       30 * time.Second,
+		Write:      60 * time.Second,
+		Idle:       120 * time.Second,
+		Handler:    10 * time.Second,
+		Store:      5 * time.Second,
+		BodyRead:   5 * time.Second,
+	}
+}
+
+// timeoutBody is the error response sent by http.TimeoutHandler.
+const timeoutBody = `{"error":{"code":"` + codeTimeout + `","message":"request timed out"}}` + "\n"
+
+// jsonTimeoutWriter labels the body http.TimeoutHandler writes on timeout as
+// JSON. Responses the handler itself produced keep their own Content-Type.
+type jsonTimeoutWriter struct {
+	http.ResponseWriter
+}
+
+func (w jsonTimeoutWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w jsonTimeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withTimeout answers with 503 once the handler timeout expires. The handler
+// sees the deadline on its request context.
+func (s *Server) withTimeout(h http.HandlerFunc) http.Handler {
+	if s.timeouts.Handler <= 0 {
+		return h
+	}
+
+	// The TimeoutHandler's writer hides the tracked one, so track again
+	// inside it.
+	th := http.TimeoutHandler(httpx.Track(s.logger, h), s.timeouts.Handler, timeoutBody)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		th.ServeHTTP(jsonTimeoutWriter{w}, r)
+	})
+}
+
+// withStoreDeadline puts the store timeout on the request context, which
+// handlers pass to every users.Store call.
+func (s *Server) withStoreDeadline(h http.Handler) http.Handler {
+	if s.timeouts.Store <= 0 {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Store)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// HTTPServer returns an http.Server for addr using the server's routes and
+// connection timeouts.
+func (s *Server) HTTPServer(addr string) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Routes(),
+		ReadHeaderTimeout: s.timeouts.ReadHeader,
+		ReadTimeout:       s.timeouts.Read,
+		WriteTimeout:      s.timeouts.Write,
+		IdleTimeout:       s.timeouts.Idle,
+	}
+	srv.RegisterOnShutdown(s.closeWebSockets)
+	return srv
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestHandlerTimeout(t *testing.T) {
+	s := newTestServer(t)
+	s.timeouts.Handler = 50 * time.Millisecond
+
+	handler := s.withTimeout(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+		w.Write([]byte("too late\n"))
+	})
+
+	w := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("timeout not applied: took %v", elapsed)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("bad response code: expected %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	assertErrorCode(t, w, codeTimeout, "request timed out")
+}
+
+func TestHandlerTimeoutFastRequests(t *testing.T) {
+	s := newTestServer(t)
+	s.timeouts.Handler = 50 * time.Millisecond
+	handler := s.Routes()
+
+	for range 20 {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/?user=fast", nil))
+
+		if w.Code != http.StatusOK || w.Body.String() != "Hello fast!\n" {
+			t.Fatalf("fast request affected by timeout: %d %q", w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); ct == "application/json" {
+			t.Fatalf("fast response labelled as JSON")
+		}
+	}
+}
+
+func TestHTTPServerTimeouts(t *testing.T) {
+	s := newTestServer(t)
+	srv := s.HTTPServer(":0")
+
+	want := defaultTimeouts()
+	if srv.ReadHeaderTimeout != want.ReadHeader || srv.ReadTimeout != want.Read ||
+		srv.WriteTimeout != want.Write || srv.IdleTimeout != want.Idle {
+		t.Errorf("connection timeouts not applied: %+v", srv)
+	}
+	if srv.ReadHeaderTimeout == 0 {
+		t.Error("no default ReadHeaderTimeout")
+	}
+}
+
+// slowStore is a users.Store whose every call takes delay, or until the
+// context is done, before it defers to the wrapped store.
+type slowStore struct {
+	users.Store
+	delay time.Duration
+}
+
+func (s slowStore) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s slowStore) AddUser(ctx context.Context, firstName string, lastName string, email string) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	return s.Store.AddUser(ctx, firstName, lastName, email)
+}
+
+func (s slowStore) GetUserByEmail(ctx context.Context, email string) (*users.User, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.Store.GetUserByEmail(ctx, email)
+}
+
+func TestStoreTimeout(t *testing.T) {
+	s := newTestServer(t)
+	s.users.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	s.store = slowStore{Store: s.users, delay: 2 * time.Second}
+	s.timeouts.Store = 50 * time.Millisecond
+	handler := s.Routes()
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/users/foo@bar.com", nil),
+		httptest.NewRequest(http.MethodGet, "/api/v1/users/foo@bar.com", nil),
+		httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"FirstName":"jane","LastName":"doe","Email":"jane@bar.com"}`)),
+	}
+	requests[2].Header.Set("Content-Type", "application/json")
+	for _, r := range requests {
+		w := httptest.NewRecorder()
+		start := time.Now()
+		handler.ServeHTTP(w, r)
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s %s: store deadline not applied: took %v", r.Method, r.URL, elapsed)
+		}
+		if w.Code != http.StatusGatewayTimeout {
+			t.Errorf("%s %s: bad response code: expected %d, got %d\nbody: %s\n", r.Method, r.URL, http.StatusGatewayTimeout, w.Code, w.Body.String())
+			continue
+		}
+		assertErrorCode(t, w, codeStoreTimeout, "user store did not respond in time")
+	}
+}
+
+func TestStoreTimeoutFastCalls(t *testing.T) {
+	s := newTestServer(t)
+	s.users.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	s.store = slowStore{Store: s.users, delay: time.Millisecond}
+	s.timeouts.Store = time.Second
+	handler := s.Routes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/foo@bar.com", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var got UserResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Email != "foo@bar.com" {
+		t.Errorf("unexpected user: %+v (%v)", got, err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Phase names reported in the Server-Timing header and in the slow request
+// log. They are stable so that frontend tooling and log queries can rely on
+// them.
+const (
+	phaseAuth     = "auth"
+	phaseBodyRead = "body-read"
+	phaseStore    = "store"
+	phaseRender   = "render"
+)
+
+// timingHeader is the request header a client sets to opt in to the
+// Server-Timing response header when debug timing is not enabled server-wide.
+const timingHeader = "X-Debug-Timing"
+
+type timingKey struct{}
+
+type phaseTiming struct {
+	name string
+	dur  time.Duration
+}
+
+// serverTiming accumulates per-phase durations for a single request.
+// A nil *serverTiming is valid and records nothing, so handlers can measure
+// unconditionally without allocating for requests that did not opt in.
+type serverTiming struct {
+	mu     sync.Mutex
+	phases []phaseTiming
+}
+
+func timingFrom(ctx context.Context) *serverTiming {
+	t, _ := ctx.Value(timingKey{}).(*serverTiming)
+	return t
+}
+
+// withTiming returns r carrying a serverTiming, and that timing. A timing
+// already in r's context is reused, so phases are recorded once however
+// many layers read them.
+func withTiming(r *http.Request) (*http.Request, *serverTiming) {
+	if t := timingFrom(r.Context()); t != nil {
+		return r, t
+	}
+	t := &serverTiming{}
+	return r.WithContext(context.WithValue(r.Context(), timingKey{}, t)), t
+}
+
+// start returns the start time of a phase, or the zero time when timing is off.
+func (t *serverTiming) start() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// end records the duration of phase since start. Repeated phases accumulate.
+func (t *serverTiming) end(phase string, start time.Time) {
+	if t == nil {
+		return
+	}
+	d := time.Since(start)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.phases {
+		if t.phases[i].name == phase {
+			t.phases[i].dur += d
+			return
+		}
+	}
+	t.phases = append(t.phases, phaseTiming{name: phase, dur: d})
+}
+
+// header renders the recorded phases as a Server-Timing value with
+// durations in milliseconds.
+func (t *serverTiming) header() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var sb strings.Builder
+	for i, p := range t.phases {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(p.name)
+		sb.WriteString(";dur=")
+		sb.WriteString(strconv.FormatFloat(float64(p.dur)/float64(time.Millisecond), 'f', 3, 64))
+	}
+	return sb.String()
+}
+
+// logAttr returns the recorded phases as a "phases_ms" group keyed by phase
+// name, with durations in milliseconds.
+func (t *serverTiming) logAttr() slog.Attr {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	attrs := make([]any, len(t.phases))
+	for i, p := range t.phases {
+		attrs[i] = slog.Float64(p.name, milliseconds(p.dur))
+	}
+	return slog.Group("phases_ms", attrs...)
+}
+
+// timingWriter adds the Server-Timing header just before the response
+// headers are sent.
+type timingWriter struct {
+	http.ResponseWriter
+	timing      *serverTiming
+	wroteHeader bool
+}
+
+func (tw *timingWriter) WriteHeader(code int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		if v := tw.timing.header(); v != "" {
+			tw.Header().Set("Server-Timing", v)
+		}
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timingWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// withServerTiming emits a Server-Timing header when enabled is true or the
+// request carries the opt-in header. Requests that do not opt in are passed
+// through untouched.
+func withServerTiming(next http.Handler, enabled bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !enabled && r.Header.Get(timingHeader) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r, t := withTiming(r)
+		next.ServeHTTP(&timingWriter{ResponseWriter: w, timing: t}, r)
+	})
+}
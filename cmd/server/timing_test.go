@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestServerTimingOptIn(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/json", bytes.NewBufferString(`{"FirstName":"human"}`))
+	req.Header.Set(timingHeader, "1")
+	w := httptest.NewRecorder()
+
+	withServerTiming(http.HandlerFunc(newTestServer(t).handleJSON), false).ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n",
+			http.StatusOK, w.Code, w.Body.String())
+	}
+
+	header := w.Header().Get("Server-Timing")
+	if header == "" {
+		t.Fatal("missing Server-Timing header")
+	}
+
+	phases := map[string]float64{}
+	for _, entry := range strings.Split(header, ", ") {
+		name, dur, ok := strings.Cut(entry, ";dur=")
+		if !ok {
+			t.Fatalf("malformed Server-Timing entry %q", entry)
+		}
+		ms, err := strconv.ParseFloat(dur, 64)
+		if err != nil {
+			t.Fatalf("bad duration in entry %q: %v", entry, err)
+		}
+		phases[name] = ms
+	}
+
+	for _, phase := range []string{phaseBodyRead, phaseRender} {
+		ms, ok := phases[phase]
+		if !ok {
+			t.Errorf("missing phase %q in %q", phase, header)
+			continue
+		}
+		if ms < 0 || ms > 1000 {
+			t.Errorf("implausible duration for %q: %vms", phase, ms)
+		}
+	}
+}
+
+func TestServerTimingNotOptedIn(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/json", bytes.NewBufferString(`{"FirstName":"human"}`))
+	w := httptest.NewRecorder()
+
+	withServerTiming(http.HandlerFunc(newTestServer(t).handleJSON), false).ServeHTTP(w, req)
+
+	if got := w.Header().Get("Server-Timing"); got != "" {
+		t.Errorf("unexpected Server-Timing header: %q", got)
+	}
+}
+
+func TestServerTimingServerWide(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/hello?user=Testing", nil)
+	w := httptest.NewRecorder()
+
+	withServerTiming(http.HandlerFunc(newTestServer(t).handleHelloParameterized), true).ServeHTTP(w, req)
+
+	if got := w.Header().Get("Server-Timing"); !strings.HasPrefix(got, phaseRender+";dur=") {
+		t.Errorf("bad Server-Timing header: %q", got)
+	}
+}
+
+func TestServerTimingNoAllocsWhenDisabled(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		timing := timingFrom(req.Context())
+		start := timing.start()
+		timing.end(phaseStore, start)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations without opt-in, got %v", allocs)
+	}
+}
+
+func TestServerTimingAuth(t *testing.T) {
+	m := users.NewManager()
+	addAdmin(t, m)
+	if err := m.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewServer(newTestServer(t).logger, m).Routes()
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/users/jhon@bar.com", nil)
+	req.SetBasicAuth(testAdminEmail, testAdminPassword)
+	req.Header.Set(timingHeader, "1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Server-Timing"); !strings.Contains(got, phaseAuth+";dur=") {
+		t.Errorf("auth phase missing from Server-Timing header: %q", got)
+	}
+}
+
+func TestServerTimingStore(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(`{"first_name":"jhon","last_name":"smith","email":"jhon@bar.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(timingHeader, "1")
+	w := httptest.NewRecorder()
+	newTestServer(t).Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Server-Timing"); !strings.Contains(got, phaseStore+";dur=") {
+		t.Errorf("store phase missing from Server-Timing header: %q", got)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestSelfSignedTLS(t *testing.T) {
+	cfg, err := selfSignedTLSConfig()
+	if err != nil {
+		t.Fatalf("error generating self-signed config: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+
+	srv := newTestServer(t).HTTPServer(ln.Addr().String())
+	srv.TLSConfig = cfg
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/hello/?user=tls")
+	if err != nil {
+		t.Fatalf("error making HTTPS request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "Hello tls!\n" {
+		t.Errorf("bad response: %d %q", resp.StatusCode, body)
+	}
+	if resp.TLS == nil {
+		t.Error("response not served over TLS")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+)
+
+const (
+	defaultMaxURLLength        = 8 << 10
+	defaultMaxQueryParams      = 100
+	defaultMaxQueryValueLength = 1 << 10
+)
+
+// urlLimits bounds the request target before any handler parses it. A zero
+// limit is not enforced.
+type urlLimits struct {
+	// maxLength caps the request target, path and query together.
+	maxLength int
+	// maxParams caps the number of query parameters, repeats included.
+	maxParams int
+	// maxValueLength caps each query value, measured still escaped.
+	maxValueLength int
+}
+
+func defaultURLLimits() urlLimits {
+	return urlLimits{
+		maxLength:      defaultMaxURLLength,
+		maxParams:      defaultMaxQueryParams,
+		maxValueLength: defaultMaxQueryValueLength,
+	}
+}
+
+// withURLLimits rejects a request whose target is longer than the limit
+// with 414, and one with too many query parameters or an over-long value
+// with 400. It scans the raw query without decoding it, so the work and
+// memory spent on a rejected request do not grow with the query.
+func (s *Server) withURLLimits(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits := s.urlLimits
+
+		target := len(r.RequestURI)
+		if target == 0 {
+			target = len(r.URL.Path) + len(r.URL.RawQuery)
+		}
+		if limits.maxLength > 0 && target > limits.maxLength {
+			httpx.WriteError(w, http.StatusRequestURITooLong, codeURITooLong, "URL must not exceed "+strconv.Itoa(limits.maxLength)+" bytes")
+			return
+		}
+
+		params := 0
+		for rest := r.URL.RawQuery; rest != ""; {
+			var param string
+			param, rest, _ = strings.Cut(rest, "&")
+			if param == "" {
+				continue
+			}
+			params++
+			if limits.maxParams > 0 && params > limits.maxParams {
+				httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "at most "+strconv.Itoa(limits.maxParams)+" query parameters are allowed")
+				return
+			}
+			_, value, _ := strings.Cut(param, "=")
+			if limits.maxValueLength > 0 && len(value) > limits.maxValueLength {
+				httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "query values must not exceed "+strconv.Itoa(limits.maxValueLength)+" bytes")
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
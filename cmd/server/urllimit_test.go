@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestURLLimits(t *testing.T) {
+	s := newTestServer(t)
+	s.urlLimits = urlLimits{maxLength: 64, maxParams: 3, maxValueLength: 8}
+	handler := s.withURLLimits(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		code   string
+	}{
+		{"no query", "/hello/", http.StatusNoContent, ""},
+		{"normal", "/hello/?user=alice", http.StatusNoContent, ""},
+		{"length at limit", "/hello/?a=" + strings.Repeat("b", 8) + "&" + strings.Repeat("c", 64-19), http.StatusNoContent, ""},
+		{"length over limit", "/" + strings.Repeat("a", 64), http.StatusRequestURITooLong, codeURITooLong},
+		{"params at limit", "/hello/?a=1&a=2&b=3", http.StatusNoContent, ""},
+		{"params over limit", "/hello/?a=1&a=2&b=3&c=4", http.StatusBadRequest, codeInvalidRequest},
+		{"empty params not counted", "/hello/?a=1&&b=2&&c=3&", http.StatusNoContent, ""},
+		{"value at limit", "/hello/?user=" + strings.Repeat("a", 8), http.StatusNoContent, ""},
+		{"value over limit", "/hello/?user=" + strings.Repeat("a", 9), http.StatusBadRequest, codeInvalidRequest},
+		{"escaped value over limit", "/hello/?user=%41%41%41", http.StatusBadRequest, codeInvalidRequest},
+		{"bare key", "/hello/?" + strings.Repeat("k", 20), http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.status {
+				t.Fatalf("bad response code for %d byte target: expected %d, got %d", len(tt.target), tt.status, w.Code)
+			}
+			if tt.code != "" {
+				assertErrorCode(t, w, tt.code, "")
+			}
+		})
+	}
+}
+
+func TestURLLimitsDisabled(t *testing.T) {
+	s := newTestServer(t)
+	s.urlLimits = urlLimits{}
+	handler := s.withURLLimits(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/?user="+strings.Repeat("a", 1<<20)+strings.Repeat("&a=1", 1000), nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("zero limits enforced: got %d", w.Code)
+	}
+}
+
+func TestURLLimitsRoutes(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/?user="+strings.Repeat("a", 1<<20), nil))
+	if w.Code != http.StatusRequestURITooLong {
+		t.Errorf("1 MiB query: expected %d, got %d", http.StatusRequestURITooLong, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello/?user=alice&user=bob", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "Hello alice!\nHello bob!\n" {
+		t.Errorf("normal request affected: %d %q", w.Code, w.Body.String())
+	}
+}
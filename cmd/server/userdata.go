@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/users"
+	"golang.org/x/text/language"
+)
+
+// maxNameLength is the longest FirstName accepted by POST /json, in runes.
+const maxNameLength = users.MaxNameLength
+
+// Validate checks the fields POST /json reads and returns a
+// users.ValidationErrors naming every problem, or nil. Field names match
+// the JSON request body.
+func (d CreateUserRequest) Validate() error {
+	var errs users.ValidationErrors
+	add := func(field string, format string, args ...any) {
+		errs = append(errs, users.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	errs = append(errs, users.NameRules{}.FieldErrors("first_name", d.FirstName)...)
+
+	var tag language.Tag
+	languageOK := true
+	if d.Language != "" {
+		var err error
+		tag, err = language.Parse(d.Language)
+		languageOK = err == nil
+	}
+	if !languageOK {
+		add("language", "must be a BCP 47 language tag such as en or pt-BR")
+	}
+
+	if d.Greeting != "" {
+		switch {
+		case !slices.Contains(greeting.Salutations, d.Greeting):
+			add("greeting", "must be one of %s", strings.Join(greeting.Salutations, ", "))
+		case d.Language != "" && languageOK && !isGreetingLanguage(tag):
+			add("greeting", "is English and cannot be combined with language %q", d.Language)
+		}
+	}
+
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// isGreetingLanguage reports whether tag is greeting.SalutationLanguage or
+// one of its regional variants.
+func isGreetingLanguage(tag language.Tag) bool {
+	base, _ := tag.Base()
+	return base.String() == greeting.SalutationLanguage
+}
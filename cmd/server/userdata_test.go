@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestCreateUserRequestValidate(t *testing.T) {
+	long := strings.Repeat("a", maxNameLength)
+	wide := strings.Repeat("é", maxNameLength)
+
+	tests := []struct {
+		name string
+		data CreateUserRequest
+		want []string // "Field: message" prefixes, in order
+	}{
+		{"name only", CreateUserRequest{FirstName: "alice"}, nil},
+		{"one rune", CreateUserRequest{FirstName: "a"}, nil},
+		{"max length", CreateUserRequest{FirstName: long}, nil},
+		{"max length multibyte", CreateUserRequest{FirstName: wide}, nil},
+		{"unicode", CreateUserRequest{FirstName: "Zoë 山田"}, nil},
+		{"template text is just a name", CreateUserRequest{FirstName: "{{.Name}}"}, nil},
+		{"every greeting", CreateUserRequest{FirstName: "alice", Greeting: "Good evening"}, nil},
+		{"language", CreateUserRequest{FirstName: "alice", Language: "fr"}, nil},
+		{"language with region", CreateUserRequest{FirstName: "alice", Language: "pt-BR"}, nil},
+		{"language with script", CreateUserRequest{FirstName: "alice", Language: "zh-Hant-TW"}, nil},
+		{"greeting in english", CreateUserRequest{FirstName: "alice", Greeting: "Hi", Language: "en"}, nil},
+		{"greeting in regional english", CreateUserRequest{FirstName: "alice", Greeting: "Hi", Language: "EN-gb"}, nil},
+		{"greeting in english with underscore", CreateUserRequest{FirstName: "alice", Greeting: "Hi", Language: "en_GB"}, nil},
+		{"language with private use", CreateUserRequest{FirstName: "alice", Language: "en-x-pirate"}, nil},
+		{"other fields ignored", CreateUserRequest{FirstName: "alice", LastName: "", Email: "not-an-email"}, nil},
+
+		{"empty name", CreateUserRequest{}, []string{"first_name: must not be empty"}},
+		{"blank name", CreateUserRequest{FirstName: " \t "}, []string{"first_name: must not be empty", "first_name: must not contain control characters"}},
+		{"spaces only", CreateUserRequest{FirstName: "   "}, []string{"first_name: must not be empty"}},
+		{"too long", CreateUserRequest{FirstName: long + "a"}, []string{"first_name: must be at most 100 characters, got 101"}},
+		{"too long multibyte", CreateUserRequest{FirstName: wide + "é"}, []string{"first_name: must be at most 100 characters, got 101"}},
+		{"nul", CreateUserRequest{FirstName: "al\x00ice"}, []string{"first_name: must not contain control characters"}},
+		{"newline", CreateUserRequest{FirstName: "alice\n"}, []string{"first_name: must not contain control characters"}},
+		{"escape", CreateUserRequest{FirstName: "\x1b[31malice"}, []string{"first_name: must not contain control characters"}},
+		{"delete", CreateUserRequest{FirstName: "alice\x7f"}, []string{"first_name: must not contain control characters"}},
+		{"C1 control", CreateUserRequest{FirstName: "alice\u0085"}, []string{"first_name: must not contain control characters"}},
+		{"too long with control", CreateUserRequest{FirstName: long + "\n"}, []string{"first_name: must be at most", "first_name: must not contain control characters"}},
+
+		{"unknown greeting", CreateUserRequest{FirstName: "alice", Greeting: "Yo"}, []string{"greeting: must be one of Hello, Hi"}},
+		{"greeting case", CreateUserRequest{FirstName: "alice", Greeting: "hello"}, []string{"greeting: must be one of"}},
+		{"greeting template", CreateUserRequest{FirstName: "alice", Greeting: "{{.Name}}"}, []string{"greeting: must be one of"}},
+		{"greeting padded", CreateUserRequest{FirstName: "alice", Greeting: " Hello"}, []string{"greeting: must be one of"}},
+
+		{"language of 5 to 8 letters", CreateUserRequest{FirstName: "alice", Language: "english"}, []string{"language: must be a BCP 47 language tag"}},
+		{"language too long", CreateUserRequest{FirstName: "alice", Language: "englishes"}, []string{"language: must be a BCP 47 language tag"}},
+		{"language trailing dash", CreateUserRequest{FirstName: "alice", Language: "fr-"}, []string{"language: must be a BCP 47 language tag"}},
+		{"language header syntax", CreateUserRequest{FirstName: "alice", Language: "fr;q=0.5"}, []string{"language: must be a BCP 47 language tag"}},
+		{"language list", CreateUserRequest{FirstName: "alice", Language: "fr, en"}, []string{"language: must be a BCP 47 language tag"}},
+
+		{"greeting with other language", CreateUserRequest{FirstName: "alice", Greeting: "Hello", Language: "fr"}, []string{`greeting: is English and cannot be combined with language "fr"`}},
+		{"greeting with bad language", CreateUserRequest{FirstName: "alice", Greeting: "Hello", Language: "??"}, []string{"language: must be a BCP 47 language tag"}},
+		{"everything wrong", CreateUserRequest{FirstName: "", Greeting: "Yo", Language: "??"}, []string{"first_name: must not be empty", "language: must be", "greeting: must be one of"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.data.Validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			var verrs users.ValidationErrors
+			if !errors.As(err, &verrs) {
+				t.Fatalf("expected users.ValidationErrors, got %T: %v", err, err)
+			}
+			got := make([]string, len(verrs))
+			for i, fe := range verrs {
+				got[i] = fe.Field + ": " + fe.Message
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d problems, got %q", len(tt.want), got)
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("problem %d: expected %q, got %q", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAllowedGreetingsAreTemplateFree(t *testing.T) {
+	for _, g := range greeting.Salutations {
+		if strings.ContainsAny(g, "{}") || strings.TrimSpace(g) != g {
+			t.Errorf("greeting %q is not plain text", g)
+		}
+		if err := (CreateUserRequest{FirstName: "alice", Greeting: g}).Validate(); err != nil {
+			t.Errorf("greeting %q rejected: %v", g, err)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kunalkumar-1/go-http/internal/httpx"
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// pagination is always present in list responses, zero-valued when empty.
+// NextCursor continues the list where the page ended; it is left out on the
+// last page and on pages requested by offset.
+type pagination struct {
+	Offset     int    `json:"offset"`
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type userListResponse struct {
+	Users      jsonList[UserResponse] `json:"users"`
+	Pagination pagination             `json:"pagination"`
+}
+
+// parseTags reads the optional ?tags= filter, a comma-separated list of
+// tags a user must all have. A nil result means no filtering.
+func parseTags(r *http.Request) ([]string, error) {
+	v := r.URL.Query().Get("tags")
+	if v == "" {
+		return nil, nil
+	}
+	tags := strings.Split(v, ",")
+	for i, tag := range tags {
+		var err error
+		if tags[i], err = users.ParseTag(tag); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// parsePage reads the offset and limit query parameters.
+func parsePage(r *http.Request) (offset int, limit int, ok bool) {
+	limit = defaultPageLimit
+
+	query := r.URL.Query()
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			return 0, 0, false
+		}
+		limit = n
+	}
+	return offset, limit, true
+}
+
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	offset, limit, ok := parsePage(r)
+	if !ok {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "invalid offset or limit")
+		return
+	}
+	verified, ok := parseVerified(r)
+	if !ok {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "invalid verified")
+		return
+	}
+	sortBy, err := users.ParseSortBy(r.URL.Query().Get("sort"))
+	if err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	tags, err := parseTags(r)
+	if err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	// Pages after the first are requested by cursor, which unlike an
+	// offset neither repeats nor skips users when others are added or
+	// deleted in between. Offsets are still accepted for older clients.
+	cursor := users.Cursor{Sort: sortBy}
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		if offset != 0 {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "cursor cannot be combined with offset")
+			return
+		}
+		if cursor, ok = s.verifyCursor(token); !ok {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidCursor, "invalid or expired cursor")
+			return
+		}
+		if cursor.Sort != sortBy {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidCursor, "cursor was issued for a different sort")
+			return
+		}
+	}
+	keep := func(u users.User) bool {
+		return (verified == nil || u.Verified == *verified) && u.HasTags(tags...)
+	}
+
+	// The revision is read first: a mutation made while listing only makes
+	// the ETag older than the body, which costs the client a refetch.
+	rev, err := s.store.Revision(r.Context())
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	etag := usersListETag(rev)
+	if notModified(w, r, etag) {
+		return
+	}
+
+	resp := userListResponse{
+		Pagination: pagination{Offset: offset, Limit: limit},
+	}
+	if offset == 0 {
+		page, next, err := s.store.ListAfter(r.Context(), cursor, limit, keep)
+		if errors.Is(err, users.ErrInvalidCursor) {
+			httpx.WriteError(w, http.StatusBadRequest, codeInvalidCursor, err.Error())
+			return
+		}
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+		for _, u := range page {
+			resp.Users = append(resp.Users, newUserResponse(u))
+		}
+		if next != nil {
+			resp.Pagination.NextCursor = s.signCursor(*next)
+		}
+		for u, err := range users.All(r.Context(), s.store) {
+			if err != nil {
+				writeUserError(w, err)
+				return
+			}
+			if keep(u) {
+				resp.Pagination.Total++
+			}
+		}
+	} else {
+		all, _, err := s.store.ListAfter(r.Context(), users.Cursor{Sort: sortBy}, math.MaxInt, keep)
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+		resp.Pagination.Total = len(all)
+		start := min(offset, len(all))
+		for _, u := range all[start:min(start+limit, len(all))] {
+			resp.Users = append(resp.Users, newUserResponse(u))
+		}
+	}
+
+	w.Header().Set("ETag", etag)
+	httpx.WriteJSON(w, http.StatusOK, resp)
+}
+
+// userETag is a strong ETag for u, changing whenever u does.
+func userETag(u users.User) string {
+	return `"user-` + strconv.FormatUint(u.Version, 10) + `"`
+}
+
+// usersListETag is a weak ETag for the user list at the manager revision
+// rev. It is weak because the body also depends on the query.
+func usersListETag(rev uint64) string {
+	return `W/"users-` + strconv.FormatUint(rev, 10) + `"`
+}
+
+// etagMatches reports whether an If-Match header value matches etag. Only
+// strong comparison is done, so weak validators never match.
+func etagMatches(ifMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseETags splits an If-None-Match header value into its entity tags. It
+// reports false unless every member is "*" or a quoted tag, optionally
+// marked weak.
+func parseETags(header string) ([]string, bool) {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		opaque := strings.TrimPrefix(tag, "W/")
+		if tag != "*" && (len(opaque) < 2 || opaque[0] != '"' || opaque[len(opaque)-1] != '"' || strings.Count(opaque, `"`) != 2) {
+			return nil, false
+		}
+		tags = append(tags, tag)
+	}
+	return tags, true
+}
+
+// notModified answers a GET whose If-None-Match matches etag with 304 and
+// reports whether it did. If-None-Match uses weak comparison. A malformed
+// header is treated as absent.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	tags, ok := parseETags(header)
+	if !ok {
+		return false
+	}
+	for _, tag := range tags {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// writeUserError maps a users.Store or users.Manager error to an error
+// response. A store that ran out of time is a 504.
+func writeUserError(w http.ResponseWriter, err error) {
+	var verrs users.ValidationErrors
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		httpx.WriteError(w, http.StatusGatewayTimeout, codeStoreTimeout, "user store did not respond in time")
+	case errors.As(err, &verrs):
+		writeValidationError(w, verrs)
+	case errors.Is(err, users.ErrUserDeleted):
+		httpx.WriteError(w, http.StatusGone, codeGone, "user was deleted")
+	case errors.Is(err, users.ErrNoResultFound):
+		httpx.WriteError(w, http.StatusNotFound, codeNotFound, "user not found")
+	case errors.Is(err, users.ErrDuplicateUser), errors.Is(err, users.ErrDuplicateEmail), errors.Is(err, users.ErrAmbiguousName):
+		httpx.WriteError(w, http.StatusConflict, codeConflict, err.Error())
+	case errors.Is(err, users.ErrInvalidToken):
+		httpx.WriteError(w, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, users.ErrTokenExpired):
+		httpx.WriteError(w, http.StatusGone, codeGone, err.Error())
+	case errors.Is(err, users.ErrVersionConflict):
+		httpx.WriteError(w, http.StatusPreconditionFailed, codePreconditionFailed, err.Error())
+	default:
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+	}
+}
+
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	var reqData CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "error parsing request body")
+		return
+	}
+
+	if err := s.users.NameRules().ValidateUser(reqData.FirstName, reqData.LastName, reqData.Email); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	timing := timingFrom(r.Context())
+	start := timing.start()
+	err := s.store.AddUser(r.Context(), reqData.FirstName, reqData.LastName, reqData.Email)
+	var user *users.User
+	if err == nil {
+		user, err = s.store.GetUserByEmail(r.Context(), reqData.Email)
+	}
+	timing.end(phaseStore, start)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	s.sendVerification(*user)
+
+	w.Header().Set("Location", "/users/"+url.PathEscape(user.Email.Address))
+	httpx.WriteJSON(w, http.StatusCreated, newUserResponse(*user))
+}
+
+func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	user, err := s.store.GetUserByEmail(r.Context(), r.PathValue("email"))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	etag := userETag(*user)
+	if notModified(w, r, etag) {
+		return
+	}
+	w.Header().Set("ETag", etag)
+	httpx.WriteJSON(w, http.StatusOK, newUserResponse(*user))
+}
+
+// handleGetUserByID looks a user up by the ID it was given when created.
+// The ID is a query parameter rather than a path segment because a
+// /users/by-id/{id} pattern would overlap /users/{email}/greetings.
+func (s *Server) handleGetUserByID(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "id must not be empty")
+		return
+	}
+	user, err := s.store.GetUserByID(r.Context(), id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	etag := userETag(*user)
+	if notModified(w, r, etag) {
+		return
+	}
+	w.Header().Set("ETag", etag)
+	httpx.WriteJSON(w, http.StatusOK, newUserResponse(*user))
+}
+
+func (s *Server) handleGetUserByName(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	user, err := s.store.GetUserByName(r.Context(), r.PathValue("first"), r.PathValue("last"))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	etag := userETag(*user)
+	if notModified(w, r, etag) {
+		return
+	}
+	w.Header().Set("ETag", etag)
+	httpx.WriteJSON(w, http.StatusOK, newUserResponse(*user))
+}
+
+type updateUserRequest struct {
+	Email string `json:"email"`
+}
+
+// handleUpdateUser changes a user's email. An If-Match header holding the
+// ETag from a previous read makes the update conditional: if the user has
+// changed since, nothing is written and the response is 412.
+func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	var reqData updateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+		httpx.WriteError(w, http.StatusBadRequest, codeInvalidRequest, "error parsing request body")
+		return
+	}
+
+	user, err := s.store.GetUserByEmail(r.Context(), r.PathValue("email"))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	version := users.AnyVersion
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !etagMatches(ifMatch, userETag(*user)) {
+			writeUserError(w, users.ErrVersionConflict)
+			return
+		}
+		if strings.TrimSpace(ifMatch) != "*" {
+			version = user.Version
+		}
+	}
+
+	err = s.store.UpdateUser(r.Context(), user.FirstName, user.LastName, reqData.Email, version)
+	if err == nil {
+		user, err = s.store.GetUserByName(r.Context(), user.FirstName, user.LastName)
+	}
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", userETag(*user))
+	httpx.WriteJSON(w, http.StatusOK, newUserResponse(*user))
+}
+
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	user, err := s.store.GetUserByEmail(r.Context(), r.PathValue("email"))
+	if err == nil {
+		err = s.store.DeleteUser(r.Context(), user.FirstName, user.LastName)
+	}
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRestoreUser brings back a soft-deleted user. It answers 404 when no
+// user with the email was ever deleted, or the tombstone has been purged,
+// and 409 when the user is not deleted or its name or email was reused.
+func (s *Server) handleRestoreUser(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	email := r.PathValue("email")
+	err := s.store.RestoreUserByEmail(r.Context(), email)
+	if errors.Is(err, users.ErrNoResultFound) {
+		if _, err := s.store.GetUserByEmail(r.Context(), email); err == nil {
+			httpx.WriteError(w, http.StatusConflict, codeConflict, "user is not deleted")
+			return
+		}
+	}
+	var user *users.User
+	if err == nil {
+		user, err = s.store.GetUserByEmail(r.Context(), email)
+	}
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", userETag(*user))
+	httpx.WriteJSON(w, http.StatusOK, newUserResponse(*user))
+}
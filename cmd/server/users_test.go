@@ -0,0 +1,634 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// collectionRoutes lists every route returning a JSON collection, with the
+// fields documented as arrays and whether the route is paginated. New list
+// routes must be added here.
+var collectionRoutes = []struct {
+	target string
+	arrays []string
+	paged  bool
+}{
+	{"/users", []string{"users"}, true},
+	{"/users/search?q=nobody", []string{"users"}, false},
+	{"/users/domains", []string{"domains"}, false},
+}
+
+// findNulls reports the path of every null value in a decoded JSON document.
+func findNulls(path string, v any) []string {
+	switch v := v.(type) {
+	case nil:
+		return []string{path}
+	case map[string]any:
+		var nulls []string
+		for k, child := range v {
+			nulls = append(nulls, findNulls(path+"."+k, child)...)
+		}
+		return nulls
+	case []any:
+		var nulls []string
+		for i, child := range v {
+			nulls = append(nulls, findNulls(fmt.Sprintf("%s[%d]", path, i), child)...)
+		}
+		return nulls
+	}
+	return nil
+}
+
+func TestListUsersByTags(t *testing.T) {
+	s := newTestServer(t)
+	for name, tags := range map[string][]string{
+		"ann": {"beta", "employee"},
+		"bob": {"beta"},
+		"cat": {"employee"},
+	} {
+		s.users.AddUser(context.Background(), name, "smith", name+"@bar.com")
+		for _, tag := range tags {
+			if err := s.users.AddTag(name, "smith", tag); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		target string
+		status int
+		names  []string
+	}{
+		{"/users?tags=beta", http.StatusOK, []string{"ann", "bob"}},
+		{"/users?tags=beta,employee", http.StatusOK, []string{"ann"}},
+		{"/api/v1/users?tags=Employee&sort=first_name", http.StatusOK, []string{"ann", "cat"}},
+		{"/users?tags=nobody", http.StatusOK, nil},
+		{"/users?tags=beta,,employee", http.StatusBadRequest, nil},
+		{"/users?tags=not_a_tag", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: bad response code: expected %d, got %d", tt.target, tt.status, w.Code)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			assertErrorCode(t, w, codeInvalidRequest, "")
+			continue
+		}
+
+		var resp userListResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		var got []string
+		for _, u := range resp.Users {
+			got = append(got, u.FirstName)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.names) || resp.Pagination.Total != len(tt.names) {
+			t.Errorf("%s: expected %v, got %v of %d", tt.target, tt.names, got, resp.Pagination.Total)
+		}
+		for _, u := range resp.Users {
+			if u.FirstName == "ann" && !slices.Equal(u.Tags, []string{"beta", "employee"}) {
+				t.Errorf("%s: ann has tags %v", tt.target, u.Tags)
+			}
+		}
+	}
+}
+
+func TestCollectionsEmptyNotNull(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	for _, route := range collectionRoutes {
+		t.Run(route.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, route.target, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("bad response code: expected %d, got %d", http.StatusOK, w.Code)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("error decoding response: %v\nbody: %s\n", err, w.Body.String())
+			}
+
+			if nulls := findNulls("$", body); len(nulls) > 0 {
+				t.Errorf("null values in empty response: %v\nbody: %s\n", nulls, w.Body.String())
+			}
+			for _, field := range route.arrays {
+				list, ok := body[field].([]any)
+				if !ok || len(list) != 0 {
+					t.Errorf("field %q: expected [], got %v", field, body[field])
+				}
+			}
+
+			if !route.paged {
+				return
+			}
+			page, ok := body["pagination"].(map[string]any)
+			if !ok {
+				t.Fatalf("missing pagination metadata\nbody: %s\n", w.Body.String())
+			}
+			for _, key := range []string{"offset", "total"} {
+				if page[key] != float64(0) {
+					t.Errorf("pagination %q: expected 0, got %v", key, page[key])
+				}
+			}
+		})
+	}
+}
+
+func TestListUsersPagination(t *testing.T) {
+	s := newTestServer(t)
+	for i := range 5 {
+		// Emails run the other way, so sorting by them reverses the list.
+		err := s.users.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", 4-i))
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	tests := []struct {
+		target string
+		status int
+		names  []string
+	}{
+		{"/users", http.StatusOK, []string{"first0", "first1", "first2", "first3", "first4"}},
+		{"/users?offset=1&limit=2", http.StatusOK, []string{"first1", "first2"}},
+		{"/users?offset=4&limit=2", http.StatusOK, []string{"first4"}},
+		{"/users?offset=10", http.StatusOK, nil},
+		{"/users?offset=-1", http.StatusBadRequest, nil},
+		{"/users?limit=0", http.StatusBadRequest, nil},
+		{"/users?limit=abc", http.StatusBadRequest, nil},
+		{"/users?sort=email", http.StatusOK, []string{"first4", "first3", "first2", "first1", "first0"}},
+		{"/users?sort=email&offset=1&limit=2", http.StatusOK, []string{"first3", "first2"}},
+		{"/users?sort=first_name&offset=3", http.StatusOK, []string{"first3", "first4"}},
+		{"/users?sort=nope", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		if w.Code != tt.status {
+			t.Errorf("%s: bad response code: expected %d, got %d", tt.target, tt.status, w.Code)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			assertErrorCode(t, w, codeInvalidRequest, "")
+			continue
+		}
+
+		var resp userListResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		if len(resp.Users) != len(tt.names) || resp.Pagination.Total != 5 {
+			t.Errorf("%s: bad page: %+v", tt.target, resp)
+			continue
+		}
+		for i, name := range tt.names {
+			if resp.Users[i].FirstName != name {
+				t.Errorf("%s: bad user at %d: expected %q, got %q", tt.target, i, name, resp.Users[i].FirstName)
+			}
+		}
+	}
+}
+
+func TestListUsersCursor(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.Routes()
+	for i := range 50 {
+		err := s.users.AddUser(context.Background(), fmt.Sprintf("first%02d", i), "last", fmt.Sprintf("user%02d@bar.com", 49-i))
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	list := func(target string) userListResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: bad response code: expected %d, got %d\nbody: %s\n", target, http.StatusOK, w.Code, w.Body.String())
+		}
+		var resp userListResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		return resp
+	}
+
+	// first14, user35@bar.com, would be on the fourth page; it is deleted
+	// while the client reads the second, which shifts every later offset
+	// by one.
+	seen := make(map[string]int)
+	pages := 0
+	var firstCursor string
+	for target := "/users?sort=email&limit=10"; ; {
+		resp := list(target)
+		pages++
+		for _, u := range resp.Users {
+			seen[u.FirstName]++
+		}
+		if pages == 1 {
+			firstCursor = resp.Pagination.NextCursor
+		}
+		if pages == 2 {
+			if err := s.users.DeleteUser(context.Background(), "first14", "last"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if resp.Pagination.NextCursor == "" {
+			break
+		}
+		target = "/users?sort=email&limit=10&cursor=" + resp.Pagination.NextCursor
+	}
+
+	if pages != 5 {
+		t.Errorf("expected 5 pages, got %d", pages)
+	}
+	if len(seen) != 49 || seen["first14"] != 0 {
+		t.Errorf("expected the 49 remaining users, got %d: %v", len(seen), seen)
+	}
+	for name, n := range seen {
+		if n != 1 {
+			t.Errorf("user %s listed %d times", name, n)
+		}
+	}
+
+	tampered := []byte(firstCursor)
+	tampered[0] ^= 1
+	tests := []struct {
+		target string
+		code   string
+	}{
+		{"/users?sort=email&cursor=" + string(tampered), codeInvalidCursor},
+		{"/users?sort=email&cursor=nodot", codeInvalidCursor},
+		{"/users?sort=first_name&cursor=" + firstCursor, codeInvalidCursor},
+		{"/users?sort=email&offset=10&cursor=" + firstCursor, codeInvalidRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: bad response code: expected %d, got %d", tt.target, http.StatusBadRequest, w.Code)
+			continue
+		}
+		assertErrorCode(t, w, tt.code, "")
+	}
+
+	// Another server, like this one after a restart, has its own secret.
+	w := httptest.NewRecorder()
+	newTestServer(t).Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?sort=email&cursor="+firstCursor, nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expired cursor: bad response code: expected %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	assertErrorCode(t, w, codeInvalidCursor, "")
+}
+
+func TestUsersCRUD(t *testing.T) {
+	s := newTestServer(t)
+	addAdmin(t, s.users)
+	handler := s.Routes()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(testAdminEmail, testAdminPassword)
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/users", `{"first_name":"jhon","last_name":"smith","email":"jhon@bar.com"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if loc := w.Header().Get("Location"); loc != "/users/jhon@bar.com" {
+		t.Errorf("bad Location header: %q", loc)
+	}
+
+	w = serve(http.MethodPost, "/users", `{"first_name":"jhon","last_name":"smith","email":"other@bar.com"}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("bad response code for duplicate: expected %d, got %d", http.StatusConflict, w.Code)
+	}
+	assertErrorCode(t, w, codeConflict, "")
+
+	w = serve(http.MethodPost, "/users", `{"first_name":"jane","last_name":"smith","email":"nope"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("bad response code for invalid email: expected %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	assertErrorCode(t, w, codeValidation, "validation failed")
+
+	w = serve(http.MethodGet, "/users/jhon@bar.com", "")
+	var user UserResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("bad get response: %d %s", w.Code, w.Body.String())
+	}
+	if user.FirstName != "jhon" || user.Email != "jhon@bar.com" {
+		t.Errorf("bad user: %+v", user)
+	}
+
+	w = serve(http.MethodDelete, "/users/jhon@bar.com", "")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("bad response code for delete: expected %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	w = serve(http.MethodGet, "/users/jhon@bar.com", "")
+	if w.Code != http.StatusGone {
+		t.Errorf("bad response code after delete: expected %d, got %d", http.StatusGone, w.Code)
+	}
+	assertErrorCode(t, w, codeGone, "")
+}
+
+func TestGetUserByID(t *testing.T) {
+	s := newTestServer(t)
+	addAdmin(t, s.users)
+	handler := s.Routes()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(testAdminEmail, testAdminPassword)
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/api/v1/users", `{"first_name":"jhon","last_name":"smith","email":"jhon@bar.com"}`)
+	var created UserResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("bad create response: %d %s", w.Code, w.Body.String())
+	}
+	if created.ID == "" {
+		t.Fatalf("created user has no id: %s", w.Body.String())
+	}
+
+	w = serve(http.MethodGet, "/api/v1/users/by-id?id="+created.ID, "")
+	var user UserResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("bad get response: %d %s", w.Code, w.Body.String())
+	}
+	if user.ID != created.ID || user.Email != "jhon@bar.com" {
+		t.Errorf("bad user: %+v", user)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("missing ETag")
+	}
+
+	tests := []struct {
+		target string
+		status int
+		code   string
+	}{
+		{"/api/v1/users/by-id", http.StatusBadRequest, codeInvalidRequest},
+		{"/api/v1/users/by-id?id=jhon@bar.com", http.StatusBadRequest, codeInvalidRequest},
+		{"/api/v1/users/by-id?id=00000000-0000-4000-8000-000000000000", http.StatusNotFound, codeNotFound},
+	}
+	for _, tc := range tests {
+		w := serve(http.MethodGet, tc.target, "")
+		if w.Code != tc.status {
+			t.Errorf("%s: bad response code: expected %d, got %d\nbody: %s\n", tc.target, tc.status, w.Code, w.Body.String())
+			continue
+		}
+		assertErrorCode(t, w, tc.code, "")
+	}
+
+	if w := serve(http.MethodDelete, "/api/v1/users/jhon@bar.com", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("bad response code for delete: expected %d, got %d", http.StatusNoContent, w.Code)
+	}
+	w = serve(http.MethodGet, "/api/v1/users/by-id?id="+created.ID, "")
+	if w.Code != http.StatusGone {
+		t.Errorf("bad response code after delete: expected %d, got %d", http.StatusGone, w.Code)
+	}
+	assertErrorCode(t, w, codeGone, "")
+}
+
+func TestConditionalGet(t *testing.T) {
+	s := newTestServer(t)
+	addAdmin(t, s.users)
+	if err := s.users.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+	handler := s.Routes()
+	get := func(target string, ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, target := range []string{"/users/jhon@bar.com", "/api/v1/users/jhon/smith", "/users", "/api/v1/users?limit=1"} {
+		t.Run(target, func(t *testing.T) {
+			w := get(target, "")
+			etag := w.Header().Get("ETag")
+			if w.Code != http.StatusOK || etag == "" {
+				t.Fatalf("first GET: expected %d with an ETag, got %d %q", http.StatusOK, w.Code, etag)
+			}
+
+			for _, header := range []string{etag, `"other", ` + etag, "*", "W/" + strings.TrimPrefix(etag, "W/")} {
+				w = get(target, header)
+				if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+					t.Errorf("If-None-Match %s: expected empty %d, got %d %q", header, http.StatusNotModified, w.Code, w.Body.String())
+				}
+				if got := w.Header().Get("ETag"); got != etag {
+					t.Errorf("If-None-Match %s: bad ETag on 304: %q", header, got)
+				}
+			}
+
+			for _, header := range []string{`"other"`, "garbage", strings.Trim(etag, `W/"`), etag + ", garbage"} {
+				if w = get(target, header); w.Code != http.StatusOK {
+					t.Errorf("If-None-Match %s: expected %d, got %d", header, http.StatusOK, w.Code)
+				}
+			}
+		})
+	}
+
+	listETag := get("/users", "").Header().Get("ETag")
+	if !strings.HasPrefix(listETag, "W/") {
+		t.Errorf("list ETag is not weak: %q", listETag)
+	}
+	userETag := get("/users/jhon@bar.com", "").Header().Get("ETag")
+	if strings.HasPrefix(userETag, "W/") {
+		t.Errorf("user ETag is not strong: %q", userETag)
+	}
+
+	if err := s.users.AddUser(context.Background(), "jane", "doe", "jane@bar.com"); err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+	if w := get("/users", listETag); w.Code != http.StatusOK {
+		t.Errorf("list ETag survived an add: got %d", w.Code)
+	}
+	if w := get("/users/jhon@bar.com", userETag); w.Code != http.StatusNotModified {
+		t.Errorf("another user's add changed the user ETag: got %d", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/users/jhon@bar.com", strings.NewReader(`{"email":"jhon@baz.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.SetBasicAuth(testAdminEmail, testAdminPassword)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code for update: expected %d, got %d", http.StatusOK, w.Code)
+	}
+	if w := get("/users/jhon@baz.com", userETag); w.Code != http.StatusOK {
+		t.Errorf("user ETag survived an update: got %d", w.Code)
+	}
+}
+
+func TestCreateUserValidationErrors(t *testing.T) {
+	for _, target := range []string{"/users", "/api/v1/users"} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"first_name":"","last_name":"smith","email":"nope"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		newTestServer(t).Routes().ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("bad response code for %s: expected %d, got %d\nbody: %s\n", target, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		}
+		assertErrorCode(t, w, codeValidation, "validation failed")
+
+		var resp errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		fields := map[string]string{}
+		for _, fe := range resp.Error.Fields {
+			fields[fe.Field] = fe.Message
+		}
+		if len(fields) != 2 || fields["first_name"] == "" || fields["email"] == "" {
+			t.Errorf("expected first_name and email errors in one response, got %+v", resp.Error.Fields)
+		}
+	}
+}
+
+func TestUpdateUserIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	addAdmin(t, s.users)
+	if err := s.users.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	handler := s.Routes()
+	serve := func(method string, target string, ifMatch string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(testAdminEmail, testAdminPassword)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodGet, "/users/jhon@bar.com", "", "")
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("missing ETag on GET\nbody: %s\n", w.Body.String())
+	}
+
+	w = serve(http.MethodPut, "/users/jhon@bar.com", etag, `{"email":"first@bar.com"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusOK, w.Code, w.Body.String())
+	}
+	newETag := w.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Errorf("ETag not changed by update: %q -> %q", etag, newETag)
+	}
+
+	// A second admin still holding the first ETag must not clobber the update.
+	w = serve(http.MethodPut, "/api/v1/users/first@bar.com", etag, `{"email":"second@bar.com"}`)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("bad response code for stale write: expected %d, got %d\nbody: %s\n", http.StatusPreconditionFailed, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codePreconditionFailed, "")
+
+	w = serve(http.MethodGet, "/api/v1/users/jhon/smith", "", "")
+	var user UserResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil || user.Email != "first@bar.com" {
+		t.Errorf("stale write was applied: %s", w.Body.String())
+	}
+	if got := w.Header().Get("ETag"); got != newETag {
+		t.Errorf("bad ETag: expected %q, got %q", newETag, got)
+	}
+
+	tests := []struct {
+		ifMatch string
+		status  int
+	}{
+		{"W/" + newETag, http.StatusPreconditionFailed},
+		{`"other", ` + newETag, http.StatusOK},
+		{"*", http.StatusOK},
+		{"", http.StatusOK},
+	}
+	for _, tc := range tests {
+		w = serve(http.MethodPut, "/users/first@bar.com", tc.ifMatch, `{"email":"first@bar.com"}`)
+		if w.Code != tc.status {
+			t.Errorf("bad response code for If-Match %q: expected %d, got %d\nbody: %s\n", tc.ifMatch, tc.status, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	s := newTestServer(t)
+	addAdmin(t, s.users)
+	if err := s.users.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	handler := s.Routes()
+	serve := func(method string, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, nil)
+		req.SetBasicAuth(testAdminEmail, testAdminPassword)
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	steps := []struct {
+		method string
+		target string
+		status int
+		code   string
+	}{
+		{http.MethodPost, "/api/v1/users/jhon@bar.com/restore", http.StatusConflict, codeConflict},
+		{http.MethodDelete, "/api/v1/users/jhon@bar.com", http.StatusNoContent, ""},
+		{http.MethodGet, "/api/v1/users/jhon@bar.com", http.StatusGone, codeGone},
+		{http.MethodGet, "/api/v1/users/jhon/smith", http.StatusGone, codeGone},
+		{http.MethodDelete, "/api/v1/users/jhon@bar.com", http.StatusGone, codeGone},
+		{http.MethodGet, "/api/v1/users/nobody@bar.com", http.StatusNotFound, codeNotFound},
+		{http.MethodPost, "/api/v1/users/nobody@bar.com/restore", http.StatusNotFound, codeNotFound},
+		{http.MethodPost, "/api/v1/users/jhon@bar.com/restore", http.StatusOK, ""},
+		{http.MethodGet, "/api/v1/users/jhon@bar.com", http.StatusOK, ""},
+	}
+	for _, step := range steps {
+		w := serve(step.method, step.target)
+		if w.Code != step.status {
+			t.Errorf("%s %s: bad response code: expected %d, got %d\nbody: %s\n", step.method, step.target, step.status, w.Code, w.Body.String())
+			continue
+		}
+		if step.code != "" {
+			assertErrorCode(t, w, step.code, "")
+		}
+	}
+
+	// Listings never include deleted users.
+	if err := s.users.DeleteUser(context.Background(), "jhon", "smith"); err != nil {
+		t.Fatal(err)
+	}
+	w := serve(http.MethodGet, "/api/v1/users")
+	if strings.Contains(w.Body.String(), "jhon@bar.com") {
+		t.Errorf("deleted user listed: %s", w.Body.String())
+	}
+}
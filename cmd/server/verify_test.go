@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestVerifyUserEndpoint(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.Routes()
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"first_name":"alice","last_name":"smith","email":"alice@example.com"}`,
+		`{"first_name":"bob","last_name":"jones","email":"bob@example.com"}`,
+	} {
+		if w := serve(http.MethodPost, "/users", body); w.Code != http.StatusCreated {
+			t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusCreated, w.Code, w.Body.String())
+		}
+	}
+
+	token, err := s.users.VerificationToken("alice", "smith")
+	if err != nil {
+		t.Fatalf("POST /users issued no token: %v", err)
+	}
+	target := "/api/v1/users/verify?token=" + url.QueryEscape(token)
+	if w := serve(http.MethodGet, target, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusNoContent, w.Code, w.Body.String())
+	}
+
+	w := serve(http.MethodGet, target, "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("reused token: expected %d, got %d", http.StatusNotFound, w.Code)
+	}
+	assertErrorCode(t, w, codeNotFound, users.ErrInvalidToken.Error())
+
+	w = serve(http.MethodGet, "/users/verify", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("missing token: expected %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = serve(http.MethodGet, "/users/alice@example.com", "")
+	var user UserResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil || !user.Verified {
+		t.Errorf("expected alice to be verified: %s", w.Body.String())
+	}
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/users", []string{"alice", "bob"}},
+		{"/users?verified=true", []string{"alice"}},
+		{"/users?verified=false", []string{"bob"}},
+		{"/users/search?q=a&verified=true", []string{"alice"}},
+		{"/users/search?q=b&verified=true", nil},
+	}
+	for _, tt := range tests {
+		w := serve(http.MethodGet, tt.target, "")
+		var resp struct {
+			Users []UserResponse `json:"users"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%s: bad response: %d %s", tt.target, w.Code, w.Body.String())
+		}
+		var got []string
+		for _, u := range resp.Users {
+			got = append(got, u.FirstName)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expected %q, got %q", tt.target, tt.want, got)
+		}
+	}
+
+	for _, target := range []string{"/users?verified=maybe", "/users/search?q=a&verified=maybe"} {
+		if w := serve(http.MethodGet, target, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", target, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestVerifyUserEndpointExpired(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	manager := users.NewManager(users.WithClock(func() time.Time { return now }), users.WithVerificationTTL(time.Hour))
+	s := NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), manager)
+
+	manager.AddUser(context.Background(), "alice", "smith", "alice@example.com")
+	token, _ := manager.VerificationToken("alice", "smith")
+	now = now.Add(2 * time.Hour)
+
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/verify?token="+url.QueryEscape(token), nil))
+	if w.Code != http.StatusGone {
+		t.Errorf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusGone, w.Code, w.Body.String())
+	}
+	assertErrorCode(t, w, codeGone, users.ErrTokenExpired.Error())
+}
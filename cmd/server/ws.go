@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/greeting"
+	"github.com/kunalkumar-1/go-http/internal/websocket"
+)
+
+const (
+	// wsMaxMessage caps the size of one message from the client.
+	wsMaxMessage = 4 << 10
+
+	// wsIdleTimeout is how long the server waits for the next message.
+	wsIdleTimeout = time.Minute
+
+	// wsCloseWait is how long the server waits for the client to answer its
+	// close frame when shutting down.
+	wsCloseWait = time.Second
+)
+
+type wsGreetRequest struct {
+	Name string `json:"name"`
+}
+
+type wsGreetResponse struct {
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// closeWebSockets tells every open WebSocket to close with "going away". It
+// is registered with http.Server.RegisterOnShutdown, because Shutdown does
+// not wait for hijacked connections.
+func (s *Server) closeWebSockets() {
+	s.shutdownOnce.Do(func() { close(s.shuttingDown) })
+}
+
+// handleWebSocket greets every {"name":...} message on a WebSocket with
+// {"message":...}. An empty name gets {"error":...} and the connection stays
+// open; binary or malformed messages close it.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	s.logRequest(r)
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		s.logger.Debug("websocket upgrade failed", "err", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(wsMaxMessage)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.shuttingDown:
+			conn.WriteClose(websocket.CloseGoingAway, "server shutting down")
+			conn.SetReadDeadline(time.Now().Add(wsCloseWait))
+		case <-done:
+		}
+	}()
+
+	for {
+		select {
+		case <-s.shuttingDown:
+		default:
+			conn.SetReadDeadline(time.Now().Add(wsIdleTimeout))
+		}
+
+		op, data, err := conn.ReadMessage()
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			conn.WriteClose(websocket.CloseGoingAway, "idle timeout")
+			return
+		}
+		if err != nil {
+			s.logger.Debug("websocket closed", "err", err)
+			return
+		}
+
+		if op != websocket.OpText {
+			conn.WriteClose(websocket.CloseUnsupportedData, "expected text messages")
+			return
+		}
+		var msg wsGreetRequest
+		if err := json.Unmarshal(data, &msg); err != nil {
+			conn.WriteClose(websocket.CloseInvalidPayload, "malformed JSON")
+			return
+		}
+
+		var resp wsGreetResponse
+		res, err := s.renderGreeting(nil, r, msg.Name)
+		switch {
+		case errors.Is(err, greeting.ErrEmptyName):
+			resp.Error = "name must not be empty"
+		case err != nil:
+			s.logger.Error("error rendering greeting", "err", err)
+			conn.WriteClose(websocket.CloseInternalError, "error rendering greeting")
+			return
+		default:
+			resp.Message = res.Greeting
+		}
+
+		out, err := json.Marshal(resp)
+		if err != nil {
+			s.logger.Error("error encoding websocket response", "err", err)
+			return
+		}
+		if err := conn.WriteMessage(websocket.OpText, out); err != nil {
+			s.logger.Debug("error writing websocket message", "err", err)
+			return
+		}
+	}
+}
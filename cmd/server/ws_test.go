@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+	"github.com/kunalkumar-1/go-http/internal/websocket"
+)
+
+func startWebSocketServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	s := NewServer(slog.New(slog.NewTextHandler(io.Discard, nil)), users.NewManager())
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = s.HTTPServer("")
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+
+	conn, err := websocket.Dial("ws" + strings.TrimPrefix(srv.URL, "http") + "/ws")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	return conn
+}
+
+func exchange(t *testing.T, conn *websocket.Conn, msg string) wsGreetResponse {
+	t.Helper()
+
+	if err := conn.WriteMessage(websocket.OpText, []byte(msg)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var resp wsGreetResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("bad response %q: %v", data, err)
+	}
+
+	return resp
+}
+
+func assertClosedWith(t *testing.T, conn *websocket.Conn, code int) {
+	t.Helper()
+
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("expected a close frame, got %v", err)
+	}
+	if closeErr.Code != code {
+		t.Errorf("bad close code: expected %d, got %d (%s)", code, closeErr.Code, closeErr.Reason)
+	}
+}
+
+func TestWebSocketGreeting(t *testing.T) {
+	conn := dialWebSocket(t, startWebSocketServer(t))
+
+	for _, name := range []string{"alice", "bob"} {
+		resp := exchange(t, conn, `{"name":"`+name+`"}`)
+		if desired := "Hello " + name + "!"; resp.Message != desired {
+			t.Errorf("bad message: expected %q, got %q", desired, resp.Message)
+		}
+	}
+}
+
+func TestWebSocketInvalidMessage(t *testing.T) {
+	conn := dialWebSocket(t, startWebSocketServer(t))
+
+	resp := exchange(t, conn, `{"name":""}`)
+	if resp.Error == "" || resp.Message != "" {
+		t.Errorf("expected an error reply for an empty name, got %+v", resp)
+	}
+
+	// The connection survives an empty name but not malformed JSON.
+	if resp := exchange(t, conn, `{"name":"alice"}`); resp.Message != "Hello alice!" {
+		t.Errorf("bad message after error: got %+v", resp)
+	}
+	if err := conn.WriteMessage(websocket.OpText, []byte(`{"name":`)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	assertClosedWith(t, conn, websocket.CloseInvalidPayload)
+}
+
+func TestWebSocketMessageTooBig(t *testing.T) {
+	conn := dialWebSocket(t, startWebSocketServer(t))
+
+	big := `{"name":"` + strings.Repeat("a", wsMaxMessage) + `"}`
+	if err := conn.WriteMessage(websocket.OpText, []byte(big)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	assertClosedWith(t, conn, websocket.CloseMessageTooBig)
+}
+
+func TestWebSocketShutdown(t *testing.T) {
+	srv := startWebSocketServer(t)
+	conn := dialWebSocket(t, srv)
+
+	if resp := exchange(t, conn, `{"name":"alice"}`); resp.Message != "Hello alice!" {
+		t.Fatalf("bad message: got %+v", resp)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	assertClosedWith(t, conn, websocket.CloseGoingAway)
+}
@@ -0,0 +1,47 @@
+// Command smoketest verifies a deployed server by running a fixed sequence
+// of real API calls. It prints a JSON report and exits nonzero if any step
+// fails.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/smoke"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:4000", "base URL of the server under test")
+	token := flag.String("token", os.Getenv("SMOKETEST_TOKEN"), "bearer token sent with every request; also read from $SMOKETEST_TOKEN")
+	username := flag.String("user", os.Getenv("SMOKETEST_USER"), "Basic auth username (an admin's email), sent instead of -token; also read from $SMOKETEST_USER")
+	password := os.Getenv("SMOKETEST_PASSWORD")
+	deadline := flag.Duration("deadline", 30*time.Second, "total time allowed for the whole sequence")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *deadline)
+	defer cancel()
+
+	report := smoke.Run(ctx, smoke.Config{
+		Server:   *server,
+		Token:    *token,
+		Username: *username,
+		Password: password,
+		Client:   &http.Client{},
+	})
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintln(os.Stderr, "error writing report:", err)
+		os.Exit(2)
+	}
+
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,372 @@
+// Command userctl manages users on a running server through its API.
+//
+// Usage:
+//
+//	userctl [--addr URL] [--user EMAIL] [--json] <add|get|list|delete|restore|import|export> [flags]
+//
+// export writes every user to an archive, and import reads either such an
+// archive or a CSV file, telling them apart by their first character.
+// The server address defaults to $USERS_ADDR. Deleting and restoring users
+// requires an admin: pass --user (or $USERS_USER) with the admin's email and
+// set the password in $USERS_PASSWORD. Validation failures exit with status
+// 2; server and network errors exit with status 1.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/kunalkumar-1/go-http/client"
+)
+
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitValidation = 2
+)
+
+// errUsage marks errors caused by bad input rather than the server.
+var errUsage = errors.New("usage")
+
+func usageErrorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, args...))
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Getenv, os.Stdout, os.Stderr))
+}
+
+type cli struct {
+	client *client.Client
+	json   bool
+	stdout io.Writer
+}
+
+// run is the testable entrypoint. It returns the process exit code.
+func run(ctx context.Context, args []string, getenv func(string) string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("userctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", getenv("USERS_ADDR"), "base URL of the server; also read from $USERS_ADDR")
+	asJSON := fs.Bool("json", false, "print JSON instead of tables")
+	user := fs.String("user", getenv("USERS_USER"), "email to authenticate as; the password is read from $USERS_PASSWORD")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout for each API call")
+	if err := fs.Parse(args); err != nil {
+		return exitValidation
+	}
+
+	if *addr == "" {
+		fmt.Fprintln(stderr, "error: --addr or $USERS_ADDR is required")
+		return exitValidation
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "error: expected a command: add, get, list, delete, restore, import or export")
+		return exitValidation
+	}
+
+	opts := []client.Option{client.WithTimeout(*timeout), client.WithUserAgent("userctl")}
+	if *user != "" {
+		opts = append(opts, client.WithBasicAuth(*user, getenv("USERS_PASSWORD")))
+	}
+	c := &cli{
+		client: client.New(*addr, opts...),
+		json:   *asJSON,
+		stdout: stdout,
+	}
+
+	commands := map[string]func(context.Context, []string, io.Writer) error{
+		"add":     c.add,
+		"get":     c.get,
+		"list":    c.list,
+		"delete":  c.delete,
+		"restore": c.restore,
+		"import":  c.importUsers,
+		"export":  c.export,
+	}
+
+	name, rest := fs.Arg(0), fs.Args()[1:]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "error: unknown command %q\n", name)
+		return exitValidation
+	}
+
+	if err := cmd(ctx, rest, stderr); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		if errors.Is(err, errUsage) || errors.Is(err, client.ErrInvalidRequest) {
+			return exitValidation
+		}
+		return exitError
+	}
+	return exitOK
+}
+
+// parseFlags parses a subcommand's flags and rejects positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string, stderr io.Writer) error {
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return usageErrorf("%v", err)
+	}
+	if fs.NArg() > 0 {
+		return usageErrorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return nil
+}
+
+func (c *cli) add(ctx context.Context, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	first := fs.String("first", "", "first name")
+	last := fs.String("last", "", "last name")
+	email := fs.String("email", "", "email address")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	if *first == "" || *last == "" || *email == "" {
+		return usageErrorf("add requires --first, --last and --email")
+	}
+
+	user, err := c.client.CreateUser(ctx, client.UserData{FirstName: *first, LastName: *last, Email: *email})
+	if err != nil {
+		return err
+	}
+	return c.print([]client.User{*user}, user)
+}
+
+func (c *cli) get(ctx context.Context, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	first := fs.String("first", "", "first name")
+	last := fs.String("last", "", "last name")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	if *first == "" || *last == "" {
+		return usageErrorf("get requires --first and --last")
+	}
+
+	user, err := c.client.GetUser(ctx, *first, *last)
+	if err != nil {
+		return err
+	}
+	return c.print([]client.User{*user}, user)
+}
+
+func (c *cli) list(ctx context.Context, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+
+	all, err := c.client.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if all == nil {
+		all = []client.User{}
+	}
+	return c.print(all, all)
+}
+
+func (c *cli) delete(ctx context.Context, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	email := fs.String("email", "", "email address of the user to delete")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	if *email == "" {
+		return usageErrorf("delete requires --email")
+	}
+
+	if err := c.client.DeleteUser(ctx, *email); err != nil {
+		return err
+	}
+	if c.json {
+		return json.NewEncoder(c.stdout).Encode(map[string]string{"deleted": *email})
+	}
+	_, err := fmt.Fprintf(c.stdout, "deleted %s\n", *email)
+	return err
+}
+
+func (c *cli) restore(ctx context.Context, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	email := fs.String("email", "", "email address of the deleted user to restore")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	if *email == "" {
+		return usageErrorf("restore requires --email")
+	}
+
+	user, err := c.client.RestoreUser(ctx, *email)
+	if err != nil {
+		return err
+	}
+	return c.print([]client.User{*user}, user)
+}
+
+// importResult summarizes an import. Failed rows are reported individually
+// and do not stop the import; server or network errors do.
+type importResult struct {
+	Imported int      `json:"imported"`
+	Failed   []string `json:"failed"`
+}
+
+// importUsers imports a CSV file or an archive written by export, creating
+// one user per row or archived user.
+func (c *cli) importUsers(ctx context.Context, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("file", "", "CSV file or archive to import, or - for stdin")
+	mode := fs.String("mode", "", "for archives, what to do with users whose name or email is taken: skip, overwrite or fail (default fail)")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+	if *file == "" {
+		return usageErrorf("import requires --file")
+	}
+
+	var in io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return usageErrorf("%v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// An archive is a JSON object; a CSV file cannot start with "{" and
+	// still have the required header.
+	br := bufio.NewReader(in)
+	for {
+		b, err := br.Peek(1)
+		if err != nil || !unicode.IsSpace(rune(b[0])) {
+			break
+		}
+		br.ReadByte()
+	}
+	if b, err := br.Peek(1); err == nil && b[0] == '{' {
+		return c.importArchive(ctx, br, *mode)
+	}
+	if *mode != "" {
+		return usageErrorf("--mode only applies to archives")
+	}
+	return c.importCSV(ctx, br)
+}
+
+// importArchive imports an archive in one call, which adds all of its users
+// or none.
+func (c *cli) importArchive(ctx context.Context, in io.Reader, mode string) error {
+	result, err := c.client.ImportArchive(ctx, in, mode)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return json.NewEncoder(c.stdout).Encode(result)
+	}
+	_, err = fmt.Fprintf(c.stdout, "added %d, overwrote %d, skipped %d users\n", result.Added, result.Overwritten, result.Skipped)
+	return err
+}
+
+// importCSV streams a CSV file with first_name, last_name and email columns,
+// in any order, creating one user per row.
+func (c *cli) importCSV(ctx context.Context, in io.Reader) error {
+	cr := csv.NewReader(in)
+	header, err := cr.Read()
+	if err != nil {
+		return usageErrorf("error reading CSV header: %v", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"first_name", "last_name", "email"} {
+		if _, ok := cols[required]; !ok {
+			return usageErrorf("CSV header is missing column %q", required)
+		}
+	}
+
+	result := importResult{Failed: []string{}}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return usageErrorf("error reading CSV: %v", err)
+		}
+
+		data := client.UserData{
+			FirstName: record[cols["first_name"]],
+			LastName:  record[cols["last_name"]],
+			Email:     record[cols["email"]],
+		}
+		_, err = c.client.CreateUser(ctx, data)
+		var apiErr *client.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode < 500 {
+			result.Failed = append(result.Failed, fmt.Sprintf("line %d: %s", line, apiErr.Detail()))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		result.Imported++
+	}
+
+	if c.json {
+		if err := json.NewEncoder(c.stdout).Encode(result); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(c.stdout, "imported %d users\n", result.Imported)
+		for _, f := range result.Failed {
+			fmt.Fprintln(c.stdout, "failed", f)
+		}
+	}
+
+	if len(result.Failed) > 0 {
+		return usageErrorf("%d rows failed", len(result.Failed))
+	}
+	return nil
+}
+
+// export writes an archive of every user to a file or stdout.
+func (c *cli) export(ctx context.Context, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	file := fs.String("file", "-", "file to write the archive to, or - for stdout")
+	if err := parseFlags(fs, args, stderr); err != nil {
+		return err
+	}
+
+	if *file == "-" {
+		return c.client.ExportArchive(ctx, c.stdout)
+	}
+	var buf bytes.Buffer
+	if err := c.client.ExportArchive(ctx, &buf); err != nil {
+		return err
+	}
+	return os.WriteFile(*file, buf.Bytes(), 0o600)
+}
+
+// print writes users as a table, or v as JSON when --json is set.
+func (c *cli) print(all []client.User, v any) error {
+	if c.json {
+		return json.NewEncoder(c.stdout).Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIRST\tLAST\tEMAIL\tCREATED")
+	for _, u := range all {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", u.FirstName, u.LastName, u.Email, u.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
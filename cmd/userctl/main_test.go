@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI is a minimal in-memory stand-in for the server's /api/v1 users
+// endpoints. The client package's own tests cover the real mux.
+type fakeAPI struct {
+	mu    sync.Mutex
+	users []map[string]any
+}
+
+func (f *fakeAPI) writeError(w http.ResponseWriter, status int, code string, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": code, "message": msg}})
+}
+
+func (f *fakeAPI) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		var data map[string]any
+		json.NewDecoder(r.Body).Decode(&data)
+		if email, _ := data["email"].(string); !strings.Contains(email, "@") {
+			f.writeError(w, http.StatusBadRequest, "invalid_request", "invalid email")
+			return
+		}
+		for _, u := range f.users {
+			if u["email"] == data["email"] {
+				f.writeError(w, http.StatusConflict, "conflict", "duplicate email")
+				return
+			}
+		}
+		data["created_at"] = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		f.users = append(f.users, data)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(data)
+	})
+	mux.HandleFunc("GET /api/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"users":      f.users,
+			"pagination": map[string]int{"offset": 0, "limit": 500, "total": len(f.users)},
+		})
+	})
+	mux.HandleFunc("GET /api/v1/users/{first}/{last}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		for _, u := range f.users {
+			if u["first_name"] == r.PathValue("first") && u["last_name"] == r.PathValue("last") {
+				json.NewEncoder(w).Encode(u)
+				return
+			}
+		}
+		f.writeError(w, http.StatusNotFound, "not_found", "user not found")
+	})
+	mux.HandleFunc("DELETE /api/v1/users/{email}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		for i, u := range f.users {
+			if u["email"] == r.PathValue("email") {
+				f.users = append(f.users[:i], f.users[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		f.writeError(w, http.StatusNotFound, "not_found", "user not found")
+	})
+	mux.HandleFunc("GET /api/v1/users/archive", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]any{"format_version": 2, "users": f.users})
+	})
+	mux.HandleFunc("POST /api/v1/users/archive", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		var archive struct {
+			Users []map[string]any `json:"users"`
+		}
+		json.NewDecoder(r.Body).Decode(&archive)
+		result := map[string]int{"added": 0, "overwritten": 0, "skipped": 0}
+		var added []map[string]any
+	next:
+		for _, a := range archive.Users {
+			for _, u := range f.users {
+				if u["email"] == a["email"] {
+					if r.URL.Query().Get("mode") != "skip" {
+						f.writeError(w, http.StatusConflict, "conflict", "duplicate email")
+						return
+					}
+					result["skipped"]++
+					continue next
+				}
+			}
+			added = append(added, a)
+			result["added"]++
+		}
+		f.users = append(f.users, added...)
+		json.NewEncoder(w).Encode(result)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		f.writeError(w, http.StatusInternalServerError, "internal", "boom")
+	})
+	return mux
+}
+
+func runCLI(t *testing.T, addr string, args ...string) (int, string, string) {
+	t.Helper()
+
+	getenv := func(key string) string {
+		if key == "USERS_ADDR" {
+			return addr
+		}
+		return ""
+	}
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, getenv, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCommands(t *testing.T) {
+	srv := httptest.NewServer((&fakeAPI{}).routes())
+	t.Cleanup(srv.Close)
+
+	code, out, errOut := runCLI(t, srv.URL, "add", "--first", "Ada", "--last", "Lovelace", "--email", "ada@example.com")
+	if code != exitOK {
+		t.Fatalf("add: bad exit code: expected %d, got %d\nstderr: %s", exitOK, code, errOut)
+	}
+	if !strings.Contains(out, "ada@example.com") || !strings.HasPrefix(out, "FIRST") {
+		t.Errorf("add: unexpected table output: %q", out)
+	}
+
+	code, out, _ = runCLI(t, srv.URL, "--json", "get", "--first", "Ada", "--last", "Lovelace")
+	if code != exitOK {
+		t.Fatalf("get: bad exit code: expected %d, got %d", exitOK, code)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(out), &got); err != nil || got["email"] != "ada@example.com" {
+		t.Errorf("get: unexpected JSON output: %q (%v)", out, err)
+	}
+
+	code, out, _ = runCLI(t, srv.URL, "--json", "list")
+	var all []map[string]any
+	if code != exitOK || json.Unmarshal([]byte(out), &all) != nil || len(all) != 1 {
+		t.Errorf("list: unexpected result %d %q", code, out)
+	}
+
+	code, _, _ = runCLI(t, srv.URL, "delete", "--email", "ada@example.com")
+	if code != exitOK {
+		t.Errorf("delete: bad exit code: expected %d, got %d", exitOK, code)
+	}
+
+	code, out, _ = runCLI(t, srv.URL, "--json", "list")
+	if code != exitOK || strings.TrimSpace(out) != "[]" {
+		t.Errorf("list after delete: unexpected result %d %q", code, out)
+	}
+}
+
+func TestImport(t *testing.T) {
+	srv := httptest.NewServer((&fakeAPI{}).routes())
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "users.csv")
+	csv := "email,first_name,last_name\n" +
+		"ada@example.com,Ada,Lovelace\n" +
+		"not-an-email,Bad,Row\n" +
+		"grace@example.com,Grace,Hopper\n"
+	if err := os.WriteFile(path, []byte(csv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out, _ := runCLI(t, srv.URL, "--json", "import", "--file", path)
+	if code != exitValidation {
+		t.Errorf("bad exit code: expected %d, got %d", exitValidation, code)
+	}
+
+	var result importResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("error decoding output %q: %v", out, err)
+	}
+	if result.Imported != 2 || len(result.Failed) != 1 || !strings.HasPrefix(result.Failed[0], "line 3:") {
+		t.Errorf("unexpected import result: %+v", result)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	srv := httptest.NewServer((&fakeAPI{}).routes())
+	t.Cleanup(srv.Close)
+
+	if code, _, errOut := runCLI(t, srv.URL, "add", "--first", "Ada", "--last", "Lovelace", "--email", "ada@example.com"); code != exitOK {
+		t.Fatalf("add: bad exit code %d\nstderr: %s", code, errOut)
+	}
+	path := filepath.Join(t.TempDir(), "users.json")
+	if code, _, errOut := runCLI(t, srv.URL, "export", "--file", path); code != exitOK {
+		t.Fatalf("export: bad exit code %d\nstderr: %s", code, errOut)
+	}
+
+	code, _, errOut := runCLI(t, srv.URL, "import", "--file", path)
+	if code != exitError || !strings.Contains(errOut, "conflict") {
+		t.Errorf("import without a mode: expected a conflict, got %d\nstderr: %s", code, errOut)
+	}
+	code, out, errOut := runCLI(t, srv.URL, "import", "--file", path, "--mode=skip")
+	if code != exitOK || out != "added 0, overwrote 0, skipped 1 users\n" {
+		t.Errorf("import --mode=skip: got %d %q\nstderr: %s", code, out, errOut)
+	}
+
+	runCLI(t, srv.URL, "delete", "--email", "ada@example.com")
+	code, out, _ = runCLI(t, srv.URL, "--json", "import", "--file", path)
+	var result map[string]int
+	if code != exitOK || json.Unmarshal([]byte(out), &result) != nil || result["added"] != 1 {
+		t.Errorf("import into an empty server: got %d %q", code, out)
+	}
+
+	csvPath := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(csvPath, []byte("first_name,last_name,email\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, _ := runCLI(t, srv.URL, "import", "--file", csvPath, "--mode", "skip"); code != exitValidation {
+		t.Errorf("--mode with a CSV file: expected exit code %d, got %d", exitValidation, code)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	srv := httptest.NewServer((&fakeAPI{}).routes())
+	t.Cleanup(srv.Close)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name string
+		addr string
+		args []string
+		want int
+	}{
+		{name: "no addr", addr: "", args: []string{"list"}, want: exitValidation},
+		{name: "no command", addr: srv.URL, args: nil, want: exitValidation},
+		{name: "unknown command", addr: srv.URL, args: []string{"frobnicate"}, want: exitValidation},
+		{name: "missing flags", addr: srv.URL, args: []string{"add", "--first", "Ada"}, want: exitValidation},
+		{name: "unknown flag", addr: srv.URL, args: []string{"get", "--nope"}, want: exitValidation},
+		{name: "server rejects input", addr: srv.URL, args: []string{"add", "--first", "A", "--last", "B", "--email", "bad"}, want: exitValidation},
+		{name: "not found", addr: srv.URL, args: []string{"get", "--first", "No", "--last", "One"}, want: exitError},
+		{name: "network error", addr: closed.URL, args: []string{"list"}, want: exitError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, errOut := runCLI(t, tc.addr, tc.args...)
+			if code != tc.want {
+				t.Errorf("bad exit code: expected %d, got %d\nstderr: %s", tc.want, code, errOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,607 @@
+// Package config loads the server's startup configuration. Every setting
+// has one name used as the key in the config file, as the flag, and, upper
+// cased with dashes turned into underscores, as the environment variable:
+// "log-level", -log-level and $LOG_LEVEL. Later layers win: defaults, then
+// the file, then the environment, then flags.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as a string such as "30s" in config
+// files.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// Config is everything the server reads at startup.
+type Config struct {
+	Addr                 string   `json:"addr"`
+	AdminAddr            string   `json:"admin-addr"`
+	GRPCAddr             string   `json:"grpc-addr"`
+	EnablePprof          bool     `json:"enable-pprof"`
+	EnableDebugEndpoints bool     `json:"enable-debug-endpoints"`
+	ShutdownTimeout      Duration `json:"shutdown-timeout"`
+	DebugTiming          bool     `json:"debug-timing"`
+	RegisterOnGreet      bool     `json:"register-on-greet"`
+	LocalesDir           string   `json:"locales-dir"`
+	Greeting             string   `json:"greeting"`
+
+	// GreetingFile holds the default greeting template in place of
+	// Greeting. It is re-read with the locale bundles on SIGHUP.
+	GreetingFile string `json:"greeting-file"`
+
+	// MissingName is how hello requests that name no one are answered:
+	// default-name greets DefaultName, reject fails with a 400 and
+	// anonymous greets without a name.
+	MissingName string `json:"missing-name"`
+	DefaultName string `json:"default-name"`
+
+	// NameRejectDigits rejects user names, and greeted names, containing
+	// a digit.
+	NameRejectDigits bool `json:"name-reject-digits"`
+
+	// Collation is the BCP 47 language tag whose collation orders names
+	// in sorted user lists. Empty compares names byte by byte.
+	Collation string `json:"collation"`
+
+	ReadHeaderTimeout Duration `json:"read-header-timeout"`
+	ReadTimeout       Duration `json:"read-timeout"`
+	WriteTimeout      Duration `json:"write-timeout"`
+	IdleTimeout       Duration `json:"idle-timeout"`
+	HandlerTimeout    Duration `json:"handler-timeout"`
+	StoreTimeout      Duration `json:"store-timeout"`
+	BodyReadTimeout   Duration `json:"body-read-timeout"`
+
+	// SlowRequestThreshold is the latency above which a request is logged
+	// at Warn and counted in http_slow_requests_total. SlowRequestGroups
+	// overrides it for route groups, as comma-separated group=duration
+	// pairs.
+	SlowRequestThreshold Duration `json:"slow-request-threshold"`
+	SlowRequestGroups    string   `json:"slow-request-groups"`
+
+	TLSCert       string `json:"tls-cert"`
+	TLSKey        string `json:"tls-key"`
+	TLSSelfSigned bool   `json:"tls-self-signed"`
+
+	DeprecateLegacy bool   `json:"deprecate-legacy"`
+	LegacySunset    string `json:"legacy-sunset"`
+	SessionSecret   string `json:"session-secret"`
+
+	IdempotencyTTL   Duration `json:"idempotency-ttl"`
+	SearchMaxResults int      `json:"search-max-results"`
+	StatsMaxNames    int      `json:"stats-max-names"`
+
+	// GreetingCooldownLimit is how many times a name may be greeted within
+	// GreetingCooldownWindow before further greetings are left out of
+	// /stats. Zero disables the cooldown.
+	GreetingCooldownLimit  int      `json:"greeting-cooldown-limit"`
+	GreetingCooldownWindow Duration `json:"greeting-cooldown-window"`
+
+	MaxInFlight     int      `json:"max-in-flight"`
+	MaxInFlightWait Duration `json:"max-in-flight-wait"`
+
+	MaxURLLength        int `json:"max-url-length"`
+	MaxQueryParams      int `json:"max-query-params"`
+	MaxQueryValueLength int `json:"max-query-value-length"`
+
+	// TrailingSlash is the canonical form paths are redirected to: keep
+	// serves either form, strip and add redirect to the form without or
+	// with the trailing slash.
+	TrailingSlash string `json:"trailing-slash"`
+
+	SnapshotPath     string   `json:"snapshot-path"`
+	SnapshotInterval Duration `json:"snapshot-interval"`
+
+	// Store is where the users API keeps users: memory, or sqlite in the
+	// database file at DBPath.
+	Store  string `json:"store"`
+	DBPath string `json:"db-path"`
+
+	LogFormat string `json:"log-format"`
+	LogLevel  string `json:"log-level"`
+
+	// AccessLog is the file each request is logged to as one JSON line.
+	// Empty disables the access log.
+	AccessLog           string `json:"access-log"`
+	AccessLogMaxSize    int    `json:"access-log-max-size"`
+	AccessLogMaxBackups int    `json:"access-log-max-backups"`
+	AccessLogCompress   bool   `json:"access-log-compress"`
+
+	// TrustedProxies is a comma-separated list of CIDR prefixes or
+	// addresses whose forwarding headers are believed.
+	TrustedProxies string `json:"trusted-proxies"`
+
+	// RedactHeaders and RedactFields are comma-separated lists of the
+	// request headers and JSON body fields masked wherever requests are
+	// logged or echoed.
+	RedactHeaders string `json:"redact-headers"`
+	RedactFields  string `json:"redact-fields"`
+
+	// PrintRoutes prints the route table and exits instead of serving. It
+	// is a command rather than a setting, so config files cannot set it.
+	PrintRoutes bool `json:"-"`
+
+	// DryRun checks the configuration and everything it names, prints
+	// what would be served and exits, without binding a socket.
+	DryRun bool `json:"-"`
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() Config {
+	return Config{
+		Addr:              ":4000",
+		AdminAddr:         "127.0.0.1:4001",
+		ShutdownTimeout:   Duration{15 * time.Second},
+		ReadHeaderTimeout: Duration{5 * time.Second},
+		ReadTimeout:       Duration{30 * time.Second},
+		WriteTimeout:      Duration{60 * time.Second},
+		IdleTimeout:       Duration{120 * time.Second},
+		HandlerTimeout:    Duration{10 * time.Second},
+		StoreTimeout:      Duration{5 * time.Second},
+		BodyReadTimeout:   Duration{5 * time.Second},
+		IdempotencyTTL:    Duration{24 * time.Hour},
+		SearchMaxResults:  50,
+		StatsMaxNames:     10000,
+
+		SlowRequestThreshold: Duration{500 * time.Millisecond},
+		SlowRequestGroups:    "stream=10s",
+
+		GreetingCooldownWindow: Duration{time.Minute},
+		MaxInFlight:            256,
+		MaxInFlightWait:        Duration{100 * time.Millisecond},
+
+		MaxURLLength:        8 << 10,
+		MaxQueryParams:      100,
+		MaxQueryValueLength: 1 << 10,
+		TrailingSlash:       "keep",
+		MissingName:         "default-name",
+		DefaultName:         "User",
+
+		SnapshotInterval: Duration{time.Minute},
+		Store:            "memory",
+		LogFormat:        "text",
+		LogLevel:         "info",
+
+		AccessLogMaxSize:    100,
+		AccessLogMaxBackups: 5,
+
+		RedactHeaders: "Authorization,Proxy-Authorization,Cookie,Set-Cookie,User,X-User",
+		RedactFields:  "password,token",
+	}
+}
+
+// RegisterFlags defines a flag for every setting on fs, defaulting to the
+// current value, so parsing only overrides the flags actually given.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "addr", c.Addr, "public listen address")
+	fs.StringVar(&c.AdminAddr, "admin-addr", c.AdminAddr, "admin listen address for /metrics and /debug/pprof")
+	fs.StringVar(&c.GRPCAddr, "grpc-addr", c.GRPCAddr, "listen address for the plaintext gRPC GreeterService; empty disables it")
+	fs.BoolVar(&c.EnablePprof, "enable-pprof", c.EnablePprof, "serve /debug/pprof on the admin address")
+
+	fs.StringVar(&c.AccessLog, "access-log", c.AccessLog, "file each request is appended to as one JSON line, rotated by size and reopened on SIGHUP; application logs stay on stdout")
+	fs.IntVar(&c.AccessLogMaxSize, "access-log-max-size", c.AccessLogMaxSize, "megabytes written to -access-log before it is rotated")
+	fs.IntVar(&c.AccessLogMaxBackups, "access-log-max-backups", c.AccessLogMaxBackups, "rotated access logs kept; older ones are removed")
+	fs.BoolVar(&c.AccessLogCompress, "access-log-compress", c.AccessLogCompress, "gzip rotated access logs")
+	fs.BoolVar(&c.EnableDebugEndpoints, "enable-debug-endpoints", c.EnableDebugEndpoints, "serve POST /debug/echo, which reflects requests back to the client")
+	fs.DurationVar(&c.ShutdownTimeout.Duration, "shutdown-timeout", c.ShutdownTimeout.Duration, "time allowed for in-flight requests to finish on shutdown")
+	fs.BoolVar(&c.DebugTiming, "debug-timing", c.DebugTiming, "emit a Server-Timing header on every response")
+	fs.BoolVar(&c.RegisterOnGreet, "register-on-greet", c.RegisterOnGreet, "register users posted to /json before greeting them")
+	fs.StringVar(&c.LocalesDir, "locales-dir", c.LocalesDir, "directory of <tag>.json locale bundles, reloaded on SIGHUP")
+	fs.StringVar(&c.Greeting, "greeting", c.Greeting, "text/template for the default greeting, e.g. \"Welcome back, {{.Name}}!\"")
+	fs.StringVar(&c.GreetingFile, "greeting-file", c.GreetingFile, "file holding the text/template for the default greeting in place of -greeting, reloaded on SIGHUP")
+	fs.StringVar(&c.MissingName, "missing-name", c.MissingName, "how hello requests without a name are answered: default-name greets -default-name, reject fails with a 400, anonymous greets without a name")
+	fs.StringVar(&c.DefaultName, "default-name", c.DefaultName, "name greeted when a hello request names no one and -missing-name is default-name")
+	fs.BoolVar(&c.NameRejectDigits, "name-reject-digits", c.NameRejectDigits, "reject user names and greeted names that contain a digit")
+	fs.StringVar(&c.Collation, "collation", c.Collation, "language tag, e.g. da, whose collation orders names in sorted user lists; empty compares bytes")
+
+	fs.DurationVar(&c.ReadHeaderTimeout.Duration, "read-header-timeout", c.ReadHeaderTimeout.Duration, "maximum time to read request headers")
+	fs.DurationVar(&c.ReadTimeout.Duration, "read-timeout", c.ReadTimeout.Duration, "maximum time to read a whole request")
+	fs.DurationVar(&c.WriteTimeout.Duration, "write-timeout", c.WriteTimeout.Duration, "maximum time to write a response")
+	fs.DurationVar(&c.IdleTimeout.Duration, "idle-timeout", c.IdleTimeout.Duration, "maximum time to keep an idle connection open")
+	fs.DurationVar(&c.HandlerTimeout.Duration, "handler-timeout", c.HandlerTimeout.Duration, "maximum time a handler may run before the client gets a 503")
+	fs.DurationVar(&c.StoreTimeout.Duration, "store-timeout", c.StoreTimeout.Duration, "maximum time a request spends in user store calls before the client gets a 504; keep it below -handler-timeout")
+	fs.DurationVar(&c.BodyReadTimeout.Duration, "body-read-timeout", c.BodyReadTimeout.Duration, "maximum time a request body may take to arrive before the client gets a 408; 0 disables")
+	fs.DurationVar(&c.SlowRequestThreshold.Duration, "slow-request-threshold", c.SlowRequestThreshold.Duration, "latency above which a request is logged at warn and counted in http_slow_requests_total; 0 disables")
+	fs.StringVar(&c.SlowRequestGroups, "slow-request-groups", c.SlowRequestGroups, "comma-separated group=duration pairs overriding -slow-request-threshold for the api, legacy or stream routes")
+
+	fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, "TLS certificate file; requires -tls-key")
+	fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, "TLS private key file; requires -tls-cert")
+	fs.BoolVar(&c.TLSSelfSigned, "tls-self-signed", c.TLSSelfSigned, "serve HTTPS with a generated self-signed certificate (development only)")
+
+	fs.BoolVar(&c.DeprecateLegacy, "deprecate-legacy", c.DeprecateLegacy, "send Deprecation headers on unversioned (v0) routes")
+	fs.StringVar(&c.LegacySunset, "legacy-sunset", c.LegacySunset, "RFC 3339 date sent as the Sunset header on unversioned (v0) routes")
+	fs.StringVar(&c.SessionSecret, "session-secret", c.SessionSecret, "secret used to sign session cookies; random per process when empty")
+
+	fs.DurationVar(&c.IdempotencyTTL.Duration, "idempotency-ttl", c.IdempotencyTTL.Duration, "how long responses to POST /users are kept for replay to requests with the same Idempotency-Key")
+	fs.IntVar(&c.SearchMaxResults, "search-max-results", c.SearchMaxResults, "maximum users returned by /users/search")
+	fs.IntVar(&c.StatsMaxNames, "stats-max-names", c.StatsMaxNames, "distinct names tracked by /stats before the least greeted are evicted")
+	fs.IntVar(&c.GreetingCooldownLimit, "greeting-cooldown-limit", c.GreetingCooldownLimit, "greetings of one name, case-insensitively, allowed within -greeting-cooldown-window before more are marked X-Greeting-Throttled and left out of /stats; 0 disables the cooldown")
+	fs.DurationVar(&c.GreetingCooldownWindow.Duration, "greeting-cooldown-window", c.GreetingCooldownWindow.Duration, "sliding window -greeting-cooldown-limit applies to")
+	fs.IntVar(&c.MaxInFlight, "max-in-flight", c.MaxInFlight, "requests handled at once before new ones wait for a slot; 0 disables the limit")
+	fs.DurationVar(&c.MaxInFlightWait.Duration, "max-in-flight-wait", c.MaxInFlightWait.Duration, "how long a request waits for a slot before it is shed with a 503")
+	fs.IntVar(&c.MaxURLLength, "max-url-length", c.MaxURLLength, "longest request URL in bytes before the client gets a 414; 0 disables the limit")
+	fs.IntVar(&c.MaxQueryParams, "max-query-params", c.MaxQueryParams, "most query parameters in a request before the client gets a 400; 0 disables the limit")
+	fs.IntVar(&c.MaxQueryValueLength, "max-query-value-length", c.MaxQueryValueLength, "longest escaped query value in bytes before the client gets a 400; 0 disables the limit")
+	fs.StringVar(&c.TrailingSlash, "trailing-slash", c.TrailingSlash, "trailing-slash policy: keep serves both forms, strip or add redirects to the form without or with it")
+
+	fs.StringVar(&c.SnapshotPath, "snapshot-path", c.SnapshotPath, "file users are restored from at startup and saved to periodically and on shutdown")
+	fs.DurationVar(&c.SnapshotInterval.Duration, "snapshot-interval", c.SnapshotInterval.Duration, "time between autosaves to -snapshot-path")
+	fs.StringVar(&c.Store, "store", c.Store, "where the users API keeps users: memory, or sqlite in the database at -db-path")
+	fs.StringVar(&c.DBPath, "db-path", c.DBPath, "SQLite database file used by -store=sqlite, created and migrated on startup")
+
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "log output format, text or json")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "minimum level logged: debug, info, warn or error; adjustable at runtime with PUT /log-level on the admin address")
+
+	fs.StringVar(&c.TrustedProxies, "trusted-proxies", c.TrustedProxies, "comma-separated CIDRs or addresses of proxies whose X-Forwarded-For and Forwarded headers name the client")
+	fs.StringVar(&c.RedactHeaders, "redact-headers", c.RedactHeaders, "comma-separated request headers masked in logs and /debug/echo")
+	fs.StringVar(&c.RedactFields, "redact-fields", c.RedactFields, "comma-separated JSON body fields masked in logs and /debug/echo")
+
+	fs.BoolVar(&c.PrintRoutes, "print-routes", c.PrintRoutes, "print every route with its methods, handler, middleware group and source line, then exit")
+	fs.BoolVar(&c.DryRun, "dry-run", c.DryRun, "check the configuration, greeting templates, snapshot and TLS key pair without serving, print what would run, then exit; non-zero with every problem found")
+}
+
+// EnvName returns the environment variable read for the setting name.
+func EnvName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// LoadConfig returns the defaults overlaid with the file at path and then
+// the environment. A missing file, or an empty path, is not an error. The
+// file is YAML if its name ends in .yaml or .yml and JSON otherwise; keys it
+// does not know are rejected.
+func LoadConfig(path string) (*Config, error) {
+	c := Default()
+	if path != "" {
+		if err := c.readFile(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if err := c.applyEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// YAML is converted to JSON so both formats share the JSON keys,
+		// the Duration parsing and the unknown key check.
+		if data, err = yamlToJSON(data); err != nil {
+			return fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return fmt.Errorf("config %s: unexpected data after the top-level object", path)
+	}
+	return nil
+}
+
+// yamlToJSON returns the single YAML document in data as JSON.
+func yamlToJSON(data []byte) ([]byte, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return nil, errors.New("unexpected data after the first document")
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	return json.Marshal(doc)
+}
+
+// applyEnv sets every setting whose environment variable is set and not
+// empty, parsing values exactly as flags are parsed.
+func (c *Config) applyEnv(getenv func(string) string) error {
+	fs := flag.NewFlagSet("env", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		v := getenv(EnvName(f.Name))
+		if v == "" {
+			return
+		}
+		if err := fs.Set(f.Name, v); err != nil {
+			errs = append(errs, fmt.Errorf("$%s: %w", EnvName(f.Name), err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// configFileEnv names the config file when -config is not given.
+const configFileEnv = "CONFIG_FILE"
+
+// Load builds the configuration from every layer. The file is named by
+// -config in args, or $CONFIG_FILE. fs receives the -config flag and one
+// flag per setting, and parses args after the file and environment are
+// applied so flags take precedence.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	// Find -config first; the real parse below reports any flag errors.
+	path := os.Getenv(configFileEnv)
+	pre := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	pre.SetOutput(io.Discard)
+	var scratch Config
+	scratch.RegisterFlags(pre)
+	pre.StringVar(&path, "config", path, "")
+	pre.Parse(args)
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.RegisterFlags(fs)
+	fs.String("config", path, "JSON or YAML (.yaml, .yml) config file, keyed by flag name; every setting can also be given as an environment variable named after its flag, e.g. $LOG_LEVEL; flags override the environment, which overrides the file; also read from $"+configFileEnv)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate reports every invalid setting at once, joined into one error.
+func (c *Config) Validate() error {
+	var errs []error
+	problem := func(name string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+	}
+
+	if err := checkAddr(c.Addr); err != nil {
+		problem("addr", "%v", err)
+	}
+	if err := checkAddr(c.AdminAddr); err != nil {
+		problem("admin-addr", "%v", err)
+	}
+	if c.GRPCAddr != "" {
+		if err := checkAddr(c.GRPCAddr); err != nil {
+			problem("grpc-addr", "%v", err)
+		}
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		problem("log-level", "invalid level %q: must be debug, info, warn or error", c.LogLevel)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		problem("log-format", "invalid format %q: must be text or json", c.LogFormat)
+	}
+	if c.TrailingSlash != "keep" && c.TrailingSlash != "strip" && c.TrailingSlash != "add" {
+		problem("trailing-slash", "invalid policy %q: must be keep, strip or add", c.TrailingSlash)
+	}
+
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		problem("tls-cert", "tls-cert and tls-key must be provided together")
+	}
+	if c.TLSSelfSigned && c.TLSCert != "" {
+		problem("tls-self-signed", "cannot be combined with tls-cert/tls-key")
+	}
+
+	if c.LegacySunset != "" {
+		if _, err := time.Parse(time.RFC3339, c.LegacySunset); err != nil {
+			problem("legacy-sunset", "must be an RFC 3339 date: %v", err)
+		}
+	}
+
+	durations := []struct {
+		name string
+		d    Duration
+	}{
+		{"shutdown-timeout", c.ShutdownTimeout},
+		{"read-header-timeout", c.ReadHeaderTimeout},
+		{"read-timeout", c.ReadTimeout},
+		{"write-timeout", c.WriteTimeout},
+		{"idle-timeout", c.IdleTimeout},
+		{"handler-timeout", c.HandlerTimeout},
+		{"store-timeout", c.StoreTimeout},
+		{"body-read-timeout", c.BodyReadTimeout},
+		{"slow-request-threshold", c.SlowRequestThreshold},
+		{"max-in-flight-wait", c.MaxInFlightWait},
+	}
+	for _, d := range durations {
+		if d.d.Duration < 0 {
+			problem(d.name, "must not be negative")
+		}
+	}
+	if c.HandlerTimeout.Duration > 0 && c.StoreTimeout.Duration >= c.HandlerTimeout.Duration {
+		problem("store-timeout", "must be shorter than handler-timeout")
+	}
+	if c.IdempotencyTTL.Duration <= 0 {
+		problem("idempotency-ttl", "must be positive")
+	}
+	if c.SnapshotPath != "" && c.SnapshotInterval.Duration <= 0 {
+		problem("snapshot-interval", "must be positive")
+	}
+	switch {
+	case c.Store != "memory" && c.Store != "sqlite":
+		problem("store", "invalid store %q: must be memory or sqlite", c.Store)
+	case c.Store == "sqlite" && c.DBPath == "":
+		problem("db-path", "required with -store=sqlite")
+	case c.Store != "sqlite" && c.DBPath != "":
+		problem("db-path", "requires -store=sqlite")
+	}
+	if _, err := ParseTrustedProxies(c.TrustedProxies); err != nil {
+		problem("trusted-proxies", "%v", err)
+	}
+	if _, err := ParseSlowRequestGroups(c.SlowRequestGroups); err != nil {
+		problem("slow-request-groups", "%v", err)
+	}
+	if c.Collation != "" {
+		if _, err := language.Parse(c.Collation); err != nil {
+			problem("collation", "invalid language tag %q", c.Collation)
+		}
+	}
+	if c.SearchMaxResults <= 0 {
+		problem("search-max-results", "must be positive")
+	}
+	if c.StatsMaxNames <= 0 {
+		problem("stats-max-names", "must be positive")
+	}
+	if c.Greeting != "" && c.GreetingFile != "" {
+		problem("greeting-file", "cannot be combined with -greeting")
+	}
+	if c.MissingName != "default-name" && c.MissingName != "reject" && c.MissingName != "anonymous" {
+		problem("missing-name", "invalid mode %q: must be default-name, reject or anonymous", c.MissingName)
+	}
+	if c.MissingName == "default-name" && strings.TrimSpace(c.DefaultName) == "" {
+		problem("default-name", "must not be empty")
+	}
+	if c.GreetingCooldownLimit < 0 {
+		problem("greeting-cooldown-limit", "must not be negative")
+	}
+	if c.GreetingCooldownLimit > 0 && c.GreetingCooldownWindow.Duration <= 0 {
+		problem("greeting-cooldown-window", "must be positive")
+	}
+	if c.MaxInFlight < 0 {
+		problem("max-in-flight", "must not be negative")
+	}
+	for _, limit := range []struct {
+		name string
+		n    int
+	}{
+		{"max-url-length", c.MaxURLLength},
+		{"max-query-params", c.MaxQueryParams},
+		{"max-query-value-length", c.MaxQueryValueLength},
+	} {
+		if limit.n < 0 {
+			problem(limit.name, "must not be negative")
+		}
+	}
+	if c.AccessLogMaxSize <= 0 {
+		problem("access-log-max-size", "must be positive")
+	}
+	if c.AccessLogMaxBackups < 0 {
+		problem("access-log-max-backups", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkAddr accepts host:port with a numeric port; the host may be empty.
+func checkAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %v", addr, err)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q in %q", port, addr)
+	}
+	return nil
+}
+
+// slowRequestGroups are the route groups ParseSlowRequestGroups accepts.
+var slowRequestGroups = []string{"api", "legacy", "stream"}
+
+// ParseSlowRequestGroups parses a comma-separated list of group=duration
+// pairs, such as "stream=10s", into the threshold of each group.
+func ParseSlowRequestGroups(list string) (map[string]time.Duration, error) {
+	thresholds := make(map[string]time.Duration)
+	for _, pair := range strings.Split(list, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		group, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid pair %q: must be group=duration", pair)
+		}
+		group = strings.TrimSpace(group)
+		if !slices.Contains(slowRequestGroups, group) {
+			return nil, fmt.Errorf("invalid group %q: must be api, legacy or stream", group)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid threshold %q for group %s", value, group)
+		}
+		thresholds[group] = d
+	}
+	return thresholds, nil
+}
+
+// ParseList splits a comma-separated list, dropping empty entries.
+func ParseList(list string) []string {
+	var out []string
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ParseTrustedProxies parses a comma-separated list of CIDR prefixes or
+// single addresses. An empty list trusts no proxies.
+func ParseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			addr, err := netip.ParseAddr(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy address %q", p)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy prefix %q", p)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+	return path
+}
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args)
+}
+
+func TestPrecedence(t *testing.T) {
+	path := writeConfig(t, "server.json", `{
+		"addr": ":5000",
+		"admin-addr": "127.0.0.1:5001",
+		"log-level": "warn",
+		"read-timeout": "45s",
+		"search-max-results": 10
+	}`)
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("READ_TIMEOUT", "50s")
+	t.Setenv("SEARCH_MAX_RESULTS", "20")
+
+	c, err := load(t, "-config", path, "-search-max-results", "30")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		got      any
+		expected any
+	}{
+		{"default", c.LogFormat, "text"},
+		{"file", c.Addr, ":5000"},
+		{"file", c.AdminAddr, "127.0.0.1:5001"},
+		{"env over file", c.LogLevel, "debug"},
+		{"env over file", c.ReadTimeout.Duration, 50 * time.Second},
+		{"flag over env", c.SearchMaxResults, 30},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, tt.got)
+		}
+	}
+}
+
+func TestConfigFileFromEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfig(t, "server.json", `{"greeting": "Hi {{.Name}}"}`))
+
+	c, err := load(t)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Greeting != "Hi {{.Name}}" {
+		t.Errorf("bad greeting: got %q", c.Greeting)
+	}
+}
+
+func TestMissingFileIsNotFatal(t *testing.T) {
+	c, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if *c != Default() {
+		t.Errorf("expected defaults, got %+v", c)
+	}
+}
+
+func TestUnknownKeysRejected(t *testing.T) {
+	path := writeConfig(t, "server.json", `{"addr": ":5000", "adress": ":6000"}`)
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "adress") {
+		t.Errorf("expected an error naming the unknown key, got %v", err)
+	}
+}
+
+func TestFileFormats(t *testing.T) {
+	files := map[string]string{
+		"server.json": `{"addr": ":5000", "read-timeout": "45s", "search-max-results": 10, "tls-self-signed": true}`,
+		"server.yaml": "addr: \":5000\"\nread-timeout: 45s\nsearch-max-results: 10\ntls-self-signed: true\n",
+		"server.yml":  "# comments are allowed\naddr: ':5000'\nread-timeout: 45s\nsearch-max-results: 10\ntls-self-signed: true\n",
+	}
+	for name, content := range files {
+		c, err := LoadConfig(writeConfig(t, name, content))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if c.Addr != ":5000" || c.ReadTimeout.Duration != 45*time.Second || c.SearchMaxResults != 10 || !c.TLSSelfSigned {
+			t.Errorf("%s: bad config %+v", name, c)
+		}
+	}
+
+	c, err := LoadConfig(writeConfig(t, "empty.yaml", ""))
+	if err != nil {
+		t.Fatalf("empty.yaml: %v", err)
+	}
+	if *c != Default() {
+		t.Errorf("empty.yaml: expected defaults, got %+v", c)
+	}
+}
+
+func TestBadFiles(t *testing.T) {
+	tests := map[string]string{
+		"server.json": `{"read-timeout": 30}`,
+		"extra.json":  `{} {}`,
+		"server.yaml": "read-timeout: 30\n",
+		"server.yml":  "adress: :6000\n",
+		"two.yaml":    "addr: :5000\n---\naddr: :6000\n",
+		"list.yaml":   "- addr\n",
+	}
+	for name, content := range tests {
+		if _, err := LoadConfig(writeConfig(t, name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBadEnv(t *testing.T) {
+	t.Setenv("STATS_MAX_NAMES", "lots")
+
+	_, err := LoadConfig("")
+	if err == nil || !strings.Contains(err.Error(), "$STATS_MAX_NAMES") {
+		t.Errorf("expected an error naming $STATS_MAX_NAMES, got %v", err)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	c := Default()
+	c.Addr = ":99999"
+	c.GRPCAddr = "grpc"
+	c.LogLevel = "loud"
+	c.MaxInFlight = -1
+	c.MaxQueryParams = -1
+	c.TrailingSlash = "sometimes"
+	c.AccessLogMaxSize = 0
+	c.GreetingCooldownLimit = 5
+	c.GreetingCooldownWindow = Duration{}
+	c.Greeting = "Hi {{.Name}}"
+	c.GreetingFile = "greeting.tmpl"
+	c.SlowRequestGroups = "exports=10s"
+	c.MissingName = "guess"
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"addr: invalid port", "grpc-addr: invalid address", "log-level: invalid level", "max-in-flight: must not be negative", "max-query-params: must not be negative", "trailing-slash: invalid policy", "access-log-max-size: must be positive", "greeting-cooldown-window: must be positive", "greeting-file: cannot be combined with -greeting", "slow-request-groups: invalid group", "missing-name: invalid mode"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+
+	d := Default()
+	if err := d.Validate(); err != nil {
+		t.Errorf("defaults should be valid: %v", err)
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	tests := []struct {
+		cert       string
+		key        string
+		selfSigned bool
+		ok         bool
+	}{
+		{"", "", false, true},
+		{"cert.pem", "key.pem", false, true},
+		{"", "", true, true},
+		{"cert.pem", "", false, false},
+		{"", "key.pem", false, false},
+		{"cert.pem", "key.pem", true, false},
+	}
+
+	for _, tt := range tests {
+		c := Default()
+		c.TLSCert, c.TLSKey, c.TLSSelfSigned = tt.cert, tt.key, tt.selfSigned
+		err := c.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("tls-cert=%q tls-key=%q tls-self-signed=%v: expected ok=%v, got %v", tt.cert, tt.key, tt.selfSigned, tt.ok, err)
+		}
+	}
+}
+
+func TestValidateStore(t *testing.T) {
+	tests := []struct {
+		store  string
+		dbPath string
+		ok     bool
+	}{
+		{"memory", "", true},
+		{"sqlite", "users.db", true},
+		{"sqlite", "", false},
+		{"memory", "users.db", false},
+		{"postgres", "", false},
+	}
+
+	for _, tt := range tests {
+		c := Default()
+		c.Store, c.DBPath = tt.store, tt.dbPath
+		err := c.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("store=%q db-path=%q: expected ok=%v, got %v", tt.store, tt.dbPath, tt.ok, err)
+		}
+	}
+}
+
+func TestFlagErrors(t *testing.T) {
+	if _, err := load(t, "-read-timeout", "soon"); err == nil {
+		t.Error("expected an error for a bad duration flag")
+	}
+	if _, err := load(t, "-h"); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := ParseTrustedProxies(" 10.0.0.0/8, 192.168.1.7 ,2001:db8::/32,,::ffff:172.16.0.1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	var got []string
+	for _, p := range prefixes {
+		got = append(got, p.String())
+	}
+	expected := "10.0.0.0/8 192.168.1.7/32 2001:db8::/32 172.16.0.1/32"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "proxy.internal", "10.0.0.1/"} {
+		if _, err := ParseTrustedProxies(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestParseSlowRequestGroups(t *testing.T) {
+	groups, err := ParseSlowRequestGroups(" stream=10s, api = 1s,,legacy=0")
+	if err != nil {
+		t.Fatalf("ParseSlowRequestGroups: %v", err)
+	}
+	expected := map[string]time.Duration{"stream": 10 * time.Second, "api": time.Second, "legacy": 0}
+	if !maps.Equal(groups, expected) {
+		t.Errorf("expected %v, got %v", expected, groups)
+	}
+
+	for _, bad := range []string{"stream", "exports=1s", "api=soon", "api=-1s"} {
+		if _, err := ParseSlowRequestGroups(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestParseList(t *testing.T) {
+	if got := ParseList(" Authorization, ,X-User,"); !slices.Equal(got, []string{"Authorization", "X-User"}) {
+		t.Errorf("expected [Authorization X-User], got %q", got)
+	}
+	if got := ParseList(""); got != nil {
+		t.Errorf("expected nothing, got %q", got)
+	}
+}
@@ -0,0 +1,136 @@
+// Package fixtures describes server state declaratively for tests. A Fixture
+// can be written as a Go literal or loaded from a JSON file under testdata,
+// and is validated eagerly so a broken fixture fails with a clear message
+// before any server is started.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+type User struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+}
+
+// Fixture is the full state a test server starts from. Now, when set, fixes
+// the clock used for user timestamps.
+type Fixture struct {
+	Now   time.Time `json:"now"`
+	Users []User    `json:"users"`
+}
+
+// Load reads and validates a JSON fixture. Unknown fields are rejected so
+// typos do not silently produce an empty fixture.
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixture: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var f Fixture
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("error parsing fixture %s: %w", path, err)
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+
+	return &f, nil
+}
+
+// MustLoad is Load for use in tests, failing t on any error.
+func MustLoad(t testing.TB, path string) *Fixture {
+	t.Helper()
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// Validate reports every problem in the fixture at once.
+func (f *Fixture) Validate() error {
+	var errs []error
+	names := make(map[string]int)
+	emails := make(map[string]int)
+
+	for i, u := range f.Users {
+		if u.FirstName == "" {
+			errs = append(errs, fmt.Errorf("users[%d]: missing first name", i))
+		}
+		if u.LastName == "" {
+			errs = append(errs, fmt.Errorf("users[%d]: missing last name", i))
+		}
+
+		addr, err := mail.ParseAddress(u.Email)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("users[%d]: invalid email %q", i, u.Email))
+		} else if j, ok := emails[addr.Address]; ok {
+			errs = append(errs, fmt.Errorf("users[%d]: email %q already used by users[%d]", i, addr.Address, j))
+		} else {
+			emails[addr.Address] = i
+		}
+
+		name := u.FirstName + " " + u.LastName
+		if j, ok := names[name]; ok {
+			errs = append(errs, fmt.Errorf("users[%d]: name %q already used by users[%d]", i, name, j))
+		} else {
+			names[name] = i
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Manager builds a users.Manager holding the fixture's users in order.
+func (f *Fixture) Manager(t testing.TB) *users.Manager {
+	t.Helper()
+
+	if err := f.Validate(); err != nil {
+		t.Fatalf("invalid fixture: %v", err)
+	}
+
+	var opts []users.Option
+	if !f.Now.IsZero() {
+		now := f.Now
+		opts = append(opts, users.WithClock(func() time.Time { return now }))
+	}
+
+	m := users.NewManager(opts...)
+	for i, u := range f.Users {
+		if err := m.AddUser(context.Background(), u.FirstName, u.LastName, u.Email); err != nil {
+			t.Fatalf("error applying users[%d]: %v", i, err)
+		}
+	}
+	return m
+}
+
+// Serve starts an httptest server for the handler built from the fixture's
+// manager. The server is closed when the test and its subtests finish.
+func (f *Fixture) Serve(t testing.TB, handler func(*users.Manager) http.Handler) (*httptest.Server, *users.Manager) {
+	t.Helper()
+
+	m := f.Manager(t)
+	srv := httptest.NewServer(handler(m))
+	t.Cleanup(srv.Close)
+	return srv, m
+}
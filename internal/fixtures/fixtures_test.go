@@ -0,0 +1,88 @@
+package fixtures
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+func TestLoad(t *testing.T) {
+	f := MustLoad(t, "testdata/users.json")
+
+	m := f.Manager(t)
+	all := m.GetAllUsers()
+	if len(all) != 2 {
+		t.Fatalf("bad user count: expected 2, got %d", len(all))
+	}
+
+	expectedTime := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, u := range all {
+		if !u.CreatedAt.Equal(expectedTime) {
+			t.Errorf("bad CreatedAt for %q: expected %v, got %v", u.FirstName, expectedTime, u.CreatedAt)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	_, err := Load("testdata/invalid.json")
+	if err == nil {
+		t.Fatal("no error returned for invalid fixture")
+	}
+
+	for _, want := range []string{
+		"users[0]: missing first name",
+		`users[1]: invalid email "not-an-email"`,
+		`users[2]: email "jhon@bar.com" already used by users[0]`,
+		`users[2]: name "jane doe" already used by users[1]`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	_, err := Load("testdata/unknown_field.json")
+	if err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+}
+
+func TestServeCleanupOrder(t *testing.T) {
+	f := &Fixture{Users: []User{{FirstName: "jhon", LastName: "smith", Email: "jhon@bar.com"}}}
+	handler := func(m *users.Manager) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	var url string
+	t.Run("serve", func(t *testing.T) {
+		srv, m := f.Serve(t, handler)
+		url = srv.URL
+
+		if len(m.GetAllUsers()) != 1 {
+			t.Errorf("fixture users not applied to manager")
+		}
+
+		// Cleanups run last-in first-out, so the server is still up for
+		// cleanups the test registers after Serve.
+		t.Cleanup(func() {
+			resp, err := http.Get(url)
+			if err != nil {
+				t.Errorf("server closed before later cleanup: %v", err)
+				return
+			}
+			resp.Body.Close()
+		})
+	})
+
+	resp, err := http.Get(url)
+	if err == nil {
+		resp.Body.Close()
+		t.Errorf("server still serving after test cleanup")
+	}
+}
@@ -0,0 +1,245 @@
+// Package greeting formats the greetings served by the hello endpoints. The
+// server, the users CLI and the client package share it so every caller
+// produces exactly the same text.
+package greeting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kunalkumar-1/go-http/internal/i18n"
+)
+
+// SalutationLanguage is the language of every Salutations entry.
+const SalutationLanguage = "en"
+
+// Salutations are the only values Salute accepts. They are plain text, never
+// templates, so a caller cannot inject template actions.
+var Salutations = []string{
+	"Hello",
+	"Hi",
+	"Hey",
+	"Welcome",
+	"Good morning",
+	"Good afternoon",
+	"Good evening",
+}
+
+var (
+	// ErrEmptyName is returned for a name that is empty or only whitespace.
+	ErrEmptyName = errors.New("user must not be empty")
+	// ErrNameTooLong is returned for a name over the Greeter's length limit.
+	ErrNameTooLong = errors.New("user is too long")
+	// ErrUnknownSalutation is returned by Salute for a salutation that is
+	// not in Salutations.
+	ErrUnknownSalutation = errors.New("unknown salutation")
+)
+
+// Result is a rendered greeting and the language tag it was rendered in.
+type Result struct {
+	Greeting string `json:"greeting"`
+	Language string `json:"language"`
+}
+
+// HTMLResult is a greeting rendered as an HTML fragment, safe to insert in
+// a page as is.
+type HTMLResult struct {
+	Greeting template.HTML
+	Language string
+}
+
+// Sanitize removes the control characters from name, so a text response
+// cannot carry terminal escapes or break lines.
+func Sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+// greetingSlack is the room Negotiate reserves for a template's text
+// around the name: enough for the built-in templates, so a greeting is
+// rendered into a single allocation.
+const greetingSlack = 24
+
+// Greeter renders greetings from an i18n catalog. It is safe for concurrent
+// use as long as its catalog is. Reload swaps in another catalog atomically,
+// so each greeting is rendered wholly from the old one or the new one.
+type Greeter struct {
+	catalog  atomic.Pointer[i18n.Catalog]
+	template string
+	now      func() time.Time
+
+	// maxNameLength caps names in runes; 0 allows any length.
+	maxNameLength int
+}
+
+type Option func(*Greeter)
+
+// WithCatalog renders greetings from c instead of the built-in translations.
+func WithCatalog(c *i18n.Catalog) Option {
+	return func(g *Greeter) {
+		g.catalog.Store(c)
+	}
+}
+
+// WithTemplate replaces the default-language greeting template. New installs
+// it with i18n.Catalog.SetDefault, so it also changes the catalog passed to
+// WithCatalog. An empty text keeps the built-in template.
+func WithTemplate(text string) Option {
+	return func(g *Greeter) {
+		g.template = text
+	}
+}
+
+// WithClock sets the clock templates see as .Time.
+func WithClock(now func() time.Time) Option {
+	return func(g *Greeter) {
+		g.now = now
+	}
+}
+
+// WithMaxNameLength rejects names longer than n runes with ErrNameTooLong.
+func WithMaxNameLength(n int) Option {
+	return func(g *Greeter) {
+		g.maxNameLength = n
+	}
+}
+
+// New returns a Greeter. It fails if the WithTemplate text is not a valid
+// greeting template.
+func New(opts ...Option) (*Greeter, error) {
+	g := &Greeter{now: time.Now}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.catalog.Load() == nil {
+		g.catalog.Store(i18n.Builtin())
+	}
+	if err := setTemplate(g.catalog.Load(), g.template); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Reload makes the Greeter render from c, with text as the default-language
+// greeting template unless it is empty. c must not be in use yet, since the
+// template is installed with i18n.Catalog.SetDefault. If text is not a valid
+// greeting template, Reload returns an error and the Greeter keeps its
+// current catalog.
+func (g *Greeter) Reload(c *i18n.Catalog, text string) error {
+	if err := setTemplate(c, text); err != nil {
+		return err
+	}
+	g.catalog.Store(c)
+	return nil
+}
+
+func setTemplate(c *i18n.Catalog, text string) error {
+	if text == "" {
+		return nil
+	}
+	if err := c.SetDefault(i18n.KeyGreeting, text); err != nil {
+		return fmt.Errorf("invalid greeting template: %w", err)
+	}
+	return nil
+}
+
+// Catalog returns the catalog the Greeter renders from.
+func (g *Greeter) Catalog() *i18n.Catalog {
+	return g.catalog.Load()
+}
+
+// Check reports whether name can be greeted.
+func (g *Greeter) Check(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return ErrEmptyName
+	}
+	if g.maxNameLength > 0 && utf8.RuneCountInString(name) > g.maxNameLength {
+		return ErrNameTooLong
+	}
+	return nil
+}
+
+// Greet returns the greeting for name in the default language.
+func (g *Greeter) Greet(name string) (string, error) {
+	res, err := g.Negotiate("", name)
+	return res.Greeting, err
+}
+
+// GreetJSON returns the default-language greeting for name encoded as a
+// Result, the shape of the /api/v1 hello responses.
+func (g *Greeter) GreetJSON(name string) ([]byte, error) {
+	res, err := g.Negotiate("", name)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+// Negotiate returns the plain-text greeting for name in the best language
+// from an Accept-Language header value. The name is passed through
+// Sanitize and otherwise used as is.
+func (g *Greeter) Negotiate(acceptLanguage string, name string) (Result, error) {
+	name = Sanitize(name)
+	if err := g.Check(name); err != nil {
+		return Result{}, err
+	}
+
+	var out strings.Builder
+	out.Grow(len(name) + greetingSlack)
+	tag, err := g.catalog.Load().Render(&out, acceptLanguage, i18n.KeyGreeting, i18n.GreetingData{Name: name, Time: g.now()})
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Greeting: out.String(), Language: tag}, nil
+}
+
+// NegotiateAnonymous is Negotiate for someone whose name is not known,
+// such as "Hello there!".
+func (g *Greeter) NegotiateAnonymous(acceptLanguage string) (Result, error) {
+	var out strings.Builder
+	tag, err := g.catalog.Load().Render(&out, acceptLanguage, i18n.KeyAnonymousGreeting, i18n.GreetingData{Time: g.now()})
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Greeting: out.String(), Language: tag}, nil
+}
+
+// NegotiateHTML is Negotiate for an HTML page. The template is executed as
+// an html/template, which escapes the name; Sanitize is not applied.
+func (g *Greeter) NegotiateHTML(acceptLanguage string, name string) (HTMLResult, error) {
+	if err := g.Check(name); err != nil {
+		return HTMLResult{}, err
+	}
+
+	var out strings.Builder
+	tag, err := g.catalog.Load().RenderHTML(&out, acceptLanguage, i18n.KeyGreeting, i18n.GreetingData{Name: name, Time: g.now()})
+	if err != nil {
+		return HTMLResult{}, err
+	}
+	return HTMLResult{Greeting: template.HTML(out.String()), Language: tag}, nil
+}
+
+// Salute greets name with one of the Salutations in plain text, sanitized
+// like Negotiate. The result is always in SalutationLanguage.
+func (g *Greeter) Salute(salutation string, name string) (Result, error) {
+	if !slices.Contains(Salutations, salutation) {
+		return Result{}, fmt.Errorf("%w: %q", ErrUnknownSalutation, salutation)
+	}
+	name = Sanitize(name)
+	if err := g.Check(name); err != nil {
+		return Result{}, err
+	}
+	return Result{Greeting: salutation + " " + name + "!", Language: SalutationLanguage}, nil
+}
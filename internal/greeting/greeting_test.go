@@ -0,0 +1,263 @@
+package greeting
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kunalkumar-1/go-http/internal/i18n"
+)
+
+func newGreeter(t *testing.T, opts ...Option) *Greeter {
+	t.Helper()
+
+	g, err := New(opts...)
+	if err != nil {
+		t.Fatalf("error creating greeter: %v", err)
+	}
+	return g
+}
+
+func TestGreet(t *testing.T) {
+	g := newGreeter(t)
+
+	got, err := g.Greet("alice")
+	if err != nil || got != "Hello alice!" {
+		t.Errorf("Greet: expected %q, got %q, %v", "Hello alice!", got, err)
+	}
+
+	// Names are data, never templates.
+	got, err = g.Greet("{{.Time}}")
+	if err != nil || got != "Hello {{.Time}}!" {
+		t.Errorf("Greet: expected the name verbatim, got %q, %v", got, err)
+	}
+
+	for _, name := range []string{"", "   "} {
+		if _, err := g.Greet(name); !errors.Is(err, ErrEmptyName) {
+			t.Errorf("Greet(%q): expected ErrEmptyName, got %v", name, err)
+		}
+	}
+}
+
+func TestGreetJSON(t *testing.T) {
+	data, err := newGreeter(t).GreetJSON("alice")
+	if err != nil {
+		t.Fatalf("error greeting: %v", err)
+	}
+	if string(data) != `{"greeting":"Hello alice!","language":"en"}` {
+		t.Errorf("bad JSON: %s", data)
+	}
+
+	var res Result
+	if err := json.Unmarshal(data, &res); err != nil || res.Greeting != "Hello alice!" {
+		t.Errorf("JSON does not round trip: %+v, %v", res, err)
+	}
+
+	if _, err := newGreeter(t).GreetJSON(""); !errors.Is(err, ErrEmptyName) {
+		t.Errorf("expected ErrEmptyName, got %v", err)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	g := newGreeter(t)
+
+	tests := []struct {
+		acceptLanguage string
+		want           Result
+	}{
+		{"", Result{Greeting: "Hello ana!", Language: "en"}},
+		{"fr-CA, en;q=0.5", Result{Greeting: "Bonjour ana !", Language: "fr"}},
+		{"xx", Result{Greeting: "Hello ana!", Language: "en"}},
+	}
+	for _, tt := range tests {
+		got, err := g.Negotiate(tt.acceptLanguage, "ana")
+		if err != nil || got != tt.want {
+			t.Errorf("Negotiate(%q): expected %+v, got %+v, %v", tt.acceptLanguage, tt.want, got, err)
+		}
+	}
+}
+
+func TestNegotiateAnonymous(t *testing.T) {
+	g := newGreeter(t, WithTemplate("Welcome {{.Name}}"))
+
+	tests := []struct {
+		acceptLanguage string
+		want           Result
+	}{
+		{"", Result{Greeting: "Hello there!", Language: "en"}},
+		{"de", Result{Greeting: "Hallo zusammen!", Language: "de"}},
+		{"xx", Result{Greeting: "Hello there!", Language: "en"}},
+	}
+	for _, tt := range tests {
+		got, err := g.NegotiateAnonymous(tt.acceptLanguage)
+		if err != nil || got != tt.want {
+			t.Errorf("NegotiateAnonymous(%q): expected %+v, got %+v, %v", tt.acceptLanguage, tt.want, got, err)
+		}
+	}
+}
+
+func TestWithTemplate(t *testing.T) {
+	morning := time.Date(2024, time.March, 1, 8, 30, 0, 0, time.UTC)
+	g := newGreeter(t,
+		WithTemplate(`{{if lt .Time.Hour 12}}Good morning{{else}}Hello{{end}}, {{.Name}}!`),
+		WithClock(func() time.Time { return morning }),
+	)
+	if got, _ := g.Greet("alice"); got != "Good morning, alice!" {
+		t.Errorf("bad greeting: %q", got)
+	}
+
+	// Other languages keep their translations.
+	if got, _ := g.Negotiate("de", "alice"); got.Greeting != "Hallo alice!" {
+		t.Errorf("bad German greeting: %q", got.Greeting)
+	}
+
+	for _, bad := range []string{"Hello {{.Name", "Hello {{.Username}}!"} {
+		if _, err := New(WithTemplate(bad)); err == nil {
+			t.Errorf("no error returned for greeting template %q", bad)
+		}
+	}
+}
+
+func TestWithCatalog(t *testing.T) {
+	catalog := i18n.Builtin()
+	g := newGreeter(t, WithCatalog(catalog), WithTemplate("Hi {{.Name}}"))
+	if g.Catalog() != catalog {
+		t.Error("greeter does not use the given catalog")
+	}
+	if got, _ := g.Greet("bob"); got != "Hi bob" {
+		t.Errorf("bad greeting: %q", got)
+	}
+}
+
+func TestReload(t *testing.T) {
+	g := newGreeter(t, WithTemplate("Hi {{.Name}}"))
+
+	if err := g.Reload(i18n.Builtin(), "Hello {{.Username}}!"); err == nil {
+		t.Error("no error returned for an invalid template")
+	}
+	if got, _ := g.Greet("bob"); got != "Hi bob" {
+		t.Errorf("rejected reload changed the greeting: %q", got)
+	}
+
+	catalog := i18n.Builtin()
+	if err := g.Reload(catalog, "Howdy {{.Name}}"); err != nil {
+		t.Fatal(err)
+	}
+	if g.Catalog() != catalog {
+		t.Error("greeter does not use the reloaded catalog")
+	}
+	if got, _ := g.Greet("bob"); got != "Howdy bob" {
+		t.Errorf("bad greeting after reload: %q", got)
+	}
+
+	// An empty template keeps the catalog's own.
+	if err := g.Reload(i18n.Builtin(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := g.Greet("bob"); got != "Hello bob!" {
+		t.Errorf("bad greeting after reload: %q", got)
+	}
+}
+
+func TestReloadWhileGreeting(t *testing.T) {
+	g := newGreeter(t)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 8 {
+		wg.Go(func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				got, err := g.Greet("bob")
+				if err != nil || (got != "Hello bob!" && got != "Howdy bob") {
+					t.Errorf("greeting during reload: %q, %v", got, err)
+					return
+				}
+			}
+		})
+	}
+
+	for i := range 200 {
+		text := "Howdy {{.Name}}"
+		if i%3 == 0 {
+			text = "Howdy {{.Bad}}"
+		} else if i%3 == 1 {
+			text = ""
+		}
+		g.Reload(i18n.Builtin(), text)
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestWithMaxNameLength(t *testing.T) {
+	g := newGreeter(t, WithMaxNameLength(3))
+	if _, err := g.Greet("éèê"); err != nil {
+		t.Errorf("three runes rejected: %v", err)
+	}
+	if _, err := g.Greet("abcd"); !errors.Is(err, ErrNameTooLong) {
+		t.Errorf("expected ErrNameTooLong, got %v", err)
+	}
+}
+
+func TestSalute(t *testing.T) {
+	g := newGreeter(t)
+
+	got, err := g.Salute("Good evening", "alice")
+	if err != nil || got != (Result{Greeting: "Good evening alice!", Language: SalutationLanguage}) {
+		t.Errorf("bad salute: %+v, %v", got, err)
+	}
+	if _, err := g.Salute("{{.Name}}", "alice"); !errors.Is(err, ErrUnknownSalutation) {
+		t.Errorf("expected ErrUnknownSalutation, got %v", err)
+	}
+}
+
+func TestSalutationsAreTemplateFree(t *testing.T) {
+	for _, s := range Salutations {
+		if strings.ContainsAny(s, "{}") || strings.TrimSpace(s) != s {
+			t.Errorf("salutation %q is not plain text", s)
+		}
+	}
+}
+
+func TestDualRendering(t *testing.T) {
+	g := newGreeter(t)
+	name := "<b>bob</b>\x1b[31m\a"
+
+	text, err := g.Negotiate("", name)
+	if err != nil || text.Greeting != "Hello <b>bob</b>[31m!" {
+		t.Errorf("text: expected %q, got %q, %v", "Hello <b>bob</b>[31m!", text.Greeting, err)
+	}
+
+	html, err := g.NegotiateHTML("fr", "<b>bob</b>")
+	if err != nil || html.Greeting != "Bonjour &lt;b&gt;bob&lt;/b&gt; !" || html.Language != "fr" {
+		t.Errorf("html: expected fr %q, got %+v, %v", "Bonjour &lt;b&gt;bob&lt;/b&gt; !", html, err)
+	}
+
+	if _, err := g.Negotiate("", "\a\t"); !errors.Is(err, ErrEmptyName) {
+		t.Errorf("expected a name of control characters to be empty, got %v", err)
+	}
+	if got := Sanitize("a\tb\nc\u0085d"); got != "abcd" {
+		t.Errorf("Sanitize: expected %q, got %q", "abcd", got)
+	}
+}
+
+func TestNegotiateHTMLExecutionError(t *testing.T) {
+	// The sample data passes, so the template is accepted, but it fails for
+	// the name "boom".
+	g := newGreeter(t, WithTemplate(`<p>Hello {{.Name}}{{if eq .Name "boom"}}{{index .Name 99}}{{end}}</p>`))
+
+	if res, err := g.NegotiateHTML("", "boom"); err == nil || res.Greeting != "" {
+		t.Errorf("expected an error and no output, got %q, %v", res.Greeting, err)
+	}
+	if res, err := g.NegotiateHTML("", "bob"); err != nil || res.Greeting != "<p>Hello bob</p>" {
+		t.Errorf("expected %q, got %q, %v", "<p>Hello bob</p>", res.Greeting, err)
+	}
+}
@@ -0,0 +1,50 @@
+package httpx
+
+import "net/http"
+
+// Middleware is a named handler wrapper. The name only identifies it in
+// Chain.Stack.
+type Middleware struct {
+	Name string
+	Wrap func(http.Handler) http.Handler
+}
+
+// Chain is an ordered stack of middleware. The first middleware added is
+// the outermost: it sees each request first and the response last.
+type Chain struct {
+	stack []Middleware
+}
+
+// NewChain returns a chain of mw, outermost first.
+func NewChain(mw ...Middleware) *Chain {
+	return new(Chain).Use(mw...)
+}
+
+// Use appends mw inside the middleware already in c and returns c.
+func (c *Chain) Use(mw ...Middleware) *Chain {
+	c.stack = append(c.stack, mw...)
+	return c
+}
+
+// Extend returns a new chain of c's middleware followed by mw, leaving c
+// unchanged.
+func (c *Chain) Extend(mw ...Middleware) *Chain {
+	return NewChain(c.stack...).Use(mw...)
+}
+
+// Then wraps h in every middleware of c.
+func (c *Chain) Then(h http.Handler) http.Handler {
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		h = c.stack[i].Wrap(h)
+	}
+	return h
+}
+
+// Stack returns the names of c's middleware, outermost first.
+func (c *Chain) Stack() []string {
+	names := make([]string, len(c.stack))
+	for i, mw := range c.stack {
+		names[i] = mw.Name
+	}
+	return names
+}
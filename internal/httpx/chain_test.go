@@ -0,0 +1,60 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// tracing returns middleware that records when it sees the request and the
+// response.
+func tracing(name string, trace *[]string) Middleware {
+	return Middleware{Name: name, Wrap: func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, name+" in")
+			next.ServeHTTP(w, r)
+			*trace = append(*trace, name+" out")
+		})
+	}}
+}
+
+func TestChainOrder(t *testing.T) {
+	var trace []string
+	c := NewChain(tracing("a", &trace)).Use(tracing("b", &trace), tracing("c", &trace))
+
+	h := c.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"a in", "b in", "c in", "handler", "c out", "b out", "a out"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("bad execution order:\nexpected %q\ngot      %q", want, trace)
+	}
+	if got, want := c.Stack(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad stack: expected %q, got %q", want, got)
+	}
+}
+
+func TestChainExtend(t *testing.T) {
+	var trace []string
+	base := NewChain(tracing("a", &trace))
+	extended := base.Extend(tracing("b", &trace))
+
+	if got, want := base.Stack(), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Extend changed the base chain: %q", got)
+	}
+	if got, want := extended.Stack(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad extended stack: expected %q, got %q", want, got)
+	}
+}
+
+func TestEmptyChain(t *testing.T) {
+	called := false
+	h := NewChain().Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called {
+		t.Error("empty chain did not call the handler")
+	}
+}
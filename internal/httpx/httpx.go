@@ -0,0 +1,246 @@
+// Package httpx writes HTTP responses. The helpers set Content-Type and
+// X-Content-Type-Options before writing, refuse to write once a response
+// has started, and log write failures in one place: at Debug when the
+// client has gone away, at Error otherwise.
+//
+// The already-started check and the logger come from Track. On a writer
+// Track has not seen, the helpers still work but log through slog.Default
+// and cannot tell whether the response has started.
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+)
+
+// Error is the body of every error response, under the "error" key.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Fields  any    `json:"fields,omitempty"`
+}
+
+type errorResponse struct {
+	Error Error `json:"error"`
+}
+
+// CodeInternal is the error code of the fallback response written when a
+// JSON body cannot be encoded.
+const CodeInternal = "internal_error"
+
+// ErrHeaderSent is logged when a helper is asked to write a response that
+// has already started.
+var ErrHeaderSent = errors.New("response header already sent")
+
+// trackedWriter records whether the response has started, and carries the
+// request and logger the helpers report write errors with.
+type trackedWriter struct {
+	http.ResponseWriter
+	ctx    context.Context
+	logger *slog.Logger
+	sent   bool
+}
+
+func (tw *trackedWriter) WriteHeader(code int) {
+	// 1xx responses are informational; the real header is still to come.
+	if code >= 200 {
+		tw.sent = true
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *trackedWriter) Write(p []byte) (int, error) {
+	tw.sent = true
+	return tw.ResponseWriter.Write(p)
+}
+
+func (tw *trackedWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// Wrap returns w tracked for the helpers: they log through logger, treat
+// write errors after r's context is canceled as the client going away, and
+// refuse to write once the header has been sent.
+func Wrap(w http.ResponseWriter, r *http.Request, logger *slog.Logger) http.ResponseWriter {
+	if tw, ok := w.(*trackedWriter); ok {
+		return tw
+	}
+	return &trackedWriter{ResponseWriter: w, ctx: r.Context(), logger: logger}
+}
+
+// Track wraps every response of next with Wrap.
+func Track(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(Wrap(w, r, logger), r)
+	})
+}
+
+// tracked finds the trackedWriter under w, following Unwrap.
+func tracked(w http.ResponseWriter) *trackedWriter {
+	for {
+		switch t := w.(type) {
+		case *trackedWriter:
+			return t
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// HeaderSent reports whether the response on w has started. It is false on
+// writers Track has not seen.
+func HeaderSent(w http.ResponseWriter) bool {
+	tw := tracked(w)
+	return tw != nil && tw.sent
+}
+
+func loggerFor(w http.ResponseWriter) *slog.Logger {
+	if tw := tracked(w); tw != nil && tw.logger != nil {
+		return tw.logger
+	}
+	return slog.Default()
+}
+
+// ClientGone reports whether err, from writing to w, means the client went
+// away rather than something the server should hear about.
+func ClientGone(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, http.ErrHandlerTimeout) {
+		return true
+	}
+	tw := tracked(w)
+	return tw != nil && errors.Is(tw.ctx.Err(), context.Canceled)
+}
+
+// LogWriteError logs a failed write to w at Debug when the client has gone
+// away and at Error otherwise.
+func LogWriteError(w http.ResponseWriter, msg string, err error) {
+	if ClientGone(w, err) {
+		loggerFor(w).Debug(msg, "err", err)
+		return
+	}
+	loggerFor(w).Error(msg, "err", err)
+}
+
+// Header values every response shares. Header.Set would allocate a slice
+// for each; these are never modified in place, so one copy serves all.
+var (
+	nosniff         = []string{"nosniff"}
+	contentTypeText = []string{"text/plain; charset=utf-8"}
+	contentTypeJSON = []string{"application/json"}
+)
+
+// start sets Content-Type and X-Content-Type-Options and writes the header,
+// or reports false if the response has already started.
+func start(w http.ResponseWriter, status int, contentType string) bool {
+	if HeaderSent(w) {
+		loggerFor(w).Error("refusing to write response", "err", ErrHeaderSent, "status", status)
+		return false
+	}
+
+	h := w.Header()
+	switch contentType {
+	case contentTypeText[0]:
+		h["Content-Type"] = contentTypeText
+	case contentTypeJSON[0]:
+		h["Content-Type"] = contentTypeJSON
+	default:
+		h.Set("Content-Type", contentType)
+	}
+	h["X-Content-Type-Options"] = nosniff
+	w.WriteHeader(status)
+	return true
+}
+
+// Write sends body with the given status and Content-Type. Nothing is
+// written if the response has already started.
+func Write(w http.ResponseWriter, status int, contentType string, body []byte) {
+	if !start(w, status, contentType) {
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		LogWriteError(w, "error writing response", err)
+	}
+}
+
+// WriteText sends body as plain text. It is copied through a pooled buffer
+// rather than converted, since few ResponseWriters take a string as is.
+func WriteText(w http.ResponseWriter, status int, body string) {
+	b := buffers.Get().(*buffer)
+	defer b.release()
+
+	b.buf.WriteString(body)
+	Write(w, status, contentTypeText[0], b.buf.Bytes())
+}
+
+// WriteTextFunc sends as plain text the body write appends to a pooled
+// buffer. write must not keep the buffer.
+func WriteTextFunc(w http.ResponseWriter, status int, write func(buf *bytes.Buffer)) {
+	b := buffers.Get().(*buffer)
+	defer b.release()
+
+	write(&b.buf)
+	Write(w, status, contentTypeText[0], b.buf.Bytes())
+}
+
+// buffer is a pooled buffer and the JSON encoder writing to it, for
+// assembling bodies before anything is written, so an encoding failure can
+// still become a 500.
+type buffer struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var buffers = sync.Pool{New: func() any {
+	b := new(buffer)
+	b.enc = json.NewEncoder(&b.buf)
+	return b
+}}
+
+// maxPooledBuffer keeps one huge response from pinning its buffer.
+const maxPooledBuffer = 64 << 10
+
+// release resets b and returns it to the pool.
+func (b *buffer) release() {
+	if b.buf.Cap() > maxPooledBuffer {
+		return
+	}
+	b.buf.Reset()
+	buffers.Put(b)
+}
+
+// WriteJSON sends v encoded as JSON. If v cannot be encoded, the client gets
+// a 500 internal_error instead.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	b := buffers.Get().(*buffer)
+	defer b.release()
+
+	if err := b.enc.Encode(v); err != nil {
+		loggerFor(w).Error("error encoding JSON response", "err", err)
+		b.buf.Reset()
+		b.enc.Encode(errorResponse{Error: Error{Code: CodeInternal, Message: "error encoding response"}})
+		status = http.StatusInternalServerError
+	}
+	Write(w, status, contentTypeJSON[0], b.buf.Bytes())
+}
+
+// WriteError sends {"error":{"code":...,"message":...}}.
+func WriteError(w http.ResponseWriter, status int, code string, message string) {
+	WriteErrorBody(w, status, Error{Code: code, Message: message})
+}
+
+// WriteErrorBody sends body under the "error" key, for errors that carry
+// more than a code and message.
+func WriteErrorBody(w http.ResponseWriter, status int, body Error) {
+	WriteJSON(w, status, errorResponse{Error: body})
+}
@@ -0,0 +1,160 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func newLogger() (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), &buf
+}
+
+func TestHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		write       func(w http.ResponseWriter)
+		status      int
+		contentType string
+		body        string
+	}{
+		{"text", func(w http.ResponseWriter) { WriteText(w, http.StatusAccepted, "hi\n") }, http.StatusAccepted, "text/plain; charset=utf-8", "hi\n"},
+		{"json", func(w http.ResponseWriter) { WriteJSON(w, http.StatusCreated, map[string]int{"n": 1}) }, http.StatusCreated, "application/json", "{\"n\":1}\n"},
+		{"error", func(w http.ResponseWriter) { WriteError(w, http.StatusNotFound, "not_found", "gone") }, http.StatusNotFound, "application/json", "{\"error\":{\"code\":\"not_found\",\"message\":\"gone\"}}\n"},
+		{"raw", func(w http.ResponseWriter) { Write(w, http.StatusOK, "text/html; charset=utf-8", []byte("<p>")) }, http.StatusOK, "text/html; charset=utf-8", "<p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.write(w)
+
+			if w.Code != tt.status {
+				t.Errorf("bad response code: expected %d, got %d\nbody: %s\n", tt.status, w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("bad content type: expected %q, got %q", tt.contentType, ct)
+			}
+			if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("expected X-Content-Type-Options nosniff, got %q", got)
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("bad body: expected %q, got %q", tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWriteJSONEncodingFailure(t *testing.T) {
+	logger, logs := newLogger()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	WriteJSON(Wrap(w, r, logger), http.StatusOK, map[string]any{"ch": make(chan int)})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("bad response code: expected %d, got %d\nbody: %s\n", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	var resp errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("error decoding fallback body: %v\nbody: %s\n", err, w.Body.String())
+	}
+	if resp.Error.Code != CodeInternal {
+		t.Errorf("bad error code: expected %q, got %q", CodeInternal, resp.Error.Code)
+	}
+	if !strings.Contains(logs.String(), "level=ERROR") {
+		t.Errorf("expected the encoding error to be logged, got %q", logs.String())
+	}
+}
+
+func TestRefuseAfterHeaderSent(t *testing.T) {
+	logger, logs := newLogger()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for _, start := range []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) { w.WriteHeader(http.StatusNoContent) },
+		func(w http.ResponseWriter) { w.Write([]byte("partial")) },
+		func(w http.ResponseWriter) { WriteText(w, http.StatusOK, "first") },
+	} {
+		logs.Reset()
+		rec := httptest.NewRecorder()
+		w := Wrap(rec, r, logger)
+		start(w)
+		code, body := rec.Code, rec.Body.String()
+
+		WriteError(w, http.StatusInternalServerError, CodeInternal, "too late")
+
+		if rec.Code != code || rec.Body.String() != body {
+			t.Errorf("expected the response to be left alone, got %d %q", rec.Code, rec.Body.String())
+		}
+		if !strings.Contains(logs.String(), ErrHeaderSent.Error()) {
+			t.Errorf("expected the refusal to be logged, got %q", logs.String())
+		}
+	}
+}
+
+func TestRefuseThroughWrapper(t *testing.T) {
+	logger, _ := newLogger()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	// A middleware's writer around the tracked one is seen through Unwrap.
+	w := unwrapper{Wrap(rec, r, logger)}
+	w.WriteHeader(http.StatusAccepted)
+	WriteText(w, http.StatusOK, "too late")
+
+	if rec.Code != http.StatusAccepted || rec.Body.Len() != 0 {
+		t.Errorf("expected the response to be left alone, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+type unwrapper struct{ http.ResponseWriter }
+
+func (u unwrapper) Unwrap() http.ResponseWriter { return u.ResponseWriter }
+
+// brokenWriter fails every body write with err.
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+	err error
+}
+
+func (b brokenWriter) Write([]byte) (int, error) { return 0, b.err }
+
+func TestWriteErrorLogging(t *testing.T) {
+	live := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	gone := live.WithContext(ctx)
+
+	tests := []struct {
+		name  string
+		r     *http.Request
+		err   error
+		level string
+	}{
+		{"server error", live, errors.New("disk on fire"), "level=ERROR"},
+		{"broken pipe", live, syscall.EPIPE, "level=DEBUG"},
+		{"connection reset", live, syscall.ECONNRESET, "level=DEBUG"},
+		{"handler timeout", live, http.ErrHandlerTimeout, "level=DEBUG"},
+		{"canceled request", gone, errors.New("write failed"), "level=DEBUG"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, logs := newLogger()
+			w := Wrap(brokenWriter{httptest.NewRecorder(), tt.err}, tt.r, logger)
+
+			WriteText(w, http.StatusOK, "hello")
+
+			if !strings.Contains(logs.String(), tt.level) {
+				t.Errorf("expected a %s entry, got %q", tt.level, logs.String())
+			}
+		})
+	}
+}
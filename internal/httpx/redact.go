@@ -0,0 +1,146 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultRedactedHeaders and DefaultRedactedFields are what a server
+// redacts unless configured otherwise: credentials, and the headers the
+// hello routes read a user's name from.
+var (
+	DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "User", "X-User"}
+	DefaultRedactedFields  = []string{"password", "token"}
+)
+
+// maskPrefix is how many characters of a secret Mask keeps.
+const maskPrefix = 2
+
+// Redactor masks the values of sensitive headers and JSON fields so
+// requests can be logged or echoed without leaking them. The zero value
+// redacts nothing.
+type Redactor struct {
+	headers map[string]bool
+	fields  map[string]bool
+}
+
+// NewRedactor returns a Redactor for the named headers and JSON object
+// fields. Both are matched case-insensitively.
+func NewRedactor(headers []string, fields []string) *Redactor {
+	rd := &Redactor{headers: make(map[string]bool), fields: make(map[string]bool)}
+	for _, h := range headers {
+		rd.headers[http.CanonicalHeaderKey(strings.TrimSpace(h))] = true
+	}
+	for _, f := range fields {
+		rd.fields[strings.ToLower(strings.TrimSpace(f))] = true
+	}
+	return rd
+}
+
+// Header returns a copy of h with the value of every sensitive header
+// masked, keeping the scheme of an Authorization header. h itself is left
+// alone.
+func (rd *Redactor) Header(h http.Header) http.Header {
+	out := h.Clone()
+	if out == nil {
+		out = http.Header{}
+	}
+	for name, values := range out {
+		if !rd.headers[name] {
+			continue
+		}
+		mask := Mask
+		if name == "Authorization" || name == "Proxy-Authorization" {
+			mask = maskCredentials
+		}
+		for i, v := range values {
+			values[i] = mask(v)
+		}
+	}
+	return out
+}
+
+// JSON returns body with the value of every sensitive field, in objects at
+// any depth, masked. A body that is not JSON, or has nothing to redact, is
+// returned as it is; one that has is re-encoded, so its layout and key
+// order may change.
+func (rd *Redactor) JSON(body []byte) []byte {
+	if len(rd.fields) == 0 {
+		return body
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return body
+	}
+	if !rd.redactValue(v) {
+		return body
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// redactValue masks the sensitive fields under v in place, reporting
+// whether there were any.
+func (rd *Redactor) redactValue(v any) bool {
+	redacted := false
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if rd.fields[strings.ToLower(k)] {
+				v[k] = maskJSON(field)
+				redacted = true
+				continue
+			}
+			redacted = rd.redactValue(field) || redacted
+		}
+	case []any:
+		for _, elem := range v {
+			redacted = rd.redactValue(elem) || redacted
+		}
+	}
+	return redacted
+}
+
+// maskJSON masks a decoded JSON value, as text.
+func maskJSON(v any) string {
+	if s, ok := v.(string); ok {
+		return Mask(s)
+	}
+	b, _ := json.Marshal(v)
+	return Mask(string(b))
+}
+
+// Mask hides v but for its first two characters and its length in
+// characters, such as "ab…(42)", to help tell values apart when
+// debugging. A value of four characters or fewer shows only its length.
+func Mask(v string) string {
+	n := utf8.RuneCountInString(v)
+	var prefix string
+	if n > 2*maskPrefix {
+		i := 0
+		for range maskPrefix {
+			_, size := utf8.DecodeRuneInString(v[i:])
+			i += size
+		}
+		prefix = v[:i]
+	}
+	return prefix + "…(" + strconv.Itoa(n) + ")"
+}
+
+// maskCredentials is Mask for an Authorization header, keeping the
+// scheme: "Bearer ab…(42)".
+func maskCredentials(v string) string {
+	if scheme, credentials, ok := strings.Cut(v, " "); ok {
+		return scheme + " " + Mask(credentials)
+	}
+	return Mask(v)
+}
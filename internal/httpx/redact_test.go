@@ -0,0 +1,75 @@
+package httpx
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Bearer ab", "Be…(9)"},
+		{"session=abcdef; theme=dark", "se…(26)"},
+		{"héllo wörld", "hé…(11)"},
+		{"abcd", "…(4)"},
+		{"", "…(0)"},
+	}
+	for _, tt := range tests {
+		if got := Mask(tt.in); got != tt.want {
+			t.Errorf("Mask(%q): expected %q, got %q", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestRedactorHeader(t *testing.T) {
+	rd := NewRedactor(DefaultRedactedHeaders, nil)
+	h := http.Header{
+		"Authorization": {"Bearer " + strings.Repeat("ab", 21)},
+		"Cookie":        {"session=secret1", "theme=secret2"},
+		"User":          {"Mary Smith"},
+		"Accept":        {"application/json"},
+		"X-Request-Id":  {"req-1"},
+	}
+	got := rd.Header(h)
+
+	want := http.Header{
+		"Authorization": {"Bearer ab…(42)"},
+		"Cookie":        {"se…(15)", "th…(13)"},
+		"User":          {"Ma…(10)"},
+		"Accept":        {"application/json"},
+		"X-Request-Id":  {"req-1"},
+	}
+	for name, values := range want {
+		if strings.Join(got[name], "|") != strings.Join(values, "|") {
+			t.Errorf("%s: expected %q, got %q", name, values, got[name])
+		}
+	}
+	if h.Get("Authorization") != "Bearer "+strings.Repeat("ab", 21) {
+		t.Errorf("Header changed its argument: %q", h.Get("Authorization"))
+	}
+
+	custom := NewRedactor([]string{"x-request-id"}, nil).Header(h)
+	if custom.Get("X-Request-Id") != "re…(5)" || custom.Get("User") != "Mary Smith" {
+		t.Errorf("configured list not honored: %v", custom)
+	}
+}
+
+func TestRedactorJSON(t *testing.T) {
+	rd := NewRedactor(nil, DefaultRedactedFields)
+	tests := []struct {
+		in, want string
+	}{
+		{`{"email":"a@b.com","Password":"hunter2x"}`, `{"Password":"hu…(8)","email":"a@b.com"}`},
+		{`[{"user":{"token":12345678}}]`, `[{"user":{"token":"12…(8)"}}]`},
+		{`{"email": "a@b.com"}`, `{"email": "a@b.com"}`},
+		{`password=hunter2`, `password=hunter2`},
+		{`{"password":"x"} {}`, `{"password":"x"} {}`},
+	}
+	for _, tt := range tests {
+		if got := string(rd.JSON([]byte(tt.in))); got != tt.want {
+			t.Errorf("JSON(%s): expected %s, got %s", tt.in, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrStreamAborted is returned by every Write to a Stream once one has
+// failed, so callers producing the body know to stop.
+var ErrStreamAborted = errors.New("stream aborted")
+
+// Stream is the ResponseWriter for a body sent in chunks, such as an export.
+// Each Write is flushed so the client sees data as it is produced. The
+// first write or flush that fails aborts the stream: it is logged once, at
+// Debug when the client has gone away and at Error otherwise, reported to
+// the abort hook, and that Write and every later one return an error
+// wrapping ErrStreamAborted without touching the connection again.
+type Stream struct {
+	http.ResponseWriter
+	rc      *http.ResponseController
+	name    string
+	aborted func(clientGone bool)
+	err     error
+}
+
+// NewStream returns a Stream writing to w. name identifies the stream in
+// the log. aborted, if not nil, is called once if the stream is aborted,
+// for metrics.
+func NewStream(w http.ResponseWriter, name string, aborted func(clientGone bool)) *Stream {
+	return &Stream{ResponseWriter: w, rc: http.NewResponseController(w), name: name, aborted: aborted}
+}
+
+func (s *Stream) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err := s.ResponseWriter.Write(p)
+	if err == nil {
+		// A writer that cannot flush still delivers the bytes, later.
+		if err = s.rc.Flush(); errors.Is(err, http.ErrNotSupported) {
+			err = nil
+		}
+	}
+	if err != nil {
+		s.abort(err)
+		return n, s.err
+	}
+	return n, nil
+}
+
+// abort records that the stream failed with err.
+func (s *Stream) abort(err error) {
+	s.err = fmt.Errorf("%w: %w", ErrStreamAborted, err)
+
+	gone := ClientGone(s.ResponseWriter, err)
+	if gone {
+		loggerFor(s.ResponseWriter).Debug("stream aborted", "stream", s.name, "err", err)
+	} else {
+		loggerFor(s.ResponseWriter).Error("stream aborted", "stream", s.name, "err", err)
+	}
+	if s.aborted != nil {
+		s.aborted(gone)
+	}
+}
+
+// Err returns the error that aborted the stream, or nil.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+func (s *Stream) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,76 @@
+package httpx
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// failingWriter fails the failAt-th body write, counting from 1, and every
+// one after it, counting them all.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	failAt int
+	err    error
+	writes int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	f.writes++
+	if f.writes >= f.failAt {
+		return 0, f.err
+	}
+	return f.ResponseRecorder.Write(p)
+}
+
+func TestStreamAbortsOnFirstFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		level string
+		gone  bool
+	}{
+		{"server error", errors.New("disk on fire"), "level=ERROR", false},
+		{"client gone", syscall.EPIPE, "level=DEBUG", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, logs := newLogger()
+			fw := &failingWriter{ResponseRecorder: httptest.NewRecorder(), failAt: 3, err: tt.err}
+			w := Wrap(fw, httptest.NewRequest(http.MethodGet, "/", nil), logger)
+
+			var aborts []bool
+			stream := NewStream(w, "test", func(gone bool) { aborts = append(aborts, gone) })
+			var err error
+			for range 10 {
+				if _, err = stream.Write([]byte("chunk\n")); err != nil {
+					break
+				}
+			}
+			// A caller that ignores the error still gets no further writes.
+			_, again := stream.Write([]byte("more\n"))
+
+			if !errors.Is(err, ErrStreamAborted) || !errors.Is(err, tt.err) || !errors.Is(again, ErrStreamAborted) {
+				t.Errorf("expected ErrStreamAborted wrapping %v, got %v then %v", tt.err, err, again)
+			}
+			if !errors.Is(stream.Err(), ErrStreamAborted) {
+				t.Errorf("Err: got %v", stream.Err())
+			}
+			if fw.writes != 3 {
+				t.Errorf("expected writing to stop at the failed write, got %d writes", fw.writes)
+			}
+			if fw.Body.String() != "chunk\nchunk\n" || !fw.Flushed {
+				t.Errorf("expected two flushed chunks, got %q (flushed %v)", fw.Body.String(), fw.Flushed)
+			}
+			if n := strings.Count(logs.String(), "\n"); n != 1 || !strings.Contains(logs.String(), tt.level+` msg="stream aborted" stream=test`) {
+				t.Errorf("expected one %s entry, got:\n%s", tt.level, logs.String())
+			}
+			if len(aborts) != 1 || aborts[0] != tt.gone {
+				t.Errorf("expected one abort with clientGone=%v, got %v", tt.gone, aborts)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// Package i18n renders localized response messages. Built-in translations are
+// compiled in; additional bundles can be loaded from a directory at startup
+// and reloaded at runtime, and take precedence over the built-ins for the
+// language tags they define.
+package i18n
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultTag is served when no requested language is available.
+const DefaultTag = "en"
+
+// Message keys. KeyAnonymousGreeting greets someone whose name is not
+// known.
+const (
+	KeyGreeting          = "greeting"
+	KeyAnonymousGreeting = "anonymous-greeting"
+)
+
+// GreetingData is the data KeyGreeting and KeyAnonymousGreeting templates
+// are executed with. Name is empty for KeyAnonymousGreeting.
+type GreetingData struct {
+	Name string
+	Time time.Time
+}
+
+// keyData holds a sample of the data each key is rendered with. Templates
+// are executed against it before activation to catch unknown fields.
+var keyData = map[string]any{
+	KeyGreeting:          GreetingData{Name: "Name", Time: time.Unix(0, 0)},
+	KeyAnonymousGreeting: GreetingData{Time: time.Unix(0, 0)},
+}
+
+var builtin = map[string]map[string]string{
+	"en": {KeyGreeting: "Hello {{.Name}}!", KeyAnonymousGreeting: "Hello there!"},
+	"fr": {KeyGreeting: "Bonjour {{.Name}} !", KeyAnonymousGreeting: "Bonjour à tous !"},
+	"es": {KeyGreeting: "¡Hola {{.Name}}!", KeyAnonymousGreeting: "¡Hola a todos!"},
+	"de": {KeyGreeting: "Hallo {{.Name}}!", KeyAnonymousGreeting: "Hallo zusammen!"},
+}
+
+// bundle maps language tag to message key to parsed template.
+type bundle map[string]map[string]*template.Template
+
+// Catalog negotiates a language and renders messages from the runtime bundle
+// loaded from dir, falling back to the built-in translations. It is safe for
+// concurrent use; Reload swaps the runtime bundle atomically.
+type Catalog struct {
+	dir     string
+	builtin bundle
+	runtime atomic.Pointer[bundle]
+}
+
+// NewCatalog returns a catalog with the built-in translations and, when dir
+// is not empty, the bundles found in dir.
+func NewCatalog(dir string) (*Catalog, error) {
+	c := &Catalog{dir: dir, builtin: bundle{}}
+	for tag, messages := range builtin {
+		for key, text := range messages {
+			if err := c.builtin.add(tag, key, text); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	empty := bundle{}
+	c.runtime.Store(&empty)
+
+	if dir != "" {
+		if err := c.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Builtin returns a catalog holding only the built-in translations.
+func Builtin() *Catalog {
+	c, err := NewCatalog("")
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// SetDefault replaces the built-in template for key in DefaultTag. It must
+// be called before the catalog is used. Runtime bundles still take
+// precedence for the tags they define.
+func (c *Catalog) SetDefault(key string, text string) error {
+	return c.builtin.add(DefaultTag, key, text)
+}
+
+// Reload reads every <tag>.json file in the catalog's directory, where tag
+// must be a language tag language.Parse accepts. Each file maps message keys
+// to templates. If any file is invalid the whole reload is
+// rejected and the previously loaded bundle stays active.
+func (c *Catalog) Reload() error {
+	if c.dir == "" {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("error listing locale bundles: %w", err)
+	}
+
+	loaded := bundle{}
+	var errs []error
+	for _, path := range paths {
+		tag := strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if _, err := language.Parse(tag); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid language tag %q", path, tag))
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+
+		for key, text := range messages {
+			if err := loaded.add(tag, key, text); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("locale bundle rejected: %w", err)
+	}
+
+	c.runtime.Store(&loaded)
+	return nil
+}
+
+// add parses text and checks that it executes against the sample data for
+// key, so a bundle cannot be activated if it would fail at render time.
+func (b bundle) add(tag string, key string, text string) error {
+	sample, ok := keyData[key]
+	if !ok {
+		return fmt.Errorf("unknown message key %q", key)
+	}
+
+	tmpl, err := template.New(tag + "/" + key).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid template for %q: %w", key, err)
+	}
+
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return fmt.Errorf("incompatible placeholders for %q: %w", key, err)
+	}
+
+	if b[tag] == nil {
+		b[tag] = make(map[string]*template.Template)
+	}
+	b[tag][key] = tmpl
+	return nil
+}
+
+// Lookup returns the template for key in the best language from an
+// Accept-Language header value, and the tag it was found under.
+func (c *Catalog) Lookup(acceptLanguage string, key string) (string, *template.Template) {
+	runtime := *c.runtime.Load()
+	lookup := func(tag string) (string, *template.Template) {
+		for _, candidate := range [2]string{tag, baseLanguage(tag)} {
+			if tmpl, ok := runtime[candidate][key]; ok {
+				return candidate, tmpl
+			}
+			if tmpl, ok := c.builtin[candidate][key]; ok {
+				return candidate, tmpl
+			}
+		}
+		return "", nil
+	}
+
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if found, tmpl := lookup(tag); tmpl != nil {
+			return found, tmpl
+		}
+	}
+	return lookup(DefaultTag)
+}
+
+// Render executes the template for key with data and reports the language
+// tag it was rendered in.
+func (c *Catalog) Render(w io.Writer, acceptLanguage string, key string, data any) (string, error) {
+	tag, tmpl := c.Lookup(acceptLanguage, key)
+	if tmpl == nil {
+		return "", fmt.Errorf("no translation for message key %q", key)
+	}
+	return tag, tmpl.Execute(w, data)
+}
+
+// RenderHTML is Render for an HTML page. The template is executed as an
+// html/template, so data is escaped for the context it lands in while the
+// template's own markup is kept. Nothing is written if execution fails.
+func (c *Catalog) RenderHTML(w io.Writer, acceptLanguage string, key string, data any) (string, error) {
+	tag, tmpl := c.Lookup(acceptLanguage, key)
+	if tmpl == nil {
+		return "", fmt.Errorf("no translation for message key %q", key)
+	}
+
+	// The escaper rewrites the tree it is given, so it gets a copy.
+	page, err := htmltemplate.New(tmpl.Name()).Option("missingkey=error").AddParseTree(tmpl.Name(), tmpl.Tree.Copy())
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := page.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	_, err = io.WriteString(w, buf.String())
+	return tag, err
+}
+
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(tag, "-")
+	return base
+}
+
+// parseAcceptLanguage returns the language tags from an Accept-Language
+// header ordered by descending quality. Wildcards and tags with q=0 are
+// dropped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for part := range strings.SplitSeq(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+
+	slices.SortStableFunc(tags, func(a, b weighted) int { return cmp.Compare(b.q, a.q) })
+
+	if len(tags) == 0 {
+		return nil
+	}
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
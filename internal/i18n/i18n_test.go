@@ -0,0 +1,188 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeBundle(t *testing.T, dir string, tag string, content string) {
+	t.Helper()
+
+	err := os.WriteFile(filepath.Join(dir, tag+".json"), []byte(content), 0o644)
+	if err != nil {
+		t.Fatalf("error writing bundle: %v", err)
+	}
+}
+
+func render(t *testing.T, c *Catalog, acceptLanguage string) (string, string) {
+	t.Helper()
+
+	var sb strings.Builder
+	tag, err := c.Render(&sb, acceptLanguage, KeyGreeting, GreetingData{Name: "Ana"})
+	if err != nil {
+		t.Fatalf("error rendering greeting: %v", err)
+	}
+	return tag, sb.String()
+}
+
+func TestBuiltins(t *testing.T) {
+	c, err := NewCatalog("")
+	if err != nil {
+		t.Fatalf("error creating catalog: %v", err)
+	}
+
+	tests := []struct {
+		acceptLanguage string
+		tag            string
+		want           string
+	}{
+		{"", "en", "Hello Ana!"},
+		{"fr", "fr", "Bonjour Ana !"},
+		{"fr-CA, en;q=0.5", "fr", "Bonjour Ana !"},
+		{"ja, de;q=0.9, fr;q=0.8", "de", "Hallo Ana!"},
+		{"es;q=0.2, de;q=0.7", "de", "Hallo Ana!"},
+		{"ja", "en", "Hello Ana!"},
+		{"fr;q=0, *", "en", "Hello Ana!"},
+	}
+
+	for _, tt := range tests {
+		tag, got := render(t, c, tt.acceptLanguage)
+		if tag != tt.tag || got != tt.want {
+			t.Errorf("Accept-Language %q: expected %s %q, got %s %q",
+				tt.acceptLanguage, tt.tag, tt.want, tag, got)
+		}
+	}
+}
+
+func TestRuntimeBundleOverridesBuiltin(t *testing.T) {
+	dir := t.TempDir()
+	writeBundle(t, dir, "fr", `{"greeting": "Salut {{.Name}} !"}`)
+	writeBundle(t, dir, "it", `{"greeting": "Ciao {{.Name}}!"}`)
+
+	c, err := NewCatalog(dir)
+	if err != nil {
+		t.Fatalf("error creating catalog: %v", err)
+	}
+
+	if _, got := render(t, c, "fr"); got != "Salut Ana !" {
+		t.Errorf("runtime override not preferred: got %q", got)
+	}
+	if _, got := render(t, c, "it"); got != "Ciao Ana!" {
+		t.Errorf("runtime-only language not served: got %q", got)
+	}
+	if _, got := render(t, c, "de"); got != "Hallo Ana!" {
+		t.Errorf("builtin not served for unlisted language: got %q", got)
+	}
+}
+
+func TestReloadRejectsBrokenBundle(t *testing.T) {
+	dir := t.TempDir()
+	writeBundle(t, dir, "fr", `{"greeting": "Salut {{.Name}} !"}`)
+
+	c, err := NewCatalog(dir)
+	if err != nil {
+		t.Fatalf("error creating catalog: %v", err)
+	}
+
+	broken := map[string]string{
+		"syntax":      `{"greeting": "Salut {{.Name !"}`,
+		"placeholder": `{"greeting": "Salut {{.Nickname}} !"}`,
+		"unknown key": `{"farewell": "Au revoir"}`,
+		"json":        `{"greeting": `,
+	}
+	for name, content := range broken {
+		writeBundle(t, dir, "it", `{"greeting": "Ciao {{.Name}}!"}`)
+		writeBundle(t, dir, "fr", content)
+
+		if err := c.Reload(); err == nil {
+			t.Errorf("%s: no error returned for broken bundle", name)
+		}
+		if _, got := render(t, c, "fr"); got != "Salut Ana !" {
+			t.Errorf("%s: previous bundle not kept: got %q", name, got)
+		}
+		if _, got := render(t, c, "it"); got != "Hello Ana!" {
+			t.Errorf("%s: bundle partially activated: got %q", name, got)
+		}
+	}
+
+	writeBundle(t, dir, "fr", `{"greeting": "Coucou {{.Name}} !"}`)
+	if err := c.Reload(); err != nil {
+		t.Fatalf("error reloading fixed bundle: %v", err)
+	}
+	if _, got := render(t, c, "fr"); got != "Coucou Ana !" {
+		t.Errorf("reload not applied: got %q", got)
+	}
+}
+
+func TestNewCatalogInvalidBundle(t *testing.T) {
+	dir := t.TempDir()
+	writeBundle(t, dir, "fr", `{"greeting": "{{"}`)
+
+	if _, err := NewCatalog(dir); err == nil {
+		t.Error("no error returned for invalid bundle at startup")
+	}
+}
+
+func TestReloadRejectsInvalidTag(t *testing.T) {
+	dir := t.TempDir()
+	writeBundle(t, dir, "english", `{"greeting": "Howdy {{.Name}}!"}`)
+
+	if _, err := NewCatalog(dir); err == nil || !strings.Contains(err.Error(), "english") {
+		t.Errorf("expected an error naming the invalid tag, got %v", err)
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	got := parseAcceptLanguage("da, en-GB;q=0.8, en;q=0.7, *;q=0.1, xx;q=bad")
+	want := []string{"da", "en-gb", "en"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	c, err := NewCatalog("")
+	if err != nil {
+		t.Fatalf("error creating catalog: %v", err)
+	}
+
+	if err := c.SetDefault(KeyGreeting, "Welcome back, {{.Name}}!"); err != nil {
+		t.Fatalf("error setting default greeting: %v", err)
+	}
+	if _, got := render(t, c, ""); got != "Welcome back, Ana!" {
+		t.Errorf("default not replaced: got %q", got)
+	}
+	if _, got := render(t, c, "fr"); got != "Bonjour Ana !" {
+		t.Errorf("other languages changed: got %q", got)
+	}
+
+	for _, bad := range []string{"{{.Name", "{{.Nickname}}", "{{.Time.Nope}}"} {
+		if err := c.SetDefault(KeyGreeting, bad); err == nil {
+			t.Errorf("no error returned for template %q", bad)
+		}
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	c := Builtin()
+	if err := c.SetDefault(KeyGreeting, "<em>Hello</em> {{.Name}}!"); err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	tag, err := c.RenderHTML(&sb, "", KeyGreeting, GreetingData{Name: "<b>bob</b>"})
+	if err != nil {
+		t.Fatalf("error rendering greeting: %v", err)
+	}
+	if want := "<em>Hello</em> &lt;b&gt;bob&lt;/b&gt;!"; tag != "en" || sb.String() != want {
+		t.Errorf("expected en %q, got %s %q", want, tag, sb.String())
+	}
+
+	// The text template is unaffected by the HTML rendering.
+	if _, got := render(t, c, ""); got != "<em>Hello</em> Ana!" {
+		t.Errorf("text rendering changed: got %q", got)
+	}
+}
@@ -0,0 +1,201 @@
+// Package outbound builds the HTTP clients used for egress traffic such as
+// webhooks, enrichment lookups and email delivery. Every client shares the
+// same proxy, destination allowlist, timeouts and pool limits.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrDestinationNotAllowed = errors.New("destination not allowed")
+
+// Outcomes recorded in Metrics for each outbound attempt.
+const (
+	OutcomeOK     = "ok"
+	OutcomeDenied = "denied"
+	OutcomeError  = "error"
+)
+
+// Config describes outbound HTTP behaviour. Allow holds scheme+host patterns
+// such as "https://api.example.com", "https://*.example.com" or
+// "http://127.0.0.1:*"; a pattern without a port matches any port. An empty
+// Allow list denies every destination.
+type Config struct {
+	// ProxyURL routes all requests through an HTTP proxy. When empty the
+	// standard proxy environment variables are used.
+	ProxyURL            string
+	Allow               []string
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	MaxConnsPerHost     int
+	MaxIdleConnsPerHost int
+
+	// Dial replaces the default TCP dialer. DialTimeout still applies.
+	Dial    func(ctx context.Context, network string, addr string) (net.Conn, error)
+	Metrics *Metrics
+}
+
+type pattern struct {
+	scheme string
+	host   string
+	port   bool
+}
+
+func parsePattern(raw string) (pattern, error) {
+	scheme, host, ok := strings.Cut(raw, "://")
+	if !ok || scheme == "" || host == "" {
+		return pattern{}, fmt.Errorf("invalid allow pattern: %q", raw)
+	}
+	if _, err := path.Match(host, ""); err != nil {
+		return pattern{}, fmt.Errorf("invalid allow pattern: %q", raw)
+	}
+
+	_, _, err := net.SplitHostPort(host)
+	return pattern{scheme: strings.ToLower(scheme), host: strings.ToLower(host), port: err == nil}, nil
+}
+
+func (p pattern) match(u *url.URL) bool {
+	if p.scheme != strings.ToLower(u.Scheme) {
+		return false
+	}
+
+	host := u.Hostname()
+	if p.port {
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443"}[p.scheme]
+		}
+		host = net.JoinHostPort(host, port)
+	}
+
+	ok, _ := path.Match(p.host, strings.ToLower(host))
+	return ok
+}
+
+// NewClient returns an http.Client configured from cfg. Requests to
+// destinations outside the allowlist fail with ErrDestinationNotAllowed
+// before any connection is made.
+func NewClient(cfg Config) (*http.Client, error) {
+	allow := make([]pattern, 0, len(cfg.Allow))
+	for _, raw := range cfg.Allow {
+		p, err := parsePattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		allow = append(allow, p)
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url: %q", cfg.ProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dial := cfg.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			if cfg.DialTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, cfg.DialTimeout)
+				defer cancel()
+			}
+			return dial(ctx, network, addr)
+		},
+		TLSHandshakeTimeout: cfg.TLSHandshakeTimeout,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		ForceAttemptHTTP2:   true,
+	}
+
+	return &http.Client{
+		Transport: &guard{next: transport, allow: allow, metrics: cfg.Metrics},
+	}, nil
+}
+
+// guard enforces the allowlist and records metrics around the transport.
+type guard struct {
+	next    http.RoundTripper
+	allow   []pattern
+	metrics *Metrics
+}
+
+func (g *guard) RoundTrip(req *http.Request) (*http.Response, error) {
+	dest := strings.ToLower(req.URL.Scheme) + "://" + strings.ToLower(req.URL.Host)
+
+	allowed := false
+	for _, p := range g.allow {
+		if p.match(req.URL) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		g.metrics.record(dest, OutcomeDenied)
+		return nil, fmt.Errorf("%w: %s", ErrDestinationNotAllowed, dest)
+	}
+
+	resp, err := g.next.RoundTrip(req)
+	if err != nil {
+		g.metrics.record(dest, OutcomeError)
+		return nil, err
+	}
+	g.metrics.record(dest, OutcomeOK)
+	return resp, nil
+}
+
+// Attempt identifies a metrics series by destination and outcome.
+type Attempt struct {
+	Destination string
+	Outcome     string
+}
+
+// Metrics counts outbound attempts. A nil *Metrics records nothing.
+type Metrics struct {
+	mu     sync.Mutex
+	counts map[Attempt]int
+}
+
+func (m *Metrics) record(dest string, outcome string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counts == nil {
+		m.counts = make(map[Attempt]int)
+	}
+	m.counts[Attempt{Destination: dest, Outcome: outcome}]++
+}
+
+// Snapshot returns a copy of the current counts.
+func (m *Metrics) Snapshot() map[Attempt]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[Attempt]int, len(m.counts))
+	for k, v := range m.counts {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,179 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientRoutesThroughProxy(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHost = r.URL.Host
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	metrics := &Metrics{}
+	client, err := NewClient(Config{
+		ProxyURL: proxy.URL,
+		Allow:    []string{"http://*.internal"},
+		Metrics:  metrics,
+	})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	resp, err := client.Get("http://hooks.internal/deliver")
+	if err != nil {
+		t.Fatalf("error sending request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "via proxy" || proxiedHost != "hooks.internal" {
+		t.Errorf("request not routed through proxy: host %q, body %q", proxiedHost, body)
+	}
+
+	got := metrics.Snapshot()[Attempt{Destination: "http://hooks.internal", Outcome: OutcomeOK}]
+	if got != 1 {
+		t.Errorf("bad ok count: expected 1, got %d", got)
+	}
+}
+
+func TestClientDeniesDestination(t *testing.T) {
+	dialed := false
+	metrics := &Metrics{}
+	client, err := NewClient(Config{
+		Allow: []string{"https://api.example.com", "http://127.0.0.1:*"},
+		Dial: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			dialed = true
+			return nil, errors.New("unexpected dial")
+		},
+		Metrics: metrics,
+	})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	for _, target := range []string{
+		"http://api.example.com/",
+		"https://evil.example.com/",
+		"https://api.example.com.evil.net/",
+	} {
+		_, err = client.Get(target)
+		if !errors.Is(err, ErrDestinationNotAllowed) {
+			t.Errorf("error mismatch for %s: expected %v, got %v", target, ErrDestinationNotAllowed, err)
+		}
+	}
+	if dialed {
+		t.Errorf("denied destination was dialed")
+	}
+
+	got := metrics.Snapshot()[Attempt{Destination: "https://evil.example.com", Outcome: OutcomeDenied}]
+	if got != 1 {
+		t.Errorf("bad denied count: expected 1, got %d", got)
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		target  string
+		want    bool
+	}{
+		{"https://api.example.com", "https://api.example.com/x", true},
+		{"https://api.example.com", "https://API.example.com:8443/x", true},
+		{"https://*.example.com", "https://a.example.com/", true},
+		{"https://*.example.com", "https://example.com/", false},
+		{"https://api.example.com:443", "https://api.example.com/", true},
+		{"https://api.example.com:443", "https://api.example.com:8443/", false},
+		{"http://127.0.0.1:*", "http://127.0.0.1:9000/", true},
+	}
+
+	for _, tt := range tests {
+		p, err := parsePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("error parsing %q: %v", tt.pattern, err)
+		}
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if got := p.match(req.URL); got != tt.want {
+			t.Errorf("%q matching %q: expected %v, got %v", tt.pattern, tt.target, tt.want, got)
+		}
+	}
+
+	for _, bad := range []string{"example.com", "https://", "https://[x"} {
+		if _, err := NewClient(Config{Allow: []string{bad}}); err == nil {
+			t.Errorf("no error returned for pattern %q", bad)
+		}
+	}
+}
+
+func TestClientDialTimeout(t *testing.T) {
+	metrics := &Metrics{}
+	client, err := NewClient(Config{
+		Allow:       []string{"http://10.255.255.1"},
+		DialTimeout: 50 * time.Millisecond,
+		// Stand-in for a black-holed address: the dial never completes on
+		// its own.
+		Dial: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		Metrics: metrics,
+	})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	start := time.Now()
+	_, err = client.Get("http://10.255.255.1/")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error mismatch: expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("dial timeout not applied: took %v", elapsed)
+	}
+
+	got := metrics.Snapshot()[Attempt{Destination: "http://10.255.255.1", Outcome: OutcomeError}]
+	if got != 1 {
+		t.Errorf("bad error count: expected 1, got %d", got)
+	}
+}
+
+func TestClientTLSHandshakeTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client, err := NewClient(Config{
+		Allow:               []string{"https://127.0.0.1:*"},
+		TLSHandshakeTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	_, err = client.Get("https://" + ln.Addr().String() + "/")
+	if err == nil || !strings.Contains(err.Error(), "TLS handshake timeout") {
+		t.Errorf("expected TLS handshake timeout, got %v", err)
+	}
+}
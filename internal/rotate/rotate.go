@@ -0,0 +1,194 @@
+// Package rotate is an io.Writer over a log file that rotates by size. When
+// a write would take the file past MaxSize, the file is renamed to
+// <path>.1, older backups shift up to <path>.2 and so on, and a fresh file
+// is started. Backups beyond MaxBackups are removed, and with Compress they
+// are gzipped as <path>.N.gz.
+//
+// Reopen closes and reopens the file at its path without rotating, for use
+// after an external tool such as logrotate has moved it away.
+package rotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Options configures a Writer. A zero MaxSize disables rotation; a zero
+// MaxBackups keeps no backups, so rotation just truncates.
+type Options struct {
+	MaxSize    int64
+	MaxBackups int
+	Compress   bool
+}
+
+// Writer appends to a file, rotating it by size. It is safe for concurrent
+// use; each Write is appended whole to a single file.
+type Writer struct {
+	path string
+	opts Options
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open opens path for appending, creating it if needed.
+func Open(path string, opts Options) (*Writer, error) {
+	w := &Writer{path: path, opts: opts}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past MaxSize. A
+// single write larger than MaxSize still goes into one file.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+	if w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate starts a new file now, regardless of size.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+// Reopen closes the file and opens path again, picking up a file moved or
+// recreated by another process.
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.f = nil
+	return w.open()
+}
+
+// Close closes the file. Writes after Close fail with os.ErrClosed.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// backup returns the name of the nth backup.
+func (w *Writer) backup(n int) string {
+	name := w.path + "." + strconv.Itoa(n)
+	if w.opts.Compress {
+		name += ".gz"
+	}
+	return name
+}
+
+// rotate shifts the backups up by one, moves the current file to backup 1
+// and opens a new one. The caller holds w.mu.
+func (w *Writer) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.f = nil
+
+	if w.opts.MaxBackups <= 0 {
+		if err := os.Remove(w.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return w.open()
+	}
+
+	if err := os.Remove(w.backup(w.opts.MaxBackups)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for n := w.opts.MaxBackups - 1; n >= 1; n-- {
+		if err := os.Rename(w.backup(n), w.backup(n+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if w.opts.Compress {
+		if err := compress(w.path, w.backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Rename(w.path, w.backup(1)); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// compress gzips src into dst and removes src.
+func compress(src string, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		return fmt.Errorf("compressing %s: %w", src, err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compressing %s: %w", src, err)
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,170 @@
+package rotate
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotateAtMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	w, err := Open(path, Options{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffff\n", "gggg\n"} {
+		if _, err := io.WriteString(w, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Two lines fit in 10 bytes; the third starts a new file.
+	want := map[string]string{
+		path:        "gggg\n",
+		path + ".1": "eeee\nffff\n",
+		path + ".2": "cccc\ndddd\n",
+	}
+	for name, content := range want {
+		if got := readFile(t, name); got != content {
+			t.Errorf("%s: expected %q, got %q", filepath.Base(name), content, got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, found %s.3", path)
+	}
+}
+
+func TestRotateAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	os.WriteFile(path, []byte("12345678\n"), 0o644)
+
+	w, err := Open(path, Options{MaxSize: 10, MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	io.WriteString(w, "next\n")
+
+	if got := readFile(t, path+".1"); got != "12345678\n" {
+		t.Errorf("existing content not counted towards MaxSize: backup holds %q", got)
+	}
+	if got := readFile(t, path); got != "next\n" {
+		t.Errorf("bad current file: %q", got)
+	}
+}
+
+func TestRotateCompress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	w, err := Open(path, Options{MaxSize: 5, MaxBackups: 1, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	io.WriteString(w, "old\n")
+	io.WriteString(w, "new\n")
+
+	f, err := os.Open(path + ".1.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if string(data) != "old\n" {
+		t.Errorf("bad compressed backup: %q", data)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Error("uncompressed backup left behind")
+	}
+}
+
+func TestRotateConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	w, err := Open(path, Options{MaxSize: 256, MaxBackups: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := strings.Repeat("x", 20) + "\n"
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 50 {
+				io.WriteString(w, line)
+			}
+		})
+	}
+	wg.Wait()
+	w.Close()
+
+	// Every line must land whole in exactly one file.
+	files, _ := filepath.Glob(path + "*")
+	var lines int
+	for _, name := range files {
+		f, _ := os.Open(name)
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if s.Text()+"\n" != line {
+				t.Fatalf("%s: torn line %q", filepath.Base(name), s.Text())
+			}
+			lines++
+		}
+		f.Close()
+	}
+	if lines != 400 {
+		t.Errorf("expected 400 lines across %d files, got %d", len(files), lines)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	w, err := Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	io.WriteString(w, "before\n")
+
+	// logrotate moves the file away, then signals the process.
+	if err := os.Rename(path, path+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "still old\n")
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "after\n")
+
+	if got := readFile(t, path+".moved"); got != "before\nstill old\n" {
+		t.Errorf("bad moved file: %q", got)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("bad reopened file: %q", got)
+	}
+
+	w.Close()
+	if _, err := io.WriteString(w, "closed\n"); err == nil {
+		t.Error("expected an error writing after Close")
+	}
+}
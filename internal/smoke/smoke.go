@@ -0,0 +1,212 @@
+// Package smoke runs a fixed sequence of real API calls against a deployed
+// server and reports the outcome of each step. It backs cmd/smoketest.
+package smoke
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Step is the outcome of a single call.
+type Step struct {
+	Name      string  `json:"name"`
+	OK        bool    `json:"ok"`
+	Status    int     `json:"status,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the JSON document printed by cmd/smoketest.
+type Report struct {
+	Server string `json:"server"`
+	Passed bool   `json:"passed"`
+	Steps  []Step `json:"steps"`
+}
+
+// Config describes where and how to run the sequence. Token, when set, is
+// sent as a bearer token on every request. Username and Password, when set,
+// are sent as Basic auth instead; deleting users requires an admin.
+type Config struct {
+	Server   string
+	Token    string
+	Username string
+	Password string
+	Client   *http.Client
+}
+
+type runner struct {
+	cfg    Config
+	report *Report
+}
+
+// check decides whether a response passed. It gets the status and body.
+type check func(status int, body []byte) error
+
+func expectStatus(want int) check {
+	return func(status int, body []byte) error {
+		if status != want {
+			return fmt.Errorf("expected status %d, got %d: %s", want, status, strings.TrimSpace(string(body)))
+		}
+		return nil
+	}
+}
+
+func expectBody(want string) check {
+	return func(status int, body []byte) error {
+		if err := expectStatus(http.StatusOK)(status, body); err != nil {
+			return err
+		}
+		if string(body) != want {
+			return fmt.Errorf("expected body %q, got %q", want, body)
+		}
+		return nil
+	}
+}
+
+func expectErrorCode(wantStatus int, wantCode string) check {
+	return func(status int, body []byte) error {
+		if err := expectStatus(wantStatus)(status, body); err != nil {
+			return err
+		}
+
+		var envelope struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return fmt.Errorf("error envelope is not JSON: %w", err)
+		}
+		if envelope.Error.Code != wantCode {
+			return fmt.Errorf("expected error code %q, got %q", wantCode, envelope.Error.Code)
+		}
+		return nil
+	}
+}
+
+// step performs one request and records it in the report.
+func (rn *runner) step(ctx context.Context, name string, method string, path string, header http.Header, body string, ok check) bool {
+	result := Step{Name: name}
+	start := time.Now()
+
+	status, respBody, err := rn.do(ctx, method, path, header, body)
+	result.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	result.Status = status
+	if err == nil {
+		err = ok(status, respBody)
+	}
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.OK = true
+	}
+
+	rn.report.Steps = append(rn.report.Steps, result)
+	return result.OK
+}
+
+func (rn *runner) do(ctx context.Context, method string, path string, header http.Header, body string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(rn.cfg.Server, "/")+path, strings.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	switch {
+	case rn.cfg.Username != "":
+		req.SetBasicAuth(rn.cfg.Username, rn.cfg.Password)
+	case rn.cfg.Token != "":
+		req.Header.Set("Authorization", "Bearer "+rn.cfg.Token)
+	}
+
+	resp, err := rn.cfg.Client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+func uniqueSuffix() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// cleanupTimeout bounds the test user cleanup, which runs even after the
+// overall deadline has passed.
+const cleanupTimeout = 5 * time.Second
+
+// Run executes the smoke sequence. Steps stop early once ctx is done, but
+// the test user is always deleted if it was created.
+func Run(ctx context.Context, cfg Config) *Report {
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	rn := &runner{cfg: cfg, report: &Report{Server: cfg.Server, Steps: []Step{}}}
+
+	rn.sequence(ctx)
+	return rn.finish()
+}
+
+func (rn *runner) sequence(ctx context.Context) {
+	suffix := uniqueSuffix()
+	email := "smoke-" + suffix + "@example.com"
+	userPath := "/users/" + url.PathEscape(email)
+	created := false
+	deleted := false
+
+	defer func() {
+		if !created || deleted {
+			return
+		}
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+		defer cancel()
+		rn.step(cleanupCtx, "cleanup", http.MethodDelete, userPath, nil, "", expectStatus(http.StatusNoContent))
+	}()
+
+	rn.step(ctx, "health", http.MethodGet, "/health", nil, "", expectStatus(http.StatusOK))
+	rn.step(ctx, "version", http.MethodGet, "/version", nil, "", expectStatus(http.StatusOK))
+	rn.step(ctx, "hello-query", http.MethodGet, "/hello/?user=smoke", nil, "", expectBody("Hello smoke!\n"))
+	rn.step(ctx, "hello-path", http.MethodGet, "/responses/smoke/hello/", nil, "", expectBody("Hello smoke!\n"))
+	rn.step(ctx, "hello-header", http.MethodGet, "/user/hello", http.Header{"User": {"smoke"}}, "", expectBody("Hello smoke!\n"))
+	rn.step(ctx, "json-greeting", http.MethodPost, "/json", nil, `{"first_name":"smoke"}`, expectStatus(http.StatusOK))
+
+	payload, _ := json.Marshal(map[string]string{
+		"first_name": "smoke",
+		"last_name":  "test-" + suffix,
+		"email":      email,
+	})
+	created = rn.step(ctx, "users-create", http.MethodPost, "/users", nil, string(payload), expectStatus(http.StatusCreated))
+	if created {
+		rn.step(ctx, "users-get", http.MethodGet, userPath, nil, "", expectStatus(http.StatusOK))
+		deleted = rn.step(ctx, "users-delete", http.MethodDelete, userPath, nil, "", expectStatus(http.StatusNoContent))
+	}
+
+	rn.step(ctx, "invalid-payload", http.MethodPost, "/json", nil, "{", expectErrorCode(http.StatusBadRequest, "invalid_request"))
+}
+
+func (rn *runner) finish() *Report {
+	rn.report.Passed = true
+	for _, s := range rn.report.Steps {
+		if !s.OK {
+			rn.report.Passed = false
+		}
+	}
+	return rn.report
+}
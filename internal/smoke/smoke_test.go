@@ -0,0 +1,129 @@
+package smoke
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubServer answers every smoke step correctly except that the first
+// DELETE fails, and records the users it holds.
+type stubServer struct {
+	mu        sync.Mutex
+	users     map[string]bool
+	deletes   int
+	failFirst bool
+}
+
+func (s *stubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/health" || r.URL.Path == "/version":
+		w.Write([]byte(`{}`))
+	case r.URL.Path == "/hello/" || strings.HasPrefix(r.URL.Path, "/responses/") || r.URL.Path == "/user/hello":
+		w.Write([]byte("Hello smoke!\n"))
+	case r.URL.Path == "/json":
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "{" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"invalid_request","message":"error parsing request body"}}`))
+			return
+		}
+		w.Write([]byte("Hello smoke!\n"))
+	case r.URL.Path == "/users" && r.Method == http.MethodPost:
+		s.users["created"] = true
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(r.URL.Path, "/users/") && r.Method == http.MethodGet:
+		w.Write([]byte(`{}`))
+	case strings.HasPrefix(r.URL.Path, "/users/") && r.Method == http.MethodDelete:
+		s.deletes++
+		if s.failFirst && s.deletes == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		delete(s.users, "created")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRunAllPass(t *testing.T) {
+	stub := &stubServer{users: map[string]bool{}}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	report := Run(context.Background(), Config{Server: srv.URL, Client: srv.Client()})
+	if !report.Passed {
+		t.Fatalf("expected report to pass: %+v", report.Steps)
+	}
+	if len(report.Steps) != 10 {
+		t.Errorf("bad step count: expected 10, got %d", len(report.Steps))
+	}
+	for _, step := range report.Steps {
+		if step.Name == "cleanup" {
+			t.Errorf("cleanup ran although delete succeeded")
+		}
+	}
+}
+
+func TestRunFailedStepStillCleansUp(t *testing.T) {
+	stub := &stubServer{users: map[string]bool{}, failFirst: true}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	report := Run(context.Background(), Config{Server: srv.URL, Client: srv.Client()})
+	if report.Passed {
+		t.Fatal("expected report to fail")
+	}
+
+	failed := map[string]Step{}
+	for _, step := range report.Steps {
+		if !step.OK {
+			failed[step.Name] = step
+		}
+	}
+	if len(failed) != 1 {
+		t.Errorf("expected only users-delete to fail, got %v", failed)
+	}
+	if step, ok := failed["users-delete"]; !ok || step.Status != http.StatusInternalServerError || step.Error == "" {
+		t.Errorf("users-delete failure not reported: %+v", step)
+	}
+
+	last := report.Steps[len(report.Steps)-1]
+	if last.Name != "cleanup" || !last.OK {
+		t.Errorf("cleanup did not run last and succeed: %+v", last)
+	}
+	if len(stub.users) != 0 {
+		t.Errorf("test user left behind")
+	}
+}
+
+func TestRunDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	report := Run(ctx, Config{Server: srv.URL, Client: srv.Client()})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("deadline not respected: took %v", elapsed)
+	}
+	if report.Passed {
+		t.Error("expected report to fail after deadline")
+	}
+}
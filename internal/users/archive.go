@@ -0,0 +1,295 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"time"
+)
+
+// ArchiveFormatVersion is the format_version ExportArchive writes. Version 1
+// archives held only names, email addresses and timestamps; version 2 added
+// email_name, role, verified and tags. ImportArchive reads every version,
+// defaulting the fields an older one lacks and ignoring fields a newer one
+// adds.
+const ArchiveFormatVersion = 2
+
+// ErrInvalidArchive is returned by ImportArchive for input that is not an
+// archive or holds a user no Manager could.
+var ErrInvalidArchive = errors.New("invalid archive")
+
+// MergeMode says what ImportArchive does with an archived user whose name
+// or email address is already taken.
+type MergeMode string
+
+const (
+	// MergeSkip keeps the existing user and drops the archived one.
+	MergeSkip MergeMode = "skip"
+	// MergeOverwrite replaces the existing user with the archived one.
+	MergeOverwrite MergeMode = "overwrite"
+	// MergeFail rejects the whole import.
+	MergeFail MergeMode = "fail"
+)
+
+// ParseMergeMode validates mode. An empty string selects MergeFail.
+func ParseMergeMode(mode string) (MergeMode, error) {
+	switch MergeMode(mode) {
+	case "":
+		return MergeFail, nil
+	case MergeSkip, MergeOverwrite, MergeFail:
+		return MergeMode(mode), nil
+	}
+	return "", fmt.Errorf("invalid merge mode %q: must be skip, overwrite or fail", mode)
+}
+
+// ImportResult counts what ImportArchive did with each archived user.
+type ImportResult struct {
+	Added       int
+	Overwritten int
+	Skipped     int
+}
+
+// archive is the JSON envelope written by ExportArchive.
+type archive struct {
+	FormatVersion int           `json:"format_version"`
+	ExportedAt    time.Time     `json:"exported_at"`
+	Users         []archiveUser `json:"users"`
+}
+
+// archiveUser is the JSON shape of one archived user. Like the other
+// exports it leaves out the password hash, and it leaves out soft-deleted
+// users and greeting history, which belong to the environment they were
+// recorded in.
+type archiveUser struct {
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Added in version 2.
+	EmailName string   `json:"email_name,omitempty"`
+	Role      Role     `json:"role,omitempty"`
+	Verified  bool     `json:"verified,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// ExportArchive writes every live user to w as a versioned JSON archive for
+// ImportArchive, in insertion order. Users are copied under the read lock
+// and encoded after it is released.
+func (m *Manager) ExportArchive(w io.Writer) error {
+	m.mu.RLock()
+	a := archive{
+		FormatVersion: ArchiveFormatVersion,
+		ExportedAt:    m.now().UTC(),
+		Users:         make([]archiveUser, len(m.users)),
+	}
+	for i, u := range m.users {
+		a.Users[i] = archiveUser{
+			FirstName: u.FirstName,
+			LastName:  u.LastName,
+			Email:     u.Email.Address,
+			CreatedAt: u.CreatedAt,
+			UpdatedAt: u.UpdatedAt,
+			EmailName: u.Email.Name,
+			Role:      u.Role,
+			Verified:  u.Verified,
+			Tags:      u.Tags,
+		}
+	}
+	m.mu.RUnlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// archivedUser converts au back, checking it as AddUser would.
+func (m *Manager) archivedUser(au archiveUser) (User, error) {
+	if err := m.nameRules.Validate(au.FirstName); err != nil {
+		return User{}, fmt.Errorf("first name: %w", err)
+	}
+	if err := m.nameRules.Validate(au.LastName); err != nil {
+		return User{}, fmt.Errorf("last name: %w", err)
+	}
+	address, err := mail.ParseAddress(au.Email)
+	if err != nil {
+		return User{}, fmt.Errorf("invalid email %q", au.Email)
+	}
+	address.Name = au.EmailName
+	role, err := ParseRole(string(au.Role))
+	if err != nil {
+		return User{}, err
+	}
+	tags, err := normalizeTags(au.Tags)
+	if err != nil {
+		return User{}, err
+	}
+	return User{
+		FirstName: NormalizeName(au.FirstName),
+		LastName:  NormalizeName(au.LastName),
+		Email:     *address,
+		CreatedAt: au.CreatedAt,
+		UpdatedAt: au.UpdatedAt,
+		Role:      role,
+		Version:   1,
+		Verified:  au.Verified,
+		Tags:      tags,
+	}, nil
+}
+
+// importSkip and importAdd are the targets of archived users that are
+// skipped or added rather than overwriting the user at a position.
+const (
+	importSkip = -2
+	importAdd  = -1
+)
+
+// ImportArchive adds the users of an archive written by ExportArchive,
+// giving them new IDs. An archived user whose name or email address is
+// taken is handled as mode says; under MergeOverwrite it replaces the
+// existing user, keeping that user's ID, password and greeting history and
+// bumping its Version. An archived user that clashes with two different
+// users cannot replace both and fails the import under every mode but
+// MergeSkip.
+//
+// The import is all or nothing: a malformed archive is rejected with
+// ErrInvalidArchive, and a clash that fails it with a *BatchError naming
+// the archived user and wrapping ErrDuplicateUser or ErrDuplicateEmail,
+// and in both cases the manager is left unchanged. Every change is audited
+// under the ActorFrom(ctx) actor.
+func (m *Manager) ImportArchive(ctx context.Context, r io.Reader, mode MergeMode) (ImportResult, error) {
+	if err := ctx.Err(); err != nil {
+		return ImportResult{}, err
+	}
+	if _, err := ParseMergeMode(string(mode)); err != nil {
+		return ImportResult{}, err
+	}
+
+	var a archive
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return ImportResult{}, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if a.FormatVersion < 1 {
+		return ImportResult{}, fmt.Errorf("%w: missing format_version", ErrInvalidArchive)
+	}
+	imported := make([]User, len(a.Users))
+	for i, au := range a.Users {
+		u, err := m.archivedUser(au)
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("%w: user %d: %v", ErrInvalidArchive, i, err)
+		}
+		imported[i] = u
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Plan every step against the users as they are before changing any,
+	// so a failure leaves nothing half imported.
+	targets := make([]int, len(imported))
+	names := make(map[fullName]bool, len(imported))
+	emails := make(map[string]bool, len(imported))
+	claimed := make(map[int]bool)
+	var result ImportResult
+	for i, u := range imported {
+		nameKey, emailKey := m.nameKey(u.FirstName, u.LastName), m.emailKey(u.Email.Address)
+		if names[nameKey] && !m.allowsDuplicateNames() {
+			return ImportResult{}, fmt.Errorf("%w: user %d: duplicate user %s %s", ErrInvalidArchive, i, u.FirstName, u.LastName)
+		}
+		if emails[emailKey] {
+			return ImportResult{}, fmt.Errorf("%w: user %d: duplicate email %s", ErrInvalidArchive, i, u.Email.Address)
+		}
+		names[nameKey], emails[emailKey] = true, true
+
+		byName, nameTaken := 0, false
+		if !m.allowsDuplicateNames() {
+			var n int
+			byName, n = m.nameIndex(u.FirstName, u.LastName)
+			nameTaken = n > 0
+		}
+		byEmail, emailTaken := m.byEmail[emailKey]
+
+		var conflict error
+		switch {
+		case !nameTaken && !emailTaken:
+			targets[i] = importAdd
+			result.Added++
+			continue
+		case nameTaken && emailTaken && byName != byEmail:
+			conflict = ErrDuplicateEmail
+		case nameTaken:
+			conflict = ErrDuplicateUser
+		default:
+			conflict = ErrDuplicateEmail
+		}
+
+		target := byEmail
+		if !emailTaken {
+			target = byName
+		}
+		switch {
+		case mode == MergeSkip:
+			targets[i] = importSkip
+			result.Skipped++
+		case mode == MergeOverwrite && (!nameTaken || !emailTaken || byName == byEmail) && !claimed[target]:
+			targets[i] = target
+			claimed[target] = true
+			result.Overwritten++
+		default:
+			return ImportResult{}, &BatchError{Index: i, Err: conflict}
+		}
+	}
+	planned := make(map[string]bool, result.Added)
+	for i := range imported {
+		if targets[i] != importAdd {
+			continue
+		}
+		id, err := m.newID(func(id string) bool { return planned[id] || m.idTaken(id) })
+		if err != nil {
+			return ImportResult{}, err
+		}
+		imported[i].ID = id
+		planned[id] = true
+	}
+
+	actor := ActorFrom(ctx)
+	now := m.now()
+	for i, u := range imported {
+		switch target := targets[i]; target {
+		case importSkip:
+		case importAdd:
+			m.nextSeq++
+			m.users = append(m.users, u)
+			m.seqs = append(m.seqs, m.nextSeq)
+			m.index(len(m.users) - 1)
+			if !u.Verified {
+				m.issueToken(len(m.users) - 1)
+			}
+			m.metrics.UserAdded()
+			m.record(actor, AuditAdd, nil, &u)
+		default:
+			before := m.users[target]
+			u.ID = before.ID
+			u.UpdatedAt = now
+			u.Version = before.Version + 1
+			u.GreetCount, u.LastGreetedAt = before.GreetCount, before.LastGreetedAt
+			u.passwordHash = before.passwordHash
+			m.unindex(target)
+			m.users[target] = u
+			m.index(target)
+			if u.Verified {
+				m.dropToken(m.seqs[target])
+			}
+			m.record(actor, AuditUpdate, &before, &m.users[target])
+		}
+	}
+	if result.Added > 0 || result.Overwritten > 0 {
+		m.rev++
+		m.metrics.UsersTotal(len(m.users))
+	}
+	return result, nil
+}
@@ -0,0 +1,227 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	src := NewManager(WithClock(func() time.Time { return clock }))
+	ctx := context.Background()
+	if err := src.AddUser(ctx, "Ada", "Lovelace", "Ada Lovelace <ada@bar.com>"); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(time.Hour)
+	if err := src.AddUserWithRole("Grace", "Hopper", "grace@bar.com", string(RoleAdmin)); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AddTag("Grace", "Hopper", "navy"); err != nil {
+		t.Fatal(err)
+	}
+	verifyAll(t, src)
+
+	var buf bytes.Buffer
+	if err := src.ExportArchive(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"format_version": 2`) {
+		t.Errorf("archive does not carry its version:\n%s", buf.String())
+	}
+
+	dst := NewManager()
+	result, err := dst.ImportArchive(ctx, &buf, MergeFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (ImportResult{Added: 2}) {
+		t.Errorf("bad result: %+v", result)
+	}
+
+	want, got := src.GetAllUsers(), dst.GetAllUsers()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d users, got %d", len(want), len(got))
+	}
+	for i := range want {
+		w, g := want[i], got[i]
+		if g.FirstName != w.FirstName || g.LastName != w.LastName || g.Email != w.Email || g.Role != w.Role ||
+			g.Verified != w.Verified || !slices.Equal(g.Tags, w.Tags) ||
+			!g.CreatedAt.Equal(w.CreatedAt) || !g.UpdatedAt.Equal(w.UpdatedAt) {
+			t.Errorf("user %d: expected %+v, got %+v", i, w, g)
+		}
+	}
+}
+
+// verifyAll verifies every user of m with its pending token.
+func verifyAll(t *testing.T, m *Manager) {
+	t.Helper()
+	m.mu.RLock()
+	tokens := make([]string, 0, len(m.tokens))
+	for token := range m.tokens {
+		tokens = append(tokens, token)
+	}
+	m.mu.RUnlock()
+	for _, token := range tokens {
+		if err := m.VerifyUser(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImportArchiveVersion1(t *testing.T) {
+	// Version 1 archives predate email names, roles, verification and
+	// tags; a field added by a later version is ignored.
+	const v1 = `{
+		"format_version": 1,
+		"exported_at": "2025-01-01T00:00:00Z",
+		"users": [
+			{"first_name": "Ada", "last_name": "Lovelace", "email": "ada@bar.com",
+			 "created_at": "2024-06-01T00:00:00Z", "updated_at": "2024-07-01T00:00:00Z",
+			 "nickname": "countess"}
+		]
+	}`
+
+	m := NewManager()
+	if _, err := m.ImportArchive(context.Background(), strings.NewReader(v1), MergeFail); err != nil {
+		t.Fatal(err)
+	}
+	u, err := m.GetUserByEmail(context.Background(), "ada@bar.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Role != RoleMember || u.Verified || u.Tags != nil || u.Version != 1 {
+		t.Errorf("expected version 2 defaults, got %+v", u)
+	}
+	if !u.CreatedAt.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("CreatedAt not kept: %v", u.CreatedAt)
+	}
+
+	for _, bad := range []string{
+		`not json`,
+		`{"users": []}`,
+		`{"format_version": 1, "users": [{"first_name": " ", "last_name": "x", "email": "x@bar.com"}]}`,
+		`{"format_version": 1, "users": [{"first_name": "a", "last_name": "x", "email": "a@bar.com"}, {"first_name": "b", "last_name": "x", "email": "a@bar.com"}]}`,
+	} {
+		if _, err := NewManager().ImportArchive(context.Background(), strings.NewReader(bad), MergeFail); !errors.Is(err, ErrInvalidArchive) {
+			t.Errorf("%s: expected ErrInvalidArchive, got %v", bad, err)
+		}
+	}
+}
+
+func TestImportArchiveMergeModes(t *testing.T) {
+	ctx := context.Background()
+	archived := NewManager()
+	for _, u := range [][3]string{
+		{"Ada", "Lovelace", "ada@new.com"},  // name taken
+		{"Alan", "Turing", "grace@bar.com"}, // email taken
+		{"Edsger", "Dijkstra", "edsger@bar.com"},
+	} {
+		if err := archived.AddUser(ctx, u[0], u[1], u[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var data bytes.Buffer
+	if err := archived.ExportArchive(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	existing := func(t *testing.T) *Manager {
+		m := NewManager()
+		if err := m.AddUser(ctx, "Ada", "Lovelace", "ada@bar.com"); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.AddUser(ctx, "Grace", "Hopper", "grace@bar.com"); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	emails := func(m *Manager) []string {
+		var out []string
+		for _, u := range m.GetAllUsers() {
+			out = append(out, u.FirstName+" "+u.Email.Address)
+		}
+		return out
+	}
+
+	tests := []struct {
+		mode   MergeMode
+		result ImportResult
+		err    error
+		want   []string
+	}{
+		{MergeSkip, ImportResult{Added: 1, Skipped: 2}, nil,
+			[]string{"Ada ada@bar.com", "Grace grace@bar.com", "Edsger edsger@bar.com"}},
+		{MergeOverwrite, ImportResult{Added: 1, Overwritten: 2}, nil,
+			[]string{"Ada ada@new.com", "Alan grace@bar.com", "Edsger edsger@bar.com"}},
+		{MergeFail, ImportResult{}, ErrDuplicateUser,
+			[]string{"Ada ada@bar.com", "Grace grace@bar.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			m := existing(t)
+			grace, _ := m.GetUserByEmail(ctx, "grace@bar.com")
+			result, err := m.ImportArchive(ctx, bytes.NewReader(data.Bytes()), tt.mode)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if result != tt.result {
+				t.Errorf("bad result: expected %+v, got %+v", tt.result, result)
+			}
+			if got := emails(m); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+			if tt.mode == MergeOverwrite {
+				u, err := m.GetUserByEmail(ctx, "grace@bar.com")
+				if err != nil || u.Version != 2 || u.ID != grace.ID {
+					t.Errorf("overwritten user should be at version 2 with its id, got %+v, %v", u, err)
+				}
+			}
+		})
+	}
+
+	// A user whose name and email belong to two different users cannot
+	// replace both.
+	m := existing(t)
+	clash := `{"format_version": 2, "users": [{"first_name": "Ada", "last_name": "Lovelace", "email": "grace@bar.com"}]}`
+	_, err := m.ImportArchive(ctx, strings.NewReader(clash), MergeOverwrite)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 0 || !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("expected a BatchError for entry 0, got %v", err)
+	}
+}
+
+func TestImportArchiveAudit(t *testing.T) {
+	log := NewAuditLog(10)
+	m := NewManager(WithAudit(log))
+	if err := m.AddUser(context.Background(), "Ada", "Lovelace", "ada@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	archive := `{"format_version": 2, "users": [
+		{"first_name": "Ada", "last_name": "Lovelace", "email": "ada@new.com"},
+		{"first_name": "Alan", "last_name": "Turing", "email": "alan@bar.com"}
+	]}`
+
+	ctx := WithActor(context.Background(), "admin@bar.com")
+	if _, err := m.ImportArchive(ctx, strings.NewReader(archive), MergeOverwrite); err != nil {
+		t.Fatal(err)
+	}
+
+	var ops []string
+	for _, e := range log.Last(10) {
+		ops = append(ops, e.Op+" by "+e.Actor)
+	}
+	if want := []string{"add by ", "update by admin@bar.com", "add by admin@bar.com"}; !slices.Equal(ops, want) {
+		t.Errorf("expected %q, got %q", want, ops)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := m.ImportArchive(cancelled, strings.NewReader(archive), MergeSkip); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Operations recorded in AuditEntry.Op.
+const (
+	AuditAdd    = "add"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// DefaultAuditCapacity is the number of entries kept by the AuditLog a
+// Manager records to unless WithAudit replaces it.
+const DefaultAuditCapacity = 1000
+
+// AuditEntry records one change to a user. Before is nil for adds and After
+// is nil for deletes. Actor is whoever the caller's context named with
+// WithActor, and empty for changes made without one.
+type AuditEntry struct {
+	Time   time.Time  `json:"time"`
+	Op     string     `json:"op"`
+	Actor  string     `json:"actor,omitempty"`
+	Before *AuditUser `json:"before,omitempty"`
+	After  *AuditUser `json:"after,omitempty"`
+}
+
+// AuditUser is the state of a user as recorded in an AuditEntry. It lists
+// the fields it keeps instead of embedding User, so the password hash, and
+// any secret added to User later, stays out of the audit log unless it is
+// added here on purpose.
+type AuditUser struct {
+	ID        string     `json:"id"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Email     string     `json:"email"`
+	Role      Role       `json:"role"`
+	Version   uint64     `json:"version"`
+	Verified  bool       `json:"verified"`
+	Tags      []string   `json:"tags,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func newAuditUser(u User) *AuditUser {
+	return &AuditUser{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email.Address,
+		Role:      u.Role,
+		Version:   u.Version,
+		Verified:  u.Verified,
+		Tags:      u.Tags,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		DeletedAt: u.DeletedAt,
+	}
+}
+
+// AuditSink receives a Manager's audit entries. Record is called with the
+// Manager's lock held, in the order the changes take effect; it must not
+// call back into the Manager.
+type AuditSink interface {
+	Record(AuditEntry)
+}
+
+// AuditReader is implemented by sinks that can return what they recorded.
+type AuditReader interface {
+	// Last returns up to n of the most recent entries, oldest first.
+	Last(n int) []AuditEntry
+}
+
+// WithAudit records every add, update and delete to sink instead of the
+// default in-memory AuditLog.
+func WithAudit(sink AuditSink) Option {
+	return func(m *Manager) {
+		m.audit = sink
+	}
+}
+
+// Audit returns the sink the Manager records changes to.
+func (m *Manager) Audit() AuditSink {
+	return m.audit
+}
+
+// record sends an entry for op to m.audit. before and after may be nil.
+func (m *Manager) record(actor string, op string, before *User, after *User) {
+	e := AuditEntry{Time: m.now(), Op: op, Actor: actor}
+	if before != nil {
+		e.Before = newAuditUser(*before)
+	}
+	if after != nil {
+		e.After = newAuditUser(*after)
+	}
+	m.audit.Record(e)
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx naming actor as the one making the
+// changes. Manager methods taking a context record it in their audit
+// entries.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set on ctx by WithActor, or "".
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// AuditLog is an AuditSink and AuditReader that keeps the most recent
+// entries in a fixed-size ring buffer, evicting the oldest once full. It is
+// safe for concurrent use.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	next    int
+	full    bool
+}
+
+// NewAuditLog returns an AuditLog holding up to capacity entries. A
+// capacity below 1 is treated as 1.
+func NewAuditLog(capacity int) *AuditLog {
+	return &AuditLog{entries: make([]AuditEntry, max(capacity, 1))}
+}
+
+func (l *AuditLog) Record(e AuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+func (l *AuditLog) Last(n int) []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	size := l.next
+	if l.full {
+		size = len(l.entries)
+	}
+	n = min(max(n, 0), size)
+
+	result := make([]AuditEntry, n)
+	start := l.next - n
+	if start < 0 {
+		start += len(l.entries)
+	}
+	for i := range result {
+		result[i] = l.entries[(start+i)%len(l.entries)]
+	}
+	return result
+}
+
+// AuditWriter is an AuditSink that appends each entry to w as one line of
+// JSON. It is safe for concurrent use. Record cannot fail, so the first
+// write error is kept for Err and later entries are dropped.
+type AuditWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func NewAuditWriter(w io.Writer) *AuditWriter {
+	return &AuditWriter{enc: json.NewEncoder(w)}
+}
+
+func (aw *AuditWriter) Record(e AuditEntry) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	if aw.err == nil {
+		aw.err = aw.enc.Encode(e)
+	}
+}
+
+// Err returns the first error writing an entry, if any.
+func (aw *AuditWriter) Err() error {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	return aw.err
+}
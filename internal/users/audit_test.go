@@ -0,0 +1,162 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAuditEntriesInOrder(t *testing.T) {
+	log := NewAuditLog(10)
+	m := NewManager(WithAudit(log))
+	ctx := WithActor(context.Background(), "admin@bar.com")
+
+	m.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	m.UpdateUser(ctx, "jhon", "smith", "new@bar.com", AnyVersion)
+	m.AddUser(ctx, "duplicate", "email", "new@bar.com") // refused, not audited
+	m.DeleteUser(ctx, "jhon", "smith")
+
+	entries := log.Last(10)
+	var ops []string
+	for _, e := range entries {
+		ops = append(ops, e.Op+" by "+e.Actor)
+	}
+	if want := []string{"add by ", "update by admin@bar.com", "delete by admin@bar.com"}; !slices.Equal(ops, want) {
+		t.Fatalf("expected %q, got %q", want, ops)
+	}
+
+	add, update, del := entries[0], entries[1], entries[2]
+	if add.Before != nil || add.After == nil || add.After.Email != "foo@bar.com" || add.After.Version != 1 {
+		t.Errorf("bad add entry: %+v", add)
+	}
+	if update.Before.Email != "foo@bar.com" || update.After.Email != "new@bar.com" || update.After.Version != 2 {
+		t.Errorf("bad update entry: before %+v, after %+v", update.Before, update.After)
+	}
+	if del.Before == nil || del.Before.Email != "new@bar.com" || del.After != nil {
+		t.Errorf("bad delete entry: %+v", del)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Time.Before(entries[i-1].Time) {
+			t.Errorf("entry %d recorded before entry %d", i, i-1)
+		}
+	}
+}
+
+func TestAuditBatchAdds(t *testing.T) {
+	log := NewAuditLog(10)
+	m := NewManager(WithAudit(log))
+
+	m.AddUsers([]NewUser{{FirstName: "a", LastName: "smith", Email: "a@bar.com"}, {FirstName: "b", LastName: "smith", Email: "b@bar.com"}})
+	m.AddUsers([]NewUser{{FirstName: "c", LastName: "smith", Email: "c@bar.com"}, {FirstName: "a", LastName: "smith", Email: "a@bar.com"}})
+
+	var added []string
+	for _, e := range log.Last(10) {
+		added = append(added, e.Op+" "+e.After.FirstName)
+	}
+	if want := []string{"add a", "add b"}; !slices.Equal(added, want) {
+		t.Errorf("rolled back batch must not be audited: expected %q, got %q", want, added)
+	}
+}
+
+func TestAuditLogEviction(t *testing.T) {
+	log := NewAuditLog(3)
+	if got := log.Last(5); len(got) != 0 {
+		t.Fatalf("expected an empty log, got %d entries", len(got))
+	}
+
+	for i := range 5 {
+		log.Record(AuditEntry{Op: fmt.Sprint(i)})
+	}
+
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{10, []string{"2", "3", "4"}},
+		{3, []string{"2", "3", "4"}},
+		{2, []string{"3", "4"}},
+		{0, nil},
+		{-1, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range log.Last(tt.n) {
+			got = append(got, e.Op)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Last(%d): expected %q, got %q", tt.n, tt.want, got)
+		}
+	}
+}
+
+func TestAuditWriter(t *testing.T) {
+	var buf bytes.Buffer
+	aw := NewAuditWriter(&buf)
+	m := NewManager(WithAudit(aw))
+
+	m.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	m.DeleteUser(WithActor(context.Background(), "admin@bar.com"), "jhon", "smith")
+	if err := aw.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per entry, got %q", lines)
+	}
+	var e AuditEntry
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Op != AuditDelete || e.Actor != "admin@bar.com" || e.Before.Email != "foo@bar.com" {
+		t.Errorf("bad entry: %s", lines[1])
+	}
+}
+
+// auditRedacted lists the User fields deliberately left out of AuditUser.
+// A field added to User must be added either to AuditUser or here. The
+// greeting history is left out because greetings are not audited changes.
+var auditRedacted = []string{"passwordHash", "GreetCount", "LastGreetedAt"}
+
+func TestAuditRedaction(t *testing.T) {
+	audited := reflect.TypeFor[AuditUser]()
+	user := reflect.TypeFor[User]()
+	for i := range user.NumField() {
+		f := user.Field(i)
+		if slices.Contains(auditRedacted, f.Name) {
+			continue
+		}
+		if _, ok := audited.FieldByName(f.Name); !ok {
+			t.Errorf("User.%s is neither audited nor listed in auditRedacted", f.Name)
+		}
+	}
+	for i := range audited.NumField() {
+		f := audited.Field(i)
+		name := strings.ToLower(f.Name)
+		if strings.Contains(name, "password") || strings.Contains(name, "hash") || strings.Contains(name, "secret") {
+			t.Errorf("AuditUser.%s looks like a secret", f.Name)
+		}
+	}
+
+	var buf bytes.Buffer
+	m := NewManager(WithAudit(NewAuditWriter(&buf)))
+	m.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	if err := m.SetPassword("jhon", "smith", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	m.UpdateUser(context.Background(), "jhon", "smith", "new@bar.com", AnyVersion)
+	m.DeleteUser(context.Background(), "jhon", "smith")
+
+	hash := m.deleted[0].passwordHash
+	if hash == "" {
+		t.Fatal("password was not set")
+	}
+	if strings.Contains(buf.String(), hash) || strings.Contains(buf.String(), "correct horse") {
+		t.Errorf("password leaked into the audit log:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,60 @@
+package users
+
+import "fmt"
+
+// NewUser is one entry of a batch passed to AddUsers.
+type NewUser struct {
+	FirstName string
+	LastName  string
+	Email     string
+}
+
+// BatchError reports which entry of a batch failed.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("entry %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// AddUsers adds every entry or none of them. The write lock is held for the
+// whole batch; if an entry fails, the entries before it are rolled back and
+// a *BatchError naming the failed entry is returned. Entries conflict with
+// each other just as they would with existing users.
+func (m *Manager) AddUsers(entries []NewUser) ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, nextSeq, rev := len(m.users), m.nextSeq, m.rev
+
+	added := make([]User, 0, len(entries))
+	for i, e := range entries {
+		user, _, err := m.addLocked(e.FirstName, e.LastName, e.Email, RoleMember, false)
+		if err != nil {
+			for j := n; j < len(m.users); j++ {
+				m.unindex(j)
+			}
+			m.users = m.users[:n]
+			m.seqs = m.seqs[:n]
+			m.nextSeq, m.rev = nextSeq, rev
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		added = append(added, user)
+	}
+
+	for i := n; i < len(m.users); i++ {
+		m.issueToken(i)
+		m.metrics.UserAdded()
+		m.record("", AuditAdd, nil, &m.users[i])
+	}
+	if len(added) > 0 {
+		m.metrics.UsersTotal(len(m.users))
+	}
+	return added, nil
+}
@@ -0,0 +1,94 @@
+package users
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sort"
+
+	"golang.org/x/text/language"
+)
+
+// ErrInvalidCursor is returned by ListAfter for a cursor that does not name
+// a known ordering.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in the users ordered by Sort, just after the user it
+// was taken from: Key is that user's sort key and Seq its insertion
+// sequence number, which breaks ties. The zero Seq is the start of the
+// ordering.
+//
+// Seqs are never reused, so a cursor stays valid when users are added or
+// deleted, but RestoreSnapshot renumbers every user, which invalidates the
+// cursors taken before it.
+type Cursor struct {
+	Sort SortBy
+	Key  string
+	Seq  uint64
+}
+
+// ListAfter returns up to limit users following cursor in its ordering,
+// counting only the users keep reports true for, and the cursor following
+// the last of them, or nil when no users remain. A nil keep keeps every
+// user. limit must be positive.
+func (m *Manager) ListAfter(ctx context.Context, cursor Cursor, limit int, keep func(u User) bool) ([]User, *Cursor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if cursor.Sort == SortByInsertion {
+		// The users are already in order, so only the page is copied.
+		// listAfter leaves them in place for this ordering.
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		start := sort.Search(len(m.seqs), func(i int) bool { return m.seqs[i] > cursor.Seq })
+		return listAfter(cursor, limit, keep, nil, m.users[start:], m.seqs[start:])
+	}
+
+	m.mu.RLock()
+	users := slices.Clone(m.users)
+	seqs := slices.Clone(m.seqs)
+	m.mu.RUnlock()
+
+	return listAfter(cursor, limit, keep, m.collation, users, seqs)
+}
+
+// listAfter is ListAfter over users, whose sequence numbers are seqs, with
+// names compared by collation as for sortKey. It sorts users and seqs in
+// place.
+func listAfter(cursor Cursor, limit int, keep func(u User) bool, collation *language.Tag, users []User, seqs []uint64) ([]User, *Cursor, error) {
+	if _, err := ParseSortBy(string(cursor.Sort)); err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	sortUsers(cursor.Sort, collation, users, seqs)
+	key, compare := sortKey(cursor.Sort, collation)
+	after := func(i int) bool {
+		if cursor.Seq == 0 {
+			return true
+		}
+		if key == nil {
+			return seqs[i] > cursor.Seq
+		}
+		return cmp.Or(compare(key(users[i]), cursor.Key), cmp.Compare(seqs[i], cursor.Seq)) > 0
+	}
+
+	var page []User
+	last := -1
+	for i, u := range users {
+		if !after(i) || keep != nil && !keep(u) {
+			continue
+		}
+		if len(page) == limit {
+			next := Cursor{Sort: cursor.Sort, Seq: seqs[last]}
+			if key != nil {
+				next.Key = key(users[last])
+			}
+			return page, &next, nil
+		}
+		page = append(page, u)
+		last = i
+	}
+	return page, nil, nil
+}
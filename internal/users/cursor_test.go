@@ -0,0 +1,93 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestListAfterWithDelete(t *testing.T) {
+	for _, by := range []SortBy{SortByInsertion, SortByFirstName, SortByLastName, SortByEmail, SortByCreatedAt} {
+		t.Run(string(by), func(t *testing.T) {
+			m := NewManager()
+			ctx := context.Background()
+			for i := range 50 {
+				// Last names repeat so the ordering relies on its tiebreak.
+				first, last := fmt.Sprintf("user%02d", (i*7)%50), fmt.Sprintf("smith%d", i%3)
+				if err := m.AddUser(ctx, first, last, first+"@bar.com"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := m.SortedUsers(by)
+
+			seen := make(map[string]int)
+			var deleted User
+			cursor := Cursor{Sort: by}
+			pages := 0
+			for {
+				page, next, err := m.ListAfter(ctx, cursor, 10, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages++
+				for _, u := range page {
+					seen[u.FirstName]++
+				}
+				if pages == 2 {
+					// Delete a user from the next page, after the cursor.
+					deleted = want[25]
+					if err := m.DeleteUser(ctx, deleted.FirstName, deleted.LastName); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if next == nil {
+					break
+				}
+				cursor = *next
+			}
+
+			if pages != 5 {
+				t.Errorf("expected 5 pages, got %d", pages)
+			}
+			for _, u := range want {
+				switch n := seen[u.FirstName]; {
+				case u.FirstName == deleted.FirstName && n != 0:
+					t.Errorf("deleted user %s listed", u.FirstName)
+				case u.FirstName != deleted.FirstName && n != 1:
+					t.Errorf("user %s listed %d times", u.FirstName, n)
+				}
+			}
+		})
+	}
+}
+
+func TestListAfterKeep(t *testing.T) {
+	m := NewManager()
+	for i := range 10 {
+		name := fmt.Sprintf("user%d", i)
+		if err := m.AddUser(context.Background(), name, "smith", name+"@bar.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	even := func(u User) bool { return (u.FirstName[len(u.FirstName)-1]-'0')%2 == 0 }
+
+	page, next, err := m.ListAfter(context.Background(), Cursor{Sort: SortByEmail}, 3, even)
+	if err != nil || next == nil {
+		t.Fatalf("expected a full first page, got %v, %v", next, err)
+	}
+	if got := firstNames(page); fmt.Sprint(got) != "[user0 user2 user4]" {
+		t.Errorf("bad first page %q", got)
+	}
+	page, next, err = m.ListAfter(context.Background(), *next, 3, even)
+	if err != nil || next != nil {
+		t.Fatalf("expected the last page, got %v, %v", next, err)
+	}
+	if got := firstNames(page); fmt.Sprint(got) != "[user6 user8]" {
+		t.Errorf("bad last page %q", got)
+	}
+
+	if _, _, err := m.ListAfter(context.Background(), Cursor{Sort: "age"}, 3, nil); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for an unknown ordering, got %v", err)
+	}
+}
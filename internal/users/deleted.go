@@ -0,0 +1,167 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"time"
+)
+
+// missingByName reports why no live user has the given name: ErrUserDeleted
+// when a tombstone matches, ErrNoResultFound otherwise. The caller holds the
+// lock.
+func (m *Manager) missingByName(first string, last string) error {
+	if _, ok := m.tombstoneByName(first, last); ok {
+		return ErrUserDeleted
+	}
+	return ErrNoResultFound
+}
+
+// tombstoneByName returns the index in deleted of the most recently deleted
+// user with the given name. Tombstones are few and short-lived, so they are
+// scanned rather than indexed.
+func (m *Manager) tombstoneByName(first string, last string) (int, bool) {
+	key := m.nameKey(first, last)
+	for i := len(m.deleted) - 1; i >= 0; i-- {
+		if m.nameKey(m.deleted[i].FirstName, m.deleted[i].LastName) == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// tombstoneByEmail is tombstoneByName for an email address.
+func (m *Manager) tombstoneByEmail(address string) (int, bool) {
+	key := m.emailKey(address)
+	for i := len(m.deleted) - 1; i >= 0; i-- {
+		if m.emailKey(m.deleted[i].Email.Address) == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// GetDeletedUserByEmail returns the most recently deleted user with the
+// given email, or ErrNoResultFound when there is none.
+func (m *Manager) GetDeletedUserByEmail(email string) (*User, error) {
+	parsedAddress, err := mail.ParseAddress(email)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEmail, email)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i, ok := m.tombstoneByEmail(parsedAddress.Address)
+	if !ok {
+		return nil, ErrNoResultFound
+	}
+	result := m.deleted[i]
+	return &result, nil
+}
+
+// DeletedUsers returns a copy of the soft-deleted users, oldest deletion
+// first.
+func (m *Manager) DeletedUsers() []User {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]User, len(m.deleted))
+	copy(result, m.deleted)
+	return result
+}
+
+// RestoreUser brings back the most recently soft-deleted user with the given
+// name. Because deleted names and emails may be reused, it fails with
+// ErrDuplicateUser or ErrDuplicateEmail when a live user has taken either
+// since; the name only counts under DuplicateNamesReject. The restored user keeps its CreatedAt but moves to the end of the
+// insertion order.
+func (m *Manager) RestoreUser(first string, last string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, n := m.nameIndex(first, last); n > 0 && !m.allowsDuplicateNames() {
+		return ErrDuplicateUser
+	}
+	t, ok := m.tombstoneByName(first, last)
+	if !ok {
+		return ErrNoResultFound
+	}
+	return m.restoreLocked(t)
+}
+
+// RestoreUserByEmail is RestoreUser for the most recently soft-deleted user
+// with the given email.
+func (m *Manager) RestoreUserByEmail(ctx context.Context, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	parsedAddress, err := mail.ParseAddress(email)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidEmail, email)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.restoreByEmailLocked(parsedAddress.Address)
+}
+
+// restoreByEmailLocked is RestoreUserByEmail for a parsed address. The
+// caller holds the write lock.
+func (m *Manager) restoreByEmailLocked(address string) error {
+	t, ok := m.tombstoneByEmail(address)
+	if !ok {
+		return ErrNoResultFound
+	}
+	return m.restoreLocked(t)
+}
+
+// restoreLocked moves the tombstone at index t of deleted back among the
+// live users. The caller holds the write lock.
+func (m *Manager) restoreLocked(t int) error {
+	restored := m.deleted[t]
+	if _, n := m.nameIndex(restored.FirstName, restored.LastName); n > 0 && !m.allowsDuplicateNames() {
+		return ErrDuplicateUser
+	}
+	if _, ok := m.byEmail[m.emailKey(restored.Email.Address)]; ok {
+		return ErrDuplicateEmail
+	}
+
+	m.deleted = append(m.deleted[:t], m.deleted[t+1:]...)
+	delete(m.deletedIDs, restored.ID)
+	restored.DeletedAt = nil
+	restored.UpdatedAt = m.now()
+	restored.Version++
+
+	m.nextSeq++
+	m.users = append(m.users, restored)
+	m.seqs = append(m.seqs, m.nextSeq)
+	m.index(len(m.users) - 1)
+	m.rev++
+	m.metrics.UsersTotal(len(m.users))
+
+	return nil
+}
+
+// PurgeDeleted permanently drops users soft-deleted at least olderThan ago
+// and returns how many were dropped.
+func (m *Manager) PurgeDeleted(olderThan time.Duration) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := m.now().Add(-olderThan)
+	kept := m.deleted[:0]
+	for _, u := range m.deleted {
+		if u.DeletedAt.After(cutoff) {
+			kept = append(kept, u)
+		} else {
+			delete(m.deletedIDs, u.ID)
+		}
+	}
+	purged := len(m.deleted) - len(kept)
+	clear(m.deleted[len(kept):])
+	m.deleted = kept
+
+	return purged
+}
@@ -0,0 +1,60 @@
+package users
+
+import (
+	"context"
+	"strings"
+)
+
+// emailDomain returns the lower-cased domain of an address, or "" when it
+// has none. Domains are case-insensitive; local parts, including any
+// +tag, are ignored.
+func emailDomain(address string) string {
+	i := strings.LastIndexByte(address, '@')
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(address[i+1:])
+}
+
+// CountByDomain returns the number of live users per email domain, keyed
+// by lower-cased domain. It never returns nil.
+func (m *Manager) CountByDomain() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, u := range m.users {
+		counts[emailDomain(u.Email.Address)]++
+	}
+	return counts
+}
+
+// CountByDomain is Manager.CountByDomain for any Store, whose users it
+// reads through All.
+func CountByDomain(ctx context.Context, s Store) (map[string]int, error) {
+	counts := make(map[string]int)
+	for u, err := range All(ctx, s) {
+		if err != nil {
+			return nil, err
+		}
+		counts[emailDomain(u.Email.Address)]++
+	}
+	return counts, nil
+}
+
+// GetUsersByDomain returns the live users whose email domain matches domain,
+// ignoring case, in insertion order. A leading "@" on domain is allowed.
+func (m *Manager) GetUsersByDomain(domain string) []User {
+	domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []User
+	for _, u := range m.users {
+		if emailDomain(u.Email.Address) == domain {
+			result = append(result, u)
+		}
+	}
+	return result
+}
@@ -0,0 +1,44 @@
+package users
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCountByDomain(t *testing.T) {
+	m := NewManager()
+	if counts := m.CountByDomain(); counts == nil || len(counts) != 0 {
+		t.Errorf("expected an empty map, got %#v", counts)
+	}
+
+	for _, u := range []struct{ first, email string }{
+		{"Alice", "alice@example.com"},
+		{"Bob", "Bob <bob@Example.COM>"},
+		{"Carol", "carol+news@x.com"},
+		{"Dave", "dave@X.com"},
+		{"Erin", "erin@EXAMPLE.com"},
+		{"Frank", "frank@other.org"},
+	} {
+		if err := m.AddUser(context.Background(), u.first, "Smith", u.email); err != nil {
+			t.Fatalf("AddUser %s: %v", u.email, err)
+		}
+	}
+	m.DeleteUser(context.Background(), "Frank", "Smith")
+
+	want := map[string]int{"example.com": 3, "x.com": 2}
+	if got := m.CountByDomain(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	var names []string
+	for _, u := range m.GetUsersByDomain("@X.COM") {
+		names = append(names, u.FirstName)
+	}
+	if want := []string{"Carol", "Dave"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected %q, got %q", want, names)
+	}
+	if got := m.GetUsersByDomain("other.org"); len(got) != 0 {
+		t.Errorf("deleted user returned: %v", got)
+	}
+}
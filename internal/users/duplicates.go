@@ -0,0 +1,91 @@
+package users
+
+import (
+	"errors"
+	"slices"
+)
+
+// DuplicateNamePolicy decides whether two live users may share a first and
+// last name.
+type DuplicateNamePolicy int
+
+const (
+	// DuplicateNamesReject refuses a user whose name is taken with
+	// ErrDuplicateUser. It is the default.
+	DuplicateNamesReject DuplicateNamePolicy = iota
+
+	// DuplicateNamesAllow lets users share a name as long as their emails
+	// differ.
+	DuplicateNamesAllow
+
+	// DuplicateNamesRequireDistinctEmail is DuplicateNamesAllow plus the
+	// check that no two users share an email. A Manager always makes that
+	// check, so it behaves exactly like DuplicateNamesAllow; it exists for
+	// callers that want to state the guarantee they rely on.
+	DuplicateNamesRequireDistinctEmail
+)
+
+// ErrAmbiguousName is returned by the methods that find a user by name
+// when more than one live user has it. Only a Manager whose policy allows
+// duplicate names returns it; callers should look such users up with
+// GetUsersByName or by email instead.
+var ErrAmbiguousName = errors.New("name matches more than one user")
+
+// WithDuplicateNamePolicy sets whether users may share a name.
+func WithDuplicateNamePolicy(policy DuplicateNamePolicy) Option {
+	return func(m *Manager) {
+		m.namePolicy = policy
+	}
+}
+
+// allowsDuplicateNames reports whether m's policy lets users share a name.
+func (m *Manager) allowsDuplicateNames() bool {
+	return m.namePolicy != DuplicateNamesReject
+}
+
+// nameIndex returns the position of the oldest live user with the given
+// name and how many live users have it. The caller holds the lock.
+func (m *Manager) nameIndex(first string, last string) (int, int) {
+	matches := m.byName[m.nameKey(first, last)]
+	if len(matches) == 0 {
+		return 0, 0
+	}
+	return matches[0], len(matches)
+}
+
+// GetUsersByName returns every live user with the given name in insertion
+// order, or nil when there is none. Under the default policy it returns at
+// most one user.
+func (m *Manager) GetUsersByName(first string, last string) []User {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches := m.byName[m.nameKey(first, last)]
+	m.metrics.Lookup(len(matches) > 0)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	result := make([]User, len(matches))
+	for i, j := range matches {
+		result[i] = m.users[j]
+	}
+	return result
+}
+
+// addIndex inserts i into the sorted positions of a name.
+func addIndex(positions []int, i int) []int {
+	at, found := slices.BinarySearch(positions, i)
+	if found {
+		return positions
+	}
+	return slices.Insert(positions, at, i)
+}
+
+// removeIndex deletes i from the sorted positions of a name.
+func removeIndex(positions []int, i int) []int {
+	if at, found := slices.BinarySearch(positions, i); found {
+		return slices.Delete(positions, at, at+1)
+	}
+	return positions
+}
@@ -0,0 +1,145 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDuplicateNamePolicies(t *testing.T) {
+	tests := []struct {
+		policy  DuplicateNamePolicy
+		allowed bool
+	}{
+		{DuplicateNamesReject, false},
+		{DuplicateNamesAllow, true},
+		{DuplicateNamesRequireDistinctEmail, true},
+	}
+
+	for _, tt := range tests {
+		m := NewManager(WithDuplicateNamePolicy(tt.policy))
+		if err := m.AddUser(context.Background(), "Alice", "Smith", "alice@example.com"); err != nil {
+			t.Fatalf("policy %d: %v", tt.policy, err)
+		}
+
+		err := m.AddUser(context.Background(), "Alice", "Smith", "alice.smith@example.com")
+		if tt.allowed && err != nil {
+			t.Errorf("policy %d: expected the duplicate name to be accepted, got %v", tt.policy, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrDuplicateUser) {
+			t.Errorf("policy %d: expected ErrDuplicateUser, got %v", tt.policy, err)
+		}
+
+		err = m.AddUser(context.Background(), "Alice", "Smith", "alice@example.com")
+		if !errors.Is(err, ErrDuplicateUser) && !errors.Is(err, ErrDuplicateEmail) {
+			t.Errorf("policy %d: expected a duplicate email to be rejected, got %v", tt.policy, err)
+		}
+	}
+}
+
+func TestGetUsersByName(t *testing.T) {
+	m := NewManager(WithDuplicateNamePolicy(DuplicateNamesAllow))
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := m.AddUser(context.Background(), "Alice", "Smith", email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.AddUser(context.Background(), "Bob", "Smith", "bob@example.com")
+
+	matches := m.GetUsersByName("Alice", "Smith")
+	if len(matches) != 3 {
+		t.Fatalf("expected 3 matches, got %v", matches)
+	}
+	for i, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if matches[i].Email.Address != email {
+			t.Errorf("match %d: expected %s, got %s", i, email, matches[i].Email.Address)
+		}
+	}
+	if got := m.GetUsersByName("Bob", "Smith"); len(got) != 1 {
+		t.Errorf("expected 1 match, got %v", got)
+	}
+	if got := m.GetUsersByName("Carol", "Smith"); got != nil {
+		t.Errorf("expected no match, got %v", got)
+	}
+
+	if err := m.DeleteUser(context.Background(), "Bob", "Smith"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetUserByEmail(context.Background(), "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetUsersByName("Alice", "Smith"); len(got) != 3 || got[2].Email.Address != "c@example.com" {
+		t.Errorf("bad matches after delete: %v", got)
+	}
+}
+
+func TestAmbiguousName(t *testing.T) {
+	m := NewManager(WithDuplicateNamePolicy(DuplicateNamesAllow))
+	m.AddUser(context.Background(), "Alice", "Smith", "a@example.com")
+	m.AddUser(context.Background(), "Alice", "Smith", "b@example.com")
+
+	if _, err := m.GetUserByName(context.Background(), "Alice", "Smith"); !errors.Is(err, ErrAmbiguousName) {
+		t.Errorf("GetUserByName: expected ErrAmbiguousName, got %v", err)
+	}
+	if err := m.UpdateUser(context.Background(), "Alice", "Smith", "c@example.com", 0); !errors.Is(err, ErrAmbiguousName) {
+		t.Errorf("UpdateUser: expected ErrAmbiguousName, got %v", err)
+	}
+	if err := m.SetRole("Alice", "Smith", "admin"); !errors.Is(err, ErrAmbiguousName) {
+		t.Errorf("SetRole: expected ErrAmbiguousName, got %v", err)
+	}
+	if err := m.SetPassword("Alice", "Smith", "correct horse battery"); !errors.Is(err, ErrAmbiguousName) {
+		t.Errorf("SetPassword: expected ErrAmbiguousName, got %v", err)
+	}
+	if err := m.DeleteUser(context.Background(), "Alice", "Smith"); !errors.Is(err, ErrAmbiguousName) {
+		t.Errorf("DeleteUser: expected ErrAmbiguousName, got %v", err)
+	}
+	if got := len(m.GetAllUsers()); got != 2 {
+		t.Errorf("expected both users to survive, got %d", got)
+	}
+}
+
+func TestGetOrCreateUserDuplicateNames(t *testing.T) {
+	m := NewManager(WithDuplicateNamePolicy(DuplicateNamesAllow))
+
+	first, created, err := m.GetOrCreateUser(context.Background(), "Alice", "Smith", "a@example.com")
+	if err != nil || !created {
+		t.Fatalf("first call: created %v, err %v", created, err)
+	}
+	second, created, err := m.GetOrCreateUser(context.Background(), "Alice", "Smith", "b@example.com")
+	if err != nil || !created {
+		t.Fatalf("second call: created %v, err %v", created, err)
+	}
+
+	again, created, err := m.GetOrCreateUser(context.Background(), "Alice", "Smith", "b@example.com")
+	if err != nil || created {
+		t.Fatalf("retry: created %v, err %v", created, err)
+	}
+	if again.Email != second.Email || again.Email == first.Email {
+		t.Errorf("retry returned the wrong user: %v", again)
+	}
+}
+
+func TestSnapshotDuplicateNames(t *testing.T) {
+	before := NewManager(WithDuplicateNamePolicy(DuplicateNamesAllow))
+	before.AddUser(context.Background(), "Alice", "Smith", "a@example.com")
+	before.AddUser(context.Background(), "Alice", "Smith", "b@example.com")
+
+	var buf bytes.Buffer
+	if err := before.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	after := NewManager(WithDuplicateNamePolicy(DuplicateNamesAllow))
+	if err := after.RestoreSnapshot(bytes.NewReader(data)); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if got := after.GetUsersByName("Alice", "Smith"); len(got) != 2 {
+		t.Errorf("expected 2 users after restore, got %v", got)
+	}
+
+	if err := NewManager().RestoreSnapshot(bytes.NewReader(data)); err == nil {
+		t.Error("expected the default policy to reject a snapshot with duplicate names")
+	}
+}
@@ -0,0 +1,80 @@
+package users
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// dotInsensitiveDomains are the providers known to deliver
+// "b.o.b@gmail.com" to the same mailbox as "bob@gmail.com".
+var dotInsensitiveDomains = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+}
+
+// NormalizeOptions selects the rules NormalizeEmail applies on top of
+// lower-casing the domain, which it always does. The zero value applies no
+// others.
+type NormalizeOptions struct {
+	// LowercaseLocal lower-cases the local part. RFC 5321 lets the
+	// receiving host treat it case sensitively, but practically none do.
+	LowercaseLocal bool
+	// StripPlusTag drops everything from the first "+" of the local part,
+	// so "bob+promo@example.com" becomes "bob@example.com".
+	StripPlusTag bool
+	// StripDots drops the dots from the local part at the providers that
+	// ignore them, such as gmail.com.
+	StripDots bool
+}
+
+// NormalizeEmail parses addr and returns the form two addresses of the same
+// mailbox share under opts. A rule that would leave the local part empty is
+// skipped.
+func NormalizeEmail(addr string, opts NormalizeOptions) (string, error) {
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidEmail, addr)
+	}
+	return opts.normalize(parsed.Address), nil
+}
+
+// normalize is NormalizeEmail for an address mail.ParseAddress has already
+// accepted.
+func (opts NormalizeOptions) normalize(address string) string {
+	i := strings.LastIndexByte(address, '@')
+	if i < 0 {
+		return address
+	}
+	local, domain := address[:i], strings.ToLower(address[i+1:])
+
+	if opts.LowercaseLocal {
+		local = strings.ToLower(local)
+	}
+	if opts.StripPlusTag {
+		if tag := strings.IndexByte(local, '+'); tag > 0 {
+			local = local[:tag]
+		}
+	}
+	if opts.StripDots && dotInsensitiveDomains[domain] {
+		if stripped := strings.ReplaceAll(local, ".", ""); stripped != "" {
+			local = stripped
+		}
+	}
+	return local + "@" + domain
+}
+
+// WithEmailNormalization makes the Manager treat addresses that normalize
+// to the same form under opts as one address: adding the second is
+// ErrDuplicateEmail, and looking up either finds the user. Users keep the
+// address they signed up with.
+func WithEmailNormalization(opts NormalizeOptions) Option {
+	return func(m *Manager) {
+		m.emailRules = opts
+	}
+}
+
+// emailKey is the byEmail key of a parsed address.
+func (m *Manager) emailKey(address string) string {
+	return m.emailRules.normalize(address)
+}
@@ -0,0 +1,136 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	all := NormalizeOptions{LowercaseLocal: true, StripPlusTag: true, StripDots: true}
+	testCases := []struct {
+		name     string
+		addr     string
+		opts     NormalizeOptions
+		expected string
+	}{
+		{"domain always lowered", "Bob.Smith+promo@GMail.COM", NormalizeOptions{}, "Bob.Smith+promo@gmail.com"},
+		{"display name dropped", "Bob <bob@example.com>", NormalizeOptions{}, "bob@example.com"},
+		{"lowercase local", "Bob.Smith@example.com", NormalizeOptions{LowercaseLocal: true}, "bob.smith@example.com"},
+		{"strip plus tag", "bob+promo@example.com", NormalizeOptions{StripPlusTag: true}, "bob@example.com"},
+		{"strip from first plus", "bob+a+b@example.com", NormalizeOptions{StripPlusTag: true}, "bob@example.com"},
+		{"leading plus kept", "+promo@example.com", NormalizeOptions{StripPlusTag: true}, "+promo@example.com"},
+		{"strip dots at gmail", "b.o.b@gmail.com", NormalizeOptions{StripDots: true}, "bob@gmail.com"},
+		{"strip dots at googlemail", "b.o.b@googlemail.com", NormalizeOptions{StripDots: true}, "bob@googlemail.com"},
+		{"strip dots after lowering domain", "b.o.b@GMAIL.com", NormalizeOptions{StripDots: true}, "bob@gmail.com"},
+		{"dots kept elsewhere", "b.o.b@example.com", NormalizeOptions{StripDots: true}, "b.o.b@example.com"},
+		{"dots kept without option", "b.o.b@gmail.com", NormalizeOptions{}, "b.o.b@gmail.com"},
+		{"all rules", "Bob.Smith+promo@gmail.com", all, "bobsmith@gmail.com"},
+		{"all rules plain", "bobsmith@gmail.com", all, "bobsmith@gmail.com"},
+		{"all rules elsewhere", "Bob.Smith+promo@Example.com", all, "bob.smith@example.com"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NormalizeEmail(tc.addr, tc.opts)
+			if err != nil {
+				t.Fatalf("NormalizeEmail(%q): %v", tc.addr, err)
+			}
+			if got != tc.expected {
+				t.Errorf("NormalizeEmail(%q, %+v): expected %q, got %q", tc.addr, tc.opts, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmailInvalid(t *testing.T) {
+	for _, addr := range []string{"", "bob", "bob@", "@example.com"} {
+		if got, err := NormalizeEmail(addr, NormalizeOptions{}); err == nil {
+			t.Errorf("NormalizeEmail(%q): expected an error, got %q", addr, got)
+		}
+	}
+}
+
+func TestManagerEmailNormalization(t *testing.T) {
+	ctx := context.Background()
+
+	m := NewManager(WithEmailNormalization(NormalizeOptions{LowercaseLocal: true, StripPlusTag: true, StripDots: true}))
+	if err := m.AddUser(ctx, "bob", "smith", "Bob.Smith+promo@gmail.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddUser(ctx, "robert", "smith", "bobsmith@gmail.com"); !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("expected ErrDuplicateEmail, got %v", err)
+	}
+
+	user, err := m.GetUserByEmail(ctx, "BOBSMITH@gmail.com")
+	if err != nil {
+		t.Fatalf("lookup by normalized address: %v", err)
+	}
+	if user.Email.Address != "Bob.Smith+promo@gmail.com" {
+		t.Errorf("original address not preserved: got %q", user.Email.Address)
+	}
+
+	// Without the options only the domain's case is ignored.
+	plain := NewManager()
+	if err := plain.AddUser(ctx, "bob", "smith", "Bob.Smith+promo@gmail.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.AddUser(ctx, "robert", "smith", "bobsmith@gmail.com"); err != nil {
+		t.Errorf("distinct addresses rejected without normalization: %v", err)
+	}
+	if err := plain.AddUser(ctx, "rob", "smith", "Bob.Smith+promo@GMAIL.com"); !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("expected domain case to be ignored, got %v", err)
+	}
+}
+
+func TestEmailDisplayName(t *testing.T) {
+	ctx := context.Background()
+
+	m := NewManager()
+	if err := m.AddUser(ctx, "bob", "smith", "Bob <bob@x.com>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddUser(ctx, "robert", "smith", "Robert <bob@x.com>"); !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("expected ErrDuplicateEmail for another display name, got %v", err)
+	}
+	if err := m.AddUser(ctx, "rob", "smith", "bob@x.com"); !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("expected ErrDuplicateEmail for the bare address, got %v", err)
+	}
+
+	user, err := m.GetUserByEmail(ctx, "bob@x.com")
+	if err != nil {
+		t.Fatalf("lookup by bare address: %v", err)
+	}
+	if user.Email.Name != "Bob" || user.Email.Address != "bob@x.com" {
+		t.Errorf("display name not preserved: got %+v", user.Email)
+	}
+
+	var ndjson bytes.Buffer
+	if _, err := m.ExportNDJSON(ctx, &ndjson); err != nil {
+		t.Fatal(err)
+	}
+	var record struct {
+		Email     string `json:"email"`
+		EmailName string `json:"email_name"`
+	}
+	if err := json.Unmarshal(ndjson.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Email != "bob@x.com" || record.EmailName != "Bob" {
+		t.Errorf("bad export %s", ndjson.String())
+	}
+
+	var snapshot bytes.Buffer
+	if err := m.WriteSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewManager()
+	if err := restored.RestoreSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := restored.GetUserByEmail(ctx, "Robert <bob@x.com>"); err != nil || user.Email.Name != "Bob" {
+		t.Errorf("display name lost by the snapshot: %v, %v", user, err)
+	}
+}
@@ -0,0 +1,131 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"iter"
+	"slices"
+	"time"
+)
+
+// exportBatchSize is the number of users the exports encode per write, and
+// how often they check whether their context is done.
+const exportBatchSize = 500
+
+// exportRecord is the JSON shape of one exported user. Its keys are
+// snake_case like the API's and the CSV export's.
+type exportRecord struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	// EmailName is the display name the address was given with, if any.
+	EmailName string    `json:"email_name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExportNDJSON writes every user to w as JSON Lines, one object per line, in
+// insertion order. It ranges over All, so writers are not blocked for the
+// length of the export, and calls w.Write once per exportBatchSize users so
+// callers can flush between batches. It returns the number of users
+// written. Once ctx is done it stops at the next batch and returns
+// ctx.Err().
+func (m *Manager) ExportNDJSON(ctx context.Context, w io.Writer) (int, error) {
+	return exportNDJSON(ctx, w, m.All())
+}
+
+// ExportNDJSON is Manager.ExportNDJSON for any Store, whose users it reads
+// through All.
+func ExportNDJSON(ctx context.Context, w io.Writer, s Store) (int, error) {
+	var err error
+	n, exportErr := exportNDJSON(ctx, w, untilError(All(ctx, s), &err))
+	if exportErr != nil {
+		return n, exportErr
+	}
+	return n, err
+}
+
+func exportNDJSON(ctx context.Context, w io.Writer, all iter.Seq[User]) (int, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	return exportBatches(ctx, w, &buf, all, func(u User) error {
+		return enc.Encode(exportRecord{
+			FirstName: u.FirstName,
+			LastName:  u.LastName,
+			Email:     u.Email.Address,
+			EmailName: u.Email.Name,
+			CreatedAt: u.CreatedAt,
+			UpdatedAt: u.UpdatedAt,
+		})
+	})
+}
+
+// ExportCSV writes all to w as CSV after a header row, in batches like
+// ExportNDJSON and with the same handling of ctx. It takes the users rather
+// than reading the Manager, so callers can export a Snapshot. It returns
+// the number of users written, not counting the header.
+func ExportCSV(ctx context.Context, w io.Writer, all []User) (int, error) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write([]string{"first_name", "last_name", "email", "created_at", "updated_at"})
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return 0, err
+	}
+
+	return exportBatches(ctx, w, &buf, slices.Values(all), func(u User) error {
+		cw.Write([]string{
+			u.FirstName,
+			u.LastName,
+			u.Email.Address,
+			u.CreatedAt.Format(time.RFC3339),
+			u.UpdatedAt.Format(time.RFC3339),
+		})
+		// Flush each row into buf so a batch is complete when it is written.
+		cw.Flush()
+		return cw.Error()
+	})
+}
+
+// exportBatches calls encode, which appends to buf, for each user in users
+// and writes buf to w after every exportBatchSize users and at the end.
+// ctx is checked before each batch. It returns the number of users whose
+// encoding reached w.
+func exportBatches(ctx context.Context, w io.Writer, buf *bytes.Buffer, users iter.Seq[User], encode func(User) error) (int, error) {
+	written, pending := 0, 0
+	flush := func() error {
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		buf.Reset()
+		written += pending
+		pending = 0
+		return nil
+	}
+
+	for u := range users {
+		if pending == 0 {
+			if err := ctx.Err(); err != nil {
+				return written, err
+			}
+		}
+		if err := encode(u); err != nil {
+			return written, err
+		}
+		pending++
+		if pending == exportBatchSize {
+			if err := flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	if buf.Len() == 0 {
+		return written, nil
+	}
+	return written, flush()
+}
@@ -0,0 +1,135 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// cancelingWriter counts the lines written to it and cancels once it has
+// seen at least limit of them.
+type cancelingWriter struct {
+	lines  int
+	limit  int
+	cancel context.CancelFunc
+}
+
+func (w *cancelingWriter) Write(p []byte) (int, error) {
+	w.lines += bytes.Count(p, []byte("\n"))
+	if w.lines >= w.limit {
+		w.cancel()
+	}
+	return len(p), nil
+}
+
+func exportTestManager(t *testing.T, n int) *Manager {
+	t.Helper()
+
+	m := NewManager()
+	for i := range n {
+		if err := m.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestExportNDJSONFormat(t *testing.T) {
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManager(WithClock(func() time.Time { return clock }))
+	ctx := context.Background()
+	if err := m.AddUser(ctx, "Ada", "Lovelace", "Ada L <ada@bar.com>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddUser(ctx, "Alan", "Turing", "alan@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.ExportNDJSON(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"first_name":"Ada","last_name":"Lovelace","email":"ada@bar.com","email_name":"Ada L","created_at":"2026-03-01T12:00:00Z","updated_at":"2026-03-01T12:00:00Z"}
+{"first_name":"Alan","last_name":"Turing","email":"alan@bar.com","created_at":"2026-03-01T12:00:00Z","updated_at":"2026-03-01T12:00:00Z"}
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestExportStopsWhenCanceled(t *testing.T) {
+	m := exportTestManager(t, 10000)
+	_, all := m.Snapshot()
+
+	tests := []struct {
+		name   string
+		header int
+		export func(ctx context.Context, w io.Writer) (int, error)
+	}{
+		{"ndjson", 0, m.ExportNDJSON},
+		{"csv", 1, func(ctx context.Context, w io.Writer) (int, error) { return ExportCSV(ctx, w, all) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			w := &cancelingWriter{limit: 1200, cancel: cancel}
+
+			rows, err := tt.export(ctx, w)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+			// The third batch crosses the limit; the export stops before
+			// the fourth.
+			if rows != 3*exportBatchSize {
+				t.Errorf("expected %d rows, got %d", 3*exportBatchSize, rows)
+			}
+			if w.lines != rows+tt.header {
+				t.Errorf("reported %d rows but wrote %d lines", rows, w.lines)
+			}
+		})
+	}
+}
+
+func TestExportCompletes(t *testing.T) {
+	m := exportTestManager(t, 1234)
+	_, all := m.Snapshot()
+
+	var ndjson bytes.Buffer
+	rows, err := m.ExportNDJSON(context.Background(), &ndjson)
+	if err != nil || rows != 1234 {
+		t.Fatalf("ndjson: expected 1234 rows, got %d, %v", rows, err)
+	}
+	if lines := bytes.Count(ndjson.Bytes(), []byte("\n")); lines != 1234 {
+		t.Errorf("ndjson: expected 1234 lines, got %d", lines)
+	}
+
+	var buf bytes.Buffer
+	rows, err = ExportCSV(context.Background(), &buf, all)
+	if err != nil || rows != 1234 {
+		t.Fatalf("csv: expected 1234 rows, got %d, %v", rows, err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1235 || records[0][0] != "first_name" || records[1234][2] != "user1233@bar.com" {
+		t.Errorf("csv: unexpected export of %d records, first %v", len(records), records[0])
+	}
+}
+
+func TestExportCSVEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	rows, err := ExportCSV(context.Background(), &buf, nil)
+	if err != nil || rows != 0 {
+		t.Fatalf("expected 0 rows, got %d, %v", rows, err)
+	}
+	if want := "first_name,last_name,email,created_at,updated_at\n"; buf.String() != want {
+		t.Errorf("expected only the header, got %q", buf.String())
+	}
+}
@@ -0,0 +1,58 @@
+package users
+
+import "time"
+
+// RecordGreeting adds a greeting to the history of the named user: it bumps
+// GreetCount and sets LastGreetedAt to the Manager's clock. Being greeted is
+// not a change to the user, so Version, UpdatedAt and the revision stay as
+// they are. It fails with ErrNoResultFound for a name no live user has.
+func (m *Manager) RecordGreeting(first string, last string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, n := m.nameIndex(first, last)
+	if n == 0 {
+		return ErrNoResultFound
+	}
+	if n > 1 {
+		return ErrAmbiguousName
+	}
+
+	m.users[i].GreetCount++
+	m.users[i].LastGreetedAt = m.now()
+
+	return nil
+}
+
+// GetInactiveSince returns every live user not greeted at or after t, in
+// insertion order: those last greeted before t and those never greeted. A
+// user greeted exactly at t is active.
+func (m *Manager) GetInactiveSince(t time.Time) []User {
+	return m.usersGreeted(func(last time.Time) bool {
+		return last.Before(t)
+	})
+}
+
+// GetActiveBetween returns every live user last greeted at or after from
+// and before to, in insertion order. Users never greeted are not active in
+// any range.
+func (m *Manager) GetActiveBetween(from time.Time, to time.Time) []User {
+	return m.usersGreeted(func(last time.Time) bool {
+		return !last.IsZero() && !last.Before(from) && last.Before(to)
+	})
+}
+
+// usersGreeted returns the users whose LastGreetedAt satisfies keep. Only
+// those are copied, and only the scan holds the read lock.
+func (m *Manager) usersGreeted(keep func(last time.Time) bool) []User {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []User
+	for _, u := range m.users {
+		if keep(u.LastGreetedAt) {
+			result = append(result, u)
+		}
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecordGreeting(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManager(WithClock(func() time.Time { return now }))
+	m.AddUser(context.Background(), "Alice", "Smith", "alice@example.com")
+
+	if err := m.RecordGreeting("Alice", "Smith"); err != nil {
+		t.Fatalf("RecordGreeting: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := m.RecordGreeting("Alice", "Smith"); err != nil {
+		t.Fatalf("RecordGreeting: %v", err)
+	}
+
+	user, _ := m.GetUserByName(context.Background(), "Alice", "Smith")
+	if user.GreetCount != 2 || !user.LastGreetedAt.Equal(now) {
+		t.Errorf("bad history: count %d, last greeted %v", user.GreetCount, user.LastGreetedAt)
+	}
+	if user.Version != 1 || user.UpdatedAt.Equal(now) {
+		t.Errorf("a greeting should not change the user: %+v", user)
+	}
+
+	if err := m.RecordGreeting("Bob", "Smith"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("expected ErrNoResultFound, got %v", err)
+	}
+}
+
+func TestRecordGreetingSnapshot(t *testing.T) {
+	before := NewManager()
+	before.AddUser(context.Background(), "Alice", "Smith", "alice@example.com")
+	before.RecordGreeting("Alice", "Smith")
+
+	var buf bytes.Buffer
+	if err := before.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	after := NewManager()
+	if err := after.RestoreSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := before.GetUserByName(context.Background(), "Alice", "Smith")
+	got, _ := after.GetUserByName(context.Background(), "Alice", "Smith")
+	if got.GreetCount != 1 || !got.LastGreetedAt.Equal(expected.LastGreetedAt) {
+		t.Errorf("history lost in snapshot: expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestRecordGreetingConcurrent(t *testing.T) {
+	m := NewManager()
+	m.AddUser(context.Background(), "Alice", "Smith", "alice@example.com")
+
+	const goroutines, greetings = 50, 200
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Go(func() {
+			for range greetings {
+				if err := m.RecordGreeting("Alice", "Smith"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	user, _ := m.GetUserByName(context.Background(), "Alice", "Smith")
+	if user.GreetCount != goroutines*greetings {
+		t.Errorf("bad count: expected %d, got %d", goroutines*greetings, user.GreetCount)
+	}
+}
+
+func TestActivityQueries(t *testing.T) {
+	cutoff := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	now := cutoff
+	m := NewManager(WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+	for _, name := range []string{"Before", "At", "After", "Never"} {
+		if err := m.AddUser(ctx, name, "Smith", name+"@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	greetAt := func(first string, at time.Time) {
+		now = at
+		if err := m.RecordGreeting(first, "Smith"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	greetAt("Before", cutoff.Add(-time.Nanosecond))
+	greetAt("At", cutoff)
+	greetAt("After", cutoff.Add(24*time.Hour))
+
+	names := func(us []User) []string {
+		var out []string
+		for _, u := range us {
+			out = append(out, u.FirstName)
+		}
+		return out
+	}
+
+	if got := names(m.GetInactiveSince(cutoff)); !slices.Equal(got, []string{"Before", "Never"}) {
+		t.Errorf("GetInactiveSince: expected [Before Never], got %q", got)
+	}
+	if got := names(m.GetActiveBetween(cutoff, cutoff.Add(24*time.Hour))); !slices.Equal(got, []string{"At"}) {
+		t.Errorf("GetActiveBetween: expected [At], the end being exclusive, got %q", got)
+	}
+	if got := names(m.GetActiveBetween(time.Time{}, cutoff.Add(48*time.Hour))); !slices.Equal(got, []string{"Before", "At", "After"}) {
+		t.Errorf("GetActiveBetween over all time: expected everyone greeted, got %q", got)
+	}
+	if got := m.GetActiveBetween(cutoff.Add(time.Hour), cutoff); got != nil {
+		t.Errorf("empty range: expected no users, got %q", names(got))
+	}
+}
@@ -0,0 +1,239 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IDAttempts is how many IDs AddUser generates for a new user before
+// giving up on finding one that is not taken.
+const IDAttempts = 5
+
+var (
+	// ErrIDCollision is wrapped by IDCollisionError.
+	ErrIDCollision = errors.New("id collision")
+
+	// ErrInvalidID is returned by GetUserByID for an ID the Manager's
+	// generator could not have produced.
+	ErrInvalidID = errors.New("invalid id")
+)
+
+// IDCollisionError is returned when every one of IDAttempts generated IDs
+// was already taken. It wraps ErrIDCollision.
+type IDCollisionError struct {
+	Attempts int
+	// Last is the last ID generated.
+	Last string
+}
+
+func (e *IDCollisionError) Error() string {
+	return fmt.Sprintf("no free id after %d attempts, last %q", e.Attempts, e.Last)
+}
+
+func (e *IDCollisionError) Unwrap() error {
+	return ErrIDCollision
+}
+
+// IDGenerator makes the IDs of new users. IDs need not be unique: the
+// Manager retries on a collision.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// IDValidator is implemented by generators that can cheaply tell whether a
+// string is in their format, which GetUserByID checks before looking an ID
+// up.
+type IDValidator interface {
+	ValidID(id string) bool
+}
+
+// WithIDGenerator sets the generator of user IDs. The default is
+// UUIDv4Generator. A deployment should keep to one: GetUserByID rejects
+// IDs not in the format of the generator's IDValidator.
+func WithIDGenerator(g IDGenerator) Option {
+	return func(m *Manager) {
+		m.idGen = g
+	}
+}
+
+// UUIDv4Generator makes random RFC 9562 version 4 UUIDs in their
+// lowercase hyphenated form.
+type UUIDv4Generator struct{}
+
+func (UUIDv4Generator) NewID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	return formatUUID(u, 4), nil
+}
+
+func (UUIDv4Generator) ValidID(id string) bool {
+	return validUUID(id, '4')
+}
+
+// UUIDv7Generator makes RFC 9562 version 7 UUIDs, which lead with the
+// Unix time in milliseconds and so sort by creation time. Now, if set,
+// replaces time.Now.
+type UUIDv7Generator struct {
+	Now func() time.Time
+}
+
+func (g UUIDv7Generator) NewID() (string, error) {
+	now := time.Now
+	if g.Now != nil {
+		now = g.Now
+	}
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return "", err
+	}
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(now().UnixMilli()))
+	copy(u[:6], ms[2:])
+	return formatUUID(u, 7), nil
+}
+
+func (UUIDv7Generator) ValidID(id string) bool {
+	return validUUID(id, '7')
+}
+
+// formatUUID sets the version and variant bits of u and formats it.
+func formatUUID(u [16]byte, version byte) string {
+	u[6] = u[6]&0x0f | version<<4
+	u[8] = u[8]&0x3f | 0x80
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// validUUID reports whether id is a lowercase hyphenated UUID of the
+// given version and the RFC 9562 variant.
+func validUUID(id string, version byte) bool {
+	if len(id) != 36 || id[14] != version || !strings.ContainsRune("89ab", rune(id[19])) {
+		return false
+	}
+	for i := range len(id) {
+		switch c := id[i]; {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case !('0' <= c && c <= '9' || 'a' <= c && c <= 'f'):
+			return false
+		}
+	}
+	return true
+}
+
+// base32IDBytes random bytes make each Base32Generator ID.
+const base32IDBytes = 10
+
+// base32IDs encodes Base32Generator IDs: the RFC 4648 alphabet in lower
+// case, without padding.
+var base32IDs = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// Base32GeneratorLength is the length of every Base32Generator ID.
+const Base32GeneratorLength = 16
+
+// Base32Generator makes short random IDs for URLs: 80 bits from
+// crypto/rand in lowercase base32, 16 characters long.
+type Base32Generator struct{}
+
+func (Base32Generator) NewID() (string, error) {
+	var b [base32IDBytes]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base32IDs.EncodeToString(b[:]), nil
+}
+
+func (Base32Generator) ValidID(id string) bool {
+	if len(id) != Base32GeneratorLength {
+		return false
+	}
+	for i := range len(id) {
+		if c := id[i]; !('a' <= c && c <= 'z' || '2' <= c && c <= '7') {
+			return false
+		}
+	}
+	return true
+}
+
+// newID returns an ID from m's generator for which taken is false, making
+// up to IDAttempts attempts.
+func (m *Manager) newID(taken func(id string) bool) (string, error) {
+	var id string
+	for range IDAttempts {
+		var err error
+		if id, err = m.idGen.NewID(); err != nil {
+			return "", fmt.Errorf("error generating id: %w", err)
+		}
+		if !taken(id) {
+			return id, nil
+		}
+	}
+	return "", &IDCollisionError{Attempts: IDAttempts, Last: id}
+}
+
+// idTaken reports whether a live or soft-deleted user has id. The caller
+// holds the lock.
+func (m *Manager) idTaken(id string) bool {
+	_, live := m.byID[id]
+	return live || m.deletedIDs[id]
+}
+
+// GetUserByID returns the live user with the given ID. An ID the
+// generator could not have produced fails with ErrInvalidID without
+// taking the lock.
+func (m *Manager) GetUserByID(ctx context.Context, id string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkID(m.idGen, id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.userByID(id)
+}
+
+// checkID fails with ErrInvalidID when gen validates IDs and could not have
+// produced id.
+func checkID(gen IDGenerator, id string) error {
+	if v, ok := gen.(IDValidator); ok && !v.ValidID(id) {
+		return fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	return nil
+}
+
+// userByID is GetUserByID without the checks. The caller holds the lock.
+func (m *Manager) userByID(id string) (*User, error) {
+	i, ok := m.byID[id]
+	m.metrics.Lookup(ok)
+	if !ok {
+		if m.deletedIDs[id] {
+			return nil, ErrUserDeleted
+		}
+		return nil, ErrNoResultFound
+	}
+	result := m.users[i]
+	return &result, nil
+}
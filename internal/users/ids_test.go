@@ -0,0 +1,271 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixedIDs hands out ids in order, failing once they run out.
+type fixedIDs struct {
+	ids []string
+}
+
+func (g *fixedIDs) NewID() (string, error) {
+	if len(g.ids) == 0 {
+		return "", errors.New("out of ids")
+	}
+	id := g.ids[0]
+	g.ids = g.ids[1:]
+	return id, nil
+}
+
+func TestAddUserIDCollision(t *testing.T) {
+	ctx := context.Background()
+	gen := &fixedIDs{ids: []string{"a", "a", "a", "b"}}
+	m := NewManager(WithIDGenerator(gen))
+
+	if err := m.AddUser(ctx, "Ada", "Lovelace", "ada@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	// Two collisions before a free id.
+	if err := m.AddUser(ctx, "Grace", "Hopper", "grace@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := m.GetUserByID(ctx, "b")
+	if err != nil || u.FirstName != "Grace" {
+		t.Errorf("expected Grace under the id after the collisions, got %+v, %v", u, err)
+	}
+
+	// A soft-deleted user keeps its id.
+	if err := m.DeleteUser(ctx, "Ada", "Lovelace"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetUserByID(ctx, "a"); !errors.Is(err, ErrUserDeleted) {
+		t.Errorf("expected ErrUserDeleted, got %v", err)
+	}
+	gen.ids = []string{"a", "b", "a", "b", "a"}
+	err = m.AddUser(ctx, "Alan", "Turing", "alan@bar.com")
+	var collision *IDCollisionError
+	if !errors.As(err, &collision) || !errors.Is(err, ErrIDCollision) || collision.Attempts != IDAttempts || collision.Last != "a" {
+		t.Errorf("expected an IDCollisionError after %d attempts, got %v", IDAttempts, err)
+	}
+	if _, err := m.GetUserByName(ctx, "Alan", "Turing"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("user added despite the collision: %v", err)
+	}
+
+	if err := m.AddUser(ctx, "Alan", "Turing", "alan@bar.com"); err == nil || !strings.Contains(err.Error(), "out of ids") {
+		t.Errorf("expected the generator's error, got %v", err)
+	}
+}
+
+func TestGetUserByID(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(WithIDGenerator(Base32Generator{}))
+	if err := m.AddUser(ctx, "Ada", "Lovelace", "ada@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	ada, err := m.GetUserByName(ctx, "Ada", "Lovelace")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := m.GetUserByID(ctx, ada.ID)
+	if err != nil || u.Email.Address != "ada@bar.com" {
+		t.Errorf("expected Ada, got %+v, %v", u, err)
+	}
+	if _, err := m.GetUserByID(ctx, strings.Repeat("a", Base32GeneratorLength)); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("expected ErrNoResultFound, got %v", err)
+	}
+	for _, bad := range []string{"", "ADA", strings.ToUpper(ada.ID), "0123456789abcdef", "123e4567-e89b-42d3-a456-426614174000"} {
+		if _, err := m.GetUserByID(ctx, bad); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("%q: expected ErrInvalidID, got %v", bad, err)
+		}
+	}
+
+	// A generator without a validator accepts any id.
+	m = NewManager(WithIDGenerator(&fixedIDs{ids: []string{"x"}}))
+	if _, err := m.GetUserByID(ctx, "any thing"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("expected ErrNoResultFound, got %v", err)
+	}
+}
+
+func TestIDGenerators(t *testing.T) {
+	generators := map[string]interface {
+		IDGenerator
+		IDValidator
+	}{
+		"uuidv4": UUIDv4Generator{},
+		"uuidv7": UUIDv7Generator{},
+		"base32": Base32Generator{},
+	}
+	invalid := map[string][]string{
+		"uuidv4": {
+			"",
+			"123e4567-e89b-72d3-a456-426614174000",  // version 7
+			"123e4567-e89b-42d3-c456-426614174000",  // variant
+			"123E4567-E89B-42D3-A456-426614174000",  // upper case
+			"123e4567e89b42d3a456426614174000",      // no hyphens
+			"123e4567-e89b-42d3-a456-42661417400g",  // not hex
+			"123e4567-e89b-42d3-a456-4266141740000", // too long
+		},
+		"uuidv7": {
+			"123e4567-e89b-42d3-a456-426614174000",
+			"0190f4e2-1c3a-7b2d-2f00-000000000000",
+		},
+		"base32": {
+			"",
+			"abcdefghijklmnop2",
+			"abcdefghijklmno",
+			"abcdefghijklmno1",
+			"ABCDEFGHIJKLMNOP",
+		},
+	}
+	for name, g := range generators {
+		t.Run(name, func(t *testing.T) {
+			seen := make(map[string]bool)
+			for range 100 {
+				id, err := g.NewID()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !g.ValidID(id) {
+					t.Errorf("generated id %q is not valid", id)
+				}
+				if seen[id] {
+					t.Errorf("id %q generated twice", id)
+				}
+				seen[id] = true
+			}
+			for _, id := range invalid[name] {
+				if g.ValidID(id) {
+					t.Errorf("%q accepted", id)
+				}
+			}
+		})
+	}
+
+	if !(UUIDv7Generator{}).ValidID("0190f4e2-1c3a-7b2d-8f00-000000000000") {
+		t.Error("valid UUIDv7 rejected")
+	}
+	if !(Base32Generator{}).ValidID("abcdefghijklmn27") {
+		t.Error("valid base32 id rejected")
+	}
+}
+
+func TestUUIDv7SortsByTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	g := UUIDv7Generator{Now: func() time.Time { return now }}
+	first, err := g.NewID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Millisecond)
+	second, err := g.NewID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(first < second) {
+		t.Errorf("expected %s < %s", first, second)
+	}
+	// 2026-03-01T12:00:00Z is 0x019ca9450a00 ms after the epoch.
+	if want := "019ca945-0a00-7"; !strings.HasPrefix(first, want) {
+		t.Errorf("expected the timestamp prefix %s, got %s", want, first)
+	}
+}
+
+// TestDeletedIDs checks that the tombstone ID index follows deletes,
+// restores, purges and snapshots.
+func TestDeletedIDs(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(WithIDGenerator(&fixedIDs{ids: []string{"a", "a"}}))
+	if err := m.AddUser(ctx, "Ada", "Lovelace", "ada@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	deleted := func(m *Manager) {
+		t.Helper()
+		if _, err := m.GetUserByID(ctx, "a"); !errors.Is(err, ErrUserDeleted) {
+			t.Errorf("expected ErrUserDeleted, got %v", err)
+		}
+	}
+
+	if err := m.DeleteUser(ctx, "Ada", "Lovelace"); err != nil {
+		t.Fatal(err)
+	}
+	deleted(m)
+
+	var buf bytes.Buffer
+	if err := m.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewManager(WithIDGenerator(&fixedIDs{}))
+	if err := restored.RestoreSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	deleted(restored)
+
+	if err := m.RestoreUser("Ada", "Lovelace"); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := m.GetUserByID(ctx, "a"); err != nil || u.FirstName != "Ada" {
+		t.Errorf("expected the restored user, got %+v, %v", u, err)
+	}
+
+	if err := m.DeleteUser(ctx, "Ada", "Lovelace"); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.PurgeDeleted(0); n != 1 {
+		t.Fatalf("expected 1 purged user, got %d", n)
+	}
+	if _, err := m.GetUserByID(ctx, "a"); !errors.Is(err, ErrNoResultFound) || errors.Is(err, ErrUserDeleted) {
+		t.Errorf("expected ErrNoResultFound for a purged user, got %v", err)
+	}
+	// A purged user's id is free again.
+	if err := m.AddUser(ctx, "Grace", "Hopper", "grace@bar.com"); err != nil {
+		t.Errorf("expected the purged id to be reusable, got %v", err)
+	}
+}
+
+func TestIDsPersist(t *testing.T) {
+	ctx := context.Background()
+	src := NewManager()
+	if err := src.AddUser(ctx, "Ada", "Lovelace", "ada@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	ada, _ := src.GetUserByName(ctx, "Ada", "Lovelace")
+
+	var buf bytes.Buffer
+	if err := src.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := NewManager()
+	if err := dst.RestoreSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := dst.GetUserByID(ctx, ada.ID); err != nil || u.Email.Address != "ada@bar.com" {
+		t.Errorf("id not kept by the snapshot: %+v, %v", u, err)
+	}
+
+	// Snapshots from before users had ids have none to keep.
+	old := NewManager(WithIDGenerator(&fixedIDs{ids: []string{"", ""}}))
+	if err := old.AddUser(ctx, "Ada", "Lovelace", "ada@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.AddUser(ctx, "Grace", "Hopper", "grace@bar.com"); err == nil {
+		t.Fatal("expected the second empty id to collide")
+	}
+	buf.Reset()
+	if err := old.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.RestoreSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	u, err := dst.GetUserByName(ctx, "Ada", "Lovelace")
+	if err != nil || !(UUIDv4Generator{}).ValidID(u.ID) {
+		t.Errorf("expected a new id for a user without one, got %+v, %v", u, err)
+	}
+}
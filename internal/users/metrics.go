@@ -0,0 +1,61 @@
+package users
+
+// Reasons passed to Metrics.AddFailed.
+const (
+	AddFailureInvalidName    = "invalid_name"
+	AddFailureInvalidEmail   = "invalid_email"
+	AddFailureDuplicateUser  = "duplicate_user"
+	AddFailureDuplicateEmail = "duplicate_email"
+	AddFailureID             = "id"
+)
+
+// AddFailureReasons lists every reason AddFailed may report, so a sink can
+// publish all of them from the start.
+var AddFailureReasons = []string{
+	AddFailureInvalidName,
+	AddFailureInvalidEmail,
+	AddFailureDuplicateUser,
+	AddFailureDuplicateEmail,
+	AddFailureID,
+}
+
+// Metrics receives counts of a Manager's operations, so the package does not
+// depend on any particular metrics library. The methods are called with the
+// Manager's lock held, in the order the operations take effect; they must be
+// quick and must not call back into the Manager.
+type Metrics interface {
+	// UserAdded is called for every user stored by AddUser,
+	// AddUserWithRole, GetOrCreateUser or a successful AddUsers.
+	UserAdded()
+
+	// AddFailed is called when an add is refused, with one of the
+	// AddFailure reasons.
+	AddFailed(reason string)
+
+	// Lookup is called by GetUserByName, GetUsersByName, GetUserByEmail
+	// and GetUserByID, reporting whether a live user was found.
+	Lookup(hit bool)
+
+	// UserDeleted is called for every user removed by DeleteUser.
+	UserDeleted()
+
+	// UsersTotal is called with the number of live users whenever it
+	// changes.
+	UsersTotal(n int)
+}
+
+// WithMetrics reports the Manager's operations to sink.
+func WithMetrics(sink Metrics) Option {
+	return func(m *Manager) {
+		m.metrics = sink
+	}
+}
+
+// nopMetrics is the sink of a Manager created without WithMetrics.
+type nopMetrics struct{}
+
+func (nopMetrics) UserAdded()       {}
+func (nopMetrics) AddFailed(string) {}
+func (nopMetrics) Lookup(bool)      {}
+func (nopMetrics) UserDeleted()     {}
+func (nopMetrics) UsersTotal(int)   {}
@@ -0,0 +1,94 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recordingMetrics records every call as a string, in order.
+type recordingMetrics struct {
+	calls []string
+}
+
+func (r *recordingMetrics) UserAdded()              { r.calls = append(r.calls, "added") }
+func (r *recordingMetrics) AddFailed(reason string) { r.calls = append(r.calls, "failed "+reason) }
+func (r *recordingMetrics) UserDeleted()            { r.calls = append(r.calls, "deleted") }
+func (r *recordingMetrics) UsersTotal(n int)        { r.calls = append(r.calls, fmt.Sprintf("total %d", n)) }
+func (r *recordingMetrics) Lookup(hit bool) {
+	if hit {
+		r.calls = append(r.calls, "hit")
+	} else {
+		r.calls = append(r.calls, "miss")
+	}
+}
+
+// take returns the calls recorded since the last take.
+func (r *recordingMetrics) take() []string {
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+func TestMetrics(t *testing.T) {
+	sink := &recordingMetrics{}
+	m := NewManager(WithMetrics(sink))
+
+	steps := []struct {
+		name string
+		op   func()
+		want []string
+	}{
+		{"add", func() { m.AddUser(context.Background(), "Alice", "Smith", "alice@example.com") }, []string{"added", "total 1"}},
+		{"add with role", func() { m.AddUserWithRole("Bob", "Jones", "bob@example.com", "admin") }, []string{"added", "total 2"}},
+		{"duplicate name", func() { m.AddUser(context.Background(), "Alice", "Smith", "other@example.com") }, []string{"failed duplicate_user"}},
+		{"duplicate email", func() { m.AddUser(context.Background(), "Carol", "White", "alice@example.com") }, []string{"failed duplicate_email"}},
+		{"invalid email", func() { m.AddUser(context.Background(), "Carol", "White", "not an email") }, []string{"failed invalid_email"}},
+		{"invalid name", func() { m.AddUser(context.Background(), "", "White", "carol@example.com") }, []string{"failed invalid_name"}},
+		{"get existing", func() { m.GetOrCreateUser(context.Background(), "Alice", "Smith", "alice@example.com") }, nil},
+		{"get or create", func() { m.GetOrCreateUser(context.Background(), "Carol", "White", "carol@example.com") }, []string{"added", "total 3"}},
+		{"lookup hit", func() { m.GetUserByName(context.Background(), "Alice", "Smith") }, []string{"hit"}},
+		{"lookup miss", func() { m.GetUserByName(context.Background(), "Dave", "Brown") }, []string{"miss"}},
+		{"email hit", func() { m.GetUserByEmail(context.Background(), "bob@example.com") }, []string{"hit"}},
+		{"email miss", func() { m.GetUserByEmail(context.Background(), "dave@example.com") }, []string{"miss"}},
+		{"bad email lookup", func() { m.GetUserByEmail(context.Background(), "nope") }, []string{"miss"}},
+		{"delete", func() { m.DeleteUser(context.Background(), "Bob", "Jones") }, []string{"deleted", "total 2"}},
+		{"delete missing", func() { m.DeleteUser(context.Background(), "Bob", "Jones") }, nil},
+		{"deleted lookup", func() { m.GetUserByName(context.Background(), "Bob", "Jones") }, []string{"miss"}},
+		{"restore", func() { m.RestoreUser("Bob", "Jones") }, []string{"total 3"}},
+		{"batch", func() {
+			m.AddUsers([]NewUser{
+				{FirstName: "Dave", LastName: "Brown", Email: "dave@example.com"},
+				{FirstName: "Erin", LastName: "Green", Email: "erin@example.com"},
+			})
+		}, []string{"added", "added", "total 5"}},
+		{"batch rolled back", func() {
+			m.AddUsers([]NewUser{
+				{FirstName: "Frank", LastName: "Black", Email: "frank@example.com"},
+				{FirstName: "Dave", LastName: "Brown", Email: "dave2@example.com"},
+			})
+		}, []string{"failed duplicate_user"}},
+		{"update", func() { m.UpdateUser(context.Background(), "Alice", "Smith", "alice@example.org", AnyVersion) }, nil},
+	}
+	for _, step := range steps {
+		step.op()
+		if got := sink.take(); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: expected %q, got %q", step.name, step.want, got)
+		}
+	}
+
+	var snapshot bytes.Buffer
+	if err := m.WriteSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	restoredSink := &recordingMetrics{}
+	restored := NewManager(WithMetrics(restoredSink))
+	if err := restored.RestoreSnapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := restoredSink.take(), []string{"total 5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("restore snapshot: expected %q, got %q", want, got)
+	}
+}
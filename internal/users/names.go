@@ -0,0 +1,81 @@
+package users
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxNameLength is the longest a first or last name may be, in runes after
+// NFC normalization.
+const MaxNameLength = 100
+
+// NameRules selects the checks ValidateName applies on top of those every
+// name gets: not empty once trimmed, at most MaxNameLength runes and free of
+// control characters. The zero value applies no others.
+type NameRules struct {
+	// RejectDigits rejects names containing a decimal digit in any script.
+	RejectDigits bool
+}
+
+// InvalidNameError is returned for a name that breaks the NameRules it is
+// checked against. Reasons lists every rule broken.
+type InvalidNameError struct {
+	Name    string
+	Reasons []string
+}
+
+func (e *InvalidNameError) Error() string {
+	return fmt.Sprintf("invalid name %q: %s", e.Name, strings.Join(e.Reasons, "; "))
+}
+
+// NormalizeName returns the form a name is stored and looked up in: trimmed
+// of surrounding whitespace and in Unicode normalization form C, so the
+// composed and decomposed spellings of "é" are the same name.
+func NormalizeName(name string) string {
+	return norm.NFC.String(strings.TrimSpace(name))
+}
+
+// ValidateName checks name against the zero NameRules and returns an
+// *InvalidNameError, or nil when it is valid.
+func ValidateName(name string) error {
+	return NameRules{}.Validate(name)
+}
+
+// Validate is ValidateName under r.
+func (r NameRules) Validate(name string) error {
+	var reasons []string
+	switch n := utf8.RuneCountInString(norm.NFC.String(name)); {
+	case strings.TrimSpace(name) == "":
+		reasons = append(reasons, "must not be empty")
+	case n > MaxNameLength:
+		reasons = append(reasons, fmt.Sprintf("must be at most %d characters, got %d", MaxNameLength, n))
+	}
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		reasons = append(reasons, "must not contain control characters")
+	}
+	if r.RejectDigits && strings.ContainsFunc(name, unicode.IsDigit) {
+		reasons = append(reasons, "must not contain digits")
+	}
+
+	if reasons != nil {
+		return &InvalidNameError{Name: name, Reasons: reasons}
+	}
+	return nil
+}
+
+// WithNameRules makes AddUser check names against rules; names are always
+// held to the checks of the zero NameRules.
+func WithNameRules(rules NameRules) Option {
+	return func(m *Manager) {
+		m.nameRules = rules
+	}
+}
+
+// NameRules returns the rules m checks new names against.
+func (m *Manager) NameRules() NameRules {
+	return m.nameRules
+}
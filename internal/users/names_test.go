@@ -0,0 +1,136 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	long := strings.Repeat("é", MaxNameLength)
+
+	tests := []struct {
+		name  string
+		rules NameRules
+		want  []string
+	}{
+		{"jhon", NameRules{}, nil},
+		{"Zoë 山田", NameRules{}, nil},
+		{"O'Brien-Smith", NameRules{}, nil},
+		{"  jhon  ", NameRules{}, nil},
+		{long, NameRules{}, nil},
+		// 100 decomposed "é" are 200 runes, but 100 once composed.
+		{strings.Repeat("é", MaxNameLength), NameRules{}, nil},
+		{"jhon3", NameRules{}, nil},
+
+		{"", NameRules{}, []string{"must not be empty"}},
+		{"   ", NameRules{}, []string{"must not be empty"}},
+		{long + "e", NameRules{}, []string{"must be at most 100 characters, got 101"}},
+		{"jh\x00on", NameRules{}, []string{"must not contain control characters"}},
+		{"jhon\n", NameRules{}, []string{"must not contain control characters"}},
+		{"\x1b[31mjhon", NameRules{}, []string{"must not contain control characters"}},
+		{"jhon3", NameRules{RejectDigits: true}, []string{"must not contain digits"}},
+		{"jhon٣", NameRules{RejectDigits: true}, []string{"must not contain digits"}},
+		{"\t", NameRules{RejectDigits: true}, []string{"must not be empty", "must not contain control characters"}},
+	}
+	for _, tt := range tests {
+		err := tt.rules.Validate(tt.name)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("Validate(%q): unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		var invalid *InvalidNameError
+		if !errors.As(err, &invalid) || invalid.Name != tt.name || !slices.Equal(invalid.Reasons, tt.want) {
+			t.Errorf("Validate(%q) = %v, want reasons %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		a string
+		b string
+	}{
+		{"José", "José"},
+		{"Ångström", "Ångström"},
+		{" jhon\t", "jhon"},
+	}
+	for _, tt := range tests {
+		if tt.a == tt.b {
+			t.Fatalf("%q and %q are the same bytes", tt.a, tt.b)
+		}
+		if NormalizeName(tt.a) != NormalizeName(tt.b) {
+			t.Errorf("%q and %q normalize differently: %q, %q", tt.a, tt.b, NormalizeName(tt.a), NormalizeName(tt.b))
+		}
+	}
+}
+
+func TestNamesNormalizedInLookups(t *testing.T) {
+	composed, decomposed := "José", "José"
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		stored string
+		lookup string
+	}{
+		{"composed stored, decomposed looked up", nil, composed, decomposed},
+		{"decomposed stored, composed looked up", nil, decomposed, composed},
+		{"case-insensitive", []Option{WithCaseInsensitiveNames()}, decomposed, "JOSÉ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(tt.opts...)
+			if err := m.AddUser(context.Background(), tt.stored, "garcía", "jose@bar.com"); err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := m.GetUserByName(context.Background(), tt.lookup, "garcía")
+			if err != nil {
+				t.Fatalf("lookup of %q: %v", tt.lookup, err)
+			}
+			if u.FirstName != NormalizeName(tt.stored) {
+				t.Errorf("expected the name stored composed, got %q", u.FirstName)
+			}
+			if err := m.AddUser(context.Background(), tt.lookup, "garcía", "other@bar.com"); !errors.Is(err, ErrDuplicateUser) {
+				t.Errorf("expected ErrDuplicateUser for the other spelling, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAddUserNameRules(t *testing.T) {
+	m := NewManager(WithNameRules(NameRules{RejectDigits: true}))
+
+	var invalid *InvalidNameError
+	err := m.AddUser(context.Background(), "jhon", "smith2", "foo@bar.com")
+	if !errors.As(err, &invalid) || !strings.HasPrefix(err.Error(), "last name: ") {
+		t.Errorf("expected *InvalidNameError for the last name, got %v", err)
+	}
+	if err := m.AddUser(context.Background(), "jhon", "smith", "foo@bar.com"); err != nil {
+		t.Errorf("valid name rejected: %v", err)
+	}
+	if m.NameRules() != (NameRules{RejectDigits: true}) {
+		t.Errorf("unexpected rules %+v", m.NameRules())
+	}
+}
+
+func TestValidateUserNames(t *testing.T) {
+	err := NameRules{RejectDigits: true}.ValidateUser("jh\non3", " ", "jhon@bar.com")
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	expected := ValidationErrors{
+		{Field: "first_name", Message: "must not contain control characters"},
+		{Field: "first_name", Message: "must not contain digits"},
+		{Field: "last_name", Message: "must not be empty"},
+	}
+	if !slices.Equal(verrs, expected) {
+		t.Errorf("bad validation errors: expected %v, got %v", expected, verrs)
+	}
+}
@@ -0,0 +1,76 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the minimum password length, in bytes, accepted by
+// SetPassword. bcrypt itself rejects passwords longer than 72 bytes.
+const MinPasswordLength = 8
+
+var ErrPasswordTooShort = fmt.Errorf("password must be at least %d bytes", MinPasswordLength)
+
+// SetPassword hashes plaintext with bcrypt and stores it on the named user,
+// bumping its UpdatedAt timestamp. Hashing is done before the lock is taken.
+func (m *Manager) SetPassword(first string, last string, plaintext string) error {
+	if len(plaintext) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, n := m.nameIndex(first, last)
+	if n == 0 {
+		return ErrNoResultFound
+	}
+	if n > 1 {
+		return ErrAmbiguousName
+	}
+
+	m.users[i].passwordHash = string(hash)
+	m.users[i].UpdatedAt = m.now()
+	m.users[i].Version++
+	m.rev++
+
+	return nil
+}
+
+// CheckPassword reports whether plaintext matches the named user's password.
+// A user without a password never matches.
+func (m *Manager) CheckPassword(first string, last string, plaintext string) (bool, error) {
+	m.mu.RLock()
+	i, n := m.nameIndex(first, last)
+	var hash string
+	if n == 1 {
+		hash = m.users[i].passwordHash
+	}
+	m.mu.RUnlock()
+
+	if n == 0 {
+		return false, ErrNoResultFound
+	}
+	if n > 1 {
+		return false, ErrAmbiguousName
+	}
+	if hash == "" {
+		return false, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(plaintext))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking password: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,310 @@
+package users
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrCorruptSnapshot = errors.New("corrupt snapshot")
+
+// snapshotMagic starts every snapshot, followed by the gob payload and a
+// big-endian CRC-32 of the payload.
+var snapshotMagic = []byte("USRS1\n")
+
+// snapshotUser is the on-disk form of a User. Unlike the JSON and CSV
+// exports it keeps the password hash, so a restore loses nothing.
+type snapshotUser struct {
+	// ID is empty in snapshots written before users had IDs.
+	ID           string
+	FirstName    string
+	LastName     string
+	Email        string
+	EmailName    string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	PasswordHash string
+	Role         string
+	Version      uint64
+	Verified     bool
+	DeletedAt    time.Time
+
+	GreetCount    int
+	LastGreetedAt time.Time
+
+	Tags []string
+}
+
+type snapshotFile struct {
+	Users []snapshotUser
+
+	// Deleted holds the soft-deleted users, so they stay restorable across
+	// restarts.
+	Deleted []snapshotUser
+}
+
+func newSnapshotUser(u User) snapshotUser {
+	su := snapshotUser{
+		ID:           u.ID,
+		FirstName:    u.FirstName,
+		LastName:     u.LastName,
+		Email:        u.Email.Address,
+		EmailName:    u.Email.Name,
+		CreatedAt:    u.CreatedAt,
+		UpdatedAt:    u.UpdatedAt,
+		PasswordHash: u.passwordHash,
+		Role:         string(u.Role),
+		Version:      u.Version,
+		Verified:     u.Verified,
+
+		GreetCount:    u.GreetCount,
+		LastGreetedAt: u.LastGreetedAt,
+
+		Tags: u.Tags,
+	}
+	if u.DeletedAt != nil {
+		su.DeletedAt = *u.DeletedAt
+	}
+	return su
+}
+
+// user converts su back, failing on fields no User could have held.
+func (su snapshotUser) user() (User, error) {
+	address, err := mail.ParseAddress(su.Email)
+	if err != nil {
+		return User{}, fmt.Errorf("invalid email %q", su.Email)
+	}
+	address.Name = su.EmailName
+	// Snapshots written before roles existed have no role; ParseRole
+	// defaults those to RoleMember.
+	role, err := ParseRole(su.Role)
+	if err != nil {
+		return User{}, err
+	}
+	tags, err := normalizeTags(su.Tags)
+	if err != nil {
+		return User{}, err
+	}
+	u := User{
+		ID:           su.ID,
+		FirstName:    su.FirstName,
+		LastName:     su.LastName,
+		Email:        *address,
+		CreatedAt:    su.CreatedAt,
+		UpdatedAt:    su.UpdatedAt,
+		Role:         role,
+		Version:      max(su.Version, 1),
+		Verified:     su.Verified,
+		passwordHash: su.PasswordHash,
+
+		GreetCount:    su.GreetCount,
+		LastGreetedAt: su.LastGreetedAt,
+
+		Tags: tags,
+	}
+	if !su.DeletedAt.IsZero() {
+		deletedAt := su.DeletedAt
+		u.DeletedAt = &deletedAt
+	}
+	return u, nil
+}
+
+// WriteSnapshot writes every user, soft-deleted ones included, to w in the
+// snapshot format read by RestoreSnapshot. Users are copied under the read
+// lock and encoded after it is released, so slow writers do not block
+// AddUser.
+func (m *Manager) WriteSnapshot(w io.Writer) error {
+	m.mu.RLock()
+	all := make([]User, len(m.users))
+	copy(all, m.users)
+	deleted := make([]User, len(m.deleted))
+	copy(deleted, m.deleted)
+	m.mu.RUnlock()
+
+	file := snapshotFile{
+		Users:   make([]snapshotUser, len(all)),
+		Deleted: make([]snapshotUser, len(deleted)),
+	}
+	for i, u := range all {
+		file.Users[i] = newSnapshotUser(u)
+	}
+	for i, u := range deleted {
+		file.Deleted[i] = newSnapshotUser(u)
+	}
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(file); err != nil {
+		return fmt.Errorf("error encoding snapshot: %w", err)
+	}
+
+	var trailer [4]byte
+	binary.BigEndian.PutUint32(trailer[:], crc32.ChecksumIEEE(payload.Bytes()))
+
+	for _, part := range [][]byte{snapshotMagic, payload.Bytes(), trailer[:]} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreSnapshot replaces every user with the contents of a snapshot
+// written by WriteSnapshot. A snapshot that fails its checksum, cannot be
+// decoded or holds conflicting users is rejected with ErrCorruptSnapshot and
+// the manager is left unchanged. Users from a snapshot written before users
+// had IDs are given new ones.
+func (m *Manager) RestoreSnapshot(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.HasPrefix(data, snapshotMagic) || len(data) < len(snapshotMagic)+4 {
+		return fmt.Errorf("%w: bad header", ErrCorruptSnapshot)
+	}
+	payload := data[len(snapshotMagic) : len(data)-4]
+	if binary.BigEndian.Uint32(data[len(data)-4:]) != crc32.ChecksumIEEE(payload) {
+		return fmt.Errorf("%w: checksum mismatch", ErrCorruptSnapshot)
+	}
+
+	var file snapshotFile
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&file); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+
+	restored := make([]User, len(file.Users))
+	for i, su := range file.Users {
+		u, err := su.user()
+		if err != nil {
+			return fmt.Errorf("%w: user %d: %v", ErrCorruptSnapshot, i, err)
+		}
+		if u.DeletedAt != nil {
+			return fmt.Errorf("%w: user %d: deleted user among live users", ErrCorruptSnapshot, i)
+		}
+		restored[i] = u
+	}
+	deleted := make([]User, len(file.Deleted))
+	for i, su := range file.Deleted {
+		u, err := su.user()
+		if err != nil {
+			return fmt.Errorf("%w: deleted user %d: %v", ErrCorruptSnapshot, i, err)
+		}
+		if u.DeletedAt == nil {
+			return fmt.Errorf("%w: deleted user %d: no deletion time", ErrCorruptSnapshot, i)
+		}
+		deleted[i] = u
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byName := make(map[fullName][]int, len(restored))
+	byEmail := make(map[string]int, len(restored))
+	byID := make(map[string]int, len(restored))
+	ids := make(map[string]bool, len(restored)+len(deleted))
+	for _, users := range [][]User{restored, deleted} {
+		for _, u := range users {
+			if u.ID == "" {
+				continue
+			}
+			if ids[u.ID] {
+				return fmt.Errorf("%w: duplicate id %s", ErrCorruptSnapshot, u.ID)
+			}
+			ids[u.ID] = true
+		}
+	}
+	// Users saved before they had IDs are given one.
+	for _, users := range [][]User{restored, deleted} {
+		for i := range users {
+			if users[i].ID != "" {
+				continue
+			}
+			id, err := m.newID(func(id string) bool { return ids[id] })
+			if err != nil {
+				return err
+			}
+			users[i].ID = id
+			ids[id] = true
+		}
+	}
+
+	seqs := make([]uint64, len(restored))
+	nextSeq := m.nextSeq
+	for i, u := range restored {
+		nameKey := m.nameKey(u.FirstName, u.LastName)
+		if _, ok := byName[nameKey]; ok && !m.allowsDuplicateNames() {
+			return fmt.Errorf("%w: duplicate user %s %s", ErrCorruptSnapshot, u.FirstName, u.LastName)
+		}
+		if _, ok := byEmail[m.emailKey(u.Email.Address)]; ok {
+			return fmt.Errorf("%w: duplicate email %s", ErrCorruptSnapshot, u.Email.Address)
+		}
+		byName[nameKey] = append(byName[nameKey], i)
+		byEmail[m.emailKey(u.Email.Address)] = i
+		byID[u.ID] = i
+		nextSeq++
+		seqs[i] = nextSeq
+	}
+
+	m.users = restored
+	m.seqs = seqs
+	m.nextSeq = nextSeq
+	m.byName = byName
+	m.byEmail = byEmail
+	m.byID = byID
+	m.deleted = deleted
+	m.deletedIDs = make(map[string]bool, len(deleted))
+	for _, u := range deleted {
+		m.deletedIDs[u.ID] = true
+	}
+	// Sequence numbers were reassigned, so pending tokens no longer name
+	// the right users.
+	clear(m.tokens)
+	clear(m.tokenBySeq)
+	m.rev++
+	m.metrics.UsersTotal(len(m.users))
+
+	return nil
+}
+
+// SaveSnapshotFile writes a snapshot to a temporary file next to path and
+// renames it into place, so path always holds a complete snapshot.
+func (m *Manager) SaveSnapshotFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := m.WriteSnapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshotFile restores the snapshot at path. A missing file is
+// reported with an error matching os.ErrNotExist.
+func (m *Manager) LoadSnapshotFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return m.RestoreSnapshot(f)
+}
@@ -0,0 +1,168 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.snapshot")
+
+	before := NewManager()
+	for i := range 3 {
+		if err := before.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := before.SetPassword("first1", "last", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := before.SaveSnapshotFile(path); err != nil {
+		t.Fatal("error saving snapshot:", err)
+	}
+
+	// A new process starts from an empty manager and restores the file.
+	after := NewManager()
+	if err := after.LoadSnapshotFile(path); err != nil {
+		t.Fatal("error restoring snapshot:", err)
+	}
+
+	want, got := before.GetAllUsers(), after.GetAllUsers()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d users, got %d", len(want), len(got))
+	}
+	for i := range want {
+		w, g := want[i], got[i]
+		if g.FirstName != w.FirstName || g.LastName != w.LastName || g.Email != w.Email ||
+			!g.CreatedAt.Equal(w.CreatedAt) || !g.UpdatedAt.Equal(w.UpdatedAt) || g.passwordHash != w.passwordHash {
+			t.Errorf("user %d: expected %+v, got %+v", i, w, g)
+		}
+	}
+
+	if ok, err := after.CheckPassword("first1", "last", "correct horse"); !ok || err != nil {
+		t.Errorf("password not restored: %v %v", ok, err)
+	}
+
+	if err := before.DeleteUser(context.Background(), "first2", "last"); err != nil {
+		t.Fatal(err)
+	}
+	if err := before.SaveSnapshotFile(path); err != nil {
+		t.Fatal("error saving snapshot:", err)
+	}
+	after = NewManager()
+	if err := after.LoadSnapshotFile(path); err != nil {
+		t.Fatal("error restoring snapshot:", err)
+	}
+	if deleted := after.DeletedUsers(); len(deleted) != 1 || deleted[0].DeletedAt == nil || !deleted[0].DeletedAt.Equal(*before.DeletedUsers()[0].DeletedAt) {
+		t.Errorf("deleted user not restored: %+v", deleted)
+	}
+	if err := after.RestoreUser("first2", "last"); err != nil {
+		t.Errorf("error restoring deleted user after restart: %v", err)
+	}
+	if _, err := after.GetUserByEmail(context.Background(), "user2@bar.com"); err != nil {
+		t.Errorf("email index not rebuilt: %v", err)
+	}
+	if err := after.AddUser(context.Background(), "first0", "last", "new@bar.com"); !errors.Is(err, ErrDuplicateUser) {
+		t.Errorf("name index not rebuilt: expected ErrDuplicateUser, got %v", err)
+	}
+
+	if err := NewManager().LoadSnapshotFile(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist for a missing snapshot, got %v", err)
+	}
+}
+
+func TestSnapshotCorrupted(t *testing.T) {
+	testManager := NewManager()
+	if err := testManager.AddUser(context.Background(), "jhon", "smith", "foo@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := testManager.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	flipped := bytes.Clone(good)
+	flipped[len(flipped)/2] ^= 0xff
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"truncated": good[:len(good)-10],
+		"flipped":   flipped,
+		"garbage":   []byte("not a snapshot at all"),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			target := NewManager()
+			if err := target.AddUser(context.Background(), "keep", "me", "keep@bar.com"); err != nil {
+				t.Fatal(err)
+			}
+
+			err := target.RestoreSnapshot(bytes.NewReader(data))
+			if !errors.Is(err, ErrCorruptSnapshot) {
+				t.Errorf("expected ErrCorruptSnapshot, got %v", err)
+			}
+			if _, err := target.GetUserByName(context.Background(), "keep", "me"); err != nil {
+				t.Error("failed restore changed the manager")
+			}
+		})
+	}
+}
+
+func TestSnapshotAutosaveRace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.snapshot")
+	testManager := NewManager()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := testManager.SaveSnapshotFile(path); err != nil {
+				t.Error("error saving snapshot:", err)
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	for i := range 500 {
+		if err := testManager.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	t.Logf("500 adds alongside autosave took %v", time.Since(start))
+
+	if err := testManager.SaveSnapshotFile(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewManager()
+	if err := restored.LoadSnapshotFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(restored.GetAllUsers()); n != 500 {
+		t.Errorf("expected 500 users after final save, got %d", n)
+	}
+
+	leftovers, _ := filepath.Glob(path + ".tmp-*")
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
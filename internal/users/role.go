@@ -0,0 +1,90 @@
+package users
+
+import "fmt"
+
+// Role controls what a user may do. The zero value is not a valid role;
+// users added without one get RoleMember.
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+)
+
+// InvalidRoleError is returned when a role string is not one of the known
+// roles.
+type InvalidRoleError struct {
+	Role string
+}
+
+func (e *InvalidRoleError) Error() string {
+	return fmt.Sprintf("invalid role: %q", e.Role)
+}
+
+// ParseRole validates role. An empty string selects RoleMember.
+func ParseRole(role string) (Role, error) {
+	switch Role(role) {
+	case "":
+		return RoleMember, nil
+	case RoleAdmin, RoleMember:
+		return Role(role), nil
+	}
+	return "", &InvalidRoleError{Role: role}
+}
+
+// AddUserWithRole is AddUser for a user with the given role. An invalid role
+// is rejected with *InvalidRoleError before anything is stored.
+func (m *Manager) AddUserWithRole(firstName string, lastName string, email string, role string) error {
+	r, err := ParseRole(role)
+	if err != nil {
+		return err
+	}
+	_, _, err = m.add("", firstName, lastName, email, r, false)
+	return err
+}
+
+// SetRole changes the role of the named user and bumps its UpdatedAt
+// timestamp.
+func (m *Manager) SetRole(first string, last string, role string) error {
+	r, err := ParseRole(role)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, n := m.nameIndex(first, last)
+	if n == 0 {
+		return ErrNoResultFound
+	}
+	if n > 1 {
+		return ErrAmbiguousName
+	}
+
+	m.users[i].Role = r
+	m.users[i].UpdatedAt = m.now()
+	m.users[i].Version++
+	m.rev++
+
+	return nil
+}
+
+// GetUsersByRole returns every user with the given role in insertion order.
+func (m *Manager) GetUsersByRole(role string) ([]User, error) {
+	r, err := ParseRole(role)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []User
+	for _, u := range m.users {
+		if u.Role == r {
+			result = append(result, u)
+		}
+	}
+	return result, nil
+}
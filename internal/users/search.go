@@ -0,0 +1,105 @@
+package users
+
+import (
+	"context"
+	"iter"
+	"strings"
+)
+
+// SearchOptions tunes Search.
+type SearchOptions struct {
+	// Fuzzy also matches names whose prefix is one edit away from the query,
+	// counting a swap of adjacent letters as one edit, so "jhon" finds
+	// "john".
+	Fuzzy bool
+
+	// Verified, when set, keeps only users whose Verified field matches.
+	Verified *bool
+
+	// Limit caps the number of results. Zero means no limit.
+	Limit int
+}
+
+// Search returns the users whose first or last name starts with q, ignoring
+// case, in insertion order. An empty q matches nobody.
+func (m *Manager) Search(q string, opts SearchOptions) []User {
+	return search(m.All(), q, opts)
+}
+
+// Search is Manager.Search for any Store, whose users it reads through All.
+func Search(ctx context.Context, s Store, q string, opts SearchOptions) ([]User, error) {
+	var err error
+	found := search(untilError(All(ctx, s), &err), q, opts)
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+func search(all iter.Seq[User], q string, opts SearchOptions) []User {
+	query := []rune(strings.ToLower(strings.TrimSpace(q)))
+	if len(query) == 0 {
+		return nil
+	}
+
+	var result []User
+	for u := range all {
+		if !nameMatches(query, u.FirstName, opts.Fuzzy) && !nameMatches(query, u.LastName, opts.Fuzzy) {
+			continue
+		}
+		if opts.Verified != nil && u.Verified != *opts.Verified {
+			continue
+		}
+		result = append(result, u)
+		if opts.Limit > 0 && len(result) == opts.Limit {
+			break
+		}
+	}
+	return result
+}
+
+func nameMatches(query []rune, name string, fuzzy bool) bool {
+	lower := []rune(strings.ToLower(name))
+	if len(lower) >= len(query) && string(lower[:len(query)]) == string(query) {
+		return true
+	}
+	if !fuzzy {
+		return false
+	}
+
+	// An edit can make the matching prefix one rune longer or shorter than
+	// the query, so try all three lengths.
+	for n := len(query) - 1; n <= len(query)+1; n++ {
+		if n > 0 && n <= len(lower) && withinOneEdit(query, lower[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinOneEdit reports whether a and b differ by at most one insertion,
+// deletion, substitution or transposition of adjacent runes.
+func withinOneEdit(a []rune, b []rune) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > 1 {
+		return false
+	}
+
+	i := 0
+	for i < len(a) && a[i] == b[i] {
+		i++
+	}
+	if i == len(a) {
+		return true
+	}
+
+	if len(a) == len(b) {
+		if string(a[i+1:]) == string(b[i+1:]) {
+			return true
+		}
+		return i+1 < len(a) && a[i] == b[i+1] && a[i+1] == b[i] && string(a[i+2:]) == string(b[i+2:])
+	}
+	return string(a[i:]) == string(b[i+1:])
+}
@@ -0,0 +1,120 @@
+package users
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// SortBy is an ordering for SortedUsers. Every ordering breaks ties by
+// insertion order, which no two users share, so it is total and a page
+// taken from it is stable across deletes.
+type SortBy string
+
+const (
+	SortByInsertion SortBy = ""
+	SortByFirstName SortBy = "first_name"
+	SortByLastName  SortBy = "last_name"
+	SortByEmail     SortBy = "email"
+	SortByCreatedAt SortBy = "created_at"
+)
+
+// legacySortBy maps the camelCase names the sort query parameter took
+// before the API moved to snake_case. They are deprecated and will be
+// dropped once clients have moved.
+var legacySortBy = map[string]SortBy{
+	"firstName": SortByFirstName,
+	"lastName":  SortByLastName,
+	"createdAt": SortByCreatedAt,
+}
+
+// ParseSortBy returns the SortBy named s, as used in the sort query
+// parameter. The deprecated camelCase names are accepted too.
+func ParseSortBy(s string) (SortBy, error) {
+	switch by := SortBy(s); by {
+	case SortByInsertion, SortByFirstName, SortByLastName, SortByEmail, SortByCreatedAt:
+		return by, nil
+	}
+	if by, ok := legacySortBy[s]; ok {
+		return by, nil
+	}
+	return "", fmt.Errorf("invalid sort %q: must be first_name, last_name, email or created_at", s)
+}
+
+// WithCollation orders names in SortedUsers by the collation rules of tag,
+// so accented and language-specific letters sort where a reader of that
+// language expects them. Without it names are compared byte by byte.
+func WithCollation(tag language.Tag) Option {
+	return func(m *Manager) {
+		m.collation = &tag
+	}
+}
+
+// createdAtKeyLayout formats CreatedAt as a sort key. It has a fixed width,
+// so keys in UTC compare as strings the way the times do.
+const createdAtKeyLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// sortKey returns the key by orders users on and how two keys compare, or
+// nil functions for insertion order. Names are compared by the rules of
+// collation, or byte by byte when it is nil.
+func sortKey(by SortBy, collation *language.Tag) (key func(u User) string, compare func(a string, b string) int) {
+	// A Collator is not safe for concurrent use, so each call gets its own.
+	compareNames := strings.Compare
+	if collation != nil {
+		compareNames = collate.New(*collation).CompareString
+	}
+
+	switch by {
+	case SortByFirstName:
+		return func(u User) string { return u.FirstName }, compareNames
+	case SortByLastName:
+		return func(u User) string { return u.LastName }, compareNames
+	case SortByEmail:
+		return func(u User) string { return u.Email.Address }, strings.Compare
+	case SortByCreatedAt:
+		return func(u User) string { return u.CreatedAt.UTC().Format(createdAtKeyLayout) }, strings.Compare
+	}
+	return nil, nil
+}
+
+// SortedUsers returns the live users ordered by by.
+func (m *Manager) SortedUsers(by SortBy) []User {
+	m.mu.RLock()
+	users := slices.Clone(m.users)
+	seqs := slices.Clone(m.seqs)
+	m.mu.RUnlock()
+
+	return sortUsers(by, m.collation, users, seqs)
+}
+
+// sortUsers orders users, and seqs with them, by by in place and returns
+// users. collation is as for sortKey.
+func sortUsers(by SortBy, collation *language.Tag, users []User, seqs []uint64) []User {
+	key, compare := sortKey(by, collation)
+	if key == nil {
+		return users
+	}
+
+	keys := make([]string, len(users))
+	order := make([]int, len(users))
+	for i, u := range users {
+		keys[i] = key(u)
+		order[i] = i
+	}
+	slices.SortFunc(order, func(i, j int) int {
+		return cmp.Or(compare(keys[i], keys[j]), cmp.Compare(seqs[i], seqs[j]))
+	})
+
+	sortedUsers := make([]User, len(users))
+	sortedSeqs := make([]uint64, len(users))
+	for k, i := range order {
+		sortedUsers[k], sortedSeqs[k] = users[i], seqs[i]
+	}
+	copy(users, sortedUsers)
+	copy(seqs, sortedSeqs)
+	return users
+}
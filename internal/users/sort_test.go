@@ -0,0 +1,110 @@
+package users
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// accentedNames are added in this order, with these first names and a
+// shared last name.
+var accentedNames = []string{"Zoe", "Ölsen", "Olsen", "Åse", "Anders", "émile", "Eve", "Øystein"}
+
+func addAccented(t *testing.T, m *Manager) {
+	t.Helper()
+	for i, name := range accentedNames {
+		if err := m.AddUser(context.Background(), name, "smith", "user"+string(rune('a'+i))+"@bar.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func firstNames(users []User) []string {
+	names := make([]string, len(users))
+	for i, u := range users {
+		names[i] = u.FirstName
+	}
+	return names
+}
+
+func TestSortedUsersCollation(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"byte order", nil, []string{"Anders", "Eve", "Olsen", "Zoe", "Åse", "Ölsen", "Øystein", "émile"}},
+		{"english", []Option{WithCollation(language.English)}, []string{"Anders", "Åse", "émile", "Eve", "Olsen", "Ölsen", "Øystein", "Zoe"}},
+		{"danish", []Option{WithCollation(language.Danish)}, []string{"Anders", "émile", "Eve", "Olsen", "Zoe", "Ölsen", "Øystein", "Åse"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(tt.opts...)
+			addAccented(t, m)
+			if got := firstNames(m.SortedUsers(SortByFirstName)); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSortedUsersTiebreak(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManager(WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+	for _, u := range []NewUser{
+		{FirstName: "carol", LastName: "smith", Email: "c@bar.com"},
+		{FirstName: "alice", LastName: "jones", Email: "b@bar.com"},
+		{FirstName: "bob", LastName: "smith", Email: "a@bar.com"},
+	} {
+		if err := m.AddUser(ctx, u.FirstName, u.LastName, u.Email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Re-adding a deleted user puts it last among equals.
+	if err := m.DeleteUser(ctx, "carol", "smith"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(-time.Hour)
+	if err := m.AddUser(ctx, "carol", "smith", "c@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		by   SortBy
+		want []string
+	}{
+		{SortByInsertion, []string{"alice", "bob", "carol"}},
+		{SortByFirstName, []string{"alice", "bob", "carol"}},
+		{SortByLastName, []string{"alice", "bob", "carol"}},
+		{SortByEmail, []string{"bob", "alice", "carol"}},
+		{SortByCreatedAt, []string{"carol", "alice", "bob"}},
+	}
+	for _, tt := range tests {
+		if got := firstNames(m.SortedUsers(tt.by)); !slices.Equal(got, tt.want) {
+			t.Errorf("sort %q: expected %q, got %q", tt.by, tt.want, got)
+		}
+	}
+}
+
+func TestParseSortBy(t *testing.T) {
+	for _, s := range []string{"", "first_name", "last_name", "email", "created_at"} {
+		if by, err := ParseSortBy(s); err != nil || string(by) != s {
+			t.Errorf("ParseSortBy(%q): got %q, %v", s, by, err)
+		}
+	}
+	for s, expected := range map[string]SortBy{"firstName": SortByFirstName, "lastName": SortByLastName, "createdAt": SortByCreatedAt} {
+		if by, err := ParseSortBy(s); err != nil || by != expected {
+			t.Errorf("ParseSortBy(%q): expected %q, got %q, %v", s, expected, by, err)
+		}
+	}
+	for _, s := range []string{"FirstName", "name", "-email", "first-name"} {
+		if _, err := ParseSortBy(s); err == nil {
+			t.Errorf("ParseSortBy(%q): expected an error", s)
+		}
+	}
+}
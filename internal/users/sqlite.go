@@ -0,0 +1,377 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	// The pure Go SQLite driver, registered as "sqlite".
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDriver is the database/sql driver name OpenSQLite uses.
+const sqliteDriver = "sqlite"
+
+// sqliteMigrations are applied in order; PRAGMA user_version records how
+// many have run.
+var sqliteMigrations = []string{
+	`CREATE TABLE users (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		first_name TEXT NOT NULL,
+		last_name  TEXT NOT NULL,
+		email      TEXT NOT NULL UNIQUE,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		UNIQUE (first_name, last_name)
+	)`,
+	// Rows from before versions existed start at the Version a new user
+	// gets.
+	`ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	// revision.n is the store's Revision, bumped by every change to users.
+	`CREATE TABLE revision (n INTEGER NOT NULL);
+	INSERT INTO revision (n) VALUES (0);
+	CREATE TRIGGER users_inserted AFTER INSERT ON users BEGIN UPDATE revision SET n = n + 1; END;
+	CREATE TRIGGER users_updated AFTER UPDATE ON users BEGIN UPDATE revision SET n = n + 1; END;
+	CREATE TRIGGER users_deleted AFTER DELETE ON users BEGIN UPDATE revision SET n = n + 1; END`,
+	// Existing rows get random version 4 UUIDs, the IDs UUIDv4Generator
+	// makes.
+	`ALTER TABLE users ADD COLUMN id TEXT;
+	UPDATE users SET id = lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+		substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) ||
+		substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6)));
+	CREATE UNIQUE INDEX users_id ON users (id)`,
+}
+
+// SQLiteStore is a Store kept in a SQLite database. Names are checked with
+// ValidateName and normalized with NormalizeName, then matched case
+// sensitively and sorted byte by byte, like a Manager created without
+// WithCaseInsensitiveNames or WithCollation. Users get UUIDv4Generator IDs.
+type SQLiteStore struct {
+	db *sql.DB
+	// q runs the queries: db, or the *sql.Tx of the transaction this store
+	// belongs to.
+	q     querier
+	now   func() time.Time
+	idGen IDGenerator
+}
+
+// querier is the part of *sql.DB and *sql.Tx the Store methods use.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// OpenSQLite opens or creates the database at path and migrates it to the
+// current schema.
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite database: %w", err)
+	}
+
+	// An in-memory database exists per connection, and SQLite serializes
+	// writers anyway.
+	db.SetMaxOpenConns(1)
+
+	s, err := NewSQLiteStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewSQLiteStore wraps an open SQLite database and migrates it.
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	s := &SQLiteStore{db: db, q: db, now: time.Now, idGen: UUIDv4Generator{}}
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLiteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error applying migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters.
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error recording migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transact runs fn inside a SQLite transaction; see Transactor. The
+// transaction holds the store's only connection until it ends.
+func (s *SQLiteStore) Transact(fn func(tx *Tx) error) error {
+	if s.q != s.db {
+		return ErrNestedTransaction
+	}
+
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	store := &SQLiteStore{db: s.db, q: sqlTx, now: s.now, idGen: s.idGen}
+	return runTx(&Tx{store: store}, fn,
+		sqlTx.Commit,
+		func() { sqlTx.Rollback() },
+	)
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// uniqueViolation maps a UNIQUE constraint failure to the Store error for
+// the violated column. The message format is shared by the SQLite drivers.
+func uniqueViolation(err error) error {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "UNIQUE constraint failed: users.email"):
+		return ErrDuplicateEmail
+	case strings.Contains(msg, "UNIQUE constraint failed: users.first_name, users.last_name"):
+		return ErrDuplicateUser
+	case strings.Contains(msg, "UNIQUE constraint failed: users.id"):
+		return ErrIDCollision
+	}
+	return err
+}
+
+func (s *SQLiteStore) AddUser(ctx context.Context, firstName string, lastName string, email string) error {
+	if err := ValidateName(firstName); err != nil {
+		return fmt.Errorf("first name: %w", err)
+	}
+	if err := ValidateName(lastName); err != nil {
+		return fmt.Errorf("last name: %w", err)
+	}
+	parsedAddress, err := mail.ParseAddress(email)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidEmail, email)
+	}
+
+	id, err := s.idGen.NewID()
+	if err != nil {
+		return fmt.Errorf("error generating id: %w", err)
+	}
+	now := s.now().UTC().Format(time.RFC3339Nano)
+	_, err = s.q.ExecContext(ctx,
+		`INSERT INTO users (id, first_name, last_name, email, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		id, NormalizeName(firstName), NormalizeName(lastName), parsedAddress.Address, now, now,
+	)
+	if err != nil {
+		return uniqueViolation(err)
+	}
+	return nil
+}
+
+// userColumns are the columns scanUser reads, in its order.
+const userColumns = `id, first_name, last_name, email, created_at, updated_at, version`
+
+const selectUsers = `SELECT ` + userColumns + ` FROM users`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser reads a row that starts with userColumns. The columns after them
+// are scanned into extra.
+func scanUser(row scanner, extra ...any) (User, error) {
+	var user User
+	var email, createdAt, updatedAt string
+	dest := append([]any{&user.ID, &user.FirstName, &user.LastName, &email, &createdAt, &updatedAt, &user.Version}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return User{}, err
+	}
+
+	user.Email = mail.Address{Address: email}
+	user.Role = RoleMember
+	var err error
+	if user.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return User{}, fmt.Errorf("invalid created_at %q: %w", createdAt, err)
+	}
+	if user.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+		return User{}, fmt.Errorf("invalid updated_at %q: %w", updatedAt, err)
+	}
+	return user, nil
+}
+
+func (s *SQLiteStore) getOne(ctx context.Context, query string, args ...any) (*User, error) {
+	user, err := scanUser(s.q.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoResultFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *SQLiteStore) GetUserByName(ctx context.Context, first string, last string) (*User, error) {
+	return s.getOne(ctx, selectUsers+` WHERE first_name = ? AND last_name = ?`, NormalizeName(first), NormalizeName(last))
+}
+
+func (s *SQLiteStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	parsedAddress, err := mail.ParseAddress(email)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEmail, email)
+	}
+	return s.getOne(ctx, selectUsers+` WHERE email = ?`, parsedAddress.Address)
+}
+
+func (s *SQLiteStore) GetUserByID(ctx context.Context, id string) (*User, error) {
+	if err := checkID(s.idGen, id); err != nil {
+		return nil, err
+	}
+	return s.getOne(ctx, selectUsers+` WHERE id = ?`, id)
+}
+
+func (s *SQLiteStore) List(ctx context.Context) ([]User, error) {
+	rows, err := s.q.QueryContext(ctx, selectUsers+` ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, user)
+	}
+	return result, rows.Err()
+}
+
+// ListAfter pages through the users like Manager.ListAfter. Only insertion
+// order is left to SQLite; the other orderings are sorted in Go, so that
+// they match a Manager's exactly.
+func (s *SQLiteStore) ListAfter(ctx context.Context, cursor Cursor, limit int, keep func(u User) bool) ([]User, *Cursor, error) {
+	var after uint64
+	if cursor.Sort == SortByInsertion {
+		after = cursor.Seq
+	}
+	rows, err := s.q.QueryContext(ctx, `SELECT `+userColumns+`, seq FROM users WHERE seq > ? ORDER BY seq`, int64(after))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	var seqs []uint64
+	kept := 0
+	for rows.Next() {
+		var seq uint64
+		user, err := scanUser(rows, &seq)
+		if err != nil {
+			return nil, nil, err
+		}
+		users = append(users, user)
+		seqs = append(seqs, seq)
+		// In insertion order one kept user past the page is enough to
+		// know that another page follows.
+		if cursor.Sort == SortByInsertion && (keep == nil || keep(user)) {
+			if kept++; kept > limit {
+				break
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return listAfter(cursor, limit, keep, nil, users, seqs)
+}
+
+// Revision reads the counter the users triggers bump. Unlike a Manager's it
+// survives a restart.
+func (s *SQLiteStore) Revision(ctx context.Context) (uint64, error) {
+	var rev uint64
+	if err := s.q.QueryRowContext(ctx, `SELECT n FROM revision`).Scan(&rev); err != nil {
+		return 0, err
+	}
+	return rev, nil
+}
+
+// UpdateUser changes the named user's email. The version check, skipped for
+// AnyVersion, and the bump of version and updated_at happen in one UPDATE,
+// so two writers that read the same version cannot both succeed.
+func (s *SQLiteStore) UpdateUser(ctx context.Context, first string, last string, email string, version uint64) error {
+	parsedAddress, err := mail.ParseAddress(email)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidEmail, email)
+	}
+
+	now := s.now().UTC().Format(time.RFC3339Nano)
+	res, err := s.q.ExecContext(ctx,
+		`UPDATE users SET email = ?, updated_at = ?, version = version + 1
+		WHERE first_name = ? AND last_name = ? AND (? = 0 OR version = ?)`,
+		parsedAddress.Address, now, NormalizeName(first), NormalizeName(last), int64(version), int64(version),
+	)
+	if err != nil {
+		return uniqueViolation(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	// Nothing matched: either there is no such user or its version moved.
+	if _, err := s.GetUserByName(ctx, first, last); err != nil {
+		return err
+	}
+	return ErrVersionConflict
+}
+
+func (s *SQLiteStore) DeleteUser(ctx context.Context, first string, last string) error {
+	res, err := s.q.ExecContext(ctx, `DELETE FROM users WHERE first_name = ? AND last_name = ?`, NormalizeName(first), NormalizeName(last))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoResultFound
+	}
+	return nil
+}
+
+// RestoreUserByEmail fails with ErrNoResultFound for any valid email:
+// DeleteUser removes the row, so there is never a user to restore.
+func (s *SQLiteStore) RestoreUserByEmail(ctx context.Context, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := mail.ParseAddress(email); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidEmail, email)
+	}
+	return ErrNoResultFound
+}
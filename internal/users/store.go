@@ -0,0 +1,95 @@
+package users
+
+import (
+	"context"
+	"iter"
+)
+
+// Store is the persistence contract shared by Manager and the database
+// backed stores. Implementations report missing users as ErrNoResultFound
+// and conflicts as ErrDuplicateUser or ErrDuplicateEmail, and return users
+// from List in insertion order. UpdateUser bumps the user's Version and
+// fails with ErrVersionConflict when version, unless AnyVersion, is no
+// longer current. Revision changes whenever a user is added, updated or
+// deleted. Manager's DeleteUser is a soft delete that RestoreUserByEmail can
+// undo; the database stores delete permanently, so they never find a user
+// to restore.
+//
+// Every method gives up once ctx is done and returns ctx.Err(), possibly
+// wrapped, so callers can tell a timeout from a missing user.
+type Store interface {
+	AddUser(ctx context.Context, firstName string, lastName string, email string) error
+	GetUserByName(ctx context.Context, first string, last string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUserByID(ctx context.Context, id string) (*User, error)
+	List(ctx context.Context) ([]User, error)
+	ListAfter(ctx context.Context, cursor Cursor, limit int, keep func(u User) bool) ([]User, *Cursor, error)
+	Revision(ctx context.Context) (uint64, error)
+	UpdateUser(ctx context.Context, first string, last string, email string, version uint64) error
+	DeleteUser(ctx context.Context, first string, last string) error
+	RestoreUserByEmail(ctx context.Context, email string) error
+}
+
+var _ Store = (*Manager)(nil)
+
+// listCheckEvery is how many users List copies between checks of its
+// context.
+const listCheckEvery = 1024
+
+// List returns a copy of all users. It fails only when ctx is done, which it
+// checks as it goes so that a long copy can be abandoned.
+func (m *Manager) List(ctx context.Context) ([]User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]User, 0, len(m.users))
+	for i, u := range m.users {
+		if i%listCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// All returns an iterator over the users of s in insertion order. It reads
+// them through ListAfter a page of allBatchSize at a time, so a Manager's
+// writers are not blocked for the whole iteration. An error ends the
+// iteration and is yielded with the zero User.
+func All(ctx context.Context, s Store) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		cursor := &Cursor{Sort: SortByInsertion}
+		for cursor != nil {
+			var page []User
+			var err error
+			page, cursor, err = s.ListAfter(ctx, *cursor, allBatchSize, nil)
+			if err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, u := range page {
+				if !yield(u, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// untilError yields the users of seq up to its first error, which it stores
+// in *err.
+func untilError(seq iter.Seq2[User, error], err *error) iter.Seq[User] {
+	return func(yield func(User) bool) {
+		for u, e := range seq {
+			if e != nil {
+				*err = e
+				return
+			}
+			if !yield(u) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package users_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+	"github.com/kunalkumar-1/go-http/internal/users/storetest"
+)
+
+func TestManagerStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) users.Store {
+		return users.NewManager()
+	})
+}
+
+func openSQLite(t *testing.T, path string) *users.SQLiteStore {
+	t.Helper()
+
+	s, err := users.OpenSQLite(path)
+	if err != nil {
+		t.Fatal("error opening sqlite store:", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) users.Store {
+		return openSQLite(t, ":memory:")
+	})
+}
+
+func TestSQLiteStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+
+	s, err := users.OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddUser(context.Background(), "jhon", "smith", "foo@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := openSQLite(t, path)
+	user, err := reopened.GetUserByEmail(context.Background(), "foo@bar.com")
+	if err != nil {
+		t.Fatal("user not retained after reopen:", err)
+	}
+	if user.FirstName != "jhon" {
+		t.Errorf("unexpected user after reopen: %+v", user)
+	}
+}
+
+// TestSQLiteStoreMigrate opens a database created before users had a
+// version or an id.
+func TestSQLiteStoreMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE users (
+			seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+			first_name TEXT NOT NULL,
+			last_name  TEXT NOT NULL,
+			email      TEXT NOT NULL UNIQUE,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+			UNIQUE (first_name, last_name)
+		)`,
+		`INSERT INTO users (first_name, last_name, email, created_at, updated_at)
+			VALUES ('jhon', 'smith', 'foo@bar.com', '2024-03-01T12:00:00Z', '2024-03-01T12:00:00Z')`,
+		`PRAGMA user_version = 1`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := openSQLite(t, path)
+	user, err := s.GetUserByEmail(context.Background(), "foo@bar.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Version != 1 {
+		t.Errorf("expected version 1 for an existing row, got %d", user.Version)
+	}
+	if !(users.UUIDv4Generator{}).ValidID(user.ID) {
+		t.Errorf("expected a version 4 UUID for an existing row, got %q", user.ID)
+	}
+	if err := s.UpdateUser(context.Background(), "jhon", "smith", "new@bar.com", 1); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := s.GetUserByEmail(context.Background(), "new@bar.com"); user == nil || user.Version != 2 {
+		t.Errorf("expected version 2 after an update, got %+v", user)
+	}
+}
+
+func TestAll(t *testing.T) {
+	for name, s := range map[string]users.Store{
+		"manager": users.NewManager(),
+		"sqlite":  openSQLite(t, ":memory:"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			// More than one page.
+			const n = 300
+			for i := range n {
+				first := fmt.Sprintf("user%03d", n-i)
+				if err := s.AddUser(ctx, first, "smith", first+"@bar.com"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			i := 0
+			for u, err := range users.All(ctx, s) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := fmt.Sprintf("user%03d", n-i); u.FirstName != want {
+					t.Fatalf("user %d: expected %s, got %s", i, want, u.FirstName)
+				}
+				i++
+			}
+			if i != n {
+				t.Errorf("expected %d users, got %d", n, i)
+			}
+
+			canceled, cancel := context.WithCancel(ctx)
+			cancel()
+			var errs []error
+			for _, err := range users.All(canceled, s) {
+				errs = append(errs, err)
+			}
+			if len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+				t.Errorf("expected a single context.Canceled, got %v", errs)
+			}
+		})
+	}
+}
+
+func TestManagerCanceledContext(t *testing.T) {
+	m := users.NewManager()
+	if err := m.AddUser(context.Background(), "jhon", "smith", "foo@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := m.AddUser(ctx, "jane", "smith", "jane@bar.com"); !errors.Is(err, context.Canceled) {
+		t.Errorf("AddUser: expected context.Canceled, got %v", err)
+	}
+	if _, err := m.GetUserByName(ctx, "jhon", "smith"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetUserByName: expected context.Canceled, got %v", err)
+	}
+	if _, err := m.GetUserByEmail(ctx, "foo@bar.com"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetUserByEmail: expected context.Canceled, got %v", err)
+	}
+	if _, err := m.List(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("List: expected context.Canceled, got %v", err)
+	}
+	if err := m.DeleteUser(ctx, "jhon", "smith"); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteUser: expected context.Canceled, got %v", err)
+	}
+
+	all, _ := m.List(context.Background())
+	if len(all) != 1 || all[0].FirstName != "jhon" {
+		t.Errorf("canceled calls changed the store: %+v", all)
+	}
+}
@@ -0,0 +1,265 @@
+// Package storetest holds the contract tests every users.Store must pass.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kunalkumar-1/go-http/internal/users"
+)
+
+// Run runs the contract tests, calling newStore for a fresh, empty store in
+// each subtest.
+func Run(t *testing.T, newStore func(t *testing.T) users.Store) {
+	t.Run("AddAndGet", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.AddUser(context.Background(), "jhon", "smith", "foo@bar.com"); err != nil {
+			t.Fatal("error adding user:", err)
+		}
+
+		byName, err := s.GetUserByName(context.Background(), "jhon", "smith")
+		if err != nil {
+			t.Fatal("error getting user by name:", err)
+		}
+		byEmail, err := s.GetUserByEmail(context.Background(), "foo@bar.com")
+		if err != nil {
+			t.Fatal("error getting user by email:", err)
+		}
+
+		if byName.Email.Address != "foo@bar.com" || byName.CreatedAt.IsZero() {
+			t.Errorf("unexpected user: %+v", byName)
+		}
+		if byName.FirstName != byEmail.FirstName || !byName.CreatedAt.Equal(byEmail.CreatedAt) {
+			t.Errorf("lookups disagree: %+v and %+v", byName, byEmail)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		s := newStore(t)
+		if _, err := s.GetUserByName(context.Background(), "no", "body"); !errors.Is(err, users.ErrNoResultFound) {
+			t.Errorf("GetUserByName: expected ErrNoResultFound, got %v", err)
+		}
+		if _, err := s.GetUserByEmail(context.Background(), "nobody@bar.com"); !errors.Is(err, users.ErrNoResultFound) {
+			t.Errorf("GetUserByEmail: expected ErrNoResultFound, got %v", err)
+		}
+		if err := s.DeleteUser(context.Background(), "no", "body"); !errors.Is(err, users.ErrNoResultFound) {
+			t.Errorf("DeleteUser: expected ErrNoResultFound, got %v", err)
+		}
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.AddUser(context.Background(), "jhon", "smith", "foo@bar.com"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddUser(context.Background(), "jhon", "smith", "other@bar.com"); !errors.Is(err, users.ErrDuplicateUser) {
+			t.Errorf("expected ErrDuplicateUser, got %v", err)
+		}
+		if err := s.AddUser(context.Background(), "jane", "smith", "foo@bar.com"); !errors.Is(err, users.ErrDuplicateEmail) {
+			t.Errorf("expected ErrDuplicateEmail, got %v", err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		s := newStore(t)
+		for _, args := range [][3]string{{"", "smith", "foo@bar.com"}, {"jhon", "", "foo@bar.com"}, {"jhon", "smith", "not-an-email"}} {
+			if err := s.AddUser(context.Background(), args[0], args[1], args[2]); err == nil {
+				t.Errorf("AddUser%q: expected an error", args)
+			}
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		for _, name := range []string{"jhon", "jane"} {
+			if err := s.AddUser(ctx, name, "smith", name+"@bar.com"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		before, err := s.GetUserByName(ctx, "jhon", "smith")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.UpdateUser(ctx, "jhon", "smith", "new@bar.com", before.Version); err != nil {
+			t.Fatal("error updating user:", err)
+		}
+		after, err := s.GetUserByEmail(ctx, "new@bar.com")
+		if err != nil {
+			t.Fatal("updated user not found by its new email:", err)
+		}
+		if after.Version != before.Version+1 || after.UpdatedAt.Before(before.UpdatedAt) || !after.CreatedAt.Equal(before.CreatedAt) {
+			t.Errorf("bad user after update: before %+v, after %+v", before, after)
+		}
+
+		// A second writer that read the same version loses.
+		if err := s.UpdateUser(ctx, "jhon", "smith", "stale@bar.com", before.Version); !errors.Is(err, users.ErrVersionConflict) {
+			t.Errorf("stale version: expected ErrVersionConflict, got %v", err)
+		}
+		if err := s.UpdateUser(ctx, "jhon", "smith", "any@bar.com", users.AnyVersion); err != nil {
+			t.Errorf("AnyVersion: %v", err)
+		}
+		if err := s.UpdateUser(ctx, "jhon", "smith", "jane@bar.com", users.AnyVersion); !errors.Is(err, users.ErrDuplicateEmail) {
+			t.Errorf("taken email: expected ErrDuplicateEmail, got %v", err)
+		}
+		if err := s.UpdateUser(ctx, "no", "body", "nobody@bar.com", users.AnyVersion); !errors.Is(err, users.ErrNoResultFound) {
+			t.Errorf("missing user: expected ErrNoResultFound, got %v", err)
+		}
+		if err := s.UpdateUser(ctx, "jhon", "smith", "not-an-email", users.AnyVersion); err == nil {
+			t.Error("invalid email: expected an error")
+		}
+
+		final, err := s.GetUserByName(ctx, "jhon", "smith")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if final.Email.Address != "any@bar.com" || final.Version != before.Version+2 {
+			t.Errorf("failed updates changed the user: %+v", final)
+		}
+	})
+
+	t.Run("GetByID", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		if err := s.AddUser(ctx, "jhon", "smith", "foo@bar.com"); err != nil {
+			t.Fatal(err)
+		}
+		byEmail, err := s.GetUserByEmail(ctx, "foo@bar.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if byEmail.ID == "" {
+			t.Fatal("user has no id")
+		}
+		byID, err := s.GetUserByID(ctx, byEmail.ID)
+		if err != nil || byID.Email.Address != "foo@bar.com" {
+			t.Errorf("GetUserByID: got %+v, %v", byID, err)
+		}
+		if _, err := s.GetUserByID(ctx, "not an id"); err == nil {
+			t.Error("expected an error for a malformed id")
+		}
+	})
+
+	t.Run("ListAfter", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		for _, name := range []string{"c", "a", "d", "b"} {
+			if err := s.AddUser(ctx, name, "smith", name+"@bar.com"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		notD := func(u users.User) bool { return u.FirstName != "d" }
+
+		for _, tc := range []struct {
+			sort users.SortBy
+			want string
+		}{
+			{users.SortByInsertion, "c a b"},
+			{users.SortByFirstName, "a b c"},
+			{users.SortByEmail, "a b c"},
+		} {
+			var got []string
+			cursor := &users.Cursor{Sort: tc.sort}
+			for cursor != nil {
+				page, next, err := s.ListAfter(ctx, *cursor, 2, notD)
+				if err != nil {
+					t.Fatalf("%s: %v", tc.sort, err)
+				}
+				if len(page) > 2 {
+					t.Errorf("%s: page of %d users for a limit of 2", tc.sort, len(page))
+				}
+				for _, u := range page {
+					got = append(got, u.FirstName)
+				}
+				cursor = next
+			}
+			if strings.Join(got, " ") != tc.want {
+				t.Errorf("%s: expected %s, got %s", tc.sort, tc.want, strings.Join(got, " "))
+			}
+		}
+
+		if _, _, err := s.ListAfter(ctx, users.Cursor{Sort: "age"}, 2, nil); !errors.Is(err, users.ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("Revision", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		revisions := make(map[uint64]string)
+		step := func(name string, change func() error) {
+			t.Helper()
+			if err := change(); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			rev, err := s.Revision(ctx)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if before, ok := revisions[rev]; ok {
+				t.Errorf("%s: revision %d already seen after %s", name, rev, before)
+			}
+			revisions[rev] = name
+		}
+		step("start", func() error { return nil })
+		step("add", func() error { return s.AddUser(ctx, "jhon", "smith", "foo@bar.com") })
+		step("update", func() error { return s.UpdateUser(ctx, "jhon", "smith", "new@bar.com", users.AnyVersion) })
+		step("delete", func() error { return s.DeleteUser(ctx, "jhon", "smith") })
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		if err := s.AddUser(ctx, "jhon", "smith", "foo@bar.com"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RestoreUserByEmail(ctx, "nobody@bar.com"); !errors.Is(err, users.ErrNoResultFound) {
+			t.Errorf("never deleted: expected ErrNoResultFound, got %v", err)
+		}
+		if err := s.RestoreUserByEmail(ctx, "not-an-email"); !errors.Is(err, users.ErrInvalidEmail) {
+			t.Errorf("invalid email: expected ErrInvalidEmail, got %v", err)
+		}
+
+		// Stores that delete permanently have nothing to restore.
+		if err := s.DeleteUser(ctx, "jhon", "smith"); err != nil {
+			t.Fatal(err)
+		}
+		err := s.RestoreUserByEmail(ctx, "foo@bar.com")
+		if errors.Is(err, users.ErrNoResultFound) {
+			return
+		}
+		if err != nil {
+			t.Fatal("error restoring user:", err)
+		}
+		if _, err := s.GetUserByEmail(ctx, "foo@bar.com"); err != nil {
+			t.Errorf("restored user not found: %v", err)
+		}
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		s := newStore(t)
+		for _, name := range []string{"a", "b", "c"} {
+			if err := s.AddUser(context.Background(), name, "smith", name+"@bar.com"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.DeleteUser(context.Background(), "b", "smith"); err != nil {
+			t.Fatal("error deleting user:", err)
+		}
+
+		all, err := s.List(context.Background())
+		if err != nil {
+			t.Fatal("error listing users:", err)
+		}
+		if len(all) != 2 || all[0].FirstName != "a" || all[1].FirstName != "c" {
+			t.Errorf("unexpected users after delete: %+v", all)
+		}
+
+		if err := s.AddUser(context.Background(), "b", "smith", "b@bar.com"); err != nil {
+			t.Errorf("error re-adding deleted user: %v", err)
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package users
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// MaxTags is the most tags a user may have.
+	MaxTags = 10
+
+	// MaxTagLength is the longest a tag may be, in bytes.
+	MaxTagLength = 32
+)
+
+// ErrTooManyTags is returned by AddTag when the user already has MaxTags
+// tags.
+var ErrTooManyTags = fmt.Errorf("a user may have at most %d tags", MaxTags)
+
+// InvalidTagError is returned when a tag is empty, too long, or has a
+// character other than a-z, 0-9 and '-'.
+type InvalidTagError struct {
+	Tag    string
+	Reason string
+}
+
+func (e *InvalidTagError) Error() string {
+	return fmt.Sprintf("invalid tag %q: %s", e.Tag, e.Reason)
+}
+
+// TagNotFoundError is returned by RemoveTag when the user does not have the
+// tag. Nothing is changed.
+type TagNotFoundError struct {
+	Tag string
+}
+
+func (e *TagNotFoundError) Error() string {
+	return fmt.Sprintf("user has no tag %q", e.Tag)
+}
+
+// ParseTag validates tag and returns it lowercased. Surrounding whitespace
+// is trimmed.
+func ParseTag(tag string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(tag))
+	switch {
+	case normalized == "":
+		return "", &InvalidTagError{Tag: tag, Reason: "must not be empty"}
+	case len(normalized) > MaxTagLength:
+		return "", &InvalidTagError{Tag: tag, Reason: fmt.Sprintf("must be at most %d characters", MaxTagLength)}
+	}
+	for _, r := range normalized {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return "", &InvalidTagError{Tag: tag, Reason: "may only contain a-z, 0-9 and '-'"}
+		}
+	}
+	return normalized, nil
+}
+
+// HasTags reports whether u has every one of tags, which must already be
+// parsed. A user has every one of no tags.
+func (u User) HasTags(tags ...string) bool {
+	for _, tag := range tags {
+		if _, found := slices.BinarySearch(u.Tags, tag); !found {
+			return false
+		}
+	}
+	return true
+}
+
+// AddTag adds tag to the named user's tags, bumps its Version and UpdatedAt
+// timestamp, and records the change in the audit log. Adding a tag the user already has changes nothing.
+// An invalid tag is rejected with *InvalidTagError, and a tag beyond
+// MaxTags with ErrTooManyTags.
+func (m *Manager) AddTag(first string, last string, tag string) error {
+	tag, err := ParseTag(tag)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, n := m.nameIndex(first, last)
+	if n == 0 {
+		return ErrNoResultFound
+	}
+	if n > 1 {
+		return ErrAmbiguousName
+	}
+	tags := m.users[i].Tags
+	at, found := slices.BinarySearch(tags, tag)
+	if found {
+		return nil
+	}
+	if len(tags) >= MaxTags {
+		return ErrTooManyTags
+	}
+
+	// Lookups hand out copies sharing Tags, so it is replaced, never
+	// changed in place.
+	before := m.users[i]
+	m.users[i].Tags = slices.Insert(slices.Clip(tags), at, tag)
+	m.users[i].UpdatedAt = m.now()
+	m.users[i].Version++
+	m.rev++
+	m.record("", AuditUpdate, &before, &m.users[i])
+	return nil
+}
+
+// RemoveTag removes tag from the named user's tags, bumps its Version and
+// UpdatedAt timestamp, and records the change in the audit log. Removing a tag the user does not have changes nothing
+// and returns *TagNotFoundError.
+func (m *Manager) RemoveTag(first string, last string, tag string) error {
+	tag, err := ParseTag(tag)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, n := m.nameIndex(first, last)
+	if n == 0 {
+		return ErrNoResultFound
+	}
+	if n > 1 {
+		return ErrAmbiguousName
+	}
+	tags := m.users[i].Tags
+	at, found := slices.BinarySearch(tags, tag)
+	if !found {
+		return &TagNotFoundError{Tag: tag}
+	}
+
+	before := m.users[i]
+	m.users[i].Tags = slices.Delete(slices.Clone(tags), at, at+1)
+	if len(m.users[i].Tags) == 0 {
+		m.users[i].Tags = nil
+	}
+	m.users[i].UpdatedAt = m.now()
+	m.users[i].Version++
+	m.rev++
+	m.record("", AuditUpdate, &before, &m.users[i])
+	return nil
+}
+
+// GetUsersByTag returns every user that has all of tags, in insertion
+// order. An invalid tag is rejected with *InvalidTagError.
+func (m *Manager) GetUsersByTag(tags ...string) ([]User, error) {
+	parsed := make([]string, len(tags))
+	for i, tag := range tags {
+		var err error
+		if parsed[i], err = ParseTag(tag); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []User
+	for _, u := range m.users {
+		if u.HasTags(parsed...) {
+			result = append(result, u)
+		}
+	}
+	return result, nil
+}
+
+// normalizeTags parses each of tags and returns them sorted without
+// duplicates, as AddTag keeps them.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	parsed := make([]string, len(tags))
+	for i, tag := range tags {
+		var err error
+		if parsed[i], err = ParseTag(tag); err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(parsed)
+	parsed = slices.Compact(parsed)
+	if len(parsed) > MaxTags {
+		return nil, ErrTooManyTags
+	}
+	return slices.Clip(parsed), nil
+}
@@ -0,0 +1,215 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+		ok   bool
+	}{
+		{"beta", "beta", true},
+		{" Beta ", "beta", true},
+		{"team-42", "team-42", true},
+		{strings.Repeat("a", MaxTagLength), strings.Repeat("a", MaxTagLength), true},
+		{"", "", false},
+		{"   ", "", false},
+		{strings.Repeat("a", MaxTagLength+1), "", false},
+		{"two words", "", false},
+		{"snake_case", "", false},
+		{"café", "", false},
+		{"a,b", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseTag(tt.tag)
+		if tt.ok {
+			if err != nil || got != tt.want {
+				t.Errorf("ParseTag(%q) = %q, %v, want %q", tt.tag, got, err, tt.want)
+			}
+			continue
+		}
+		var invalid *InvalidTagError
+		if !errors.As(err, &invalid) || invalid.Tag != tt.tag {
+			t.Errorf("ParseTag(%q): expected *InvalidTagError for the tag, got %v", tt.tag, err)
+		}
+	}
+}
+
+func TestAddTag(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	m := NewManager(WithClock(func() time.Time { return now }))
+	if err := m.AddUser(context.Background(), "jhon", "smith", "foo@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	now = start.Add(time.Hour)
+	for _, tag := range []string{"Employee", "beta", "beta"} {
+		if err := m.AddTag("jhon", "smith", tag); err != nil {
+			t.Fatalf("AddTag(%q): %v", tag, err)
+		}
+	}
+	u, _ := m.GetUserByName(context.Background(), "jhon", "smith")
+	if !slices.Equal(u.Tags, []string{"beta", "employee"}) {
+		t.Errorf("expected tags [beta employee], got %v", u.Tags)
+	}
+	// The repeated tag changed nothing.
+	if u.Version != 3 || !u.UpdatedAt.Equal(now) {
+		t.Errorf("expected version 3 updated at %v, got version %d at %v", now, u.Version, u.UpdatedAt)
+	}
+
+	var invalid *InvalidTagError
+	if err := m.AddTag("jhon", "smith", "not valid"); !errors.As(err, &invalid) {
+		t.Errorf("expected *InvalidTagError, got %v", err)
+	}
+	if err := m.AddTag("no", "body", "beta"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("expected ErrNoResultFound, got %v", err)
+	}
+
+	for i := range MaxTags - 2 {
+		if err := m.AddTag("jhon", "smith", fmt.Sprintf("tag-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.AddTag("jhon", "smith", "one-too-many"); !errors.Is(err, ErrTooManyTags) {
+		t.Errorf("expected ErrTooManyTags, got %v", err)
+	}
+	if err := m.AddTag("jhon", "smith", "beta"); err != nil {
+		t.Errorf("adding a tag the user has at the limit: %v", err)
+	}
+}
+
+func TestTagsNotShared(t *testing.T) {
+	m := NewManager()
+	m.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	m.AddTag("jhon", "smith", "b")
+
+	before, _ := m.GetUserByName(context.Background(), "jhon", "smith")
+	m.AddTag("jhon", "smith", "a")
+	m.RemoveTag("jhon", "smith", "b")
+	if !slices.Equal(before.Tags, []string{"b"}) {
+		t.Errorf("an earlier copy saw later changes: %v", before.Tags)
+	}
+}
+
+func TestRemoveTag(t *testing.T) {
+	m := NewManager()
+	m.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	m.AddTag("jhon", "smith", "beta")
+	m.AddTag("jhon", "smith", "employee")
+	rev, _ := m.Revision(context.Background())
+
+	var notFound *TagNotFoundError
+	if err := m.RemoveTag("jhon", "smith", "Alpha"); !errors.As(err, &notFound) || notFound.Tag != "alpha" {
+		t.Fatalf("expected *TagNotFoundError for alpha, got %v", err)
+	}
+	u, _ := m.GetUserByName(context.Background(), "jhon", "smith")
+	if got, _ := m.Revision(context.Background()); u.Version != 3 || got != rev || len(u.Tags) != 2 {
+		t.Errorf("removing a missing tag changed the user: %+v", u)
+	}
+
+	if err := m.RemoveTag("jhon", "smith", "BETA"); err != nil {
+		t.Fatal(err)
+	}
+	u, _ = m.GetUserByName(context.Background(), "jhon", "smith")
+	if !slices.Equal(u.Tags, []string{"employee"}) || u.Version != 4 {
+		t.Errorf("expected tags [employee] at version 4, got %v at %d", u.Tags, u.Version)
+	}
+
+	reader := m.Audit().(AuditReader)
+	last := reader.Last(1)[0]
+	if last.Op != AuditUpdate || !slices.Equal(last.Before.Tags, []string{"beta", "employee"}) || !slices.Equal(last.After.Tags, []string{"employee"}) {
+		t.Errorf("tag removal not audited: %+v", last)
+	}
+}
+
+func TestGetUsersByTag(t *testing.T) {
+	m := NewManager()
+	for _, u := range []struct {
+		first string
+		tags  []string
+	}{
+		{"ann", []string{"beta", "employee"}},
+		{"bob", []string{"beta"}},
+		{"cat", []string{"employee"}},
+		{"dan", []string{"employee", "beta", "admin"}},
+	} {
+		m.AddUser(context.Background(), u.first, "smith", u.first+"@bar.com")
+		for _, tag := range u.tags {
+			if err := m.AddTag(u.first, "smith", tag); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{[]string{"beta"}, []string{"ann", "bob", "dan"}},
+		{[]string{"beta", "Employee"}, []string{"ann", "dan"}},
+		{[]string{"beta", "employee", "admin"}, []string{"dan"}},
+		{[]string{"nobody"}, nil},
+	}
+	for _, tt := range tests {
+		found, err := m.GetUsersByTag(tt.tags...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, u := range found {
+			got = append(got, u.FirstName)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("GetUsersByTag(%v) = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+
+	var invalid *InvalidTagError
+	if _, err := m.GetUsersByTag("beta", "no good"); !errors.As(err, &invalid) {
+		t.Errorf("expected *InvalidTagError, got %v", err)
+	}
+}
+
+func TestTagsRoundTrip(t *testing.T) {
+	m := NewManager()
+	m.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	m.AddTag("jhon", "smith", "beta")
+	m.AddTag("jhon", "smith", "employee")
+	u, _ := m.GetUserByName(context.Background(), "jhon", "smith")
+
+	data, err := json.Marshal(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded User
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(decoded.Tags, u.Tags) {
+		t.Errorf("JSON round trip: expected tags %v, got %v", u.Tags, decoded.Tags)
+	}
+
+	var buf bytes.Buffer
+	if err := m.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewManager()
+	if err := restored.RestoreSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	ru, _ := restored.GetUserByName(context.Background(), "jhon", "smith")
+	if !slices.Equal(ru.Tags, u.Tags) {
+		t.Errorf("snapshot round trip: expected tags %v, got %v", u.Tags, ru.Tags)
+	}
+}
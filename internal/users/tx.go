@@ -0,0 +1,312 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/mail"
+	"slices"
+)
+
+var (
+	// ErrNestedTransaction is returned by Tx.Transact. A transaction cannot
+	// be started inside another one.
+	ErrNestedTransaction = errors.New("transaction already in progress: use the Tx passed to fn")
+
+	// ErrTxDone is returned by the methods of a Tx used after the function
+	// it was passed to has returned.
+	ErrTxDone = errors.New("transaction has already finished")
+)
+
+// Transactor is a Store that can apply several changes atomically.
+// Transact calls fn with a Tx and keeps the changes made through it only if
+// fn returns nil. If fn returns an error or panics, every change is
+// discarded and the error or panic is passed on.
+//
+// fn must make its changes through tx. The store is locked for the whole
+// call, so calling the store itself from fn blocks forever.
+type Transactor interface {
+	Store
+	Transact(fn func(tx *Tx) error) error
+}
+
+var (
+	_ Transactor = (*Manager)(nil)
+	_ Transactor = (*SQLiteStore)(nil)
+	_ Transactor = (*Tx)(nil)
+)
+
+// Tx is the Store passed to the function given to Transact. It sees its own
+// changes. It must not be kept after that function returns.
+type Tx struct {
+	store Store
+	done  bool
+}
+
+func (tx *Tx) AddUser(ctx context.Context, firstName string, lastName string, email string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	return tx.store.AddUser(ctx, firstName, lastName, email)
+}
+
+func (tx *Tx) GetUserByName(ctx context.Context, first string, last string) (*User, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	return tx.store.GetUserByName(ctx, first, last)
+}
+
+func (tx *Tx) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	return tx.store.GetUserByEmail(ctx, email)
+}
+
+func (tx *Tx) GetUserByID(ctx context.Context, id string) (*User, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	return tx.store.GetUserByID(ctx, id)
+}
+
+func (tx *Tx) List(ctx context.Context) ([]User, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	return tx.store.List(ctx)
+}
+
+func (tx *Tx) ListAfter(ctx context.Context, cursor Cursor, limit int, keep func(u User) bool) ([]User, *Cursor, error) {
+	if tx.done {
+		return nil, nil, ErrTxDone
+	}
+	return tx.store.ListAfter(ctx, cursor, limit, keep)
+}
+
+func (tx *Tx) Revision(ctx context.Context) (uint64, error) {
+	if tx.done {
+		return 0, ErrTxDone
+	}
+	return tx.store.Revision(ctx)
+}
+
+func (tx *Tx) UpdateUser(ctx context.Context, first string, last string, email string, version uint64) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	return tx.store.UpdateUser(ctx, first, last, email, version)
+}
+
+func (tx *Tx) DeleteUser(ctx context.Context, first string, last string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	return tx.store.DeleteUser(ctx, first, last)
+}
+
+func (tx *Tx) RestoreUserByEmail(ctx context.Context, email string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	return tx.store.RestoreUserByEmail(ctx, email)
+}
+
+// Transact always fails with ErrNestedTransaction.
+func (tx *Tx) Transact(fn func(tx *Tx) error) error {
+	return ErrNestedTransaction
+}
+
+// runTx calls fn with tx and then commit, or rollback if fn fails or
+// panics. A panic is re-raised once rollback has run.
+func runTx(tx *Tx, fn func(tx *Tx) error, commit func() error, rollback func()) error {
+	defer func() {
+		tx.done = true
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		rollback()
+		return err
+	}
+	return commit()
+}
+
+// Transact runs fn as a transaction; see Transactor. The write lock is held
+// throughout. The Manager's state is copied before fn runs and put back on
+// rollback. Metrics and audit entries for the changes are only reported on
+// commit.
+func (m *Manager) Transact(fn func(tx *Tx) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	saved := m.saveState()
+	backend := &managerTx{m: m}
+	return runTx(&Tx{store: backend}, fn,
+		func() error {
+			for _, report := range backend.reports {
+				report()
+			}
+			return nil
+		},
+		func() { m.restoreState(saved) },
+	)
+}
+
+// managerState is a copy of everything a transaction can change.
+type managerState struct {
+	users      []User
+	seqs       []uint64
+	nextSeq    uint64
+	rev        uint64
+	byName     map[fullName][]int
+	byEmail    map[string]int
+	byID       map[string]int
+	deleted    []User
+	deletedIDs map[string]bool
+	tokens     map[string]pendingVerification
+	tokenBySeq map[uint64]string
+}
+
+// saveState copies m's state. The index slices are copied too, because
+// index and unindex change them in place. The caller holds the write lock.
+func (m *Manager) saveState() managerState {
+	byName := make(map[fullName][]int, len(m.byName))
+	for key, positions := range m.byName {
+		byName[key] = slices.Clone(positions)
+	}
+	return managerState{
+		users:      slices.Clone(m.users),
+		seqs:       slices.Clone(m.seqs),
+		nextSeq:    m.nextSeq,
+		rev:        m.rev,
+		byName:     byName,
+		byEmail:    maps.Clone(m.byEmail),
+		byID:       maps.Clone(m.byID),
+		deleted:    slices.Clone(m.deleted),
+		deletedIDs: maps.Clone(m.deletedIDs),
+		tokens:     maps.Clone(m.tokens),
+		tokenBySeq: maps.Clone(m.tokenBySeq),
+	}
+}
+
+// restoreState puts back a state saved by saveState. The caller holds the
+// write lock.
+func (m *Manager) restoreState(s managerState) {
+	m.users, m.seqs = s.users, s.seqs
+	m.nextSeq, m.rev = s.nextSeq, s.rev
+	m.byName, m.byEmail, m.byID = s.byName, s.byEmail, s.byID
+	m.deleted, m.deletedIDs = s.deleted, s.deletedIDs
+	m.tokens, m.tokenBySeq = s.tokens, s.tokenBySeq
+}
+
+// managerTx is the Store behind a Manager's Tx. It runs with the write lock
+// already held and queues the metrics and audit reports until commit.
+type managerTx struct {
+	m       *Manager
+	reports []func()
+}
+
+func (t *managerTx) AddUser(ctx context.Context, firstName string, lastName string, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	user, _, err := t.m.addLocked(firstName, lastName, email, RoleMember, false)
+	if err != nil {
+		return err
+	}
+	t.m.issueToken(len(t.m.users) - 1)
+	actor := ActorFrom(ctx)
+	t.reports = append(t.reports, func() { t.m.reportAdd(actor, user) })
+	return nil
+}
+
+func (t *managerTx) GetUserByName(ctx context.Context, first string, last string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return t.m.userByName(first, last)
+}
+
+func (t *managerTx) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return t.m.userByEmail(email)
+}
+
+func (t *managerTx) GetUserByID(ctx context.Context, id string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkID(t.m.idGen, id); err != nil {
+		return nil, err
+	}
+	return t.m.userByID(id)
+}
+
+func (t *managerTx) List(ctx context.Context) ([]User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(t.m.users), nil
+}
+
+func (t *managerTx) ListAfter(ctx context.Context, cursor Cursor, limit int, keep func(u User) bool) ([]User, *Cursor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return listAfter(cursor, limit, keep, t.m.collation, slices.Clone(t.m.users), slices.Clone(t.m.seqs))
+}
+
+func (t *managerTx) Revision(ctx context.Context) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return t.m.rev, nil
+}
+
+func (t *managerTx) UpdateUser(ctx context.Context, first string, last string, email string, version uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	before, after, err := t.m.updateLocked(first, last, email, version)
+	if err != nil {
+		return err
+	}
+	actor := ActorFrom(ctx)
+	t.reports = append(t.reports, func() { t.m.record(actor, AuditUpdate, &before, &after) })
+	return nil
+}
+
+func (t *managerTx) DeleteUser(ctx context.Context, first string, last string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	before, err := t.m.deleteLocked(first, last)
+	if err != nil {
+		return err
+	}
+	actor := ActorFrom(ctx)
+	t.reports = append(t.reports, func() { t.m.reportDelete(actor, before) })
+	return nil
+}
+
+func (t *managerTx) RestoreUserByEmail(ctx context.Context, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	parsedAddress, err := mail.ParseAddress(email)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidEmail, email)
+	}
+	return t.m.restoreByEmailLocked(parsedAddress.Address)
+}
@@ -0,0 +1,163 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func snapshotBytes(t *testing.T, m *Manager) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := m.WriteSnapshot(&buf); err != nil {
+		t.Fatalf("error writing snapshot: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestTransactCommits(t *testing.T) {
+	metrics := &recordingMetrics{}
+	log := NewAuditLog(10)
+	m := NewManager(WithMetrics(metrics), WithAudit(log))
+	ctx := context.Background()
+	if err := m.AddUser(ctx, "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	metrics.calls = nil
+
+	err := m.Transact(func(tx *Tx) error {
+		if err := tx.AddUser(WithActor(ctx, "admin"), "ada", "lovelace", "ada@bar.com"); err != nil {
+			return err
+		}
+		if _, err := tx.GetUserByEmail(ctx, "ada@bar.com"); err != nil {
+			t.Errorf("transaction does not see its own add: %v", err)
+		}
+		if slices.Contains(metrics.calls, "added") {
+			t.Errorf("add reported before commit: %v", metrics.calls)
+		}
+		return tx.DeleteUser(ctx, "jhon", "smith")
+	})
+	if err != nil {
+		t.Fatalf("Transact: %v", err)
+	}
+
+	all, _ := m.List(ctx)
+	if len(all) != 1 || all[0].FirstName != "ada" {
+		t.Errorf("expected only ada after commit, got %v", all)
+	}
+	// Lookups are reported as they happen, changes on commit with the
+	// committed total.
+	expected := []string{"hit", "added", "total 1", "deleted", "total 1"}
+	if !slices.Equal(metrics.calls, expected) {
+		t.Errorf("metrics: expected %v, got %v", expected, metrics.calls)
+	}
+	entries := log.Last(2)
+	if len(entries) != 2 || entries[0].Op != AuditAdd || entries[0].Actor != "admin" || entries[1].Op != AuditDelete {
+		t.Errorf("unexpected audit entries: %+v", entries)
+	}
+}
+
+func TestTransactRollsBack(t *testing.T) {
+	m := NewManager()
+	ctx := context.Background()
+	for _, u := range []NewUser{
+		{FirstName: "jhon", LastName: "smith", Email: "jhon@bar.com"},
+		{FirstName: "grace", LastName: "hopper", Email: "grace@bar.com"},
+	} {
+		if err := m.AddUser(ctx, u.FirstName, u.LastName, u.Email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := snapshotBytes(t, m)
+	rev, _ := m.Revision(ctx)
+
+	var kept *Tx
+	err := m.Transact(func(tx *Tx) error {
+		kept = tx
+		if err := tx.AddUser(ctx, "ada", "lovelace", "ada@bar.com"); err != nil {
+			return err
+		}
+		if err := tx.DeleteUser(ctx, "jhon", "smith"); err != nil {
+			return err
+		}
+		return tx.AddUser(ctx, "alan", "turing", "grace@bar.com")
+	})
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Fatalf("expected ErrDuplicateEmail, got %v", err)
+	}
+
+	if after := snapshotBytes(t, m); !bytes.Equal(before, after) {
+		t.Errorf("state changed by a rolled back transaction:\nbefore: %s\nafter:  %s", before, after)
+	}
+	if got, _ := m.Revision(ctx); got != rev {
+		t.Errorf("revision: expected %d, got %d", rev, got)
+	}
+	if _, err := m.GetUserByName(ctx, "jhon", "smith"); err != nil {
+		t.Errorf("deleted user not restored: %v", err)
+	}
+	if _, err := m.GetUserByEmail(ctx, "ada@bar.com"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("rolled back user still indexed: %v", err)
+	}
+	if err := kept.AddUser(ctx, "late", "write", "late@bar.com"); !errors.Is(err, ErrTxDone) {
+		t.Errorf("expected ErrTxDone after Transact returned, got %v", err)
+	}
+}
+
+func TestTransactRejectsNesting(t *testing.T) {
+	m := NewManager()
+	ctx := context.Background()
+
+	var nested error
+	err := m.Transact(func(tx *Tx) error {
+		if err := tx.AddUser(ctx, "ada", "lovelace", "ada@bar.com"); err != nil {
+			return err
+		}
+		nested = tx.Transact(func(*Tx) error {
+			t.Error("nested transaction ran")
+			return nil
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transact: %v", err)
+	}
+	if !errors.Is(nested, ErrNestedTransaction) {
+		t.Errorf("expected ErrNestedTransaction, got %v", nested)
+	}
+	if _, err := m.GetUserByEmail(ctx, "ada@bar.com"); err != nil {
+		t.Errorf("outer transaction not committed: %v", err)
+	}
+}
+
+func TestTransactPanicRollsBack(t *testing.T) {
+	m := NewManager()
+	ctx := context.Background()
+	if err := m.AddUser(ctx, "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshotBytes(t, m)
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("expected the panic to be re-raised, recovered %v", p)
+			}
+		}()
+		m.Transact(func(tx *Tx) error {
+			if err := tx.DeleteUser(ctx, "jhon", "smith"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+
+	if after := snapshotBytes(t, m); !bytes.Equal(before, after) {
+		t.Errorf("state changed by a panicking transaction:\nbefore: %s\nafter:  %s", before, after)
+	}
+	// The lock must have been released.
+	if err := m.AddUser(ctx, "ada", "lovelace", "ada@bar.com"); err != nil {
+		t.Errorf("error adding after panic: %v", err)
+	}
+}
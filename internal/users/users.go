@@ -1,67 +1,652 @@
 package users
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/language"
 )
 
-var ErrNoResultFound = errors.New("no result found")
+var (
+	ErrNoResultFound   = errors.New("no result found")
+	ErrDuplicateUser   = errors.New("user already exists")
+	ErrDuplicateEmail  = errors.New("email already in use")
+	ErrVersionConflict = errors.New("user was modified by someone else")
+	ErrInvalidEmail    = errors.New("invalid email")
+
+	// ErrUserDeleted is returned by lookups that only match a soft-deleted
+	// user. It wraps ErrNoResultFound, so callers that do not care about
+	// tombstones can keep checking for that.
+	ErrUserDeleted = fmt.Errorf("%w: user was deleted", ErrNoResultFound)
+)
+
+// AnyVersion passed as the expected version to UpdateUser skips the version
+// check.
+const AnyVersion uint64 = 0
 
 type User struct {
+	// ID is assigned by the Manager's IDGenerator when the user is added,
+	// and never changes. Users read from a SQLiteStore have none.
+	ID string
+
 	FirstName string
 	LastName  string
+	// Email keeps the display name it was given with, as in
+	// "Bob <bob@bar.com>", but only Email.Address identifies the mailbox:
+	// duplicate checks and lookups ignore the name.
 	Email     mail.Address
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Role      Role
+
+	// Version starts at 1 and is bumped by every change to the user, so a
+	// caller can detect that a user changed since it was read.
+	Version uint64
+
+	// Tags are the user's labels, lowercase, sorted and without
+	// duplicates; see AddTag. Copies of a user share Tags, which must not
+	// be changed in place.
+	Tags []string
+
+	// Verified is set once the user confirms their email address with the
+	// token issued when they were added; see VerifyUser.
+	Verified bool
+
+	// GreetCount and LastGreetedAt record how often and when the user was
+	// last greeted; see RecordGreeting. LastGreetedAt is zero until then.
+	GreetCount    int
+	LastGreetedAt time.Time
+
+	// DeletedAt is set on soft-deleted users, which are only returned by
+	// DeletedUsers and GetDeletedUserByEmail.
+	DeletedAt *time.Time
+
+	// passwordHash is the bcrypt hash set by SetPassword. It is unexported
+	// so it never leaves the package through encoding or formatting.
+	passwordHash string
+}
+
+// String formats the user as a name and address. It never includes the
+// password hash.
+func (u User) String() string {
+	return u.FirstName + " " + u.LastName + " <" + u.Email.Address + ">"
 }
 
+// Manager keeps users in insertion order alongside indexes keyed by name and
+// by email address, the latter normalized by emailRules. The indexes map to
+// positions in users, byName to every position holding the name in
+// ascending order, and are rebuilt for the shifted tail on delete. seqs
+// holds a monotonically increasing sequence number per user, parallel to
+// users, so iteration can resume after a lock has been released. rev is
+// bumped on every mutation. deleted holds the tombstones of soft-deleted
+// users, oldest first, and deletedIDs the set of their IDs; tombstones are
+// otherwise not indexed. tokens holds the pending verification tokens, and
+// tokenBySeq the token of each user that has one.
+//
+// Manager is safe for concurrent use. Lookups return copies of the stored
+// users. now is the clock used for CreatedAt and UpdatedAt and defaults to
+// time.Now.
 type Manager struct {
-	users []User
+	mu         sync.RWMutex
+	now        func() time.Time
+	users      []User
+	seqs       []uint64
+	nextSeq    uint64
+	rev        uint64
+	byName     map[fullName][]int
+	byEmail    map[string]int
+	byID       map[string]int
+	idGen      IDGenerator
+	deleted    []User
+	deletedIDs map[string]bool
+	metrics    Metrics
+	audit      AuditSink
+
+	tokens          map[string]pendingVerification
+	tokenBySeq      map[uint64]string
+	verificationTTL time.Duration
+
+	caseInsensitiveNames bool
+	namePolicy           DuplicateNamePolicy
+	nameRules            NameRules
+	emailRules           NormalizeOptions
+	collation            *language.Tag
 }
 
-func NewManager() *Manager {
-	return &Manager{}
+// Option configures a Manager created by NewManager.
+type Option func(*Manager)
+
+// WithCaseInsensitiveNames matches names case-insensitively, using Unicode
+// case folding, in lookups and duplicate detection. Names keep their
+// original case.
+func WithCaseInsensitiveNames() Option {
+	return func(m *Manager) {
+		m.caseInsensitiveNames = true
+	}
+}
+
+// WithClock sets the clock used for CreatedAt and UpdatedAt timestamps.
+func WithClock(now func() time.Time) Option {
+	return func(m *Manager) {
+		m.now = now
+	}
 }
 
-func (m *Manager) AddUser(firstName string, lastName string, email string) error {
-	if firstName == "" {
-		return fmt.Errorf("invalid first name: %q", firstName)
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{
+		now:        time.Now,
+		byName:     make(map[fullName][]int),
+		byEmail:    make(map[string]int),
+		byID:       make(map[string]int),
+		deletedIDs: make(map[string]bool),
+		idGen:      UUIDv4Generator{},
+		metrics:    nopMetrics{},
+		audit:      NewAuditLog(DefaultAuditCapacity),
+
+		tokens:          make(map[string]pendingVerification),
+		tokenBySeq:      make(map[uint64]string),
+		verificationTTL: DefaultVerificationTTL,
 	}
-	if lastName == "" {
-		return fmt.Errorf("invalid last name: %q", lastName)
+	for _, opt := range opts {
+		opt(m)
 	}
+	return m
+}
 
-	existinguser, err := m.GetUserByName(firstName, lastName)
-	if err != nil && !errors.Is(err, ErrNoResultFound) {
-		return fmt.Errorf("error getting user by name: %v", err)
+// fullName keys byName. The parts are kept apart rather than joined, so no
+// two names share a key whatever characters they contain.
+type fullName struct {
+	first string
+	last  string
+}
+
+// nameKey is the byName key of a name. Both parts are normalized with
+// NormalizeName, so a lookup matches however the name is spelled.
+func (m *Manager) nameKey(first string, last string) fullName {
+	first, last = NormalizeName(first), NormalizeName(last)
+	if m.caseInsensitiveNames {
+		return fullName{foldName(first), foldName(last)}
 	}
+	return fullName{first, last}
+}
 
-	if existinguser != nil {
-		return errors.New("user already exists")
+// foldName maps every rune to the smallest rune in its case-folding orbit, so
+// two names produce the same key exactly when strings.EqualFold reports them
+// equal.
+func foldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		folded := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			folded = min(folded, f)
+		}
+		return folded
+	}, name)
+}
+
+func (m *Manager) AddUser(ctx context.Context, firstName string, lastName string, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, _, err := m.add(ActorFrom(ctx), firstName, lastName, email, RoleMember, false)
+	return err
+}
+
+// EmailConflictError is returned by GetOrCreateUser when a user with the
+// name already exists under another email. It wraps ErrDuplicateUser.
+type EmailConflictError struct {
+	FirstName string
+	LastName  string
+	// Email is the existing user's address, Requested the one asked for.
+	Email     string
+	Requested string
+}
+
+func (e *EmailConflictError) Error() string {
+	return fmt.Sprintf("user %s %s already exists with a different email", e.FirstName, e.LastName)
+}
+
+func (e *EmailConflictError) Unwrap() error {
+	return ErrDuplicateUser
+}
+
+// GetOrCreateUser atomically returns the user with the given name, creating
+// it when it does not exist. created reports whether the user was added by
+// this call. An existing user with the same name but a different email is
+// reported as *EmailConflictError rather than returned, so repeating a call
+// with the same arguments always converges on the same user. Like AddUser,
+// it fails once ctx is done and audits the addition under ctx's actor.
+func (m *Manager) GetOrCreateUser(ctx context.Context, firstName string, lastName string, email string) (user *User, created bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	u, created, err := m.add(ActorFrom(ctx), firstName, lastName, email, RoleMember, true)
+	if err != nil {
+		return nil, false, err
+	}
+	return &u, created, nil
+}
+
+// add inserts a new user on behalf of actor. When getExisting is set, a user
+// with the same name and email is returned instead of ErrDuplicateUser.
+func (m *Manager) add(actor string, firstName string, lastName string, email string, role Role, getExisting bool) (User, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, created, err := m.addLocked(firstName, lastName, email, role, getExisting)
+	if created {
+		m.issueToken(len(m.users) - 1)
+		m.reportAdd(actor, user)
+	}
+	return user, created, err
+}
+
+// reportAdd tells the metrics sink and the audit log that actor added user.
+// The caller holds the write lock.
+func (m *Manager) reportAdd(actor string, user User) {
+	m.metrics.UserAdded()
+	m.metrics.UsersTotal(len(m.users))
+	m.record(actor, AuditAdd, nil, &user)
+}
+
+// addLocked is add for callers already holding the write lock. It only ever
+// appends, so a caller can undo it by truncating; see AddUsers. Failures are
+// reported to m.metrics here; successes are left to the caller, which may
+// still roll them back.
+func (m *Manager) addLocked(firstName string, lastName string, email string, role Role, getExisting bool) (User, bool, error) {
+	fail := func(reason string, err error) (User, bool, error) {
+		m.metrics.AddFailed(reason)
+		return User{}, false, err
+	}
+
+	if err := m.nameRules.Validate(firstName); err != nil {
+		return fail(AddFailureInvalidName, fmt.Errorf("first name: %w", err))
+	}
+	if err := m.nameRules.Validate(lastName); err != nil {
+		return fail(AddFailureInvalidName, fmt.Errorf("last name: %w", err))
+	}
+	firstName = NormalizeName(firstName)
+	lastName = NormalizeName(lastName)
+
+	sameName := m.byName[m.nameKey(firstName, lastName)]
+	nameTaken := len(sameName) > 0 && !m.allowsDuplicateNames()
+	if nameTaken && !getExisting {
+		return fail(AddFailureDuplicateUser, ErrDuplicateUser)
 	}
 
 	parsedAddress, err := mail.ParseAddress(email)
 	if err != nil {
-		return fmt.Errorf("invalid email: %s", email)
+		return fail(AddFailureInvalidEmail, fmt.Errorf("%w: %s", ErrInvalidEmail, email))
 	}
 
+	if getExisting {
+		for _, j := range sameName {
+			if m.emailKey(m.users[j].Email.Address) == m.emailKey(parsedAddress.Address) {
+				return m.users[j], false, nil
+			}
+		}
+	}
+	if nameTaken {
+		existing := m.users[sameName[0]]
+		return fail(AddFailureDuplicateUser, &EmailConflictError{
+			FirstName: existing.FirstName,
+			LastName:  existing.LastName,
+			Email:     existing.Email.Address,
+			Requested: parsedAddress.Address,
+		})
+	}
+
+	if _, ok := m.byEmail[m.emailKey(parsedAddress.Address)]; ok {
+		return fail(AddFailureDuplicateEmail, ErrDuplicateEmail)
+	}
+
+	id, err := m.newID(m.idTaken)
+	if err != nil {
+		return fail(AddFailureID, err)
+	}
+
+	now := m.now()
 	newUser := User{
+		ID:        id,
 		FirstName: firstName,
 		LastName:  lastName,
 		Email:     *parsedAddress,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Role:      role,
+		Version:   1,
 	}
 
+	m.nextSeq++
 	m.users = append(m.users, newUser)
+	m.seqs = append(m.seqs, m.nextSeq)
+	m.index(len(m.users) - 1)
+	m.rev++
+
+	return newUser, true, nil
+}
+
+// GetUserByName returns the live user with the given name. Under a policy
+// that allows duplicate names it is superseded by GetUsersByName: it fails
+// with ErrAmbiguousName when the name matches more than one user, as do the
+// other methods that take a name.
+func (m *Manager) GetUserByName(ctx context.Context, first string, last string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.userByName(first, last)
+}
+
+// userByName is GetUserByName for callers holding the lock.
+func (m *Manager) userByName(first string, last string) (*User, error) {
+	i, n := m.nameIndex(first, last)
+	m.metrics.Lookup(n > 0)
+	if n == 0 {
+		return nil, m.missingByName(first, last)
+	}
+	if n > 1 {
+		return nil, ErrAmbiguousName
+	}
+
+	result := m.users[i]
+	return &result, nil
+}
+
+func (m *Manager) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.userByEmail(email)
+}
+
+// userByEmail is GetUserByEmail for callers holding the lock.
+func (m *Manager) userByEmail(email string) (*User, error) {
+	parsedAddress, err := mail.ParseAddress(email)
+	if err != nil {
+		m.metrics.Lookup(false)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEmail, email)
+	}
+
+	i, ok := m.byEmail[m.emailKey(parsedAddress.Address)]
+	m.metrics.Lookup(ok)
+	if !ok {
+		if _, deleted := m.tombstoneByEmail(parsedAddress.Address); deleted {
+			return nil, ErrUserDeleted
+		}
+		return nil, ErrNoResultFound
+	}
+
+	result := m.users[i]
+	return &result, nil
+}
+
+func (m *Manager) GetAllUsers() []User {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]User, len(m.users))
+	copy(result, m.users)
+	return result
+}
+
+// Snapshot returns a copy of all users together with the revision they were
+// read at. The revision changes whenever a user is added, updated or deleted,
+// so it identifies the content of the snapshot.
+func (m *Manager) Snapshot() (uint64, []User) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]User, len(m.users))
+	copy(result, m.users)
+	return m.rev, result
+}
+
+// Revision returns the counter bumped on every mutation of the Manager. It
+// is the revision Snapshot reports.
+func (m *Manager) Revision(ctx context.Context) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.rev, nil
+}
+
+// UpdateUser changes the email address of the named user and bumps its
+// UpdatedAt timestamp and Version. CreatedAt is left untouched. Unless
+// version is AnyVersion, the update is refused with ErrVersionConflict when
+// the user's Version is no longer the one the caller read.
+func (m *Manager) UpdateUser(ctx context.Context, first string, last string, email string, version uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before, after, err := m.updateLocked(first, last, email, version)
+	if err != nil {
+		return err
+	}
+	m.record(ActorFrom(ctx), AuditUpdate, &before, &after)
 
 	return nil
 }
 
-func (m *Manager) GetUserByName(first string, last string) (*User, error) {
-	for i, user := range m.users {
-		if user.FirstName == first && user.LastName == last {
-			result := &m.users[i]
-			return result, nil
+// updateLocked is UpdateUser without the audit record. It returns the user
+// as it was and as it is now. The caller holds the write lock.
+func (m *Manager) updateLocked(first string, last string, email string, version uint64) (User, User, error) {
+	parsedAddress, err := mail.ParseAddress(email)
+	if err != nil {
+		return User{}, User{}, fmt.Errorf("%w: %s", ErrInvalidEmail, email)
+	}
+
+	i, n := m.nameIndex(first, last)
+	if n == 0 {
+		return User{}, User{}, ErrNoResultFound
+	}
+	if n > 1 {
+		return User{}, User{}, ErrAmbiguousName
+	}
+
+	if version != AnyVersion && m.users[i].Version != version {
+		return User{}, User{}, ErrVersionConflict
+	}
+	if j, ok := m.byEmail[m.emailKey(parsedAddress.Address)]; ok && j != i {
+		return User{}, User{}, ErrDuplicateEmail
+	}
+
+	before := m.users[i]
+	m.unindex(i)
+	m.users[i].Email = *parsedAddress
+	m.users[i].UpdatedAt = m.now()
+	m.users[i].Version++
+	m.index(i)
+	m.rev++
+
+	return before, m.users[i], nil
+}
+
+// DeleteUser soft-deletes the named user: it disappears from lookups,
+// listings and searches, and its name and email may be reused, but it can
+// be brought back with RestoreUser until PurgeDeleted drops it.
+func (m *Manager) DeleteUser(ctx context.Context, first string, last string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before, err := m.deleteLocked(first, last)
+	if err != nil {
+		return err
+	}
+	m.reportDelete(ActorFrom(ctx), before)
+	return nil
+}
+
+// deleteLocked is DeleteUser for callers holding the write lock. It returns
+// the user as it was before the delete and leaves reporting to the caller.
+func (m *Manager) deleteLocked(first string, last string) (User, error) {
+	i, n := m.nameIndex(first, last)
+	if n == 0 {
+		return User{}, m.missingByName(first, last)
+	}
+	if n > 1 {
+		return User{}, ErrAmbiguousName
+	}
+
+	before := m.users[i]
+	tombstone := before
+	now := m.now()
+	tombstone.DeletedAt = &now
+	tombstone.UpdatedAt = now
+	tombstone.Version++
+	m.deleted = append(m.deleted, tombstone)
+	m.deletedIDs[tombstone.ID] = true
+
+	m.dropToken(m.seqs[i])
+	for j := i; j < len(m.users); j++ {
+		m.unindex(j)
+	}
+	m.users = append(m.users[:i], m.users[i+1:]...)
+	m.seqs = append(m.seqs[:i], m.seqs[i+1:]...)
+	for j := i; j < len(m.users); j++ {
+		m.index(j)
+	}
+	m.rev++
+	return before, nil
+}
+
+// reportDelete tells the metrics sink and the audit log that actor deleted
+// the user that was before. The caller holds the write lock.
+func (m *Manager) reportDelete(actor string, before User) {
+	m.metrics.UserDeleted()
+	m.metrics.UsersTotal(len(m.users))
+	m.record(actor, AuditDelete, &before, nil)
+}
+
+// ForEachBatch calls fn with successive batches of at most batchSize users in
+// insertion order. The read lock is held only while each batch is copied, so
+// writers are not stalled for the length of the scan.
+//
+// Every user present when ForEachBatch starts is visited exactly once unless
+// it is deleted before its batch is reached. Users added during the scan are
+// not visited, so the scan always terminates, and no user is ever visited
+// twice. Iteration stops at the first error returned by fn, which is returned
+// to the caller.
+func (m *Manager) ForEachBatch(batchSize int, fn func([]User) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("invalid batch size: %d", batchSize)
+	}
+
+	m.mu.RLock()
+	stopSeq := m.nextSeq
+	m.mu.RUnlock()
+
+	var lastSeq uint64
+	for {
+		m.mu.RLock()
+		start := sort.Search(len(m.seqs), func(i int) bool {
+			return m.seqs[i] > lastSeq
+		})
+		end := sort.Search(len(m.seqs), func(i int) bool {
+			return m.seqs[i] > stopSeq
+		})
+		end = min(end, start+batchSize)
+		batch := make([]User, end-start)
+		copy(batch, m.users[start:end])
+		if end > start {
+			lastSeq = m.seqs[end-1]
+		}
+		m.mu.RUnlock()
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := fn(batch); err != nil {
+			return err
 		}
 	}
+}
+
+// allBatchSize is the number of users All copies under each read lock.
+const allBatchSize = 256
 
-	return nil, ErrNoResultFound
+// errStopIteration ends ForEachBatch when an iterator's consumer stops early.
+var errStopIteration = errors.New("stop iteration")
+
+// All returns an iterator over copies of the users in insertion order. It is
+// built on ForEachBatch: the read lock is held only while each batch is
+// copied, never while a user is yielded, so the loop body may call back into
+// the Manager and breaking out early cannot leak a lock. The same visiting
+// guarantees as ForEachBatch apply.
+func (m *Manager) All() iter.Seq[User] {
+	return func(yield func(User) bool) {
+		m.ForEachBatch(allBatchSize, func(batch []User) error {
+			for _, user := range batch {
+				if !yield(user) {
+					return errStopIteration
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// AllSorted returns an iterator over the users ordered by less. Sorting needs
+// every user at once, so it iterates over a snapshot taken when iteration
+// starts. The sort is stable, so users less considers equal stay in
+// insertion order.
+func (m *Manager) AllSorted(less func(a User, b User) bool) iter.Seq[User] {
+	return func(yield func(User) bool) {
+		sorted := m.GetAllUsers()
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return less(sorted[i], sorted[j])
+		})
+		for _, user := range sorted {
+			if !yield(user) {
+				return
+			}
+		}
+	}
+}
+
+func (m *Manager) index(i int) {
+	user := m.users[i]
+	key := m.nameKey(user.FirstName, user.LastName)
+	m.byName[key] = addIndex(m.byName[key], i)
+	m.byEmail[m.emailKey(user.Email.Address)] = i
+	m.byID[user.ID] = i
+}
+
+func (m *Manager) unindex(i int) {
+	user := m.users[i]
+	key := m.nameKey(user.FirstName, user.LastName)
+	if positions := removeIndex(m.byName[key], i); len(positions) > 0 {
+		m.byName[key] = positions
+	} else {
+		delete(m.byName, key)
+	}
+	delete(m.byEmail, m.emailKey(user.Email.Address))
+	delete(m.byID, user.ID)
 }
@@ -1,14 +1,25 @@
 package users
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/mail"
 	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestAddUser(t *testing.T) {
 	testManager := NewManager()
+	testTime := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	testManager.now = func() time.Time { return testTime }
 
 	testFirstName := "jhon"
 	testLastName := "smith"
@@ -17,7 +28,7 @@ func TestAddUser(t *testing.T) {
 		t.Fatalf("failed to parse email: %v", err)
 	}
 
-	err = testManager.AddUser(testFirstName, testLastName, testEmail.Address)
+	err = testManager.AddUser(context.Background(), testFirstName, testLastName, testEmail.Address)
 	if err != nil {
 		t.Fatalf("failed to add user: %v", err)
 	}
@@ -33,9 +44,17 @@ func TestAddUser(t *testing.T) {
 		FirstName: testFirstName,
 		LastName:  testLastName,
 		Email:     *testEmail,
+		CreatedAt: testTime,
+		UpdatedAt: testTime,
+		Role:      RoleMember,
+		Version:   1,
 	}
 
 	founduser := testManager.users[0]
+	if !(UUIDv4Generator{}).ValidID(founduser.ID) {
+		t.Errorf("expected a UUIDv4 id, got %q", founduser.ID)
+	}
+	expectedUser.ID = founduser.ID
 	if !reflect.DeepEqual(expectedUser, founduser) {
 		t.Fatalf("failed to add user: expected %v, got %v",
 			expectedUser, founduser)
@@ -49,7 +68,7 @@ func TestAddUserInvalidEmail(t *testing.T) {
 	testLastName := "smith"
 	testEmail := "foobar"
 
-	err := testManager.AddUser(testFirstName, testLastName, testEmail)
+	err := testManager.AddUser(context.Background(), testFirstName, testLastName, testEmail)
 	if err == nil {
 		t.Errorf("no error returned when adding invalid email")
 	} else {
@@ -74,11 +93,11 @@ func TestAddUserFirstName(t *testing.T) {
 		t.Errorf("no error returned when adding first name %v", err)
 	}
 
-	err = testManager.AddUser(testFirstName, testLastName, testEmail.String())
+	err = testManager.AddUser(context.Background(), testFirstName, testLastName, testEmail.String())
 	if err == nil {
 		t.Errorf("no error returned or invalid email")
 	} else {
-		expectedErr := "invalid first name: \"\""
+		expectedErr := "first name: invalid name \"\": must not be empty"
 		if err.Error() != expectedErr {
 			t.Errorf("error mismatch: expected %v, got %v", expectedErr, err)
 		}
@@ -99,11 +118,11 @@ func TestAddUserLastName(t *testing.T) {
 		t.Errorf("no error returned when adding last name %v", err)
 	}
 
-	err = testManager.AddUser(testFirstName, testLastName, testEmail.String())
+	err = testManager.AddUser(context.Background(), testFirstName, testLastName, testEmail.String())
 	if err == nil {
 		t.Errorf("no error returned or invalid email")
 	} else {
-		expectedErr := "invalid last name: \"\""
+		expectedErr := "last name: invalid name \"\": must not be empty"
 		if err.Error() != expectedErr {
 			t.Errorf("error mismatch: expected %v, got %v", expectedErr, err)
 		}
@@ -124,12 +143,12 @@ func TestAddUserDuplicateName(t *testing.T) {
 		t.Errorf("no error returned when adding duplicate name %v", err)
 	}
 
-	err = testManager.AddUser(testFirstName, testLastName, testEmail.String())
+	err = testManager.AddUser(context.Background(), testFirstName, testLastName, testEmail.String())
 	if err != nil {
 		t.Errorf("error creating user")
 	}
 
-	err = testManager.AddUser(testFirstName, testLastName, testEmail.String())
+	err = testManager.AddUser(context.Background(), testFirstName, testLastName, testEmail.String())
 	if err == nil {
 		t.Errorf("error creating duplicate user")
 	} else {
@@ -147,19 +166,19 @@ func TestAddUserDuplicateName(t *testing.T) {
 func TestGetUserByName(t *testing.T) {
 	testManager := NewManager()
 
-	err := testManager.AddUser("foo", "bar", "f.foo@bar.com")
+	err := testManager.AddUser(context.Background(), "foo", "bar", "f.foo@bar.com")
 	if err != nil {
 		t.Fatalf("error adding test user: %v", err)
 	}
-	err = testManager.AddUser("bari", "foo", "bar@bar.com")
+	err = testManager.AddUser(context.Background(), "bari", "foo", "bar@bar.com")
 	if err != nil {
 		t.Fatalf("error adding test user: %v", err)
 	}
-	err = testManager.AddUser("barz", "foo", "barz@bar.com")
+	err = testManager.AddUser(context.Background(), "barz", "foo", "barz@bar.com")
 	if err != nil {
 		t.Fatalf("error adding test user: %v", err)
 	}
-	err = testManager.AddUser("fozz", "foo", "fooz@bar.com")
+	err = testManager.AddUser(context.Background(), "fozz", "foo", "fooz@bar.com")
 	if err != nil {
 		t.Fatalf("error adding test user: %v", err)
 	}
@@ -209,7 +228,7 @@ func TestGetUserByName(t *testing.T) {
 	}
 
 	for name, test := range tests {
-		result, err := testManager.GetUserByName(test.first, test.last)
+		result, err := testManager.GetUserByName(context.Background(), test.first, test.last)
 		if err != test.expectedError {
 			t.Errorf("%s: invalid result:\nexpected: %v\ngot: %v", name, result, test.expected)
 			return
@@ -217,3 +236,1076 @@ func TestGetUserByName(t *testing.T) {
 	}
 
 }
+
+func TestGetUserByEmail(t *testing.T) {
+	testManager := NewManager()
+
+	err := testManager.AddUser(context.Background(), "foo", "bar", "foo@bar.com")
+	if err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+
+	user, err := testManager.GetUserByEmail(context.Background(), "foo@bar.com")
+	if err != nil {
+		t.Fatalf("error getting user by email: %v", err)
+	}
+	if user.FirstName != "foo" || user.LastName != "bar" {
+		t.Errorf("wrong user returned: %v", user)
+	}
+
+	_, err = testManager.GetUserByEmail(context.Background(), "nobody@bar.com")
+	if !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("error mismatch: expected %v, got %v", ErrNoResultFound, err)
+	}
+}
+
+func TestAddUserDuplicateEmail(t *testing.T) {
+	testManager := NewManager()
+
+	err := testManager.AddUser(context.Background(), "foo", "bar", "foo@bar.com")
+	if err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+
+	err = testManager.AddUser(context.Background(), "baz", "qux", "foo@bar.com")
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("error mismatch: expected %v, got %v", ErrDuplicateEmail, err)
+	}
+
+	if len(testManager.users) != 1 {
+		t.Errorf("bad test manager count: expected %d user, got %d", 1, len(testManager.users))
+	}
+}
+
+func TestDeleteUserIndexConsistency(t *testing.T) {
+	testManager := NewManager()
+
+	names := []string{"a", "b", "c", "d"}
+	for _, name := range names {
+		err := testManager.AddUser(context.Background(), name, "smith", name+"@bar.com")
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	err := testManager.DeleteUser(context.Background(), "b", "smith")
+	if err != nil {
+		t.Fatalf("error deleting user: %v", err)
+	}
+
+	err = testManager.DeleteUser(context.Background(), "b", "smith")
+	if !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("error mismatch: expected %v, got %v", ErrNoResultFound, err)
+	}
+
+	err = testManager.AddUser(context.Background(), "b", "smith", "b@bar.com")
+	if err != nil {
+		t.Fatalf("error re-adding deleted user: %v", err)
+	}
+
+	if len(testManager.users) != len(names) {
+		t.Fatalf("bad test manager count: expected %d users, got %d", len(names), len(testManager.users))
+	}
+
+	for _, name := range names {
+		byName, err := testManager.GetUserByName(context.Background(), name, "smith")
+		if err != nil {
+			t.Fatalf("error getting user %q by name: %v", name, err)
+		}
+		byEmail, err := testManager.GetUserByEmail(context.Background(), name+"@bar.com")
+		if err != nil {
+			t.Fatalf("error getting user %q by email: %v", name, err)
+		}
+		if !reflect.DeepEqual(byName, byEmail) || byName.FirstName != name {
+			t.Errorf("index mismatch for %q: by name %v, by email %v", name, byName, byEmail)
+		}
+	}
+
+	if len(testManager.byName) != len(names) || len(testManager.byEmail) != len(names) {
+		t.Errorf("stale index entries: %d names, %d emails", len(testManager.byName), len(testManager.byEmail))
+	}
+}
+
+func TestNameKeysDoNotCollide(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCaseInsensitiveNames()}} {
+		testManager := NewManager(opts...)
+
+		err := testManager.AddUser(context.Background(), "a|b", "c", "ab@bar.com")
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+		err = testManager.AddUser(context.Background(), "a", "b|c", "bc@bar.com")
+		if err != nil {
+			t.Fatalf("error adding user whose joined name matches another: %v", err)
+		}
+
+		user, err := testManager.GetUserByName(context.Background(), "a", "b|c")
+		if err != nil {
+			t.Fatalf("error getting user by name: %v", err)
+		}
+		if user.Email.Address != "bc@bar.com" {
+			t.Errorf("wrong user for a, b|c: got %v", user)
+		}
+
+		err = testManager.DeleteUser(context.Background(), "a|b", "c")
+		if err != nil {
+			t.Fatalf("error deleting user: %v", err)
+		}
+		if _, err := testManager.GetUserByName(context.Background(), "a", "b|c"); err != nil {
+			t.Errorf("deleting a|b c removed a b|c: %v", err)
+		}
+	}
+}
+
+func BenchmarkAddUser10k(b *testing.B) {
+	for b.Loop() {
+		testManager := NewManager()
+		for i := range 10000 {
+			err := testManager.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", i))
+			if err != nil {
+				b.Fatalf("error adding test user: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkGetUserByName(b *testing.B) {
+	testManager := NewManager()
+	for i := range 10000 {
+		err := testManager.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", i))
+		if err != nil {
+			b.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	for b.Loop() {
+		_, err := testManager.GetUserByName(context.Background(), "first9999", "last")
+		if err != nil {
+			b.Fatalf("error getting user: %v", err)
+		}
+	}
+}
+
+func TestForEachBatch(t *testing.T) {
+	testManager := NewManager()
+	for i := range 25 {
+		err := testManager.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", i))
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	var sizes []int
+	var seen []string
+	err := testManager.ForEachBatch(10, func(batch []User) error {
+		sizes = append(sizes, len(batch))
+		for _, user := range batch {
+			seen = append(seen, user.FirstName)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error iterating users: %v", err)
+	}
+
+	if !reflect.DeepEqual(sizes, []int{10, 10, 5}) {
+		t.Errorf("bad batch sizes: expected [10 10 5], got %v", sizes)
+	}
+	for i, name := range seen {
+		if name != fmt.Sprintf("first%d", i) {
+			t.Fatalf("bad iteration order at %d: got %q", i, name)
+		}
+	}
+}
+
+func TestForEachBatchStopsOnError(t *testing.T) {
+	testManager := NewManager()
+	for i := range 5 {
+		err := testManager.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", i))
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	errStop := errors.New("stop")
+	calls := 0
+	err := testManager.ForEachBatch(2, func(batch []User) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("error mismatch: expected %v, got %v", errStop, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected iteration to stop after 1 batch, got %d", calls)
+	}
+
+	err = testManager.ForEachBatch(0, func([]User) error { return nil })
+	if err == nil {
+		t.Error("no error returned for zero batch size")
+	}
+}
+
+func TestForEachBatchConcurrentWrites(t *testing.T) {
+	testManager := NewManager()
+	const existing = 2000
+	for i := range existing {
+		err := testManager.AddUser(context.Background(), fmt.Sprintf("old%d", i), "last", fmt.Sprintf("old%d@bar.com", i))
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	done := make(chan struct{})
+	writerErr := make(chan error, 1)
+	var maxWriterLatency time.Duration
+	go func() {
+		defer close(writerErr)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			start := time.Now()
+			err := testManager.AddUser(context.Background(), fmt.Sprintf("new%d", i), "last", fmt.Sprintf("new%d@bar.com", i))
+			if err == nil && i%2 == 0 {
+				err = testManager.DeleteUser(context.Background(), fmt.Sprintf("new%d", i), "last")
+			}
+			maxWriterLatency = max(maxWriterLatency, time.Since(start))
+			if err != nil {
+				writerErr <- err
+				return
+			}
+		}
+	}()
+
+	visits := map[string]int{}
+	err := testManager.ForEachBatch(50, func(batch []User) error {
+		for _, user := range batch {
+			visits[user.FirstName]++
+		}
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	close(done)
+	if werr := <-writerErr; werr != nil {
+		t.Fatalf("writer failed: %v", werr)
+	}
+	if err != nil {
+		t.Fatalf("error iterating users: %v", err)
+	}
+
+	for i := range existing {
+		name := fmt.Sprintf("old%d", i)
+		if visits[name] != 1 {
+			t.Errorf("user %q visited %d times, expected once", name, visits[name])
+		}
+	}
+	for name, count := range visits {
+		if count > 1 {
+			t.Errorf("user %q visited %d times", name, count)
+		}
+		if strings.HasPrefix(name, "new") {
+			t.Errorf("user %q added during the scan was visited", name)
+		}
+	}
+
+	if maxWriterLatency > 250*time.Millisecond {
+		t.Errorf("writers stalled during iteration: max latency %v", maxWriterLatency)
+	}
+}
+
+func TestUpdateUserTimestamps(t *testing.T) {
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	updatedAt := createdAt.Add(90 * time.Minute)
+	testManager := NewManager(WithClock(func() time.Time { return createdAt }))
+
+	err := testManager.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	if err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+
+	testManager.now = func() time.Time { return updatedAt }
+	err = testManager.UpdateUser(context.Background(), "jhon", "smith", "jhon@bar.com", AnyVersion)
+	if err != nil {
+		t.Fatalf("error updating test user: %v", err)
+	}
+
+	user, err := testManager.GetUserByEmail(context.Background(), "jhon@bar.com")
+	if err != nil {
+		t.Fatalf("error getting updated user by email: %v", err)
+	}
+	if !user.CreatedAt.Equal(createdAt) {
+		t.Errorf("bad CreatedAt: expected %v, got %v", createdAt, user.CreatedAt)
+	}
+	if !user.UpdatedAt.Equal(updatedAt) {
+		t.Errorf("bad UpdatedAt: expected %v, got %v", updatedAt, user.UpdatedAt)
+	}
+
+	_, err = testManager.GetUserByEmail(context.Background(), "foo@bar.com")
+	if !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("old email still indexed: expected %v, got %v", ErrNoResultFound, err)
+	}
+}
+
+func TestUpdateUserErrors(t *testing.T) {
+	testManager := NewManager()
+	for _, name := range []string{"jhon", "jane"} {
+		err := testManager.AddUser(context.Background(), name, "smith", name+"@bar.com")
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	err := testManager.UpdateUser(context.Background(), "jhon", "smith", "jane@bar.com", AnyVersion)
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("error mismatch: expected %v, got %v", ErrDuplicateEmail, err)
+	}
+
+	err = testManager.UpdateUser(context.Background(), "nobody", "smith", "nobody@bar.com", AnyVersion)
+	if !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("error mismatch: expected %v, got %v", ErrNoResultFound, err)
+	}
+
+	err = testManager.UpdateUser(context.Background(), "jhon", "smith", "jhon@bar.com", AnyVersion)
+	if err != nil {
+		t.Errorf("error updating user to its own email: %v", err)
+	}
+}
+
+func TestSnapshotRevision(t *testing.T) {
+	testManager := NewManager()
+
+	rev0, all := testManager.Snapshot()
+	if len(all) != 0 {
+		t.Fatalf("bad snapshot size: expected 0, got %d", len(all))
+	}
+
+	err := testManager.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	if err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+	rev1, all := testManager.Snapshot()
+	if rev1 == rev0 || len(all) != 1 {
+		t.Fatalf("bad snapshot after add: revision %d -> %d, %d users", rev0, rev1, len(all))
+	}
+
+	if rev, _ := testManager.Snapshot(); rev != rev1 {
+		t.Errorf("revision changed without mutation: %d -> %d", rev1, rev)
+	}
+
+	err = testManager.UpdateUser(context.Background(), "jhon", "smith", "jhon@bar.com", AnyVersion)
+	if err != nil {
+		t.Fatalf("error updating test user: %v", err)
+	}
+	rev2, _ := testManager.Snapshot()
+	if rev2 == rev1 {
+		t.Errorf("revision not bumped by update")
+	}
+
+	err = testManager.DeleteUser(context.Background(), "jhon", "smith")
+	if err != nil {
+		t.Fatalf("error deleting test user: %v", err)
+	}
+	rev3, _ := testManager.Snapshot()
+	if rev3 == rev2 {
+		t.Errorf("revision not bumped by delete")
+	}
+	if rev, _ := testManager.Revision(context.Background()); rev != rev3 {
+		t.Errorf("Revision disagrees with Snapshot: %d, %d", rev, rev3)
+	}
+}
+
+func TestCaseInsensitiveNames(t *testing.T) {
+	testManager := NewManager(WithCaseInsensitiveNames())
+
+	err := testManager.AddUser(context.Background(), " jhon ", "smith  ", "foo@bar.com")
+	if err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+	err = testManager.AddUser(context.Background(), "łukasz", "nowak", "lukasz@bar.com")
+	if err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+
+	stored := testManager.GetAllUsers()[0]
+	if stored.FirstName != "jhon" || stored.LastName != "smith" {
+		t.Errorf("names not trimmed: got %q %q", stored.FirstName, stored.LastName)
+	}
+
+	tests := []struct {
+		first string
+		last  string
+		want  string
+	}{
+		{"Jhon", "Smith", "jhon"},
+		{"JHON", "sMiTh", "jhon"},
+		{"  jhon", "smith  ", "jhon"},
+		{"Łukasz", "Nowak", "łukasz"},
+		{"ŁUKASZ", "NOWAK", "łukasz"},
+	}
+	for _, tt := range tests {
+		user, err := testManager.GetUserByName(context.Background(), tt.first, tt.last)
+		if err != nil {
+			t.Errorf("error getting user %q %q: %v", tt.first, tt.last, err)
+			continue
+		}
+		if user.FirstName != tt.want {
+			t.Errorf("wrong user for %q %q: got %q", tt.first, tt.last, user.FirstName)
+		}
+	}
+
+	err = testManager.AddUser(context.Background(), "JHON", " Smith", "other@bar.com")
+	if !errors.Is(err, ErrDuplicateUser) {
+		t.Errorf("error mismatch: expected %v, got %v", ErrDuplicateUser, err)
+	}
+
+	err = testManager.AddUser(context.Background(), "  ", "smith", "blank@bar.com")
+	if err == nil {
+		t.Errorf("no error returned for whitespace-only first name")
+	}
+
+	err = testManager.DeleteUser(context.Background(), "Łukasz", "NOWAK")
+	if err != nil {
+		t.Errorf("error deleting user case-insensitively: %v", err)
+	}
+}
+
+func TestCaseSensitiveNamesByDefault(t *testing.T) {
+	testManager := NewManager()
+
+	err := testManager.AddUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	if err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+
+	_, err = testManager.GetUserByName(context.Background(), "Jhon", "Smith")
+	if !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("error mismatch: expected %v, got %v", ErrNoResultFound, err)
+	}
+
+	// Surrounding whitespace is trimmed whatever the case rules.
+	err = testManager.AddUser(context.Background(), " jhon ", "smith", "other@bar.com")
+	if !errors.Is(err, ErrDuplicateUser) {
+		t.Errorf("error mismatch: expected %v, got %v", ErrDuplicateUser, err)
+	}
+}
+
+func TestGetOrCreateUser(t *testing.T) {
+	testManager := NewManager()
+
+	user, created, err := testManager.GetOrCreateUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	if err != nil {
+		t.Fatalf("error creating user: %v", err)
+	}
+	if !created || user.FirstName != "jhon" {
+		t.Errorf("bad first call: created %v, user %v", created, user)
+	}
+
+	again, created, err := testManager.GetOrCreateUser(context.Background(), "jhon", "smith", "foo@bar.com")
+	if err != nil {
+		t.Fatalf("error getting existing user: %v", err)
+	}
+	if created || !reflect.DeepEqual(user, again) {
+		t.Errorf("bad retry: created %v, expected %v, got %v", created, user, again)
+	}
+
+	conflicting, _, err := testManager.GetOrCreateUser(context.Background(), "jhon", "smith", "other@bar.com")
+	var conflict *EmailConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrDuplicateUser) {
+		t.Fatalf("error mismatch: expected *EmailConflictError wrapping %v, got %v", ErrDuplicateUser, err)
+	}
+	if conflict.Email != "foo@bar.com" || conflict.Requested != "other@bar.com" || conflicting != nil {
+		t.Errorf("bad conflict: %+v, user %v", conflict, conflicting)
+	}
+
+	_, _, err = testManager.GetOrCreateUser(context.Background(), "jane", "smith", "foo@bar.com")
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("error mismatch: expected %v, got %v", ErrDuplicateEmail, err)
+	}
+
+	if len(testManager.GetAllUsers()) != 1 {
+		t.Errorf("bad user count: expected 1, got %d", len(testManager.GetAllUsers()))
+	}
+}
+
+func TestGetOrCreateUserConcurrent(t *testing.T) {
+	testManager := NewManager()
+
+	var created atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			user, ok, err := testManager.GetOrCreateUser(context.Background(), "jhon", "smith", "foo@bar.com")
+			if err != nil || user.Email.Address != "foo@bar.com" {
+				t.Errorf("unexpected result: %v, %v", user, err)
+				return
+			}
+			if ok {
+				created.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+
+	if created.Load() != 1 {
+		t.Errorf("expected exactly one creation, got %d", created.Load())
+	}
+	if n := len(testManager.GetAllUsers()); n != 1 {
+		t.Errorf("bad user count: expected 1, got %d", n)
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	testManager := NewManager()
+	for i := range 10000 {
+		err := testManager.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := testManager.ExportNDJSON(context.Background(), &buf); err != nil {
+		t.Fatal("error exporting users:", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 10000 {
+		t.Fatalf("expected 10000 lines, got %d", len(lines))
+	}
+	for i, line := range lines {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("line %d does not parse: %v", i+1, err)
+		}
+		if want := fmt.Sprintf("user%d@bar.com", i); record["email"] != want {
+			t.Fatalf("line %d: expected email %q, got %v", i+1, want, record["email"])
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	testManager := NewManager()
+	if err := testManager.AddUser(context.Background(), "jhon", "smith", "foo@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testManager.SetPassword("jhon", "smith", "short"); !errors.Is(err, ErrPasswordTooShort) {
+		t.Errorf("expected ErrPasswordTooShort, got %v", err)
+	}
+	if err := testManager.SetPassword("no", "body", "long enough"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("expected ErrNoResultFound, got %v", err)
+	}
+
+	ok, err := testManager.CheckPassword("jhon", "smith", "")
+	if ok || err != nil {
+		t.Errorf("user without a password should not match: %v %v", ok, err)
+	}
+
+	if err := testManager.SetPassword("jhon", "smith", "correct horse"); err != nil {
+		t.Fatal("error setting password:", err)
+	}
+
+	ok, err = testManager.CheckPassword("jhon", "smith", "correct horse")
+	if !ok || err != nil {
+		t.Errorf("correct password should match: %v %v", ok, err)
+	}
+	ok, err = testManager.CheckPassword("jhon", "smith", "battery staple")
+	if ok || err != nil {
+		t.Errorf("wrong password should not match: %v %v", ok, err)
+	}
+	if _, err := testManager.CheckPassword("no", "body", "correct horse"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("expected ErrNoResultFound, got %v", err)
+	}
+}
+
+func TestPasswordHashNotExposed(t *testing.T) {
+	testManager := NewManager()
+	if err := testManager.AddUser(context.Background(), "jhon", "smith", "foo@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testManager.SetPassword("jhon", "smith", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := testManager.GetUserByName(context.Background(), "jhon", "smith")
+	hash := user.passwordHash
+	if hash == "" {
+		t.Fatal("password hash not stored")
+	}
+
+	marshalled, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export bytes.Buffer
+	if _, err := testManager.ExportNDJSON(context.Background(), &export); err != nil {
+		t.Fatal(err)
+	}
+
+	outputs := map[string]string{
+		"json":   string(marshalled),
+		"ndjson": export.String(),
+		"String": user.String(),
+		"%v":     fmt.Sprintf("%v", *user),
+		"%+v":    fmt.Sprintf("%+v", *user),
+	}
+	for name, out := range outputs {
+		if strings.Contains(out, hash) || strings.Contains(out, "$2a$") {
+			t.Errorf("%s output contains hash material: %s", name, out)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	testManager := NewManager()
+	for i := range 600 {
+		err := testManager.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", i))
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	i := 0
+	for user := range testManager.All() {
+		if user.FirstName != fmt.Sprintf("first%d", i) {
+			t.Fatalf("bad iteration order at %d: got %q", i, user.FirstName)
+		}
+		i++
+	}
+	if i != 600 {
+		t.Errorf("expected 600 users, got %d", i)
+	}
+}
+
+func TestAllBreakReleasesLock(t *testing.T) {
+	testManager := NewManager()
+	for i := range 10 {
+		err := testManager.AddUser(context.Background(), fmt.Sprintf("first%d", i), "last", fmt.Sprintf("user%d@bar.com", i))
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	for user := range testManager.All() {
+		// Writing from inside the loop would deadlock if a lock were held
+		// while yielding.
+		if err := testManager.UpdateUser(context.Background(), user.FirstName, user.LastName, "changed@bar.com", AnyVersion); err != nil {
+			t.Fatalf("error updating user inside loop: %v", err)
+		}
+		break
+	}
+
+	done := make(chan error)
+	go func() { done <- testManager.AddUser(context.Background(), "after", "break", "after@bar.com") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("error adding user after break: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("AddUser blocked after breaking out of All")
+	}
+}
+
+func TestAllConcurrentAdds(t *testing.T) {
+	testManager := NewManager()
+	for i := range 1000 {
+		err := testManager.AddUser(context.Background(), fmt.Sprintf("old%d", i), "last", fmt.Sprintf("old%d@bar.com", i))
+		if err != nil {
+			t.Fatalf("error adding test user: %v", err)
+		}
+	}
+
+	writerErr := make(chan error, 1)
+	go func() {
+		defer close(writerErr)
+		for i := range 1000 {
+			if err := testManager.AddUser(context.Background(), fmt.Sprintf("new%d", i), "last", fmt.Sprintf("new%d@bar.com", i)); err != nil {
+				writerErr <- err
+				return
+			}
+		}
+	}()
+
+	old := 0
+	for user := range testManager.All() {
+		if strings.HasPrefix(user.FirstName, "old") {
+			old++
+		}
+	}
+	if err := <-writerErr; err != nil {
+		t.Fatalf("error adding user concurrently: %v", err)
+	}
+	if old != 1000 {
+		t.Errorf("expected every existing user once, got %d", old)
+	}
+}
+
+func TestAllSorted(t *testing.T) {
+	testManager := NewManager()
+	for _, name := range []string{"carol", "alice", "bob", "alice2"} {
+		if err := testManager.AddUser(context.Background(), name, "last", name+"@bar.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for user := range testManager.AllSorted(func(a, b User) bool { return a.FirstName < b.FirstName }) {
+		got = append(got, user.FirstName)
+		if len(got) == 3 {
+			break
+		}
+	}
+	if want := []string{"alice", "alice2", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestValidateUser(t *testing.T) {
+	if err := ValidateUser("jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Errorf("unexpected error for valid user: %v", err)
+	}
+
+	err := ValidateUser(" ", "smith", "nope")
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	expected := ValidationErrors{
+		{Field: "first_name", Message: "must not be empty"},
+		{Field: "email", Message: "must be a valid email address"},
+	}
+	if !slices.Equal(verrs, expected) {
+		t.Errorf("bad validation errors: expected %v, got %v", expected, verrs)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	m := NewManager()
+	if err := m.AddUserWithRole("Ada", "Admin", "ada@example.com", "admin"); err != nil {
+		t.Fatalf("error adding admin: %v", err)
+	}
+	if err := m.AddUser(context.Background(), "Max", "Member", "max@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := m.GetUserByName(context.Background(), "Max", "Member")
+	if err != nil || user.Role != RoleMember {
+		t.Errorf("bad default role: expected %q, got %+v (%v)", RoleMember, user, err)
+	}
+
+	admins, err := m.GetUsersByRole("admin")
+	if err != nil || len(admins) != 1 || admins[0].FirstName != "Ada" {
+		t.Errorf("bad admins: %v (%v)", admins, err)
+	}
+
+	var roleErr *InvalidRoleError
+	err = m.AddUserWithRole("Sam", "Super", "sam@example.com", "superuser")
+	if !errors.As(err, &roleErr) || roleErr.Role != "superuser" {
+		t.Errorf("expected *InvalidRoleError for unknown role, got %v", err)
+	}
+	if _, err := m.GetUserByName(context.Background(), "Sam", "Super"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("user with invalid role was stored: %v", err)
+	}
+	if err := m.SetRole("Max", "Member", "root"); !errors.As(err, &roleErr) {
+		t.Errorf("expected *InvalidRoleError from SetRole, got %v", err)
+	}
+	if _, err := m.GetUsersByRole("root"); !errors.As(err, &roleErr) {
+		t.Errorf("expected *InvalidRoleError from GetUsersByRole, got %v", err)
+	}
+
+	if err := m.SetRole("Max", "Member", "admin"); err != nil {
+		t.Fatalf("error promoting member: %v", err)
+	}
+	admins, _ = m.GetUsersByRole("admin")
+	if len(admins) != 2 {
+		t.Errorf("expected 2 admins after promotion, got %d", len(admins))
+	}
+}
+
+func TestSearch(t *testing.T) {
+	m := NewManager()
+	for _, u := range [][3]string{
+		{"John", "Smith", "john@example.com"},
+		{"Joanna", "Baker", "joanna@example.com"},
+		{"Mary", "Jones", "mary@example.com"},
+		{"Peter", "Parker", "peter@example.com"},
+	} {
+		if err := m.AddUser(context.Background(), u[0], u[1], u[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(all []User) []string {
+		var result []string
+		for _, u := range all {
+			result = append(result, u.FirstName)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		q        string
+		opts     SearchOptions
+		expected []string
+	}{
+		{name: "prefix of first or last name", q: "jo", expected: []string{"John", "Joanna", "Mary"}},
+		{name: "case-insensitive", q: "PARK", expected: []string{"Peter"}},
+		{name: "no match", q: "zed", expected: nil},
+		{name: "empty", q: "  ", expected: nil},
+		{name: "typo without fuzzy", q: "jhon", expected: nil},
+		{name: "transposition or deletion", q: "jhon", opts: SearchOptions{Fuzzy: true}, expected: []string{"John", "Mary"}},
+		{name: "substitution", q: "smyth", opts: SearchOptions{Fuzzy: true}, expected: []string{"John"}},
+		{name: "deletion", q: "pter", opts: SearchOptions{Fuzzy: true}, expected: []string{"Peter"}},
+		{name: "two edits", q: "jhonn", opts: SearchOptions{Fuzzy: true}, expected: nil},
+		{name: "limit", q: "jo", opts: SearchOptions{Limit: 2}, expected: []string{"John", "Joanna"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := names(m.Search(tc.q, tc.opts))
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("bad results for %q: expected %v, got %v", tc.q, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWithinOneEdit(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"john", "john", true},
+		{"jhon", "john", true},
+		{"jon", "john", true},
+		{"johnn", "john", true},
+		{"jahn", "john", true},
+		{"jhno", "john", false},
+		{"jo", "john", false},
+		{"", "j", true},
+	}
+	for _, tc := range tests {
+		if got := withinOneEdit([]rune(tc.a), []rune(tc.b)); got != tc.expected {
+			t.Errorf("withinOneEdit(%q, %q): expected %v, got %v", tc.a, tc.b, tc.expected, got)
+		}
+	}
+}
+
+func TestAddUsersRollsBack(t *testing.T) {
+	m := NewManager()
+	if err := m.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	rev, _ := m.Snapshot()
+
+	_, err := m.AddUsers([]NewUser{
+		{FirstName: "ada", LastName: "lovelace", Email: "ada@bar.com"},
+		{FirstName: "grace", LastName: "hopper", Email: "grace@bar.com"},
+		{FirstName: "jhon", LastName: "smith", Email: "other@bar.com"},
+	})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 2 || !errors.Is(err, ErrDuplicateUser) {
+		t.Fatalf("expected BatchError for entry 2 wrapping ErrDuplicateUser, got %v", err)
+	}
+
+	if got, _ := m.Snapshot(); got != rev {
+		t.Errorf("revision changed by a rolled back batch: expected %d, got %d", rev, got)
+	}
+	if n := len(m.GetAllUsers()); n != 1 {
+		t.Errorf("expected 1 user after rollback, got %d", n)
+	}
+	if _, err := m.GetUserByEmail(context.Background(), "ada@bar.com"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("rolled back user still indexed: %v", err)
+	}
+
+	added, err := m.AddUsers([]NewUser{{FirstName: "ada", LastName: "lovelace", Email: "ada@bar.com"}})
+	if err != nil || len(added) != 1 {
+		t.Fatalf("error adding after rollback: %v", err)
+	}
+	if _, err := m.AddUsers([]NewUser{
+		{FirstName: "a", LastName: "b", Email: "same@bar.com"},
+		{FirstName: "c", LastName: "d", Email: "same@bar.com"},
+	}); !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("expected entries to conflict with each other, got %v", err)
+	}
+}
+
+func TestUpdateUserVersion(t *testing.T) {
+	testManager := NewManager()
+	if err := testManager.AddUser(context.Background(), "jhon", "smith", "jhon@bar.com"); err != nil {
+		t.Fatalf("error adding test user: %v", err)
+	}
+
+	user, _ := testManager.GetUserByName(context.Background(), "jhon", "smith")
+	if user.Version != 1 {
+		t.Fatalf("bad initial version: expected 1, got %d", user.Version)
+	}
+
+	if err := testManager.UpdateUser(context.Background(), "jhon", "smith", "first@bar.com", user.Version); err != nil {
+		t.Fatalf("error updating with current version: %v", err)
+	}
+
+	// A second writer still holding version 1 must not clobber the update.
+	err := testManager.UpdateUser(context.Background(), "jhon", "smith", "second@bar.com", user.Version)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("error mismatch: expected %v, got %v", ErrVersionConflict, err)
+	}
+
+	updated, _ := testManager.GetUserByName(context.Background(), "jhon", "smith")
+	if updated.Email.Address != "first@bar.com" || updated.Version != 2 {
+		t.Errorf("bad user after stale write: %+v", updated)
+	}
+
+	if err := testManager.SetRole("jhon", "smith", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if updated, _ = testManager.GetUserByName(context.Background(), "jhon", "smith"); updated.Version != 3 {
+		t.Errorf("version not bumped by SetRole: got %d", updated.Version)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	testManager := NewManager(WithClock(func() time.Time { return now }))
+	for _, name := range []string{"ada", "grace"} {
+		if err := testManager.AddUser(context.Background(), name, "smith", name+"@bar.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := testManager.DeleteUser(context.Background(), "ada", "smith"); err != nil {
+		t.Fatalf("error deleting user: %v", err)
+	}
+
+	if _, err := testManager.GetUserByName(context.Background(), "ada", "smith"); !errors.Is(err, ErrUserDeleted) || !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("GetUserByName: expected ErrUserDeleted wrapping ErrNoResultFound, got %v", err)
+	}
+	if _, err := testManager.GetUserByEmail(context.Background(), "ada@bar.com"); !errors.Is(err, ErrUserDeleted) {
+		t.Errorf("GetUserByEmail: expected ErrUserDeleted, got %v", err)
+	}
+	if _, err := testManager.GetUserByName(context.Background(), "no", "body"); err != ErrNoResultFound {
+		t.Errorf("GetUserByName: expected ErrNoResultFound for an unknown user, got %v", err)
+	}
+	if err := testManager.DeleteUser(context.Background(), "ada", "smith"); !errors.Is(err, ErrUserDeleted) {
+		t.Errorf("DeleteUser twice: expected ErrUserDeleted, got %v", err)
+	}
+	if all := testManager.GetAllUsers(); len(all) != 1 || all[0].FirstName != "grace" {
+		t.Errorf("GetAllUsers: expected only grace, got %v", all)
+	}
+	if found := testManager.Search("ad", SearchOptions{}); len(found) != 0 {
+		t.Errorf("Search: expected no results, got %v", found)
+	}
+
+	deleted := testManager.DeletedUsers()
+	if len(deleted) != 1 || deleted[0].DeletedAt == nil || !deleted[0].DeletedAt.Equal(now) {
+		t.Fatalf("DeletedUsers: expected ada deleted at %v, got %+v", now, deleted)
+	}
+	if deleted[0].Version != 2 {
+		t.Errorf("bad tombstone version: expected 2, got %d", deleted[0].Version)
+	}
+
+	now = now.Add(time.Hour)
+	if err := testManager.RestoreUser("ada", "smith"); err != nil {
+		t.Fatalf("error restoring user: %v", err)
+	}
+	restored, err := testManager.GetUserByEmail(context.Background(), "ada@bar.com")
+	if err != nil {
+		t.Fatalf("restored user not found: %v", err)
+	}
+	if restored.DeletedAt != nil || !restored.UpdatedAt.Equal(now) || restored.Version != 3 {
+		t.Errorf("bad restored user: %+v", restored)
+	}
+	if len(testManager.DeletedUsers()) != 0 {
+		t.Errorf("tombstone kept after restore")
+	}
+	if err := testManager.RestoreUser("ada", "smith"); !errors.Is(err, ErrDuplicateUser) {
+		t.Errorf("RestoreUser of a live user: expected ErrDuplicateUser, got %v", err)
+	}
+	if err := testManager.RestoreUser("no", "body"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("RestoreUser of an unknown user: expected ErrNoResultFound, got %v", err)
+	}
+}
+
+// Adding a user whose name or email matches only a soft-deleted user is
+// allowed and creates a new user; the tombstone then cannot be restored
+// until the new user is gone.
+func TestSoftDeleteReuse(t *testing.T) {
+	testManager := NewManager()
+	if err := testManager.AddUser(context.Background(), "ada", "smith", "ada@bar.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testManager.DeleteUser(context.Background(), "ada", "smith"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testManager.AddUser(context.Background(), "ada", "smith", "ada2@bar.com"); err != nil {
+		t.Fatalf("error adding user over a deleted name: %v", err)
+	}
+	if err := testManager.RestoreUser("ada", "smith"); !errors.Is(err, ErrDuplicateUser) {
+		t.Errorf("expected ErrDuplicateUser, got %v", err)
+	}
+
+	if err := testManager.AddUser(context.Background(), "other", "person", "ada@bar.com"); err != nil {
+		t.Fatalf("error adding user over a deleted email: %v", err)
+	}
+	if err := testManager.DeleteUser(context.Background(), "ada", "smith"); err != nil {
+		t.Fatal(err)
+	}
+	// The most recent tombstone wins, and its email is free.
+	if err := testManager.RestoreUser("ada", "smith"); err != nil {
+		t.Fatalf("error restoring the newer tombstone: %v", err)
+	}
+	if user, err := testManager.GetUserByName(context.Background(), "ada", "smith"); err != nil || user.Email.Address != "ada2@bar.com" {
+		t.Errorf("expected ada2@bar.com restored, got %v, %v", user, err)
+	}
+	if err := testManager.DeleteUser(context.Background(), "other", "person"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testManager.DeleteUser(context.Background(), "ada", "smith"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testManager.RestoreUser("ada", "smith"); err != nil {
+		t.Fatalf("error restoring: %v", err)
+	}
+	if err := testManager.RestoreUser("ada", "smith"); !errors.Is(err, ErrDuplicateUser) {
+		t.Errorf("expected ErrDuplicateUser, got %v", err)
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	testManager := NewManager(WithClock(func() time.Time { return now }))
+	for i := range 3 {
+		name := fmt.Sprintf("user%d", i)
+		if err := testManager.AddUser(context.Background(), name, "smith", name+"@bar.com"); err != nil {
+			t.Fatal(err)
+		}
+		if err := testManager.DeleteUser(context.Background(), name, "smith"); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(24 * time.Hour)
+	}
+
+	// user0 was deleted 72h ago, user1 48h ago and user2 24h ago.
+	if n := testManager.PurgeDeleted(48 * time.Hour); n != 2 {
+		t.Errorf("bad purge count: expected 2, got %d", n)
+	}
+	if _, err := testManager.GetUserByName(context.Background(), "user0", "smith"); err != ErrNoResultFound {
+		t.Errorf("purged user: expected ErrNoResultFound, got %v", err)
+	}
+	if err := testManager.RestoreUser("user1", "smith"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("purged user restored: got %v", err)
+	}
+	if err := testManager.RestoreUser("user2", "smith"); err != nil {
+		t.Errorf("error restoring unpurged user: %v", err)
+	}
+	if n := testManager.PurgeDeleted(0); n != 0 {
+		t.Errorf("bad purge count: expected 0, got %d", n)
+	}
+}
@@ -0,0 +1,64 @@
+package users
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// FieldError is a problem with one field of a user.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"error"`
+}
+
+// ValidationErrors lists every field-level problem found, so callers can
+// report them all at once instead of one per round trip.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, fe := range v {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "invalid user: " + strings.Join(msgs, "; ")
+}
+
+// ValidateUser checks every field of a new user and returns a
+// ValidationErrors naming each bad one, or nil when all are valid. Field
+// names match the JSON request body. Names are checked against the zero
+// NameRules.
+func ValidateUser(firstName string, lastName string, email string) error {
+	return NameRules{}.ValidateUser(firstName, lastName, email)
+}
+
+// ValidateUser is the package-level ValidateUser with names checked
+// against r.
+func (r NameRules) ValidateUser(firstName string, lastName string, email string) error {
+	var errs ValidationErrors
+	errs = append(errs, r.FieldErrors("first_name", firstName)...)
+	errs = append(errs, r.FieldErrors("last_name", lastName)...)
+	if strings.TrimSpace(email) == "" {
+		errs = append(errs, FieldError{Field: "email", Message: "must not be empty"})
+	} else if _, err := mail.ParseAddress(email); err != nil {
+		errs = append(errs, FieldError{Field: "email", Message: "must be a valid email address"})
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// FieldErrors checks name against r and returns a FieldError for field per
+// rule it breaks.
+func (r NameRules) FieldErrors(field string, name string) []FieldError {
+	var invalid *InvalidNameError
+	if !errors.As(r.Validate(name), &invalid) {
+		return nil
+	}
+	errs := make([]FieldError, len(invalid.Reasons))
+	for i, reason := range invalid.Reasons {
+		errs[i] = FieldError{Field: field, Message: reason}
+	}
+	return errs
+}
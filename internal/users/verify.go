@@ -0,0 +1,112 @@
+package users
+
+import (
+	"crypto/rand"
+	"errors"
+	"slices"
+	"time"
+)
+
+// DefaultVerificationTTL is how long a verification token stays valid
+// unless WithVerificationTTL says otherwise.
+const DefaultVerificationTTL = 48 * time.Hour
+
+var (
+	// ErrInvalidToken is returned by VerifyUser for a token that was never
+	// issued, was already used, or belongs to a user that was deleted.
+	ErrInvalidToken = errors.New("invalid verification token")
+
+	// ErrTokenExpired is returned by VerifyUser for a token older than the
+	// verification TTL. The token is discarded.
+	ErrTokenExpired = errors.New("verification token expired")
+
+	// ErrAlreadyVerified is returned by VerificationToken for a user whose
+	// address is already confirmed.
+	ErrAlreadyVerified = errors.New("user is already verified")
+)
+
+// pendingVerification ties a token to the user it was issued for. Users are
+// identified by sequence number rather than name, so a token outlives
+// neither its user's deletion nor the reuse of that user's name.
+type pendingVerification struct {
+	seq     uint64
+	expires time.Time
+}
+
+// WithVerificationTTL sets how long verification tokens stay valid.
+func WithVerificationTTL(ttl time.Duration) Option {
+	return func(m *Manager) {
+		m.verificationTTL = ttl
+	}
+}
+
+// issueToken creates the verification token for the user at position i. The
+// caller holds the write lock.
+func (m *Manager) issueToken(i int) {
+	token := rand.Text()
+	seq := m.seqs[i]
+	m.tokens[token] = pendingVerification{seq: seq, expires: m.now().Add(m.verificationTTL)}
+	m.tokenBySeq[seq] = token
+}
+
+// dropToken forgets any token issued for the user with the given sequence
+// number. The caller holds the write lock.
+func (m *Manager) dropToken(seq uint64) {
+	if token, ok := m.tokenBySeq[seq]; ok {
+		delete(m.tokens, token)
+		delete(m.tokenBySeq, seq)
+	}
+}
+
+// VerificationToken returns the pending verification token of the named
+// user, for delivery by email. It fails with ErrAlreadyVerified once the
+// user is verified and with ErrNoResultFound when the user has no pending
+// token, as after a restart, since tokens are not kept in snapshots.
+func (m *Manager) VerificationToken(first string, last string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i, n := m.nameIndex(first, last)
+	if n == 0 {
+		return "", m.missingByName(first, last)
+	}
+	if n > 1 {
+		return "", ErrAmbiguousName
+	}
+	if m.users[i].Verified {
+		return "", ErrAlreadyVerified
+	}
+	token, ok := m.tokenBySeq[m.seqs[i]]
+	if !ok {
+		return "", ErrNoResultFound
+	}
+	return token, nil
+}
+
+// VerifyUser marks the user the token was issued for as verified and
+// consumes the token. Unknown and used tokens are rejected with
+// ErrInvalidToken, expired ones with ErrTokenExpired.
+func (m *Manager) VerifyUser(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending, ok := m.tokens[token]
+	if !ok {
+		return ErrInvalidToken
+	}
+	m.dropToken(pending.seq)
+	if !m.now().Before(pending.expires) {
+		return ErrTokenExpired
+	}
+
+	i, ok := slices.BinarySearch(m.seqs, pending.seq)
+	if !ok {
+		return ErrInvalidToken
+	}
+	m.users[i].Verified = true
+	m.users[i].UpdatedAt = m.now()
+	m.users[i].Version++
+	m.rev++
+
+	return nil
+}
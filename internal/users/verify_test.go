@@ -0,0 +1,128 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestVerifyUser(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManager(WithClock(func() time.Time { return now }), WithVerificationTTL(time.Hour))
+
+	if err := m.AddUser(context.Background(), "Alice", "Smith", "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token, err := m.VerificationToken("Alice", "Smith")
+	if err != nil || token == "" {
+		t.Fatalf("VerificationToken: %q, %v", token, err)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if err := m.VerifyUser(token); err != nil {
+		t.Fatalf("VerifyUser: %v", err)
+	}
+	user, _ := m.GetUserByName(context.Background(), "Alice", "Smith")
+	if !user.Verified || user.Version != 2 || !user.UpdatedAt.Equal(now) {
+		t.Errorf("user not marked verified: %+v", user)
+	}
+
+	if err := m.VerifyUser(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("reused token: expected ErrInvalidToken, got %v", err)
+	}
+	if _, err := m.VerificationToken("Alice", "Smith"); !errors.Is(err, ErrAlreadyVerified) {
+		t.Errorf("expected ErrAlreadyVerified, got %v", err)
+	}
+	if err := m.VerifyUser("no such token"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unknown token: expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestVerifyUserExpired(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManager(WithClock(func() time.Time { return now }), WithVerificationTTL(time.Hour))
+
+	m.AddUser(context.Background(), "Alice", "Smith", "alice@example.com")
+	token, _ := m.VerificationToken("Alice", "Smith")
+
+	now = now.Add(time.Hour)
+	if err := m.VerifyUser(token); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expected ErrTokenExpired, got %v", err)
+	}
+	if err := m.VerifyUser(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expired token should be discarded, got %v", err)
+	}
+	if user, _ := m.GetUserByName(context.Background(), "Alice", "Smith"); user.Verified {
+		t.Error("user verified with an expired token")
+	}
+}
+
+func TestVerifyUserDeleted(t *testing.T) {
+	m := NewManager()
+	m.AddUser(context.Background(), "Alice", "Smith", "alice@example.com")
+	token, _ := m.VerificationToken("Alice", "Smith")
+
+	// A new user with the same name must not be verified by the old token.
+	m.DeleteUser(context.Background(), "Alice", "Smith")
+	m.AddUser(context.Background(), "Alice", "Smith", "alice@example.org")
+	if err := m.VerifyUser(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+	fresh, err := m.VerificationToken("Alice", "Smith")
+	if err != nil || fresh == token {
+		t.Errorf("expected a new token for the new user, got %q, %v", fresh, err)
+	}
+}
+
+func TestVerificationTokensForBatch(t *testing.T) {
+	m := NewManager()
+	_, err := m.AddUsers([]NewUser{
+		{FirstName: "Alice", LastName: "Smith", Email: "alice@example.com"},
+		{FirstName: "Bob", LastName: "Jones", Email: "bob@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := m.VerificationToken("Alice", "Smith")
+	b, _ := m.VerificationToken("Bob", "Jones")
+	if a == "" || b == "" || a == b {
+		t.Fatalf("expected distinct tokens, got %q and %q", a, b)
+	}
+
+	m.AddUsers([]NewUser{
+		{FirstName: "Carol", LastName: "White", Email: "carol@example.com"},
+		{FirstName: "Alice", LastName: "Smith", Email: "alice@example.org"},
+	})
+	if _, err := m.VerificationToken("Carol", "White"); !errors.Is(err, ErrNoResultFound) {
+		t.Errorf("rolled back user kept a token: %v", err)
+	}
+}
+
+func TestSearchVerifiedFilter(t *testing.T) {
+	m := NewManager()
+	m.AddUser(context.Background(), "Alice", "Smith", "alice@example.com")
+	m.AddUser(context.Background(), "Alan", "Jones", "alan@example.com")
+	token, _ := m.VerificationToken("Alan", "Jones")
+	m.VerifyUser(token)
+
+	verified, unverified := true, false
+	tests := []struct {
+		verified *bool
+		want     []string
+	}{
+		{nil, []string{"Alice", "Alan"}},
+		{&verified, []string{"Alan"}},
+		{&unverified, []string{"Alice"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, u := range m.Search("al", SearchOptions{Verified: tt.verified}) {
+			got = append(got, u.FirstName)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("verified=%v: expected %q, got %q", tt.verified, tt.want, got)
+		}
+	}
+}
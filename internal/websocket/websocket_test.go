@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func startEcho(t *testing.T, limit int64) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadLimit(limit)
+		for {
+			op, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(op, data); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestEcho(t *testing.T) {
+	conn, err := Dial(startEcho(t, 1024) + "/")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	for _, msg := range []string{"hello", strings.Repeat("x", 300), ""} {
+		if err := conn.WriteMessage(OpText, []byte(msg)); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+		op, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		if op != OpText || !bytes.Equal(data, []byte(msg)) {
+			t.Errorf("bad echo: expected %q, got opcode %d %q", msg, op, data)
+		}
+	}
+
+	if err := conn.WriteClose(CloseNormal, "bye"); err != nil {
+		t.Fatalf("WriteClose: %v", err)
+	}
+	_, _, err = conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseNormal {
+		t.Errorf("bad close: expected code %d, got %v", CloseNormal, err)
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	conn, err := Dial(startEcho(t, 16) + "/")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(OpText, []byte(strings.Repeat("x", 17))); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	_, _, err = conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Errorf("bad close: expected code %d, got %v", CloseMessageTooBig, err)
+	}
+}
+
+func TestUpgradeRejectsPlainRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := Upgrade(w, r); !errors.Is(err, ErrBadHandshake) {
+		t.Errorf("expected ErrBadHandshake, got %v", err)
+	}
+	if w.Code != http.StatusUpgradeRequired {
+		t.Errorf("bad response code: expected %d, got %d\n", http.StatusUpgradeRequired, w.Code)
+	}
+}
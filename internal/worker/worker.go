@@ -0,0 +1,178 @@
+// Package worker runs periodic background tasks the same way everywhere. A
+// task runs every interval, give or take some jitter, until its context is
+// done. Errors are logged and recorded; a panic is recovered, logged and
+// recorded the same way, and the task keeps its schedule.
+//
+// The Runner remembers when each task last ran and how it went, for health
+// and debug endpoints.
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultJitter is the jitter of a Runner created without WithJitter.
+const DefaultJitter = 0.1
+
+// Clock is the time source of a Runner.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Status is what a Runner knows about one task.
+type Status struct {
+	Name string `json:"name"`
+	// Runs counts the calls of the task, Failures those that returned an
+	// error or panicked.
+	Runs     uint64 `json:"runs"`
+	Failures uint64 `json:"failures"`
+	// LastRun is when the last call started; zero before the first.
+	LastRun time.Time `json:"last_run,omitzero"`
+	// LastError is the error of the last call, or empty if it succeeded.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Runner runs tasks and tracks their status. It is safe for concurrent use.
+type Runner struct {
+	logger *slog.Logger
+	clock  Clock
+	jitter float64
+
+	mu    sync.Mutex
+	tasks map[string]*Status
+}
+
+type Option func(*Runner)
+
+// WithLogger logs failures to logger instead of slog.Default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Runner) {
+		r.logger = logger
+	}
+}
+
+// WithClock replaces the real clock, for tests.
+func WithClock(c Clock) Option {
+	return func(r *Runner) {
+		r.clock = c
+	}
+}
+
+// WithJitter spreads each wait uniformly over interval ± fraction×interval,
+// so tasks started together do not stay in step. 0 disables jitter.
+func WithJitter(fraction float64) Option {
+	return func(r *Runner) {
+		r.jitter = fraction
+	}
+}
+
+func NewRunner(opts ...Option) *Runner {
+	r := &Runner{
+		logger: slog.Default(),
+		clock:  realClock{},
+		jitter: DefaultJitter,
+		tasks:  make(map[string]*Status),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run calls fn every interval until ctx is done, then returns. The first
+// call is one interval after Run starts. fn is passed ctx, so a call in
+// progress at shutdown can stop early. Run panics if interval is not
+// positive or a task called name is already running.
+func (r *Runner) Run(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	if interval <= 0 {
+		panic(fmt.Sprintf("worker %s: interval must be positive, got %s", name, interval))
+	}
+	r.mu.Lock()
+	if _, ok := r.tasks[name]; ok {
+		r.mu.Unlock()
+		panic("worker " + name + " is already running")
+	}
+	r.tasks[name] = &Status{Name: name}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.tasks, name)
+		r.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.clock.After(r.wait(interval)):
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		r.runOnce(ctx, name, fn)
+	}
+}
+
+// wait returns interval with jitter applied.
+func (r *Runner) wait(interval time.Duration) time.Duration {
+	if r.jitter <= 0 {
+		return interval
+	}
+	spread := (rand.Float64()*2 - 1) * r.jitter * float64(interval)
+	return max(interval+time.Duration(spread), 0)
+}
+
+func (r *Runner) runOnce(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	start := r.clock.Now()
+	err := call(ctx, fn)
+	if err != nil {
+		r.logger.Error("background task failed", "worker", name, "err", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.tasks[name]
+	st.Runs++
+	st.LastRun = start
+	st.LastError = ""
+	if err != nil {
+		st.Failures++
+		st.LastError = err.Error()
+	}
+}
+
+// call runs fn, turning a panic into an error.
+func call(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return fn(ctx)
+}
+
+// Status returns the status of every running task, sorted by name.
+func (r *Runner) Status() []Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]Status, 0, len(r.tasks))
+	for _, st := range r.tasks {
+		out = append(out, *st)
+	}
+	slices.SortFunc(out, func(a, b Status) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
@@ -0,0 +1,226 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock fires After channels only when Advance moves past them. Every
+// After call is reported on waits, so a test can step a Runner one wait at
+// a time. waits holds one report, so a Runner that is stopping is not
+// blocked by a test that has stopped reading.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	waits   chan time.Duration
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC), waits: make(chan time.Duration, 1)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.mu.Lock()
+	c.waiters = append(c.waiters, fakeWaiter{deadline: c.now.Add(d), ch: ch})
+	c.mu.Unlock()
+	c.waits <- d
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// start runs fn on r in the background and returns a function that cancels
+// it and waits for Run to return, failing the test if that takes more than
+// a second.
+func start(t *testing.T, r *Runner, name string, interval time.Duration, fn func(context.Context) error) (stop func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx, name, interval, fn)
+		close(done)
+	}()
+	return func() {
+		t.Helper()
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Run did not return after cancel")
+		}
+	}
+}
+
+func newTestRunner(clock Clock, opts ...Option) *Runner {
+	return NewRunner(append([]Option{WithClock(clock), WithJitter(0), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))}, opts...)...)
+}
+
+func TestRunTicks(t *testing.T) {
+	clock := newFakeClock()
+	r := newTestRunner(clock)
+
+	var mu sync.Mutex
+	var calls []time.Time
+	stop := start(t, r, "tick", time.Minute, func(context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, clock.Now())
+		return nil
+	})
+
+	first := clock.Now()
+	for range 5 {
+		if d := <-clock.waits; d != time.Minute {
+			t.Fatalf("expected to wait a minute, waited %s", d)
+		}
+		clock.Advance(30 * time.Second)
+		clock.Advance(30 * time.Second)
+	}
+	// The sixth wait starts once the fifth call has returned.
+	<-clock.waits
+
+	status := r.Status()
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 5 {
+		t.Fatalf("expected 5 calls, got %d", len(calls))
+	}
+	for i, at := range calls {
+		if want := first.Add(time.Duration(i+1) * time.Minute); !at.Equal(want) {
+			t.Errorf("call %d at %s, expected %s", i, at, want)
+		}
+	}
+	if len(status) != 1 || status[0].Name != "tick" || status[0].Runs != 5 || status[0].Failures != 0 || !status[0].LastRun.Equal(calls[4]) {
+		t.Errorf("bad status: %+v", status)
+	}
+	if got := r.Status(); len(got) != 0 {
+		t.Errorf("stopped task still listed: %+v", got)
+	}
+}
+
+func TestRunRecoversPanics(t *testing.T) {
+	clock := newFakeClock()
+	r := newTestRunner(clock)
+
+	calls := 0
+	stop := start(t, r, "flaky", time.Second, func(context.Context) error {
+		calls++
+		switch calls {
+		case 1:
+			panic("boom")
+		case 2:
+			return errors.New("disk full")
+		}
+		return nil
+	})
+	defer stop()
+
+	clock.Advance(<-clock.waits)
+	d := <-clock.waits
+	if st := r.Status()[0]; st.Runs != 1 || st.Failures != 1 || !strings.Contains(st.LastError, "panic: boom") {
+		t.Errorf("after panic: %+v", st)
+	}
+	clock.Advance(d)
+	d = <-clock.waits
+	if st := r.Status()[0]; st.Runs != 2 || st.Failures != 2 || st.LastError != "disk full" {
+		t.Errorf("after error: %+v", st)
+	}
+	clock.Advance(d)
+	<-clock.waits
+	if st := r.Status()[0]; st.Runs != 3 || st.Failures != 2 || st.LastError != "" {
+		t.Errorf("after success: %+v", st)
+	}
+}
+
+func TestRunStopsPromptly(t *testing.T) {
+	clock := newFakeClock()
+	r := newTestRunner(clock)
+
+	// Cancelled while waiting for the next tick.
+	stop := start(t, r, "idle", time.Hour, func(context.Context) error { return nil })
+	<-clock.waits
+	stop()
+
+	// Cancelled while the task is running: the task sees ctx end.
+	running := make(chan struct{})
+	stop = start(t, r, "busy", time.Hour, func(ctx context.Context) error {
+		close(running)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	clock.Advance(<-clock.waits)
+	<-running
+	stop()
+}
+
+func TestRunJitter(t *testing.T) {
+	clock := newFakeClock()
+	r := newTestRunner(clock, WithJitter(0.5))
+	stop := start(t, r, "jittery", time.Minute, func(context.Context) error { return nil })
+	defer stop()
+
+	seen := make(map[time.Duration]bool)
+	for range 50 {
+		d := <-clock.waits
+		if d < 30*time.Second || d > 90*time.Second {
+			t.Fatalf("wait %s outside 1m ± 50%%", d)
+		}
+		seen[d] = true
+		clock.Advance(d)
+	}
+	<-clock.waits
+	if len(seen) < 2 {
+		t.Errorf("expected jittered waits, every wait was %v", seen)
+	}
+}
+
+func TestRunRejectsDuplicateNames(t *testing.T) {
+	clock := newFakeClock()
+	r := newTestRunner(clock)
+	stop := start(t, r, "once", time.Minute, func(context.Context) error { return nil })
+	defer stop()
+	<-clock.waits
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a second task with the same name")
+		}
+	}()
+	r.Run(context.Background(), "once", time.Minute, func(context.Context) error { return nil })
+}
@@ -0,0 +1,6 @@
+// Package proto holds the protobuf definitions for the gRPC API. Run
+// go generate ./proto to regenerate the Go stubs in
+// internal/grpcapi/greeter/v1 with buf; the generated files are committed.
+package proto
+
+//go:generate buf generate --template buf.gen.yaml